@@ -11,7 +11,6 @@ import (
 
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcutil/base58"
 )
 
 const (
@@ -22,6 +21,11 @@ const (
 	// DefaultStream is the only stream currently in use on the Bitmessage
 	// network, which is 1.
 	DefaultStream = 1
+
+	// MaxStream is the largest stream number. Stream numbers are encoded
+	// as var ints, but net addresses and version messages only have room
+	// for 32 bits.
+	MaxStream = 0xffffffff
 )
 
 var (
@@ -38,11 +42,21 @@ var (
 	// new address with a version less than 4.
 	ErrDepricatedAddressVersion = errors.New("Address versions below 4 are depricated.")
 
-	// ErrInvalidStream is returned if someone tries to create an address
-	// with stream other than 1.
-	ErrInvalidStream = errors.New("Only stream 1 is currently in use.")
+	// ErrInvalidStream is returned for a stream number which is zero or
+	// greater than MaxStream, and if someone tries to create an address
+	// with stream other than 1, which is the only one currently in use.
+	ErrInvalidStream = errors.New("invalid stream number")
 )
 
+// CheckStream returns ErrInvalidStream if stream is not a valid stream
+// number, which is to say that it is zero or greater than MaxStream.
+func CheckStream(stream uint64) error {
+	if stream == 0 || stream > MaxStream {
+		return ErrInvalidStream
+	}
+	return nil
+}
+
 // Address represents a Bitmessage address.
 type Address interface {
 	Version() uint64
@@ -51,6 +65,13 @@ type Address interface {
 	String() string
 }
 
+// AddressesEqual returns whether two addresses have the same version, stream
+// and ripe. The ripes are compared in constant time.
+func AddressesEqual(a, b Address) bool {
+	same := a.RipeHash().ConstantTimeEqual(b.RipeHash())
+	return same && a.Version() == b.Version() && a.Stream() == b.Stream()
+}
+
 // addressV4 represents a version 4  Bitmessage address.
 type addressV4 struct {
 	stream uint64
@@ -101,12 +122,7 @@ func (addr *addressV4) String() string {
 	WriteVarInt(&binaryData, addr.stream)
 	binaryData.Write(ripe)
 
-	// calc checksum from 2 rounds of SHA512
-	checksum := hash.DoubleSha512(binaryData.Bytes())[:4]
-
-	totalBin := append(binaryData.Bytes(), checksum...)
-
-	return "BM-" + string(base58.Encode(totalBin))
+	return "BM-" + Base58CheckEncode(binaryData.Bytes(), AddressChecksumSize)
 }
 
 // depricatedAddress represents a version 2 or 3 Bitmessage address.
@@ -121,6 +137,9 @@ func NewDepricatedAddress(version, stream uint64, ripe *hash.Ripe) (Address, err
 	if version < 2 || version > 3 {
 		return nil, ErrUnknownAddressType
 	}
+	if err := CheckStream(stream); err != nil {
+		return nil, err
+	}
 	return &depricatedAddress{
 		version: version,
 		stream:  stream,
@@ -159,12 +178,7 @@ func (addr *depricatedAddress) String() string {
 	WriteVarInt(&binaryData, addr.stream)
 	binaryData.Write(ripe)
 
-	// calc checksum from 2 rounds of SHA512
-	checksum := hash.DoubleSha512(binaryData.Bytes())[:4]
-
-	totalBin := append(binaryData.Bytes(), checksum...)
-
-	return "BM-" + string(base58.Encode(totalBin))
+	return "BM-" + Base58CheckEncode(binaryData.Bytes(), AddressChecksumSize)
 }
 
 // DecodeAddress decodes the Bitmessage address into an Address object.
@@ -173,20 +187,18 @@ func DecodeAddress(addr string) (Address, error) {
 		addr = addr[3:]
 	}
 
-	data := base58.Decode(addr)
-	if len(data) <= 12 { // rough lower bound, also don't want it to be empty
+	data, err := Base58CheckDecode(addr, AddressChecksumSize)
+	if err == ErrBase58CheckLength {
 		return nil, ErrUnknownAddressType
 	}
-
-	hashData := data[:len(data)-4]
-	checksum := data[len(data)-4:]
-
-	if !bytes.Equal(checksum, hash.DoubleSha512(hashData)[0:4]) {
-		return nil, ErrChecksumMismatch
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= 8 { // rough lower bound, also don't want it to be empty
+		return nil, ErrUnknownAddressType
 	}
 
 	buf := bytes.NewReader(data)
-	var err error
 
 	version, err := ReadVarInt(buf) // read version
 	if err != nil {
@@ -197,9 +209,12 @@ func DecodeAddress(addr string) (Address, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := CheckStream(stream); err != nil {
+		return nil, err
+	}
 
-	ripe := make([]byte, buf.Len()-4) // exclude bytes already read and checksum
-	buf.Read(ripe)                    // this can never cause an error
+	ripe := make([]byte, buf.Len()) // exclude bytes already read
+	buf.Read(ripe)                  // this can never cause an error
 
 	lenRipe := len(ripe)
 
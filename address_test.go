@@ -8,6 +8,8 @@ package bmutil
 import (
 	"reflect"
 	"testing"
+
+	"github.com/DanielKrawisz/bmutil/hash"
 )
 
 type addressTestPair struct {
@@ -98,6 +100,67 @@ func TestAddressErrors(t *testing.T) {
 	}
 }
 
+func TestAddressStream(t *testing.T) {
+	ripe := [20]byte{0, 118, 97, 129, 167, 56, 98, 210, 144, 213, 33, 56,
+		250, 180, 161, 223, 177, 177, 12, 17}
+
+	tests := []struct {
+		stream uint64
+		err    error
+	}{
+		{0, ErrInvalidStream},
+		{1, nil},
+		{2, nil},
+		{MaxStream, nil},
+		{MaxStream + 1, ErrInvalidStream},
+	}
+
+	for i, test := range tests {
+		if err := CheckStream(test.stream); err != test.err {
+			t.Errorf("CheckStream #%d: got %v want %v", i, err, test.err)
+		}
+
+		for _, addr := range []Address{
+			&addressV4{stream: test.stream, ripe: ripe},
+			&depricatedAddress{version: 3, stream: test.stream, ripe: ripe},
+		} {
+			if _, err := DecodeAddress(addr.String()); err != test.err {
+				t.Errorf("DecodeAddress #%d, v%d: got %v want %v", i,
+					addr.Version(), err, test.err)
+			}
+		}
+	}
+
+	r := hash.Ripe(ripe)
+	if _, err := NewDepricatedAddress(3, 0, &r); err != ErrInvalidStream {
+		t.Errorf("NewDepricatedAddress: got %v want %v", err, ErrInvalidStream)
+	}
+}
+
+func TestAddressesEqual(t *testing.T) {
+	ripe := [20]byte{0, 118, 97, 129, 167, 56, 98, 210, 144, 213, 33, 56,
+		250, 180, 161, 223, 177, 177, 12, 17}
+	other := ripe
+	other[19] ^= 1
+
+	a := &addressV4{stream: 1, ripe: ripe}
+	tests := []struct {
+		b     Address
+		equal bool
+	}{
+		{&addressV4{stream: 1, ripe: ripe}, true},
+		{&addressV4{stream: 1, ripe: other}, false},
+		{&addressV4{stream: 2, ripe: ripe}, false},
+		{&depricatedAddress{version: 3, stream: 1, ripe: ripe}, false},
+	}
+
+	for i, test := range tests {
+		if got := AddressesEqual(a, test.b); got != test.equal {
+			t.Errorf("AddressesEqual #%d: got %v want %v", i, got, test.equal)
+		}
+	}
+}
+
 // Test Tag, PrivateKey and PrivateKeySingleHash
 func TestCalcHash(t *testing.T) {
 	for _, pair := range addressTests {
@@ -0,0 +1,625 @@
+// Originally derived from: btcsuite/btcd/addrmgr/addrmanager.go
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// newBucketCount is the number of buckets that we spread new addresses
+	// over.
+	newBucketCount = 256
+
+	// newBucketSize is the maximum number of addresses in each new address
+	// bucket.
+	newBucketSize = 64
+
+	// newBucketsPerGroup is the number of new buckets that addresses learned
+	// from a single source group can be placed in.
+	newBucketsPerGroup = 32
+
+	// triedBucketCount is the number of buckets we split tried addresses
+	// over.
+	triedBucketCount = 64
+
+	// triedBucketSize is the maximum number of addresses in each tried
+	// address bucket.
+	triedBucketSize = 64
+
+	// triedBucketsPerGroup is the number of tried buckets over which an
+	// address group will be spread.
+	triedBucketsPerGroup = 8
+
+	// numMissingDays is the number of days before which we assume an
+	// address has vanished if we have not seen it announced in that long.
+	numMissingDays = 30
+
+	// numRetries is the number of tried without a single success before
+	// we assume an address is bad.
+	numRetries = 3
+
+	// maxFailures is the maximum number of failures we will accept without
+	// a success before considering an address bad.
+	maxFailures = 10
+
+	// minBadDays is the number of days since the last success before we
+	// will consider evicting an address.
+	minBadDays = 7
+
+	// connectedUpdateInterval is how often the timestamp of a connected
+	// address is updated.
+	connectedUpdateInterval = 20 * time.Minute
+
+	// dumpAddressInterval is the interval used to dump the address
+	// cache to disk for future use.
+	dumpAddressInterval = 10 * time.Minute
+
+	// peersFilename is the name of the file in the data directory in which
+	// the addresses are saved.
+	peersFilename = "peers.json"
+
+	// serialisationVersion is the current version of the on-disk format.
+	serialisationVersion = 1
+)
+
+// ErrUnknownVersion is returned when the saved address file has a format
+// version that we do not understand.
+var ErrUnknownVersion = errors.New("unknown version of saved addresses")
+
+// AddrManager provides a concurrency safe address manager for caching
+// potential peers on the Bitmessage network.
+type AddrManager struct {
+	mtx       sync.Mutex
+	peersFile string
+	rand      *rand.Rand
+	key       [32]byte
+	addrIndex map[string]*KnownAddress // address key to ka for all addrs.
+	addrNew   [newBucketCount]map[string]*KnownAddress
+	addrTried [triedBucketCount]map[string]*KnownAddress
+	nNew      int
+	nTried    int
+
+	started  bool
+	wg       sync.WaitGroup
+	quit     chan struct{}
+	shutdown bool
+}
+
+// serializedKnownAddress is the on-disk form of a KnownAddress.
+type serializedKnownAddress struct {
+	Addr        string
+	Src         string
+	Stream      uint32
+	Services    wire.ServiceFlag
+	Attempts    int
+	TimeStamp   int64
+	LastAttempt int64
+	LastSuccess int64
+	Tried       bool
+}
+
+// serializedAddrManager is the on-disk form of an AddrManager. The
+// buckets are not saved because they can be recomputed from the key.
+type serializedAddrManager struct {
+	Version   int
+	Key       [32]byte
+	Addresses []*serializedKnownAddress
+}
+
+// New returns a new address manager which saves its addresses in dataDir.
+// Use Start to begin processing asynchronous address updates.
+func New(dataDir string) *AddrManager {
+	am := &AddrManager{
+		peersFile: filepath.Join(dataDir, peersFilename),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		quit:      make(chan struct{}),
+	}
+	am.reset()
+	return am
+}
+
+// reset resets the address manager by reinitialising the random source
+// and allocating fresh empty bucket storage.
+func (a *AddrManager) reset() {
+	a.addrIndex = make(map[string]*KnownAddress)
+
+	// fill key with bytes from a good random source.
+	io.ReadFull(crand.Reader, a.key[:])
+	for i := range a.addrNew {
+		a.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	for i := range a.addrTried {
+		a.addrTried[i] = make(map[string]*KnownAddress)
+	}
+	a.nNew = 0
+	a.nTried = 0
+}
+
+// hashUint64 returns the first 8 bytes of the sha256 hash of the key and
+// the given strings as an integer.
+func (a *AddrManager) hashUint64(data ...string) uint64 {
+	h := sha256.New()
+	h.Write(a.key[:])
+	for _, d := range data {
+		h.Write([]byte(d))
+	}
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+// getNewBucket returns the new bucket for an address learned from srcAddr.
+// Addresses from a given source group are confined to newBucketsPerGroup
+// buckets.
+func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
+	i := a.hashUint64(GroupKey(netAddr), GroupKey(srcAddr)) % newBucketsPerGroup
+	return int(a.hashUint64(GroupKey(srcAddr), strconv.Itoa(int(i))) % newBucketCount)
+}
+
+// getTriedBucket returns the tried bucket for an address. Addresses from a
+// given group are confined to triedBucketsPerGroup buckets.
+func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
+	i := a.hashUint64(NetAddressKey(netAddr)) % triedBucketsPerGroup
+	return int(a.hashUint64(GroupKey(netAddr), strconv.Itoa(int(i))) % triedBucketCount)
+}
+
+// expireNew makes space in the new bucket by removing bad addresses, or
+// the oldest address if none are bad.
+func (a *AddrManager) expireNew(bucket int, now time.Time) {
+	var oldest *KnownAddress
+	for k, v := range a.addrNew[bucket] {
+		if v.isBad(now) {
+			delete(a.addrNew[bucket], k)
+			delete(a.addrIndex, k)
+			a.nNew--
+			continue
+		}
+		if oldest == nil || v.na.Timestamp.Before(oldest.na.Timestamp) {
+			oldest = v
+		}
+	}
+
+	if len(a.addrNew[bucket]) >= newBucketSize && oldest != nil {
+		k := NetAddressKey(oldest.na)
+		delete(a.addrNew[bucket], k)
+		delete(a.addrIndex, k)
+		a.nNew--
+	}
+}
+
+// addNew places a known address in its new bucket, making room if
+// necessary.
+func (a *AddrManager) addNew(ka *KnownAddress, now time.Time) {
+	bucket := a.getNewBucket(ka.na, ka.srcAddr)
+	if len(a.addrNew[bucket]) >= newBucketSize {
+		a.expireNew(bucket, now)
+	}
+
+	k := NetAddressKey(ka.na)
+	ka.setTried(false)
+	a.addrNew[bucket][k] = ka
+	a.addrIndex[k] = ka
+	a.nNew++
+}
+
+// updateAddress is a helper function to either update an address already
+// known to the address manager, or to add the address if not already
+// known.
+func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress, now time.Time) {
+	if !IsRoutable(netAddr) {
+		return
+	}
+
+	k := NetAddressKey(netAddr)
+	if ka, ok := a.addrIndex[k]; ok {
+		// Update the last seen time and services. Each update is
+		// copied so that the caller may not modify our data.
+		if netAddr.Timestamp.After(ka.na.Timestamp) ||
+			ka.na.Services&netAddr.Services != netAddr.Services {
+
+			naCopy := *ka.na
+			if netAddr.Timestamp.After(naCopy.Timestamp) {
+				naCopy.Timestamp = netAddr.Timestamp
+			}
+			naCopy.AddService(netAddr.Services)
+			ka.setNetAddress(&naCopy)
+		}
+		return
+	}
+
+	naCopy := *netAddr
+	srcCopy := *srcAddr
+	a.addNew(&KnownAddress{na: &naCopy, srcAddr: &srcCopy}, now)
+}
+
+// AddAddresses adds new addresses to the address manager. It enforces a
+// maximum number of addresses and silently ignores duplicate addresses.
+func (a *AddrManager) AddAddresses(addrs []*wire.NetAddress, srcAddr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := time.Now()
+	for _, na := range addrs {
+		a.updateAddress(na, srcAddr, now)
+	}
+}
+
+// AddAddress adds a new address to the address manager. It enforces a
+// maximum number of addresses and silently ignores duplicate addresses.
+func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddress) {
+	a.AddAddresses([]*wire.NetAddress{addr}, srcAddr)
+}
+
+// NumAddresses returns the number of addresses known to the address
+// manager.
+func (a *AddrManager) NumAddresses() int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.nNew + a.nTried
+}
+
+// NeedMoreAddresses returns whether or not the address manager needs more
+// addresses.
+func (a *AddrManager) NeedMoreAddresses() bool {
+	return a.NumAddresses() < 1000
+}
+
+// candidates returns the addresses in the given table and stream.
+func candidates(table []map[string]*KnownAddress, stream uint32) []*KnownAddress {
+	var c []*KnownAddress
+	for _, bucket := range table {
+		for _, ka := range bucket {
+			if ka.na.Stream == stream {
+				c = append(c, ka)
+			}
+		}
+	}
+	return c
+}
+
+// GetAddress returns a single address in the given stream that should be
+// routable. It picks a random one from the possible addresses with
+// preference given to ones that have not been used recently and should not
+// pick 'close' addresses consecutively. It returns nil if there are no
+// addresses in the stream.
+func (a *AddrManager) GetAddress(stream uint32) *KnownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	tried := candidates(a.addrTried[:], stream)
+	fresh := candidates(a.addrNew[:], stream)
+
+	// Use a 50% chance for choosing between tried and new table entries.
+	var c []*KnownAddress
+	if len(tried) > 0 && (len(fresh) == 0 || a.rand.Intn(2) == 0) {
+		c = tried
+	} else {
+		c = fresh
+	}
+	if len(c) == 0 {
+		return nil
+	}
+
+	// Pick addresses at random, accepting each with its chance. The
+	// factor ensures that the loop ends even if every chance is low,
+	// which it does within a few dozen iterations since no chance is
+	// below minChance.
+	now := time.Now()
+	factor := 1.0
+	for {
+		ka := c[a.rand.Intn(len(c))]
+		if a.rand.Float64() < factor*ka.chance(now) {
+			return ka
+		}
+		factor *= 1.2
+	}
+}
+
+// find returns the known address for addr or nil if it is not known.
+func (a *AddrManager) find(addr *wire.NetAddress) *KnownAddress {
+	return a.addrIndex[NetAddressKey(addr)]
+}
+
+// Attempt increases the given address' attempt counter and updates
+// the last attempt time.
+func (a *AddrManager) Attempt(addr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return
+	}
+	ka.mtx.Lock()
+	ka.attempts++
+	ka.lastattempt = time.Now()
+	ka.mtx.Unlock()
+}
+
+// Connected marks the given address as currently connected and working at
+// the current time. The address must already be known to the address
+// manager else it will be ignored.
+func (a *AddrManager) Connected(addr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return
+	}
+
+	// Update the time as long as it has been long enough since last
+	// updated so we don't always update.
+	now := time.Now()
+	if now.After(ka.na.Timestamp.Add(connectedUpdateInterval)) {
+		naCopy := *ka.na
+		naCopy.Timestamp = time.Unix(now.Unix(), 0)
+		ka.setNetAddress(&naCopy)
+	}
+}
+
+// Good marks the given address as good. To be called after a successful
+// connection and version exchange. If the address is unknown to the
+// address manager it will be ignored.
+func (a *AddrManager) Good(addr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return
+	}
+
+	now := time.Now()
+	ka.mtx.Lock()
+	ka.lastsuccess = now
+	ka.lastattempt = now
+	ka.attempts = 0
+	ka.mtx.Unlock()
+
+	if ka.tried {
+		return
+	}
+
+	// Remove the address from its new bucket.
+	k := NetAddressKey(ka.na)
+	delete(a.addrNew[a.getNewBucket(ka.na, ka.srcAddr)], k)
+	a.nNew--
+
+	// If the tried bucket is full, move the oldest entry in it back to
+	// the new table to make room.
+	bucket := a.getTriedBucket(ka.na)
+	if len(a.addrTried[bucket]) >= triedBucketSize {
+		var oldest *KnownAddress
+		for _, v := range a.addrTried[bucket] {
+			if oldest == nil || v.na.Timestamp.Before(oldest.na.Timestamp) {
+				oldest = v
+			}
+		}
+		delete(a.addrTried[bucket], NetAddressKey(oldest.na))
+		a.nTried--
+		a.addNew(oldest, now)
+	}
+
+	ka.setTried(true)
+	a.addrTried[bucket][k] = ka
+	a.nTried++
+}
+
+// AddressCache returns a randomized subset of the good addresses in the
+// given stream, no more than can be sent in a single addr message.
+func (a *AddrManager) AddressCache(stream uint32) []*wire.NetAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := time.Now()
+	var addrs []*wire.NetAddress
+	for _, ka := range a.addrIndex {
+		if ka.na.Stream == stream && !ka.isBad(now) {
+			addrs = append(addrs, ka.na)
+		}
+	}
+
+	// Fisher-Yates shuffle the addresses and take the first ones.
+	for i := range addrs {
+		j := a.rand.Intn(i + 1)
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	}
+	if len(addrs) > wire.MaxAddrPerMsg {
+		addrs = addrs[:wire.MaxAddrPerMsg]
+	}
+	return addrs
+}
+
+// parseNetAddress parses a host:port string saved by savePeers.
+func parseNetAddress(addr string, stream uint32, services wire.ServiceFlag) (*wire.NetAddress, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %s", host)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewNetAddressIPPort(ip, uint16(port), stream, services), nil
+}
+
+// Save writes the known addresses to the peers file.
+func (a *AddrManager) Save() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	sam := &serializedAddrManager{
+		Version:   serialisationVersion,
+		Key:       a.key,
+		Addresses: make([]*serializedKnownAddress, 0, len(a.addrIndex)),
+	}
+	for k, ka := range a.addrIndex {
+		sam.Addresses = append(sam.Addresses, &serializedKnownAddress{
+			Addr:        k,
+			Src:         NetAddressKey(ka.srcAddr),
+			Stream:      ka.na.Stream,
+			Services:    ka.na.Services,
+			Attempts:    ka.attempts,
+			TimeStamp:   ka.na.Timestamp.Unix(),
+			LastAttempt: ka.lastattempt.Unix(),
+			LastSuccess: ka.lastsuccess.Unix(),
+			Tried:       ka.tried,
+		})
+	}
+
+	// Write to a temporary file first so that a crash cannot leave a
+	// partially written peers file.
+	tmp := a.peersFile + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(f).Encode(sam); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.peersFile)
+}
+
+// Load reads the known addresses from the peers file, replacing any
+// addresses already known. It is not an error for the file not to exist.
+func (a *AddrManager) Load() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	f, err := os.Open(a.peersFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sam serializedAddrManager
+	if err = json.NewDecoder(f).Decode(&sam); err != nil {
+		return err
+	}
+	if sam.Version != serialisationVersion {
+		return ErrUnknownVersion
+	}
+
+	a.reset()
+	a.key = sam.Key
+	now := time.Now()
+	for _, v := range sam.Addresses {
+		na, err := parseNetAddress(v.Addr, v.Stream, v.Services)
+		if err != nil {
+			a.reset()
+			return err
+		}
+		na.Timestamp = time.Unix(v.TimeStamp, 0)
+		src, err := parseNetAddress(v.Src, v.Stream, 0)
+		if err != nil {
+			a.reset()
+			return err
+		}
+
+		ka := &KnownAddress{
+			na:          na,
+			srcAddr:     src,
+			attempts:    v.Attempts,
+			lastattempt: time.Unix(v.LastAttempt, 0),
+			lastsuccess: time.Unix(v.LastSuccess, 0),
+		}
+
+		k := NetAddressKey(na)
+		bucket := a.getTriedBucket(na)
+		if v.Tried && len(a.addrTried[bucket]) < triedBucketSize {
+			ka.tried = true
+			a.addrTried[bucket][k] = ka
+			a.addrIndex[k] = ka
+			a.nTried++
+			continue
+		}
+		a.addNew(ka, now)
+	}
+
+	return nil
+}
+
+// Start loads the saved addresses and begins saving them periodically.
+func (a *AddrManager) Start() error {
+	a.mtx.Lock()
+	started := a.started
+	a.started = true
+	a.mtx.Unlock()
+	if started {
+		return nil
+	}
+
+	if err := a.Load(); err != nil {
+		return err
+	}
+
+	a.wg.Add(1)
+	go a.addressHandler()
+	return nil
+}
+
+// Stop stops the address manager and saves the addresses a final time.
+func (a *AddrManager) Stop() error {
+	a.mtx.Lock()
+	if !a.started || a.shutdown {
+		a.mtx.Unlock()
+		return nil
+	}
+	a.shutdown = true
+	a.mtx.Unlock()
+
+	close(a.quit)
+	a.wg.Wait()
+	return a.Save()
+}
+
+// addressHandler is the main handler for the address manager. It must be
+// run as a goroutine.
+func (a *AddrManager) addressHandler() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(dumpAddressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are not fatal here; the next save may succeed
+			// and Stop reports any error from the final save.
+			a.Save()
+		case <-a.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/addrmgr"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newAddr returns a routable address in the given stream.
+func newAddr(i int, stream uint32) *wire.NetAddress {
+	ip := net.ParseIP(fmt.Sprintf("8.%d.%d.1", i/256, i%256))
+	return wire.NewNetAddressIPPort(ip, 8444, stream, wire.SFNodeNetwork)
+}
+
+var srcAddr = wire.NewNetAddressIPPort(net.ParseIP("9.9.9.9"), 8444, 1, 0)
+
+// TestAddAddress tests adding addresses to the address manager.
+func TestAddAddress(t *testing.T) {
+	am := addrmgr.New("")
+
+	// Unroutable addresses are ignored.
+	am.AddAddress(wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"),
+		8444, 1, 0), srcAddr)
+	if n := am.NumAddresses(); n != 0 {
+		t.Errorf("NumAddresses: got %d want %d", n, 0)
+	}
+
+	// Duplicates are only counted once.
+	am.AddAddresses([]*wire.NetAddress{newAddr(1, 1), newAddr(2, 1),
+		newAddr(1, 1)}, srcAddr)
+	if n := am.NumAddresses(); n != 2 {
+		t.Errorf("NumAddresses: got %d want %d", n, 2)
+	}
+
+	// Lots of addresses from the same source group cannot fill the
+	// table.
+	for i := 0; i < 10000; i++ {
+		am.AddAddress(newAddr(i, 1), srcAddr)
+	}
+	if n := am.NumAddresses(); n > 32*64 {
+		t.Errorf("NumAddresses: got %d want at most %d", n, 32*64)
+	}
+}
+
+// TestGetAddress tests that GetAddress only returns addresses in the
+// requested stream and that Good moves addresses to the tried table.
+func TestGetAddress(t *testing.T) {
+	am := addrmgr.New("")
+
+	if ka := am.GetAddress(1); ka != nil {
+		t.Errorf("GetAddress: got %v want nil", ka.NetAddress())
+	}
+
+	am.AddAddress(newAddr(1, 1), srcAddr)
+	am.AddAddress(newAddr(2, 2), srcAddr)
+
+	for i := 0; i < 10; i++ {
+		ka := am.GetAddress(2)
+		if ka == nil {
+			t.Fatal("GetAddress: got nil")
+		}
+		if s := ka.NetAddress().Stream; s != 2 {
+			t.Errorf("GetAddress: got stream %d want %d", s, 2)
+		}
+	}
+	if ka := am.GetAddress(3); ka != nil {
+		t.Errorf("GetAddress: got %v want nil", ka.NetAddress())
+	}
+
+	na := newAddr(1, 1)
+	am.Attempt(na)
+	ka := am.GetAddress(1)
+	if ka.Tried() || ka.LastAttempt().IsZero() {
+		t.Errorf("Attempt: got tried %v, last attempt %v", ka.Tried(),
+			ka.LastAttempt())
+	}
+
+	am.Good(na)
+	ka = am.GetAddress(1)
+	if !ka.Tried() {
+		t.Error("Good: address was not moved to the tried table")
+	}
+	if n := am.NumAddresses(); n != 2 {
+		t.Errorf("NumAddresses: got %d want %d", n, 2)
+	}
+
+	// An address which has failed many times may still be returned.
+	na = newAddr(2, 2)
+	for i := 0; i < 5000; i++ {
+		am.Attempt(na)
+	}
+	if ka := am.GetAddress(2); ka == nil {
+		t.Error("GetAddress: got nil")
+	}
+}
+
+// TestAddressCache tests that AddressCache returns the addresses in a
+// stream.
+func TestAddressCache(t *testing.T) {
+	am := addrmgr.New("")
+	for i := 0; i < 5; i++ {
+		am.AddAddress(newAddr(i, 1), srcAddr)
+	}
+	am.AddAddress(newAddr(5, 2), srcAddr)
+
+	if n := len(am.AddressCache(1)); n != 5 {
+		t.Errorf("AddressCache: got %d addresses want %d", n, 5)
+	}
+	if n := len(am.AddressCache(2)); n != 1 {
+		t.Errorf("AddressCache: got %d addresses want %d", n, 1)
+	}
+}
+
+// TestPersistence tests that addresses survive a restart.
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	am := addrmgr.New(dir)
+	if err = am.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	am.AddAddress(newAddr(1, 1), srcAddr)
+	am.AddAddress(newAddr(2, 1), srcAddr)
+	am.Good(newAddr(1, 1))
+	if err = am.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	am = addrmgr.New(dir)
+	if err = am.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer am.Stop()
+
+	if n := am.NumAddresses(); n != 2 {
+		t.Errorf("NumAddresses: got %d want %d", n, 2)
+	}
+	tried := 0
+	for _, na := range am.AddressCache(1) {
+		if na.Services != wire.SFNodeNetwork {
+			t.Errorf("Services: got %v want %v", na.Services,
+				wire.SFNodeNetwork)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if ka := am.GetAddress(1); ka.Tried() {
+			tried++
+			if k := addrmgr.NetAddressKey(ka.NetAddress()); k != "8.0.1.1:8444" {
+				t.Errorf("tried address: got %s want %s", k, "8.0.1.1:8444")
+			}
+		}
+	}
+	if tried == 0 {
+		t.Error("tried address was not restored")
+	}
+
+	// A corrupt file is reported.
+	ioutil.WriteFile(dir+"/peers.json", []byte("{"), 0600)
+	if err = addrmgr.New(dir).Load(); err == nil {
+		t.Error("Load: got nil want error")
+	}
+}
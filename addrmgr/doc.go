@@ -0,0 +1,24 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package addrmgr implements a concurrency safe Bitmessage address manager.
+
+The address manager keeps track of the addresses of peers on the network
+along with the stream they belong to and how well connections to them have
+gone. Addresses which we have only heard about are kept in the new table and
+addresses which we have successfully connected to are moved to the tried
+table. Both tables are split into buckets which are chosen from a secret key
+and the network groups of the address and of the peer that told us about it,
+so that no single peer can fill the tables with addresses that it controls.
+
+GetAddress selects an address in a given stream at random, favoring
+addresses which are recent and have not failed many times. Attempt, Good and
+Connected should be called to tell the address manager how connections to an
+address have gone.
+
+The tables are written to disk periodically while the address manager is
+running and when it is stopped, and are read back when it is started.
+*/
+package addrmgr
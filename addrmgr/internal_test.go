@@ -0,0 +1,38 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the addrmgr package rather than than the
+addrmgr_test package so it can bridge access to the internals to properly
+test cases which are either not possible or can't reliably be tested via
+the public interface. The functions are only exported while the tests are
+being run.
+*/
+
+package addrmgr
+
+import (
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TstNewKnownAddress creates a KnownAddress with the given fields.
+func TstNewKnownAddress(na *wire.NetAddress, attempts int,
+	lastattempt, lastsuccess time.Time) *KnownAddress {
+	return &KnownAddress{na: na, attempts: attempts,
+		lastattempt: lastattempt, lastsuccess: lastsuccess}
+}
+
+// TstKnownAddressIsBad makes the internal isBad function available to the
+// test package.
+func TstKnownAddressIsBad(ka *KnownAddress, now time.Time) bool {
+	return ka.isBad(now)
+}
+
+// TstKnownAddressChance makes the internal chance function available to
+// the test package.
+func TstKnownAddressChance(ka *KnownAddress, now time.Time) float64 {
+	return ka.chance(now)
+}
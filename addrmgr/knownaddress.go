@@ -0,0 +1,136 @@
+// Originally derived from: btcsuite/btcd/addrmgr/knownaddress.go
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// minChance is the lowest selection probability of a known address, so that
+// the chance of an address which has failed many times does not become 0.
+const minChance = 1e-6
+
+// KnownAddress tracks information about a known network address that is
+// used to determine how viable an address is.
+type KnownAddress struct {
+	na          *wire.NetAddress
+	srcAddr     *wire.NetAddress
+	attempts    int
+	lastattempt time.Time
+	lastsuccess time.Time
+	tried       bool
+
+	// mtx protects the fields which are read by the exported methods.
+	// They are only written while the AddrManager's mutex is held as
+	// well, so the AddrManager reads them without mtx.
+	mtx sync.RWMutex
+}
+
+// NetAddress returns the underlying wire.NetAddress associated with the
+// known address.
+func (ka *KnownAddress) NetAddress() *wire.NetAddress {
+	ka.mtx.RLock()
+	defer ka.mtx.RUnlock()
+	return ka.na
+}
+
+// LastAttempt returns the last time the known address was attempted.
+func (ka *KnownAddress) LastAttempt() time.Time {
+	ka.mtx.RLock()
+	defer ka.mtx.RUnlock()
+	return ka.lastattempt
+}
+
+// Tried returns whether a connection to the address has ever succeeded.
+func (ka *KnownAddress) Tried() bool {
+	ka.mtx.RLock()
+	defer ka.mtx.RUnlock()
+	return ka.tried
+}
+
+// setNetAddress replaces the underlying wire.NetAddress.
+func (ka *KnownAddress) setNetAddress(na *wire.NetAddress) {
+	ka.mtx.Lock()
+	ka.na = na
+	ka.mtx.Unlock()
+}
+
+// setTried sets whether the address is in the tried table.
+func (ka *KnownAddress) setTried(tried bool) {
+	ka.mtx.Lock()
+	ka.tried = tried
+	ka.mtx.Unlock()
+}
+
+// chance returns the selection probability for a known address. The
+// priority depends upon how recently the address has been seen, how
+// recently it was last attempted and how often attempts to connect to it
+// have failed.
+func (ka *KnownAddress) chance(now time.Time) float64 {
+	lastAttempt := now.Sub(ka.lastattempt)
+	if lastAttempt < 0 {
+		lastAttempt = 0
+	}
+
+	c := 1.0
+
+	// Very recent attempts are less likely to be retried.
+	if lastAttempt < 10*time.Minute {
+		c *= 0.01
+	}
+
+	// Failed attempts deprioritise.
+	for i := ka.attempts; i > 0 && c > minChance; i-- {
+		c /= 1.5
+	}
+
+	if c < minChance {
+		return minChance
+	}
+	return c
+}
+
+// isBad returns true if the address in question has not been tried in the
+// last minute and meets one of the following criteria:
+// 1) It claims to be from the future
+// 2) It hasn't been seen in over a month
+// 3) It has failed at least three times and never succeeded
+// 4) It has failed ten times in the last week
+// All addresses that meet these criteria are assumed to be worthless and
+// not worth keeping hold of.
+func (ka *KnownAddress) isBad(now time.Time) bool {
+	if ka.lastattempt.After(now.Add(-1 * time.Minute)) {
+		return false
+	}
+
+	// From the future?
+	if ka.na.Timestamp.After(now.Add(10 * time.Minute)) {
+		return true
+	}
+
+	// Over a month old?
+	if ka.na.Timestamp.Before(now.Add(-1 * numMissingDays * 24 * time.Hour)) {
+		return true
+	}
+
+	// Never succeeded?
+	if ka.lastsuccess.IsZero() && ka.attempts >= numRetries {
+		return true
+	}
+
+	// Hasn't succeeded in too long?
+	if !ka.lastsuccess.After(now.Add(-1*minBadDays*24*time.Hour)) &&
+		ka.attempts >= maxFailures {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/addrmgr"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestChance tests that addresses which were attempted recently or which
+// have failed are less likely to be chosen.
+func TestChance(t *testing.T) {
+	now := time.Now()
+	na := wire.NewNetAddressIPPort(net.ParseIP("8.8.8.8"), 8444, 1, 0)
+
+	fresh := addrmgr.TstNewKnownAddress(na, 0, now.Add(-time.Hour), time.Time{})
+	recent := addrmgr.TstNewKnownAddress(na, 0, now.Add(-time.Minute), time.Time{})
+	failed := addrmgr.TstNewKnownAddress(na, 2, now.Add(-time.Hour), time.Time{})
+
+	if c := addrmgr.TstKnownAddressChance(fresh, now); c != 1.0 {
+		t.Errorf("fresh: got %v want %v", c, 1.0)
+	}
+	if c := addrmgr.TstKnownAddressChance(recent, now); c != 0.01 {
+		t.Errorf("recent: got %v want %v", c, 0.01)
+	}
+	if c := addrmgr.TstKnownAddressChance(failed, now); c != 1.0/1.5/1.5 {
+		t.Errorf("failed: got %v want %v", c, 1.0/1.5/1.5)
+	}
+
+	// The chance of an address which has failed very often does not
+	// become 0.
+	hopeless := addrmgr.TstNewKnownAddress(na, 5000, now.Add(-time.Minute), time.Time{})
+	if c := addrmgr.TstKnownAddressChance(hopeless, now); c <= 0 {
+		t.Errorf("hopeless: got %v want more than 0", c)
+	}
+}
+
+// TestIsBad tests the conditions under which an address is considered bad.
+func TestIsBad(t *testing.T) {
+	now := time.Now()
+	newNA := func(ts time.Time) *wire.NetAddress {
+		na := wire.NewNetAddressIPPort(net.ParseIP("8.8.8.8"), 8444, 1, 0)
+		na.Timestamp = ts
+		return na
+	}
+	hourAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		ka  *addrmgr.KnownAddress
+		bad bool
+	}{
+		// Seen recently.
+		{addrmgr.TstNewKnownAddress(newNA(hourAgo), 0, hourAgo, time.Time{}), false},
+		// From the future.
+		{addrmgr.TstNewKnownAddress(newNA(now.Add(time.Hour)), 0, hourAgo, time.Time{}), true},
+		// Not seen in over a month.
+		{addrmgr.TstNewKnownAddress(newNA(now.Add(-31*24*time.Hour)), 0, hourAgo, time.Time{}), true},
+		// Never succeeded after three attempts.
+		{addrmgr.TstNewKnownAddress(newNA(hourAgo), 3, hourAgo, time.Time{}), true},
+		// Ten failures since the last success over a week ago.
+		{addrmgr.TstNewKnownAddress(newNA(hourAgo), 10, hourAgo, now.Add(-8*24*time.Hour)), true},
+		// Ten failures but a recent success.
+		{addrmgr.TstNewKnownAddress(newNA(hourAgo), 10, hourAgo, now.Add(-time.Hour)), false},
+		// Attempted in the last minute.
+		{addrmgr.TstNewKnownAddress(newNA(now.Add(time.Hour)), 0, now, time.Time{}), false},
+	}
+
+	for i, test := range tests {
+		if bad := addrmgr.TstKnownAddressIsBad(test.ka, now); bad != test.bad {
+			t.Errorf("test #%d: got %v want %v", i, bad, test.bad)
+		}
+	}
+}
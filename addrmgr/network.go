@@ -0,0 +1,93 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ipNet returns a net.IPNet from an address string in CIDR notation.
+func ipNet(cidr string) *net.IPNet {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// unroutable lists the ranges of addresses which cannot be reached over
+// the public internet.
+var unroutable = []*net.IPNet{
+	ipNet("0.0.0.0/8"),      // RFC 1122 "this network"
+	ipNet("10.0.0.0/8"),     // RFC 1918 private
+	ipNet("100.64.0.0/10"),  // RFC 6598 shared address space
+	ipNet("127.0.0.0/8"),    // loopback
+	ipNet("169.254.0.0/16"), // RFC 3927 link local
+	ipNet("172.16.0.0/12"),  // RFC 1918 private
+	ipNet("192.168.0.0/16"), // RFC 1918 private
+	ipNet("198.18.0.0/15"),  // RFC 2544 benchmarking
+	ipNet("224.0.0.0/3"),    // multicast and reserved
+	ipNet("::/128"),         // unspecified
+	ipNet("::1/128"),        // loopback
+	ipNet("2001:db8::/32"),  // RFC 3849 documentation
+	ipNet("fc00::/7"),       // RFC 4193 unique local
+	ipNet("fe80::/10"),      // RFC 4291 link local
+	ipNet("ff00::/8"),       // multicast
+}
+
+// onionCatNet is the range of IPv6 addresses which OnionCat uses to
+// represent Tor hidden services.
+var onionCatNet = ipNet("fd87:d87e:eb43::/48")
+
+// IsOnionCatTor returns whether the address is a Tor hidden service
+// represented as an OnionCat address.
+func IsOnionCatTor(na *wire.NetAddress) bool {
+	return onionCatNet.Contains(na.IP)
+}
+
+// IsRoutable returns whether the address could be reached over the public
+// internet or over Tor.
+func IsRoutable(na *wire.NetAddress) bool {
+	if na.IP == nil || na.Port == 0 {
+		return false
+	}
+	if IsOnionCatTor(na) {
+		return true
+	}
+	for _, n := range unroutable {
+		if n.Contains(na.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// GroupKey returns a string identifying the network group that an address
+// belongs to. Addresses in the same group are likely to be under the
+// control of the same entity. For IPv4 the group is the /16 and for IPv6
+// it is the /32. Hidden services are split into 16 groups by the first
+// four bits of their names.
+func GroupKey(na *wire.NetAddress) string {
+	if !IsRoutable(na) {
+		return "unroutable"
+	}
+	if IsOnionCatTor(na) {
+		return fmt.Sprintf("tor:%d", na.IP[6]>>4)
+	}
+	if ip := na.IP.To4(); ip != nil {
+		return ip.Mask(net.CIDRMask(16, 32)).String()
+	}
+	return na.IP.Mask(net.CIDRMask(32, 128)).String()
+}
+
+// NetAddressKey returns a string which identifies a network address, in
+// the form host:port.
+func NetAddressKey(na *wire.NetAddress) string {
+	return net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port)))
+}
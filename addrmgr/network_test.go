@@ -0,0 +1,66 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/addrmgr"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestIsRoutable tests IsRoutable and GroupKey.
+func TestIsRoutable(t *testing.T) {
+	tests := []struct {
+		ip       string
+		port     uint16
+		routable bool
+		group    string
+	}{
+		{"8.8.8.8", 8444, true, "8.8.0.0"},
+		{"8.8.4.4", 8444, true, "8.8.0.0"},
+		{"8.8.8.8", 0, false, "unroutable"},
+		{"127.0.0.1", 8444, false, "unroutable"},
+		{"10.1.2.3", 8444, false, "unroutable"},
+		{"192.168.1.1", 8444, false, "unroutable"},
+		{"172.20.0.1", 8444, false, "unroutable"},
+		{"0.0.0.0", 8444, false, "unroutable"},
+		{"::1", 8444, false, "unroutable"},
+		{"fe80::1", 8444, false, "unroutable"},
+		{"2a01:4f8:1:2::3", 8444, true, "2a01:4f8::"},
+		{"fd87:d87e:eb43:a123::1", 8444, true, "tor:10"},
+		{"fd00::1", 8444, false, "unroutable"},
+	}
+
+	for i, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), test.port, 1, 0)
+		if r := addrmgr.IsRoutable(na); r != test.routable {
+			t.Errorf("IsRoutable #%d: got %v want %v", i, r, test.routable)
+		}
+		if g := addrmgr.GroupKey(na); g != test.group {
+			t.Errorf("GroupKey #%d: got %s want %s", i, g, test.group)
+		}
+	}
+}
+
+// TestNetAddressKey tests NetAddressKey.
+func TestNetAddressKey(t *testing.T) {
+	tests := []struct {
+		ip   string
+		port uint16
+		key  string
+	}{
+		{"8.8.8.8", 8444, "8.8.8.8:8444"},
+		{"2a01:4f8:1:2::3", 8444, "[2a01:4f8:1:2::3]:8444"},
+	}
+
+	for i, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), test.port, 1, 0)
+		if k := addrmgr.NetAddressKey(na); k != test.key {
+			t.Errorf("test #%d: got %s want %s", i, k, test.key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+var (
+	// ErrUnknownKey is returned when the agent does not hold the identity
+	// named in a request.
+	ErrUnknownKey = errors.New("agent does not hold the identity")
+
+	// ErrDecryptionFailed is returned by Decrypt when the ciphertext was
+	// not encrypted for the identity.
+	ErrDecryptionFailed = errors.New("identity could not decrypt the ciphertext")
+
+	// ErrAgentFailure is returned when the agent fails to answer a
+	// request for any other reason.
+	ErrAgentFailure = errors.New("agent failed to answer the request")
+
+	// ErrMalformedMessage is returned when a message received over the
+	// socket cannot be decoded, or is not the one expected.
+	ErrMalformedMessage = errors.New("malformed agent message")
+
+	// ErrForbiddenPeer is returned when a process of another user
+	// connects to the agent's socket.
+	ErrForbiddenPeer = errors.New("peer belongs to another user")
+)
+
+// Agent signs and decrypts with private identities which it does not
+// reveal. Identities are named by the ripe hash of their public keys, which
+// is the hash in their address.
+type Agent interface {
+	// List returns the public keys of the identities held by the agent.
+	List() ([]*identity.PublicKey, error)
+
+	// Sign signs the digest with the signing key of the identity and
+	// returns the DER-encoded signature.
+	Sign(key *hash.Ripe, digest []byte) ([]byte, error)
+
+	// Decrypt decrypts the ciphertext with the decryption key of the
+	// identity. It returns ErrDecryptionFailed if the ciphertext was not
+	// encrypted for the identity.
+	Decrypt(key *hash.Ripe, ciphertext []byte) ([]byte, error)
+}
+
+// Keyring is an Agent which holds private identities in memory. It is what
+// an agent process serves. It is safe for concurrent use.
+type Keyring struct {
+	mtx  sync.RWMutex
+	keys map[hash.Ripe]*identity.PrivateKey
+}
+
+// NewKeyring returns an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[hash.Ripe]*identity.PrivateKey),
+	}
+}
+
+// Add adds a private identity to the keyring.
+func (k *Keyring) Add(pk *identity.PrivateKey) {
+	k.mtx.Lock()
+	k.keys[*pk.Hash()] = pk
+	k.mtx.Unlock()
+}
+
+// Remove removes an identity from the keyring and returns whether it was
+// there.
+func (k *Keyring) Remove(key *hash.Ripe) bool {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if _, ok := k.keys[*key]; !ok {
+		return false
+	}
+	delete(k.keys, *key)
+	return true
+}
+
+// get returns the identity with the given ripe hash.
+func (k *Keyring) get(key *hash.Ripe) (*identity.PrivateKey, error) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	pk, ok := k.keys[*key]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return pk, nil
+}
+
+// List returns the public keys of the identities in the keyring, ordered by
+// their ripe hashes. This is part of the Agent interface.
+func (k *Keyring) List() ([]*identity.PublicKey, error) {
+	k.mtx.RLock()
+	hashes := make([]hash.Ripe, 0, len(k.keys))
+	for h := range k.keys {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+
+	keys := make([]*identity.PublicKey, len(hashes))
+	for i, h := range hashes {
+		keys[i] = k.keys[h].Public()
+	}
+	k.mtx.RUnlock()
+	return keys, nil
+}
+
+// Sign signs the digest with the signing key of the identity. This is part
+// of the Agent interface.
+func (k *Keyring) Sign(key *hash.Ripe, digest []byte) ([]byte, error) {
+	pk, err := k.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pk.Signer().Sign(digest)
+}
+
+// Decrypt decrypts the ciphertext with the decryption key of the identity.
+// This is part of the Agent interface.
+func (k *Keyring) Decrypt(key *hash.Ripe, ciphertext []byte) ([]byte, error) {
+	pk, err := k.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := btcec.Decrypt(pk.Decryption, ciphertext)
+	if err == btcec.ErrInvalidMAC {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, err
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/agent"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// newTestClient returns a Client connected through a pipe to a keyring
+// holding a new identity.
+func newTestClient(t *testing.T) (*agent.Client, *agent.Keyring, *identity.PrivateKey) {
+	pk, err := identity.NewRandom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := agent.NewKeyring()
+	keys.Add(pk)
+
+	client, server := net.Pipe()
+	go func() {
+		agent.ServeConn(keys, server)
+		server.Close()
+	}()
+	return agent.NewClient(client), keys, pk
+}
+
+func TestList(t *testing.T) {
+	c, keys, pk := newTestClient(t)
+	defer c.Close()
+
+	list, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || *list[0].Hash() != *pk.Hash() {
+		t.Fatalf("got %v want the public key %v", list, pk.Public())
+	}
+
+	keys.Remove(pk.Hash())
+	list, err = c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("got %d keys after removing the identity, want 0", len(list))
+	}
+}
+
+func TestSign(t *testing.T) {
+	c, _, pk := newTestClient(t)
+	defer c.Close()
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := c.Sign(pk.Hash(), digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Verify(digest[:], pk.Signing.PubKey()) {
+		t.Error("signature does not verify")
+	}
+
+	if _, err = c.Sign(pk.Hash(), make([]byte, 65)); err == nil {
+		t.Error("signed a digest which is too long")
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	c, _, pk := newTestClient(t)
+	defer c.Close()
+
+	plaintext := []byte("a secret")
+	ciphertext, err := btcec.Encrypt(pk.Decryption.PubKey(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(pk.Hash(), ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("got %x want %x", got, plaintext)
+	}
+
+	other, _ := btcec.NewPrivateKey(btcec.S256())
+	ciphertext, err = btcec.Encrypt(other.PubKey(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Decrypt(pk.Hash(), ciphertext); err != agent.ErrDecryptionFailed {
+		t.Errorf("got error %v want %v", err, agent.ErrDecryptionFailed)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	c, _, _ := newTestClient(t)
+	defer c.Close()
+
+	var unknown hash.Ripe
+	if _, err := c.Sign(&unknown, make([]byte, 32)); err != agent.ErrUnknownKey {
+		t.Errorf("Sign: got error %v want %v", err, agent.ErrUnknownKey)
+	}
+	if _, err := c.Decrypt(&unknown, []byte{1}); err != agent.ErrUnknownKey {
+		t.Errorf("Decrypt: got error %v want %v", err, agent.ErrUnknownKey)
+	}
+}
+
+// TestMalformed tests that the server drops a connection which sends a
+// message it cannot decode.
+func TestMalformed(t *testing.T) {
+	client, server := net.Pipe()
+	errs := make(chan error)
+	go func() {
+		errs <- agent.ServeConn(agent.NewKeyring(), server)
+	}()
+
+	// A sign request whose body is too short for a ripe hash.
+	go client.Write([]byte{0, 0, 0, 2, 2, 0})
+	if err := <-errs; err != agent.ErrMalformedMessage {
+		t.Errorf("got error %v want %v", err, agent.ErrMalformedMessage)
+	}
+	client.Close()
+}
+
+func TestSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix socket permissions on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "bmagent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "agent.sock")
+
+	l, err := agent.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket has permissions %v want 0600", perm)
+	}
+
+	// Nothing but the socket is left in the directory.
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir: got %d entries, %v want 1", len(entries), err)
+	}
+
+	pk, err := identity.NewRandom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := agent.NewKeyring()
+	keys.Add(pk)
+	go agent.Serve(l, keys)
+
+	c, err := agent.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	list, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || *list[0].Hash() != *pk.Hash() {
+		t.Errorf("got %v want the public key %v", list, pk.Public())
+	}
+
+	l.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket was not removed: %v", err)
+	}
+}
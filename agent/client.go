@@ -0,0 +1,103 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// Client is an Agent which forwards each request to an agent process over
+// a connection. It is safe for concurrent use; requests are sent one at a
+// time.
+type Client struct {
+	mtx  sync.Mutex
+	conn io.ReadWriter
+}
+
+// NewClient returns a Client which talks to an agent over conn.
+func NewClient(conn io.ReadWriter) *Client {
+	return &Client{conn: conn}
+}
+
+// Dial connects to the agent listening on the Unix socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// Close closes the connection to the agent if it can be closed.
+func (c *Client) Close() error {
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// call sends a request to the agent and returns the body of the response,
+// which must be of the type given by want.
+func (c *Client) call(typ byte, body []byte, want byte) ([]byte, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if err := writeMessage(c.conn, typ, body); err != nil {
+		return nil, err
+	}
+	respType, resp, err := readMessage(c.conn)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch respType {
+	case want:
+		return resp, nil
+	case msgFailure:
+		return nil, failureError(resp)
+	default:
+		return nil, ErrMalformedMessage
+	}
+}
+
+// List returns the public keys of the identities held by the agent. This
+// is part of the Agent interface.
+func (c *Client) List() ([]*identity.PublicKey, error) {
+	resp, err := c.call(msgList, nil, msgListResponse)
+	if err != nil {
+		return nil, err
+	}
+	return decodeKeys(resp)
+}
+
+// Sign has the agent sign the digest with the signing key of the identity.
+// This is part of the Agent interface.
+func (c *Client) Sign(key *hash.Ripe, digest []byte) ([]byte, error) {
+	resp, err := c.call(msgSign, encodeKeyRequest(key, digest),
+		msgSignResponse)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBytes(resp, maxSignatureSize)
+}
+
+// Decrypt has the agent decrypt the ciphertext with the decryption key of
+// the identity. This is part of the Agent interface.
+func (c *Client) Decrypt(key *hash.Ripe, ciphertext []byte) ([]byte, error) {
+	resp, err := c.call(msgDecrypt, encodeKeyRequest(key, ciphertext),
+		msgDecryptResponse)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBytes(resp, maxMessageSize)
+}
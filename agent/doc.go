@@ -0,0 +1,58 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package agent lets a separate process hold private identities and answer
+requests to sign and decrypt with them over a local socket, in the manner
+of ssh-agent, so that a long-running node never has the raw private keys in
+its own memory.
+
+The agent process keeps its identities in a Keyring and serves them on a
+Unix socket:
+
+	keys := agent.NewKeyring()
+	keys.Add(privateKey)
+
+	l, err := agent.Listen("/run/user/1000/bmagent.sock")
+	...
+	err = agent.Serve(l, keys)
+
+Only the current user may connect to the socket, and on Linux Serve also
+checks the user of each process which connects with CheckPeer.
+
+The node dials the socket and uses the Client as an Agent. Identities are
+named by the ripe hash in their address. To read a message object, the node
+has the agent decrypt it and decodes the result with the cipher package:
+
+	a, err := agent.Dial("/run/user/1000/bmagent.sock")
+	...
+	plain, err := a.Decrypt(address.RipeHash(), msg.Encrypted)
+	if err == agent.ErrDecryptionFailed {
+		// The message is not for this identity.
+	}
+	dec, err := cipher.DecodeDecryptedMessage(plain)
+
+To sign, the node hashes whatever is signed itself and has the agent sign
+the digest.
+
+Each message on the socket is a 4-byte big-endian length, followed by a
+byte giving the type of the message and then its body. Byte arrays in a
+body are encoded as var_bytes. A client sends one request at a time and
+reads the response before sending the next. The requests, and the
+responses to them, are
+
+	list      (1)  none
+	          (6)  count, then count pairs of 64-byte public keys, the
+	               verification key before the encryption key
+	sign      (2)  20-byte ripe hash, digest
+	          (7)  DER-encoded signature
+	decrypt   (3)  20-byte ripe hash, ciphertext
+	          (8)  plaintext
+
+Any request may instead be answered with a failure (5), whose body is one
+byte giving the reason: 0 for an unspecified failure, 1 if the agent does
+not hold the identity and 2 if the identity could not decrypt the
+ciphertext.
+*/
+package agent
@@ -0,0 +1,40 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// CheckPeer returns ErrForbiddenPeer if the process at the other end of
+// conn belongs to another user, which it learns with SO_PEERCRED. Serve
+// checks every connection with it, and so should anything else which
+// accepts connections to pass to ServeConn.
+func CheckPeer(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd),
+			syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return ErrForbiddenPeer
+	}
+	return nil
+}
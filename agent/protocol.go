@@ -0,0 +1,177 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// The types of the messages sent over the socket.
+const (
+	msgList            byte = 1
+	msgSign            byte = 2
+	msgDecrypt         byte = 3
+	msgFailure         byte = 5
+	msgListResponse    byte = 6
+	msgSignResponse    byte = 7
+	msgDecryptResponse byte = 8
+)
+
+// The reasons given in a failure message.
+const (
+	failureOther      byte = 0
+	failureUnknownKey byte = 1
+	failureDecryption byte = 2
+)
+
+const (
+	// maxMessageSize is the largest message accepted, which is enough for
+	// the ciphertext of any object.
+	maxMessageSize = wire.MaxMessagePayload + 1024
+
+	// maxDigestSize is the largest digest which may be signed.
+	maxDigestSize = 64
+
+	// maxSignatureSize is the largest DER-encoded signature.
+	maxSignatureSize = 72
+)
+
+// writeMessage writes a message of the given type to w in one write.
+func writeMessage(w io.Writer, typ byte, body []byte) error {
+	b := make([]byte, 5+len(body))
+	binary.BigEndian.PutUint32(b, uint32(1+len(body)))
+	b[4] = typ
+	copy(b[5:], body)
+	_, err := w.Write(b)
+	return err
+}
+
+// readMessage reads a message from r and returns its type and body. It
+// returns io.EOF if r ends before the message begins.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > maxMessageSize {
+		return 0, nil, ErrMalformedMessage
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return b[0], b[1:], nil
+}
+
+// encodeKeyRequest encodes the body of a sign or decrypt request.
+func encodeKeyRequest(key *hash.Ripe, data []byte) []byte {
+	var b bytes.Buffer
+	b.Write(key[:])
+	bmutil.WriteVarBytes(&b, data)
+	return b.Bytes()
+}
+
+// decodeKeyRequest decodes the body of a sign or decrypt request.
+func decodeKeyRequest(body []byte, maxData int) (*hash.Ripe, []byte, error) {
+	if len(body) < hash.RipeSize {
+		return nil, nil, ErrMalformedMessage
+	}
+	key := new(hash.Ripe)
+	copy(key[:], body)
+
+	data, err := decodeBytes(body[hash.RipeSize:], maxData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, data, nil
+}
+
+// encodeBytes encodes a body which is one byte array.
+func encodeBytes(data []byte) []byte {
+	var b bytes.Buffer
+	bmutil.WriteVarBytes(&b, data)
+	return b.Bytes()
+}
+
+// decodeBytes decodes a body which is one byte array and nothing else.
+func decodeBytes(body []byte, max int) ([]byte, error) {
+	r := bytes.NewReader(body)
+	data, err := wire.ReadBoundedBytes(r, uint64(max), "decodeBytes", "data")
+	if err != nil || r.Len() != 0 {
+		return nil, ErrMalformedMessage
+	}
+	return data, nil
+}
+
+// encodeKeys encodes the body of a response to a list request.
+func encodeKeys(keys []*identity.PublicKey) []byte {
+	var b bytes.Buffer
+	bmutil.WriteVarInt(&b, uint64(len(keys)))
+	for _, k := range keys {
+		b.Write(k.Verification.Bytes())
+		b.Write(k.Encryption.Bytes())
+	}
+	return b.Bytes()
+}
+
+// decodeKeys decodes the body of a response to a list request.
+func decodeKeys(body []byte) ([]*identity.PublicKey, error) {
+	r := bytes.NewReader(body)
+	count, err := wire.ReadBoundedLength(r, uint64(r.Len()/(2*wire.PubKeySize)),
+		"decodeKeys", "key count")
+	if err != nil || count != uint64(r.Len()/(2*wire.PubKeySize)) ||
+		r.Len()%(2*wire.PubKeySize) != 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	keys := make([]*identity.PublicKey, count)
+	for i := range keys {
+		var vk, ek wire.PubKey
+		r.Read(vk[:])
+		r.Read(ek[:])
+		if keys[i], err = identity.NewPublicKey(&vk, &ek); err != nil {
+			return nil, ErrMalformedMessage
+		}
+	}
+	return keys, nil
+}
+
+// failureReason returns the reason to give in a failure message for an
+// error returned by an Agent.
+func failureReason(err error) byte {
+	switch err {
+	case ErrUnknownKey:
+		return failureUnknownKey
+	case ErrDecryptionFailed:
+		return failureDecryption
+	default:
+		return failureOther
+	}
+}
+
+// failureError returns the error for the body of a failure message.
+func failureError(body []byte) error {
+	if len(body) == 1 {
+		switch body[0] {
+		case failureUnknownKey:
+			return ErrUnknownKey
+		case failureDecryption:
+			return ErrDecryptionFailed
+		}
+	}
+	return ErrAgentFailure
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package agent
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listener is a Unix socket listener which removes its socket when it is
+// closed.
+type listener struct {
+	net.Listener
+	path string
+}
+
+// Close closes the listener and removes its socket.
+func (l *listener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// Listen listens on a Unix socket at path which only the current user may
+// connect to. Any socket left at path by an agent which did not shut down
+// cleanly is removed first. The socket should still be created in a
+// directory which others cannot write to.
+//
+// The socket is created in a new directory which only the current user may
+// enter and is only moved to path once its permissions have been
+// restricted, so that nobody else can connect to it in between.
+func Listen(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".bmagent")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	tmp := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return &listener{Listener: l, path: path}, nil
+}
+
+// Serve accepts connections on l and answers the requests on each with a,
+// until l is closed. It returns the error from Accept. Where the operating
+// system can tell, connections from processes of other users are closed
+// without being answered.
+func Serve(l net.Listener, a Agent) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		if uc, ok := conn.(*net.UnixConn); ok && CheckPeer(uc) != nil {
+			conn.Close()
+			continue
+		}
+		go func() {
+			ServeConn(a, conn)
+			conn.Close()
+		}()
+	}
+}
+
+// ServeConn answers the requests sent over conn with a, until conn is
+// closed. It returns nil if the client closes conn between requests and
+// ErrMalformedMessage if a request cannot be decoded. Requests of unknown
+// types are answered with a failure.
+func ServeConn(a Agent, conn io.ReadWriter) error {
+	for {
+		typ, body, err := readMessage(conn)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		respType, resp, err := answer(a, typ, body)
+		if err == ErrMalformedMessage {
+			return err
+		}
+		if err != nil {
+			respType, resp = msgFailure, []byte{failureReason(err)}
+		}
+		if err = writeMessage(conn, respType, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// answer asks a for the response to a request.
+func answer(a Agent, typ byte, body []byte) (byte, []byte, error) {
+	switch typ {
+	case msgList:
+		if len(body) != 0 {
+			return 0, nil, ErrMalformedMessage
+		}
+		keys, err := a.List()
+		if err != nil {
+			return 0, nil, err
+		}
+		return msgListResponse, encodeKeys(keys), nil
+
+	case msgSign:
+		key, digest, err := decodeKeyRequest(body, maxDigestSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		sig, err := a.Sign(key, digest)
+		if err != nil {
+			return 0, nil, err
+		}
+		return msgSignResponse, encodeBytes(sig), nil
+
+	case msgDecrypt:
+		key, ciphertext, err := decodeKeyRequest(body, maxMessageSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		plaintext, err := a.Decrypt(key, ciphertext)
+		if err != nil {
+			return 0, nil, err
+		}
+		return msgDecryptResponse, encodeBytes(plaintext), nil
+
+	default:
+		return 0, nil, ErrAgentFailure
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// AddressChecksumSize is the number of bytes of checksum at the end of an
+// encoded address.
+const AddressChecksumSize = 4
+
+// ErrBase58CheckLength is returned when a Base58Check string is not long
+// enough to hold its checksum, or when the checksum length is impossible.
+var ErrBase58CheckLength = errors.New("invalid length for base58check")
+
+// base58Checksum returns the first n bytes of the double SHA-512 of data.
+func base58Checksum(data []byte, n int) []byte {
+	return hash.DoubleSha512(data)[:n]
+}
+
+// Base58CheckEncode returns the base58 encoding of payload followed by a
+// checksum of checksumSize bytes, which are the first bytes of the double
+// SHA-512 of payload. Addresses are encoded this way with a checksum of
+// AddressChecksumSize bytes. checksumSize may be from 0 to 64; it panics
+// otherwise.
+func Base58CheckEncode(payload []byte, checksumSize int) string {
+	b := make([]byte, 0, len(payload)+checksumSize)
+	b = append(b, payload...)
+	b = append(b, base58Checksum(payload, checksumSize)...)
+	return base58.Encode(b)
+}
+
+// Base58CheckDecode decodes a string made by Base58CheckEncode with the
+// same checksum size and returns the payload. It returns
+// ErrBase58CheckLength if the string is too short to hold the checksum and
+// ErrChecksumMismatch if the checksum is wrong.
+func Base58CheckDecode(s string, checksumSize int) ([]byte, error) {
+	if checksumSize < 0 || checksumSize > 64 {
+		return nil, ErrBase58CheckLength
+	}
+
+	data := base58.Decode(s)
+	if len(data) < checksumSize || len(data) == 0 {
+		return nil, ErrBase58CheckLength
+	}
+
+	payload := data[:len(data)-checksumSize]
+	if !bytes.Equal(data[len(payload):], base58Checksum(payload, checksumSize)) {
+		return nil, ErrChecksumMismatch
+	}
+	return payload, nil
+}
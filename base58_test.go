@@ -0,0 +1,65 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestBase58Check(t *testing.T) {
+	payloads := [][]byte{
+		{},
+		{0},
+		{0, 0, 1},
+		[]byte("an auxiliary identifier"),
+	}
+
+	for _, size := range []int{0, 1, AddressChecksumSize, 64} {
+		for i, payload := range payloads {
+			s := Base58CheckEncode(payload, size)
+			got, err := Base58CheckDecode(s, size)
+			if err != nil {
+				if len(payload)+size == 0 && err == ErrBase58CheckLength {
+					continue
+				}
+				t.Errorf("Base58CheckDecode #%d, size %d: %v", i, size, err)
+				continue
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Base58CheckDecode #%d, size %d: got %x want %x",
+					i, size, got, payload)
+			}
+		}
+	}
+
+	// Addresses are Base58Check strings.
+	for _, test := range addressTests {
+		data, err := Base58CheckDecode(test.addrString[3:], AddressChecksumSize)
+		if err != nil {
+			t.Errorf("Base58CheckDecode %s: %v", test.addrString, err)
+			continue
+		}
+		if s := Base58CheckEncode(data, AddressChecksumSize); s != test.addrString[3:] {
+			t.Errorf("Base58CheckEncode: got %s want %s", s, test.addrString[3:])
+		}
+	}
+
+	// A corrupted checksum is detected.
+	b := base58.Decode(Base58CheckEncode([]byte("abc"), AddressChecksumSize))
+	b[len(b)-1] ^= 1
+	if _, err := Base58CheckDecode(base58.Encode(b), AddressChecksumSize); err != ErrChecksumMismatch {
+		t.Errorf("Base58CheckDecode: got %v want %v", err, ErrChecksumMismatch)
+	}
+
+	if _, err := Base58CheckDecode("2", AddressChecksumSize); err != ErrBase58CheckLength {
+		t.Errorf("Base58CheckDecode: got %v want %v", err, ErrBase58CheckLength)
+	}
+	if _, err := Base58CheckDecode("2", 65); err != ErrBase58CheckLength {
+		t.Errorf("Base58CheckDecode: got %v want %v", err, ErrBase58CheckLength)
+	}
+}
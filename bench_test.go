@@ -10,6 +10,7 @@ package bmutil
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"testing"
 )
@@ -82,6 +83,54 @@ func BenchmarkReadVarInt9(b *testing.B) {
 	}
 }
 
+// BenchmarkWriteVarIntBuffer performs a benchmark on how long it takes to
+// write variable length integers of each size to a bytes.Buffer.
+func BenchmarkWriteVarIntBuffer(b *testing.B) {
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		WriteVarInt(&buf, 1)
+		WriteVarInt(&buf, 65535)
+		WriteVarInt(&buf, 4294967295)
+		WriteVarInt(&buf, 18446744073709551615)
+	}
+}
+
+// varIntStream is a concatenation of variable length integers of each size.
+var varIntStream = []byte{
+	0x01,
+	0xfd, 0xff, 0xff,
+	0xfe, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+}
+
+// BenchmarkReadVarIntBytesReader performs a benchmark on how long it takes
+// to read variable length integers of each size from a reusable
+// bytes.Reader.
+func BenchmarkReadVarIntBytesReader(b *testing.B) {
+	r := bytes.NewReader(varIntStream)
+	for i := 0; i < b.N; i++ {
+		r.Reset(varIntStream)
+		for j := 0; j < 4; j++ {
+			ReadVarInt(r)
+		}
+	}
+}
+
+// BenchmarkReadVarIntReader performs a benchmark on how long it takes to
+// read variable length integers of each size from a reader which has no
+// methods other than Read.
+func BenchmarkReadVarIntReader(b *testing.B) {
+	br := bytes.NewReader(varIntStream)
+	var r io.Reader = struct{ io.Reader }{br}
+	for i := 0; i < b.N; i++ {
+		br.Reset(varIntStream)
+		for j := 0; j < 4; j++ {
+			ReadVarInt(r)
+		}
+	}
+}
+
 // BenchmarkReadVarStr4 performs a benchmark on how long it takes to read a
 // four byte variable length string.
 func BenchmarkReadVarStr4(b *testing.B) {
@@ -0,0 +1,113 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package bootstrap finds the addresses of nodes to connect to when a node
+// starts for the first time and knows of no peers.
+package bootstrap
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/netparams"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// secondsIn3Days is the number of seconds in 3 days.
+	secondsIn3Days = 24 * 60 * 60 * 3
+
+	// secondsIn4Days is the number of seconds in 4 days.
+	secondsIn4Days = 24 * 60 * 60 * 4
+)
+
+// ErrNoSeeds is returned when no addresses could be found either from the
+// DNS seeds or from the hard-coded seed nodes.
+var ErrNoSeeds = errors.New("no seed addresses found")
+
+// LookupFunc resolves a host name to its IP addresses. net.LookupIP may be
+// used, or a function which resolves through a proxy.
+type LookupFunc func(host string) ([]net.IP, error)
+
+// newSeedAddress returns a NetAddress with a timestamp between three and
+// seven days ago, so that seeded addresses are not preferred over
+// addresses learned from the network.
+func newSeedAddress(ip net.IP, port uint16, stream uint32) *wire.NetAddress {
+	na := wire.NewNetAddressIPPort(ip, port, stream, wire.SFNodeNetwork)
+	na.Timestamp = time.Unix(time.Now().Unix()-secondsIn3Days-
+		rand.Int63n(secondsIn4Days), 0)
+	return na
+}
+
+// DNSSeeds resolves the DNS seeds of the network and returns the addresses
+// that they return. Errors from individual seeds are ignored unless every
+// seed fails, in which case the last error is returned.
+func DNSSeeds(params *netparams.Params, stream uint32, lookup LookupFunc) ([]*wire.NetAddress, error) {
+	var addrs []*wire.NetAddress
+	var lastErr error
+	for _, seed := range params.DNSSeeds {
+		ips, err := lookup(seed.Host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, newSeedAddress(ip, seed.Port, stream))
+		}
+	}
+
+	if len(addrs) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return addrs, nil
+}
+
+// SeedNodes returns the addresses of the hard-coded seed nodes of the
+// network. Seed nodes given by host name rather than IP address are
+// resolved with lookup.
+func SeedNodes(params *netparams.Params, stream uint32, lookup LookupFunc) ([]*wire.NetAddress, error) {
+	addrs := make([]*wire.NetAddress, 0, len(params.SeedNodes))
+	for _, node := range params.SeedNodes {
+		host, portStr, err := net.SplitHostPort(node)
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			if ips, err = lookup(host); err != nil {
+				continue
+			}
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, newSeedAddress(ip, uint16(port), stream))
+		}
+	}
+	return addrs, nil
+}
+
+// Seed returns addresses suitable for the address manager of a node
+// starting for the first time. The DNS seeds are tried first and the
+// hard-coded seed nodes are used if they return nothing.
+func Seed(params *netparams.Params, stream uint32, lookup LookupFunc) ([]*wire.NetAddress, error) {
+	if addrs, _ := DNSSeeds(params, stream, lookup); len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	addrs, err := SeedNodes(params, stream, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNoSeeds
+	}
+	return addrs, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bootstrap_test
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/bootstrap"
+	"github.com/DanielKrawisz/bmutil/netparams"
+)
+
+var errLookup = errors.New("lookup failed")
+
+// fakeLookup returns a LookupFunc which answers from the given map.
+func fakeLookup(hosts map[string][]net.IP) bootstrap.LookupFunc {
+	return func(host string) ([]net.IP, error) {
+		ips, ok := hosts[host]
+		if !ok {
+			return nil, errLookup
+		}
+		return ips, nil
+	}
+}
+
+var testParams = netparams.Params{
+	DNSSeeds: []netparams.DNSSeed{
+		{"seed1.example.com", 8444},
+		{"seed2.example.com", 8080},
+	},
+	SeedNodes: []string{
+		"1.2.3.4:8444",
+		"node.example.com:8111",
+	},
+}
+
+// TestSeed tests that Seed uses the DNS seeds when possible and falls back
+// to the hard-coded seed nodes.
+func TestSeed(t *testing.T) {
+	tests := []struct {
+		hosts map[string][]net.IP
+		addrs []string
+		err   error
+	}{
+		// Both DNS seeds answer.
+		{
+			map[string][]net.IP{
+				"seed1.example.com": {net.ParseIP("5.5.5.5"), net.ParseIP("6.6.6.6")},
+				"seed2.example.com": {net.ParseIP("7.7.7.7")},
+			},
+			[]string{"5.5.5.5:8444", "6.6.6.6:8444", "7.7.7.7:8080"},
+			nil,
+		},
+		// One DNS seed fails.
+		{
+			map[string][]net.IP{
+				"seed2.example.com": {net.ParseIP("7.7.7.7")},
+			},
+			[]string{"7.7.7.7:8080"},
+			nil,
+		},
+		// Both DNS seeds fail.
+		{
+			map[string][]net.IP{
+				"node.example.com": {net.ParseIP("8.8.8.8")},
+			},
+			[]string{"1.2.3.4:8444", "8.8.8.8:8111"},
+			nil,
+		},
+		// Only the seed node given by IP address is found.
+		{
+			map[string][]net.IP{},
+			[]string{"1.2.3.4:8444"},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		addrs, err := bootstrap.Seed(&testParams, 1, fakeLookup(test.hosts))
+		if err != test.err {
+			t.Errorf("test #%d: got error %v want %v", i, err, test.err)
+			continue
+		}
+		if len(addrs) != len(test.addrs) {
+			t.Errorf("test #%d: got %d addresses want %d", i, len(addrs),
+				len(test.addrs))
+			continue
+		}
+		now := time.Now()
+		for j, na := range addrs {
+			got := net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port)))
+			if got != test.addrs[j] {
+				t.Errorf("test #%d, address %d: got %s want %s", i, j, got,
+					test.addrs[j])
+			}
+			if na.Stream != 1 {
+				t.Errorf("test #%d, address %d: got stream %d want %d", i, j,
+					na.Stream, 1)
+			}
+			age := now.Sub(na.Timestamp)
+			if age < 3*24*time.Hour || age > 7*24*time.Hour {
+				t.Errorf("test #%d, address %d: timestamp %v is not between "+
+					"three and seven days ago", i, j, na.Timestamp)
+			}
+		}
+	}
+
+	// Nothing is found.
+	_, err := bootstrap.Seed(&netparams.Params{}, 1,
+		fakeLookup(map[string][]net.IP{}))
+	if err != bootstrap.ErrNoSeeds {
+		t.Errorf("got error %v want %v", err, bootstrap.ErrNoSeeds)
+	}
+}
+
+// TestDNSSeedsError tests that DNSSeeds reports an error if every seed
+// fails.
+func TestDNSSeedsError(t *testing.T) {
+	_, err := bootstrap.DNSSeeds(&testParams, 1, fakeLookup(nil))
+	if err != errLookup {
+		t.Errorf("got error %v want %v", err, errLookup)
+	}
+}
+
+// TestMainNetSeedNodes tests that the hard-coded seed nodes of the main
+// network are well formed.
+func TestMainNetSeedNodes(t *testing.T) {
+	addrs, err := bootstrap.SeedNodes(&netparams.MainNetParams, 1,
+		fakeLookup(nil))
+	if err != nil {
+		t.Fatalf("SeedNodes: %v", err)
+	}
+	if len(addrs) != len(netparams.MainNetParams.SeedNodes) {
+		t.Errorf("got %d addresses want %d", len(addrs),
+			len(netparams.MainNetParams.SeedNodes))
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// aliasVersion is the version of the alias record format.
+	aliasVersion = 1
+
+	// MaxAliasName is the longest name in bytes which an alias may have.
+	MaxAliasName = 255
+
+	// maxAliasSignature is longer than any DER encoded signature.
+	maxAliasSignature = 80
+)
+
+// aliasDomain is hashed before an alias record when it is signed, so that
+// its signature cannot be taken for that of an object or anything else.
+var aliasDomain = []byte("Bitmessage alias record\x00")
+
+var (
+	// ErrInvalidAliasName is returned by SignAlias for a name which is
+	// empty, too long, not valid UTF-8, or has control characters or
+	// space at either end.
+	ErrInvalidAliasName = errors.New("invalid alias name")
+
+	// ErrMalformedAlias is returned by DecodeAlias for a record which
+	// cannot be read.
+	ErrMalformedAlias = errors.New("malformed alias record")
+
+	// ErrAliasExpired is returned by Alias.Verify for a record which is not
+	// valid at the given time.
+	ErrAliasExpired = errors.New("alias record expired")
+
+	// ErrAliasTimes is returned by SignAlias if the expiration is before
+	// the time of creation.
+	ErrAliasTimes = errors.New("alias record expires before it is created")
+
+	// ErrAliasAddressMismatch is returned by Alias.Verify for a record
+	// whose address is not derived from its keys.
+	ErrAliasAddressMismatch = errors.New("alias address does not match its keys")
+)
+
+// Alias is a record which binds a human-readable name to an address. It is
+// signed with the signing key of the address, so anyone who has it can
+// check that the owner of the address claimed the name, and it carries the
+// public keys of the address so that it can be checked without looking up
+// a pubkey. Whether a name belongs to only one address is up to the naming
+// layer which collects the records; a record only shows that an address
+// claimed it between Created and Expiration.
+type Alias struct {
+	// Name is the name which the address claims. Names are compared as
+	// they are, so a naming layer should normalize them before they are
+	// signed.
+	Name string
+
+	// Address is the address which claims the name. It is derived from
+	// Key.
+	Address bmutil.Address
+
+	// Key holds the public keys of the address.
+	Key *identity.PublicKey
+
+	// Created is the time at which the claim begins.
+	Created time.Time
+
+	// Expiration is the time at which the claim ends.
+	Expiration time.Time
+
+	// Signature is the signature of the record by the signing key of the
+	// address.
+	Signature []byte
+}
+
+// checkAliasName returns ErrInvalidAliasName if name cannot be an alias.
+func checkAliasName(name string) error {
+	if name == "" || len(name) > MaxAliasName || !utf8.ValidString(name) ||
+		strings.TrimSpace(name) != name {
+		return ErrInvalidAliasName
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrInvalidAliasName
+		}
+	}
+	return nil
+}
+
+// SignAlias creates an alias record in which the address of privID claims
+// name from created until expiration, and signs it. The times are kept to
+// the second. It returns ErrAliasTimes if expiration is before created.
+func SignAlias(name string, privID *identity.PrivateID, created,
+	expiration time.Time) (*Alias, error) {
+
+	if err := checkAliasName(name); err != nil {
+		return nil, err
+	}
+	if expiration.Before(created) {
+		return nil, ErrAliasTimes
+	}
+	a := &Alias{
+		Name:       name,
+		Address:    privID.Address(),
+		Key:        privID.PublicKey(),
+		Created:    time.Unix(created.Unix(), 0),
+		Expiration: time.Unix(expiration.Unix(), 0),
+	}
+
+	hash, err := signingHash(a.encodeForSigning)
+	if err != nil {
+		return nil, err
+	}
+	if a.Signature, err = privID.PrivateKey().Signer().Sign(hash); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// encodeForSigning writes what is signed, which is aliasDomain followed by
+// the record without its signature.
+func (a *Alias) encodeForSigning(w io.Writer) error {
+	if _, err := w.Write(aliasDomain); err != nil {
+		return err
+	}
+	return a.encodeUnsigned(w)
+}
+
+// encodeUnsigned writes the record without its signature.
+func (a *Alias) encodeUnsigned(w io.Writer) error {
+	var err error
+	if err = bmutil.WriteVarInt(w, aliasVersion); err != nil {
+		return err
+	}
+	if err = bmutil.WriteVarInt(w, a.Address.Version()); err != nil {
+		return err
+	}
+	if err = bmutil.WriteVarInt(w, a.Address.Stream()); err != nil {
+		return err
+	}
+	if _, err = w.Write(a.Key.Verification.Wire()[:]); err != nil {
+		return err
+	}
+	if _, err = w.Write(a.Key.Encryption.Wire()[:]); err != nil {
+		return err
+	}
+	if err = bmutil.WriteVarString(w, a.Name); err != nil {
+		return err
+	}
+
+	var times [16]byte
+	binary.BigEndian.PutUint64(times[:8], uint64(a.Created.Unix()))
+	binary.BigEndian.PutUint64(times[8:], uint64(a.Expiration.Unix()))
+	_, err = w.Write(times[:])
+	return err
+}
+
+// Encode writes the alias record.
+func (a *Alias) Encode(w io.Writer) error {
+	if err := a.encodeUnsigned(w); err != nil {
+		return err
+	}
+	return bmutil.WriteVarBytes(w, a.Signature)
+}
+
+// Bytes returns the encoding of the alias record.
+func (a *Alias) Bytes() []byte {
+	var b bytes.Buffer
+	a.Encode(&b)
+	return b.Bytes()
+}
+
+// DecodeAlias reads an alias record. It returns ErrMalformedAlias if the
+// record cannot be read, but does not check its signature, which is done
+// by Verify.
+func DecodeAlias(r io.Reader) (*Alias, error) {
+	version, err := bmutil.ReadVarInt(r)
+	if err != nil || version != aliasVersion {
+		return nil, ErrMalformedAlias
+	}
+	addrVersion, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+	stream, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+
+	var vk, ek wire.PubKey
+	if _, err = io.ReadFull(r, vk[:]); err != nil {
+		return nil, ErrMalformedAlias
+	}
+	if _, err = io.ReadFull(r, ek[:]); err != nil {
+		return nil, ErrMalformedAlias
+	}
+	key, err := identity.NewPublicKey(&vk, &ek)
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+	pub, err := identity.NewPublic(key, addrVersion, stream, 0, nil)
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+
+	a := &Alias{Address: pub.Address(), Key: key}
+	name, err := wire.ReadBoundedBytes(r, MaxAliasName, "DecodeAlias", "name")
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+	a.Name = string(name)
+	if checkAliasName(a.Name) != nil {
+		return nil, ErrMalformedAlias
+	}
+
+	var times [16]byte
+	if _, err = io.ReadFull(r, times[:]); err != nil {
+		return nil, ErrMalformedAlias
+	}
+	a.Created = time.Unix(int64(binary.BigEndian.Uint64(times[:8])), 0)
+	a.Expiration = time.Unix(int64(binary.BigEndian.Uint64(times[8:])), 0)
+
+	a.Signature, err = wire.ReadBoundedBytes(r, maxAliasSignature,
+		"DecodeAlias", "signature")
+	if err != nil {
+		return nil, ErrMalformedAlias
+	}
+	return a, nil
+}
+
+// Verify checks that Address is derived from Key, the signature of the
+// record, and that now is between Created and Expiration. It returns
+// ErrAliasAddressMismatch if the address does not match the keys,
+// ErrMalformedSignature or ErrInvalidSignature if the signature is bad, and
+// ErrAliasExpired if the record is not valid at now.
+func (a *Alias) Verify(now time.Time) error {
+	// Only the version and stream of the address are signed, so the rest
+	// of it must be checked against the keys.
+	if a.Address == nil {
+		return ErrAliasAddressMismatch
+	}
+	pub, err := identity.NewPublic(a.Key, a.Address.Version(),
+		a.Address.Stream(), 0, nil)
+	if err != nil || pub.Address().String() != a.Address.String() {
+		return ErrAliasAddressMismatch
+	}
+
+	s, err := CheckSignature(a.Signature)
+	if err != nil {
+		return err
+	}
+	key, err := a.Key.Verification.Wire().ToBtcec()
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	// Unlike objects, alias records were never signed with SHA-1, so only
+	// SHA-256 is accepted.
+	sha := sha256.New()
+	if err = a.encodeForSigning(sha); err != nil {
+		return err
+	}
+	if !s.Verify(sha.Sum(nil), key) {
+		return ErrInvalidSignature
+	}
+
+	if now.Before(a.Created) || !now.Before(a.Expiration) {
+		return ErrAliasExpired
+	}
+	return nil
+}
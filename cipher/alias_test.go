@@ -0,0 +1,104 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+)
+
+func TestAlias(t *testing.T) {
+	created := time.Unix(1500000000, 0)
+	expiration := created.Add(365 * 24 * time.Hour)
+	a, err := SignAlias("alice", PrivID1(), created, expiration)
+	if err != nil {
+		t.Fatalf("SignAlias: %v", err)
+	}
+	if !AddressesEqual(a.Address, PrivID1().Address()) {
+		t.Errorf("Address: got %s want %s", a.Address, PrivID1().Address())
+	}
+
+	b, err := DecodeAlias(bytes.NewReader(a.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAlias: %v", err)
+	}
+	if b.Address.String() != a.Address.String() || b.Name != a.Name ||
+		!b.Created.Equal(a.Created) || !b.Expiration.Equal(a.Expiration) ||
+		!reflect.DeepEqual(b.Signature, a.Signature) {
+		t.Errorf("DecodeAlias: got %+v want %+v", b, a)
+	}
+
+	if err := b.Verify(created.Add(time.Hour)); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	for _, now := range []time.Time{created.Add(-time.Second), expiration} {
+		if err := b.Verify(now); err != ErrAliasExpired {
+			t.Errorf("Verify(%v): got %v want %v", now, err, ErrAliasExpired)
+		}
+	}
+
+	// The record cannot be changed or given to another address.
+	b.Name = "mallory"
+	if err := b.Verify(created); err != ErrInvalidSignature {
+		t.Errorf("Verify: got %v want %v", err, ErrInvalidSignature)
+	}
+	b.Name = a.Name
+	b.Key = PrivID2().PublicKey()
+	if err := b.Verify(created); err != ErrAliasAddressMismatch {
+		t.Errorf("Verify: got %v want %v", err, ErrAliasAddressMismatch)
+	}
+	b.Address = PrivID2().Address()
+	if err := b.Verify(created); err != ErrInvalidSignature {
+		t.Errorf("Verify: got %v want %v", err, ErrInvalidSignature)
+	}
+	b.Key, b.Address = a.Key, a.Address
+	b.Signature = []byte{1, 2, 3}
+	if err := b.Verify(created); err != ErrMalformedSignature {
+		t.Errorf("Verify: got %v want %v", err, ErrMalformedSignature)
+	}
+}
+
+func TestAliasErrors(t *testing.T) {
+	now := time.Now()
+	for _, name := range []string{"", " alice", "al\x00ice", "\xff",
+		strings.Repeat("a", MaxAliasName+1)} {
+		if _, err := SignAlias(name, PrivID1(), now, now.Add(time.Hour)); err !=
+			ErrInvalidAliasName {
+			t.Errorf("SignAlias(%q): got %v want %v", name, err,
+				ErrInvalidAliasName)
+		}
+	}
+
+	if _, err := SignAlias("alice", PrivID1(), now, now.Add(-time.Second)); err !=
+		ErrAliasTimes {
+		t.Errorf("SignAlias: got %v want %v", err, ErrAliasTimes)
+	}
+
+	a, err := SignAlias("alice", PrivID1(), now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignAlias: %v", err)
+	}
+
+	// An address of the same version and stream but other keys.
+	swapped := *a
+	swapped.Address = PrivID2().Address()
+	if err := swapped.Verify(now); err != ErrAliasAddressMismatch {
+		t.Errorf("Verify: got %v want %v", err, ErrAliasAddressMismatch)
+	}
+
+	encoded := a.Bytes()
+	version := append([]byte{2}, encoded[1:]...)
+	for i, data := range [][]byte{nil, encoded[:len(encoded)-1], version} {
+		if _, err := DecodeAlias(bytes.NewReader(data)); err != ErrMalformedAlias {
+			t.Errorf("test #%d: got %v want %v", i, err, ErrMalformedAlias)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Backend names an implementation of a cryptographic primitive.
+type Backend string
+
+// The implementations which may be chosen for AES and SHA-512.
+const (
+	// BackendGeneric is the portable implementation in Go.
+	BackendGeneric Backend = "generic"
+
+	// BackendAESNI is the AES-NI instruction set on amd64.
+	BackendAESNI Backend = "aes-ni"
+
+	// BackendAVX2 is the AVX2 vector implementation on amd64.
+	BackendAVX2 Backend = "avx2"
+
+	// BackendARMv8 is the ARMv8 cryptography extension on arm64.
+	BackendARMv8 Backend = "armv8"
+
+	// BackendCPACF is the CP Assist for Cryptographic Functions on s390x.
+	BackendCPACF Backend = "cpacf"
+)
+
+// Hardware returns whether the backend is accelerated by the processor.
+func (b Backend) Hardware() bool {
+	return b != BackendGeneric
+}
+
+// Backends describes the implementations used for payload encryption.
+// AES is used by the ECIES encryption of objects and SHA-512 by the key
+// derivation, by signatures and by proof of work.
+type Backends struct {
+	AES Backend
+	SHA Backend
+}
+
+// String returns the backends in a human-readable form.
+func (b Backends) String() string {
+	return fmt.Sprintf("AES: %s, SHA-512: %s", b.AES, b.SHA)
+}
+
+// backends is detected once at startup.
+var backends = detectBackends(runtime.GOARCH)
+
+// ActiveBackends returns the implementations of AES and SHA-512 which were
+// chosen for this processor. The standard library dispatches to the same
+// instructions at run time, so this reports what encryption actually uses.
+// A feature disabled with GODEBUG, such as cpu.aes=off, is reported as
+// unavailable.
+//
+// Built with the purego tag, which also turns off the assembly in the
+// standard library, only the generic implementations are used and no
+// processor features are detected. That build needs no assembly or cgo and
+// is suitable for wasm and TinyGo.
+func ActiveBackends() Backends {
+	return backends
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !purego
+// +build !purego
+
+package cipher
+
+import "golang.org/x/sys/cpu"
+
+// detectBackends selects the fastest implementations that the processor
+// supports on the given architecture.
+func detectBackends(arch string) Backends {
+	b := Backends{AES: BackendGeneric, SHA: BackendGeneric}
+
+	switch arch {
+	case "amd64":
+		if cpu.X86.HasAES {
+			b.AES = BackendAESNI
+		}
+		if cpu.X86.HasAVX2 && cpu.X86.HasBMI1 && cpu.X86.HasBMI2 {
+			b.SHA = BackendAVX2
+		}
+	case "arm64":
+		if cpu.ARM64.HasAES {
+			b.AES = BackendARMv8
+		}
+		if cpu.ARM64.HasSHA512 {
+			b.SHA = BackendARMv8
+		}
+	case "s390x":
+		if cpu.S390X.HasAES && cpu.S390X.HasAESCBC {
+			b.AES = BackendCPACF
+		}
+		if cpu.S390X.HasSHA512 {
+			b.SHA = BackendCPACF
+		}
+	}
+
+	return b
+}
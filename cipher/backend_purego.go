@@ -0,0 +1,14 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build purego
+// +build purego
+
+package cipher
+
+// detectBackends always selects the generic implementations, since the
+// purego build uses no assembly.
+func detectBackends(arch string) Backends {
+	return Backends{AES: BackendGeneric, SHA: BackendGeneric}
+}
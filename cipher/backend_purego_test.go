@@ -0,0 +1,23 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build purego
+// +build purego
+
+package cipher_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+)
+
+// TestActiveBackends tests that the purego build uses only the generic
+// implementations.
+func TestActiveBackends(t *testing.T) {
+	b := cipher.ActiveBackends()
+	if b.AES != cipher.BackendGeneric || b.SHA != cipher.BackendGeneric {
+		t.Errorf("got %s want generic backends", b)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !purego
+// +build !purego
+
+package cipher_test
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/cpu"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+)
+
+// TestActiveBackends tests that the reported backends agree with the
+// features of the processor.
+func TestActiveBackends(t *testing.T) {
+	b := cipher.ActiveBackends()
+	t.Logf("backends: %s", b)
+
+	var aes, sha bool
+	switch runtime.GOARCH {
+	case "amd64":
+		aes = cpu.X86.HasAES
+		sha = cpu.X86.HasAVX2 && cpu.X86.HasBMI1 && cpu.X86.HasBMI2
+	case "arm64":
+		aes = cpu.ARM64.HasAES
+		sha = cpu.ARM64.HasSHA512
+	case "s390x":
+		aes = cpu.S390X.HasAES && cpu.S390X.HasAESCBC
+		sha = cpu.S390X.HasSHA512
+	}
+
+	if b.AES.Hardware() != aes {
+		t.Errorf("AES: got %s, hardware support %v", b.AES, aes)
+	}
+	if b.SHA.Hardware() != sha {
+		t.Errorf("SHA-512: got %s, hardware support %v", b.SHA, sha)
+	}
+	if cipher.BackendGeneric.Hardware() {
+		t.Error("generic backend reported as hardware")
+	}
+}
@@ -6,9 +6,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/subtle"
 	"errors"
 	"fmt"
 	"io"
@@ -49,7 +46,7 @@ func (i *incompleteTaglessBroadcast) Encrypt(address bmutil.Address, data []byte
 	encrypted, err := btcec.Encrypt(bmutil.V4BroadcastDecryptionKey(address).PubKey(), data)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("encryption failed: %w", err)
 	}
 
 	return obj.NewTaglessBroadcast(0, i.expiration, i.streamNumber, encrypted), nil
@@ -83,7 +80,7 @@ func (i *incompleteTaggedBroadcast) Encrypt(address bmutil.Address, data []byte)
 	encrypted, err := btcec.Encrypt(bmutil.V5BroadcastDecryptionKey(address).PubKey(), data)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("encryption failed: %w", err)
 	}
 
 	return obj.NewTaggedBroadcast(0, i.expiration, i.streamNumber, i.tag, encrypted), nil
@@ -164,22 +161,16 @@ func (broadcast *Broadcast) decodeFromDecrypted(r io.Reader) error {
 	broadcast.bm = &Bitmessage{}
 	err := broadcast.bm.decodeBroadcast(r)
 	if err != nil {
-		return err
+		return malformed("payload", err)
 	}
 
-	var sigLength uint64
-	if sigLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if sigLength > obj.SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, obj.SignatureMaxLength)
-		return wire.NewMessageError("DecodeFromDecrypted", str)
+	broadcast.sig, err = wire.ReadBoundedBytes(r, obj.SignatureMaxLength,
+		"DecodeFromDecrypted", "signature")
+	if err != nil {
+		return malformed("signature", err)
 	}
-	broadcast.sig = make([]byte, sigLength)
-	_, err = io.ReadFull(r, broadcast.sig)
-	return err
+
+	return checkTrailing(r)
 }
 
 func (broadcast *Broadcast) signAndEncrypt(
@@ -187,26 +178,24 @@ func (broadcast *Broadcast) signAndEncrypt(
 	address bmutil.Address,
 	private *identity.PrivateKey) error {
 
-	// Start signing
-	var b bytes.Buffer
-	err := broadcastEncodeForSigning(&b, i, broadcast.bm)
+	// Hash
+	hash, err := signingHash(func(w io.Writer) error {
+		return broadcastEncodeForSigning(w, i, broadcast.bm)
+	})
 	if err != nil {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	b.Reset()
-
 	// Sign
-	sig, err := private.Signing.Sign(hash[:])
+	broadcast.sig, err = private.Signer().Sign(hash)
 	if err != nil {
-		return fmt.Errorf("signing failed: %v", err)
+		return fmt.Errorf("signing failed: %w", err)
 	}
-	broadcast.sig = sig.Serialize()
 
 	// Start encryption
-	err = broadcast.encodeForEncryption(&b)
+	b := wire.GetBuffer()
+	defer wire.PutBuffer(b)
+	err = broadcast.encodeForEncryption(b)
 	if err != nil {
 		return err
 	}
@@ -215,7 +204,7 @@ func (broadcast *Broadcast) signAndEncrypt(
 	broadcast.msg, err = i.Encrypt(address, b.Bytes())
 
 	if err != nil {
-		return fmt.Errorf("encryption failed: %v", err)
+		return fmt.Errorf("encryption failed: %w", err)
 	}
 
 	return nil
@@ -239,30 +228,8 @@ func (broadcast Broadcast) verify(address bmutil.Address) error {
 			"forwarding attack.", dencAddr, genAddr)
 	}
 
-	// Start signature verification
-	var b bytes.Buffer
-	err := broadcast.encodeForSigning(&b)
-	if err != nil {
-		return err
-	}
-
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(broadcast.sig, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	pk := id.Key().Verification
-	if !sig.Verify(hash[:], pk.Btcec()) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], pk.Btcec()) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-	return nil
+	return verifySignature(broadcast.sig, id.Key().Verification.Btcec(),
+		broadcast.encodeForSigning)
 }
 
 // CreateTaglessBroadcast creates a Broadcast that we send over the network,
@@ -322,7 +289,7 @@ func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Addre
 		if err == btcec.ErrInvalidMAC { // decryption failed due to invalid key
 			return nil, ErrInvalidIdentity
 		}
-		return nil, err
+		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
 	broadcast := Broadcast{}
 
@@ -357,7 +324,7 @@ func NewTaglessBroadcast(msg *obj.TaglessBroadcast, address bmutil.Address) (*Br
 // NewTaggedBroadcast takes a broadcast we have received over the network
 // and attempts to decrypt it.
 func NewTaggedBroadcast(msg *obj.TaggedBroadcast, address bmutil.Address) (*Broadcast, error) {
-	if subtle.ConstantTimeCompare(msg.Tag[:], bmutil.Tag(address)[:]) != 1 {
+	if !msg.Tag.ConstantTimeEqual(bmutil.Tag(address)) {
 		return nil, ErrInvalidIdentity
 	}
 
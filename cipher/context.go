@@ -0,0 +1,123 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"context"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// The functions in this file are like those without Context in their names,
+// except that they take a context. A single encryption or decryption is not
+// interrupted, but nothing is started once the context is done, and the
+// functions which try many identities stop between them. The context is
+// also there to carry values, such as tracing metadata, to code which wraps
+// these functions.
+
+// SignAndEncryptBroadcastContext is like SignAndEncryptBroadcast, but returns
+// the error of ctx if it is done.
+func SignAndEncryptBroadcastContext(ctx context.Context, expiration time.Time,
+	msg *Bitmessage, tag *hash.Sha, privID *identity.PrivateID) (*Broadcast, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return SignAndEncryptBroadcast(expiration, msg, tag, privID)
+}
+
+// SignAndEncryptMessageContext is like SignAndEncryptMessage, but returns the
+// error of ctx if it is done.
+func SignAndEncryptMessageContext(ctx context.Context, expiration time.Time,
+	streamNumber uint64, bm *Bitmessage, ack []byte,
+	privID *identity.PrivateKey, pubID *identity.PublicKey) (*Message, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return SignAndEncryptMessage(expiration, streamNumber, bm, ack, privID,
+		pubID)
+}
+
+// TryDecryptAndVerifyBroadcastContext is like TryDecryptAndVerifyBroadcast,
+// but returns the error of ctx if it is done.
+func TryDecryptAndVerifyBroadcastContext(ctx context.Context,
+	msg obj.Broadcast, address bmutil.Address) (*Broadcast, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return TryDecryptAndVerifyBroadcast(msg, address)
+}
+
+// TryDecryptAndVerifyMessageContext is like TryDecryptAndVerifyMessage, but
+// returns the error of ctx if it is done.
+func TryDecryptAndVerifyMessageContext(ctx context.Context, msg *obj.Message,
+	privID *identity.PrivateID) (*Message, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return TryDecryptAndVerifyMessage(msg, privID)
+}
+
+// TryDecryptAndVerifyBroadcastAny tries to decrypt a broadcast with each of
+// the addresses in turn, as when matching it against a set of
+// subscriptions. It returns the broadcast and the index of the address
+// which decrypted it. If decryption succeeds but verification fails, it
+// returns the error as TryDecryptAndVerifyBroadcast does, along with the
+// index. If no address decrypts the broadcast, it returns
+// ErrInvalidIdentity, and if ctx is done first, it returns the error of
+// ctx. The index is -1 in both cases. If attempt is not nil, it is called
+// with the error of each address which is tried, as for counting them.
+func TryDecryptAndVerifyBroadcastAny(ctx context.Context, msg obj.Broadcast,
+	addresses []bmutil.Address, attempt func(error)) (*Broadcast, int, error) {
+
+	for i, address := range addresses {
+		if err := ctx.Err(); err != nil {
+			return nil, -1, err
+		}
+		b, err := TryDecryptAndVerifyBroadcast(msg, address)
+		if attempt != nil {
+			attempt(err)
+		}
+		if err == ErrInvalidIdentity {
+			continue
+		}
+		return b, i, err
+	}
+	return nil, -1, ErrInvalidIdentity
+}
+
+// TryDecryptAndVerifyMessageAny tries to decrypt a message with each of the
+// private identities in turn. It returns the message and the index of the
+// identity which decrypted it. If decryption succeeds but verification
+// fails, it returns the error as TryDecryptAndVerifyMessage does, along
+// with the index. If no identity decrypts the message, it returns
+// ErrInvalidIdentity, and if ctx is done first, it returns the error of
+// ctx. The index is -1 in both cases. If attempt is not nil, it is called
+// with the error of each identity which is tried.
+func TryDecryptAndVerifyMessageAny(ctx context.Context, msg *obj.Message,
+	privIDs []*identity.PrivateID, attempt func(error)) (*Message, int, error) {
+
+	for i, privID := range privIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, -1, err
+		}
+		m, err := TryDecryptAndVerifyMessage(msg, privID)
+		if attempt != nil {
+			attempt(err)
+		}
+		if err == ErrInvalidIdentity {
+			continue
+		}
+		return m, i, err
+	}
+	return nil, -1, ErrInvalidIdentity
+}
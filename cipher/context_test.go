@@ -0,0 +1,108 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TestTryDecryptAndVerifyMessageAny tests trying a message against several
+// identities.
+func TestTryDecryptAndVerifyMessageAny(t *testing.T) {
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	message, err := TstSignAndEncryptMessage(t, 0, time.Now().Add(time.Minute*5).
+		Truncate(time.Second), 1, nil, 4, 1, 1, SignKey1, EncKey1, nil,
+		destRipe, 1, []byte("Hey there!"), []byte{}, nil, PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessage: %v", err)
+	}
+	var b bytes.Buffer
+	message.Object().MsgObject().Encode(&b)
+	msg := new(obj.Message)
+	msg.Decode(bytes.NewReader(b.Bytes()))
+
+	ctx := context.Background()
+	ids := []*identity.PrivateID{PrivID1(), PrivID2()}
+	if m, i, err := TryDecryptAndVerifyMessageAny(ctx, msg, ids, nil); err != nil ||
+		i != 1 || m == nil {
+		t.Errorf("TryDecryptAndVerifyMessageAny: got %d, %v want 1, nil", i, err)
+	}
+	if _, i, err := TryDecryptAndVerifyMessageAny(ctx, msg, ids[:1], nil); err !=
+		ErrInvalidIdentity || i != -1 {
+		t.Errorf("TryDecryptAndVerifyMessageAny: got %d, %v want -1, %v", i,
+			err, ErrInvalidIdentity)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, i, err := TryDecryptAndVerifyMessageAny(canceled, msg, ids, nil); err !=
+		context.Canceled || i != -1 {
+		t.Errorf("TryDecryptAndVerifyMessageAny: got %d, %v want -1, %v", i,
+			err, context.Canceled)
+	}
+	if _, err := TryDecryptAndVerifyMessageContext(canceled, msg,
+		PrivID2()); err != context.Canceled {
+		t.Errorf("TryDecryptAndVerifyMessageContext: got %v want %v", err,
+			context.Canceled)
+	}
+	if _, err := SignAndEncryptMessageContext(canceled, time.Now(), 1,
+		message.Bitmessage(), nil, PrivID1().PrivateKey(),
+		PrivID2().PublicKey()); err != context.Canceled {
+		t.Errorf("SignAndEncryptMessageContext: got %v want %v", err,
+			context.Canceled)
+	}
+}
+
+// TestTryDecryptAndVerifyBroadcastAny tests trying a broadcast against
+// several subscriptions.
+func TestTryDecryptAndVerifyBroadcastAny(t *testing.T) {
+	sender := ReplaceVersion(PrivID1(), 3)
+	expiration, bm, tag, privID := TstBroadcastEncryptParams(t,
+		time.Now().Add(time.Minute*5).Truncate(time.Second), 1, nil, 3, 1, 1,
+		SignKey1, EncKey1, 1000, 1000, 1, []byte("Hey there!"), sender)
+	broadcast, err := SignAndEncryptBroadcastContext(context.Background(),
+		expiration, bm, tag, privID)
+	if err != nil {
+		t.Fatalf("SignAndEncryptBroadcastContext: %v", err)
+	}
+
+	ctx := context.Background()
+	addresses := []Address{ReplaceVersion(PrivID2(), 3).Address(),
+		sender.Address()}
+	var attempts []error
+	attempt := func(err error) { attempts = append(attempts, err) }
+	if b, i, err := TryDecryptAndVerifyBroadcastAny(ctx, broadcast.Object(),
+		addresses, attempt); err != nil || i != 1 || b == nil {
+		t.Errorf("TryDecryptAndVerifyBroadcastAny: got %d, %v want 1, nil",
+			i, err)
+	}
+	if len(attempts) != 2 || attempts[0] != ErrInvalidIdentity ||
+		attempts[1] != nil {
+		t.Errorf("TryDecryptAndVerifyBroadcastAny: got attempts %v want [%v <nil>]",
+			attempts, ErrInvalidIdentity)
+	}
+	if _, i, err := TryDecryptAndVerifyBroadcastAny(ctx, broadcast.Object(),
+		addresses[:1], nil); err != ErrInvalidIdentity || i != -1 {
+		t.Errorf("TryDecryptAndVerifyBroadcastAny: got %d, %v want -1, %v",
+			i, err, ErrInvalidIdentity)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, i, err := TryDecryptAndVerifyBroadcastAny(canceled,
+		broadcast.Object(), addresses, nil); err != context.Canceled || i != -1 {
+		t.Errorf("TryDecryptAndVerifyBroadcastAny: got %d, %v want -1, %v",
+			i, err, context.Canceled)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// SigningEncoder is implemented by whatever is signed along with the
+// decrypted payload of an object. For a message, that is its
+// *wire.ObjectHeader. For a broadcast, it is the obj.Broadcast itself.
+type SigningEncoder interface {
+	EncodeForSigning(io.Writer) error
+}
+
+// Decrypted is the decrypted payload of a message or broadcast object which
+// is decoded lazily. The public identity of the sender, and the destination
+// of a message, are decoded right away. The content, ack and signature are
+// only located the first time one of them is needed, and are returned as
+// slices of the decrypted data rather than copies. This makes it cheap to
+// verify an object without decoding its content.
+//
+// A Decrypted is not safe for concurrent use.
+type Decrypted struct {
+	Public      identity.Public
+	Destination *hash.Ripe
+
+	data      []byte
+	isMessage bool
+	start     int // Offset of the content.
+
+	located  bool
+	err      error
+	encoding uint64
+	content  []byte
+	ack      []byte
+	signed   int // Length of the part of data which is signed.
+	sig      []byte
+}
+
+// DecodeDecryptedMessage decodes the decrypted payload of a message object.
+// The data must not be modified while the Decrypted is in use.
+func DecodeDecryptedMessage(data []byte) (*Decrypted, error) {
+	return decodeDecrypted(data, true)
+}
+
+// DecodeDecryptedBroadcast decodes the decrypted payload of a broadcast
+// object. The data must not be modified while the Decrypted is in use.
+func DecodeDecryptedBroadcast(data []byte) (*Decrypted, error) {
+	return decodeDecrypted(data, false)
+}
+
+func decodeDecrypted(data []byte, isMessage bool) (*Decrypted, error) {
+	r := bytes.NewReader(data)
+	d := &Decrypted{
+		data:      data,
+		isMessage: isMessage,
+	}
+
+	var err error
+	if d.Public, err = identity.Decode(r); err != nil {
+		return nil, err
+	}
+
+	if isMessage {
+		d.Destination = &hash.Ripe{}
+		if err = wire.ReadElement(r, d.Destination); err != nil {
+			return nil, err
+		}
+	}
+
+	d.start = len(data) - r.Len()
+	return d, nil
+}
+
+// readSlice reads a var int length followed by that many bytes from r and
+// returns the bytes as a slice of d.data.
+func (d *Decrypted) readSlice(r *bytes.Reader, max uint64, name string) ([]byte, error) {
+	length, err := wire.ReadBoundedLength(r, max, "Decrypted", name)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(r.Len()) {
+		return nil, malformed(name, io.ErrUnexpectedEOF)
+	}
+
+	offset := len(d.data) - r.Len()
+	r.Seek(int64(length), io.SeekCurrent)
+	return d.data[offset : offset+int(length) : offset+int(length)], nil
+}
+
+// locate finds the content, ack and signature in the decrypted data. It
+// only does so once and remembers the result.
+func (d *Decrypted) locate() error {
+	if d.located {
+		return d.err
+	}
+	d.located = true
+
+	r := bytes.NewReader(d.data)
+	r.Seek(int64(d.start), io.SeekStart)
+
+	if d.encoding, d.err = bmutil.ReadVarInt(r); d.err != nil {
+		return d.err
+	}
+	if d.content, d.err = d.readSlice(r, wire.MaxPayloadOfMsgObject,
+		"message"); d.err != nil {
+		return d.err
+	}
+	if d.isMessage {
+		if d.ack, d.err = d.readSlice(r, wire.MaxPayloadOfMsgObject,
+			"ack"); d.err != nil {
+			return d.err
+		}
+	}
+
+	d.signed = len(d.data) - r.Len()
+	if d.sig, d.err = d.readSlice(r, obj.SignatureMaxLength,
+		"signature"); d.err != nil {
+		return d.err
+	}
+	d.err = checkTrailing(r)
+	return d.err
+}
+
+// malformed returns an error wrapping ErrMalformedPayload if err indicates
+// that field was declared to be longer than the data remaining. Otherwise
+// it returns err unchanged.
+func malformed(field string, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %s is truncated", ErrMalformedPayload, field)
+	}
+	return err
+}
+
+// checkTrailing returns an error wrapping ErrMalformedPayload if anything
+// other than zero padding remains in r after the signature.
+func checkTrailing(r io.Reader) error {
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for _, b := range rest {
+		if b != 0 {
+			return fmt.Errorf("%w: %d bytes follow the signature",
+				ErrMalformedPayload, len(rest))
+		}
+	}
+	return nil
+}
+
+// Encoding returns the encoding of the content.
+func (d *Decrypted) Encoding() (uint64, error) {
+	if err := d.locate(); err != nil {
+		return 0, err
+	}
+	return d.encoding, nil
+}
+
+// RawContent returns the content without decoding it.
+func (d *Decrypted) RawContent() ([]byte, error) {
+	if err := d.locate(); err != nil {
+		return nil, err
+	}
+	return d.content, nil
+}
+
+// Content decodes and returns the content.
+func (d *Decrypted) Content() (format.Encoding, error) {
+	if err := d.locate(); err != nil {
+		return nil, err
+	}
+	return format.Read(d.encoding, d.content)
+}
+
+// Ack returns the acknowledgement message. It is always nil for
+// broadcasts.
+func (d *Decrypted) Ack() ([]byte, error) {
+	if err := d.locate(); err != nil {
+		return nil, err
+	}
+	return d.ack, nil
+}
+
+// Signature returns the signature.
+func (d *Decrypted) Signature() ([]byte, error) {
+	if err := d.locate(); err != nil {
+		return nil, err
+	}
+	return d.sig, nil
+}
+
+// Bitmessage decodes the content and returns it along with the sender and
+// destination.
+func (d *Decrypted) Bitmessage() (*Bitmessage, error) {
+	content, err := d.Content()
+	if err != nil {
+		return nil, err
+	}
+	return &Bitmessage{
+		Public:      d.Public,
+		Destination: d.Destination,
+		Content:     content,
+	}, nil
+}
+
+// Verify checks the signature against the public identity of the sender.
+// The signed data is taken straight from the decrypted payload, so the
+// content is never decoded. prefix is what is signed before the payload;
+// see SigningEncoder. It returns ErrMalformedSignature if the signature is
+// malformed and ErrInvalidSignature if it does not match.
+func (d *Decrypted) Verify(prefix SigningEncoder) error {
+	if err := d.locate(); err != nil {
+		return err
+	}
+
+	return verifySignature(d.sig, d.Public.Key().Verification.Btcec(),
+		func(w io.Writer) error {
+			if err := prefix.EncodeForSigning(w); err != nil {
+				return err
+			}
+			_, err := w.Write(d.data[:d.signed])
+			return err
+		})
+}
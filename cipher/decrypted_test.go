@@ -0,0 +1,183 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// TestDecryptedMessage tests decoding and verifying the decrypted payload
+// of a message lazily.
+func TestDecryptedMessage(t *testing.T) {
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	ack := []byte("ack ack ack")
+	message, err := TstSignAndEncryptMessage(t, 0, time.Now().Add(time.Minute*5).
+		Truncate(time.Second), 1, nil, 4, 1, 1, SignKey1, EncKey1, nil,
+		destRipe, 1, []byte("Hey there!"), ack, nil, PrivID1().PrivateKey(),
+		PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessage: %v", err)
+	}
+
+	msg := message.Object()
+	data, err := btcec.Decrypt(PrivID2().PrivateKey().Decryption, msg.Encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	d, err := DecodeDecryptedMessage(data)
+	if err != nil {
+		t.Fatalf("DecodeDecryptedMessage: %v", err)
+	}
+	if got, want := d.Public.Address().String(),
+		PrivID1().Address().String(); got != want {
+		t.Errorf("Public: got %s want %s", got, want)
+	}
+	if !d.Destination.IsEqual(destRipe) {
+		t.Errorf("Destination: got %s want %s", d.Destination, destRipe)
+	}
+	if err = d.Verify(msg.Header()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if got, _ := d.Ack(); !bytes.Equal(got, ack) {
+		t.Errorf("Ack: got %x want %x", got, ack)
+	}
+	bm, err := d.Bitmessage()
+	if err != nil {
+		t.Fatalf("Bitmessage: %v", err)
+	}
+	if got, want := bm.Content.Message(),
+		message.Bitmessage().Content.Message(); !bytes.Equal(got, want) {
+		t.Errorf("Content: got %q want %q", got, want)
+	}
+
+	// The signature must cover the content.
+	raw, _ := d.RawContent()
+	raw[0] ^= 0xff
+	if err = d.Verify(msg.Header()); err != ErrInvalidSignature {
+		t.Errorf("Verify of modified content: got %v want %v", err,
+			ErrInvalidSignature)
+	}
+	raw[0] ^= 0xff
+
+	// A truncated payload is only noticed once the content is needed.
+	d, err = DecodeDecryptedMessage(data[:len(data)-10])
+	if err != nil {
+		t.Fatalf("DecodeDecryptedMessage of truncated data: %v", err)
+	}
+	if _, err = d.Signature(); err == nil {
+		t.Error("Signature of truncated data: got no error")
+	}
+	if err = d.Verify(msg.Header()); err == nil {
+		t.Error("Verify of truncated data: got no error")
+	}
+
+	if _, err = DecodeDecryptedMessage(data[:10]); err == nil {
+		t.Error("DecodeDecryptedMessage of truncated header: got no error")
+	}
+}
+
+// TestDecryptedLengths tests that the lengths declared in a decrypted
+// payload must match the data and that nothing but zero padding may follow
+// the signature.
+func TestDecryptedLengths(t *testing.T) {
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	message, err := TstSignAndEncryptMessage(t, 0, time.Now().Add(time.Minute*5).
+		Truncate(time.Second), 1, nil, 4, 1, 1, SignKey1, EncKey1, nil,
+		destRipe, 1, []byte("Hey there!"), []byte("ack"), nil,
+		PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessage: %v", err)
+	}
+
+	msg := message.Object()
+	data, err := btcec.Decrypt(PrivID2().PrivateKey().Decryption, msg.Encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	tests := []struct {
+		data []byte
+		err  error
+	}{
+		{data, nil},
+		{append(append([]byte{}, data...), 0, 0, 0), nil},
+		{append(append([]byte{}, data...), 0, 1), ErrMalformedPayload},
+		{data[:len(data)-1], ErrMalformedPayload},
+	}
+
+	for i, test := range tests {
+		d, err := DecodeDecryptedMessage(test.data)
+		if err != nil {
+			t.Fatalf("DecodeDecryptedMessage #%d: %v", i, err)
+		}
+		if _, err = d.Signature(); !errors.Is(err, test.err) {
+			t.Errorf("Signature #%d: got %v want %v", i, err, test.err)
+		}
+
+		// The same data must be treated alike when the object is
+		// decrypted all at once.
+		encrypted, err := btcec.Encrypt(
+			PrivID2().PrivateKey().Decryption.PubKey(), test.data)
+		if err != nil {
+			t.Fatalf("Encrypt #%d: %v", i, err)
+		}
+		object := *msg
+		object.Encrypted = encrypted
+		if _, err = NewMessage(&object, PrivID2()); !errors.Is(err, test.err) {
+			t.Errorf("NewMessage #%d: got %v want %v", i, err, test.err)
+		}
+	}
+}
+
+// TestDecryptedBroadcast tests decoding and verifying the decrypted payload
+// of a broadcast lazily.
+func TestDecryptedBroadcast(t *testing.T) {
+	address := PrivID1().Address()
+	broadcast, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, time.Now().Add(time.Minute*5).Truncate(time.Second),
+			1, Tag(address), 4, 1, 1, SignKey1, EncKey1,
+			1000, 1000, 1, []byte("Hey there!"), PrivID1()))
+	if err != nil {
+		t.Fatalf("SignAndEncryptBroadcast: %v", err)
+	}
+
+	object := broadcast.Object()
+	data, err := btcec.Decrypt(V5BroadcastDecryptionKey(address),
+		object.Encrypted())
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	d, err := DecodeDecryptedBroadcast(data)
+	if err != nil {
+		t.Fatalf("DecodeDecryptedBroadcast: %v", err)
+	}
+	if d.Destination != nil {
+		t.Errorf("Destination: got %s want nil", d.Destination)
+	}
+	if err = d.Verify(object); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if ack, _ := d.Ack(); ack != nil {
+		t.Errorf("Ack: got %x want nil", ack)
+	}
+	content, err := d.Content()
+	if err != nil {
+		t.Fatalf("Content: %v", err)
+	}
+	if got, want := content.Message(),
+		broadcast.Bitmessage().Content.Message(); !bytes.Equal(got, want) {
+		t.Errorf("Content: got %q want %q", got, want)
+	}
+}
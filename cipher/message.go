@@ -6,9 +6,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -101,75 +98,34 @@ func (msg *Message) decodeFromDecrypted(r io.Reader) error {
 	msg.bm = &Bitmessage{}
 	err := msg.bm.decodeMessage(r)
 	if err != nil {
-		return err
+		return malformed("payload", err)
 	}
 
-	var ackLength uint64
-	if ackLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if ackLength > wire.MaxPayloadOfMsgObject {
-		str := fmt.Sprintf("ack length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			ackLength, wire.MaxPayloadOfMsgObject)
-		return wire.NewMessageError("decodeFromDecrypted", str)
-	}
-	msg.ack = make([]byte, ackLength)
-	_, err = io.ReadFull(r, msg.ack)
+	msg.ack, err = wire.ReadBoundedBytes(r, wire.MaxPayloadOfMsgObject,
+		"decodeFromDecrypted", "ack")
 	if err != nil {
-		return err
+		return malformed("ack", err)
 	}
 
-	var sigLength uint64
-	if sigLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if sigLength > obj.SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, obj.SignatureMaxLength)
-		return wire.NewMessageError("decodeFromDecrypted", str)
+	msg.sig, err = wire.ReadBoundedBytes(r, obj.SignatureMaxLength,
+		"decodeFromDecrypted", "signature")
+	if err != nil {
+		return malformed("signature", err)
 	}
-	msg.sig = make([]byte, sigLength)
-	_, err = io.ReadFull(r, msg.sig)
-	return err
+
+	return checkTrailing(r)
 }
 
 func (msg Message) verify(private *identity.PrivateID) error {
 	// Check if embedded destination ripe corresponds to private identity.
-	if subtle.ConstantTimeCompare(private.Address().RipeHash()[:],
-		msg.bm.Destination.Bytes()) != 1 {
+	if !private.Address().RipeHash().ConstantTimeEqual(msg.bm.Destination) {
 		return fmt.Errorf("Decryption succeeded but ripes don't match. Got %s"+
 			" expected %s", msg.bm.Destination,
 			hex.EncodeToString(private.Address().RipeHash()[:]))
 	}
 
-	// Start signature verification
-	var b bytes.Buffer
-	err := msg.encodeForSigning(&b)
-	if err != nil {
-		return err
-	}
-
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes())
-
-	// Verify
-	pvk := msg.bm.Public.Key().Verification.Btcec()
-
-	sig, err := btcec.ParseSignature(msg.sig, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	if !sig.Verify(hash[:], pvk) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], pvk) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-
-	return nil
+	return verifySignature(msg.sig, msg.bm.Public.Key().Verification.Btcec(),
+		msg.encodeForSigning)
 }
 
 // NewMessage attempts to decrypt the data in a message object and turn it
@@ -180,7 +136,7 @@ func NewMessage(msg *obj.Message, private *identity.PrivateID) (*Message, error)
 	if err == btcec.ErrInvalidMAC { // decryption failed due to invalid key
 		return nil, ErrInvalidIdentity
 	} else if err != nil { // other reasons
-		return nil, err
+		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
 
 	message := Message{
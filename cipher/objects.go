@@ -2,7 +2,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"time"
@@ -23,6 +22,16 @@ var (
 	// message is malformed or fails to verify (because of invalid checksum).
 	ErrInvalidSignature = errors.New("invalid signature/verification failed")
 
+	// ErrMalformedSignature is returned when the signature embedded in the
+	// message is not a DER encoded ECDSA signature, so that it could not
+	// have been verified by any key.
+	ErrMalformedSignature = errors.New("malformed signature")
+
+	// ErrMalformedPayload is returned when a length declared in a decrypted
+	// payload runs past the end of the data, or when anything other than
+	// zero padding follows the signature.
+	ErrMalformedPayload = errors.New("malformed decrypted payload")
+
 	// ErrInvalidIdentity is returned when the provided address/identity is
 	// unable to decrypt the given message.
 	ErrInvalidIdentity = errors.New("invalid supplied identity/decryption failed")
@@ -30,20 +39,32 @@ var (
 	// ErrInvalidObjectType is returned when the given object is not of
 	// the expected type.
 	ErrInvalidObjectType = errors.New("invalid object type")
+
+	// ErrInvalidTTL is returned by GeneratePubKey when the time to live is
+	// not positive or is greater than wire.MaxObjectTTL.
+	ErrInvalidTTL = errors.New("invalid time to live")
 )
 
 // GeneratePubKey generates a PubKey from the specified private
 // identity. It also signs and encrypts it (if necessary) yielding an object
 // that only needs proof-of-work to be done on it.
 func GeneratePubKey(privID *identity.PrivateID, expiry time.Duration) (PubKeyObject, error) {
+	if expiry <= 0 || expiry > wire.MaxObjectTTL {
+		return nil, ErrInvalidTTL
+	}
+	return CreatePubKey(time.Now().Add(expiry), privID)
+}
 
+// CreatePubKey is like GeneratePubKey, but takes the time at which the
+// pubkey expires rather than its time to live.
+func CreatePubKey(expiration time.Time, privID *identity.PrivateID) (PubKeyObject, error) {
 	switch privID.Address().Version() {
 	case obj.SimplePubKeyVersion:
-		return createSimplePubKey(time.Now().Add(expiry), privID), nil
+		return createSimplePubKey(expiration, privID), nil
 	case obj.ExtendedPubKeyVersion:
-		return createExtendedPubKey(time.Now().Add(expiry), privID)
+		return createExtendedPubKey(expiration, privID)
 	case obj.EncryptedPubKeyVersion:
-		return createDecryptedPubKey(time.Now().Add(expiry), privID)
+		return createDecryptedPubKey(expiration, privID)
 	default:
 		return nil, ErrUnsupportedOp
 	}
@@ -51,7 +72,8 @@ func GeneratePubKey(privID *identity.PrivateID, expiry time.Duration) (PubKeyObj
 
 // TryDecryptAndVerifyPubKey tries to decrypt a wire.PubKeyObject of the address.
 // If it fails, it returns ErrInvalidIdentity. If decryption succeeds, it
-// verifies the embedded signature. If signature verification fails, it returns
+// verifies the embedded signature. If the signature is malformed, it returns
+// ErrMalformedSignature, and if verification fails, it returns
 // ErrInvalidSignature. Else, it returns nil.
 //
 // All necessary fields of the provided wire.PubKeyObject are populated.
@@ -102,7 +124,8 @@ func TryDecryptAndVerifyPubKey(msg obj.Object, address bmutil.Address) (PubKeyOb
 // the Signature and Encrypted fields using the provided private identity.
 //
 // The private identity supplied should be of the sender. There are no checks
-// against supplying invalid private identity.
+// against supplying invalid private identity. It is signed with the Signer of
+// the identity, which may be shared between several parties.
 func SignAndEncryptBroadcast(expiration time.Time,
 	msg *Bitmessage, tag *hash.Sha, privID *identity.PrivateID) (*Broadcast, error) {
 
@@ -126,8 +149,9 @@ func SignAndEncryptBroadcast(expiration time.Time,
 
 // TryDecryptAndVerifyBroadcast tries to decrypt a wire.BroadcastObject of the
 // public identity. If it fails, it returns ErrInvalidIdentity. If decryption
-// succeeds, it verifies the embedded signature. If signature verification
-// fails, it returns ErrInvalidSignature. Else, it returns nil.
+// succeeds, it verifies the embedded signature. If the signature is malformed,
+// it returns ErrMalformedSignature, and if verification fails, it returns
+// ErrInvalidSignature. Else, it returns nil.
 //
 // All necessary fields of the provided wire.BroadcastObject are populated.
 func TryDecryptAndVerifyBroadcast(msg obj.Broadcast, address bmutil.Address) (*Broadcast, error) {
@@ -149,7 +173,8 @@ func TryDecryptAndVerifyBroadcast(msg obj.Broadcast, address bmutil.Address) (*B
 //
 // The private identity supplied should be of the sender. The public identity
 // should be that of the recipient. There are no checks against supplying
-// invalid private or public identities.
+// invalid private or public identities. It is signed with the Signer of the
+// private identity, which may be shared between several parties.
 func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 	bm *Bitmessage, ack []byte, privID *identity.PrivateKey,
 	pubID *identity.PublicKey) (*Message, error) {
@@ -165,26 +190,22 @@ func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 		ack: ack,
 	}
 
-	// Start signing
-	var b bytes.Buffer
-	err := message.encodeForSigning(&b)
+	// Hash
+	hash, err := signingHash(message.encodeForSigning)
 	if err != nil {
 		return nil, err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	b.Reset()
-
 	// Sign
-	sig, err := privID.Signing.Sign(hash[:])
+	message.sig, err = privID.Signer().Sign(hash)
 	if err != nil {
-		return nil, fmt.Errorf("signing failed: %v", err)
+		return nil, fmt.Errorf("signing failed: %w", err)
 	}
-	message.sig = sig.Serialize()
 
 	// Start encryption
-	err = message.encodeForEncryption(&b)
+	b := wire.GetBuffer()
+	defer wire.PutBuffer(b)
+	err = message.encodeForEncryption(b)
 	if err != nil {
 		return nil, err
 	}
@@ -192,7 +213,7 @@ func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 	// Encrypt
 	encrypted, err := btcec.Encrypt(pubID.Encryption.Btcec(), b.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("encryption failed: %v", err)
+		return nil, fmt.Errorf("encryption failed: %w", err)
 	}
 
 	message.msg = obj.NewMessage(0, expiration, streamNumber, encrypted)
@@ -202,8 +223,9 @@ func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 
 // TryDecryptAndVerifyMessage tries to decrypt an obj.Message using the private
 // identity. If it fails, it returns ErrInvalidIdentity. If decryption succeeds,
-// it verifies the embedded signature. If signature verification fails, it
-// returns ErrInvalidSignature. Else, it returns nil.
+// it verifies the embedded signature. If the signature is malformed, it
+// returns ErrMalformedSignature, and if verification fails, it returns
+// ErrInvalidSignature. Else, it returns nil.
 //
 // All necessary fields of the provided obj.Message are populated.
 func TryDecryptAndVerifyMessage(msg *obj.Message, privID *identity.PrivateID) (*Message, error) {
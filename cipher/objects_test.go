@@ -3,6 +3,7 @@ package cipher_test
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -170,7 +171,7 @@ func TestPubKeys(t *testing.T) {
 	invDec, _ := btcec.Encrypt(randId.PubKey(), []byte{0x00})
 	undecData, _ := btcec.Encrypt(V5BroadcastDecryptionKey(PrivID1().Address()).PubKey(),
 		[]byte{0x00})
-	validPubKey, _ := wire.NewPubKey(randId.PubKey().SerializeUncompressed()[1:])
+	validPubKey := wire.NewPubKeyFromBtcec(randId.PubKey())
 
 	forwardingData := TstGenerateForwardingData(validPubKey)
 	invalidSig := TstGenerateInvalidSig()
@@ -238,6 +239,15 @@ func TestPubKeys(t *testing.T) {
 	}
 }
 
+func TestGeneratePubKeyInvalidTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Hour,
+		wire.MaxObjectTTL + time.Second} {
+		if _, err := GeneratePubKey(PrivID1(), ttl); err != ErrInvalidTTL {
+			t.Errorf("ttl %s: got %v want %v", ttl, err, ErrInvalidTTL)
+		}
+	}
+}
+
 // TestBroadcasts tests signing and encrypting broadcasts and
 // decrypting and verifying broadcasts.
 func TestBroadcasts(t *testing.T) {
@@ -330,7 +340,7 @@ func TestBroadcasts(t *testing.T) {
 	randId, _ := btcec.NewPrivateKey(btcec.S256())
 	undecData, _ := btcec.Encrypt(V5BroadcastDecryptionKey(PrivID1().Address()).PubKey(),
 		[]byte{0x00, 0x00})
-	validPubkey, _ := wire.NewPubKey(randId.PubKey().SerializeUncompressed()[1:])
+	validPubkey := wire.NewPubKeyFromBtcec(randId.PubKey())
 
 	invSigningKey, invEncKey, forwardingData, invalidSig, mismatchSig := TstGenerateBroadcastErrorData(t, validPubkey)
 
@@ -424,7 +434,7 @@ func TestMessages(t *testing.T) {
 	invPrivID, _ := btcec.Encrypt(randId.PubKey(), []byte{0x00, 0x00})
 	undecData, _ := btcec.Encrypt(PrivID1().PrivateKey().Decryption.PubKey(),
 		[]byte{0x00, 0x00})
-	validPubkey, _ := wire.NewPubKey(randId.PubKey().SerializeUncompressed()[1:])
+	validPubkey := wire.NewPubKeyFromBtcec(randId.PubKey())
 
 	invDest, invSigningKey, invalidSig, mismatchSig := TstGenerateMessageErrorData(t, validPubkey)
 
@@ -479,3 +489,68 @@ func TestMessages(t *testing.T) {
 		}
 	}
 }
+
+// remoteSigner signs with a key which is not in the PrivateKey, as the
+// Signer of a shared identity does.
+type remoteSigner struct {
+	key    *btcec.PrivateKey
+	err    error
+	signed int
+}
+
+func (s *remoteSigner) Verification() *identity.PubKey {
+	return (*identity.PubKey)(s.key.PubKey())
+}
+
+func (s *remoteSigner) Sign(hash []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.signed++
+	sig, err := s.key.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+// TestSharedIdentity tests signing with an identity whose signing key is
+// not held in its PrivateKey.
+func TestSharedIdentity(t *testing.T) {
+	signer := &remoteSigner{key: PrivKey1().Signing}
+	private := identity.NewPrivateID(identity.NewPrivateAddress(
+		&identity.PrivateKey{
+			Decryption: PrivKey1().Decryption,
+			Shared:     signer,
+		}, 4, 1), identity.BehaviorAck, &pow.Default)
+	address := private.Address()
+	if address.String() != PrivID1().Address().String() {
+		t.Fatalf("Address: got %s want %s", address, PrivID1().Address())
+	}
+
+	params := func() (time.Time, *Bitmessage, *hash.Sha, *identity.PrivateID) {
+		return TstBroadcastEncryptParams(t,
+			time.Now().Add(time.Minute*5).Truncate(time.Second),
+			1, Tag(address), 4, 1, 1, SignKey1, EncKey1,
+			1000, 1000, 1, []byte("Hey there!"), private)
+	}
+
+	broadcast, err := SignAndEncryptBroadcast(params())
+	if err != nil {
+		t.Fatalf("SignAndEncryptBroadcast: %v", err)
+	}
+	if signer.signed != 1 {
+		t.Errorf("signer signed %d times want 1", signer.signed)
+	}
+	if _, err = TryDecryptAndVerifyBroadcast(broadcast.Object(),
+		address); err != nil {
+		t.Errorf("TryDecryptAndVerifyBroadcast: %v", err)
+	}
+
+	signer.err = identity.ErrNotEnoughShares
+	if _, err = SignAndEncryptBroadcast(params()); !errors.Is(err,
+		identity.ErrNotEnoughShares) {
+		t.Errorf("SignAndEncryptBroadcast: got %v want %v", err,
+			identity.ErrNotEnoughShares)
+	}
+}
@@ -7,9 +7,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -75,23 +72,17 @@ func signExtendedPubKey(ep *obj.ExtendedPubKey, private *identity.PrivateKey) er
 		return errors.New("PrivateKey is nil.")
 	}
 
-	// Start signing
-	var b bytes.Buffer
-	err := ep.EncodeForSigning(&b)
+	// Hash
+	hash, err := signingHash(ep.EncodeForSigning)
 	if err != nil {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	b.Reset()
-
 	// Sign
-	sig, err := private.Signing.Sign(hash[:])
+	ep.Signature, err = private.Signer().Sign(hash)
 	if err != nil {
-		return fmt.Errorf("signing failed: %v", err)
+		return fmt.Errorf("signing failed: %w", err)
 	}
-	ep.Signature = sig.Serialize()
 	return nil
 }
 
@@ -105,30 +96,7 @@ func verifyExtendedPubKey(ep *obj.ExtendedPubKey) error {
 		return err
 	}
 
-	// Start signature verification
-	var b bytes.Buffer
-	err = ep.EncodeForSigning(&b)
-	if err != nil {
-		return err
-	}
-
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(ep.Signature, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	if !sig.Verify(hash[:], signKey) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], signKey) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-
-	return nil
+	return verifySignature(ep.Signature, signKey, ep.EncodeForSigning)
 }
 
 func createExtendedPubKey(expires time.Time, privID *identity.PrivateID) (*obj.ExtendedPubKey, error) {
@@ -178,7 +146,7 @@ func (dp *decryptedPubKey) Data() *obj.PubKeyData {
 }
 
 func (dp *decryptedPubKey) Tag() *hash.Sha {
-	return dp.object.Tag
+	return &dp.object.Tag
 }
 
 func (dp *decryptedPubKey) String() string {
@@ -200,15 +168,15 @@ func (dp *decryptedPubKey) decodeFromDecrypted(r io.Reader) error {
 	dp.data = &obj.PubKeyData{}
 	err := dp.data.Decode(r)
 	if err != nil {
-		return err
+		return malformed("pubkey data", err)
 	}
 
 	dp.signature, err = obj.DecodePubKeySignature(r)
 	if err != nil {
-		return err
+		return malformed("signature", err)
 	}
 
-	return nil
+	return checkTrailing(r)
 }
 
 func (dp *decryptedPubKey) EncodeForSigning(w io.Writer) error {
@@ -217,7 +185,7 @@ func (dp *decryptedPubKey) EncodeForSigning(w io.Writer) error {
 		return err
 	}
 
-	err = wire.WriteElement(w, dp.object.Tag)
+	err = wire.WriteElement(w, &dp.object.Tag)
 	if err != nil {
 		return err
 	}
@@ -226,25 +194,21 @@ func (dp *decryptedPubKey) EncodeForSigning(w io.Writer) error {
 }
 
 func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
-	// Start signing
-	var b bytes.Buffer
-	err := dp.EncodeForSigning(&b)
+	// Hash
+	hash, err := signingHash(dp.EncodeForSigning)
 	if err != nil {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	b.Reset()
-
 	// Sign
-	sig, err := private.PrivateKey().Signing.Sign(hash[:])
+	dp.signature, err = private.PrivateKey().Signer().Sign(hash)
 	if err != nil {
-		return fmt.Errorf("signing failed: %v", err)
+		return fmt.Errorf("signing failed: %w", err)
 	}
-	dp.signature = sig.Serialize()
 
-	err = dp.EncodeForEncryption(&b)
+	b := wire.GetBuffer()
+	defer wire.PutBuffer(b)
+	err = dp.EncodeForEncryption(b)
 	if err != nil {
 		return err
 	}
@@ -253,7 +217,7 @@ func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
 	dp.object.Encrypted, err = btcec.Encrypt(
 		V5BroadcastDecryptionKey(private.Address()).PubKey(), b.Bytes())
 	if err != nil {
-		return fmt.Errorf("encryption failed: %v", err)
+		return fmt.Errorf("encryption failed: %w", err)
 	}
 
 	return nil
@@ -262,7 +226,7 @@ func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
 func (dp *decryptedPubKey) decryptAndVerify(address Address) error {
 	// Try decryption.
 	// Check tag, save decryption cost.
-	if subtle.ConstantTimeCompare(dp.object.Tag[:], Tag(address)[:]) != 1 {
+	if !dp.object.Tag.ConstantTimeEqual(Tag(address)) {
 		return ErrInvalidIdentity
 	}
 
@@ -270,7 +234,7 @@ func (dp *decryptedPubKey) decryptAndVerify(address Address) error {
 	if err == btcec.ErrInvalidMAC { // decryption failed due to invalid key
 		return ErrInvalidIdentity
 	} else if err != nil { // other reasons
-		return err
+		return fmt.Errorf("decryption failed: %w", err)
 	}
 
 	err = dp.decodeFromDecrypted(bytes.NewReader(dec))
@@ -301,31 +265,8 @@ func (dp *decryptedPubKey) decryptAndVerify(address Address) error {
 			"forwarding attack.", dencAddr, genAddr)
 	}
 
-	// Start signature verification
-	var b bytes.Buffer
-	err = dp.EncodeForSigning(&b)
-	if err != nil {
-		return err
-	}
-
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(dp.signature, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	k := public.Verification.Btcec()
-	if !sig.Verify(hash[:], k) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], k) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-
-	return nil
+	return verifySignature(dp.signature, public.Verification.Btcec(),
+		dp.EncodeForSigning)
 }
 
 func createDecryptedPubKey(expires time.Time, privID *identity.PrivateID) (*decryptedPubKey, error) {
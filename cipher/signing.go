@@ -0,0 +1,85 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// signingHash returns the SHA-256 hash of whatever encode writes, which is
+// what gets signed. encode writes straight into the hash rather than into
+// a buffer.
+func signingHash(encode func(io.Writer) error) ([]byte, error) {
+	h := sha256.New()
+	if err := encode(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// CheckSignature checks that sig has the structure of a DER encoded ECDSA
+// signature, which is a sequence of two positive integers with nothing
+// after it, and that both integers are in the range allowed for secp256k1.
+// It returns the parsed signature, or ErrMalformedSignature if it is not
+// well formed. Leading zeros in the integers are tolerated, since some old
+// clients wrote them.
+func CheckSignature(sig []byte) (*btcec.Signature, error) {
+	// A sequence of two integers, each of at least one byte.
+	if len(sig) < 8 || sig[0] != 0x30 || int(sig[1]) != len(sig)-2 {
+		return nil, ErrMalformedSignature
+	}
+
+	rest := sig[2:]
+	for i := 0; i < 2; i++ {
+		if len(rest) < 3 || rest[0] != 0x02 {
+			return nil, ErrMalformedSignature
+		}
+		n := int(rest[1])
+		if n == 0 || len(rest) < 2+n || rest[2]&0x80 != 0 {
+			return nil, ErrMalformedSignature
+		}
+		rest = rest[2+n:]
+	}
+	if len(rest) != 0 {
+		return nil, ErrMalformedSignature
+	}
+
+	s, err := btcec.ParseSignature(sig, btcec.S256())
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+	return s, nil
+}
+
+// verifySignature checks that sig is a signature by key of whatever encode
+// writes. For backwards compatibility, a signature of the SHA-1 hash rather
+// than the SHA-256 hash is also accepted. encode writes to both hashes at
+// once. It returns ErrMalformedSignature if the signature is malformed and
+// ErrInvalidSignature if it does not match.
+func verifySignature(sig []byte, key *btcec.PublicKey,
+	encode func(io.Writer) error) error {
+
+	s, err := CheckSignature(sig)
+	if err != nil {
+		return err
+	}
+
+	sha256Hash := sha256.New()
+	sha1Hash := sha1.New()
+	if err := encode(io.MultiWriter(sha256Hash, sha1Hash)); err != nil {
+		return err
+	}
+
+	if !s.Verify(sha256Hash.Sum(nil), key) { // Try SHA256 first
+		if !s.Verify(sha1Hash.Sum(nil), key) { // then SHA1
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestCheckSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  []byte
+		err  error
+	}{
+		{"valid", []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01}, nil},
+		{"padded", []byte{0x30, 0x08, 0x02, 0x02, 0x00, 0x01, 0x02, 0x02, 0x00, 0x01}, nil},
+		{"empty", nil, ErrMalformedSignature},
+		{"not a sequence", []byte{0x31, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+		{"wrong length", []byte{0x30, 0x07, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+		{"trailing byte", []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01, 0x00},
+			ErrMalformedSignature},
+		{"not an integer", []byte{0x30, 0x06, 0x03, 0x01, 0x01, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+		{"empty integer", []byte{0x30, 0x06, 0x02, 0x00, 0x02, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+		{"negative", []byte{0x30, 0x06, 0x02, 0x01, 0x81, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+		{"integer too long", []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x02, 0x01},
+			ErrMalformedSignature},
+		{"zero", []byte{0x30, 0x06, 0x02, 0x01, 0x00, 0x02, 0x01, 0x01},
+			ErrMalformedSignature},
+	}
+
+	for _, test := range tests {
+		if _, err := CheckSignature(test.sig); err != test.err {
+			t.Errorf("%s: got %v want %v", test.name, err, test.err)
+		}
+	}
+}
+
+// TestVerifyErrors tests that malformed signatures and keys are told apart
+// from signatures which do not match.
+func TestVerifyErrors(t *testing.T) {
+	v3ID := ReplaceVersion(PrivID1(), 3)
+	pk, err := GeneratePubKey(v3ID, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := pk.Object().(*obj.ExtendedPubKey)
+	sig := ep.Signature
+
+	modified := func(sig []byte, verification *wire.PubKey) *obj.ExtendedPubKey {
+		data := *ep.Data()
+		if verification != nil {
+			data.Verification = verification
+		}
+		return obj.NewExtendedPubKey(0, ep.Header().Expiration(),
+			ep.Header().StreamNumber, &data, sig)
+	}
+
+	bad := append([]byte{}, sig...)
+	bad[len(bad)-1] ^= 0x01
+	var offCurve wire.PubKey
+	offCurve[0] = 1
+
+	tests := []struct {
+		name string
+		pk   *obj.ExtendedPubKey
+		err  error
+	}{
+		{"valid", modified(sig, nil), nil},
+		{"bad signature", modified(bad, nil), ErrInvalidSignature},
+		{"malformed signature", modified(append(append([]byte{}, sig...), 0), nil),
+			ErrMalformedSignature},
+		{"malformed key", modified(sig, &offCurve), wire.ErrInvalidPubKey},
+	}
+
+	for _, test := range tests {
+		if _, err := TryDecryptAndVerifyPubKey(test.pk, v3ID.Address()); err != test.err {
+			t.Errorf("%s: got %v want %v", test.name, err, test.err)
+		}
+	}
+}
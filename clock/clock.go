@@ -0,0 +1,96 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. A Clock must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+}
+
+// Func is a function which is a Clock.
+type Func func() time.Time
+
+// Now calls f. This is part of the Clock interface.
+func (f Func) Now() time.Time {
+	return f()
+}
+
+// system is the Clock of the operating system.
+type system struct{}
+
+// Now returns time.Now(). This is part of the Clock interface.
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System is the Clock of the operating system.
+var System Clock = system{}
+
+// Or returns c, or System if c is nil, so that a component can tell the time
+// without checking whether it was given a Clock.
+func Or(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+	return c
+}
+
+// offset is a Clock which is ahead of another by a fixed duration.
+type offset struct {
+	clock Clock
+	d     time.Duration
+}
+
+// Now returns the time of the underlying clock plus the offset. This is
+// part of the Clock interface.
+func (o *offset) Now() time.Time {
+	return o.clock.Now().Add(o.d)
+}
+
+// Offset returns a Clock which is ahead of c by d, or behind it if d is
+// negative. If c is nil, System is used.
+func Offset(c Clock, d time.Duration) Clock {
+	return &offset{clock: Or(c), d: d}
+}
+
+// Manual is a Clock which only moves when it is told to. It is meant for
+// tests which have to simulate the passage of time.
+type Manual struct {
+	mtx sync.Mutex
+	now time.Time
+}
+
+// NewManual returns a Manual clock which is set to t.
+func NewManual(t time.Time) *Manual {
+	return &Manual{now: t}
+}
+
+// Now returns the time to which the clock is set. This is part of the Clock
+// interface.
+func (m *Manual) Now() time.Time {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.now
+}
+
+// Set sets the clock to t.
+func (m *Manual) Set(t time.Time) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.now = t
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (m *Manual) Advance(d time.Duration) time.Time {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.now = m.now.Add(d)
+	return m.now
+}
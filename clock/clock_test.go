@@ -0,0 +1,48 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+)
+
+func TestManual(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	c := clock.NewManual(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Now: got %v want %v", c.Now(), start)
+	}
+	if now := c.Advance(time.Hour); !now.Equal(start.Add(time.Hour)) ||
+		!c.Now().Equal(now) {
+		t.Errorf("Advance: got %v, %v want %v", now, c.Now(),
+			start.Add(time.Hour))
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Set: got %v want %v", c.Now(), start)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	c := clock.Offset(clock.NewManual(start), -time.Minute)
+	if want := start.Add(-time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Now: got %v want %v", c.Now(), want)
+	}
+
+	f := clock.Func(func() time.Time { return start })
+	if !clock.Or(f).Now().Equal(start) {
+		t.Errorf("Or: got %v want %v", clock.Or(f).Now(), start)
+	}
+	if clock.Or(nil) != clock.System {
+		t.Errorf("Or: got %v want System", clock.Or(nil))
+	}
+	if d := time.Since(clock.Offset(nil, time.Hour).Now()); d > -59*time.Minute {
+		t.Errorf("Offset of System is %v behind", d)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package clock lets applications decide what time it is for the parts of
+bmutil which depend on it, such as building objects, checking whether they
+have expired, sending unacknowledged messages again and removing expired
+objects from a store.
+
+Every component which tells the time takes a Clock in the Clock field of
+its configuration, such as store.GCConfig, store.CacheConfig,
+relay.ValidatorConfig, message.KeyringConfig, outbox.Config and
+stats.CollectorConfig. The field is nil by default, in which case System is
+used. Components built on another, such as message.Resolver on a Keyring
+or outbox.Resender on an Outbox, use the clock of the one they are built
+on. A test can give them a Manual clock and move it forward to see what
+happens when an object expires without waiting for it to do so:
+
+	c := clock.NewManual(time.Now())
+	gc := store.NewGC(objects, &store.GCConfig{Clock: c})
+	c.Advance(store.ExpirationGracePeriod + time.Hour)
+	n, err := gc.Collect()
+
+Timeouts which are measured with timers, such as those of a peer's
+handshake and of rate limits, and the timestamps of log entries, follow the
+time of the system rather than a Clock.
+
+A device whose own clock is known to be wrong, for example one which
+learns the time from its peers, can use Offset to correct it.
+*/
+package clock
@@ -0,0 +1,33 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmaddr generates Bitmessage addresses, prints their components and converts
+identities between the formats in which they are stored.
+
+Usage:
+
+	bmaddr generate [flags]
+	bmaddr show [flags] [address ...]
+	bmaddr convert -from format -to format
+
+Generate creates new identities, either from random keys or, if a passphrase
+is given, deterministically from the passphrase as PyBitmessage does, and
+writes them in the chosen format.
+
+Show prints the version, stream, ripe and tag of each address given as an
+argument. With no arguments, it reads identities from standard input and
+also prints their public keys.
+
+Convert reads identities from standard input in one format and writes them
+to standard output in another. Each identity is checked against its keys.
+
+The formats are
+
+	wif       one identity per line: the address, then the private signing
+	          key and the private encryption key in wallet import format
+	keys.dat  the INI file in which PyBitmessage keeps its identities
+	json      an array of objects with the fields of keys.dat
+*/
+package main
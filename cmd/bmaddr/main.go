@@ -0,0 +1,229 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cmd/internal/keyfile"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// errUsage is returned by a command whose arguments are wrong, once the
+// usage has been printed.
+var errUsage = errors.New("invalid arguments")
+
+// command is a subcommand of bmaddr.
+type command struct {
+	summary string
+	run     func(args []string, in io.Reader, out, errOut io.Writer) error
+}
+
+// commands are the subcommands of bmaddr, by name.
+var commands = map[string]*command{
+	"generate": {"generate new addresses", generate},
+	"show":     {"print the components of addresses", show},
+	"convert":  {"convert identities between formats", convert},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: bmaddr command [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range []string{"generate", "show", "convert"} {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(w, "\nFormats: %s\n", keyfile.Names())
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+	switch err {
+	case nil:
+	case errUsage:
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "bmaddr:", err)
+		os.Exit(1)
+	}
+}
+
+// newFlagSet returns a FlagSet for a command which writes its errors to
+// errOut.
+func newFlagSet(name string, errOut io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("bmaddr "+name, flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	return fs
+}
+
+// parse parses the arguments of a command, returning errUsage if they are
+// wrong.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// generate creates new identities and writes them out.
+func generate(args []string, in io.Reader, out, errOut io.Writer) error {
+	fs := newFlagSet("generate", errOut)
+	passphrase := fs.String("passphrase", "",
+		"generate addresses deterministically from this passphrase")
+	n := fs.Int("n", 1, "number of addresses to generate")
+	zeros := fs.Int("zeros", 1,
+		"initial zero bytes in the ripe, which shortens the address")
+	version := fs.Uint64("version", 4, "address version")
+	stream := fs.Uint64("stream", 1, "stream number")
+	label := fs.String("label", "", "label of the addresses")
+	name := fs.String("format", "wif", "output format")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *n < 1 || *zeros < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	f, err := keyfile.Lookup(*name)
+	if err != nil {
+		return err
+	}
+
+	var keys []*identity.PrivateKey
+	if *passphrase != "" {
+		keys, err = identity.NewDeterministic(*passphrase, uint64(*zeros), *n)
+		if err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < *n; i++ {
+			key, err := identity.NewRandom(*zeros)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	records := make([]*keyfile.Record, len(keys))
+	for i, key := range keys {
+		id := identity.NewPrivateAddress(key, *version, *stream)
+		if id.Address() == nil {
+			return fmt.Errorf("cannot make a version %d address in stream %d",
+				*version, *stream)
+		}
+		records[i] = keyfile.NewRecord(id, *label)
+	}
+	return f.Write(out, records)
+}
+
+// show prints the components of each address given as an argument, or of
+// each identity read from in if there are none.
+func show(args []string, in io.Reader, out, errOut io.Writer) error {
+	fs := newFlagSet("show", errOut)
+	name := fs.String("format", "wif",
+		"input format, if no addresses are given")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		for i, s := range fs.Args() {
+			address, err := bmutil.DecodeAddress(s)
+			if err != nil {
+				return fmt.Errorf("%s: %v", s, err)
+			}
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			showAddress(out, address)
+		}
+		return nil
+	}
+
+	f, err := keyfile.Lookup(*name)
+	if err != nil {
+		return err
+	}
+	records, err := f.Read(in)
+	if err != nil {
+		return err
+	}
+	for i, r := range records {
+		id, err := r.Identity()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if r.Label != "" {
+			fmt.Fprintf(out, "label:          %s\n", r.Label)
+		}
+		showAddress(out, id.Address())
+		key := id.PublicKey()
+		fmt.Fprintf(out, "signing key:    %s\n", key.Verification)
+		fmt.Fprintf(out, "encryption key: %s\n", key.Encryption)
+	}
+	return nil
+}
+
+// showAddress prints the components of an address.
+func showAddress(w io.Writer, address bmutil.Address) {
+	fmt.Fprintf(w, "address:        %s\n", address)
+	fmt.Fprintf(w, "version:        %d\n", address.Version())
+	fmt.Fprintf(w, "stream:         %d\n", address.Stream())
+	fmt.Fprintf(w, "ripe:           %s\n", address.RipeHash())
+	fmt.Fprintf(w, "tag:            %s\n", bmutil.Tag(address))
+}
+
+// convert reads identities in one format and writes them in another.
+func convert(args []string, in io.Reader, out, errOut io.Writer) error {
+	fs := newFlagSet("convert", errOut)
+	from := fs.String("from", "", "input format")
+	to := fs.String("to", "", "output format")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *from == "" || *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	src, err := keyfile.Lookup(*from)
+	if err != nil {
+		return err
+	}
+	dst, err := keyfile.Lookup(*to)
+	if err != nil {
+		return err
+	}
+
+	records, err := src.Read(in)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if _, err := r.Identity(); err != nil {
+			return err
+		}
+	}
+	return dst.Write(out, records)
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// run runs a command and returns what it wrote.
+func run(t *testing.T, name string, in string, args ...string) string {
+	var out bytes.Buffer
+	err := commands[name].run(args, strings.NewReader(in), &out, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("%s %v: %v", name, args, err)
+	}
+	return out.String()
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	a := run(t, "generate", "", "-passphrase", "hello", "-n", "2")
+	b := run(t, "generate", "", "-passphrase", "hello", "-n", "2")
+	if a != b {
+		t.Errorf("deterministic addresses differ:\n%s\n%s", a, b)
+	}
+	if lines := strings.Split(strings.TrimSpace(a), "\n"); len(lines) != 2 {
+		t.Errorf("expected 2 addresses, got %d", len(lines))
+	}
+
+	c := run(t, "generate", "", "-passphrase", "goodbye")
+	if strings.Fields(a)[0] == strings.Fields(c)[0] {
+		t.Error("different passphrases gave the same address")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	wif := run(t, "generate", "", "-n", "2")
+
+	for _, name := range []string{"keys.dat", "json"} {
+		converted := run(t, "convert", wif, "-from", "wif", "-to", name)
+		back := run(t, "convert", converted, "-from", name, "-to", "wif")
+		if back != wif {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, wif, back)
+		}
+	}
+}
+
+func TestConvertMismatch(t *testing.T) {
+	a := strings.Fields(run(t, "generate", ""))
+	b := strings.Fields(run(t, "generate", ""))
+
+	// The keys of one identity with the address of another.
+	in := a[0] + " " + b[1] + " " + b[2] + "\n"
+	err := convert([]string{"-from", "wif", "-to", "json"},
+		strings.NewReader(in), ioutil.Discard, ioutil.Discard)
+	if err == nil {
+		t.Error("expected an error for keys which do not match the address")
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	err := generate([]string{"-stream", "2"}, nil, ioutil.Discard,
+		ioutil.Discard)
+	if err == nil {
+		t.Error("expected an error for an unsupported stream")
+	}
+}
+
+func TestShow(t *testing.T) {
+	wif := run(t, "generate", "")
+	address := strings.Fields(wif)[0]
+
+	out := run(t, "show", "", address)
+	if !strings.Contains(out, "stream:         1\n") ||
+		!strings.Contains(out, "version:        4\n") {
+		t.Errorf("wrong components:\n%s", out)
+	}
+
+	out = run(t, "show", wif)
+	if !strings.Contains(out, address) ||
+		!strings.Contains(out, "encryption key:") {
+		t.Errorf("wrong components:\n%s", out)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// field is one decoded field of a message. Value is a string, a number or
+// a []string.
+type field struct {
+	Name  string
+	Value interface{}
+}
+
+// frame is a decoded message.
+type frame struct {
+	// Offset is the position of the frame in the input.
+	Offset int
+
+	// Length is the number of bytes in the frame, including its header.
+	Length int
+
+	Command string
+	Fields  []field
+}
+
+// add appends a field to the frame.
+func (f *frame) add(name string, value interface{}) {
+	f.Fields = append(f.Fields, field{name, value})
+}
+
+// MarshalJSON writes the frame as a JSON object whose fields are in the
+// order in which they were decoded.
+func (f *frame) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf(`{"offset":%d,"length":%d,"command":%q`,
+		f.Offset, f.Length, f.Command))
+	for _, fd := range f.Fields {
+		name, err := json.Marshal(fd.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(fd.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteByte(',')
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// writeText writes the frame in annotated form.
+func (f *frame) writeText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%08x %s (%d bytes)\n", f.Offset, f.Command,
+		f.Length)
+	if err != nil {
+		return err
+	}
+	for _, fd := range f.Fields {
+		if list, ok := fd.Value.([]string); ok {
+			_, err = fmt.Fprintf(w, "    %-20s %d\n", fd.Name, len(list))
+			for i := 0; err == nil && i < len(list); i++ {
+				_, err = fmt.Fprintf(w, "      %s\n", list[i])
+			}
+		} else {
+			_, err = fmt.Fprintf(w, "    %-20s %v\n", fd.Name, fd.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dissect reads frames from r until it is exhausted, calling emit with
+// each one. It returns an error for the first frame which cannot be
+// decoded.
+func dissect(r io.Reader, bmnet wire.BitmessageNet, emit func(*frame) error) error {
+	offset := 0
+	for {
+		n, msg, _, err := wire.ReadMessageN(r, bmnet)
+		if err == io.EOF && n == 0 {
+			return nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("frame at offset %d: %v", offset, err)
+		}
+
+		f := &frame{Offset: offset, Length: n, Command: msg.Command()}
+		describeMessage(f, msg)
+		if err := emit(f); err != nil {
+			return err
+		}
+		offset += n
+	}
+}
+
+// dissectObject decodes a single object without a message header.
+func dissectObject(b []byte) (*frame, error) {
+	msg, err := wire.DecodeMsgObject(b)
+	if err != nil {
+		return nil, err
+	}
+	f := &frame{Length: len(b), Command: wire.CmdObject}
+	describeMessage(f, msg)
+	return f, nil
+}
+
+// formatTime formats the times in messages.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// describeMessage adds the fields of a message to a frame.
+func describeMessage(f *frame, msg wire.Message) {
+	switch m := msg.(type) {
+	case *wire.MsgVersion:
+		f.add("protocolVersion", m.ProtocolVersion)
+		f.add("services", m.Services.String())
+		f.add("timestamp", formatTime(m.Timestamp))
+		f.add("addrYou", formatNetAddress(m.AddrYou))
+		f.add("addrMe", formatNetAddress(m.AddrMe))
+		f.add("nonce", fmt.Sprintf("%016x", m.Nonce))
+		f.add("userAgent", m.UserAgent)
+		streams := make([]string, len(m.StreamNumbers))
+		for i, s := range m.StreamNumbers {
+			streams[i] = fmt.Sprint(s)
+		}
+		f.add("streams", streams)
+
+	case *wire.MsgAddr:
+		addrs := make([]string, len(m.AddrList))
+		for i, na := range m.AddrList {
+			addrs[i] = fmt.Sprintf("%s stream=%d services=%s time=%s",
+				formatNetAddress(na), na.Stream, na.Services,
+				formatTime(na.Timestamp))
+		}
+		f.add("addresses", addrs)
+
+	case *wire.MsgInv:
+		f.add("inventory", formatInvList(m.InvList))
+
+	case *wire.MsgGetData:
+		f.add("inventory", formatInvList(m.InvList))
+
+	case *wire.MsgObject:
+		describeObject(f, m)
+	}
+}
+
+// formatNetAddress formats the host and port of a network address.
+func formatNetAddress(na *wire.NetAddress) string {
+	if na == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s:%d", na.IP, na.Port)
+}
+
+// formatInvList formats the hashes of an inv or getdata message.
+func formatInvList(list []*wire.InvVect) []string {
+	hashes := make([]string, len(list))
+	for i, iv := range list {
+		hashes[i] = (*hash.Sha)(iv).String()
+	}
+	return hashes
+}
+
+// describeObject adds the fields of the header of an object and of its
+// contents, if they can be decoded, to a frame.
+func describeObject(f *frame, msg *wire.MsgObject) {
+	encoded := wire.Encode(msg)
+	header := msg.Header()
+
+	f.add("inventoryHash", hash.InventoryHash(encoded).String())
+	f.add("nonce", fmt.Sprintf("%016x", uint64(header.Nonce)))
+	f.add("expiration", formatTime(header.Expiration()))
+	f.add("objectType", header.ObjectType.String())
+	f.add("version", header.Version)
+	f.add("stream", header.StreamNumber)
+
+	o, err := obj.ReadObject(encoded)
+	if err != nil {
+		f.add("error", err.Error())
+		return
+	}
+
+	switch o := o.(type) {
+	case *obj.GetPubKey:
+		if o.Ripe != nil {
+			f.add("ripe", o.Ripe.String())
+		} else {
+			f.add("tag", o.Tag.String())
+		}
+
+	case *obj.SimplePubKey:
+		describePubKeyData(f, o.Data())
+
+	case *obj.ExtendedPubKey:
+		describePubKeyData(f, o.Data())
+		f.add("signature", hex.EncodeToString(o.Signature))
+
+	case *obj.EncryptedPubKey:
+		f.add("tag", o.Tag.String())
+		f.add("encrypted", len(o.Encrypted))
+
+	case *obj.Message:
+		f.add("encrypted", len(o.Encrypted))
+
+	case *obj.TaglessBroadcast:
+		f.add("encrypted", len(o.Encrypted()))
+
+	case *obj.TaggedBroadcast:
+		f.add("tag", o.Tag.String())
+		f.add("encrypted", len(o.Encrypted()))
+
+	default:
+		// The payload is of an unknown type or version, or could not be
+		// decoded as what its header says it is.
+		f.add("payload", hex.EncodeToString(msg.Payload()))
+	}
+}
+
+// describePubKeyData adds the fields of an unencrypted pubkey to a frame.
+func describePubKeyData(f *frame, data *obj.PubKeyData) {
+	f.add("behavior", fmt.Sprintf("%08x", data.Behavior))
+	f.add("signingKey", data.Verification.String())
+	f.add("encryptionKey", data.Encryption.String())
+	if data.Pow != nil {
+		f.add("nonceTrialsPerByte", data.Pow.NonceTrialsPerByte)
+		f.add("extraBytes", data.Pow.ExtraBytes)
+	}
+}
+
+// decodeHex decodes hexadecimal text, ignoring whitespace.
+func decodeHex(text []byte) ([]byte, error) {
+	clean := strings.Join(strings.Fields(string(text)), "")
+	return hex.DecodeString(clean)
+}
@@ -0,0 +1,28 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmdump decodes raw Bitmessage traffic and prints the structure of each
+message, in the manner of tcpdump.
+
+Usage:
+
+	bmdump [flags] [file ...]
+
+Each file, or standard input if none are given, is read as a sequence of
+frames, each of which is a message header followed by its payload, as they
+are sent between peers. Every field of every message is printed, including
+the header and contents of objects. Decoding stops at the first frame which
+is invalid.
+
+The flags are
+
+	-hex      the input is hexadecimal text rather than binary, and
+	          whitespace in it is ignored
+	-object   the input is a single object without a message header, as
+	          objects are kept in a database
+	-json     print each frame as a JSON object on one line
+	-net      the network whose frames are read, mainnet or testnet
+*/
+package main
@@ -0,0 +1,112 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// networks are the networks whose frames can be read, by name.
+var networks = map[string]wire.BitmessageNet{
+	"mainnet": wire.MainNet,
+	"testnet": wire.TestNet,
+}
+
+// config is what the flags say to do.
+type config struct {
+	hex    bool
+	object bool
+	json   bool
+	net    wire.BitmessageNet
+}
+
+// printer returns a function which writes frames to w as the config says.
+func (cfg *config) printer(w io.Writer) func(*frame) error {
+	if cfg.json {
+		enc := json.NewEncoder(w)
+		return func(f *frame) error {
+			return enc.Encode(f)
+		}
+	}
+	return func(f *frame) error {
+		return f.writeText(w)
+	}
+}
+
+// dump decodes one input and writes what it contains to w.
+func dump(cfg *config, r io.Reader, w io.Writer) error {
+	if cfg.hex || cfg.object {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if cfg.hex {
+			if b, err = decodeHex(b); err != nil {
+				return err
+			}
+		}
+		r = bytes.NewReader(b)
+
+		if cfg.object {
+			f, err := dissectObject(b)
+			if err != nil {
+				return err
+			}
+			return cfg.printer(w)(f)
+		}
+	}
+
+	return dissect(r, cfg.net, cfg.printer(w))
+}
+
+func main() {
+	cfg := &config{}
+	flag.BoolVar(&cfg.hex, "hex", false, "input is hexadecimal text")
+	flag.BoolVar(&cfg.object, "object", false,
+		"input is a single object without a message header")
+	flag.BoolVar(&cfg.json, "json", false, "print frames as JSON")
+	netName := flag.String("net", "mainnet", "network: mainnet or testnet")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: bmdump [flags] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var ok bool
+	if cfg.net, ok = networks[*netName]; !ok {
+		fmt.Fprintf(os.Stderr, "bmdump: unknown network %q\n", *netName)
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 0 {
+		if err := dump(cfg, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "bmdump:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, name := range flag.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bmdump:", err)
+			os.Exit(1)
+		}
+		err = dump(cfg, file, os.Stdout)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bmdump: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
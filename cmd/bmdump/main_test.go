@@ -0,0 +1,109 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// frames returns the encoding of a verack, an inv and a msg object.
+func frames(t *testing.T) []byte {
+	inv := wire.NewMsgInv()
+	var sha hash.Sha
+	sha[0] = 0xab
+	inv.AddInvVect((*wire.InvVect)(&sha))
+
+	msg := obj.NewMessage(123, time.Unix(1500000000, 0), 1, []byte{1, 2, 3})
+
+	var b bytes.Buffer
+	for _, m := range []wire.Message{wire.NewMsgVerAck(), inv, msg.MsgObject()} {
+		if err := wire.WriteMessage(&b, m, wire.MainNet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.Bytes()
+}
+
+// run dumps input and returns what was written.
+func run(t *testing.T, cfg *config, input []byte) string {
+	var out bytes.Buffer
+	if err := dump(cfg, bytes.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestDumpText(t *testing.T) {
+	out := run(t, &config{net: wire.MainNet}, frames(t))
+
+	for _, expected := range []string{
+		"00000000 verack (24 bytes)\n",
+		"00000018 inv (",
+		"      ab00000000",
+		" object (",
+		"    objectType           Msg\n",
+		"    expiration           2017-07-14T02:40:00Z\n",
+		"    encrypted            3\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in\n%s", expected, out)
+		}
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	input := []byte(hex.EncodeToString(frames(t)))
+	out := run(t, &config{hex: true, json: true, net: wire.MainNet}, input)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(lines))
+	}
+	var f map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f["command"] != "object" || f["stream"] != float64(1) ||
+		f["nonce"] != "000000000000007b" {
+		t.Errorf("wrong object %v", f)
+	}
+}
+
+func TestDumpObject(t *testing.T) {
+	msg := obj.NewMessage(0, time.Unix(1500000000, 0), 1, []byte{1, 2, 3})
+	out := run(t, &config{object: true}, wire.Encode(msg))
+	if !strings.Contains(out, "    objectType           Msg\n") {
+		t.Errorf("wrong object\n%s", out)
+	}
+}
+
+func TestDumpErrors(t *testing.T) {
+	input := frames(t)
+	tests := []struct {
+		name  string
+		cfg   *config
+		input []byte
+	}{
+		{"truncated", &config{net: wire.MainNet}, input[:len(input)-1]},
+		{"wrong network", &config{net: wire.TestNet}, input},
+		{"bad hex", &config{hex: true, net: wire.MainNet}, []byte("xyz")},
+	}
+
+	for _, test := range tests {
+		err := dump(test.cfg, bytes.NewReader(test.input), &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmkeys converts files of identities between the keys.dat file of
+PyBitmessage, encrypted JSON exports and plain listings of keys, and checks
+them for corruption.
+
+Usage:
+
+	bmkeys -from format [-to format] [-verify] [flags] [input [output]]
+
+The identities are read from input, or from standard input, in the format
+given with -from, and written to output, or to standard output, in the
+format given with -to. The formats are
+
+	keys.dat   the INI file in which PyBitmessage keeps its identities
+	wif        one identity per line: the address, then the private signing
+	           key and the private encryption key in wallet import format
+	json       an array of objects with the fields of keys.dat
+	encrypted  the json format encrypted with a password
+
+The password of an encrypted file is given with -password, or in the
+BMKEYS_PASSWORD environment variable.
+
+With -verify, the address of each identity is made again from its keys and
+compared with the address in the file, and each identity whose keys do not
+match is printed. Bmkeys exits with status 1 if there are any, and writes
+nothing. If -to is not given, the identities are only verified.
+*/
+package main
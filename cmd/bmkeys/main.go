@@ -0,0 +1,148 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/DanielKrawisz/bmutil/cmd/internal/keyfile"
+)
+
+// passwordEnv is the environment variable which may hold the password of
+// an encrypted file.
+const passwordEnv = "BMKEYS_PASSWORD"
+
+// errNoPassword is returned when an encrypted file is read or written
+// without a password.
+var errNoPassword = errors.New("a password is needed for the encrypted format")
+
+// lookup returns the format with the given name.
+func lookup(name, password string) (*keyfile.Format, error) {
+	if name != "encrypted" {
+		return keyfile.Lookup(name)
+	}
+	if password == "" {
+		return nil, errNoPassword
+	}
+	return keyfile.EncryptedFormat(password), nil
+}
+
+// verify checks every record and writes those which fail to w. It returns
+// the number which failed.
+func verify(w io.Writer, records []*keyfile.Record) int {
+	failed := 0
+	for _, r := range records {
+		if err := r.Verify(); err != nil {
+			fmt.Fprintf(w, "%s: %v\n", r.Address, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// options are what the flags say to do.
+type options struct {
+	from, to string
+	password string
+	verify   bool
+}
+
+// errCorrupt is returned when some identities fail verification.
+var errCorrupt = errors.New("corrupt identities")
+
+// run reads identities from in and writes them to out, reporting
+// identities which fail verification to report.
+func run(opts *options, in io.Reader, out, report io.Writer) error {
+	src, err := lookup(opts.from, opts.password)
+	if err != nil {
+		return err
+	}
+	var dst *keyfile.Format
+	if opts.to != "" {
+		if dst, err = lookup(opts.to, opts.password); err != nil {
+			return err
+		}
+	}
+
+	records, err := src.Read(in)
+	if err != nil {
+		return err
+	}
+
+	if opts.verify {
+		failed := verify(report, records)
+		fmt.Fprintf(report, "%d of %d identities verified\n",
+			len(records)-failed, len(records))
+		if failed > 0 {
+			return errCorrupt
+		}
+	}
+
+	if dst == nil {
+		return nil
+	}
+	return dst.Write(out, records)
+}
+
+func main() {
+	opts := &options{}
+	flag.StringVar(&opts.from, "from", "", "input format")
+	flag.StringVar(&opts.to, "to", "", "output format")
+	flag.StringVar(&opts.password, "password", os.Getenv(passwordEnv),
+		"password of encrypted files")
+	flag.BoolVar(&opts.verify, "verify", false,
+		"check that the keys of each identity make its address")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: bmkeys -from format [-to format] "+
+			"[-verify] [flags] [input [output]]")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Formats: %s, encrypted\n", keyfile.Names())
+	}
+	flag.Parse()
+
+	if opts.from == "" || (opts.to == "" && !opts.verify) || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in := io.Reader(os.Stdin)
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	// The output is only written once everything has been read, so that a
+	// file is not left half written.
+	var out bytes.Buffer
+	if err := run(opts, in, &out, os.Stderr); err != nil {
+		fail(err)
+	}
+
+	var err error
+	if flag.NArg() > 1 {
+		err = ioutil.WriteFile(flag.Arg(1), out.Bytes(), 0600)
+	} else {
+		_, err = os.Stdout.Write(out.Bytes())
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// fail prints an error and exits.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "bmkeys:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/cmd/internal/keyfile"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// wif returns a listing of n identities.
+func wif(t *testing.T, n int) string {
+	keys, err := identity.NewDeterministic("bmkeys", 1, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]*keyfile.Record, n)
+	for i, key := range keys {
+		records[i] = keyfile.NewRecord(identity.NewPrivateAddress(key, 4, 1), "")
+	}
+	var b bytes.Buffer
+	if err := keyfile.WriteWIF(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// convert runs bmkeys and returns what it wrote.
+func convert(t *testing.T, opts *options, in string) string {
+	var out, report bytes.Buffer
+	if err := run(opts, strings.NewReader(in), &out, &report); err != nil {
+		t.Fatalf("%v: %v\n%s", opts, err, report.String())
+	}
+	return out.String()
+}
+
+func TestConvert(t *testing.T) {
+	listing := wif(t, 2)
+
+	for _, name := range []string{"keys.dat", "json", "encrypted"} {
+		converted := convert(t, &options{from: "wif", to: name,
+			password: "secret"}, listing)
+		back := convert(t, &options{from: name, to: "wif",
+			password: "secret", verify: true}, converted)
+		if back != listing {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, listing, back)
+		}
+	}
+
+	err := run(&options{from: "wif", to: "encrypted"},
+		strings.NewReader(listing), &bytes.Buffer{}, &bytes.Buffer{})
+	if err != errNoPassword {
+		t.Errorf("expected errNoPassword, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(wif(t, 2)), "\n")
+	a := strings.Fields(lines[0])
+	b := strings.Fields(lines[1])
+
+	// The first identity is intact and the second has the wrong
+	// encryption key.
+	in := lines[0] + "\n" + b[0] + " " + b[1] + " " + a[2] + "\n"
+
+	var out, report bytes.Buffer
+	err := run(&options{from: "wif", to: "json", verify: true},
+		strings.NewReader(in), &out, &report)
+	if err != errCorrupt {
+		t.Fatalf("expected errCorrupt, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Error("corrupt identities were written")
+	}
+	if !strings.Contains(report.String(), b[0]+": keys make address") ||
+		strings.Contains(report.String(), a[0]+":") {
+		t.Errorf("wrong report:\n%s", report.String())
+	}
+}
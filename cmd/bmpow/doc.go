@@ -0,0 +1,39 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmpow does the proof of work for Bitmessage objects.
+
+Usage:
+
+	bmpow -hash hex -target n [flags]
+	bmpow -object [flags] [file]
+	bmpow -worker [flags]
+
+With -hash, bmpow finds a nonce for an initial hash, which is the SHA-512 of
+an object without its nonce, and a target, and prints it.
+
+With -object, bmpow reads an encoded object from the file, or from standard
+input, calculates its target from its length and time to live, does the
+proof of work and writes the object with its nonce set to standard output.
+The proof of work parameters of the recipient are given with -trials and
+-extra, and default to those of the network.
+
+With -worker, bmpow reads jobs from standard input, one per line, each of
+which is an initial hash in hexadecimal and a target in decimal, and writes
+a line for each, which is either the nonce in decimal or "error: " followed
+by what was wrong with the job. This lets bmpow serve as a worker to which
+the proof of work is handed off by another program.
+
+The flags are
+
+	-workers  the number of goroutines doing the work, which defaults to the
+	          number of CPUs
+	-progress print the time elapsed and the expected number of trials
+	          to standard error while working
+	-hex      objects are read and written as hexadecimal text
+	-ttl      the time to live used to calculate the target of an object,
+	          rather than the time until it expires
+*/
+package main
@@ -0,0 +1,115 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// fail prints an error and exits.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "bmpow:", err)
+	os.Exit(1)
+}
+
+// doObject does the proof of work for the object read from r and writes it
+// to w.
+func doObject(s *solver, r io.Reader, w io.Writer, isHex bool,
+	data pow.Data, ttl time.Duration) error {
+
+	encoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if isHex {
+		encoded, err = hex.DecodeString(strings.Join(
+			strings.Fields(string(encoded)), ""))
+		if err != nil {
+			return err
+		}
+	}
+
+	target, initialHash, err := objectJob(encoded, data, ttl, time.Now())
+	if err != nil {
+		return err
+	}
+	setNonce(encoded, s.solve(target, initialHash))
+
+	if isHex {
+		_, err = fmt.Fprintln(w, hex.EncodeToString(encoded))
+	} else {
+		_, err = w.Write(encoded)
+	}
+	return err
+}
+
+func main() {
+	initialHash := flag.String("hash", "", "initial hash in hexadecimal")
+	target := flag.Uint64("target", 0, "target for -hash")
+	object := flag.Bool("object", false, "do the proof of work for an object")
+	worker := flag.Bool("worker", false, "read jobs from standard input")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines")
+	progress := flag.Bool("progress", false, "print progress to standard error")
+	isHex := flag.Bool("hex", false, "objects are hexadecimal text")
+	ttl := flag.Duration("ttl", 0, "time to live of the object")
+	trials := flag.Uint64("trials", pow.DefaultNonceTrialsPerByte,
+		"nonce trials per byte")
+	extra := flag.Uint64("extra", pow.DefaultExtraBytes, "extra bytes")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: bmpow -hash hex -target n [flags]\n"+
+			"       bmpow -object [flags] [file]\n"+
+			"       bmpow -worker [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	s := &solver{workers: *workers, interval: time.Second}
+	if *progress {
+		s.progress = os.Stderr
+	}
+
+	switch {
+	case *worker:
+		if err := s.work(os.Stdin, os.Stdout); err != nil {
+			fail(err)
+		}
+
+	case *object:
+		in := os.Stdin
+		if flag.NArg() > 0 {
+			file, err := os.Open(flag.Arg(0))
+			if err != nil {
+				fail(err)
+			}
+			defer file.Close()
+			in = file
+		}
+		data := pow.Data{NonceTrialsPerByte: *trials, ExtraBytes: *extra}
+		if err := doObject(s, in, os.Stdout, *isHex, data, *ttl); err != nil {
+			fail(err)
+		}
+
+	case *initialHash != "":
+		t, h, err := parseJob(fmt.Sprintf("%s %d", *initialHash, *target))
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println(s.solve(t, h))
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// errExpired is returned for an object which has expired, whose target
+// cannot be calculated.
+var errExpired = errors.New("object has expired")
+
+// solver does the proof of work.
+type solver struct {
+	workers int
+
+	// progress, if not nil, is where the progress of each job is written.
+	progress io.Writer
+	interval time.Duration
+}
+
+// solve finds a nonce for an initial hash and a target.
+func (s *solver) solve(target pow.Target, initialHash []byte) pow.Nonce {
+	if s.progress != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go s.report(target, done)
+	}
+
+	if s.workers <= 1 {
+		return pow.DoSequential(target, initialHash)
+	}
+	return pow.DoParallel(target, initialHash, s.workers)
+}
+
+// report writes the time elapsed at each interval until done is closed.
+func (s *solver) report(target pow.Target, done <-chan struct{}) {
+	start := time.Now()
+	trials := uint64(math.MaxUint64)
+	if target != 0 {
+		trials /= uint64(target)
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Fprintf(s.progress, "%s elapsed, %d trials expected\n",
+				time.Since(start).Truncate(time.Second), trials)
+		case <-done:
+			return
+		}
+	}
+}
+
+// objectJob returns the target and initial hash of an encoded object. If
+// ttl is zero, the time until the object expires is used.
+func objectJob(encoded []byte, data pow.Data, ttl time.Duration,
+	now time.Time) (pow.Target, []byte, error) {
+
+	msg, err := wire.DecodeMsgObject(encoded)
+	if err != nil {
+		return 0, nil, err
+	}
+	if ttl == 0 {
+		ttl = msg.Header().Expiration().Sub(now)
+		if ttl <= 0 {
+			return 0, nil, errExpired
+		}
+	}
+
+	target := pow.CalculateTarget(uint64(len(encoded)),
+		uint64(ttl/time.Second), data)
+
+	// The nonce is not part of what is hashed.
+	return target, hash.Sha512(encoded[8:]), nil
+}
+
+// setNonce writes a nonce into an encoded object.
+func setNonce(encoded []byte, nonce pow.Nonce) {
+	copy(encoded[:8], nonce.Bytes())
+}
+
+// parseJob reads a line of the form "hash target".
+func parseJob(line string) (pow.Target, []byte, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return 0, nil, fmt.Errorf("expected 2 fields, got %d", len(fields))
+	}
+	initialHash, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(initialHash) != sha512.Size {
+		return 0, nil, fmt.Errorf("initial hash must be %d bytes",
+			sha512.Size)
+	}
+	target, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pow.Target(target), initialHash, nil
+}
+
+// work reads jobs from r and writes a line for each to w, until r is
+// exhausted.
+func (s *solver) work(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var err error
+		target, initialHash, jobErr := parseJob(line)
+		if jobErr != nil {
+			_, err = fmt.Fprintf(w, "error: %v\n", jobErr)
+		} else {
+			_, err = fmt.Fprintf(w, "%d\n", s.solve(target, initialHash))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
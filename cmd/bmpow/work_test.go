@@ -0,0 +1,87 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// easy is a target which is found after a few thousand trials.
+const easy = pow.Target(math.MaxUint64 / 1000)
+
+func TestWork(t *testing.T) {
+	initialHash := hash.Sha512([]byte("bmpow"))
+	in := fmt.Sprintf("%x %d\n\nnot a job\n%x %d\n", initialHash, easy,
+		initialHash[:10], easy)
+
+	for _, workers := range []int{1, 4} {
+		var out bytes.Buffer
+		s := &solver{workers: workers}
+		if err := s.work(strings.NewReader(in), &out); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %q", out.String())
+		}
+		nonce, err := strconv.ParseUint(lines[0], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pow.Check(easy, pow.Nonce(nonce), initialHash) {
+			t.Errorf("%d workers: nonce %d is not valid", workers, nonce)
+		}
+		for _, line := range lines[1:] {
+			if !strings.HasPrefix(line, "error: ") {
+				t.Errorf("expected an error, got %q", line)
+			}
+		}
+	}
+}
+
+func TestObject(t *testing.T) {
+	now := time.Now()
+	data := pow.Data{NonceTrialsPerByte: 1, ExtraBytes: 1}
+	msg := obj.NewMessage(0, now.Add(time.Hour), 1, []byte{1, 2, 3})
+
+	var out bytes.Buffer
+	in := strings.NewReader(hex.EncodeToString(wire.Encode(msg)))
+	if err := doObject(&solver{workers: 2}, in, &out, true, data, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := hex.DecodeString(strings.TrimSpace(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := wire.DecodeMsgObject(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done.CheckPow(data, now) {
+		t.Error("proof of work is not valid")
+	}
+}
+
+func TestObjectExpired(t *testing.T) {
+	msg := obj.NewMessage(0, time.Now().Add(-time.Hour), 1, []byte{1, 2, 3})
+	_, _, err := objectJob(wire.Encode(msg), pow.Default, 0, time.Now())
+	if err != errExpired {
+		t.Errorf("expected errExpired, got %v", err)
+	}
+}
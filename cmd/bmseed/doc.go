@@ -0,0 +1,16 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmseed writes the seed corpora of package fuzzseed for go test.
+
+Usage:
+
+	bmseed -kind messages|objects [-testnet] dir
+
+The seeds are written to dir, which is the directory that go test reads for
+a fuzz target, such as testdata/fuzz/FuzzReadMessage. Messages are framed
+for the main network unless -testnet is given.
+*/
+package main
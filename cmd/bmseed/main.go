@@ -0,0 +1,49 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/DanielKrawisz/bmutil/fuzzseed"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func main() {
+	kind := flag.String("kind", "", "kind of seeds: messages or objects")
+	testnet := flag.Bool("testnet", false, "frame messages for the test network")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: bmseed -kind messages|objects [-testnet] dir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var seeds []fuzzseed.Seed
+	switch *kind {
+	case "messages":
+		bmnet := wire.MainNet
+		if *testnet {
+			bmnet = wire.TestNet
+		}
+		seeds = fuzzseed.Messages(bmnet)
+	case "objects":
+		seeds = fuzzseed.Objects()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := fuzzseed.WriteCorpus(flag.Arg(0), seeds); err != nil {
+		fmt.Fprintln(os.Stderr, "bmseed:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %d seeds to %s\n", len(seeds), flag.Arg(0))
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+var (
+	// errNoIdentities is returned for an identity file which is empty.
+	errNoIdentities = errors.New("no identities")
+
+	// errNoPubKey is returned when a message is to be sent to an address
+	// whose pubkey was not given.
+	errNoPubKey = errors.New("the pubkey of the recipient is needed")
+)
+
+// readIdentities reads identities with one per line. Blank lines and lines
+// starting with # are skipped.
+func readIdentities(r io.Reader) ([]*identity.PrivateID, error) {
+	var ids []*identity.PrivateID
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 fields, got %d",
+				line, len(fields))
+		}
+		address, err := identity.ImportWIF(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		ids = append(ids, identity.NewPrivateID(address, 0, &pow.Default))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errNoIdentities
+	}
+	return ids, nil
+}
+
+// findIdentity returns the identity with the given address, or the first
+// if address is empty.
+func findIdentity(ids []*identity.PrivateID, address string) (*identity.PrivateID, error) {
+	if address == "" {
+		return ids[0], nil
+	}
+	for _, id := range ids {
+		if id.Address().String() == address {
+			return id, nil
+		}
+	}
+	return nil, fmt.Errorf("no identity for %s", address)
+}
+
+// decodeObject decodes an object which is either binary or hexadecimal.
+func decodeObject(b []byte) []byte {
+	if decoded, err := hex.DecodeString(strings.TrimSpace(string(b))); err == nil {
+		return decoded
+	}
+	return b
+}
+
+// readPubKey reads the pubkey object of address and returns the identity in
+// it.
+func readPubKey(b []byte, address bmutil.Address) (identity.Public, error) {
+	o, err := obj.ReadObject(decodeObject(b))
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := cipher.TryDecryptAndVerifyPubKey(o, address)
+	if err != nil {
+		return nil, err
+	}
+	id, err := cipher.ToIdentity(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if id.Address().String() != address.String() {
+		return nil, fmt.Errorf("pubkey is for %s", id.Address())
+	}
+	return id, nil
+}
+
+// request is what is to be composed.
+type request struct {
+	from    *identity.PrivateID
+	to      identity.Public
+	subject string
+	body    string
+	ttl     time.Duration
+	ack     bool
+	testnet bool
+}
+
+// compose signs and encrypts a message, or a broadcast if there is no
+// recipient, and does its proof of work.
+func compose(req *request) (*wire.MsgObject, error) {
+	b := message.NewMessage().From(req.from).Subject(req.subject).
+		Body(req.body).TTL(req.ttl)
+	if req.to != nil {
+		b.To(req.to)
+	}
+	if req.ack {
+		b.RequestAck()
+	}
+	if req.testnet {
+		b.Net(wire.TestNet)
+	}
+
+	o, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewMsgObject(o.Header(), o.Payload()), nil
+}
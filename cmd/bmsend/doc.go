@@ -0,0 +1,46 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmsend composes a message or broadcast, does its proof of work and writes
+the object to a file or sends it to a node.
+
+Usage:
+
+	bmsend -identities file [flags]
+
+The identities are read from a file with one identity per line, which is
+the address followed by the private signing key and the private encryption
+key in wallet import format, as written by bmaddr. The message is sent from
+the identity given with -from, or from the first identity in the file.
+
+If -to is given, a message is sent to that address. Its pubkey must be
+given with -pubkey, which is a file holding the pubkey object in binary or
+hexadecimal, unless the address is one of the identities in the file.
+Without -to, a broadcast is sent.
+
+The body is given with -body, or is read from standard input if it is not.
+
+The object is written to the file given with -out, or to standard output,
+in binary or, with -hex, in hexadecimal. If -node is given, it is sent to
+the node at that address instead: bmsend connects, does the handshake,
+announces the object with an inv message and sends it when the node asks
+for it.
+
+The flags are
+
+	-identities  the file of identities
+	-from        the address of the sender
+	-to          the address of the recipient
+	-pubkey      the pubkey object of the recipient
+	-subject     the subject
+	-body        the body
+	-ttl         how long the object lasts on the network, from 5m to 672h
+	-ack         ask for an acknowledgement
+	-out         the file to which the object is written
+	-hex         write the object in hexadecimal
+	-node        the address of a node to send the object to
+	-testnet     the node is on the test network
+*/
+package main
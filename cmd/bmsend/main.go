@@ -0,0 +1,138 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// requestTimeout is how long a node is given to ask for the object
+	// once it has been offered.
+	requestTimeout = time.Minute
+
+	// lingerTime is how long the connection to a node is kept open once
+	// the object has been queued.
+	lingerTime = 2 * time.Second
+)
+
+// fail prints an error and exits.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "bmsend:", err)
+	os.Exit(1)
+}
+
+func main() {
+	identities := flag.String("identities", "", "file of identities")
+	from := flag.String("from", "", "address of the sender")
+	to := flag.String("to", "", "address of the recipient")
+	pubkey := flag.String("pubkey", "", "file holding the pubkey of the recipient")
+	subject := flag.String("subject", "", "subject")
+	body := flag.String("body", "", "body, which is read from standard input if not given")
+	ttl := flag.Duration("ttl", message.DefaultTTL, "time to live")
+	ack := flag.Bool("ack", false, "ask for an acknowledgement")
+	out := flag.String("out", "", "file to which the object is written")
+	isHex := flag.Bool("hex", false, "write the object in hexadecimal")
+	node := flag.String("node", "", "address of a node to send the object to")
+	testnet := flag.Bool("testnet", false, "the node is on the test network")
+	flag.Parse()
+
+	if *identities == "" || flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: bmsend -identities file [flags]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(*identities)
+	if err != nil {
+		fail(err)
+	}
+	ids, err := readIdentities(file)
+	file.Close()
+	if err != nil {
+		fail(err)
+	}
+
+	req := &request{subject: *subject, body: *body, ttl: *ttl, ack: *ack,
+		testnet: *testnet}
+	if req.from, err = findIdentity(ids, *from); err != nil {
+		fail(err)
+	}
+
+	if *to != "" {
+		if self, err := findIdentity(ids, *to); err == nil {
+			req.to = self.Public()
+		} else if *pubkey == "" {
+			fail(errNoPubKey)
+		} else {
+			address, err := bmutil.DecodeAddress(*to)
+			if err != nil {
+				fail(err)
+			}
+			b, err := ioutil.ReadFile(*pubkey)
+			if err != nil {
+				fail(err)
+			}
+			if req.to, err = readPubKey(b, address); err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	if *body == "" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		req.body = string(b)
+	}
+
+	msg, err := compose(req)
+	if err != nil {
+		fail(err)
+	}
+
+	if *node != "" {
+		conn, err := net.Dial("tcp", *node)
+		if err != nil {
+			fail(err)
+		}
+		cfg := &peer.Config{
+			Net:     wire.MainNet,
+			Streams: []uint32{uint32(req.from.Address().Stream())},
+		}
+		if *testnet {
+			cfg.Net = wire.TestNet
+		}
+		if err := send(conn, cfg, msg, requestTimeout, lingerTime); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	encoded := wire.Encode(msg)
+	if *isHex {
+		encoded = []byte(hex.EncodeToString(encoded) + "\n")
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(encoded)
+	} else {
+		err = ioutil.WriteFile(*out, encoded, 0644)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// identities returns a file of two identities and the identities in it.
+func identities(t *testing.T) (string, []*identity.PrivateID) {
+	keys, err := identity.NewDeterministic("bmsend", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, key := range keys {
+		address, signing, encryption :=
+			identity.NewPrivateAddress(key, 4, 1).ExportWIF()
+		lines = append(lines, address+" "+signing+" "+encryption)
+	}
+	file := "# test identities\n" + strings.Join(lines, "\n") + "\n"
+
+	ids, err := readIdentities(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("expected 2 identities, got %d", len(ids))
+	}
+	return file, ids
+}
+
+// receive decodes an object with a keyring holding ids and subscribed to
+// their broadcasts.
+func receive(t *testing.T, msg *wire.MsgObject, ids []*identity.PrivateID) *message.Received {
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &pow.Default})
+	for _, id := range ids {
+		keyring.AddIdentity(id)
+		keyring.Subscribe(id.Address())
+	}
+	r, err := message.Receive(msg, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadIdentities(t *testing.T) {
+	_, ids := identities(t)
+
+	id, err := findIdentity(ids, ids[1].Address().String())
+	if err != nil || id != ids[1] {
+		t.Errorf("wrong identity %v, %v", id, err)
+	}
+	if id, _ := findIdentity(ids, ""); id != ids[0] {
+		t.Error("expected the first identity by default")
+	}
+
+	if _, err := readIdentities(strings.NewReader("\n")); err != errNoIdentities {
+		t.Errorf("expected errNoIdentities, got %v", err)
+	}
+	if _, err := readIdentities(strings.NewReader("BM-x y\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
+
+func TestComposeMessage(t *testing.T) {
+	_, ids := identities(t)
+
+	msg, err := compose(&request{
+		from:    ids[0],
+		to:      ids[1].Public(),
+		subject: "hello",
+		body:    "composed by bmsend",
+		ttl:     message.MinTTL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := receive(t, msg, ids)
+	if r.IsBroadcast() || r.To.Address().String() != ids[1].Address().String() {
+		t.Errorf("wrong recipient %v", r.To)
+	}
+	content := r.Bitmessage.Content.(*format.Encoding2)
+	if content.Subject != "hello" || content.Body != "composed by bmsend" {
+		t.Errorf("wrong content %v", content)
+	}
+}
+
+func TestComposeBroadcast(t *testing.T) {
+	_, ids := identities(t)
+
+	msg, err := compose(&request{from: ids[0], body: "to everyone",
+		ttl: message.MinTTL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := receive(t, msg, ids); !r.IsBroadcast() {
+		t.Error("expected a broadcast")
+	}
+
+	_, err = compose(&request{from: ids[0], ttl: message.MinTTL, ack: true})
+	if err != message.ErrAckBroadcast {
+		t.Errorf("expected ErrAckBroadcast, got %v", err)
+	}
+}
+
+func TestReadPubKey(t *testing.T) {
+	_, ids := identities(t)
+
+	pubkey, err := cipher.GeneratePubKey(ids[1], time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := wire.Encode(pubkey.Object())
+
+	for _, b := range [][]byte{encoded, []byte(hex.EncodeToString(encoded))} {
+		id, err := readPubKey(b, ids[1].Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.Address().String() != ids[1].Address().String() {
+			t.Errorf("wrong identity %s", id.Address())
+		}
+	}
+
+	if _, err := readPubKey(encoded, ids[0].Address()); err == nil {
+		t.Error("expected an error for the pubkey of another address")
+	}
+}
+
+func TestSend(t *testing.T) {
+	_, ids := identities(t)
+	msg, err := compose(&request{from: ids[0], body: "to a node",
+		ttl: message.MinTTL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, remote := net.Pipe()
+	received := make(chan wire.Message, 1)
+	go func() {
+		node, err := peer.NewInbound(&peer.Config{Net: wire.MainNet,
+			Streams: []uint32{1}, Nonces: peer.NewNonceSet()}, remote)
+		if err != nil {
+			close(received)
+			return
+		}
+
+		// The node stays connected until bmsend hangs up.
+		defer func() { <-node.Done() }()
+
+		inv := (<-node.In()).(*wire.MsgInv)
+		getdata := wire.NewMsgGetData()
+		getdata.AddInvVect(inv.InvList[0])
+		node.QueueMessage(getdata)
+		received <- <-node.In()
+	}()
+
+	cfg := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		Nonces: peer.NewNonceSet()}
+	if err := send(local, cfg, msg, time.Second, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	object, ok := (<-received).(*wire.MsgObject)
+	if !ok || string(wire.Encode(object)) != string(wire.Encode(msg)) {
+		t.Error("node did not receive the object")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// errNotRequested is returned when a node does not ask for the object
+// which it was offered.
+var errNotRequested = errors.New("node did not ask for the object")
+
+// requests returns whether a getdata message asks for the object with the
+// given inventory vector.
+func requests(msg *wire.MsgGetData, iv *wire.InvVect) bool {
+	for _, v := range msg.InvList {
+		if *v == *iv {
+			return true
+		}
+	}
+	return false
+}
+
+// send offers an object to the node at the other end of conn and sends it
+// when the node asks for it. The node is given timeout to ask, and linger
+// to read the object once it has been queued, since there is no reply to
+// say that it has arrived.
+func send(conn net.Conn, cfg *peer.Config, msg *wire.MsgObject,
+	timeout, linger time.Duration) error {
+
+	p, err := peer.NewOutbound(cfg, conn)
+	if err != nil {
+		return err
+	}
+	defer p.Disconnect(nil)
+
+	iv := (*wire.InvVect)(obj.InventoryHash(msg))
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(iv)
+	if err := p.QueueMessage(inv); err != nil {
+		return err
+	}
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case m, ok := <-p.In():
+			if !ok {
+				return p.Err()
+			}
+			getdata, isGetData := m.(*wire.MsgGetData)
+			if !isGetData || !requests(getdata, iv) {
+				continue
+			}
+			if err := p.QueueMessage(msg); err != nil {
+				return err
+			}
+
+			select {
+			case <-time.After(linger):
+				return nil
+			case <-p.Done():
+				return p.Err()
+			}
+
+		case <-deadline:
+			return errNotRequested
+		}
+	}
+}
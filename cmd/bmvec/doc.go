@@ -0,0 +1,35 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Bmvec writes test vectors for other implementations of Bitmessage, and checks
+vectors written by them.
+
+Usage:
+
+	bmvec generate [-format json|hex]
+	bmvec verify [file]
+
+Generate writes the corpus of vectors to standard output. There are three
+kinds:
+
+	addresses  addresses made deterministically from passphrases, with
+	           their version, stream, ripe, tag and private keys
+	objects    encoded objects of every type and version, with the fields
+	           of their headers and their inventory hashes
+	ecies      messages encrypted to a private key
+
+Every vector but the ecies ones is the same each time it is generated. Since
+encryption uses an ephemeral key, each ecies ciphertext is different, and is
+checked by decrypting it.
+
+In json format, the corpus is a single object with a field for each kind.
+In hex format, only the binary vectors, which are the objects and the ecies
+ciphertexts, are written, one per line, each preceded by its kind and name.
+
+Verify reads a corpus in json format from the file, or from standard input,
+and checks every vector against the decoders of bmutil. It prints each
+vector which fails and exits with status 1 if there are any.
+*/
+package main
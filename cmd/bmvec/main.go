@@ -0,0 +1,102 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// writeCorpus writes a corpus in the given format.
+func writeCorpus(w io.Writer, c *Corpus, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+
+	case "hex":
+		for _, v := range c.Objects {
+			if _, err := fmt.Fprintf(w, "object\t%s\t%s\n", v.Name,
+				v.Encoded); err != nil {
+				return err
+			}
+		}
+		for _, v := range c.ECIES {
+			if _, err := fmt.Fprintf(w, "ecies\t%s\t%s\n", v.Name,
+				v.Ciphertext); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: bmvec generate [-format json|hex]\n"+
+		"       bmvec verify [file]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "generate":
+		fs := flag.NewFlagSet("bmvec generate", flag.ExitOnError)
+		format := fs.String("format", "json", "output format: json or hex")
+		fs.Parse(os.Args[2:])
+
+		c, err := generateCorpus()
+		if err == nil {
+			err = writeCorpus(os.Stdout, c, *format)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bmvec:", err)
+			os.Exit(1)
+		}
+
+	case "verify":
+		in := os.Stdin
+		if len(os.Args) > 2 {
+			file, err := os.Open(os.Args[2])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "bmvec:", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		var c Corpus
+		if err := json.NewDecoder(in).Decode(&c); err != nil {
+			fmt.Fprintln(os.Stderr, "bmvec:", err)
+			os.Exit(1)
+		}
+		failures := verifyCorpus(&c)
+		for _, f := range failures {
+			fmt.Println(f)
+		}
+		n := len(c.Addresses) + len(c.Objects) + len(c.ECIES)
+		fmt.Printf("%d of %d vectors passed\n", n-len(failures), n)
+		if len(failures) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+	}
+}
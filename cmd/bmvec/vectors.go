@@ -0,0 +1,362 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// AddressVector is an address made from a passphrase.
+type AddressVector struct {
+	Passphrase    string `json:"passphrase"`
+	Address       string `json:"address"`
+	Version       uint64 `json:"version"`
+	Stream        uint64 `json:"stream"`
+	Ripe          string `json:"ripe"`
+	Tag           string `json:"tag"`
+	SigningKey    string `json:"privSigningKey"`
+	EncryptionKey string `json:"privEncryptionKey"`
+}
+
+// ObjectVector is an encoded object.
+type ObjectVector struct {
+	Name          string `json:"name"`
+	Encoded       string `json:"encoded"`
+	Nonce         uint64 `json:"nonce"`
+	Expiration    int64  `json:"expiration"`
+	ObjectType    uint32 `json:"objectType"`
+	Version       uint64 `json:"version"`
+	Stream        uint64 `json:"stream"`
+	InventoryHash string `json:"inventoryHash"`
+}
+
+// ECIESVector is a message encrypted to a private key.
+type ECIESVector struct {
+	Name       string `json:"name"`
+	PrivateKey string `json:"privateKey"`
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Corpus is every kind of vector.
+type Corpus struct {
+	Addresses []*AddressVector `json:"addresses"`
+	Objects   []*ObjectVector  `json:"objects"`
+	ECIES     []*ECIESVector   `json:"ecies"`
+}
+
+// The parameters which the objects in the corpus are made with.
+var (
+	vectorNonce      = pow.Nonce(0x0102030405060708)
+	vectorExpiration = time.Unix(1500000000, 0)
+)
+
+// vectorAddresses are the passphrases and versions of the addresses in the
+// corpus.
+var vectorAddresses = []struct {
+	passphrase string
+	version    uint64
+}{
+	{"bmvec", 4},
+	{"bmvec v3", 3},
+	{"the quick brown fox jumps over the lazy dog", 4},
+}
+
+// vectorPlaintexts are the messages which are encrypted in the corpus.
+var vectorPlaintexts = []struct {
+	name      string
+	plaintext []byte
+}{
+	{"short", []byte("bitmessage")},
+	{"block", bytes.Repeat([]byte{0x55}, 16)},
+	{"long", bytes.Repeat([]byte("All work and no play. "), 20)},
+}
+
+// newIdentity returns the identity made from a passphrase.
+func newIdentity(passphrase string, version uint64) (*identity.PrivateAddress, error) {
+	keys, err := identity.NewDeterministic(passphrase, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return identity.NewPrivateAddress(keys[0], version, 1), nil
+}
+
+// newAddressVector returns the vector of the address made from a
+// passphrase.
+func newAddressVector(passphrase string, version uint64) (*AddressVector, error) {
+	id, err := newIdentity(passphrase, version)
+	if err != nil {
+		return nil, err
+	}
+	address := id.Address()
+	if address == nil {
+		return nil, fmt.Errorf("cannot make a version %d address", version)
+	}
+	_, signing, encryption := id.ExportWIF()
+	return &AddressVector{
+		Passphrase:    passphrase,
+		Address:       address.String(),
+		Version:       address.Version(),
+		Stream:        address.Stream(),
+		Ripe:          hex.EncodeToString(address.RipeHash()[:]),
+		Tag:           hex.EncodeToString(bmutil.Tag(address)[:]),
+		SigningKey:    signing,
+		EncryptionKey: encryption,
+	}, nil
+}
+
+// newObjectVector returns the vector of an object.
+func newObjectVector(name string, o obj.Object) *ObjectVector {
+	header := o.Header()
+	encoded := wire.Encode(o)
+	return &ObjectVector{
+		Name:          name,
+		Encoded:       hex.EncodeToString(encoded),
+		Nonce:         uint64(header.Nonce),
+		Expiration:    header.Expiration().Unix(),
+		ObjectType:    uint32(header.ObjectType),
+		Version:       header.Version,
+		Stream:        header.StreamNumber,
+		InventoryHash: hex.EncodeToString(hash.InventoryHash(encoded)[:]),
+	}
+}
+
+// vectorObjects returns an object of every type and version, made with the
+// keys of id.
+func vectorObjects(id *identity.PrivateAddress) []*ObjectVector {
+	address := id.Address()
+	tag := bmutil.Tag(address)
+	key := id.PublicKey()
+	v3, _ := bmutil.NewDepricatedAddress(3, 1, address.RipeHash())
+
+	// The encrypted part of an object is opaque to its decoder.
+	encrypted := make([]byte, 64)
+	for i := range encrypted {
+		encrypted[i] = byte(i)
+	}
+
+	return []*ObjectVector{
+		newObjectVector("getpubkey v3",
+			obj.NewGetPubKey(vectorNonce, vectorExpiration, v3)),
+		newObjectVector("getpubkey v4",
+			obj.NewGetPubKey(vectorNonce, vectorExpiration, address)),
+		newObjectVector("pubkey v2",
+			obj.NewSimplePubKey(vectorNonce, vectorExpiration, 1, 1,
+				key.Verification.Wire(), key.Encryption.Wire())),
+		newObjectVector("pubkey v4",
+			obj.NewEncryptedPubKey(vectorNonce, vectorExpiration, 1, tag,
+				encrypted)),
+		newObjectVector("msg",
+			obj.NewMessage(vectorNonce, vectorExpiration, 1, encrypted)),
+		newObjectVector("broadcast v4",
+			obj.NewTaglessBroadcast(vectorNonce, vectorExpiration, 1,
+				encrypted)),
+		newObjectVector("broadcast v5",
+			obj.NewTaggedBroadcast(vectorNonce, vectorExpiration, 1, tag,
+				encrypted)),
+	}
+}
+
+// vectorECIES returns each plaintext encrypted to the decryption key of id.
+func vectorECIES(id *identity.PrivateAddress) ([]*ECIESVector, error) {
+	private := id.PrivateKey().Decryption
+	vectors := make([]*ECIESVector, len(vectorPlaintexts))
+	for i, p := range vectorPlaintexts {
+		ciphertext, err := btcec.Encrypt(private.PubKey(), p.plaintext)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = &ECIESVector{
+			Name:       p.name,
+			PrivateKey: hex.EncodeToString(private.Serialize()),
+			Plaintext:  hex.EncodeToString(p.plaintext),
+			Ciphertext: hex.EncodeToString(ciphertext),
+		}
+	}
+	return vectors, nil
+}
+
+// generateCorpus returns the corpus of vectors.
+func generateCorpus() (*Corpus, error) {
+	c := &Corpus{}
+	for _, a := range vectorAddresses {
+		v, err := newAddressVector(a.passphrase, a.version)
+		if err != nil {
+			return nil, err
+		}
+		c.Addresses = append(c.Addresses, v)
+	}
+
+	id, err := newIdentity(vectorAddresses[0].passphrase,
+		vectorAddresses[0].version)
+	if err != nil {
+		return nil, err
+	}
+	c.Objects = vectorObjects(id)
+	if c.ECIES, err = vectorECIES(id); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodeHex decodes a hex field of a vector.
+func decodeHex(name, s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return b, nil
+}
+
+// verify checks an address vector.
+func (v *AddressVector) verify() error {
+	address, err := bmutil.DecodeAddress(v.Address)
+	if err != nil {
+		return err
+	}
+	if address.Version() != v.Version {
+		return fmt.Errorf("version is %d, expected %d", address.Version(),
+			v.Version)
+	}
+	if address.Stream() != v.Stream {
+		return fmt.Errorf("stream is %d, expected %d", address.Stream(),
+			v.Stream)
+	}
+	if ripe := hex.EncodeToString(address.RipeHash()[:]); ripe != v.Ripe {
+		return fmt.Errorf("ripe is %s, expected %s", ripe, v.Ripe)
+	}
+	if tag := hex.EncodeToString(bmutil.Tag(address)[:]); tag != v.Tag {
+		return fmt.Errorf("tag is %s, expected %s", tag, v.Tag)
+	}
+
+	if _, err := identity.ImportWIF(v.Address, v.SigningKey,
+		v.EncryptionKey); err != nil {
+		return err
+	}
+
+	if v.Passphrase != "" {
+		id, err := newIdentity(v.Passphrase, v.Version)
+		if err != nil {
+			return err
+		}
+		if s := id.Address().String(); s != v.Address {
+			return fmt.Errorf("passphrase gives %s", s)
+		}
+	}
+	return nil
+}
+
+// verify checks an object vector.
+func (v *ObjectVector) verify() error {
+	encoded, err := decodeHex("encoded", v.Encoded)
+	if err != nil {
+		return err
+	}
+	o, err := obj.ReadObject(encoded)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.(*wire.MsgObject); ok {
+		return fmt.Errorf("payload could not be decoded")
+	}
+
+	header := o.Header()
+	switch {
+	case uint64(header.Nonce) != v.Nonce:
+		return fmt.Errorf("nonce is %d, expected %d", header.Nonce, v.Nonce)
+	case header.Expiration().Unix() != v.Expiration:
+		return fmt.Errorf("expiration is %d, expected %d",
+			header.Expiration().Unix(), v.Expiration)
+	case uint32(header.ObjectType) != v.ObjectType:
+		return fmt.Errorf("object type is %d, expected %d",
+			header.ObjectType, v.ObjectType)
+	case header.Version != v.Version:
+		return fmt.Errorf("version is %d, expected %d", header.Version,
+			v.Version)
+	case header.StreamNumber != v.Stream:
+		return fmt.Errorf("stream is %d, expected %d", header.StreamNumber,
+			v.Stream)
+	}
+
+	if !bytes.Equal(wire.Encode(o), encoded) {
+		return fmt.Errorf("encoding differs after decoding")
+	}
+	inv := hex.EncodeToString(hash.InventoryHash(encoded)[:])
+	if inv != v.InventoryHash {
+		return fmt.Errorf("inventory hash is %s, expected %s", inv,
+			v.InventoryHash)
+	}
+	return nil
+}
+
+// verify checks an ecies vector.
+func (v *ECIESVector) verify() error {
+	key, err := decodeHex("privateKey", v.PrivateKey)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decodeHex("plaintext", v.Plaintext)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := decodeHex("ciphertext", v.Ciphertext)
+	if err != nil {
+		return err
+	}
+
+	private, _ := btcec.PrivKeyFromBytes(btcec.S256(), key)
+	decrypted, err := btcec.Decrypt(private, ciphertext)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		return fmt.Errorf("decrypts to %x", decrypted)
+	}
+	return nil
+}
+
+// failure is a vector which did not verify.
+type failure struct {
+	kind string
+	name string
+	err  error
+}
+
+func (f *failure) String() string {
+	return fmt.Sprintf("%s %s: %v", f.kind, f.name, f.err)
+}
+
+// verifyCorpus checks every vector in a corpus and returns those which
+// fail.
+func verifyCorpus(c *Corpus) []*failure {
+	var failures []*failure
+	for _, v := range c.Addresses {
+		if err := v.verify(); err != nil {
+			failures = append(failures, &failure{"address", v.Address, err})
+		}
+	}
+	for _, v := range c.Objects {
+		if err := v.verify(); err != nil {
+			failures = append(failures, &failure{"object", v.Name, err})
+		}
+	}
+	for _, v := range c.ECIES {
+		if err := v.verify(); err != nil {
+			failures = append(failures, &failure{"ecies", v.Name, err})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateVerify(t *testing.T) {
+	c, err := generateCorpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures := verifyCorpus(c); len(failures) != 0 {
+		t.Fatalf("vectors failed: %v", failures)
+	}
+
+	// Everything but the ciphertexts is the same each time.
+	again, err := generateCorpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Addresses, again.Addresses) ||
+		!reflect.DeepEqual(c.Objects, again.Objects) {
+		t.Error("corpus is not deterministic")
+	}
+
+	// The corpus survives being written and read.
+	var b bytes.Buffer
+	if err := writeCorpus(&b, c, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var read Corpus
+	if err := json.Unmarshal(b.Bytes(), &read); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, &read) {
+		t.Error("corpus changed when read back")
+	}
+
+	b.Reset()
+	if err := writeCorpus(&b, c, "hex"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(c.Objects)+len(c.ECIES) {
+		t.Errorf("expected %d lines, got %d", len(c.Objects)+len(c.ECIES),
+			len(lines))
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	c, err := generateCorpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Addresses[0].Stream = 2
+	c.Objects[0].Expiration++
+	c.Objects[1].Encoded = c.Objects[1].Encoded[:20]
+	c.ECIES[0].Plaintext = "00"
+
+	failures := verifyCorpus(c)
+	if len(failures) != 4 {
+		t.Fatalf("expected 4 failures, got %v", failures)
+	}
+	for i, kind := range []string{"address", "object", "object", "ecies"} {
+		if failures[i].kind != kind {
+			t.Errorf("failure %d: expected %s, got %s", i, kind,
+				failures[i].kind)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package keyfile reads and writes the files in which identities are kept, for
+the commands of bmutil.
+
+The formats are plain listings of addresses and keys in wallet import
+format, the keys.dat file of PyBitmessage, a JSON array with the same fields
+as keys.dat, and an encrypted form of the JSON array which is protected by a
+password.
+
+The encrypted form is a JSON object holding the parameters of scrypt, which
+derives a key from the password, and the JSON array sealed with AES-256-GCM
+under that key.
+*/
+package keyfile
@@ -0,0 +1,140 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package keyfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// The parameters of scrypt for new encrypted files.
+const (
+	ScryptN = 1 << 15
+	ScryptR = 8
+	ScryptP = 1
+)
+
+// encryptedVersion is the version of the encrypted format.
+const encryptedVersion = 1
+
+var (
+	// ErrWrongPassword is returned when an encrypted file cannot be opened
+	// with the password which was given, or has been corrupted.
+	ErrWrongPassword = errors.New("wrong password or corrupted file")
+
+	// ErrUnknownVersion is returned for an encrypted file of a version
+	// which is not known.
+	ErrUnknownVersion = errors.New("unknown version of encrypted file")
+)
+
+// encrypted is an encrypted file.
+type encrypted struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// aead returns the cipher for a password and the parameters of a file.
+func (e *encrypted) aead(password string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), e.Salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt writes records encrypted with a password.
+func Encrypt(w io.Writer, records []*Record, password string) error {
+	var plain bytes.Buffer
+	if err := WriteJSON(&plain, records); err != nil {
+		return err
+	}
+
+	e := &encrypted{
+		Version: encryptedVersion,
+		KDF:     "scrypt",
+		N:       ScryptN,
+		R:       ScryptR,
+		P:       ScryptP,
+		Salt:    make([]byte, 32),
+	}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return err
+	}
+	aead, err := e.aead(password)
+	if err != nil {
+		return err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return err
+	}
+	e.Ciphertext = aead.Seal(nil, e.Nonce, plain.Bytes(), nil)
+
+	b, err := json.MarshalIndent(e, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// Decrypt reads records which were encrypted with a password.
+func Decrypt(r io.Reader, password string) ([]*Record, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var e encrypted
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	if e.Version != encryptedVersion || e.KDF != "scrypt" {
+		return nil, ErrUnknownVersion
+	}
+
+	aead, err := e.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassword
+	}
+	plain, err := aead.Open(nil, e.Nonce, e.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return ReadJSON(bytes.NewReader(plain))
+}
+
+// EncryptedFormat returns the format of files encrypted with a password.
+func EncryptedFormat(password string) *Format {
+	return &Format{
+		Read: func(r io.Reader) ([]*Record, error) {
+			return Decrypt(r, password)
+		},
+		Write: func(w io.Writer, records []*Record) error {
+			return Encrypt(w, records, password)
+		},
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package keyfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// Record is an identity in the form in which it is read and written.
+type Record struct {
+	Address       string `json:"address"`
+	Label         string `json:"label,omitempty"`
+	SigningKey    string `json:"privSigningKey"`
+	EncryptionKey string `json:"privEncryptionKey"`
+	NonceTrials   uint64 `json:"nonceTrialsPerByte,omitempty"`
+	ExtraBytes    uint64 `json:"payloadLengthExtraBytes,omitempty"`
+}
+
+// NewRecord returns the record of an identity.
+func NewRecord(id *identity.PrivateAddress, label string) *Record {
+	address, signing, encryption := id.ExportWIF()
+	return &Record{
+		Address:       address,
+		Label:         label,
+		SigningKey:    signing,
+		EncryptionKey: encryption,
+	}
+}
+
+// Identity returns the identity of a record, checking that its keys match
+// its address.
+func (r *Record) Identity() (*identity.PrivateAddress, error) {
+	id, err := identity.ImportWIF(r.Address, r.SigningKey, r.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", r.Address, err)
+	}
+	return id, nil
+}
+
+// Verify checks that the keys of a record make its address. It returns an
+// error which says what is wrong if they do not.
+func (r *Record) Verify() error {
+	address, err := bmutil.DecodeAddress(r.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address: %v", err)
+	}
+	signing, err := bmutil.DecodeWIF(r.SigningKey)
+	if err != nil {
+		return fmt.Errorf("invalid signing key: %v", err)
+	}
+	encryption, err := bmutil.DecodeWIF(r.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %v", err)
+	}
+
+	key := &identity.PrivateKey{Signing: signing, Decryption: encryption}
+	id := identity.NewPrivateAddress(key, address.Version(), address.Stream())
+	if made := id.Address().String(); made != r.Address {
+		return fmt.Errorf("keys make address %s", made)
+	}
+	return nil
+}
+
+// Format reads and writes records in one format.
+type Format struct {
+	Read  func(r io.Reader) ([]*Record, error)
+	Write func(w io.Writer, records []*Record) error
+}
+
+// formats are the formats which can be read and written, by name.
+var formats = map[string]*Format{
+	"wif":      {ReadWIF, WriteWIF},
+	"keys.dat": {ReadKeysDat, WriteKeysDat},
+	"json":     {ReadJSON, WriteJSON},
+}
+
+// Lookup returns the format with the given name.
+func Lookup(name string) (*Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return f, nil
+}
+
+// ReadWIF reads records with one identity per line. Blank lines and lines
+// starting with # are skipped.
+func ReadWIF(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 fields, got %d",
+				line, len(fields))
+		}
+		records = append(records, &Record{
+			Address:       fields[0],
+			SigningKey:    fields[1],
+			EncryptionKey: fields[2],
+		})
+	}
+	return records, scanner.Err()
+}
+
+// WriteWIF writes records with one identity per line.
+func WriteWIF(w io.Writer, records []*Record) error {
+	for _, r := range records {
+		_, err := fmt.Fprintf(w, "%s %s %s\n", r.Address, r.SigningKey,
+			r.EncryptionKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadKeysDat reads records from a keys.dat file. Sections which are not
+// named after an address, such as bitmessagesettings, are skipped.
+func ReadKeysDat(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	var current *Record
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			current = nil
+			section := text[1 : len(text)-1]
+			if strings.HasPrefix(section, "BM-") {
+				current = &Record{Address: section}
+				records = append(records, current)
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		i := strings.Index(text, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key := strings.TrimSpace(text[:i])
+		value := strings.TrimSpace(text[i+1:])
+
+		var err error
+		switch key {
+		case "label":
+			current.Label = value
+		case "privsigningkey":
+			current.SigningKey = value
+		case "privencryptionkey":
+			current.EncryptionKey = value
+		case "noncetrialsperbyte":
+			current.NonceTrials, err = strconv.ParseUint(value, 10, 64)
+		case "payloadlengthextrabytes":
+			current.ExtraBytes, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// WriteKeysDat writes records as the sections of a keys.dat file.
+func WriteKeysDat(w io.Writer, records []*Record) error {
+	for i, r := range records {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		lines := []string{
+			"[" + r.Address + "]",
+			"label = " + r.Label,
+			"enabled = true",
+			"decoy = false",
+		}
+		if r.NonceTrials != 0 {
+			lines = append(lines, fmt.Sprintf("noncetrialsperbyte = %d", r.NonceTrials))
+		}
+		if r.ExtraBytes != 0 {
+			lines = append(lines, fmt.Sprintf("payloadlengthextrabytes = %d", r.ExtraBytes))
+		}
+		lines = append(lines,
+			"privsigningkey = "+r.SigningKey,
+			"privencryptionkey = "+r.EncryptionKey)
+
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads records from a JSON array.
+func ReadJSON(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// WriteJSON writes records as a JSON array.
+func WriteJSON(w io.Writer, records []*Record) error {
+	if records == nil {
+		records = []*Record{}
+	}
+	b, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// Names returns the names of the formats in order.
+func Names() string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package keyfile_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/cmd/internal/keyfile"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// records returns the records of n identities made from a passphrase.
+func records(t *testing.T, passphrase string, n int) []*keyfile.Record {
+	keys, err := identity.NewDeterministic(passphrase, 1, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]*keyfile.Record, n)
+	for i, key := range keys {
+		records[i] = keyfile.NewRecord(identity.NewPrivateAddress(key, 4, 1),
+			"label")
+	}
+	return records
+}
+
+func TestKeysDat(t *testing.T) {
+	r := records(t, "keys.dat", 1)[0]
+	in := "[bitmessagesettings]\nport = 8444\n\n" +
+		"[" + r.Address + "]\n" +
+		"label = friends\n" +
+		"enabled = true\n" +
+		"noncetrialsperbyte = 1000\n" +
+		"privsigningkey = " + r.SigningKey + "\n" +
+		"privencryptionkey = " + r.EncryptionKey + "\n"
+
+	read, err := keyfile.ReadKeysDat(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(read))
+	}
+	expected := *r
+	expected.Label = "friends"
+	expected.NonceTrials = 1000
+	if !reflect.DeepEqual(read[0], &expected) {
+		t.Errorf("expected %+v, got %+v", expected, read[0])
+	}
+}
+
+func TestFormats(t *testing.T) {
+	in := records(t, "formats", 2)
+	in[1].NonceTrials = 2000
+	in[1].ExtraBytes = 3000
+
+	for _, name := range []string{"keys.dat", "json"} {
+		f, err := keyfile.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := f.Write(&b, in); err != nil {
+			t.Fatal(err)
+		}
+		out, err := f.Read(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %v, got %v", name, in, out)
+		}
+	}
+
+	// Only the address and keys are written in wif format.
+	var b bytes.Buffer
+	if err := keyfile.WriteWIF(&b, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := keyfile.ReadWIF(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if out[i].Address != in[i].Address ||
+			out[i].SigningKey != in[i].SigningKey ||
+			out[i].EncryptionKey != in[i].EncryptionKey {
+			t.Errorf("wif: expected %v, got %v", in[i], out[i])
+		}
+	}
+
+	if _, err := keyfile.Lookup("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	rs := records(t, "verify", 2)
+	if err := rs[0].Verify(); err != nil {
+		t.Errorf("valid record failed: %v", err)
+	}
+
+	// The keys of one identity with the address of another.
+	bad := *rs[0]
+	bad.EncryptionKey = rs[1].EncryptionKey
+	err := bad.Verify()
+	if err == nil || !strings.Contains(err.Error(), "keys make address") {
+		t.Errorf("expected the address made by the keys, got %v", err)
+	}
+
+	bad = *rs[0]
+	bad.SigningKey = "not a key"
+	if err := bad.Verify(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	in := records(t, "encrypted", 2)
+
+	var b bytes.Buffer
+	if err := keyfile.Encrypt(&b, in, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), in[0].SigningKey) {
+		t.Fatal("keys were written in the clear")
+	}
+	encrypted := b.String()
+
+	out, err := keyfile.EncryptedFormat("secret").Read(strings.NewReader(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %v, got %v", in, out)
+	}
+
+	_, err = keyfile.Decrypt(strings.NewReader(encrypted), "wrong")
+	if err != keyfile.ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+)
+
+const (
+	// DefaultBanThreshold is the score at which a peer is banned.
+	DefaultBanThreshold = 100
+
+	// DefaultBanDuration is the time for which a peer is banned.
+	DefaultBanDuration = 24 * time.Hour
+
+	// DefaultBanHalfLife is the time in which a peer's score falls by
+	// half, so that occasional mistakes are forgiven.
+	DefaultBanHalfLife = 10 * time.Minute
+
+	// banVersion is the current version of the on-disk format.
+	banVersion = 1
+)
+
+var (
+	// ErrBanned is returned when a connection is refused, or a peer is
+	// disconnected, because the peer is banned.
+	ErrBanned = errors.New("peer is banned")
+
+	// ErrUnknownBanVersion is returned when a saved ban list has a format
+	// which is not understood.
+	ErrUnknownBanVersion = errors.New("unknown version of saved ban list")
+)
+
+// Misbehavior is a kind of bad behavior by a peer which adds to its ban
+// score.
+type Misbehavior uint8
+
+// The kinds of misbehavior that a BanManager knows.
+const (
+	// MisbehaviorBadPow is an object with insufficient proof of work.
+	MisbehaviorBadPow Misbehavior = iota
+
+	// MisbehaviorMalformed is a message which could not be decoded.
+	MisbehaviorMalformed
+
+	// MisbehaviorOversized is a message or object larger than allowed.
+	MisbehaviorOversized
+
+	// MisbehaviorProtocol is any other violation of the protocol, such
+	// as messages sent out of order.
+	MisbehaviorProtocol
+)
+
+// Map of misbehaviors back to their constant names for pretty printing.
+var misbehaviorStrings = map[Misbehavior]string{
+	MisbehaviorBadPow:    "MisbehaviorBadPow",
+	MisbehaviorMalformed: "MisbehaviorMalformed",
+	MisbehaviorOversized: "MisbehaviorOversized",
+	MisbehaviorProtocol:  "MisbehaviorProtocol",
+}
+
+// String returns the Misbehavior in human-readable form.
+func (m Misbehavior) String() string {
+	if s, ok := misbehaviorStrings[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Misbehavior (%d)", uint8(m))
+}
+
+// DefaultBanScores is the score added for each kind of misbehavior if none
+// is given in the BanConfig.
+var DefaultBanScores = map[Misbehavior]uint32{
+	MisbehaviorBadPow:    50,
+	MisbehaviorMalformed: 20,
+	MisbehaviorOversized: 50,
+	MisbehaviorProtocol:  100,
+}
+
+// BanConfig is the configuration of a BanManager. Zero values are replaced
+// by the defaults.
+type BanConfig struct {
+	// Threshold is the score at which a peer is banned. The default is
+	// DefaultBanThreshold.
+	Threshold uint32
+
+	// Duration is the time for which a peer is banned. The default is
+	// DefaultBanDuration.
+	Duration time.Duration
+
+	// HalfLife is the time in which a peer's score falls by half. The
+	// default is DefaultBanHalfLife.
+	HalfLife time.Duration
+
+	// Scores is the score added for each kind of misbehavior. Kinds
+	// which are not in the map use DefaultBanScores.
+	Scores map[Misbehavior]uint32
+
+	// Path, if not empty, is the file in which Save and Load keep the
+	// bans. Scores are not saved.
+	Path string
+
+	// Clock tells the time by which scores decay and bans end. The
+	// default is clock.System.
+	Clock clock.Clock
+}
+
+// banScore is the decaying score of a peer.
+type banScore struct {
+	score   float64
+	updated time.Time
+}
+
+// BanManager keeps a score of the misbehavior of each peer and bans those
+// whose score reaches a threshold for a while. Scores decay over time.
+// Peers are identified by host, so that a peer cannot escape a ban by
+// connecting from another port. It is safe for concurrent use.
+type BanManager struct {
+	cfg BanConfig
+	now func() time.Time
+
+	mtx    sync.Mutex
+	scores map[string]*banScore
+	bans   map[string]time.Time
+
+	// pruned is when the BanManager was last pruned. Misbehaved prunes it
+	// once every half life, so that the scores of peers which misbehaved
+	// once do not pile up.
+	pruned time.Time
+}
+
+// serializedBans is the on-disk form of a BanManager. The ends of the bans
+// are stored as unix times.
+type serializedBans struct {
+	Version int
+	Bans    map[string]int64
+}
+
+// NewBanManager returns a BanManager with the given configuration, which
+// may be nil.
+func NewBanManager(cfg *BanConfig) *BanManager {
+	b := &BanManager{
+		scores: make(map[string]*banScore),
+		bans:   make(map[string]time.Time),
+	}
+	if cfg != nil {
+		b.cfg = *cfg
+	}
+	b.now = clock.Or(b.cfg.Clock).Now
+	if b.cfg.Threshold == 0 {
+		b.cfg.Threshold = DefaultBanThreshold
+	}
+	if b.cfg.Duration == 0 {
+		b.cfg.Duration = DefaultBanDuration
+	}
+	if b.cfg.HalfLife == 0 {
+		b.cfg.HalfLife = DefaultBanHalfLife
+	}
+	return b
+}
+
+// banHost returns the host of an address, which identifies a peer.
+func banHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// decayed returns the score s as it is at time now.
+func (b *BanManager) decayed(s *banScore, now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.score
+	}
+	return s.score * math.Exp2(-float64(elapsed)/float64(b.cfg.HalfLife))
+}
+
+// Misbehaved adds the score of the misbehavior to the peer at addr and
+// bans it if the score reaches the threshold. It returns whether the peer
+// is banned.
+func (b *BanManager) Misbehaved(addr net.Addr, m Misbehavior) bool {
+	points, ok := b.cfg.Scores[m]
+	if !ok {
+		points = DefaultBanScores[m]
+	}
+
+	host := banHost(addr)
+	now := b.now()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if now.Sub(b.pruned) >= b.cfg.HalfLife {
+		b.prune(now)
+	}
+
+	if b.banned(host, now) {
+		return true
+	}
+
+	s, ok := b.scores[host]
+	if !ok {
+		s = &banScore{}
+		b.scores[host] = s
+	}
+	s.score = b.decayed(s, now) + float64(points)
+	s.updated = now
+
+	if s.score < float64(b.cfg.Threshold) {
+		return false
+	}
+	delete(b.scores, host)
+	b.bans[host] = now.Add(b.cfg.Duration)
+	return true
+}
+
+// Score returns the current score of the peer at addr.
+func (b *BanManager) Score(addr net.Addr) uint32 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	s, ok := b.scores[banHost(addr)]
+	if !ok {
+		return 0
+	}
+	return uint32(b.decayed(s, b.now()))
+}
+
+// Ban bans the peer at addr for the given time, or for the configured
+// duration if d is zero.
+func (b *BanManager) Ban(addr net.Addr, d time.Duration) {
+	if d == 0 {
+		d = b.cfg.Duration
+	}
+	host := banHost(addr)
+
+	b.mtx.Lock()
+	delete(b.scores, host)
+	b.bans[host] = b.now().Add(d)
+	b.mtx.Unlock()
+}
+
+// Unban lifts any ban on the peer at addr and resets its score.
+func (b *BanManager) Unban(addr net.Addr) {
+	host := banHost(addr)
+
+	b.mtx.Lock()
+	delete(b.scores, host)
+	delete(b.bans, host)
+	b.mtx.Unlock()
+}
+
+// IsBanned returns whether the peer at addr is banned.
+func (b *BanManager) IsBanned(addr net.Addr) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.banned(banHost(addr), b.now())
+}
+
+// banned returns whether the host is banned at time now, removing the ban
+// if it has ended. The mutex must be held.
+func (b *BanManager) banned(host string, now time.Time) bool {
+	until, ok := b.bans[host]
+	if !ok {
+		return false
+	}
+	if now.Before(until) {
+		return true
+	}
+	delete(b.bans, host)
+	return false
+}
+
+// Prune forgets bans which have ended and scores which have decayed to
+// nothing, and returns the number of peers forgotten. Misbehaved also does
+// so once every half life.
+func (b *BanManager) Prune() int {
+	now := b.now()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.prune(now)
+}
+
+// prune is Prune with the mutex held.
+func (b *BanManager) prune(now time.Time) int {
+	b.pruned = now
+	n := 0
+	for host := range b.bans {
+		if !b.banned(host, now) {
+			n++
+		}
+	}
+	for host, s := range b.scores {
+		if b.decayed(s, now) < 1 {
+			delete(b.scores, host)
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the bans which have not ended to the file given in the
+// BanConfig. It does nothing if there is no file.
+func (b *BanManager) Save() error {
+	if b.cfg.Path == "" {
+		return nil
+	}
+	now := b.now()
+
+	b.mtx.Lock()
+	sb := &serializedBans{
+		Version: banVersion,
+		Bans:    make(map[string]int64, len(b.bans)),
+	}
+	for host, until := range b.bans {
+		if now.Before(until) {
+			sb.Bans[host] = until.Unix()
+		}
+	}
+	b.mtx.Unlock()
+
+	// Write to a temporary file first so that a crash cannot leave a
+	// partially written file.
+	tmp := b.cfg.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(f).Encode(sb); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.cfg.Path)
+}
+
+// Load reads the bans from the file given in the BanConfig and adds those
+// which have not ended. It is not an error for the file not to exist, or
+// for there to be no file.
+func (b *BanManager) Load() error {
+	if b.cfg.Path == "" {
+		return nil
+	}
+
+	f, err := os.Open(b.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sb serializedBans
+	if err = json.NewDecoder(f).Decode(&sb); err != nil {
+		return err
+	}
+	if sb.Version != banVersion {
+		return ErrUnknownBanVersion
+	}
+
+	now := b.now()
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for host, unix := range sb.Bans {
+		until := time.Unix(unix, 0)
+		if now.Before(until) && until.After(b.bans[host]) {
+			b.bans[host] = until
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/connmgr"
+	"github.com/DanielKrawisz/bmutil/peer"
+)
+
+// tcpAddr returns a TCP address on the given IPv4 host and port.
+func tcpAddr(host string, port int) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(host), Port: port}
+}
+
+// TestBanManager tests that scores add up to a ban, decay over time and
+// that bans end.
+func TestBanManager(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	b := connmgr.NewBanManager(&connmgr.BanConfig{
+		HalfLife: time.Minute,
+		Duration: time.Hour,
+		Clock:    clock.Func(func() time.Time { return now }),
+	})
+
+	a := tcpAddr("10.0.0.1", 8444)
+	if b.Misbehaved(a, connmgr.MisbehaviorMalformed) {
+		t.Fatal("banned after one malformed message")
+	}
+	if got := b.Score(a); got != 20 {
+		t.Errorf("Score: got %d want 20", got)
+	}
+
+	// The score halves after the half-life.
+	now = now.Add(time.Minute)
+	if got := b.Score(a); got != 10 {
+		t.Errorf("Score after decay: got %d want 10", got)
+	}
+
+	// The same host on another port shares the score.
+	other := tcpAddr("10.0.0.1", 9000)
+	if b.Misbehaved(other, connmgr.MisbehaviorBadPow) {
+		t.Fatal("banned below the threshold")
+	}
+	if !b.Misbehaved(a, connmgr.MisbehaviorBadPow) {
+		t.Fatal("not banned at the threshold")
+	}
+	if !b.IsBanned(other) {
+		t.Error("ban does not cover another port")
+	}
+	if b.IsBanned(tcpAddr("10.0.0.2", 8444)) {
+		t.Error("another host is banned")
+	}
+
+	// The ban ends after its duration.
+	now = now.Add(time.Hour)
+	if b.IsBanned(a) {
+		t.Error("ban did not end")
+	}
+	if got := b.Score(a); got != 0 {
+		t.Errorf("Score after ban: got %d want 0", got)
+	}
+
+	// Explicit bans.
+	b.Ban(a, 0)
+	if !b.IsBanned(a) {
+		t.Error("Ban did not ban")
+	}
+	b.Unban(a)
+	if b.IsBanned(a) {
+		t.Error("Unban did not unban")
+	}
+
+	// Configured scores replace the defaults.
+	b = connmgr.NewBanManager(&connmgr.BanConfig{
+		Scores: map[connmgr.Misbehavior]uint32{
+			connmgr.MisbehaviorProtocol: 1,
+		},
+	})
+	if b.Misbehaved(a, connmgr.MisbehaviorProtocol) {
+		t.Error("configured score not used")
+	}
+}
+
+// TestBanManagerPrune tests that Prune forgets ended bans and decayed
+// scores.
+func TestBanManagerPrune(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	b := connmgr.NewBanManager(&connmgr.BanConfig{
+		HalfLife: time.Minute,
+		Duration: time.Hour,
+		Clock:    clock.Func(func() time.Time { return now }),
+	})
+
+	b.Ban(tcpAddr("10.0.0.1", 8444), 0)
+	b.Ban(tcpAddr("10.0.0.2", 8444), 3*time.Hour)
+	b.Misbehaved(tcpAddr("10.0.0.3", 8444), connmgr.MisbehaviorMalformed)
+
+	if n := b.Prune(); n != 0 {
+		t.Errorf("Prune: got %d want 0", n)
+	}
+	now = now.Add(2 * time.Hour)
+	if n := b.Prune(); n != 2 {
+		t.Errorf("Prune: got %d want 2", n)
+	}
+	if !b.IsBanned(tcpAddr("10.0.0.2", 8444)) {
+		t.Error("longer ban was pruned")
+	}
+}
+
+// TestBanManagerPruneMisbehaved tests that Misbehaved prunes the scores of
+// other peers once every half life.
+func TestBanManagerPruneMisbehaved(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	b := connmgr.NewBanManager(&connmgr.BanConfig{
+		HalfLife: time.Minute,
+		Clock:    clock.Func(func() time.Time { return now }),
+	})
+
+	b.Misbehaved(tcpAddr("10.0.0.1", 8444), connmgr.MisbehaviorMalformed)
+	now = now.Add(time.Hour)
+	b.Misbehaved(tcpAddr("10.0.0.2", 8444), connmgr.MisbehaviorMalformed)
+
+	// Only the score of the peer which misbehaved recently is left.
+	now = now.Add(time.Hour)
+	if n := b.Prune(); n != 1 {
+		t.Errorf("Prune: got %d want 1", n)
+	}
+}
+
+// TestBanManagerSave tests that bans survive being saved and loaded.
+func TestBanManagerSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bans.json")
+
+	now := time.Now()
+	b := connmgr.NewBanManager(&connmgr.BanConfig{Path: path,
+		Clock: clock.Func(func() time.Time { return now })})
+	b.Ban(tcpAddr("10.0.0.1", 8444), time.Hour)
+	b.Ban(tcpAddr("10.0.0.2", 8444), 3*time.Hour)
+	b.Misbehaved(tcpAddr("10.0.0.3", 8444), connmgr.MisbehaviorMalformed)
+	if err = b.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := connmgr.NewBanManager(&connmgr.BanConfig{Path: path,
+		Clock: clock.NewManual(now.Add(2 * time.Hour))})
+	if err = loaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.IsBanned(tcpAddr("10.0.0.1", 8444)) {
+		t.Error("ended ban was loaded")
+	}
+	if !loaded.IsBanned(tcpAddr("10.0.0.2", 8444)) {
+		t.Error("ban was not loaded")
+	}
+	if loaded.Score(tcpAddr("10.0.0.3", 8444)) != 0 {
+		t.Error("score was saved")
+	}
+
+	// A missing file is not an error.
+	missing := connmgr.NewBanManager(&connmgr.BanConfig{
+		Path: filepath.Join(dir, "missing.json"),
+	})
+	if err = missing.Load(); err != nil {
+		t.Errorf("Load of missing file: %v", err)
+	}
+}
+
+// TestConnManagerBans tests that the connection manager does not connect
+// to banned peers and disconnects peers which are banned.
+func TestConnManagerBans(t *testing.T) {
+	l, accepted := listen(t)
+	defer l.Close()
+
+	bans := connmgr.NewBanManager(nil)
+	connected := make(chan *connmgr.ConnReq, 1)
+	disconnected := make(chan error, 1)
+	cm, err := connmgr.New(&connmgr.Config{
+		PeerConfig: peerConfig,
+		BanManager: bans,
+		OnConnection: func(c *connmgr.ConnReq, p *peer.Peer) {
+			connected <- c
+		},
+		OnDisconnection: func(c *connmgr.ConnReq, err error) {
+			disconnected <- err
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer cm.Stop()
+
+	req := &connmgr.ConnReq{Addr: l.Addr(), Stream: 1}
+	if err = cm.Connect(req); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for connection")
+	}
+	<-accepted
+
+	if cm.Misbehaved(req.ID(), connmgr.MisbehaviorMalformed) {
+		t.Fatal("banned below the threshold")
+	}
+	if !cm.Misbehaved(req.ID(), connmgr.MisbehaviorProtocol) {
+		t.Fatal("not banned at the threshold")
+	}
+	select {
+	case err = <-disconnected:
+		if err != connmgr.ErrBanned {
+			t.Errorf("got %v want %v", err, connmgr.ErrBanned)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disconnection")
+	}
+
+	// A banned peer is not dialed.
+	req = &connmgr.ConnReq{Addr: l.Addr(), Stream: 1}
+	if err = cm.Connect(req); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	select {
+	case <-connected:
+		t.Fatal("connected to a banned peer")
+	case <-accepted:
+		t.Fatal("dialed a banned peer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if req.State() != connmgr.ConnFailed {
+		t.Errorf("got state %v want %v", req.State(), connmgr.ConnFailed)
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// defaultDialTimeout is the time allowed for a connection to be
+	// established if none is given in the Config.
+	defaultDialTimeout = 30 * time.Second
+
+	// defaultRetryDuration is the base duration to wait before retrying a
+	// connection if none is given in the Config.
+	defaultRetryDuration = 5 * time.Second
+
+	// defaultMaxRetryDuration is the longest duration to wait before
+	// retrying a connection if none is given in the Config.
+	defaultMaxRetryDuration = 5 * time.Minute
+)
+
+var (
+	// ErrDialTimeout is returned when a connection could not be
+	// established within the dial timeout.
+	ErrDialTimeout = errors.New("dial timed out")
+
+	// ErrStopped is returned when a request is made of a connection
+	// manager that has been stopped.
+	ErrStopped = errors.New("connection manager stopped")
+)
+
+// ConnState represents the state of the requested connection.
+type ConnState uint8
+
+// ConnState can be either pending, established, disconnected or failed.
+// When a new connection is requested, it is attempted and categorized as
+// established or failed depending on the connection result. An established
+// connection which was disconnected is categorized as disconnected.
+const (
+	ConnPending ConnState = iota
+	ConnEstablished
+	ConnDisconnected
+	ConnFailed
+)
+
+// Map of connection states back to their constant names for pretty
+// printing.
+var csStrings = map[ConnState]string{
+	ConnPending:      "ConnPending",
+	ConnEstablished:  "ConnEstablished",
+	ConnDisconnected: "ConnDisconnected",
+	ConnFailed:       "ConnFailed",
+}
+
+// String returns the ConnState in human-readable form.
+func (s ConnState) String() string {
+	if str, ok := csStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown ConnState (%d)", uint8(s))
+}
+
+// ConnReq is a request to connect to a peer in a stream.
+type ConnReq struct {
+	// The following variables must only be used atomically.
+	id uint64
+
+	Addr      net.Addr
+	Stream    uint32
+	Permanent bool
+
+	// automatic is set for the requests made by the connection manager
+	// to maintain TargetOutbound, which are replaced when they end.
+	automatic bool
+
+	mtx        sync.Mutex
+	state      ConnState
+	peer       *peer.Peer
+	retryCount uint32
+}
+
+// ID returns a unique identifier for the connection request.
+func (c *ConnReq) ID() uint64 {
+	return atomic.LoadUint64(&c.id)
+}
+
+// State returns the connection state of the request.
+func (c *ConnReq) State() ConnState {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.state
+}
+
+// Peer returns the peer of an established connection, or nil.
+func (c *ConnReq) Peer() *peer.Peer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.peer
+}
+
+// String returns a human-readable string for the connection request.
+func (c *ConnReq) String() string {
+	if c.Addr == nil {
+		return fmt.Sprintf("reqid %d", c.ID())
+	}
+	return fmt.Sprintf("%s (reqid %d)", c.Addr, c.ID())
+}
+
+// updateState sets the state and peer of the request.
+func (c *ConnReq) updateState(state ConnState, p *peer.Peer) {
+	c.mtx.Lock()
+	c.state = state
+	c.peer = p
+	c.mtx.Unlock()
+}
+
+// Config holds the configuration options related to the connection
+// manager.
+type Config struct {
+	// PeerConfig is the configuration of the peers that are created.
+	PeerConfig *peer.Config
+
+	// Streams are the streams in which outbound connections are made.
+	Streams []uint32
+
+	// TargetOutbound is the number of outbound connections to maintain
+	// in each stream. It is only used if GetNewAddress is not nil.
+	TargetOutbound uint32
+
+	// GetNewAddress returns the address of a peer in the given stream
+	// to connect to. If it is nil, no connections are made automatically.
+	GetNewAddress func(stream uint32) (net.Addr, error)
+
+	// Dial connects to the address. If it is nil, a TCP connection is
+	// made.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// DialTimeout is the time allowed for Dial to return. If zero, a
+	// default is used.
+	DialTimeout time.Duration
+
+	// RetryDuration is the duration to wait before retrying a connection
+	// to a permanent peer. The duration doubles with every failure up to
+	// MaxRetryDuration. It is also the time to wait before asking for a
+	// new address after a failure. If zero, defaults are used.
+	RetryDuration    time.Duration
+	MaxRetryDuration time.Duration
+
+	// OnConnection is called when a connection has been established and
+	// the peer has completed the handshake.
+	OnConnection func(*ConnReq, *peer.Peer)
+
+	// OnDisconnection is called when an established connection is lost.
+	// err is the reason the peer was disconnected.
+	OnDisconnection func(c *ConnReq, err error)
+
+	// BanManager, if not nil, scores the misbehavior of peers. Banned
+	// peers are not connected to, and peers which violate the protocol
+	// are scored automatically.
+	BanManager *BanManager
+
+	// Logger, if not nil, is told of failed connections and of peers
+	// which are banned.
+	Logger logging.Logger
+}
+
+// ConnManager provides a manager to handle network connections.
+type ConnManager struct {
+	// The following variables must only be used atomically.
+	connReqCount uint64
+
+	cfg Config
+	log logging.Logger
+
+	mtx   sync.Mutex
+	conns map[uint64]*ConnReq
+
+	wg   sync.WaitGroup
+	stop sync.Once
+	quit chan struct{}
+}
+
+// New returns a new connection manager.
+func New(cfg *Config) (*ConnManager, error) {
+	if cfg.PeerConfig == nil {
+		return nil, errors.New("connmgr: PeerConfig is required")
+	}
+
+	cm := &ConnManager{
+		cfg:   *cfg,
+		log:   logging.Or(cfg.Logger),
+		conns: make(map[uint64]*ConnReq),
+		quit:  make(chan struct{}),
+	}
+	if cm.cfg.Dial == nil {
+		cm.cfg.Dial = func(addr net.Addr) (net.Conn, error) {
+			return net.Dial(addr.Network(), addr.String())
+		}
+	}
+	if cm.cfg.DialTimeout == 0 {
+		cm.cfg.DialTimeout = defaultDialTimeout
+	}
+	if cm.cfg.RetryDuration == 0 {
+		cm.cfg.RetryDuration = defaultRetryDuration
+	}
+	if cm.cfg.MaxRetryDuration == 0 {
+		cm.cfg.MaxRetryDuration = defaultMaxRetryDuration
+	}
+	return cm, nil
+}
+
+// Start launches the connection manager and begins connecting to peers in
+// each stream.
+func (cm *ConnManager) Start() {
+	if cm.cfg.GetNewAddress == nil {
+		return
+	}
+	for _, stream := range cm.cfg.Streams {
+		for i := uint32(0); i < cm.cfg.TargetOutbound; i++ {
+			cm.wg.Add(1)
+			go cm.newConnReq(stream)
+		}
+	}
+}
+
+// Stop disconnects every peer and waits for the connection manager to
+// finish.
+func (cm *ConnManager) Stop() {
+	cm.stop.Do(func() {
+		close(cm.quit)
+		cm.mtx.Lock()
+		for _, c := range cm.conns {
+			if p := c.Peer(); p != nil {
+				p.Disconnect(ErrStopped)
+			}
+		}
+		cm.mtx.Unlock()
+	})
+	cm.wg.Wait()
+}
+
+// wait waits for d unless the connection manager is stopped first, in
+// which case it returns false.
+func (cm *ConnManager) wait(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-cm.quit:
+		return false
+	}
+}
+
+// register assigns an id to the request and adds it to the set of
+// requests.
+func (cm *ConnManager) register(c *ConnReq) {
+	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+	cm.mtx.Lock()
+	cm.conns[c.ID()] = c
+	cm.mtx.Unlock()
+}
+
+// remove removes the request from the set of requests.
+func (cm *ConnManager) remove(c *ConnReq) {
+	cm.mtx.Lock()
+	delete(cm.conns, c.ID())
+	cm.mtx.Unlock()
+}
+
+// newConnReq asks for a new address in the stream and connects to it. It
+// must be run as a goroutine after incrementing the wait group.
+func (cm *ConnManager) newConnReq(stream uint32) {
+	defer cm.wg.Done()
+
+	for {
+		select {
+		case <-cm.quit:
+			return
+		default:
+		}
+
+		addr, err := cm.cfg.GetNewAddress(stream)
+		if err == nil {
+			c := &ConnReq{Addr: addr, Stream: stream, automatic: true}
+			cm.register(c)
+			cm.wg.Add(1)
+			go cm.connect(c)
+			return
+		}
+
+		if !cm.wait(cm.cfg.RetryDuration) {
+			return
+		}
+	}
+}
+
+// Connect connects to the address in the request. Permanent requests are
+// retried with backoff until they are removed. Other requests are tried
+// once, and are not counted towards TargetOutbound.
+func (cm *ConnManager) Connect(c *ConnReq) error {
+	select {
+	case <-cm.quit:
+		return ErrStopped
+	default:
+	}
+
+	cm.register(c)
+	cm.wg.Add(1)
+	go cm.connect(c)
+	return nil
+}
+
+// Disconnect disconnects the peer of the request with the given id. A
+// permanent request will be retried; use Remove to stop that.
+func (cm *ConnManager) Disconnect(id uint64) {
+	cm.mtx.Lock()
+	c, ok := cm.conns[id]
+	cm.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	if p := c.Peer(); p != nil {
+		p.Disconnect(nil)
+	}
+}
+
+// Remove disconnects the peer of the request with the given id and
+// forgets the request so that it will not be retried.
+func (cm *ConnManager) Remove(id uint64) {
+	cm.mtx.Lock()
+	c, ok := cm.conns[id]
+	delete(cm.conns, id)
+	cm.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	if p := c.Peer(); p != nil {
+		p.Disconnect(nil)
+	}
+}
+
+// Misbehaved reports misbehavior by the peer of the request with the given
+// id, such as an object with insufficient proof of work, to the
+// BanManager. If the peer is banned as a result, it is disconnected with
+// ErrBanned. It returns whether the peer is banned, and does nothing if
+// there is no BanManager.
+func (cm *ConnManager) Misbehaved(id uint64, m Misbehavior) bool {
+	if cm.cfg.BanManager == nil {
+		return false
+	}
+
+	cm.mtx.Lock()
+	c, ok := cm.conns[id]
+	cm.mtx.Unlock()
+	if !ok {
+		return false
+	}
+
+	if !cm.cfg.BanManager.Misbehaved(c.Addr, m) {
+		return false
+	}
+	cm.log.Log(logging.Warn, "peer banned", "addr", c.Addr,
+		"misbehavior", m)
+	if p := c.Peer(); p != nil {
+		p.Disconnect(ErrBanned)
+	}
+	return true
+}
+
+// scoreError reports an error from a peer to the BanManager if it is a
+// violation of the protocol or a message which could not be read.
+func (cm *ConnManager) scoreError(c *ConnReq, err error) {
+	if cm.cfg.BanManager == nil {
+		return
+	}
+
+	var protocolErr *peer.ProtocolError
+	var messageErr *wire.MessageError
+	switch {
+	case errors.As(err, &protocolErr):
+		cm.cfg.BanManager.Misbehaved(c.Addr, MisbehaviorProtocol)
+	case errors.Is(err, wire.ErrTooLarge):
+		cm.cfg.BanManager.Misbehaved(c.Addr, MisbehaviorOversized)
+	case errors.As(err, &messageErr):
+		cm.cfg.BanManager.Misbehaved(c.Addr, MisbehaviorMalformed)
+	}
+}
+
+// Requests returns the connection requests currently being handled.
+func (cm *ConnManager) Requests() []*ConnReq {
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+
+	reqs := make([]*ConnReq, 0, len(cm.conns))
+	for _, c := range cm.conns {
+		reqs = append(reqs, c)
+	}
+	return reqs
+}
+
+// isRemoved returns whether the request has been removed.
+func (cm *ConnManager) isRemoved(c *ConnReq) bool {
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+	_, ok := cm.conns[c.ID()]
+	return !ok
+}
+
+// dial calls the dialer, giving up after the dial timeout or when the
+// connection manager is stopped.
+func (cm *ConnManager) dial(addr net.Addr) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := cm.cfg.Dial(addr)
+		ch <- result{conn, err}
+	}()
+
+	t := time.NewTimer(cm.cfg.DialTimeout)
+	defer t.Stop()
+	err := ErrDialTimeout
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-t.C:
+	case <-cm.quit:
+		err = ErrStopped
+	}
+
+	// Close the connection if the dialer eventually succeeds.
+	go func() {
+		if r := <-ch; r.conn != nil {
+			r.conn.Close()
+		}
+	}()
+	return nil, err
+}
+
+// backoff returns the duration to wait before retrying a permanent
+// request.
+func (cm *ConnManager) backoff(c *ConnReq) time.Duration {
+	c.mtx.Lock()
+	c.retryCount++
+	retries := c.retryCount
+	c.mtx.Unlock()
+
+	d := cm.cfg.RetryDuration
+	for i := uint32(1); i < retries && d < cm.cfg.MaxRetryDuration; i++ {
+		d *= 2
+	}
+	if d > cm.cfg.MaxRetryDuration {
+		d = cm.cfg.MaxRetryDuration
+	}
+	return d
+}
+
+// connect makes the connection and handshake for the request and then
+// waits for the peer to be disconnected, retrying or replacing the
+// connection as appropriate. It must be run as a goroutine after
+// incrementing the wait group.
+func (cm *ConnManager) connect(c *ConnReq) {
+	defer cm.wg.Done()
+
+	for {
+		c.updateState(ConnPending, nil)
+
+		var conn net.Conn
+		var err error
+		if cm.cfg.BanManager != nil && cm.cfg.BanManager.IsBanned(c.Addr) {
+			err = ErrBanned
+		} else {
+			conn, err = cm.dial(c.Addr)
+		}
+		var p *peer.Peer
+		if err == nil {
+			p, err = peer.NewOutbound(cm.cfg.PeerConfig, conn)
+			cm.scoreError(c, err)
+		}
+
+		if err != nil {
+			c.updateState(ConnFailed, nil)
+			cm.log.Log(logging.Debug, "connection failed", "addr", c.Addr,
+				"err", err)
+		} else if cm.isRemoved(c) {
+			// The request was removed while we were connecting.
+			p.Disconnect(nil)
+			c.updateState(ConnDisconnected, nil)
+			return
+		} else {
+			c.mtx.Lock()
+			c.retryCount = 0
+			c.mtx.Unlock()
+			c.updateState(ConnEstablished, p)
+			if cm.cfg.OnConnection != nil {
+				cm.cfg.OnConnection(c, p)
+			}
+
+			// Stop may have been called before the peer was set.
+			select {
+			case <-p.Done():
+			case <-cm.quit:
+				p.Disconnect(ErrStopped)
+			}
+
+			c.updateState(ConnDisconnected, nil)
+			cm.scoreError(c, p.Err())
+			if cm.cfg.OnDisconnection != nil {
+				cm.cfg.OnDisconnection(c, p.Err())
+			}
+		}
+
+		if cm.isRemoved(c) {
+			return
+		}
+
+		if !c.Permanent {
+			// Only the connection manager's own requests are
+			// replaced, so that failed calls to Connect do not add
+			// to the connections kept for TargetOutbound.
+			cm.remove(c)
+			if !c.automatic || cm.cfg.GetNewAddress == nil {
+				return
+			}
+			if err != nil && !cm.wait(cm.cfg.RetryDuration) {
+				return
+			}
+			cm.wg.Add(1)
+			go cm.newConnReq(c.Stream)
+			return
+		}
+
+		if !cm.wait(cm.backoff(c)) {
+			return
+		}
+	}
+}
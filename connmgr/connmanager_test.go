@@ -0,0 +1,292 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/connmgr"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+var peerConfig = &peer.Config{
+	Net:     wire.MainNet,
+	Streams: []uint32{1},
+}
+
+// respond performs the inbound side of a handshake by hand. The peer
+// package cannot be used on both sides because it would detect a
+// connection to itself.
+func respond(conn net.Conn) {
+	if _, _, err := wire.ReadMessage(conn, wire.MainNet); err != nil {
+		conn.Close()
+		return
+	}
+	na := wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), 8444, 1, 0)
+	wire.WriteMessage(conn, wire.NewMsgVersion(na, na, 1, []uint32{1}),
+		wire.MainNet)
+	wire.WriteMessage(conn, &wire.MsgVerAck{}, wire.MainNet)
+	wire.ReadMessage(conn, wire.MainNet)
+}
+
+// listen starts a listener which responds to handshakes and returns the
+// listener and a channel on which accepted connections are sent.
+func listen(t *testing.T) (net.Listener, chan net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ch := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			respond(conn)
+			ch <- conn
+		}
+	}()
+	return l, ch
+}
+
+// TestTargetOutbound tests that the connection manager makes the target
+// number of connections and replaces those that are lost.
+func TestTargetOutbound(t *testing.T) {
+	l, accepted := listen(t)
+	defer l.Close()
+
+	connected := make(chan *connmgr.ConnReq, 10)
+	disconnected := make(chan *connmgr.ConnReq, 10)
+	cm, err := connmgr.New(&connmgr.Config{
+		PeerConfig:     peerConfig,
+		Streams:        []uint32{1},
+		TargetOutbound: 2,
+		GetNewAddress: func(stream uint32) (net.Addr, error) {
+			return l.Addr(), nil
+		},
+		RetryDuration: time.Millisecond,
+		OnConnection: func(c *connmgr.ConnReq, p *peer.Peer) {
+			connected <- c
+		},
+		OnDisconnection: func(c *connmgr.ConnReq, err error) {
+			disconnected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	cm.Start()
+	defer cm.Stop()
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		select {
+		case c := <-connected:
+			if c.State() != connmgr.ConnEstablished {
+				t.Errorf("State: got %v want %v", c.State(),
+					connmgr.ConnEstablished)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for connection")
+		}
+		conns = append(conns, <-accepted)
+	}
+
+	// Close one connection from the remote side. It should be replaced.
+	conns[0].Close()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disconnection")
+	}
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for replacement connection")
+	}
+}
+
+// TestPermanentRetry tests that a permanent connection is retried with
+// backoff until it succeeds.
+func TestPermanentRetry(t *testing.T) {
+	l, accepted := listen(t)
+	defer l.Close()
+
+	var mtx sync.Mutex
+	var attempts []time.Time
+	failures := 3
+	errDial := errors.New("dial failed")
+
+	connected := make(chan *connmgr.ConnReq, 1)
+	cm, err := connmgr.New(&connmgr.Config{
+		PeerConfig: peerConfig,
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			attempts = append(attempts, time.Now())
+			if len(attempts) <= failures {
+				return nil, errDial
+			}
+			return net.Dial("tcp", addr.String())
+		},
+		RetryDuration:    10 * time.Millisecond,
+		MaxRetryDuration: 25 * time.Millisecond,
+		OnConnection: func(c *connmgr.ConnReq, p *peer.Peer) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer cm.Stop()
+
+	req := &connmgr.ConnReq{Addr: l.Addr(), Stream: 1, Permanent: true}
+	if err = cm.Connect(req); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for connection")
+	}
+	<-accepted
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(attempts) != failures+1 {
+		t.Fatalf("got %d attempts want %d", len(attempts), failures+1)
+	}
+
+	// The waits should have been 10ms, 20ms and then 25ms.
+	want := []time.Duration{10, 20, 25}
+	for i, w := range want {
+		if d := attempts[i+1].Sub(attempts[i]); d < w*time.Millisecond {
+			t.Errorf("wait %d: got %v want at least %v", i, d,
+				w*time.Millisecond)
+		}
+	}
+
+	// Removing the request disconnects the peer.
+	p := req.Peer()
+	cm.Remove(req.ID())
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disconnection")
+	}
+}
+
+// TestDialTimeout tests that a dialer which does not return is abandoned.
+func TestDialTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	failed := make(chan struct{})
+	cm, err := connmgr.New(&connmgr.Config{
+		PeerConfig: peerConfig,
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			<-block
+			return nil, errors.New("unblocked")
+		},
+		DialTimeout:   10 * time.Millisecond,
+		RetryDuration: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := &connmgr.ConnReq{
+		Addr:      &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8444},
+		Stream:    1,
+		Permanent: true,
+	}
+	cm.Connect(req)
+	go func() {
+		for req.State() != connmgr.ConnFailed {
+			time.Sleep(time.Millisecond)
+		}
+		close(failed)
+	}()
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("dial did not time out")
+	}
+	cm.Stop()
+}
+
+// TestConnState tests the stringer of ConnState.
+func TestConnState(t *testing.T) {
+	tests := []struct {
+		in   connmgr.ConnState
+		want string
+	}{
+		{connmgr.ConnPending, "ConnPending"},
+		{connmgr.ConnEstablished, "ConnEstablished"},
+		{connmgr.ConnDisconnected, "ConnDisconnected"},
+		{connmgr.ConnFailed, "ConnFailed"},
+		{0xff, "Unknown ConnState (255)"},
+	}
+
+	for i, test := range tests {
+		if s := test.in.String(); s != test.want {
+			t.Errorf("test #%d: got %s want %s", i, s, test.want)
+		}
+	}
+}
+
+// TestManualConnect tests that a request made with Connect which is not
+// permanent is not replaced when it fails.
+func TestManualConnect(t *testing.T) {
+	var mtx sync.Mutex
+	asked := 0
+	cm, err := connmgr.New(&connmgr.Config{
+		PeerConfig: peerConfig,
+		Streams:    []uint32{1},
+		GetNewAddress: func(stream uint32) (net.Addr, error) {
+			mtx.Lock()
+			asked++
+			mtx.Unlock()
+			return nil, errors.New("no addresses")
+		},
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			return nil, errors.New("dial failed")
+		},
+		RetryDuration: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer cm.Stop()
+
+	req := &connmgr.ConnReq{
+		Addr:   &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8444},
+		Stream: 1,
+	}
+	if err = cm.Connect(req); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	for i := 0; len(cm.Requests()) != 0; i++ {
+		if i == 1000 {
+			t.Fatal("failed request was not removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if asked != 0 {
+		t.Errorf("failed request was replaced: asked for %d addresses", asked)
+	}
+}
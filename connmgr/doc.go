@@ -0,0 +1,24 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package connmgr implements a generic Bitmessage network connection manager.
+
+The connection manager maintains a target number of outbound connections in
+each stream that a node serves. Addresses are obtained from a callback,
+which is usually backed by an address manager. Every connection is dialed
+with a timeout and then handed to the peer package for the handshake. When
+a connection fails or is lost, a new address is requested after a delay.
+
+Connections may also be requested explicitly with Connect. Permanent
+requests are retried with exponential backoff until they are removed.
+
+A BanManager keeps a score of the misbehavior of each peer, such as objects
+with insufficient proof of work or malformed messages, and bans peers whose
+score reaches a threshold for a while. Scores decay over time and bans may
+be saved to a file. A connection manager given a BanManager does not connect
+to banned peers, scores violations of the protocol itself and disconnects
+peers banned through Misbehaved.
+*/
+package connmgr
@@ -0,0 +1,313 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package crawler
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/addrmgr"
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultDialTimeout is the time allowed for a connection to a node
+	// to be made.
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultListenTime is how long the crawler listens for the addresses
+	// which a node advertises after the handshake.
+	DefaultListenTime = 30 * time.Second
+
+	// DefaultMaxConcurrent is the number of nodes which are visited at
+	// once.
+	DefaultMaxConcurrent = 16
+)
+
+// Config is the configuration of a Crawler. Zero values are replaced by the
+// defaults.
+type Config struct {
+	// PeerConfig is the configuration of the peers which visit nodes.
+	// Nodes which serve none of its streams fail the handshake. It must
+	// not be nil.
+	PeerConfig *peer.Config
+
+	// Dial connects to an address. If it is nil, a TCP connection is made
+	// with a timeout of DialTimeout.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// DialTimeout is the time allowed for a connection to be made when
+	// Dial is nil. The default is DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// ListenTime is how long each node is listened to for addresses after
+	// the handshake. The default is DefaultListenTime.
+	ListenTime time.Duration
+
+	// MaxConcurrent is the number of nodes which are visited at once. The
+	// default is DefaultMaxConcurrent.
+	MaxConcurrent int
+
+	// MaxNodes, if not zero, is the most nodes which are visited.
+	MaxNodes int
+
+	// Filter returns whether an address should be crawled. The default is
+	// addrmgr.IsRoutable.
+	Filter func(*wire.NetAddress) bool
+
+	// AddrManager, if not nil, is given the addresses which are learned
+	// and told of the nodes which are reached.
+	AddrManager *addrmgr.AddrManager
+
+	// OnNode, if not nil, is called with each node once it has been
+	// visited. It is called from several goroutines at once and must not
+	// block for long.
+	OnNode func(*Node)
+
+	// Logger, if not nil, is told of each node which is visited.
+	Logger logging.Logger
+}
+
+// Node is what was learned by visiting an address.
+type Node struct {
+	// Addr is the address which was visited.
+	Addr *wire.NetAddress
+
+	// Visited is when the visit started.
+	Visited time.Time
+
+	// Err is why the node could not be reached, or nil if it was.
+	Err error
+
+	// Latency is the time taken to connect and complete the handshake.
+	Latency time.Duration
+
+	// ProtocolVersion, UserAgent, Services and Streams are what the node
+	// advertised in its version message.
+	ProtocolVersion int32
+	UserAgent       string
+	Services        wire.ServiceFlag
+	Streams         []uint32
+
+	// Addresses is the number of addresses which the node advertised.
+	Addresses int
+}
+
+// Reachable returns whether the handshake with the node was completed.
+func (n *Node) Reachable() bool {
+	return n.Err == nil
+}
+
+// Crawler walks the network by visiting the addresses which nodes
+// advertise. It is safe for concurrent use.
+type Crawler struct {
+	cfg Config
+	log logging.Logger
+
+	mtx     sync.Mutex
+	seen    map[string]struct{}
+	queue   []*wire.NetAddress
+	nodes   []*Node
+	started int
+}
+
+// New returns a Crawler with nothing to crawl.
+func New(cfg *Config) *Crawler {
+	c := &Crawler{
+		cfg:  *cfg,
+		log:  logging.Or(cfg.Logger),
+		seen: make(map[string]struct{}),
+	}
+	if c.cfg.DialTimeout == 0 {
+		c.cfg.DialTimeout = DefaultDialTimeout
+	}
+	if c.cfg.Dial == nil {
+		timeout := c.cfg.DialTimeout
+		c.cfg.Dial = func(addr net.Addr) (net.Conn, error) {
+			return net.DialTimeout(addr.Network(), addr.String(), timeout)
+		}
+	}
+	if c.cfg.ListenTime == 0 {
+		c.cfg.ListenTime = DefaultListenTime
+	}
+	if c.cfg.MaxConcurrent == 0 {
+		c.cfg.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if c.cfg.Filter == nil {
+		c.cfg.Filter = addrmgr.IsRoutable
+	}
+	return c
+}
+
+// Add adds addresses to be crawled. Addresses which have been added
+// before, or which the filter rejects, are ignored.
+func (c *Crawler) Add(addrs ...*wire.NetAddress) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.add(addrs)
+}
+
+// add adds addresses to the queue. It must be called with the mutex held.
+func (c *Crawler) add(addrs []*wire.NetAddress) {
+	for _, na := range addrs {
+		if !c.cfg.Filter(na) {
+			continue
+		}
+		k := key(na)
+		if _, ok := c.seen[k]; ok {
+			continue
+		}
+		c.seen[k] = struct{}{}
+		naCopy := *na
+		c.queue = append(c.queue, &naCopy)
+	}
+}
+
+// Crawl visits the queued addresses, and the addresses which the nodes
+// advertise, until there are none left, MaxNodes have been visited or quit
+// is closed. It returns every node visited so far, as Nodes does.
+func (c *Crawler) Crawl(quit <-chan struct{}) []*Node {
+	done := make(chan struct{}, c.cfg.MaxConcurrent)
+	active := 0
+	for {
+		c.mtx.Lock()
+		for active < c.cfg.MaxConcurrent && len(c.queue) > 0 &&
+			(c.cfg.MaxNodes == 0 || c.started < c.cfg.MaxNodes) {
+
+			na := c.queue[0]
+			c.queue[0] = nil
+			c.queue = c.queue[1:]
+			c.started++
+			active++
+			go func() {
+				c.visit(na, quit)
+				done <- struct{}{}
+			}()
+		}
+		c.mtx.Unlock()
+
+		if active == 0 {
+			return c.Nodes()
+		}
+
+		select {
+		case <-done:
+			active--
+		case <-quit:
+			for ; active > 0; active-- {
+				<-done
+			}
+			return c.Nodes()
+		}
+	}
+}
+
+// visit connects to a node, records what it says about itself and queues
+// the addresses which it advertises.
+func (c *Crawler) visit(na *wire.NetAddress, quit <-chan struct{}) {
+	node := &Node{Addr: na, Visited: time.Now()}
+	if c.cfg.AddrManager != nil {
+		c.cfg.AddrManager.Attempt(na)
+	}
+
+	addr := &net.TCPAddr{IP: na.IP, Port: int(na.Port)}
+	conn, err := c.cfg.Dial(addr)
+	var p *peer.Peer
+	if err == nil {
+		p, err = peer.NewOutbound(c.cfg.PeerConfig, conn)
+	}
+	if err != nil {
+		node.Err = err
+		c.record(node)
+		return
+	}
+
+	node.Latency = time.Since(node.Visited)
+	version := p.Version()
+	node.ProtocolVersion = version.ProtocolVersion
+	node.UserAgent = version.UserAgent
+	node.Services = version.Services
+	node.Streams = append([]uint32(nil), version.StreamNumbers...)
+	if c.cfg.AddrManager != nil {
+		c.cfg.AddrManager.Good(na)
+	}
+
+	timer := time.NewTimer(c.cfg.ListenTime)
+	defer timer.Stop()
+listen:
+	for {
+		select {
+		case msg, ok := <-p.In():
+			if !ok {
+				break listen
+			}
+			addrs, ok := msg.(*wire.MsgAddr)
+			if !ok {
+				continue
+			}
+			node.Addresses += len(addrs.AddrList)
+			if c.cfg.AddrManager != nil {
+				c.cfg.AddrManager.AddAddresses(addrs.AddrList, na)
+			}
+			c.mtx.Lock()
+			c.add(addrs.AddrList)
+			c.mtx.Unlock()
+		case <-timer.C:
+			break listen
+		case <-quit:
+			break listen
+		}
+	}
+	p.Disconnect(nil)
+	c.record(node)
+}
+
+// record records a node which has been visited.
+func (c *Crawler) record(node *Node) {
+	if node.Err != nil {
+		c.log.Log(logging.Debug, "node unreachable", "addr", key(node.Addr),
+			"err", node.Err)
+	} else {
+		c.log.Log(logging.Debug, "node visited", "addr", key(node.Addr),
+			"user_agent", node.UserAgent, "addresses", node.Addresses)
+	}
+
+	c.mtx.Lock()
+	c.nodes = append(c.nodes, node)
+	c.mtx.Unlock()
+
+	if c.cfg.OnNode != nil {
+		c.cfg.OnNode(node)
+	}
+}
+
+// Nodes returns the nodes which have been visited, in the order of their
+// addresses.
+func (c *Crawler) Nodes() []*Node {
+	c.mtx.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mtx.Unlock()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return key(nodes[i].Addr) < key(nodes[j].Addr)
+	})
+	return nodes
+}
+
+// Pending returns the number of addresses which are waiting to be visited.
+func (c *Crawler) Pending() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.queue)
+}
+
+// key returns the string which identifies an address.
+func key(na *wire.NetAddress) string {
+	return addrmgr.NetAddressKey(na)
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package crawler_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/crawler"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+var errRefused = errors.New("connection refused")
+
+// node is a remote node in a fake network.
+type node struct {
+	agent string
+	peers []string
+}
+
+// netAddress returns the address of a node in the fake network.
+func netAddress(host string) *wire.NetAddress {
+	return wire.NewNetAddressIPPort(net.ParseIP(host), 8444, 1,
+		wire.SFNodeNetwork)
+}
+
+// dialer returns a Dial function which connects to the nodes of a fake
+// network over in-memory pipes. Each node advertises its peers after the
+// handshake.
+func dialer(network map[string]*node) func(net.Addr) (net.Conn, error) {
+	return func(addr net.Addr) (net.Conn, error) {
+		n, ok := network[addr.(*net.TCPAddr).IP.String()]
+		if !ok {
+			return nil, errRefused
+		}
+
+		a, b := net.Pipe()
+		go func() {
+			p, err := peer.NewInbound(&peer.Config{
+				Net:           wire.MainNet,
+				Streams:       []uint32{1},
+				UserAgentName: n.agent,
+				Nonces:        peer.NewNonceSet(),
+			}, b)
+			if err != nil {
+				return
+			}
+			msg := &wire.MsgAddr{}
+			for _, host := range n.peers {
+				msg.AddAddress(netAddress(host))
+			}
+			p.QueueMessage(msg)
+			for range p.In() {
+			}
+		}()
+		return a, nil
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	network := map[string]*node{
+		"10.0.0.1": {agent: "a", peers: []string{"10.0.0.2", "10.0.0.3"}},
+		"10.0.0.2": {agent: "b", peers: []string{"10.0.0.1", "10.0.0.4"}},
+		"10.0.0.3": {agent: "a"},
+	}
+
+	var visited int32
+	c := crawler.New(&crawler.Config{
+		PeerConfig: &peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+		Dial:       dialer(network),
+		ListenTime: 50 * time.Millisecond,
+		Filter:     func(*wire.NetAddress) bool { return true },
+		OnNode:     func(*crawler.Node) { atomic.AddInt32(&visited, 1) },
+	})
+	c.Add(netAddress("10.0.0.1"))
+
+	nodes := c.Crawl(nil)
+	if len(nodes) != 4 || visited != 4 {
+		t.Fatalf("got %d nodes, %d visited, want 4", len(nodes), visited)
+	}
+	for i, n := range nodes {
+		reachable := i < 3
+		if n.Reachable() != reachable {
+			t.Errorf("%s: Reachable got %v want %v", n.Addr.IP,
+				n.Reachable(), reachable)
+		}
+	}
+	if nodes[3].Err != errRefused {
+		t.Errorf("%s: Err got %v want %v", nodes[3].Addr.IP, nodes[3].Err,
+			errRefused)
+	}
+	if nodes[0].Addresses != 2 || len(nodes[0].Streams) != 1 ||
+		!strings.Contains(nodes[0].UserAgent, "/a:") {
+		t.Errorf("wrong node %+v", nodes[0])
+	}
+
+	s := crawler.Summarize(nodes)
+	if s.Visited != 4 || s.Reachable != 3 || s.Streams[1] != 3 ||
+		len(s.UserAgents) != 2 {
+		t.Errorf("wrong summary %+v", s)
+	}
+	if !strings.HasPrefix(s.String(), "4 nodes visited, 3 reachable\n") {
+		t.Errorf("wrong summary\n%s", s)
+	}
+}
+
+func TestCrawlLimits(t *testing.T) {
+	network := map[string]*node{
+		"10.0.0.1": {agent: "a", peers: []string{"10.0.0.2"}},
+		"10.0.0.2": {agent: "b"},
+	}
+
+	c := crawler.New(&crawler.Config{
+		PeerConfig: &peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+		Dial:       dialer(network),
+		ListenTime: 50 * time.Millisecond,
+		MaxNodes:   1,
+		Filter:     func(*wire.NetAddress) bool { return true },
+	})
+	c.Add(netAddress("10.0.0.1"), netAddress("10.0.0.1"))
+	if nodes := c.Crawl(nil); len(nodes) != 1 {
+		t.Errorf("got %d nodes want 1", len(nodes))
+	}
+	if c.Pending() != 1 {
+		t.Errorf("Pending: got %d want 1", c.Pending())
+	}
+
+	// Addresses which are not routable are left out by default.
+	c = crawler.New(&crawler.Config{
+		PeerConfig: &peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+	})
+	c.Add(netAddress("127.0.0.1"), netAddress("10.0.0.1"))
+	if c.Pending() != 0 {
+		t.Errorf("Pending: got %d want 0", c.Pending())
+	}
+
+	// A crawl which is stopped returns at once.
+	quit := make(chan struct{})
+	close(quit)
+	c = crawler.New(&crawler.Config{
+		PeerConfig: &peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+		Dial:       dialer(network),
+		ListenTime: time.Hour,
+		Filter:     func(*wire.NetAddress) bool { return true },
+	})
+	c.Add(netAddress("10.0.0.1"))
+	if nodes := c.Crawl(quit); len(nodes) > 1 {
+		t.Errorf("got %d nodes want at most 1", len(nodes))
+	}
+}
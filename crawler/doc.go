@@ -0,0 +1,27 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package crawler walks the Bitmessage network by connecting to nodes and
+following the addresses which they advertise, so that tools which measure
+the health of the network can be built on bmutil.
+
+A Crawler starts from the addresses given to Add, such as those returned by
+bootstrap.Seed. It connects to each with a peer, records what the node says
+about itself in its version message, and listens for the addr messages
+which nodes send after the handshake. Every address learned is crawled in
+turn, once, until none are left:
+
+	c := crawler.New(&crawler.Config{PeerConfig: cfg})
+	c.Add(seeds...)
+	nodes := c.Crawl(quit)
+	fmt.Println(crawler.Summarize(nodes))
+
+Nodes which cannot be reached are recorded along with the reason. By
+default only routable addresses are crawled; Filter may be set to crawl
+others, such as onion addresses when the Dial function goes through Tor. If
+an AddrManager is given, the addresses learned and the nodes reached are
+reported to it as well.
+*/
+package crawler
@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Summary counts what the reachable nodes of a crawl advertised.
+type Summary struct {
+	// Visited is the number of nodes which were visited and Reachable the
+	// number with which the handshake was completed.
+	Visited   int
+	Reachable int
+
+	// UserAgents, Versions and Streams count the reachable nodes by user
+	// agent, by protocol version and by each stream which they serve.
+	UserAgents map[string]int
+	Versions   map[int32]int
+	Streams    map[uint32]int
+}
+
+// Summarize counts the nodes of a crawl.
+func Summarize(nodes []*Node) *Summary {
+	s := &Summary{
+		Visited:    len(nodes),
+		UserAgents: make(map[string]int),
+		Versions:   make(map[int32]int),
+		Streams:    make(map[uint32]int),
+	}
+	for _, n := range nodes {
+		if !n.Reachable() {
+			continue
+		}
+		s.Reachable++
+		s.UserAgents[n.UserAgent]++
+		s.Versions[n.ProtocolVersion]++
+		for _, stream := range n.Streams {
+			s.Streams[stream]++
+		}
+	}
+	return s
+}
+
+// String returns the summary in a human-readable form, with the user agents
+// from the most to the least common.
+func (s *Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d nodes visited, %d reachable\n", s.Visited,
+		s.Reachable)
+
+	agents := make([]string, 0, len(s.UserAgents))
+	for agent := range s.UserAgents {
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		ci, cj := s.UserAgents[agents[i]], s.UserAgents[agents[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return agents[i] < agents[j]
+	})
+	for _, agent := range agents {
+		fmt.Fprintf(&b, "%6d %s\n", s.UserAgents[agent], agent)
+	}
+	return b.String()
+}
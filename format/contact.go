@@ -0,0 +1,252 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format/serialize"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// ContactEncoding is the encoding of a ContactCard. It is not one of the
+// encodings which the protocol defines, so a card can only be read by
+// clients which support it.
+const ContactEncoding = 16
+
+var (
+	// ErrAddressMismatch is returned by ContactCard.Verify for an identity
+	// whose address is not that of the card.
+	ErrAddressMismatch = errors.New("Address does not match")
+
+	// ErrFingerprintMismatch is returned by ContactCard.Verify for an
+	// identity whose keys do not have the fingerprint of the card.
+	ErrFingerprintMismatch = errors.New("Fingerprint does not match")
+)
+
+// The properties of a contact card. X-BITMESSAGE and
+// X-BITMESSAGE-FINGERPRINT are extensions to vCard, whose names are allowed
+// to begin with X-.
+const (
+	vcardBegin       = "BEGIN:VCARD"
+	vcardEnd         = "END:VCARD"
+	vcardVersion     = "VERSION:4.0"
+	vcardName        = "FN"
+	vcardEmail       = "EMAIL"
+	vcardNote        = "NOTE"
+	vcardAddress     = "X-BITMESSAGE"
+	vcardFingerprint = "X-BITMESSAGE-FINGERPRINT"
+)
+
+// ContactCard is a message which introduces an identity so that it can be
+// added to the recipient's contacts. Its content is a vCard with the
+// Bitmessage address of the identity and the fingerprint of its keys, so
+// that clients which do not support it can still show it as text.
+type ContactCard struct {
+	// Name is the name of the contact.
+	Name string
+
+	// Address is the Bitmessage address of the contact.
+	Address bmutil.Address
+
+	// Fingerprint is the fingerprint of the keys of the contact, as
+	// returned by identity.PublicKey.Fingerprint, or empty if it is not
+	// known.
+	Fingerprint string
+
+	// Email is the email address of the contact, if any.
+	Email string
+
+	// Note is a free-form note about the contact, if any.
+	Note string
+}
+
+// NewContactCard returns a card for a public identity under the given name.
+func NewContactCard(name string, pub identity.Public) *ContactCard {
+	return &ContactCard{
+		Name:        name,
+		Address:     pub.Address(),
+		Fingerprint: pub.Key().Fingerprint(),
+	}
+}
+
+// Verify returns nil if pub is the identity which the card describes. Once
+// the pubkey of the address on a card has been received, Verify shows
+// whether its keys are those which the sender of the card meant.
+func (c *ContactCard) Verify(pub identity.Public) error {
+	if !bmutil.AddressesEqual(c.Address, pub.Address()) {
+		return ErrAddressMismatch
+	}
+	if c.Fingerprint != "" && c.Fingerprint != pub.Key().Fingerprint() {
+		return ErrFingerprintMismatch
+	}
+	return nil
+}
+
+// Encoding returns the encoding format of the bitmessage.
+func (c *ContactCard) Encoding() uint64 {
+	return ContactEncoding
+}
+
+// Encoding returns the encoding format of the bitmessage.
+func (c *ContactCard) encoding() serialize.Format {
+	return serialize.Format_CONTACT
+}
+
+// vcardEscape escapes a value for a vCard.
+var vcardEscape = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`,
+	"\r\n", `\n`, "\n", `\n`)
+
+// Message returns the raw form of the object payload, which is the card as
+// a vCard.
+func (c *ContactCard) Message() []byte {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(vcardEscape.Replace(value))
+		b.WriteString("\r\n")
+	}
+
+	b.WriteString(vcardBegin + "\r\n" + vcardVersion + "\r\n")
+	line(vcardName, c.Name)
+	if c.Email != "" {
+		line(vcardEmail, c.Email)
+	}
+	if c.Note != "" {
+		line(vcardNote, c.Note)
+	}
+	if c.Address != nil {
+		line(vcardAddress, c.Address.String())
+	}
+	if c.Fingerprint != "" {
+		line(vcardFingerprint, c.Fingerprint)
+	}
+	b.WriteString(vcardEnd + "\r\n")
+	return []byte(b.String())
+}
+
+// vcardUnescape undoes vcardEscape.
+func vcardUnescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// vcardLines splits a vCard into lines and unfolds those which continue on
+// the next line.
+func vcardLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') &&
+			len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// ReadMessage reads the object payload and incorporates it.
+func (c *ContactCard) readMessage(msg []byte) error {
+	card, err := ParseContactCard(string(msg))
+	if err != nil {
+		return err
+	}
+	*c = *card
+	return nil
+}
+
+// ParseContactCard reads a card from a vCard, as written by Message. It
+// ignores the properties which a card does not have, so it also reads the
+// vCards of other programs as long as they have a Bitmessage address. It
+// returns ErrInvalidFormat if s is not such a vCard.
+func ParseContactCard(s string) (*ContactCard, error) {
+	lines := vcardLines(s)
+	if len(lines) < 2 || !strings.EqualFold(lines[0], vcardBegin) {
+		return nil, ErrInvalidFormat
+	}
+
+	c := &ContactCard{}
+	for _, line := range lines[1:] {
+		if strings.EqualFold(line, vcardEnd) {
+			if c.Address == nil {
+				return nil, ErrInvalidFormat
+			}
+			return c, nil
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, ErrInvalidFormat
+		}
+		value := vcardUnescape(line[colon+1:])
+
+		// Parameters, such as TYPE=work, and groups, such as item1., are
+		// not used.
+		name := line[:colon]
+		if i := strings.IndexByte(name, ';'); i >= 0 {
+			name = name[:i]
+		}
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+
+		switch strings.ToUpper(name) {
+		case vcardName:
+			c.Name = value
+		case vcardEmail:
+			if c.Email == "" {
+				c.Email = value
+			}
+		case vcardNote:
+			c.Note = value
+		case vcardAddress:
+			addr, err := bmutil.DecodeAddress(value)
+			if err != nil {
+				return nil, ErrInvalidFormat
+			}
+			c.Address = addr
+		case vcardFingerprint:
+			fingerprint, err := hex.DecodeString(value)
+			if err != nil || len(fingerprint) != 32 {
+				return nil, ErrInvalidFormat
+			}
+			c.Fingerprint = hex.EncodeToString(fingerprint)
+		}
+	}
+	return nil, ErrInvalidFormat
+}
+
+// ToProtobuf encodes the message in a protobuf format.
+func (c *ContactCard) ToProtobuf() *serialize.Encoding {
+	return &serialize.Encoding{
+		Format:  c.encoding(),
+		Subject: []byte(c.Name),
+		Body:    c.Message(),
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// newPublic returns a random public identity.
+func newPublic(t *testing.T) identity.Public {
+	key, err := identity.NewRandom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := identity.NewPrivateAddress(key, bmutil.DefaultAddressVersion,
+		bmutil.DefaultStream)
+	return identity.NewPrivateID(addr, 0, nil).Public()
+}
+
+// TestContactCard tests sending a contact card and checking it against the
+// pubkey of the contact.
+func TestContactCard(t *testing.T) {
+	alice := newPublic(t)
+	card := format.NewContactCard("Alice; of Wonderland", alice)
+	card.Note = "Met at the tea party,\nsix o'clock"
+
+	var b bytes.Buffer
+	if err := format.Encode(&b, card); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	q, err := format.Decode(&b)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got, ok := q.(*format.ContactCard)
+	if !ok {
+		t.Fatalf("Decode: got %T want *format.ContactCard", q)
+	}
+	if !reflect.DeepEqual(got, card) {
+		t.Errorf("Decode: got %+v want %+v", got, card)
+	}
+	if err := format.DefaultCapabilities.Check(format.MetadataOf(got)); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	if err := got.Verify(alice); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := got.Verify(newPublic(t)); err != format.ErrAddressMismatch {
+		t.Errorf("Verify: got %v want %v", err, format.ErrAddressMismatch)
+	}
+	got.Fingerprint = strings.Repeat("00", 32)
+	if err := got.Verify(alice); err != format.ErrFingerprintMismatch {
+		t.Errorf("Verify: got %v want %v", err, format.ErrFingerprintMismatch)
+	}
+}
+
+// TestParseContactCard tests reading vCards.
+func TestParseContactCard(t *testing.T) {
+	const address = "BM-2cTux3PGRqHTEH6wyUP2sWeT4LrsGgy63z"
+	fingerprint := strings.Repeat("ab", 32)
+
+	// A vCard from another program, with parameters, a group, a folded
+	// line and properties which a card does not have.
+	card, err := format.ParseContactCard("begin:vcard\n" +
+		"VERSION:3.0\n" +
+		"N:Hatter;Mad;;;\n" +
+		"FN:Mad\n" +
+		"  Hatter\n" +
+		"item1.EMAIL;TYPE=work:hatter@example.com\n" +
+		"EMAIL:other@example.com\n" +
+		"x-bitmessage:" + address + "\n" +
+		"X-BITMESSAGE-FINGERPRINT:" + strings.ToUpper(fingerprint) + "\n" +
+		"END:VCARD\n")
+	if err != nil {
+		t.Fatalf("ParseContactCard: %v", err)
+	}
+	if card.Name != "Mad Hatter" || card.Email != "hatter@example.com" ||
+		card.Address.String() != address || card.Fingerprint != fingerprint {
+		t.Errorf("ParseContactCard: got %+v", card)
+	}
+
+	tests := []string{
+		"",
+		"FN:Alice\nX-BITMESSAGE:" + address + "\nEND:VCARD",
+		"BEGIN:VCARD\nFN:Alice\nEND:VCARD",
+		"BEGIN:VCARD\nX-BITMESSAGE:" + address,
+		"BEGIN:VCARD\nX-BITMESSAGE:BM-nope\nEND:VCARD",
+		"BEGIN:VCARD\nX-BITMESSAGE:" + address + "\nX-BITMESSAGE-FINGERPRINT:abc\nEND:VCARD",
+		"BEGIN:VCARD\nX-BITMESSAGE:" + address + "\nno colon\nEND:VCARD",
+	}
+	for i, test := range tests {
+		if _, err := format.ParseContactCard(test); err != format.ErrInvalidFormat {
+			t.Errorf("test #%d: got %v want %v", i, err, format.ErrInvalidFormat)
+		}
+	}
+	if _, err := format.Read(format.ContactEncoding, []byte(tests[2])); err !=
+		format.ErrInvalidFormat {
+		t.Errorf("Read: got %v want %v", err, format.ErrInvalidFormat)
+	}
+}
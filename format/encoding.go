@@ -6,17 +6,33 @@
 package format
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"regexp"
 
 	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/format/serialize"
 	"github.com/DanielKrawisz/bmutil/wire"
 )
 
-var encoding2Regex = regexp.MustCompile(`^Subject:(.*)\nBody:((?s).*)`)
+// The prefixes of the subject and body of an Encoding2 message. The subject
+// runs until the first newline, which must be followed by the body prefix.
+// Everything after that is the body.
+var (
+	encoding2Subject = []byte("Subject:")
+	encoding2Body    = []byte("\nBody:")
+)
+
+var (
+	// ErrInvalidFormat is returned for a message which cannot be read in
+	// the encoding which it claims to have.
+	ErrInvalidFormat = errors.New("Invalid format")
+
+	// ErrUnsupportedEncoding is returned for a message in an encoding
+	// which is not supported.
+	ErrUnsupportedEncoding = errors.New("Unsupported encoding")
+)
 
 // Encoding represents a msg or broadcast object payload.
 type Encoding interface {
@@ -100,17 +116,32 @@ func (l *Encoding2) encoding() serialize.Format {
 
 // Message returns the raw form of the object payload.
 func (l *Encoding2) Message() []byte {
-	return []byte(fmt.Sprintf("Subject:%s\nBody:%s", l.Subject, l.Body))
+	msg := make([]byte, 0, len(encoding2Subject)+len(l.Subject)+
+		len(encoding2Body)+len(l.Body))
+	msg = append(msg, encoding2Subject...)
+	msg = append(msg, l.Subject...)
+	msg = append(msg, encoding2Body...)
+	return append(msg, l.Body...)
 }
 
-// ReadMessage reads the object payload and incorporates it.
+// ReadMessage reads the object payload and incorporates it. It makes a
+// single pass over msg and copies it only once, so that it takes linear
+// time and memory however the message is constructed.
 func (l *Encoding2) readMessage(msg []byte) error {
-	matches := encoding2Regex.FindStringSubmatch(string(msg))
-	if len(matches) < 3 {
-		return errors.New("Invalid format")
+	if !bytes.HasPrefix(msg, encoding2Subject) {
+		return ErrInvalidFormat
+	}
+	rest := msg[len(encoding2Subject):]
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 || !bytes.HasPrefix(rest[end:], encoding2Body) {
+		return ErrInvalidFormat
 	}
-	l.Subject = matches[1]
-	l.Body = matches[2]
+
+	// Convert the whole message at once and take the subject and body as
+	// substrings of it.
+	s := string(rest)
+	l.Subject = s[:end]
+	l.Body = s[end+len(encoding2Body):]
 	return nil
 }
 
@@ -124,16 +155,24 @@ func (l *Encoding2) ToProtobuf() *serialize.Encoding {
 }
 
 // Read takes an encoding format code and an object payload and
-// returns it as an Encoding object.
+// returns it as an Encoding object. A payload longer than
+// wire.MaxPayloadOfMsgObject could not have come from an object, so it is
+// rejected with ErrInvalidFormat without being read.
 func Read(encoding uint64, msg []byte) (Encoding, error) {
+	if len(msg) > wire.MaxPayloadOfMsgObject {
+		return nil, ErrInvalidFormat
+	}
+
 	var q Encoding
 	switch encoding {
 	case 1:
 		q = &Encoding1{}
 	case 2:
 		q = &Encoding2{}
+	case ContactEncoding:
+		q = &ContactCard{}
 	default:
-		return nil, errors.New("Unsupported encoding")
+		return nil, ErrUnsupportedEncoding
 	}
 	err := q.readMessage(msg)
 	if err != nil {
@@ -149,21 +188,16 @@ func Decode(r io.Reader) (Encoding, error) {
 	if encoding, err = bmutil.ReadVarInt(r); err != nil {
 		return nil, err
 	}
-	var msgLength uint64
-	if msgLength, err = bmutil.ReadVarInt(r); err != nil {
-		return nil, err
-	}
-	if msgLength > wire.MaxPayloadOfMsgObject {
-		str := fmt.Sprintf("message length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			msgLength, wire.MaxPayloadOfMsgObject)
-		return nil, wire.NewMessageError("DecodeFromDecrypted", str)
-	}
-	message := make([]byte, msgLength)
-	_, err = io.ReadFull(r, message)
+	message, err := wire.ReadBoundedBytes(r, wire.MaxPayloadOfMsgObject,
+		"DecodeFromDecrypted", "message")
 	if err != nil {
 		return nil, err
 	}
 
-	return Read(encoding, message)
+	q, err := Read(encoding, message)
+	if err != nil {
+		str := fmt.Sprintf("cannot read message of encoding %d", encoding)
+		return nil, wire.WrapMessageError("DecodeFromDecrypted", str, err)
+	}
+	return q, nil
 }
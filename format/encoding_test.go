@@ -0,0 +1,98 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestEncoding2 tests reading encoding 2 messages.
+func TestEncoding2(t *testing.T) {
+	tests := []struct {
+		msg     string
+		subject string
+		body    string
+		err     error
+	}{
+		{"Subject:hello\nBody:world", "hello", "world", nil},
+		{"Subject:\nBody:", "", "", nil},
+		{"Subject:a\nBody:b\nBody:c\n", "a", "b\nBody:c\n", nil},
+		{"Subject:a\rb\nBody:\x00\xff", "a\rb", "\x00\xff", nil},
+		{"Subject:a\nb\nBody:c", "", "", format.ErrInvalidFormat},
+		{"Subject:abc", "", "", format.ErrInvalidFormat},
+		{"subject:a\nBody:b", "", "", format.ErrInvalidFormat},
+		{" Subject:a\nBody:b", "", "", format.ErrInvalidFormat},
+		{"", "", "", format.ErrInvalidFormat},
+	}
+
+	for i, test := range tests {
+		q, err := format.Read(2, []byte(test.msg))
+		if err != test.err {
+			t.Errorf("Read #%d: got error %v want %v", i, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		e := q.(*format.Encoding2)
+		if e.Subject != test.subject || e.Body != test.body {
+			t.Errorf("Read #%d: got %q, %q want %q, %q", i, e.Subject,
+				e.Body, test.subject, test.body)
+		}
+		if got := q.Message(); !bytes.Equal(got, []byte(test.msg)) {
+			t.Errorf("Message #%d: got %q want %q", i, got, test.msg)
+		}
+	}
+
+	// Payloads larger than any object are rejected.
+	big := append([]byte("Subject:\nBody:"),
+		make([]byte, wire.MaxPayloadOfMsgObject)...)
+	if _, err := format.Read(2, big); err != format.ErrInvalidFormat {
+		t.Errorf("Read of oversized payload: got %v want %v", err,
+			format.ErrInvalidFormat)
+	}
+}
+
+// benchmarkEncoding2 performs a benchmark on how long it takes to read a
+// maximum size encoding 2 message.
+func benchmarkEncoding2(b *testing.B, msg []byte) {
+	b.SetBytes(int64(len(msg)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		format.Read(2, msg)
+	}
+}
+
+// maxEncoding2 returns a message of the maximum size which begins with
+// prefix and is filled with fill.
+func maxEncoding2(prefix string, fill byte) []byte {
+	msg := bytes.Repeat([]byte{fill}, wire.MaxPayloadOfMsgObject)
+	copy(msg, prefix)
+	return msg
+}
+
+// BenchmarkEncoding2LongBody benchmarks a valid message whose body takes up
+// the maximum size.
+func BenchmarkEncoding2LongBody(b *testing.B) {
+	benchmarkEncoding2(b, maxEncoding2("Subject:\nBody:", '\n'))
+}
+
+// BenchmarkEncoding2LongSubject benchmarks a message whose subject never
+// ends, which must be scanned to the end before it is rejected.
+func BenchmarkEncoding2LongSubject(b *testing.B) {
+	benchmarkEncoding2(b, maxEncoding2("Subject:", 'a'))
+}
+
+// BenchmarkEncoding2RepeatedPrefix benchmarks a message made of nothing but
+// repeated prefixes.
+func BenchmarkEncoding2RepeatedPrefix(b *testing.B) {
+	msg := bytes.Repeat([]byte("Subject:\nBody:"),
+		wire.MaxPayloadOfMsgObject/len("Subject:\nBody:"))
+	benchmarkEncoding2(b, msg)
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import "fmt"
+
+// Metadata describes how the content of a message or broadcast was
+// encoded, so that a client can show which encoding the sender used and
+// refuse those which it does not support. None of the encodings read by
+// this package have optional features such as compression or attachments,
+// so the encoding is all there is to describe.
+type Metadata struct {
+	// Encoding is the encoding of the content.
+	Encoding uint64
+}
+
+// MetadataOf returns the metadata of the content.
+func MetadataOf(content Encoding) Metadata {
+	return Metadata{Encoding: content.Encoding()}
+}
+
+// String returns the metadata in human-readable form.
+func (m Metadata) String() string {
+	return fmt.Sprintf("encoding %d", m.Encoding)
+}
+
+// Capabilities are the encodings which a client supports.
+type Capabilities struct {
+	// Encodings are the encodings which are supported.
+	Encodings []uint64
+}
+
+// DefaultCapabilities are those of this package, which reads encodings 1
+// and 2 and contact cards.
+var DefaultCapabilities = Capabilities{Encodings: []uint64{1, 2, ContactEncoding}}
+
+// Check returns nil if content with the given metadata is supported, and
+// ErrUnsupportedEncoding if it is not.
+func (c *Capabilities) Check(m Metadata) error {
+	for _, e := range c.Encodings {
+		if e == m.Encoding {
+			return nil
+		}
+	}
+	return ErrUnsupportedEncoding
+}
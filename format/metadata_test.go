@@ -0,0 +1,68 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestMetadataOf(t *testing.T) {
+	tests := []struct {
+		content format.Encoding
+		want    format.Metadata
+	}{
+		{&format.Encoding1{Body: "hello"}, format.Metadata{Encoding: 1}},
+		{&format.Encoding2{Subject: "a", Body: "b"}, format.Metadata{Encoding: 2}},
+	}
+
+	for i, test := range tests {
+		if got := format.MetadataOf(test.content); got != test.want {
+			t.Errorf("#%d: got %v want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestMetadataString(t *testing.T) {
+	tests := []struct {
+		m    format.Metadata
+		want string
+	}{
+		{format.Metadata{Encoding: 2}, "encoding 2"},
+		{format.Metadata{Encoding: format.ContactEncoding}, "encoding 16"},
+	}
+
+	for i, test := range tests {
+		if got := test.m.String(); got != test.want {
+			t.Errorf("#%d: got %q want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	plain := format.Capabilities{Encodings: []uint64{2}}
+
+	tests := []struct {
+		caps *format.Capabilities
+		m    format.Metadata
+		err  error
+	}{
+		{&format.DefaultCapabilities, format.Metadata{Encoding: 1}, nil},
+		{&format.DefaultCapabilities, format.Metadata{Encoding: 2}, nil},
+		{&format.DefaultCapabilities, format.Metadata{Encoding: 3},
+			format.ErrUnsupportedEncoding},
+		{&format.DefaultCapabilities,
+			format.Metadata{Encoding: format.ContactEncoding}, nil},
+		{&plain, format.Metadata{Encoding: 1}, format.ErrUnsupportedEncoding},
+		{&plain, format.Metadata{Encoding: 2}, nil},
+	}
+
+	for i, test := range tests {
+		if err := test.caps.Check(test.m); err != test.err {
+			t.Errorf("#%d: got error %v want %v", i, err, test.err)
+		}
+	}
+}
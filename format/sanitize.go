@@ -0,0 +1,251 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"html"
+	"strings"
+)
+
+// SanitizePolicy says what Sanitize does with the HTML in a body.
+type SanitizePolicy struct {
+	// AllowedTags lists the elements, in lower case, which are kept. Their
+	// attributes are always removed. Elements which can run scripts or
+	// load content, such as script, style and iframe, are never kept.
+	AllowedTags []string
+
+	// Strip removes tags which are not allowed instead of escaping them.
+	// The contents of elements such as script and style are removed
+	// along with them.
+	Strip bool
+}
+
+var (
+	// EscapePolicy escapes all markup, so the body is shown as it was
+	// written.
+	EscapePolicy = &SanitizePolicy{}
+
+	// StripPolicy removes all markup and leaves the text.
+	StripPolicy = &SanitizePolicy{Strip: true}
+
+	// BasicPolicy keeps simple formatting and removes everything else.
+	BasicPolicy = &SanitizePolicy{
+		AllowedTags: []string{"b", "i", "u", "em", "strong", "p", "br",
+			"blockquote", "pre", "code"},
+		Strip: true,
+	}
+)
+
+// unsafeTags are the elements which may never be allowed.
+var unsafeTags = map[string]struct{}{
+	"script": {}, "style": {}, "iframe": {}, "frame": {}, "frameset": {},
+	"object": {}, "embed": {}, "applet": {}, "link": {}, "meta": {},
+	"base": {}, "form": {}, "svg": {}, "math": {},
+}
+
+// rawTextTags are the elements whose contents are not text to be shown,
+// and so are removed along with the element when stripping.
+var rawTextTags = map[string]struct{}{
+	"script": {}, "style": {}, "iframe": {}, "noscript": {}, "noembed": {},
+	"noframes": {}, "object": {}, "applet": {}, "textarea": {}, "title": {},
+	"xmp": {}, "svg": {}, "math": {},
+}
+
+// voidTags are the allowed elements which have no closing tag.
+var voidTags = map[string]struct{}{
+	"br": {}, "hr": {}, "wbr": {},
+}
+
+// tag is an element, comment or other piece of markup found in a body.
+type tag struct {
+	name    string // lower case, empty for comments and declarations
+	closing bool
+	end     int // index just after the tag
+}
+
+// Sanitize returns body with its HTML handled according to policy. The
+// text of the result is always escaped, so it is safe to render as HTML
+// whatever the policy. Any allowed elements left open are closed at the
+// end. A nil policy is the same as EscapePolicy.
+func Sanitize(body string, policy *SanitizePolicy) string {
+	if policy == nil {
+		policy = EscapePolicy
+	}
+
+	allowed := make(map[string]struct{}, len(policy.AllowedTags))
+	for _, name := range policy.AllowedTags {
+		name = strings.ToLower(name)
+		if _, ok := unsafeTags[name]; !ok {
+			allowed[name] = struct{}{}
+		}
+	}
+
+	var out strings.Builder
+	var open []string
+	text := 0
+	for i := 0; i < len(body); {
+		if body[i] != '<' {
+			i++
+			continue
+		}
+
+		t, ok := parseTag(body, i)
+		if !ok {
+			i++
+			continue
+		}
+
+		out.WriteString(html.EscapeString(body[text:i]))
+		text = t.end
+
+		if _, ok := allowed[t.name]; ok {
+			open = writeAllowedTag(&out, t, open)
+		} else if !policy.Strip {
+			out.WriteString(html.EscapeString(body[i:t.end]))
+		} else if _, raw := rawTextTags[t.name]; raw && !t.closing {
+			text = skipRawText(body, t)
+		}
+		i = text
+	}
+	out.WriteString(html.EscapeString(body[text:]))
+
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+
+	return out.String()
+}
+
+// writeAllowedTag writes an allowed tag without its attributes and returns
+// the elements which remain open. A closing tag which does not match an
+// open element is dropped.
+func writeAllowedTag(out *strings.Builder, t tag, open []string) []string {
+	if _, void := voidTags[t.name]; void {
+		if !t.closing {
+			out.WriteString("<" + t.name + ">")
+		}
+		return open
+	}
+
+	if !t.closing {
+		out.WriteString("<" + t.name + ">")
+		return append(open, t.name)
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j] != t.name {
+			continue
+		}
+		for k := len(open) - 1; k >= j; k-- {
+			out.WriteString("</" + open[k] + ">")
+		}
+		return open[:j]
+	}
+	return open
+}
+
+// skipRawText returns the index just after the closing tag of the raw text
+// element t, or the end of the body if there is none.
+func skipRawText(body string, t tag) int {
+	for i := t.end; ; {
+		j := strings.Index(body[i:], "</")
+		if j < 0 {
+			return len(body)
+		}
+		start := i + j
+		if hasPrefixFold(body[start+2:], t.name) {
+			c, ok := parseTag(body, start)
+			if ok && c.closing && c.name == t.name {
+				return c.end
+			}
+		}
+		i = start + 2
+	}
+}
+
+// hasPrefixFold returns whether s begins with prefix, which is in lower
+// case, ignoring the case of ASCII letters. Unlike strings.ToLower, it
+// never changes the length of s, so indices into s remain valid.
+func hasPrefixFold(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTag reads the markup which starts with the '<' at body[i]. It
+// returns false if the '<' does not begin markup, in which case it is
+// text.
+func parseTag(body string, i int) (tag, bool) {
+	rest := body[i+1:]
+
+	switch {
+	case strings.HasPrefix(rest, "!--"):
+		j := strings.Index(rest[3:], "-->")
+		if j < 0 {
+			return tag{end: len(body)}, true
+		}
+		return tag{end: i + 1 + 3 + j + 3}, true
+	case strings.HasPrefix(rest, "!") || strings.HasPrefix(rest, "?"):
+		j := strings.IndexByte(rest, '>')
+		if j < 0 {
+			return tag{end: len(body)}, true
+		}
+		return tag{end: i + 1 + j + 1}, true
+	}
+
+	var t tag
+	if strings.HasPrefix(rest, "/") {
+		t.closing = true
+		rest = rest[1:]
+	}
+
+	n := 0
+	for n < len(rest) && isTagNameByte(rest[n], n == 0) {
+		n++
+	}
+	if n == 0 {
+		return tag{}, false
+	}
+	t.name = strings.ToLower(rest[:n])
+
+	// Find the end of the tag, skipping over quoted attribute values.
+	var quote byte
+	for j := n; j < len(rest); j++ {
+		c := rest[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			t.end = len(body) - len(rest) + j + 1
+			return t, true
+		}
+	}
+
+	// An unterminated tag runs to the end of the body, as a browser
+	// would read it.
+	t.end = len(body)
+	return t, true
+}
+
+func isTagNameByte(c byte, first bool) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+		return true
+	}
+	return !first && ('0' <= c && c <= '9' || c == '-')
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+// TestSanitize tests Sanitize with each of the predefined policies.
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		body   string
+		policy *format.SanitizePolicy
+		want   string
+	}{
+		// Plain text is escaped whatever the policy.
+		{"1 < 2 & 3 > 2", nil, "1 &lt; 2 &amp; 3 &gt; 2"},
+		{"1 < 2 & 3 > 2", format.StripPolicy, "1 &lt; 2 &amp; 3 &gt; 2"},
+		{"1 < 2 & 3 > 2", format.BasicPolicy, "1 &lt; 2 &amp; 3 &gt; 2"},
+
+		{"<b>hi</b>", format.EscapePolicy, "&lt;b&gt;hi&lt;/b&gt;"},
+		{"<b>hi</b>", format.StripPolicy, "hi"},
+		{`<B onclick="x()">hi</b>`, format.BasicPolicy, "<b>hi</b>"},
+
+		// Scripts are removed with their contents when stripping.
+		{"a<script>alert('</b>')</script>b", format.StripPolicy, "ab"},
+		{"a<SCRIPT>x</script >b", format.BasicPolicy, "ab"},
+		{"a<script>never closed", format.StripPolicy, "a"},
+		{"<script>İİİ</script>x", format.StripPolicy, "x"},
+		{"a<script>x</SCRIPT>b", format.StripPolicy, "ab"},
+		{"a<script>x</script>", format.EscapePolicy,
+			"a&lt;script&gt;x&lt;/script&gt;"},
+
+		// Quoted '>' does not end a tag.
+		{`<a href="x>y">link</a>`, format.StripPolicy, "link"},
+
+		// Comments and declarations.
+		{"a<!-- <b>hidden</b> -->b", format.BasicPolicy, "ab"},
+		{"<!DOCTYPE html>a", format.StripPolicy, "a"},
+
+		// Unbalanced allowed tags.
+		{"<b><i>x</b>y", format.BasicPolicy, "<b><i>x</i></b>y"},
+		{"x</i>y", format.BasicPolicy, "xy"},
+		{"<p>open", format.BasicPolicy, "<p>open</p>"},
+		{"a<br/>b", format.BasicPolicy, "a<br>b"},
+
+		// Not a tag.
+		{"a < b and <3", format.StripPolicy, "a &lt; b and &lt;3"},
+	}
+
+	for i, test := range tests {
+		got := format.Sanitize(test.body, test.policy)
+		if got != test.want {
+			t.Errorf("test #%d: got %q want %q", i, got, test.want)
+		}
+	}
+}
+
+// TestSanitizeUnsafeTags tests that scripts cannot be allowed by a policy.
+func TestSanitizeUnsafeTags(t *testing.T) {
+	policy := &format.SanitizePolicy{AllowedTags: []string{"script", "b"}}
+	got := format.Sanitize("<script>x</script><b>y</b>", policy)
+	want := "&lt;script&gt;x&lt;/script&gt;<b>y</b>"
+	if got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
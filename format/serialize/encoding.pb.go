@@ -7,12 +7,15 @@ Package serialize is a generated protocol buffer package.
 
 It is generated from these files:
 	encoding.proto
+	pow.proto
 
 It has these top-level messages:
 	Message
 	MessageState
 	ImapData
 	Encoding
+	PowJob
+	PowResult
 */
 package serialize
 
@@ -37,17 +40,20 @@ const (
 	Format_UNUSED    Format = 0
 	Format_ENCODING1 Format = 1
 	Format_ENCODING2 Format = 2
+	Format_CONTACT   Format = 16
 )
 
 var Format_name = map[int32]string{
-	0: "UNUSED",
-	1: "ENCODING1",
-	2: "ENCODING2",
+	0:  "UNUSED",
+	1:  "ENCODING1",
+	2:  "ENCODING2",
+	16: "CONTACT",
 }
 var Format_value = map[string]int32{
 	"UNUSED":    0,
 	"ENCODING1": 1,
 	"ENCODING2": 2,
+	"CONTACT":   16,
 }
 
 func (x Format) String() string {
@@ -144,34 +150,34 @@ func init() {
 func init() { proto.RegisterFile("encoding.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 454 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x4c, 0x92, 0xcf, 0x8e, 0xd3, 0x30,
-	0x10, 0xc6, 0x49, 0x76, 0x9b, 0x38, 0xd3, 0xb4, 0x44, 0x23, 0x84, 0x2c, 0x10, 0xff, 0x8a, 0x40,
-	0xc0, 0xa1, 0x12, 0xe5, 0x0d, 0xd8, 0x06, 0xb4, 0x07, 0x8a, 0xe4, 0xb2, 0x07, 0xb8, 0x44, 0x6e,
-	0xe2, 0x14, 0xb3, 0x6d, 0x5c, 0x12, 0x17, 0xb1, 0x3c, 0x02, 0x2f, 0xc8, 0xeb, 0xe0, 0x38, 0x4e,
-	0xb6, 0xb7, 0xf9, 0x7e, 0x33, 0x93, 0xc9, 0x37, 0x1e, 0x98, 0x8a, 0x2a, 0x57, 0x85, 0xac, 0xb6,
-	0xf3, 0x43, 0xad, 0xb4, 0x9a, 0xfd, 0xf5, 0x21, 0xfc, 0x24, 0x9a, 0x86, 0x6f, 0x05, 0xbe, 0x00,
-	0xd2, 0x67, 0xa9, 0xf7, 0xd4, 0x7b, 0x35, 0x5e, 0x44, 0xf3, 0xd4, 0x01, 0x36, 0xa4, 0x10, 0xe1,
-	0xbc, 0xac, 0xd5, 0x9e, 0xfa, 0xa6, 0x24, 0x62, 0x36, 0xc6, 0x29, 0xf8, 0x5a, 0xd1, 0x33, 0x4b,
-	0x4c, 0x84, 0x8f, 0x00, 0x54, 0x99, 0xe5, 0xdf, 0x79, 0x55, 0x89, 0x1d, 0x3d, 0x37, 0x9c, 0xb0,
-	0x48, 0x95, 0x17, 0x1d, 0xc0, 0xc7, 0x00, 0xe2, 0xf7, 0x41, 0xd6, 0x5c, 0x4b, 0x55, 0xd1, 0x91,
-	0x6d, 0x3b, 0x21, 0x98, 0xc0, 0x19, 0xcf, 0xaf, 0x69, 0x60, 0x12, 0x31, 0x6b, 0x43, 0x7c, 0x09,
-	0x91, 0xdc, 0xf3, 0x43, 0x56, 0x70, 0xcd, 0x69, 0xe8, 0x7e, 0xee, 0xd2, 0x90, 0xa5, 0x01, 0x8c,
-	0x48, 0x17, 0xe1, 0x7d, 0x08, 0xd4, 0xe6, 0x87, 0xc8, 0x35, 0x25, 0xb6, 0xd9, 0x29, 0x7c, 0x0e,
-	0xa3, 0x46, 0x73, 0x2d, 0x68, 0x64, 0x7b, 0x27, 0x73, 0x67, 0x7a, 0xdd, 0x42, 0xd6, 0xe5, 0x66,
-	0xff, 0x3c, 0x88, 0x4f, 0x39, 0xbe, 0x86, 0xe4, 0x70, 0xdc, 0x5c, 0x8b, 0x9b, 0xac, 0x16, 0x3f,
-	0x8f, 0xa2, 0xd1, 0xa2, 0xb0, 0x9b, 0x21, 0xec, 0x6e, 0xc7, 0x59, 0x8f, 0x5b, 0xc7, 0x8d, 0xa8,
-	0x8a, 0x4c, 0xd7, 0x52, 0x34, 0xd6, 0xf1, 0x84, 0x45, 0x2d, 0xf9, 0xd2, 0x02, 0x7c, 0x08, 0xd1,
-	0x8e, 0x37, 0x3a, 0x6b, 0x89, 0x33, 0x4c, 0x5a, 0xb0, 0x36, 0x1a, 0x9f, 0x41, 0x6c, 0x3c, 0x9a,
-	0x19, 0xb9, 0x90, 0xbf, 0xcc, 0x88, 0xc0, 0x8e, 0x18, 0x1b, 0xc6, 0x1c, 0xea, 0x4b, 0xcc, 0x8e,
-	0x8c, 0x1b, 0x53, 0x12, 0x0e, 0x25, 0xa9, 0x43, 0xf8, 0x00, 0xc8, 0xf0, 0x05, 0x62, 0xd3, 0x83,
-	0x9e, 0xa5, 0x40, 0xfa, 0x65, 0x99, 0x55, 0x4c, 0xb4, 0xdc, 0x8b, 0xdb, 0x71, 0x9e, 0xfd, 0x9d,
-	0xb8, 0x85, 0xc3, 0xbc, 0x7b, 0x30, 0x2a, 0x77, 0x7c, 0xdb, 0xd8, 0x57, 0x1e, 0xb1, 0x4e, 0xcc,
-	0xbe, 0x02, 0xe9, 0x0f, 0x02, 0x9f, 0x40, 0x50, 0xaa, 0x7a, 0xcf, 0xb5, 0xed, 0x9f, 0x2e, 0xc2,
-	0xf9, 0x07, 0x2b, 0x99, 0xc3, 0x48, 0x21, 0x6c, 0x8e, 0xdd, 0x5b, 0xf8, 0xf6, 0x2d, 0x7a, 0xd9,
-	0x5e, 0xd0, 0x46, 0x15, 0x37, 0xf6, 0x5e, 0x62, 0x66, 0xe3, 0x37, 0x0b, 0x08, 0xba, 0x7e, 0x04,
-	0x08, 0xae, 0x56, 0x57, 0xeb, 0x74, 0x99, 0xdc, 0xc1, 0x09, 0x44, 0xe9, 0xea, 0xe2, 0xf3, 0xf2,
-	0x72, 0xf5, 0xf1, 0x6d, 0xe2, 0x9d, 0xca, 0x45, 0xe2, 0xbf, 0x1f, 0x7f, 0x33, 0x1b, 0xae, 0x25,
-	0xdf, 0xc9, 0x3f, 0x62, 0x13, 0xd8, 0x83, 0x7e, 0xf7, 0x3f, 0x00, 0x00, 0xff, 0xff, 0x51, 0xab,
-	0x00, 0x93, 0xe2, 0x02, 0x00, 0x00,
+	// 462 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4d, 0x92, 0xdb, 0x8e, 0xd3, 0x30,
+	0x10, 0x86, 0x49, 0x76, 0x9b, 0x38, 0xd3, 0xb4, 0x44, 0x23, 0x84, 0x2c, 0x10, 0xa7, 0x22, 0x56,
+	0xc0, 0x45, 0x25, 0xca, 0x03, 0x20, 0x68, 0x03, 0xda, 0x0b, 0xba, 0x92, 0xdb, 0xbd, 0x80, 0x9b,
+	0xc8, 0x4d, 0x9c, 0x62, 0xb6, 0x8d, 0x4b, 0xe2, 0x22, 0x96, 0x47, 0xe0, 0x05, 0x79, 0x1d, 0x1c,
+	0xe7, 0x40, 0xef, 0xe6, 0xff, 0x66, 0xc6, 0xe3, 0x39, 0xc0, 0x58, 0x14, 0xa9, 0xca, 0x64, 0xb1,
+	0x9d, 0x1e, 0x4a, 0xa5, 0xd5, 0xe4, 0x8f, 0x0b, 0xfe, 0x67, 0x51, 0x55, 0x7c, 0x2b, 0xf0, 0x05,
+	0x90, 0xce, 0x4b, 0x9d, 0xa7, 0xce, 0xcb, 0xe1, 0x2c, 0x98, 0xc6, 0x2d, 0x60, 0xbd, 0x0b, 0x11,
+	0xce, 0xf3, 0x52, 0xed, 0xa9, 0x6b, 0x42, 0x02, 0x66, 0x6d, 0x1c, 0x83, 0xab, 0x15, 0x3d, 0xb3,
+	0xc4, 0x58, 0xf8, 0x08, 0x40, 0xe5, 0x49, 0xfa, 0x8d, 0x17, 0x85, 0xd8, 0xd1, 0x73, 0xc3, 0x09,
+	0x0b, 0x54, 0x3e, 0x6f, 0x00, 0x3e, 0x06, 0x10, 0xbf, 0x0e, 0xb2, 0xe4, 0x5a, 0xaa, 0x82, 0x0e,
+	0x6c, 0xda, 0x09, 0xc1, 0x08, 0xce, 0x78, 0x7a, 0x43, 0x3d, 0xe3, 0x08, 0x59, 0x6d, 0xe2, 0x05,
+	0x04, 0x72, 0xcf, 0x0f, 0x49, 0xc6, 0x35, 0xa7, 0x7e, 0xfb, 0xb9, 0x4b, 0x43, 0x16, 0x06, 0x30,
+	0x22, 0x5b, 0x0b, 0xef, 0x83, 0xa7, 0x36, 0xdf, 0x45, 0xaa, 0x29, 0xb1, 0xc9, 0xad, 0xc2, 0xe7,
+	0x30, 0xa8, 0x34, 0xd7, 0x82, 0x06, 0x36, 0x77, 0x34, 0x6d, 0x9b, 0x5e, 0xd5, 0x90, 0x35, 0xbe,
+	0xc9, 0x5f, 0x07, 0xc2, 0x53, 0x8e, 0xaf, 0x20, 0x3a, 0x1c, 0x37, 0x37, 0xe2, 0x36, 0x29, 0xc5,
+	0x8f, 0xa3, 0xa8, 0xb4, 0xc8, 0xec, 0x64, 0x08, 0xbb, 0xdb, 0x70, 0xd6, 0xe1, 0xba, 0xe3, 0x4a,
+	0x14, 0x59, 0xa2, 0x4b, 0x29, 0x2a, 0xdb, 0xf1, 0x88, 0x05, 0x35, 0x59, 0xd7, 0x00, 0x1f, 0x42,
+	0xb0, 0xe3, 0x95, 0x4e, 0x6a, 0xd2, 0x36, 0x4c, 0x6a, 0xb0, 0x32, 0x1a, 0x9f, 0x41, 0x68, 0x7a,
+	0x34, 0x35, 0x52, 0x21, 0x7f, 0x9a, 0x12, 0x9e, 0x2d, 0x31, 0x34, 0x8c, 0xb5, 0xa8, 0x0b, 0x31,
+	0x33, 0x32, 0xdd, 0x98, 0x10, 0xbf, 0x0f, 0x89, 0x5b, 0x84, 0x0f, 0x80, 0xf4, 0x2f, 0x10, 0xeb,
+	0xee, 0xf5, 0x24, 0x06, 0xd2, 0x0d, 0xcb, 0x8c, 0x62, 0xa4, 0xe5, 0x5e, 0xfc, 0x2f, 0xe7, 0xd8,
+	0xef, 0x84, 0x35, 0xec, 0xeb, 0xdd, 0x83, 0x41, 0xbe, 0xe3, 0xdb, 0xca, 0x6e, 0x79, 0xc0, 0x1a,
+	0x31, 0xf9, 0x02, 0xa4, 0x3b, 0x08, 0x7c, 0x02, 0x5e, 0xae, 0xca, 0x3d, 0xd7, 0x36, 0x7f, 0x3c,
+	0xf3, 0xa7, 0x1f, 0xad, 0x64, 0x2d, 0x46, 0x0a, 0x7e, 0x75, 0x6c, 0x76, 0xe1, 0xda, 0x5d, 0x74,
+	0xb2, 0xbe, 0xa0, 0x8d, 0xca, 0x6e, 0xed, 0xbd, 0x84, 0xcc, 0xda, 0xaf, 0xdf, 0x81, 0xd7, 0xe4,
+	0x23, 0x80, 0x77, 0xbd, 0xbc, 0x5e, 0xc5, 0x8b, 0xe8, 0x0e, 0x8e, 0x20, 0x88, 0x97, 0xf3, 0xab,
+	0xc5, 0xe5, 0xf2, 0xd3, 0x9b, 0xc8, 0x39, 0x95, 0xb3, 0xc8, 0xc5, 0x21, 0xf8, 0xf3, 0xab, 0xe5,
+	0xfa, 0xfd, 0x7c, 0x1d, 0x45, 0x1f, 0x86, 0x5f, 0xcd, 0xb8, 0x4b, 0xc9, 0x77, 0xf2, 0xb7, 0xd8,
+	0x78, 0xf6, 0xba, 0xdf, 0xfe, 0x03, 0x15, 0x56, 0x08, 0xfe, 0xef, 0x02, 0x00, 0x00,
 }
@@ -5,4 +5,4 @@
 
 package serialize
 
-//go:generate protoc --go_out=. encoding.proto
+//go:generate protoc --go_out=. encoding.proto pow.proto
@@ -0,0 +1,65 @@
+// Code generated by protoc-gen-go.
+// source: pow.proto
+// DO NOT EDIT!
+
+package serialize
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// PowJob is a request for proof-of-work to be done on an object. It is
+// passed from a client to whatever worker is going to do the work.
+type PowJob struct {
+	Id          uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	InitialHash []byte `protobuf:"bytes,2,opt,name=initial_hash,json=initialHash,proto3" json:"initial_hash,omitempty"`
+	Target      uint64 `protobuf:"varint,3,opt,name=target" json:"target,omitempty"`
+	// deadline is the Unix time, in seconds, by which the work is to be done.
+	Deadline int64  `protobuf:"varint,4,opt,name=deadline" json:"deadline,omitempty"`
+	Priority uint32 `protobuf:"varint,5,opt,name=priority" json:"priority,omitempty"`
+}
+
+func (m *PowJob) Reset()                    { *m = PowJob{} }
+func (m *PowJob) String() string            { return proto.CompactTextString(m) }
+func (*PowJob) ProtoMessage()               {}
+func (*PowJob) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+
+// PowResult is returned by a worker when a PowJob is complete.
+type PowResult struct {
+	Id          uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	InitialHash []byte `protobuf:"bytes,2,opt,name=initial_hash,json=initialHash,proto3" json:"initial_hash,omitempty"`
+	Nonce       uint64 `protobuf:"varint,3,opt,name=nonce" json:"nonce,omitempty"`
+}
+
+func (m *PowResult) Reset()                    { *m = PowResult{} }
+func (m *PowResult) String() string            { return proto.CompactTextString(m) }
+func (*PowResult) ProtoMessage()               {}
+func (*PowResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+
+func init() {
+	proto.RegisterType((*PowJob)(nil), "PowJob")
+	proto.RegisterType((*PowResult)(nil), "PowResult")
+}
+
+func init() { proto.RegisterFile("pow.proto", fileDescriptor1) }
+
+var fileDescriptor1 = []byte{
+	// 181 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe3, 0xe2, 0x2c, 0xc8, 0x2f, 0xd7,
+	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x57, 0xea, 0x66, 0xe4, 0x62, 0x0b, 0xc8, 0x2f, 0xf7, 0xca, 0x4f,
+	0x12, 0xe2, 0xe3, 0x62, 0xca, 0x4c, 0x91, 0x60, 0x54, 0x60, 0xd4, 0x60, 0x09, 0x02, 0xb2, 0x84,
+	0x14, 0xb9, 0x78, 0x32, 0xf3, 0x32, 0x4b, 0x32, 0x13, 0x73, 0xe2, 0x33, 0x12, 0x8b, 0x33, 0x24,
+	0x98, 0x80, 0x32, 0x3c, 0x41, 0xdc, 0x50, 0x31, 0x0f, 0xa0, 0x90, 0x90, 0x18, 0x17, 0x5b, 0x49,
+	0x62, 0x51, 0x7a, 0x6a, 0x89, 0x04, 0x33, 0x58, 0x1b, 0x94, 0x27, 0x24, 0xc5, 0xc5, 0x91, 0x92,
+	0x9a, 0x98, 0x92, 0x93, 0x99, 0x97, 0x2a, 0xc1, 0x02, 0x94, 0x61, 0x0e, 0x82, 0xf3, 0x41, 0x72,
+	0x05, 0x45, 0x99, 0xf9, 0x45, 0x99, 0x25, 0x95, 0x12, 0xac, 0x40, 0x39, 0xde, 0x20, 0x38, 0x5f,
+	0x29, 0x84, 0x8b, 0x13, 0xe8, 0x98, 0xa0, 0xd4, 0xe2, 0xd2, 0x9c, 0x12, 0x72, 0xdc, 0x23, 0xc2,
+	0xc5, 0x9a, 0x97, 0x9f, 0x97, 0x9c, 0x0a, 0x75, 0x0e, 0x84, 0xe3, 0xc4, 0x1d, 0xc5, 0x59, 0x9c,
+	0x5a, 0x04, 0x54, 0x93, 0x59, 0x95, 0x9a, 0xc4, 0x06, 0xf6, 0xb7, 0x31, 0x00, 0xe0, 0x78, 0x63,
+	0xfb, 0x04, 0x01, 0x00, 0x00,
+}
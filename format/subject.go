@@ -0,0 +1,38 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import "mime"
+
+// Subjects in encoding 2 may be any UTF-8 but a newline. Gateways to email
+// and some older clients treat the subject as ASCII, however, and mangle
+// anything else. EncodeSubject and DecodeSubject write subjects as the
+// encoded words of RFC 2047, such as "=?utf-8?b?w6l0w6k=?=", which survive
+// them. This is optional: a subject which is not encoded is read as it is,
+// so messages remain plain encoding 2 either way.
+
+// EncodeSubject returns the subject as RFC 2047 encoded words if it has
+// characters which are not printable ASCII, and unchanged otherwise.
+func EncodeSubject(subject string) string {
+	return mime.BEncoding.Encode("utf-8", subject)
+}
+
+// DecodeSubject returns the subject with any RFC 2047 encoded words in it
+// decoded. Words in the UTF-8, ISO-8859-1 and US-ASCII character sets can
+// be decoded. If the subject cannot be decoded, it is returned unchanged.
+func DecodeSubject(subject string) string {
+	var d mime.WordDecoder
+	s, err := d.DecodeHeader(subject)
+	if err != nil {
+		return subject
+	}
+	return s
+}
+
+// DecodedSubject returns the subject of the message with any RFC 2047
+// encoded words in it decoded.
+func (l *Encoding2) DecodedSubject() string {
+	return DecodeSubject(l.Subject)
+}
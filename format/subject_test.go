@@ -0,0 +1,78 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		encoded string
+	}{
+		{"", ""},
+		{"hello world", "hello world"},
+		{"été", "=?utf-8?b?w6l0w6k=?="},
+		{"a\nb", "=?utf-8?b?YQpi?="},
+		{strings.Repeat("日本語", 20), ""},
+	}
+
+	for i, test := range tests {
+		encoded := format.EncodeSubject(test.subject)
+		if test.encoded != "" && encoded != test.encoded {
+			t.Errorf("EncodeSubject #%d: got %q want %q", i, encoded,
+				test.encoded)
+		}
+		for _, c := range encoded {
+			if c < ' ' || c > '~' {
+				t.Errorf("EncodeSubject #%d: got %q which is not "+
+					"printable ASCII", i, encoded)
+				break
+			}
+		}
+		if got := format.DecodeSubject(encoded); got != test.subject {
+			t.Errorf("DecodeSubject #%d: got %q want %q", i, got,
+				test.subject)
+		}
+	}
+}
+
+func TestDecodeSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		decoded string
+	}{
+		{"plain", "plain"},
+		{"Re: =?ISO-8859-1?Q?caf=E9?=", "Re: café"},
+		{"=?utf-8?q?a_b?= =?utf-8?q?c?=", "a bc"},
+		{"=?koi8-r?b?8NLJ18XU?=", "=?koi8-r?b?8NLJ18XU?="},
+		{"=?utf-8?x?abc?=", "=?utf-8?x?abc?="},
+	}
+
+	for i, test := range tests {
+		if got := format.DecodeSubject(test.subject); got != test.decoded {
+			t.Errorf("DecodeSubject #%d: got %q want %q", i, got,
+				test.decoded)
+		}
+	}
+
+	// An encoded subject is still read as plain encoding 2.
+	subject := format.EncodeSubject("über")
+	q, err := format.Read(2, []byte("Subject:"+subject+"\nBody:"))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	e := q.(*format.Encoding2)
+	if e.Subject != subject {
+		t.Errorf("Subject: got %q want %q", e.Subject, subject)
+	}
+	if got := e.DecodedSubject(); got != "über" {
+		t.Errorf("DecodedSubject: got %q want %q", got, "über")
+	}
+}
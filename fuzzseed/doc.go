@@ -0,0 +1,28 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package fuzzseed generates seed corpora for fuzzing the decoders of bmutil.
+
+A fuzzer which starts from random bytes spends most of its time failing the
+first check of a decoder. Starting instead from valid encodings, and from
+encodings which are nearly valid, lets it reach the code which handles each
+field.
+
+Messages returns a seed for every type of network message, framed with a
+message header as read by wire.ReadMessage, and Objects returns a seed for
+every type and version of object, as read by obj.ReadObject. Both include
+the valid encodings along with near-valid variants made by Mutate, which are
+truncated, have bits flipped, or have bytes added to the end. Every seed is
+the same each time it is generated, so corpora can be checked in.
+
+WriteCorpus writes seeds in the format of the corpus files of go test, in
+the directory which go test reads for a fuzz target:
+
+	seeds := fuzzseed.Messages(wire.MainNet)
+	err := fuzzseed.WriteCorpus("testdata/fuzz/FuzzReadMessage", seeds)
+
+The fuzz target must take a single []byte argument.
+*/
+package fuzzseed
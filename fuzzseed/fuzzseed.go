@@ -0,0 +1,252 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fuzzseed
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// Seed is one input for a fuzzer.
+type Seed struct {
+	// Name identifies the seed, and is the name of its file in a corpus.
+	Name string
+
+	Data []byte
+}
+
+// The fields from which seeds are made, which are fixed so that the seeds
+// are the same each time.
+var (
+	seedTime  = time.Unix(1500000000, 0)
+	seedNonce = pow.Nonce(0x0102030405060708)
+)
+
+// pattern returns n bytes which count up from start.
+func pattern(start byte, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+// seedRipe returns the ripe of the addresses in seeds.
+func seedRipe() *hash.Ripe {
+	ripe, _ := hash.NewRipe(pattern(0x10, hash.RipeSize))
+	return ripe
+}
+
+// seedPubKey returns a public key which is structurally valid, though it is
+// not a point on the curve.
+func seedPubKey(start byte) *wire.PubKey {
+	var pk wire.PubKey
+	copy(pk[:], pattern(start, wire.PubKeySize))
+	return &pk
+}
+
+// seedNetAddress returns a network address.
+func seedNetAddress(ip string, port uint16) *wire.NetAddress {
+	na := wire.NewNetAddressIPPort(net.ParseIP(ip), port, 1,
+		wire.SFNodeNetwork)
+	na.Timestamp = seedTime
+	return na
+}
+
+// inv returns n inventory vectors.
+func inv(n int) []*wire.InvVect {
+	list := make([]*wire.InvVect, n)
+	for i := range list {
+		var iv wire.InvVect
+		copy(iv[:], pattern(byte(i*hash.ShaSize), hash.ShaSize))
+		list[i] = &iv
+	}
+	return list
+}
+
+// objects returns an object of every type and version.
+func objects() []struct {
+	name string
+	o    obj.Object
+} {
+	ripe := seedRipe()
+	v3, _ := bmutil.NewDepricatedAddress(3, 1, ripe)
+	v4, _ := bmutil.NewAddress(4, 1, ripe)
+	tag := bmutil.Tag(v4)
+	encrypted := pattern(0x80, 96)
+	data := &obj.PubKeyData{
+		Behavior:     1,
+		Verification: seedPubKey(0x20),
+		Encryption:   seedPubKey(0x60),
+		Pow:          &pow.Default,
+	}
+
+	return []struct {
+		name string
+		o    obj.Object
+	}{
+		{"getpubkey-v3", obj.NewGetPubKey(seedNonce, seedTime, v3)},
+		{"getpubkey-v4", obj.NewGetPubKey(seedNonce, seedTime, v4)},
+		{"pubkey-v2", obj.NewSimplePubKey(seedNonce, seedTime, 1, 1,
+			data.Verification, data.Encryption)},
+		{"pubkey-v3", obj.NewExtendedPubKey(seedNonce, seedTime, 1, data,
+			pattern(0x30, 72))},
+		{"pubkey-v4", obj.NewEncryptedPubKey(seedNonce, seedTime, 1, tag,
+			encrypted)},
+		{"msg", obj.NewMessage(seedNonce, seedTime, 1, encrypted)},
+		{"broadcast-v4", obj.NewTaglessBroadcast(seedNonce, seedTime, 1,
+			encrypted)},
+		{"broadcast-v5", obj.NewTaggedBroadcast(seedNonce, seedTime, 1, tag,
+			encrypted)},
+		{"unknown-type", wire.NewMsgObject(wire.NewObjectHeader(seedNonce,
+			seedTime, wire.ObjectType(7), 1, 1), encrypted)},
+	}
+}
+
+// Objects returns seeds for the decoders of objects: the encoding of an
+// object of every type and version, and the variants of each made by
+// Mutate.
+func Objects() []Seed {
+	var seeds []Seed
+	for _, o := range objects() {
+		seeds = append(seeds, Mutate(Seed{Name: o.name, Data: wire.Encode(o.o)})...)
+	}
+	return seeds
+}
+
+// Messages returns seeds for the decoders of network messages: every type
+// of message, framed for the given network, and the variants of each made by
+// Mutate. The header of a message is checked before its payload is decoded,
+// so each message is also given a wrong checksum.
+func Messages(bmnet wire.BitmessageNet) []Seed {
+	version := wire.NewMsgVersion(seedNetAddress("127.0.0.1", 8444),
+		seedNetAddress("10.0.0.1", 8444), 0x1122334455667788, []uint32{1})
+	version.Timestamp = seedTime
+
+	addr := wire.NewMsgAddr()
+	addr.AddAddresses(seedNetAddress("192.168.0.1", 8444),
+		seedNetAddress("::1", 8445))
+
+	invMsg := wire.NewMsgInv()
+	getData := wire.NewMsgGetData()
+	for _, iv := range inv(3) {
+		invMsg.AddInvVect(iv)
+		getData.AddInvVect(iv)
+	}
+
+	messages := []struct {
+		name string
+		msg  wire.Message
+	}{
+		{"version", version},
+		{"verack", wire.NewMsgVerAck()},
+		{"addr", addr},
+		{"inv", invMsg},
+		{"getdata", getData},
+		{"pong", wire.NewMsgPong()},
+	}
+	for _, o := range objects() {
+		messages = append(messages, struct {
+			name string
+			msg  wire.Message
+		}{"object-" + o.name, o.o})
+	}
+
+	var seeds []Seed
+	for _, m := range messages {
+		var b bytes.Buffer
+		if err := wire.WriteMessage(&b, m.msg, bmnet); err != nil {
+			// The messages are fixed, so this can only be a bug.
+			panic(fmt.Sprintf("fuzzseed: cannot encode %s: %v", m.name, err))
+		}
+		frame := b.Bytes()
+		seeds = append(seeds, Mutate(Seed{Name: m.name, Data: frame})...)
+
+		// The checksum is the last four bytes of the header.
+		bad := append([]byte(nil), frame...)
+		bad[wire.MessageHeaderSize-1] ^= 0xff
+		seeds = append(seeds, Seed{Name: m.name + "-checksum", Data: bad})
+	}
+	return seeds
+}
+
+// Mutate returns a seed along with near-valid variants of it: the seed
+// truncated to its first byte, half its length and all but its last byte,
+// with the lowest bit flipped in its first, middle and last bytes, and with
+// bytes added to its end.
+func Mutate(s Seed) []Seed {
+	seeds := []Seed{s}
+	n := len(s.Data)
+	if n == 0 {
+		return seeds
+	}
+
+	for _, at := range distinct(1, n/2, n-1) {
+		seeds = append(seeds, Seed{
+			Name: fmt.Sprintf("%s-trunc-%d", s.Name, at),
+			Data: append([]byte(nil), s.Data[:at]...),
+		})
+	}
+	for _, at := range distinct(0, n/2, n-1) {
+		flipped := append([]byte(nil), s.Data...)
+		flipped[at] ^= 1
+		seeds = append(seeds, Seed{
+			Name: fmt.Sprintf("%s-flip-%d", s.Name, at),
+			Data: flipped,
+		})
+	}
+	seeds = append(seeds, Seed{
+		Name: s.Name + "-extra",
+		Data: append(append([]byte(nil), s.Data...), pattern(0xf0, 8)...),
+	})
+	return seeds
+}
+
+// distinct returns the positive values of positions without duplicates, in
+// order, leaving out zeros except in the first position.
+func distinct(positions ...int) []int {
+	var result []int
+	seen := make(map[int]bool)
+	for i, p := range positions {
+		if seen[p] || (p <= 0 && i > 0) {
+			continue
+		}
+		seen[p] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+// Encode returns the contents of the corpus file of go test for a fuzz
+// target which takes a single []byte argument.
+func Encode(data []byte) []byte {
+	return []byte(fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", data))
+}
+
+// WriteCorpus writes each seed to a file in dir, which is created if it does
+// not exist. Existing files with the same names are replaced.
+func WriteCorpus(dir string, seeds []Seed) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, s := range seeds {
+		err := ioutil.WriteFile(filepath.Join(dir, s.Name), Encode(s.Data), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
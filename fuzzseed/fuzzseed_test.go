@@ -0,0 +1,144 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fuzzseed_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/fuzzseed"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// valid returns whether a seed is an encoding as it was made, rather than a
+// variant of one.
+func valid(s fuzzseed.Seed) bool {
+	for _, variant := range []string{"-trunc-", "-flip-", "-extra", "-checksum"} {
+		if strings.Contains(s.Name, variant) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNames checks that the names of seeds are unique.
+func checkNames(t *testing.T, seeds []fuzzseed.Seed) {
+	names := make(map[string]bool)
+	for _, s := range seeds {
+		if names[s.Name] {
+			t.Errorf("duplicate seed %s", s.Name)
+		}
+		names[s.Name] = true
+	}
+}
+
+func TestObjects(t *testing.T) {
+	seeds := fuzzseed.Objects()
+	checkNames(t, seeds)
+	if !reflect.DeepEqual(seeds, fuzzseed.Objects()) {
+		t.Error("seeds differ each time")
+	}
+
+	n := 0
+	for _, s := range seeds {
+		if !valid(s) {
+			continue
+		}
+		n++
+		o, err := obj.ReadObject(s.Data)
+		if err != nil {
+			t.Errorf("%s: %v", s.Name, err)
+			continue
+		}
+		_, generic := o.(*wire.MsgObject)
+		if generic != (s.Name == "unknown-type") {
+			t.Errorf("%s: decoded as %T", s.Name, o)
+		}
+	}
+	if n != 9 {
+		t.Errorf("expected 9 objects, got %d", n)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	seeds := fuzzseed.Messages(wire.MainNet)
+	checkNames(t, seeds)
+
+	n := 0
+	for _, s := range seeds {
+		_, _, err := wire.ReadMessage(bytes.NewReader(s.Data), wire.MainNet)
+		switch {
+		case valid(s):
+			n++
+			if err != nil {
+				t.Errorf("%s: %v", s.Name, err)
+			}
+		case strings.HasSuffix(s.Name, "-checksum"):
+			if err == nil {
+				t.Errorf("%s: expected a checksum error", s.Name)
+			}
+		}
+	}
+	if n != 15 {
+		t.Errorf("expected 15 messages, got %d", n)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	if seeds := fuzzseed.Mutate(fuzzseed.Seed{Name: "empty"}); len(seeds) != 1 {
+		t.Errorf("expected only the empty seed, got %v", seeds)
+	}
+
+	seeds := fuzzseed.Mutate(fuzzseed.Seed{Name: "s", Data: []byte{0, 2}})
+	checkNames(t, seeds)
+	expected := []fuzzseed.Seed{
+		{"s", []byte{0, 2}},
+		{"s-trunc-1", []byte{0}},
+		{"s-flip-0", []byte{1, 2}},
+		{"s-flip-1", []byte{0, 3}},
+		{"s-extra", []byte{0, 2, 0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7}},
+	}
+	if !reflect.DeepEqual(seeds, expected) {
+		t.Errorf("expected %v, got %v", expected, seeds)
+	}
+}
+
+func TestWriteCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzseed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	seed := fuzzseed.Seed{Name: "quoted", Data: []byte("a\"\x00\xff\n")}
+	corpus := filepath.Join(dir, "FuzzTest")
+	if err := fuzzseed.WriteCorpus(corpus, []fuzzseed.Seed{seed}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(corpus, "quoted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(b), "\n")
+	if len(lines) != 3 || lines[0] != "go test fuzz v1" ||
+		!strings.HasPrefix(lines[1], "[]byte(") || !strings.HasSuffix(lines[1], ")") {
+		t.Fatalf("wrong corpus file %q", b)
+	}
+	data, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(lines[1], "[]byte("), ")"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != string(seed.Data) {
+		t.Errorf("expected %q, got %q", seed.Data, data)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package gateway maps between Bitmessage addresses and the email addresses
+of an email gateway such as mailchuck, and composes the messages which
+control the gateway, so that clients can send and receive email through one.
+
+Every Bitmessage address has an email address at the gateway, which is the
+address itself followed by the domain of the gateway, and an identity can
+register a name to use instead. Email is sent by sending a message to the
+relay address of the gateway with the recipient at the start of the subject,
+and arrives from the relay address with the sender marked in the subject.
+
+A Command holds the recipient, subject and body of a message to a gateway.
+The Bitmessage address of the recipient must still be resolved to a public
+identity before the message is built:
+
+	c, err := gateway.Mailchuck.Send("alice@example.com", "Hello", "Hi Alice!")
+	// look up the pubkey of c.To as pub
+	o, err := c.Apply(message.NewMessage().From(id).To(pub)).Build()
+
+The subjects of messages which are received from or sent to the relay
+address are read with ParseIncoming and ParseOutgoing.
+*/
+package gateway
@@ -0,0 +1,214 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/message"
+)
+
+var (
+	// ErrInvalidEmail is returned for a string which is not an email
+	// address.
+	ErrInvalidEmail = errors.New("invalid email address")
+
+	// ErrWrongDomain is returned for an email address which is not at the
+	// domain of the gateway.
+	ErrWrongDomain = errors.New("email address not at the gateway")
+
+	// ErrInvalidName is returned by Register for a name which cannot be
+	// registered.
+	ErrInvalidName = errors.New("invalid name")
+)
+
+// Gateway describes an email gateway.
+type Gateway struct {
+	// Name identifies the gateway.
+	Name string
+
+	// Domain is the domain of the email addresses of the gateway.
+	Domain string
+
+	// Relay is the address through which email is sent and received.
+	Relay bmutil.Address
+
+	// Registration is the address to which requests to register a name
+	// and other commands are sent.
+	Registration bmutil.Address
+
+	// Unregistration is the address to which a request to unregister is
+	// sent.
+	Unregistration bmutil.Address
+
+	// FromMarker precedes the email address of the sender in the subject
+	// of a message from the relay address.
+	FromMarker string
+}
+
+// mustDecode decodes an address which is known to be valid.
+func mustDecode(s string) bmutil.Address {
+	addr, err := bmutil.DecodeAddress(s)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+// Mailchuck is the gateway at mailchuck.com, as PyBitmessage knows it.
+var Mailchuck = &Gateway{
+	Name:           "mailchuck",
+	Domain:         "mailchuck.com",
+	Relay:          mustDecode("BM-2cWim8aZwUNqxzjMxstnUMtVEUQJeezstf"),
+	Registration:   mustDecode("BM-2cVYYrhaY5Gbi3KqrX9Eae2NRNrkfrhCSA"),
+	Unregistration: mustDecode("BM-2cVMAHTRjZHCTPMue75XBK5Tco175DtJ9J"),
+	FromMarker:     "MAILCHUCK-FROM::",
+}
+
+// splitEmail returns the local part and the domain of an email address.
+func splitEmail(email string) (local, domain string, err error) {
+	i := strings.LastIndexByte(email, '@')
+	if i <= 0 || i == len(email)-1 ||
+		strings.ContainsAny(email, " \t\r\n<>") ||
+		strings.IndexByte(email, '@') != i {
+		return "", "", ErrInvalidEmail
+	}
+	return email[:i], email[i+1:], nil
+}
+
+// Email returns the email address of a Bitmessage address at the gateway.
+func (g *Gateway) Email(addr bmutil.Address) string {
+	return addr.String() + "@" + g.Domain
+}
+
+// IsGatewayEmail returns whether an email address is at the gateway.
+func (g *Gateway) IsGatewayEmail(email string) bool {
+	_, domain, err := splitEmail(email)
+	return err == nil && strings.EqualFold(domain, g.Domain)
+}
+
+// Address returns the Bitmessage address of an email address at the
+// gateway which has the form of the addresses returned by Email. An email
+// address at the gateway which is a registered name rather than a
+// Bitmessage address gives an error from bmutil.DecodeAddress.
+func (g *Gateway) Address(email string) (bmutil.Address, error) {
+	local, domain, err := splitEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(domain, g.Domain) {
+		return nil, ErrWrongDomain
+	}
+	return bmutil.DecodeAddress(local)
+}
+
+// IsGateway returns whether addr is one of the addresses of the gateway.
+func (g *Gateway) IsGateway(addr bmutil.Address) bool {
+	for _, a := range []bmutil.Address{g.Relay, g.Registration,
+		g.Unregistration} {
+		if a != nil && bmutil.AddressesEqual(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Command is a message which tells a gateway to do something.
+type Command struct {
+	To      bmutil.Address
+	Subject string
+	Body    string
+}
+
+// Apply sets the subject and body of a message to those of the command.
+// The recipient must already have been set to the public identity of To.
+func (c *Command) Apply(b *message.Builder) *message.Builder {
+	return b.Subject(c.Subject).Body(c.Body)
+}
+
+// Send returns the command which sends an email. The recipient goes at the
+// start of the subject.
+func (g *Gateway) Send(to, subject, body string) (*Command, error) {
+	if _, _, err := splitEmail(to); err != nil {
+		return nil, err
+	}
+	return &Command{
+		To:      g.Relay,
+		Subject: to + " " + subject,
+		Body:    body,
+	}, nil
+}
+
+// Register returns the command which asks for a name at the gateway for the
+// identity which sends it. The name is either the local part of the email
+// address or the whole address at the domain of the gateway.
+func (g *Gateway) Register(name string) (*Command, error) {
+	email := name
+	if !strings.Contains(name, "@") {
+		email = name + "@" + g.Domain
+	}
+	local, domain, err := splitEmail(email)
+	if err != nil {
+		return nil, ErrInvalidName
+	}
+	if !strings.EqualFold(domain, g.Domain) {
+		return nil, ErrWrongDomain
+	}
+	return &Command{
+		To:      g.Registration,
+		Subject: local + "@" + g.Domain,
+	}, nil
+}
+
+// Unregister returns the command which gives up the registration of the
+// identity which sends it.
+func (g *Gateway) Unregister() *Command {
+	return &Command{To: g.Unregistration}
+}
+
+// Status returns the command which asks for the status of the registration
+// of the identity which sends it.
+func (g *Gateway) Status() *Command {
+	return &Command{To: g.Registration, Subject: "status"}
+}
+
+// Settings returns the command which asks for the settings of the identity
+// which sends it. The gateway answers with a message which is to be edited
+// and sent back.
+func (g *Gateway) Settings() *Command {
+	return &Command{To: g.Registration, Subject: "config"}
+}
+
+// ParseIncoming reads the subject of a message from the relay address. It
+// returns the email address of the sender and the subject of the email, or
+// false if the subject does not name a sender.
+func (g *Gateway) ParseIncoming(subject string) (from, emailSubject string, ok bool) {
+	i := strings.Index(subject, g.FromMarker)
+	if g.FromMarker == "" || i < 0 {
+		return "", "", false
+	}
+	rest := subject[i+len(g.FromMarker):]
+	j := strings.Index(rest, " | ")
+	if j <= 0 || strings.ContainsAny(rest[:j], " \t") {
+		return "", "", false
+	}
+	return rest[:j], subject[:i] + rest[j+3:], true
+}
+
+// ParseOutgoing reads the subject of a message to the relay address. It
+// returns the email address of the recipient and the subject of the email,
+// or false if the subject does not start with a recipient.
+func (g *Gateway) ParseOutgoing(subject string) (to, emailSubject string, ok bool) {
+	i := strings.IndexByte(subject, ' ')
+	if i < 0 {
+		return "", "", false
+	}
+	if _, _, err := splitEmail(subject[:i]); err != nil {
+		return "", "", false
+	}
+	return subject[:i], subject[i+1:], true
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gateway_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/gateway"
+)
+
+const user = "BM-2cTux3PGRqHTEH6wyUP2sWeT4LrsGgy63z"
+
+func TestEmail(t *testing.T) {
+	g := gateway.Mailchuck
+	addr, err := bmutil.DecodeAddress(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := g.Email(addr)
+	if email != user+"@mailchuck.com" {
+		t.Errorf("Email: got %s", email)
+	}
+	got, err := g.Address(email)
+	if err != nil || !bmutil.AddressesEqual(got, addr) {
+		t.Errorf("Address: got %v, %v want %s, nil", got, err, user)
+	}
+	if got, err = g.Address(user + "@MailChuck.com"); err != nil ||
+		!bmutil.AddressesEqual(got, addr) {
+		t.Errorf("Address: got %v, %v want %s, nil", got, err, user)
+	}
+	if !g.IsGatewayEmail("alice@mailchuck.com") ||
+		g.IsGatewayEmail("alice@example.com") {
+		t.Error("IsGatewayEmail: wrong result")
+	}
+
+	tests := []struct {
+		email string
+		err   error
+	}{
+		{"", gateway.ErrInvalidEmail},
+		{user, gateway.ErrInvalidEmail},
+		{"@mailchuck.com", gateway.ErrInvalidEmail},
+		{"a@b@mailchuck.com", gateway.ErrInvalidEmail},
+		{user + "@example.com", gateway.ErrWrongDomain},
+		{"alice@mailchuck.com", bmutil.ErrUnknownAddressType},
+	}
+	for _, test := range tests {
+		if _, err := g.Address(test.email); err == nil ||
+			test.err != nil && err != test.err {
+			t.Errorf("Address(%q): got %v want %v", test.email, err, test.err)
+		}
+	}
+
+	if !g.IsGateway(g.Relay) || g.IsGateway(addr) {
+		t.Error("IsGateway: wrong result")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	g := gateway.Mailchuck
+
+	c, err := g.Send("alice@example.com", "Hello there", "Hi Alice!")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if c.To != g.Relay || c.Subject != "alice@example.com Hello there" ||
+		c.Body != "Hi Alice!" {
+		t.Errorf("Send: got %+v", c)
+	}
+	to, subject, ok := g.ParseOutgoing(c.Subject)
+	if !ok || to != "alice@example.com" || subject != "Hello there" {
+		t.Errorf("ParseOutgoing: got %q, %q, %v", to, subject, ok)
+	}
+	if _, err := g.Send("alice", "Hello", ""); err != gateway.ErrInvalidEmail {
+		t.Errorf("Send: got %v want %v", err, gateway.ErrInvalidEmail)
+	}
+
+	for _, name := range []string{"bob", "bob@mailchuck.com"} {
+		c, err := g.Register(name)
+		if err != nil || c.To != g.Registration ||
+			c.Subject != "bob@mailchuck.com" {
+			t.Errorf("Register(%q): got %+v, %v", name, c, err)
+		}
+	}
+	if _, err := g.Register("bob smith"); err != gateway.ErrInvalidName {
+		t.Errorf("Register: got %v want %v", err, gateway.ErrInvalidName)
+	}
+	if _, err := g.Register("bob@example.com"); err != gateway.ErrWrongDomain {
+		t.Errorf("Register: got %v want %v", err, gateway.ErrWrongDomain)
+	}
+
+	if c := g.Unregister(); c.To != g.Unregistration || c.Subject != "" {
+		t.Errorf("Unregister: got %+v", c)
+	}
+	if c := g.Status(); c.To != g.Registration || c.Subject != "status" {
+		t.Errorf("Status: got %+v", c)
+	}
+	if c := g.Settings(); c.To != g.Registration || c.Subject != "config" {
+		t.Errorf("Settings: got %+v", c)
+	}
+}
+
+func TestParseIncoming(t *testing.T) {
+	tests := []struct {
+		subject string
+		from    string
+		want    string
+		ok      bool
+	}{
+		{"MAILCHUCK-FROM::alice@example.com | Hello", "alice@example.com",
+			"Hello", true},
+		{"Re: MAILCHUCK-FROM::alice@example.com | Hello", "alice@example.com",
+			"Re: Hello", true},
+		{"Hello", "", "", false},
+		{"MAILCHUCK-FROM:: | Hello", "", "", false},
+	}
+	for _, test := range tests {
+		from, subject, ok := gateway.Mailchuck.ParseIncoming(test.subject)
+		if from != test.from || subject != test.want || ok != test.ok {
+			t.Errorf("ParseIncoming(%q): got %q, %q, %v want %q, %q, %v",
+				test.subject, from, subject, ok, test.from, test.want, test.ok)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package hash
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 )
@@ -58,6 +59,14 @@ func (hash *Ripe) IsEqual(target *Ripe) bool {
 	return bytes.Equal(hash[:], target[:])
 }
 
+// ConstantTimeEqual returns true if target is the same as hash. It takes the
+// same time however many bytes the two have in common, so it should be used
+// on receive paths where the result would otherwise reveal which addresses
+// a node owns.
+func (hash *Ripe) ConstantTimeEqual(target *Ripe) bool {
+	return target != nil && subtle.ConstantTimeCompare(hash[:], target[:]) == 1
+}
+
 // NewRipe returns a new Ripe from a byte slice. An error is returned if
 // the number of bytes passed in is not RipeSize.
 func NewRipe(newHash []byte) (*Ripe, error) {
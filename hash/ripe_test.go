@@ -49,6 +49,10 @@ func TestRipeHash(t *testing.T) {
 		t.Errorf("IsEqual: hash contents should not match - got: %v, want: %v",
 			h, ripe)
 	}
+	if h.ConstantTimeEqual(ripe) || h.ConstantTimeEqual(nil) {
+		t.Errorf("ConstantTimeEqual: hash contents should not match - got: %v, want: %v",
+			h, ripe)
+	}
 
 	// Set hash from byte slice and ensure contents match.
 	err = h.SetBytes(ripe.Bytes())
@@ -59,6 +63,10 @@ func TestRipeHash(t *testing.T) {
 		t.Errorf("IsEqual: hash contents mismatch - got: %v, want: %v",
 			h, ripe)
 	}
+	if !h.ConstantTimeEqual(ripe) {
+		t.Errorf("ConstantTimeEqual: hash contents mismatch - got: %v, want: %v",
+			h, ripe)
+	}
 
 	// Invalid size for SetBytes.
 	err = h.SetBytes([]byte{0x00})
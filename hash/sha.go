@@ -9,7 +9,8 @@
 package hash
 
 import (
-	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 )
@@ -25,13 +26,30 @@ const HashStringSize = ShaSize * 2
 var ErrHashStrSize = fmt.Errorf("string length must be %v chars", HashStringSize)
 
 // Sha is used in several of the bitmessage messages and common structures.
-// It typically represents a half of the double SHA512 of data.
+// It typically represents a half of the double SHA512 of data. Since it is
+// an array, a Sha can be compared with == and used directly as a map key.
 type Sha [ShaSize]byte
 
 // String returns the ShaHash as the hexadecimal string of the byte-reversed
 // hash.
 func (hash Sha) String() string {
-	return hex.EncodeToString(hash[:])
+	var buf [HashStringSize]byte
+	hex.Encode(buf[:], hash[:])
+	return string(buf[:])
+}
+
+// AppendHex appends the hexadecimal string of the hash to b and returns the
+// extended slice. It does not allocate if b has enough room.
+func (hash *Sha) AppendHex(b []byte) []byte {
+	n := len(b)
+	if cap(b)-n < HashStringSize {
+		nb := make([]byte, n, n+HashStringSize)
+		copy(nb, b)
+		b = nb
+	}
+	b = b[:n+HashStringSize]
+	hex.Encode(b[n:], hash[:])
+	return b
 }
 
 // Bytes returns the bytes which represent the hash as a byte slice.
@@ -57,10 +75,14 @@ func (hash *Sha) SetBytes(newHash []byte) error {
 
 // IsEqual returns true if target is the same as hash.
 func (hash *Sha) IsEqual(target *Sha) bool {
-	if target == nil {
-		return false
-	}
-	return bytes.Equal(hash[:], target[:])
+	return target != nil && *hash == *target
+}
+
+// ConstantTimeEqual returns true if target is the same as hash. It takes the
+// same time however many bytes the two have in common, so it should be used
+// to compare tags on receive paths.
+func (hash *Sha) ConstantTimeEqual(target *Sha) bool {
+	return target != nil && subtle.ConstantTimeCompare(hash[:], target[:]) == 1
 }
 
 // NewSha returns a new ShaHash from a byte slice. An error is returned if
@@ -83,17 +105,21 @@ func NewShaFromStr(hash string) (*Sha, error) {
 	}
 
 	// Convert string hash to bytes.
-	buf, err := hex.DecodeString(hash)
-	if err != nil {
+	var sh Sha
+	if _, err := hex.Decode(sh[:], []byte(hash)); err != nil {
 		return nil, err
 	}
 
-	return NewSha(buf)
+	return &sh, nil
 }
 
 // InventoryHash takes double sha512 of the bytes and returns the first half.
 // It calculates inventory hash of the object as required by the protocol.
 func InventoryHash(stuff []byte) *Sha {
-	hash, _ := NewSha(DoubleSha512(stuff)[:32])
-	return hash
+	sum := sha512.Sum512(stuff)
+	sum = sha512.Sum512(sum[:])
+
+	var hash Sha
+	copy(hash[:], sum[:ShaSize])
+	return &hash
 }
@@ -58,6 +58,11 @@ func TestShaHash(t *testing.T) {
 		t.Errorf("IsEqual: should return false for nil input.")
 	}
 
+	if h.ConstantTimeEqual(shaHash) || h.ConstantTimeEqual(nil) {
+		t.Errorf("ConstantTimeEqual: hash contents should not match - got: %v, want: %v",
+			h, shaHash)
+	}
+
 	// Set hash from byte slice and ensure contents match.
 	err = h.SetBytes(shaHash.Bytes())
 	if err != nil {
@@ -67,6 +72,10 @@ func TestShaHash(t *testing.T) {
 		t.Errorf("IsEqual: hash contents mismatch - got: %v, want: %v",
 			h, shaHash)
 	}
+	if !h.ConstantTimeEqual(shaHash) {
+		t.Errorf("ConstantTimeEqual: hash contents mismatch - got: %v, want: %v",
+			h, shaHash)
+	}
 
 	// Invalid size for SetBytes.
 	err = h.SetBytes([]byte{0x00})
@@ -99,6 +108,72 @@ func TestShaHashString(t *testing.T) {
 	}
 }
 
+// TestShaHashAppendHex tests that AppendHex agrees with String and only
+// allocates when there is not enough room.
+func TestShaHashAppendHex(t *testing.T) {
+	h := hash.InventoryHash([]byte("hello"))
+	want := "hash " + h.String()
+
+	if got := string(h.AppendHex([]byte("hash "))); got != want {
+		t.Errorf("AppendHex: got %s want %s", got, want)
+	}
+
+	b := make([]byte, 0, 5+hash.HashStringSize)
+	b = append(b, "hash "...)
+	allocs := testing.AllocsPerRun(100, func() {
+		h.AppendHex(b)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendHex: got %v allocations want 0", allocs)
+	}
+	if got := string(h.AppendHex(b)); got != want {
+		t.Errorf("AppendHex: got %s want %s", got, want)
+	}
+}
+
+// TestShaHashAllocs tests that comparing hashes, converting them to
+// strings and using them as map keys do not allocate more than they must.
+func TestShaHashAllocs(t *testing.T) {
+	a := hash.InventoryHash([]byte("a"))
+	b := *a
+	m := map[hash.Sha]struct{}{*a: {}}
+
+	tests := []struct {
+		name   string
+		f      func()
+		allocs float64
+	}{
+		{"IsEqual", func() { a.IsEqual(&b) }, 0},
+		{"map key", func() { _ = m[b] }, 0},
+		{"String", func() { _ = a.String() }, 1},
+	}
+
+	for _, test := range tests {
+		if got := testing.AllocsPerRun(100, test.f); got > test.allocs {
+			t.Errorf("%s: got %v allocations want %v", test.name, got,
+				test.allocs)
+		}
+	}
+}
+
+// BenchmarkShaHashString performs a benchmark on how long it takes to
+// convert a hash to a string.
+func BenchmarkShaHashString(b *testing.B) {
+	h := hash.InventoryHash([]byte("hello"))
+	for i := 0; i < b.N; i++ {
+		_ = h.String()
+	}
+}
+
+// BenchmarkInventoryHash performs a benchmark on how long it takes to
+// calculate an inventory hash.
+func BenchmarkInventoryHash(b *testing.B) {
+	data := bytes.Repeat([]byte{0xaa}, 256)
+	for i := 0; i < b.N; i++ {
+		hash.InventoryHash(data)
+	}
+}
+
 // TestNewShaHashFromStr executes tests against the NewShaHashFromStr function.
 func TestNewShaHashFromStr(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,33 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+
+	. "github.com/DanielKrawisz/bmutil"
+)
+
+// ErrChanPassphrase is returned by VerifyChan when the passphrase does not
+// produce the address of the chan.
+var ErrChanPassphrase = errors.New("passphrase does not match chan address")
+
+// VerifyChan recomputes the keys of a chan from its passphrase and checks
+// that they produce the chan's address. A chan's keys are the first
+// deterministic keys of its passphrase, so a mistyped passphrase makes a
+// different identity which receives nothing sent to the chan. It returns
+// the identity of the chan, or ErrChanPassphrase if the passphrase is wrong.
+func VerifyChan(passphrase string, address Address) (*PrivateAddress, error) {
+	keys, err := NewDeterministic(passphrase, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	id := NewPrivateAddress(keys[0], address.Version(), address.Stream())
+	if !AddressesEqual(id.Address(), address) {
+		return nil, ErrChanPassphrase
+	}
+	return id, nil
+}
@@ -11,6 +11,7 @@ import (
 
 	. "github.com/DanielKrawisz/bmutil"
 	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
 )
@@ -96,6 +97,23 @@ func TestNewHD(t *testing.T) {
 	// TODO add more test cases with key derivations
 }
 
+func TestNewPrivateIDWithPolicy(t *testing.T) {
+	key, err := NewRandom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := NewPrivateAddress(key, DefaultAddressVersion, DefaultStream)
+	desktop := pow.Desktop()
+	id := NewPrivateIDWithPolicy(addr, 0, desktop)
+	if *id.Pow() != desktop.Demand {
+		t.Errorf("Pow: got %v want %v", id.Pow(), &desktop.Demand)
+	}
+	if *id.Public().Pow() != desktop.Demand {
+		t.Errorf("Public: got %v want %v", id.Public().Pow(),
+			&desktop.Demand)
+	}
+}
+
 func TestNewDeterministicErrors(t *testing.T) {
 	// NewDeterministic
 	_, err := NewDeterministic("abcabc", 0, 1) // 0 initial zeros
@@ -103,3 +121,28 @@ func TestNewDeterministicErrors(t *testing.T) {
 		t.Error("NewDeterministic: 0 initial zeros, got no error")
 	}
 }
+
+func TestVerifyChan(t *testing.T) {
+	for _, pair := range deterministicAddressTests {
+		addr, err := DecodeAddress(pair.address[0])
+		if err != nil {
+			t.Fatalf("DecodeAddress: %v", err)
+		}
+
+		id, err := VerifyChan(pair.passphrase, addr)
+		if err != nil {
+			t.Errorf("VerifyChan %s: %v", pair.passphrase, err)
+			continue
+		}
+		if got := id.Address().String(); got != pair.address[0] {
+			t.Errorf("VerifyChan %s: got %s want %s", pair.passphrase,
+				got, pair.address[0])
+		}
+
+		// A mistyped passphrase does not match.
+		if _, err = VerifyChan(pair.passphrase+" ", addr); err != ErrChanPassphrase {
+			t.Errorf("VerifyChan %q: got %v want %v", pair.passphrase+" ",
+				err, ErrChanPassphrase)
+		}
+	}
+}
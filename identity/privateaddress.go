@@ -1,7 +1,6 @@
 package identity
 
 import (
-	"bytes"
 	"errors"
 
 	. "github.com/DanielKrawisz/bmutil"
@@ -89,7 +88,7 @@ func ImportWIF(addrStr, signingKeyWif, decryptionKeyWif string) (*PrivateAddress
 
 	// check if the address given is consistent with the private keys.
 	address := priv.Address()
-	if !bytes.Equal(address.RipeHash()[:], addr.RipeHash()[:]) {
+	if !address.RipeHash().ConstantTimeEqual(addr.RipeHash()) {
 		return nil, errors.New("address does not correspond to private keys")
 	}
 	return priv, nil
@@ -49,3 +49,12 @@ func NewPrivateID(id *PrivateAddress, behavior uint32, data *pow.Data) *PrivateI
 		pow:            data,
 	}
 }
+
+// NewPrivateIDWithPolicy constructs a PrivateID which demands the proof of
+// work of the given policy.
+func NewPrivateIDWithPolicy(id *PrivateAddress, behavior uint32,
+	policy *pow.Policy) *PrivateID {
+
+	data := policy.Demand
+	return NewPrivateID(id, behavior, &data)
+}
@@ -23,12 +23,24 @@ const BMPurposeCode = 0x80000052
 type PrivateKey struct {
 	Signing    *btcec.PrivateKey
 	Decryption *btcec.PrivateKey
+
+	// Shared, if not nil, signs in place of Signing for an identity whose
+	// signing key is split between several parties, and Signing is nil.
+	Shared Signer
+}
+
+// Signer returns the Signer which signs for this identity.
+func (pk *PrivateKey) Signer() Signer {
+	if pk.Shared != nil {
+		return pk.Shared
+	}
+	return (*keySigner)(pk.Signing)
 }
 
 // Public turns a Private identity object into Public identity object.
 func (pk *PrivateKey) Public() *PublicKey {
 	return &PublicKey{
-		Verification: (*PubKey)(pk.Signing.PubKey()),
+		Verification: pk.Signer().Verification(),
 		Encryption:   (*PubKey)(pk.Decryption.PubKey()),
 	}
 }
@@ -38,9 +50,12 @@ func (pk *PrivateKey) Hash() *hash.Ripe {
 	return pk.Public().Hash()
 }
 
-// ExportWIF exports the private keys in WIF format.
+// ExportWIF exports the private keys in WIF format. SigningWif is empty if
+// the signing key is shared.
 func (pk *PrivateKey) ExportWIF() (SigningWif, DecryptionWif string) {
-	SigningWif = EncodeWIF(pk.Signing)
+	if pk.Signing != nil {
+		SigningWif = EncodeWIF(pk.Signing)
+	}
 	DecryptionWif = EncodeWIF(pk.Decryption)
 	return
 }
@@ -30,16 +30,12 @@ func (pubkey *PubKey) uncompressed() []byte {
 
 // Bytes returns the bytes which represent the hash as a byte slice.
 func (pubkey *PubKey) Bytes() []byte {
-	return pubkey.uncompressed()[1:]
+	return pubkey.Wire().Bytes()
 }
 
 // Wire returns the PubKey in wire format.
 func (pubkey *PubKey) Wire() *wire.PubKey {
-	// There should be no error here because we should know that the key
-	// is valid at the time it is created. It should never be necessary to
-	// check for an error here, and if it is then the progarm is wrong.
-	pk, _ := wire.NewPubKey(pubkey.Bytes())
-	return pk
+	return wire.NewPubKeyFromBtcec(pubkey.Btcec())
 }
 
 // String returns the PubKey as a hexadecimal string.
@@ -52,16 +48,10 @@ func (pubkey *PubKey) IsEqual(target *PubKey) bool {
 	return pubkey.Btcec().IsEqual(target.Btcec())
 }
 
-// NewPubKey returns a new PubKey from a wire.PubKey. An error is returned if
-// the number of bytes passed in is not PubKeySize.
+// NewPubKey returns a new PubKey from a wire.PubKey. It returns
+// wire.ErrInvalidPubKey if pub is not a point on the secp256k1 curve.
 func NewPubKey(pub *wire.PubKey) (*PubKey, error) {
-	pubkey := make([]byte, wire.PubKeySize+1)
-	copy(pubkey[1:], pub[0:wire.PubKeySize])
-
-	pubkey[0] = 0x04 // uncompressed key
-
-	// Check that we can parse a btcec public key from this.
-	k, err := btcec.ParsePubKey(pubkey, btcec.S256())
+	k, err := pub.ToBtcec()
 	if err != nil {
 		return nil, err
 	}
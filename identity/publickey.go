@@ -6,7 +6,9 @@
 package identity
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/DanielKrawisz/bmutil/hash"
@@ -36,6 +38,16 @@ func (k *PublicKey) Hash() *hash.Ripe {
 	return r
 }
 
+// Fingerprint returns the SHA-256 hash of the signing and encryption keys as
+// a hex string, which people can compare to check that they have the same
+// keys.
+func (k *PublicKey) Fingerprint() string {
+	sha := sha256.New()
+	sha.Write(k.Verification.uncompressed())
+	sha.Write(k.Encryption.uncompressed())
+	return hex.EncodeToString(sha.Sum(nil))
+}
+
 // String creates a human-readible string of a PublicKey.
 func (k *PublicKey) String() string {
 	return fmt.Sprintf("{VerificationKey: %s, EncryptionKey: %s}",
@@ -0,0 +1,146 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+var (
+	// ErrNotEnoughShares is returned by SharedSigner.Sign when fewer than
+	// the threshold of participants provide a share of the signature.
+	ErrNotEnoughShares = errors.New("not enough signature shares")
+
+	// ErrInvalidSharedSignature is returned by SharedSigner.Sign when the
+	// shares combine to a signature which does not verify.
+	ErrInvalidSharedSignature = errors.New("shared signature does not verify")
+)
+
+// Signer signs with the signing key of an identity. The key may be held in
+// one place, as it is in a PrivateKey, or be split between several parties
+// who must cooperate to sign, as it is in a SharedSigner.
+type Signer interface {
+	// Verification returns the public key which verifies the signatures.
+	Verification() *PubKey
+
+	// Sign signs a hash and returns the DER encoding of the signature.
+	Sign(hash []byte) ([]byte, error)
+}
+
+// keySigner is a Signer for a signing key held in one place.
+type keySigner btcec.PrivateKey
+
+// Verification returns the public key which verifies the signatures. This
+// is part of the Signer interface.
+func (k *keySigner) Verification() *PubKey {
+	return (*PubKey)((*btcec.PrivateKey)(k).PubKey())
+}
+
+// Sign signs a hash and returns the DER encoding of the signature. This is
+// part of the Signer interface.
+func (k *keySigner) Sign(hash []byte) ([]byte, error) {
+	sig, err := (*btcec.PrivateKey)(k).Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+// Participant is one of the parties who share the signing key of a
+// SharedSigner.
+type Participant interface {
+	// SignShare returns the participant's share of a signature of the
+	// hash.
+	SignShare(hash []byte) ([]byte, error)
+}
+
+// SignatureShare is a share of a signature made by a participant.
+type SignatureShare struct {
+	// Index is the position of the participant in the Participants of
+	// the SharedSigner.
+	Index int
+
+	// Share is the share which the participant returned.
+	Share []byte
+}
+
+// SharedSigner is a Signer for a signing key which is split between
+// participants, any Threshold of whom can sign together. It lets a team
+// operate an address, such as one which publishes broadcasts, without any
+// one of them holding its signing key.
+//
+// SharedSigner only gathers the shares and checks the result. The threshold
+// scheme itself, such as a threshold ECDSA protocol, is provided by the
+// participants and by Combine, which may be backed by other machines or by
+// hardware.
+type SharedSigner struct {
+	// Key is the public key which verifies the signatures.
+	Key *PubKey
+
+	// Threshold is the number of shares which are needed to sign.
+	Threshold int
+
+	// Participants are the parties who share the signing key.
+	Participants []Participant
+
+	// Combine combines Threshold shares of a signature of the hash into
+	// the DER encoding of the signature.
+	Combine func(hash []byte, shares []SignatureShare) ([]byte, error)
+}
+
+// Verification returns the public key which verifies the signatures. This
+// is part of the Signer interface.
+func (s *SharedSigner) Verification() *PubKey {
+	return s.Key
+}
+
+// Sign asks the participants in turn for shares of a signature of the hash
+// until it has Threshold of them, and combines them. Participants which
+// return an error are passed over. It returns ErrNotEnoughShares if too few
+// participants provide a share, and ErrInvalidSharedSignature if the
+// signature does not verify with Key. This is part of the Signer interface.
+func (s *SharedSigner) Sign(hash []byte) ([]byte, error) {
+	if s.Threshold < 1 {
+		return nil, ErrNotEnoughShares
+	}
+
+	shares := make([]SignatureShare, 0, s.Threshold)
+	var lastErr error
+	for i, p := range s.Participants {
+		share, err := p.SignShare(hash)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		shares = append(shares, SignatureShare{Index: i, Share: share})
+		if len(shares) == s.Threshold {
+			break
+		}
+	}
+	if len(shares) < s.Threshold {
+		if lastErr != nil {
+			return nil, fmt.Errorf("%w: got %d of %d: %v",
+				ErrNotEnoughShares, len(shares), s.Threshold, lastErr)
+		}
+		return nil, fmt.Errorf("%w: got %d of %d", ErrNotEnoughShares,
+			len(shares), s.Threshold)
+	}
+
+	sig, err := s.Combine(hash, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the signature here, since a bad share would otherwise only be
+	// noticed by those who receive the object.
+	parsed, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil || !parsed.Verify(hash, s.Key.Btcec()) {
+		return nil, ErrInvalidSharedSignature
+	}
+	return sig, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// dealtShare is a share of a signing key split by a trusted dealer with
+// Shamir's secret sharing. It stands in for a real threshold scheme in
+// these tests; unlike one, its shares reveal the key to whoever combines
+// them.
+type dealtShare struct {
+	y       *big.Int
+	offline bool
+}
+
+func (s *dealtShare) SignShare(hash []byte) ([]byte, error) {
+	if s.offline {
+		return nil, errors.New("participant is offline")
+	}
+	return s.y.Bytes(), nil
+}
+
+// deal splits the key into n shares, any threshold of which recover it.
+// The share of participant i is the value of a random polynomial at i+1.
+func deal(t *testing.T, key *btcec.PrivateKey, threshold, n int) []*dealtShare {
+	order := btcec.S256().N
+	coeffs := []*big.Int{key.D}
+	for i := 1; i < threshold; i++ {
+		c, err := rand.Int(rand.Reader, order)
+		if err != nil {
+			t.Fatalf("rand.Int: %v", err)
+		}
+		coeffs = append(coeffs, c)
+	}
+
+	shares := make([]*dealtShare, n)
+	for i := range shares {
+		x := big.NewInt(int64(i + 1))
+		y := new(big.Int)
+		for j := len(coeffs) - 1; j >= 0; j-- {
+			y.Mul(y, x)
+			y.Add(y, coeffs[j])
+			y.Mod(y, order)
+		}
+		shares[i] = &dealtShare{y: y}
+	}
+	return shares
+}
+
+// combineDealt recovers the key from dealt shares by Lagrange
+// interpolation and signs with it.
+func combineDealt(hash []byte, shares []SignatureShare) ([]byte, error) {
+	order := btcec.S256().N
+	d := new(big.Int)
+	for _, si := range shares {
+		xi := big.NewInt(int64(si.Index + 1))
+		num, den := big.NewInt(1), big.NewInt(1)
+		for _, sj := range shares {
+			if sj.Index == si.Index {
+				continue
+			}
+			xj := big.NewInt(int64(sj.Index + 1))
+			num.Mul(num, new(big.Int).Neg(xj))
+			num.Mod(num, order)
+			den.Mul(den, new(big.Int).Sub(xi, xj))
+			den.Mod(den, order)
+		}
+		l := num.Mul(num, den.ModInverse(den, order))
+		l.Mul(l, new(big.Int).SetBytes(si.Share))
+		d.Add(d, l)
+		d.Mod(d, order)
+	}
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), d.Bytes())
+	sig, err := key.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+func TestSharedSigner(t *testing.T) {
+	pk, err := NewRandom(1)
+	if err != nil {
+		t.Fatalf("NewRandom: %v", err)
+	}
+
+	shares := deal(t, pk.Signing, 2, 3)
+	participants := make([]Participant, len(shares))
+	for i, s := range shares {
+		participants[i] = s
+	}
+	signer := &SharedSigner{
+		Key:          pk.Public().Verification,
+		Threshold:    2,
+		Participants: participants,
+		Combine:      combineDealt,
+	}
+
+	shared := &PrivateKey{
+		Decryption: pk.Decryption,
+		Shared:     signer,
+	}
+	if *shared.Hash() != *pk.Hash() {
+		t.Errorf("Hash: got %s want %s", shared.Hash(), pk.Hash())
+	}
+	if signing, _ := shared.ExportWIF(); signing != "" {
+		t.Errorf("ExportWIF: got signing key %q want none", signing)
+	}
+
+	hash := sha256.Sum256([]byte("Hello"))
+	verify := func(name string) {
+		sig, err := shared.Signer().Sign(hash[:])
+		if err != nil {
+			t.Errorf("%s: Sign: %v", name, err)
+			return
+		}
+		parsed, err := btcec.ParseDERSignature(sig, btcec.S256())
+		if err != nil || !parsed.Verify(hash[:], pk.Signing.PubKey()) {
+			t.Errorf("%s: signature does not verify", name)
+		}
+	}
+
+	verify("all participants")
+	shares[0].offline = true
+	verify("one participant offline")
+
+	shares[2].offline = true
+	if _, err := signer.Sign(hash[:]); !errors.Is(err, ErrNotEnoughShares) {
+		t.Errorf("Sign with two participants offline: got %v want %v",
+			err, ErrNotEnoughShares)
+	}
+
+	// Shares of another key combine to a signature which is refused.
+	shares[0].offline, shares[2].offline = false, false
+	other, err := NewRandom(1)
+	if err != nil {
+		t.Fatalf("NewRandom: %v", err)
+	}
+	for i, s := range deal(t, other.Signing, 2, 3) {
+		participants[i] = s
+	}
+	if _, err := signer.Sign(hash[:]); err != ErrInvalidSharedSignature {
+		t.Errorf("Sign with shares of another key: got %v want %v", err,
+			ErrInvalidSharedSignature)
+	}
+}
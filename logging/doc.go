@@ -0,0 +1,21 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package logging lets applications receive the internal events of bmutil,
+such as peers connecting and objects being rejected, in their own logging
+stack.
+
+A Logger takes a level, a message and fields given as alternating keys and
+values:
+
+	logger.Log(logging.Info, "peer connected", "addr", addr, "inbound", true)
+
+Components which log, such as peer.Config, connmgr.Config,
+outbox.ResenderConfig and relay.Validator, take a Logger which is nil by
+default, and nothing is logged unless one is given. A Logger for another
+library is written by implementing Log, or with LoggerFunc. TextLogger
+writes entries as lines of key=value pairs for simple programs.
+*/
+package logging
@@ -0,0 +1,13 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import "time"
+
+// TstSetTextLoggerNow sets the function with which a TextLogger reads the
+// time.
+func TstSetTextLoggerNow(t *TextLogger, now func() time.Time) {
+	t.now = now
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of an entry.
+type Level uint8
+
+// The levels of entries, from the least to the most severe.
+const (
+	// Debug is detail which is only wanted when looking into a problem.
+	Debug Level = iota
+
+	// Info is a normal event, such as a peer connecting.
+	Info
+
+	// Warn is something which is wrong but which is handled, such as a
+	// peer misbehaving.
+	Warn
+
+	// Error is a failure which the application may have to act on.
+	Error
+)
+
+// levelStrings is a map of levels back to their names for pretty printing.
+var levelStrings = map[Level]string{
+	Debug: "debug",
+	Info:  "info",
+	Warn:  "warn",
+	Error: "error",
+}
+
+// String returns the Level in human-readable form.
+func (l Level) String() string {
+	if s, ok := levelStrings[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Level (%d)", uint8(l))
+}
+
+// Logger receives entries. Fields are given as alternating keys, which are
+// strings, and values. A Logger must be safe for concurrent use.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc is a function which is a Logger.
+type LoggerFunc func(level Level, msg string, keyvals ...interface{})
+
+// Log calls f. This is part of the Logger interface.
+func (f LoggerFunc) Log(level Level, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+// nop is a Logger which discards every entry.
+type nop struct{}
+
+// Log discards the entry. This is part of the Logger interface.
+func (nop) Log(Level, string, ...interface{}) {}
+
+// Nop is a Logger which discards every entry.
+var Nop Logger = nop{}
+
+// Or returns l, or Nop if l is nil, so that a component can log without
+// checking whether it was given a Logger.
+func Or(l Logger) Logger {
+	if l == nil {
+		return Nop
+	}
+	return l
+}
+
+// fields is a Logger which adds fields to every entry.
+type fields struct {
+	logger  Logger
+	keyvals []interface{}
+}
+
+// Log logs the entry with the fields before its own. This is part of the
+// Logger interface.
+func (f *fields) Log(level Level, msg string, keyvals ...interface{}) {
+	all := make([]interface{}, 0, len(f.keyvals)+len(keyvals))
+	all = append(all, f.keyvals...)
+	f.logger.Log(level, msg, append(all, keyvals...)...)
+}
+
+// With returns a Logger which adds the given fields to every entry before
+// passing it to l.
+func With(l Logger, keyvals ...interface{}) Logger {
+	if l == nil || l == Nop {
+		return Nop
+	}
+	return &fields{
+		logger:  l,
+		keyvals: append([]interface{}(nil), keyvals...),
+	}
+}
+
+// TextLogger writes entries at or above a minimum level as lines of
+// key=value pairs, such as
+//
+//	time=2016-01-02T15:04:05Z level=info msg="peer connected" inbound=true
+//
+// It is safe for concurrent use.
+type TextLogger struct {
+	min Level
+	now func() time.Time
+
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// NewTextLogger returns a TextLogger which writes the entries at or above
+// min to w.
+func NewTextLogger(w io.Writer, min Level) *TextLogger {
+	return &TextLogger{
+		min: min,
+		now: time.Now,
+		w:   w,
+	}
+}
+
+// quote returns s, quoted if it is empty or has spaces, quotes, equals
+// signs or characters which cannot be printed.
+func quote(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || !strconv.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Log writes the entry if its level is high enough. A key without a value
+// is given the value "MISSING". This is part of the Logger interface.
+func (t *TextLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < t.min {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(t.now().UTC().Format(time.RFC3339))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(quote(msg))
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		b.WriteByte(' ')
+		b.WriteString(quote(fmt.Sprint(keyvals[i])))
+		b.WriteByte('=')
+		b.WriteString(quote(fmt.Sprint(value)))
+	}
+	b.WriteByte('\n')
+
+	t.mtx.Lock()
+	io.WriteString(t.w, b.String())
+	t.mtx.Unlock()
+}
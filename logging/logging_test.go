@@ -0,0 +1,61 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/logging"
+)
+
+func TestTextLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := logging.NewTextLogger(&b, logging.Info)
+	logging.TstSetTextLoggerNow(l, func() time.Time {
+		return time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	})
+
+	l.Log(logging.Debug, "hidden")
+	l.Log(logging.Info, "peer connected", "addr", "127.0.0.1:8444",
+		"inbound", true)
+	logging.With(l, "peer", 3).Log(logging.Warn, "disconnected",
+		"err", errors.New("stalled on \"inv\""), "odd")
+
+	want := `time=2016-01-02T15:04:05Z level=info msg="peer connected" addr=127.0.0.1:8444 inbound=true
+time=2016-01-02T15:04:05Z level=warn msg=disconnected peer=3 err="stalled on \"inv\"" odd=MISSING
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoggerFunc(t *testing.T) {
+	var got []interface{}
+	l := logging.LoggerFunc(func(level logging.Level, msg string,
+		keyvals ...interface{}) {
+		got = append([]interface{}{level, msg}, keyvals...)
+	})
+
+	logging.With(logging.With(l, "a", 1), "b", 2).Log(logging.Error,
+		"failed", "c", 3)
+	want := []interface{}{logging.Error, "failed", "a", 1, "b", 2, "c", 3}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got %v want %v", got, want)
+			break
+		}
+	}
+
+	if logging.Or(nil) != logging.Nop || logging.Or(l) == logging.Nop {
+		t.Error("Or: wrong logger")
+	}
+	logging.With(nil, "a", 1).Log(logging.Info, "discarded")
+}
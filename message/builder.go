@@ -0,0 +1,276 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// DefaultTTL is the time to live of a message built by a Builder if none is
+// given.
+const DefaultTTL = 4 * 24 * time.Hour
+
+// ackSize is the number of random bytes in the payload of an
+// acknowledgement.
+const ackSize = 32
+
+var (
+	// ErrNoSender is returned by a Builder which was not given the
+	// identity of the sender.
+	ErrNoSender = errors.New("no sender given")
+
+	// ErrNoRecipient is returned by a Builder which was given a nil
+	// recipient.
+	ErrNoRecipient = errors.New("no recipient given")
+
+	// ErrInvalidSubject is returned by a Builder which was given a subject
+	// that spans more than one line.
+	ErrInvalidSubject = errors.New("subject must be a single line")
+
+	// ErrAckBroadcast is returned by a Builder which was asked for an
+	// acknowledgement but has no recipient. Broadcasts have no
+	// acknowledgements.
+	ErrAckBroadcast = errors.New("broadcasts cannot request an acknowledgement")
+)
+
+// networkPow is the proof of work which is done on objects that are not
+// sent to a particular identity, which are broadcasts and acknowledgements.
+var networkPow = pow.Default
+
+// Builder puts together a message or broadcast one part at a time. Each
+// method checks its argument and returns the Builder, so calls can be
+// chained:
+//
+//	o, err := NewMessage().From(id).To(public).Subject(s).Body(b).
+//		TTL(time.Hour).RequestAck().Build()
+//
+// If a method is given an invalid argument, the Builder remembers the first
+// such error and Build returns it. If To is never called, Build creates a
+// broadcast rather than a message.
+type Builder struct {
+	from    *identity.PrivateID
+	to      identity.Public
+	subject string
+	body    string
+	ttl     time.Duration
+	anyTTL  bool
+	ack     bool
+	net     wire.BitmessageNet
+	clock   clock.Clock
+	err     error
+
+	// built is the acknowledgement included in the last object which was
+	// built.
+	built []byte
+}
+
+// NewMessage returns an empty Builder.
+func NewMessage() *Builder {
+	return &Builder{
+		ttl: DefaultTTL,
+		net: wire.MainNet,
+	}
+}
+
+// fail records err unless an error has already been recorded.
+func (b *Builder) fail(err error) *Builder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// From sets the identity of the sender.
+func (b *Builder) From(id *identity.PrivateID) *Builder {
+	if id == nil {
+		return b.fail(ErrNoSender)
+	}
+	b.from = id
+	return b
+}
+
+// To sets the recipient. Without one, a broadcast is built.
+func (b *Builder) To(id identity.Public) *Builder {
+	if id == nil {
+		return b.fail(ErrNoRecipient)
+	}
+	b.to = id
+	return b
+}
+
+// Subject sets the subject, which must be a single line.
+func (b *Builder) Subject(s string) *Builder {
+	if strings.ContainsAny(s, "\r\n") {
+		return b.fail(ErrInvalidSubject)
+	}
+	b.subject = s
+	return b
+}
+
+// Body sets the body.
+func (b *Builder) Body(s string) *Builder {
+	b.body = s
+	return b
+}
+
+// TTL sets how long the message lasts on the network. It must be positive,
+// and unless AnyTTL is called, Build also requires it to be no less than
+// MinTTL and no greater than wire.MaxObjectTTL.
+func (b *Builder) TTL(d time.Duration) *Builder {
+	if d <= 0 {
+		return b.fail(ErrInvalidTTL)
+	}
+	b.ttl = d
+	return b
+}
+
+// AnyTTL allows a time to live outside of MinTTL and wire.MaxObjectTTL. Such objects
+// are likely to be dropped by the network, so this is mainly useful for
+// testing.
+func (b *Builder) AnyTTL() *Builder {
+	b.anyTTL = true
+	return b
+}
+
+// RequestAck asks for an acknowledgement to be included in the message, so
+// that the recipient's client sends it back to the network on receipt.
+func (b *Builder) RequestAck() *Builder {
+	b.ack = true
+	return b
+}
+
+// Net sets the network on which the recipient sends the acknowledgement
+// which RequestAck asks for. The default is wire.MainNet.
+func (b *Builder) Net(net wire.BitmessageNet) *Builder {
+	b.net = net
+	return b
+}
+
+// Clock sets the Clock which tells the time from which the time to live is
+// counted. The default is clock.System.
+func (b *Builder) Clock(c clock.Clock) *Builder {
+	b.clock = c
+	return b
+}
+
+// Build signs and encrypts the message or broadcast and does the proof of
+// work on it. It returns an *obj.Message if a recipient was given and an
+// obj.Broadcast otherwise.
+func (b *Builder) Build() (obj.Object, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.from == nil {
+		return nil, ErrNoSender
+	}
+	if b.ack && b.to == nil {
+		return nil, ErrAckBroadcast
+	}
+	if !b.anyTTL {
+		if err := checkTTL(b.ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	// The protocol only supports one second precision.
+	now := clock.Or(b.clock).Now()
+	expiration := wire.Timestamp(now.Add(b.ttl))
+	content := &format.Encoding2{
+		Subject: b.subject,
+		Body:    b.body,
+	}
+
+	b.built = nil
+	if b.to == nil {
+		return b.buildBroadcast(content, expiration, now)
+	}
+
+	// The recipient sends the acknowledgement, so it goes in the stream
+	// of the recipient.
+	var ack []byte
+	if b.ack {
+		var err error
+		ack, err = newAck(b.to.Address().Stream(), b.net, expiration, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg, err := newMessage(b.from, b.to, content, expiration, ack, now)
+	if err != nil {
+		return nil, err
+	}
+	b.built = ack
+	return msg, nil
+}
+
+// Ack returns the acknowledgement which was included in the object returned
+// by the last call to Build, or nil if there was none. It is the encoded
+// network message which the recipient will send.
+func (b *Builder) Ack() []byte {
+	return b.built
+}
+
+// buildBroadcast creates a broadcast from the sender. Broadcasts from v4
+// addresses are tagged.
+func (b *Builder) buildBroadcast(content format.Encoding,
+	expiration, now time.Time) (obj.Object, error) {
+
+	bm := &cipher.Bitmessage{
+		Public:  b.from.Public(),
+		Content: content,
+	}
+
+	address := b.from.Address()
+	var tag *hash.Sha
+	if address.Version() == 4 {
+		tag = bmutil.Tag(address)
+	}
+
+	broadcast, err := cipher.SignAndEncryptBroadcast(expiration, bm, tag, b.from)
+	if err != nil {
+		return nil, err
+	}
+
+	o := broadcast.Object()
+	doPow(o, networkPow, expiration, now)
+	return o, nil
+}
+
+// newAck creates an acknowledgement, which is an object message with a
+// random payload that has been encoded as a network message. The proof of
+// work is done on it so that the recipient can send it as it is on the
+// given network.
+func newAck(stream uint64, net wire.BitmessageNet,
+	expiration, now time.Time) ([]byte, error) {
+	payload := make([]byte, ackSize)
+	if _, err := rand.Read(payload); err != nil {
+		return nil, err
+	}
+
+	msg := wire.NewMsgObject(wire.NewObjectHeader(0, expiration,
+		wire.ObjectTypeMsg, obj.MessageVersion, stream), payload)
+	doPow(msg, networkPow, expiration, now)
+
+	var buf bytes.Buffer
+	if err := wire.WriteMessage(&buf, msg, net); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestBuilderMessage(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	to := recipient(t)
+
+	b := message.NewMessage().From(from).To(to.Public()).Subject("Hello").
+		Body("Hey there!").TTL(time.Hour).RequestAck()
+	o, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	msg, ok := o.(*obj.Message)
+	if !ok {
+		t.Fatalf("Build: got %T want *obj.Message", o)
+	}
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(to)
+	r, err := message.Receive(msg.MsgObject(), keyring)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	content, ok := r.Bitmessage.Content.(*format.Encoding2)
+	if !ok {
+		t.Fatalf("content: got %T want *format.Encoding2", r.Bitmessage.Content)
+	}
+	if content.Subject != "Hello" || content.Body != "Hey there!" {
+		t.Errorf("content: got %q, %q", content.Subject, content.Body)
+	}
+
+	// The acknowledgement is a network message which can be sent as it is.
+	if !bytes.Equal(r.Ack, b.Ack()) {
+		t.Errorf("Ack: got %x want %x", r.Ack, b.Ack())
+	}
+	m, _, err := wire.ReadMessage(bytes.NewReader(r.Ack), wire.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	ack, ok := m.(*wire.MsgObject)
+	if !ok {
+		t.Fatalf("ack: got %T want *wire.MsgObject", m)
+	}
+	if !ack.CheckPow(lowPow, time.Now()) {
+		t.Error("ack: insufficient proof of work")
+	}
+	if got, want := ack.Header().StreamNumber, to.Address().Stream(); got != want {
+		t.Errorf("ack: got stream %d want %d", got, want)
+	}
+}
+
+// TestBuilderAckNet tests that the acknowledgement is encoded for the
+// network given to the Builder.
+func TestBuilderAckNet(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	b := message.NewMessage().From(sender(t)).To(recipient(t).Public()).
+		TTL(time.Hour).RequestAck().Net(wire.TestNet)
+	if _, err := b.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, _, err := wire.ReadMessage(bytes.NewReader(b.Ack()), wire.TestNet); err != nil {
+		t.Errorf("ReadMessage on TestNet: %v", err)
+	}
+	if _, _, err := wire.ReadMessage(bytes.NewReader(b.Ack()), wire.MainNet); err == nil {
+		t.Error("ReadMessage on MainNet: no error")
+	}
+}
+
+func TestBuilderBroadcast(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	b := message.NewMessage().From(from).Subject("News").Body("Hey everyone!").
+		TTL(time.Hour)
+	o, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, ok := o.(obj.Broadcast); !ok {
+		t.Fatalf("Build: got %T want obj.Broadcast", o)
+	}
+	if b.Ack() != nil {
+		t.Errorf("Ack: got %x want nil", b.Ack())
+	}
+
+	msg, err := wire.DecodeMsgObject(wire.Encode(o))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.Subscribe(from.Address())
+	r, err := message.Receive(msg, keyring)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if !r.IsBroadcast() {
+		t.Error("did not receive a broadcast")
+	}
+}
+
+// TestBuilderClock tests building and receiving objects with a clock which
+// is not the system clock.
+func TestBuilderClock(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	c := clock.NewManual(time.Unix(1500000000, 0))
+	o, err := message.NewMessage().From(from).Body("Hey everyone!").
+		TTL(time.Hour).Clock(c).Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if want := c.Now().Add(time.Hour); !o.Header().Expiration().Equal(want) {
+		t.Errorf("Expiration: got %v want %v", o.Header().Expiration(), want)
+	}
+
+	msg, err := wire.DecodeMsgObject(wire.Encode(o))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.Subscribe(from.Address())
+	_, err = message.Receive(msg, keyring)
+	checkReject(t, "system clock", err, message.RejectExpired)
+
+	keyring = message.NewKeyring(&message.KeyringConfig{Pow: &lowPow, Clock: c})
+	keyring.Subscribe(from.Address())
+	if _, err = message.Receive(msg, keyring); err != nil {
+		t.Errorf("Receive: %v", err)
+	}
+	c.Advance(2 * time.Hour)
+	_, err = message.Receive(msg, keyring)
+	checkReject(t, "later", err, message.RejectExpired)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+
+	tests := []struct {
+		name string
+		b    *message.Builder
+		want error
+	}{
+		{"no sender", message.NewMessage().To(to.Public()), message.ErrNoSender},
+		{"nil sender", message.NewMessage().From(nil), message.ErrNoSender},
+		{"nil recipient", message.NewMessage().From(from).To(nil),
+			message.ErrNoRecipient},
+		{"subject", message.NewMessage().From(from).Subject("a\nb"),
+			message.ErrInvalidSubject},
+		{"ttl", message.NewMessage().From(from).TTL(0), message.ErrInvalidTTL},
+		{"short ttl", message.NewMessage().From(from).TTL(time.Minute),
+			message.ErrInvalidTTL},
+		{"long ttl", message.NewMessage().From(from).TTL(wire.MaxObjectTTL + time.Second),
+			message.ErrInvalidTTL},
+		{"ack", message.NewMessage().From(from).RequestAck(),
+			message.ErrAckBroadcast},
+
+		// The first error is kept.
+		{"first", message.NewMessage().TTL(-time.Hour).Subject("a\nb"),
+			message.ErrInvalidTTL},
+	}
+
+	for _, test := range tests {
+		if _, err := test.b.Build(); err != test.want {
+			t.Errorf("%s: got %v want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBuilderAnyTTL(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	o, err := message.NewMessage().From(from).TTL(time.Minute).AnyTTL().Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if ttl := o.Header().Expiration().Sub(time.Now()); ttl > time.Minute {
+		t.Errorf("ttl: got %s want no more than %s", ttl, time.Minute)
+	}
+
+	// A time to live which is not positive is never allowed.
+	if _, err := message.NewMessage().From(from).AnyTTL().TTL(0).
+		Build(); err != message.ErrInvalidTTL {
+		t.Errorf("got %v want %v", err, message.ErrInvalidTTL)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+const (
+	// MinTTL is the shortest time to live which an object is given. An
+	// object which lives for less time is likely to expire before it has
+	// spread across the network, and is dropped by nodes whose clocks are
+	// ahead of ours.
+	MinTTL = 5 * time.Minute
+)
+
+// ErrInvalidTTL is returned by Compose when the time to live is less than
+// MinTTL or greater than wire.MaxObjectTTL. It is the same error which
+// cipher.GeneratePubKey returns.
+var ErrInvalidTTL = cipher.ErrInvalidTTL
+
+// checkTTL returns ErrInvalidTTL if ttl is outside the bounds which the
+// network accepts.
+func checkTTL(ttl time.Duration) error {
+	if ttl < MinTTL || ttl > wire.MaxObjectTTL {
+		return ErrInvalidTTL
+	}
+	return nil
+}
+
+// Compose creates a message from one identity to another which expires
+// after ttl. The content is signed with the sender's private key and
+// encrypted to the recipient's public key, and then proof of work is done
+// at the difficulty which the recipient demands. It returns an object which
+// is ready to be sent. c tells the time from which ttl is counted; if it is
+// nil, clock.System is used.
+//
+// Compose does not add an acknowledgement to the message, and always keeps
+// the time to live within MinTTL and wire.MaxObjectTTL. Use a Builder to request an
+// acknowledgement or to go outside those bounds.
+func Compose(from *identity.PrivateID, to identity.Public,
+	content format.Encoding, ttl time.Duration,
+	c clock.Clock) (*wire.MsgObject, error) {
+
+	if err := checkTTL(ttl); err != nil {
+		return nil, err
+	}
+
+	// The protocol only supports one second precision.
+	now := clock.Or(c).Now()
+	expiration := wire.Timestamp(now.Add(ttl))
+
+	msg, err := newMessage(from, to, content, expiration, nil, now)
+	if err != nil {
+		return nil, err
+	}
+	return msg.MsgObject(), nil
+}
+
+// newMessage creates a message, including the given acknowledgement, and
+// does the proof of work on it as of now.
+func newMessage(from *identity.PrivateID, to identity.Public,
+	content format.Encoding, expiration time.Time, ack []byte,
+	now time.Time) (*obj.Message, error) {
+
+	address := to.Address()
+	bm := &cipher.Bitmessage{
+		Public:      from.Public(),
+		Destination: address.RipeHash(),
+		Content:     content,
+	}
+
+	message, err := cipher.SignAndEncryptMessage(expiration, address.Stream(),
+		bm, ack, from.PrivateKey(), to.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	msg := message.Object()
+	doPow(msg, *to.Pow(), expiration, now)
+	return msg, nil
+}
+
+// doPow does the proof of work on o and sets its nonce. The time to live
+// of o is taken to be the time from now until its expiration.
+func doPow(o obj.Object, data pow.Data, expiration, now time.Time) {
+	ttl := uint64(expiration.Unix() - now.Unix())
+	o.Header().Nonce = pow.Do(wire.Encode(o), ttl, data)
+}
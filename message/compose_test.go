@@ -0,0 +1,118 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// lowPow is easy enough that tests do not have to wait for proof of work.
+var lowPow = pow.Data{
+	NonceTrialsPerByte: 1,
+	ExtraBytes:         1,
+}
+
+func newID(t *testing.T, address, signingKey, decryptionKey string,
+	data *pow.Data) *identity.PrivateID {
+
+	a, err := identity.ImportWIF(address, signingKey, decryptionKey)
+	if err != nil {
+		t.Fatalf("ImportWIF: %v", err)
+	}
+	return identity.NewPrivateID(a, 0, data)
+}
+
+// sender returns an identity with the default proof of work, since a
+// public identity which is decoded never demands less than that.
+func sender(t *testing.T) *identity.PrivateID {
+	return newID(t, "BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb", nil)
+}
+
+func recipient(t *testing.T) *identity.PrivateID {
+	return newID(t, "BM-2cTLMh1CufXWQ9co4CWzD9muDZP4a7N4MA",
+		"5Jw6Gtjy8RCZ5BmTtyx3VykzdXvX4WyWsGu2wLrhfTv8zgKfo7C",
+		"5JY8Lsf5cmNTrXXj1e7FkvCZVYgsK7tAiiocTDtVKLBvQm1EsFw", &lowPow)
+}
+
+func TestCompose(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+	content := &format.Encoding2{
+		Subject: "Hello",
+		Body:    "Hey there!",
+	}
+
+	msg, err := message.Compose(from, to.Public(), content, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	if !msg.CheckPow(lowPow, time.Now()) {
+		t.Error("insufficient proof of work")
+	}
+	if got, want := msg.Header().StreamNumber,
+		to.Address().Stream(); got != want {
+		t.Errorf("stream: got %d want %d", got, want)
+	}
+
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	m, err := cipher.TryDecryptAndVerifyMessage(o.(*obj.Message), to)
+	if err != nil {
+		t.Fatalf("TryDecryptAndVerifyMessage: %v", err)
+	}
+	bm := m.Bitmessage()
+	if got, want := bm.Public.Address().String(),
+		from.Address().String(); got != want {
+		t.Errorf("sender: got %s want %s", got, want)
+	}
+	if got, want := string(bm.Content.Message()),
+		string(content.Message()); got != want {
+		t.Errorf("content: got %q want %q", got, want)
+	}
+}
+
+// TestComposeClock tests that the time to live of a composed message is
+// counted from the time of the given clock.
+func TestComposeClock(t *testing.T) {
+	c := clock.NewManual(time.Unix(1500000000, 0))
+	msg, err := message.Compose(sender(t), recipient(t).Public(),
+		&format.Encoding1{Body: "Hey there!"}, time.Hour, c)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+	if got, want := msg.Header().Expiration(),
+		c.Now().Add(time.Hour); !got.Equal(want) {
+		t.Errorf("expiration: got %v want %v", got, want)
+	}
+}
+
+func TestComposeInvalidTTL(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+	content := &format.Encoding1{Body: "Hey there!"}
+
+	for _, ttl := range []time.Duration{0, -time.Hour,
+		message.MinTTL - time.Second, wire.MaxObjectTTL + time.Second} {
+		if _, err := message.Compose(from, to.Public(), content,
+			ttl, nil); err != message.ErrInvalidTTL {
+			t.Errorf("ttl %s: got %v want %v", ttl, err, message.ErrInvalidTTL)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// contactsVersion is the current version of the contact list format.
+const contactsVersion = 1
+
+var (
+	// ErrUnknownContactsVersion is returned when a contact list has a
+	// format which is not understood.
+	ErrUnknownContactsVersion = errors.New("unknown version of contact list")
+
+	// ErrInvalidContact is returned when a contact in a contact list has
+	// an invalid address, a public identity which is not of its address,
+	// or is audited without a public identity.
+	ErrInvalidContact = errors.New("invalid contact")
+)
+
+// Contact is a public identity or subscription in a contact list, which is
+// how contacts are shared between devices. It never holds private keys.
+type Contact struct {
+	Address bmutil.Address
+
+	// Public is the public identity of the address if it is known, and
+	// nil otherwise.
+	Public identity.Public
+
+	// Subscribed is whether the broadcasts of the address are read.
+	Subscribed bool
+
+	// Audited is whether the traffic of the address is tracked. It is
+	// only true if Public is known.
+	Audited bool
+
+	// Label is a name which the user gave the address.
+	Label string
+
+	// Notes are the user's notes on the address, such as how far it is
+	// trusted.
+	Notes string
+}
+
+// serializedContact is the form of a Contact in a contact list. The public
+// identity is in the form written by identity.Encode.
+type serializedContact struct {
+	Address    string
+	Public     []byte `json:",omitempty"`
+	Subscribed bool   `json:",omitempty"`
+	Audited    bool   `json:",omitempty"`
+	Label      string `json:",omitempty"`
+	Notes      string `json:",omitempty"`
+}
+
+// serializedContacts is the form of a contact list.
+type serializedContacts struct {
+	Version  int
+	Contacts []serializedContact
+}
+
+// Contacts returns the watched identities, including those which are
+// audited, and the subscriptions in the keyring. Our own identities are
+// left out.
+func (k *Keyring) Contacts() []*Contact {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	var contacts []*Contact
+	for _, e := range k.entries {
+		if e.Watched == nil && !e.Subscribed {
+			continue
+		}
+		contacts = append(contacts, &Contact{
+			Address:    e.Address,
+			Public:     e.Watched,
+			Subscribed: e.Subscribed,
+			Audited:    e.Audited,
+			Label:      e.Label,
+			Notes:      e.Notes,
+		})
+	}
+	return contacts
+}
+
+// WriteContacts writes a contact list.
+func WriteContacts(w io.Writer, contacts []*Contact) error {
+	sc := &serializedContacts{
+		Version:  contactsVersion,
+		Contacts: make([]serializedContact, len(contacts)),
+	}
+	for i, c := range contacts {
+		s := &sc.Contacts[i]
+		s.Address = c.Address.String()
+		s.Subscribed = c.Subscribed
+		s.Audited = c.Audited && c.Public != nil
+		s.Label = c.Label
+		s.Notes = c.Notes
+		if c.Public != nil {
+			var b bytes.Buffer
+			if err := identity.Encode(&b, c.Public); err != nil {
+				return err
+			}
+			s.Public = b.Bytes()
+		}
+	}
+	return json.NewEncoder(w).Encode(sc)
+}
+
+// ReadContacts reads a contact list written by WriteContacts. It returns
+// ErrInvalidContact if any contact in it is invalid.
+func ReadContacts(r io.Reader) ([]*Contact, error) {
+	var sc serializedContacts
+	if err := json.NewDecoder(r).Decode(&sc); err != nil {
+		return nil, err
+	}
+	if sc.Version != contactsVersion {
+		return nil, ErrUnknownContactsVersion
+	}
+
+	contacts := make([]*Contact, len(sc.Contacts))
+	for i, s := range sc.Contacts {
+		address, err := bmutil.DecodeAddress(s.Address)
+		if err != nil {
+			return nil, ErrInvalidContact
+		}
+		c := &Contact{
+			Address:    address,
+			Subscribed: s.Subscribed,
+			Audited:    s.Audited,
+			Label:      s.Label,
+			Notes:      s.Notes,
+		}
+		if s.Audited && s.Public == nil {
+			return nil, ErrInvalidContact
+		}
+		if s.Public != nil {
+			c.Public, err = identity.Decode(bytes.NewReader(s.Public))
+			if err != nil || !bmutil.AddressesEqual(c.Public.Address(), address) {
+				return nil, ErrInvalidContact
+			}
+		}
+		contacts[i] = c
+	}
+	return contacts, nil
+}
+
+// MergeContacts adds a contact list to the keyring. Identities are watched
+// or audited and addresses subscribed to, but what is already in the
+// keyring is kept:
+// a watched identity is not replaced, and neither is a label or notes which
+// have been given. It returns the number of contacts which changed the
+// keyring.
+func (k *Keyring) MergeContacts(contacts []*Contact) (int, error) {
+	changed := 0
+	for _, c := range contacts {
+		old := k.Lookup(c.Address)
+		if old == nil {
+			old = &Entry{}
+		}
+
+		var merged bool
+		if c.Public != nil && old.Watched == nil {
+			if err := k.Watch(c.Public); err != nil {
+				return changed, err
+			}
+			merged = true
+		}
+		if c.Audited && c.Public != nil && !old.Audited {
+			id := old.Watched
+			if id == nil {
+				id = c.Public
+			}
+			if err := k.Audit(id); err != nil {
+				return changed, err
+			}
+			merged = true
+		}
+		if c.Subscribed && !old.Subscribed {
+			if err := k.Subscribe(c.Address); err != nil {
+				return changed, err
+			}
+			merged = true
+		}
+
+		label, notes := old.Label, old.Notes
+		if label == "" {
+			label = c.Label
+		}
+		if notes == "" {
+			notes = c.Notes
+		}
+		if label != old.Label || notes != old.Notes {
+			if err := k.SetLabel(c.Address, label, notes); err != nil {
+				return changed, err
+			}
+
+			// A label is only kept for an address in the keyring.
+			merged = merged || k.Lookup(c.Address) != nil
+		}
+
+		if merged {
+			changed++
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/message"
+)
+
+func TestContacts(t *testing.T) {
+	own := sender(t)
+	contact := recipient(t)
+
+	// The list of one device has our own identity, which is left out, and
+	// an audited identity with a label.
+	laptop := message.NewKeyring(nil)
+	laptop.AddIdentity(own)
+	laptop.Audit(contact.Public())
+	laptop.SetLabel(contact.Address(), "Alice", "met in person")
+
+	var b bytes.Buffer
+	if err := message.WriteContacts(&b, laptop.Contacts()); err != nil {
+		t.Fatalf("WriteContacts: %v", err)
+	}
+	contacts, err := message.ReadContacts(&b)
+	if err != nil {
+		t.Fatalf("ReadContacts: %v", err)
+	}
+	if len(contacts) != 1 {
+		t.Fatalf("ReadContacts: got %d contacts want 1", len(contacts))
+	}
+	c := contacts[0]
+	if c.Public == nil || c.Public.Address().String() != contact.Address().String() ||
+		!c.Audited || c.Label != "Alice" || c.Notes != "met in person" ||
+		c.Subscribed {
+		t.Errorf("ReadContacts: got %+v", c)
+	}
+
+	// The other device already subscribes to the address and has a label
+	// of its own for it, which is kept.
+	phone := message.NewKeyring(nil)
+	phone.Subscribe(contact.Address())
+	phone.SetLabel(contact.Address(), "Alice B.", "")
+
+	n, err := phone.MergeContacts(contacts)
+	if err != nil {
+		t.Fatalf("MergeContacts: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("MergeContacts: got %d changed want 1", n)
+	}
+	e := phone.Lookup(contact.Address())
+	if e == nil || e.Watched == nil || !e.Audited || !e.Subscribed ||
+		e.Label != "Alice B." || e.Notes != "met in person" {
+		t.Errorf("merged entry: got %+v", e)
+	}
+
+	// Merging again changes nothing.
+	if n, err = phone.MergeContacts(contacts); err != nil || n != 0 {
+		t.Errorf("MergeContacts: got %d, %v want 0, nil", n, err)
+	}
+
+	// A label alone does not add an address.
+	n, err = phone.MergeContacts([]*message.Contact{
+		{Address: own.Address(), Label: "me"},
+	})
+	if err != nil || n != 0 || phone.Lookup(own.Address()) != nil {
+		t.Errorf("MergeContacts of a label: got %d, %v", n, err)
+	}
+}
+
+func TestReadContactsErrors(t *testing.T) {
+	own := sender(t)
+	contact := recipient(t)
+
+	var b bytes.Buffer
+	message.WriteContacts(&b, []*message.Contact{
+		{Address: own.Address(), Public: contact.Public()},
+	})
+	mismatched := b.String()
+
+	tests := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"version", `{"Version":2,"Contacts":[]}`,
+			message.ErrUnknownContactsVersion},
+		{"address", `{"Version":1,"Contacts":[{"Address":"BM-xyz"}]}`,
+			message.ErrInvalidContact},
+		{"public", `{"Version":1,"Contacts":[{"Address":"` +
+			own.Address().String() + `","Public":"AAAA"}]}`,
+			message.ErrInvalidContact},
+		{"mismatched", mismatched, message.ErrInvalidContact},
+		{"audited", `{"Version":1,"Contacts":[{"Address":"` +
+			own.Address().String() + `","Audited":true}]}`,
+			message.ErrInvalidContact},
+	}
+	for _, test := range tests {
+		_, err := message.ReadContacts(strings.NewReader(test.in))
+		if err != test.err {
+			t.Errorf("%s: got %v want %v", test.name, err, test.err)
+		}
+	}
+}
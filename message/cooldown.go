@@ -0,0 +1,218 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+const (
+	// DefaultGetPubKeyCooldown is the time during which further requests
+	// for the same pubkey are ignored. It should be no longer than the time
+	// to live of the pubkeys sent in answer, since once a pubkey expires
+	// it must be possible to request it again.
+	DefaultGetPubKeyCooldown = 24 * time.Hour
+
+	// DefaultGetPubKeyCooldownSize is the number of requests which a
+	// GetPubKeyCooldown remembers.
+	DefaultGetPubKeyCooldownSize = 100000
+)
+
+// CooldownConfig is the configuration of a GetPubKeyCooldown. Zero values
+// are replaced by the defaults.
+type CooldownConfig struct {
+	// Cooldown is the time during which repeated requests for a pubkey
+	// are ignored. The default is DefaultGetPubKeyCooldown.
+	Cooldown time.Duration
+
+	// Size is the number of requests which are remembered. The default
+	// is DefaultGetPubKeyCooldownSize.
+	Size int
+
+	// Clock tells the time at which requests are allowed and cool down.
+	// The default is clock.System.
+	Clock clock.Clock
+}
+
+// requestKey identifies what a getpubkey request asks for: the ripe of a v2
+// or v3 address, or the tag of a v4 address.
+type requestKey struct {
+	ripe hash.Ripe
+	tag  hash.Sha
+}
+
+// GetPubKeyCooldown remembers the getpubkey requests which have recently
+// been answered or relayed, so that requests for the same pubkey can be
+// ignored until a cooldown has passed. Getpubkey objects are cheap to make
+// with a new nonce, and without a cooldown a flood of them can make a node
+// publish the same pubkey over and over or relay the same request to every
+// peer.
+//
+// A GetPubKeyCooldown remembers a bounded number of requests. When it is
+// full, it forgets the request which was allowed longest ago, so that a
+// flood of requests for different pubkeys cannot stop it from allowing new
+// ones. Requests which have cooled down are forgotten as new ones are
+// allowed, and all at once by Prune, which Start calls on a schedule. A
+// GetPubKeyCooldown is safe for concurrent use.
+type GetPubKeyCooldown struct {
+	cooldown time.Duration
+	size     int
+	now      func() time.Time
+
+	mtx     sync.Mutex
+	order   *list.List // of *cooldownEntry, front is most recently allowed
+	entries map[requestKey]*list.Element
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// cooldownEntry is a request remembered by a GetPubKeyCooldown.
+type cooldownEntry struct {
+	key     requestKey
+	allowed time.Time
+}
+
+// NewGetPubKeyCooldown returns a new GetPubKeyCooldown. cfg may be nil, in
+// which case the defaults are used.
+func NewGetPubKeyCooldown(cfg *CooldownConfig) *GetPubKeyCooldown {
+	if cfg == nil {
+		cfg = &CooldownConfig{}
+	}
+
+	c := &GetPubKeyCooldown{
+		cooldown: cfg.Cooldown,
+		size:     cfg.Size,
+		now:      clock.Or(cfg.Clock).Now,
+		order:    list.New(),
+		entries:  make(map[requestKey]*list.Element),
+	}
+	if c.cooldown == 0 {
+		c.cooldown = DefaultGetPubKeyCooldown
+	}
+	if c.size <= 0 {
+		c.size = DefaultGetPubKeyCooldownSize
+	}
+	return c
+}
+
+// keyOf returns the key of a getpubkey request.
+func keyOf(request *obj.GetPubKey) requestKey {
+	if request.Header().Version >= obj.TagGetPubKeyVersion || request.Ripe == nil {
+		return requestKey{tag: request.Tag}
+	}
+	return requestKey{ripe: *request.Ripe}
+}
+
+// Allow returns whether the request should be answered or relayed, which it
+// should be unless a request for the same pubkey was allowed within the
+// cooldown. If it is allowed, the cooldown for the pubkey starts again.
+func (c *GetPubKeyCooldown) Allow(request *obj.GetPubKey) bool {
+	key := keyOf(request)
+	now := c.now()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cooldownEntry)
+		if now.Sub(entry.allowed) < c.cooldown {
+			return false
+		}
+		entry.allowed = now
+		c.order.MoveToFront(e)
+		return true
+	}
+
+	c.prune(now)
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&cooldownEntry{key: key, allowed: now})
+	return true
+}
+
+// Forget removes the request from the cooldown, so that the next request
+// for the same pubkey is allowed. It is for when answering or relaying an
+// allowed request failed.
+func (c *GetPubKeyCooldown) Forget(request *obj.GetPubKey) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if e, ok := c.entries[keyOf(request)]; ok {
+		c.remove(e)
+	}
+}
+
+// remove forgets a request. It must be called with the mutex held.
+func (c *GetPubKeyCooldown) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*cooldownEntry).key)
+	c.order.Remove(e)
+}
+
+// prune removes the requests which have cooled down and returns how many
+// there were. Since the requests are in the order in which they were
+// allowed, only those which are removed are looked at. It must be called
+// with the mutex held.
+func (c *GetPubKeyCooldown) prune(now time.Time) int {
+	n := 0
+	for e := c.order.Back(); e != nil; e = c.order.Back() {
+		if now.Sub(e.Value.(*cooldownEntry).allowed) < c.cooldown {
+			break
+		}
+		c.remove(e)
+		n++
+	}
+	return n
+}
+
+// Prune forgets the requests which have cooled down and returns how many
+// there were.
+func (c *GetPubKeyCooldown) Prune() int {
+	now := c.now()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.prune(now)
+}
+
+// Len returns the number of requests remembered.
+func (c *GetPubKeyCooldown) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.order.Len()
+}
+
+// Start begins calling Prune at the given interval, so that the memory held
+// by requests which have cooled down is freed even if no new requests
+// arrive.
+func (c *GetPubKeyCooldown) Start(interval time.Duration) {
+	c.quit = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Prune()
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the pruning begun by Start.
+func (c *GetPubKeyCooldown) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
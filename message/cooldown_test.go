@@ -0,0 +1,114 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TestGetPubKeyCooldown tests that repeated requests for the same pubkey
+// are refused until the cooldown has passed.
+func TestGetPubKeyCooldown(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	c := message.NewGetPubKeyCooldown(&message.CooldownConfig{
+		Cooldown: time.Hour,
+		Size:     3,
+		Clock:    clock.Func(func() time.Time { return now }),
+	})
+
+	request := func(version uint64, b byte) *obj.GetPubKey {
+		var ripe hash.Ripe
+		ripe[19] = b
+		var address bmutil.Address
+		if version == 4 {
+			address, _ = bmutil.NewAddress(4, 1, &ripe)
+		} else {
+			address, _ = bmutil.NewDepricatedAddress(version, 1, &ripe)
+		}
+		return obj.NewGetPubKey(0, now.Add(time.Hour), address)
+	}
+
+	v3, v4 := request(3, 1), request(4, 1)
+	if !c.Allow(v3) || !c.Allow(v4) {
+		t.Fatal("Allow: first requests refused")
+	}
+
+	// Another request for the same pubkey, with a different nonce, is
+	// refused.
+	again := request(4, 1)
+	again.Header().Nonce = 1
+	if c.Allow(again) || c.Allow(v3) {
+		t.Error("Allow: repeated request allowed within the cooldown")
+	}
+
+	// The cooldown is full once a third pubkey is requested, and a
+	// fourth makes it forget the request allowed longest ago.
+	now = now.Add(time.Minute)
+	if !c.Allow(request(4, 2)) {
+		t.Error("Allow: request for another pubkey refused")
+	}
+	if !c.Allow(request(4, 3)) {
+		t.Error("Allow: request refused while the cooldown is full")
+	}
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len: got %d want %d", n, 3)
+	}
+	if c.Allow(request(4, 2)) {
+		t.Error("Allow: recent request forgotten")
+	}
+	if !c.Allow(v3) {
+		t.Error("Allow: oldest request was not forgotten")
+	}
+
+	c.Forget(v3)
+	if !c.Allow(v3) {
+		t.Error("Allow: forgotten request refused")
+	}
+
+	now = now.Add(time.Hour)
+	if !c.Allow(request(4, 4)) {
+		t.Error("Allow: request refused after the cooldown")
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len: got %d want %d", n, 1)
+	}
+	if !c.Allow(v3) {
+		t.Error("Allow: repeated request refused after the cooldown")
+	}
+
+	now = now.Add(time.Hour)
+	if n := c.Prune(); n != 2 {
+		t.Errorf("Prune: got %d want %d", n, 2)
+	}
+}
+
+// TestGetPubKeyCooldownStart tests that a started GetPubKeyCooldown prunes
+// the requests which have cooled down.
+func TestGetPubKeyCooldownStart(t *testing.T) {
+	c := message.NewGetPubKeyCooldown(&message.CooldownConfig{
+		Cooldown: time.Millisecond,
+	})
+	var ripe hash.Ripe
+	address, _ := bmutil.NewAddress(4, 1, &ripe)
+	if !c.Allow(obj.NewGetPubKey(0, time.Now().Add(time.Hour), address)) {
+		t.Fatal("Allow: first request refused")
+	}
+
+	c.Start(time.Millisecond)
+	defer c.Stop()
+	for i := 0; c.Len() != 0; i++ {
+		if i == 1000 {
+			t.Fatal("cooled down request was not pruned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
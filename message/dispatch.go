@@ -0,0 +1,269 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// Handlers are the functions which a Dispatcher calls with the objects that
+// concern us. Any of them may be nil.
+type Handlers struct {
+	// OnMsg is called with each message which has been received.
+	OnMsg func(r *Received)
+
+	// OnBroadcast is called with each broadcast which has been received.
+	OnBroadcast func(r *Received)
+
+	// OnPubKey is called with the identity in each pubkey of an address
+	// in the keyring.
+	OnPubKey func(msg *wire.MsgObject, id identity.Public)
+
+	// OnGetPubKey is called with our identity for each request for its
+	// pubkey.
+	OnGetPubKey func(msg *wire.MsgObject, id *identity.PrivateID)
+
+	// OnAuditedGetPubKey is called with an audited identity for each
+	// request for its pubkey. Without the private keys, the request can
+	// only be noted and not answered.
+	OnAuditedGetPubKey func(msg *wire.MsgObject, id identity.Public)
+}
+
+// Dispatcher passes each object that arrives to the handlers which are
+// interested in it, so that the code which receives objects from the
+// network does not need to know what the application does with them.
+//
+// Handlers are registered either for every object or for the objects which
+// concern one address: messages to it, broadcasts and pubkeys from it, and
+// requests for its pubkey. A Dispatcher is safe for concurrent use.
+type Dispatcher struct {
+	keyring *Keyring
+
+	mtx       sync.RWMutex
+	handlers  []*Handlers
+	addresses map[string][]*Handlers
+	filters   []Filter
+}
+
+// NewDispatcher returns a Dispatcher which reads objects with keyring.
+func NewDispatcher(keyring *Keyring) *Dispatcher {
+	return &Dispatcher{
+		keyring:   keyring,
+		addresses: make(map[string][]*Handlers),
+	}
+}
+
+// Handle registers handlers which are called for every object.
+func (d *Dispatcher) Handle(h *Handlers) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.handlers = append(d.handlers, h)
+}
+
+// HandleAddress registers handlers which are called for the objects that
+// concern address, after the handlers registered with Handle.
+func (d *Dispatcher) HandleAddress(address bmutil.Address, h *Handlers) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	key := address.String()
+	d.addresses[key] = append(d.addresses[key], h)
+}
+
+// handlersFor returns the handlers for an object which concerns address.
+func (d *Dispatcher) handlersFor(address bmutil.Address) []*Handlers {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	handlers := append([]*Handlers(nil), d.handlers...)
+	return append(handlers, d.addresses[address.String()]...)
+}
+
+// Dispatch reads an object and calls the handlers which are interested in
+// it. Messages and broadcasts are passed through the filters first. It
+// returns a *RejectError if the object is invalid, does not concern us or
+// is dropped by a filter, in which case no handlers are called.
+func (d *Dispatcher) Dispatch(msg *wire.MsgObject) error {
+	switch msg.Header().ObjectType {
+	case wire.ObjectTypeMsg:
+		r, err := Receive(msg, d.keyring)
+		if err != nil {
+			return err
+		}
+		if err := d.filter(r); err != nil {
+			return err
+		}
+		for _, h := range d.handlersFor(r.To.Address()) {
+			if h.OnMsg != nil {
+				h.OnMsg(r)
+			}
+		}
+
+	case wire.ObjectTypeBroadcast:
+		r, err := Receive(msg, d.keyring)
+		if err != nil {
+			return err
+		}
+		if err := d.filter(r); err != nil {
+			return err
+		}
+		for _, h := range d.handlersFor(r.Bitmessage.Public.Address()) {
+			if h.OnBroadcast != nil {
+				h.OnBroadcast(r)
+			}
+		}
+
+	case wire.ObjectTypePubKey:
+		if err := d.keyring.checkObject(msg, d.keyring.now()); err != nil {
+			return err
+		}
+		id, err := openPubKey(msg, d.keyring.lookupPubKey)
+		if err != nil {
+			return err
+		}
+		for _, h := range d.handlersFor(id.Address()) {
+			if h.OnPubKey != nil {
+				h.OnPubKey(msg, id)
+			}
+		}
+
+	case wire.ObjectTypeGetPubKey:
+		if err := d.keyring.checkObject(msg, d.keyring.now()); err != nil {
+			return err
+		}
+		id, audited, err := d.keyring.requested(msg)
+		if err != nil {
+			return err
+		}
+		if audited != nil {
+			for _, h := range d.handlersFor(audited.Address()) {
+				if h.OnAuditedGetPubKey != nil {
+					h.OnAuditedGetPubKey(msg, audited)
+				}
+			}
+			break
+		}
+		for _, h := range d.handlersFor(id.Address()) {
+			if h.OnGetPubKey != nil {
+				h.OnGetPubKey(msg, id)
+			}
+		}
+
+	default:
+		return reject(RejectUnsupported, nil)
+	}
+	return nil
+}
+
+// lookupPubKey returns the address in the keyring with the given ripe or
+// tag, or nil if there is none.
+func (k *Keyring) lookupPubKey(ripe *hash.Ripe, tag *hash.Sha) bmutil.Address {
+	var e *Entry
+	if tag != nil {
+		e = k.LookupTag(tag)
+	} else {
+		e = k.LookupRipe(ripe)
+	}
+	if e == nil {
+		return nil
+	}
+	return e.Address
+}
+
+// openPubKey decodes a pubkey and verifies it. The pubkey is matched to an
+// address with lookup, which is given the ripe of a v2 or v3 pubkey, which
+// is not encrypted, or the tag of a v4 pubkey, and returns nil if the pubkey
+// is not wanted. It returns the identity in the pubkey or a *RejectError.
+func openPubKey(msg *wire.MsgObject,
+	lookup func(ripe *hash.Ripe, tag *hash.Sha) bmutil.Address) (identity.Public, error) {
+
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		return nil, reject(RejectMalformed, err)
+	}
+
+	var address bmutil.Address
+	switch pk := o.(type) {
+	case *obj.EncryptedPubKey:
+		tag := pk.Tag
+		address = lookup(nil, &tag)
+	case *obj.SimplePubKey, *obj.ExtendedPubKey:
+		// The address of an unencrypted pubkey is the one which its keys
+		// make, so it is verified before it is looked up.
+		id, err := pubKeyIdentity(o, nil)
+		if err != nil {
+			return nil, err
+		}
+		if lookup(id.Address().RipeHash(), nil) == nil {
+			return nil, reject(RejectNotForUs, nil)
+		}
+		return id, nil
+	default:
+		return nil, reject(RejectMalformed, nil)
+	}
+	if address == nil {
+		return nil, reject(RejectNotForUs, nil)
+	}
+
+	id, err := pubKeyIdentity(o, address)
+	if err != nil {
+		return nil, err
+	}
+	if !bmutil.AddressesEqual(id.Address(), address) {
+		return nil, reject(RejectMalformed, nil)
+	}
+	return id, nil
+}
+
+// pubKeyIdentity decrypts a pubkey with address, which may be nil if it is
+// not encrypted, verifies it and returns the identity in it.
+func pubKeyIdentity(o obj.Object, address bmutil.Address) (identity.Public, error) {
+	pubkey, err := cipher.TryDecryptAndVerifyPubKey(o, address)
+	if err != nil {
+		return nil, verifyError(err)
+	}
+	id, err := cipher.ToIdentity(pubkey)
+	if err != nil {
+		return nil, reject(RejectMalformed, err)
+	}
+	return id, nil
+}
+
+// requested returns the identity in the keyring whose pubkey is requested
+// by a getpubkey object, or a *RejectError. If the pubkey is of an audited
+// identity, it is returned as audited instead.
+func (k *Keyring) requested(msg *wire.MsgObject) (id *identity.PrivateID,
+	audited identity.Public, err error) {
+
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		return nil, nil, reject(RejectMalformed, err)
+	}
+	request, ok := o.(*obj.GetPubKey)
+	if !ok {
+		return nil, nil, reject(RejectMalformed, nil)
+	}
+
+	if id := k.owner(request.Ripe, &request.Tag); id != nil {
+		return id, nil, nil
+	}
+
+	var e *Entry
+	if request.Ripe != nil {
+		e = k.LookupRipe(request.Ripe)
+	} else {
+		e = k.LookupTag(&request.Tag)
+	}
+	if e != nil && e.Audited {
+		return nil, e.Watched, nil
+	}
+	return nil, nil, reject(RejectNotForUs, nil)
+}
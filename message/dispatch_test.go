@@ -0,0 +1,170 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// counter counts the calls to the handlers of a Dispatcher.
+type counter struct {
+	msgs, broadcasts, pubkeys, getpubkeys int
+}
+
+func (c *counter) handlers() *message.Handlers {
+	return &message.Handlers{
+		OnMsg:       func(*message.Received) { c.msgs++ },
+		OnBroadcast: func(*message.Received) { c.broadcasts++ },
+		OnPubKey:    func(*wire.MsgObject, identity.Public) { c.pubkeys++ },
+		OnGetPubKey: func(*wire.MsgObject, *identity.PrivateID) { c.getpubkeys++ },
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	to := recipient(t)
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(to)
+	keyring.Subscribe(from.Address())
+
+	d := message.NewDispatcher(keyring)
+	var all, ours, theirs counter
+	d.Handle(all.handlers())
+	d.HandleAddress(to.Address(), ours.handlers())
+	d.HandleAddress(from.Address(), theirs.handlers())
+
+	msg, err := message.Compose(from, to.Public(),
+		&format.Encoding1{Body: "Hey there!"}, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	b, err := wire.DecodeMsgObject(broadcast(t, from, "Hey everyone!"))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+
+	request := obj.NewGetPubKey(0, time.Now().Add(time.Hour), to.Address()).MsgObject()
+	message.TstDoPow(request, lowPow)
+
+	pk, err := cipher.GeneratePubKey(from, time.Hour)
+	if err != nil {
+		t.Fatalf("GeneratePubKey: %v", err)
+	}
+	pubkey, err := wire.DecodeMsgObject(wire.Encode(pk.Object()))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	message.TstDoPow(pubkey, lowPow)
+
+	for _, o := range []*wire.MsgObject{msg, b, request, pubkey} {
+		if err := d.Dispatch(o); err != nil {
+			t.Errorf("Dispatch %s: %v", o.Header().ObjectType, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		got, want counter
+	}{
+		{"all", all, counter{1, 1, 1, 1}},
+		{"recipient", ours, counter{msgs: 1, getpubkeys: 1}},
+		{"sender", theirs, counter{broadcasts: 1, pubkeys: 1}},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %+v want %+v", test.name, test.got, test.want)
+		}
+	}
+
+	// Objects which do not concern us are rejected.
+	request = obj.NewGetPubKey(0, time.Now().Add(time.Hour), from.Address()).MsgObject()
+	message.TstDoPow(request, lowPow)
+	checkReject(t, "getpubkey", d.Dispatch(request), message.RejectNotForUs)
+
+	pk, err = cipher.GeneratePubKey(to, time.Hour)
+	if err != nil {
+		t.Fatalf("GeneratePubKey: %v", err)
+	}
+	pubkey, err = wire.DecodeMsgObject(wire.Encode(pk.Object()))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	message.TstDoPow(pubkey, lowPow)
+	empty := message.NewDispatcher(message.NewKeyring(&message.KeyringConfig{Pow: &lowPow}))
+	checkReject(t, "pubkey", empty.Dispatch(pubkey), message.RejectNotForUs)
+}
+
+func TestDispatcherAudit(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	audited := sender(t)
+	other := recipient(t)
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.Audit(audited.Public())
+
+	d := message.NewDispatcher(keyring)
+	var c counter
+	var requests int
+	h := c.handlers()
+	h.OnAuditedGetPubKey = func(_ *wire.MsgObject, id identity.Public) {
+		if id.Address().String() != audited.Address().String() {
+			t.Errorf("OnAuditedGetPubKey: got %s want %s", id.Address(),
+				audited.Address())
+		}
+		requests++
+	}
+	d.HandleAddress(audited.Address(), h)
+
+	b, err := wire.DecodeMsgObject(broadcast(t, audited, "Hey everyone!"))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+
+	request := obj.NewGetPubKey(0, time.Now().Add(time.Hour),
+		audited.Address()).MsgObject()
+	message.TstDoPow(request, lowPow)
+
+	for _, o := range []*wire.MsgObject{b, request, pubkey(t, audited)} {
+		if err := d.Dispatch(o); err != nil {
+			t.Errorf("Dispatch %s: %v", o.Header().ObjectType, err)
+		}
+	}
+	if want := (counter{broadcasts: 1, pubkeys: 1}); c != want {
+		t.Errorf("got %+v want %+v", c, want)
+	}
+	if requests != 1 {
+		t.Errorf("OnAuditedGetPubKey: called %d times want 1", requests)
+	}
+
+	// Messages to an audited address cannot be read.
+	msg, err := message.Compose(other, audited.Public(),
+		&format.Encoding1{Body: "Hey there!"}, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+	checkReject(t, "msg", d.Dispatch(msg), message.RejectNotForUs)
+
+	// Neither broadcasts nor requests are read once the address is no
+	// longer audited.
+	keyring.Unaudit(audited.Address())
+	checkReject(t, "broadcast", d.Dispatch(b), message.RejectNotForUs)
+	checkReject(t, "getpubkey", d.Dispatch(request), message.RejectNotForUs)
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package message puts together the steps which are needed to send and
+receive a Bitmessage so that an application does not have to call format,
+cipher, pow and wire itself.
+
+Compose encodes, signs and encrypts a message and does the proof of work on
+it, returning an object which is ready to be given to the network. A
+Builder does the same one part at a time, and can also build broadcasts and
+request acknowledgements.
+
+Receive does the reverse. It checks the expiration and proof of work of an
+object, decrypts it with one of the identities or subscriptions in a
+Keyring and verifies who sent it. If the object cannot be received, the
+error is a *RejectError which says why.
+
+A Keyring holds our own identities, the public identities which we watch and
+the addresses we subscribe to, and can look any of them up by address, ripe
+or tag. A watched identity can also be audited, so that its traffic is
+tracked without its private keys: its broadcasts and pubkeys are read and
+requests for its pubkey are seen, but messages to it cannot be read.
+Addresses can be given labels and notes. The watched identities and
+subscriptions, with their labels, can be written as a contact list with
+WriteContacts and merged into the Keyring of another device with
+MergeContacts. Listeners are told of every change, and a Keyring opened with a
+KeyStore saves every change to it.
+
+A Dispatcher reads each object which arrives with a Keyring and calls the
+handlers registered for its type, either for every object or only for the
+objects which concern a given address. Filters added to a Dispatcher see
+each message and broadcast before it is delivered, and can drop it or flag
+it with a reason. Each Received says which encoding its content is in, and
+EncodingFilter drops those which a client cannot display.
+
+A Resolver finds the pubkeys of the addresses which messages are to be sent
+to. It requests each pubkey which is not in the Keyring, watches for it to
+arrive, adds it to the Keyring and wakes everyone who was waiting for it.
+Introduce uses a Resolver to make first contact with an address: it
+publishes our pubkey and resolves theirs, so that both sides can encrypt.
+
+Subscriptions holds the addresses whose broadcasts are followed, indexed by
+tag. It reads the results of a relay.Decoder and sends each broadcast from a
+subscribed address on a channel once it has been decrypted and verified.
+Only tagged broadcasts whose tag is subscribed to are decrypted.
+
+GetPubKeyCooldown remembers the getpubkey requests which have recently been
+answered or relayed, so that a flood of requests for the same pubkey does
+not make a node publish or relay it over and over. When it is full it
+forgets the oldest request rather than refusing new ones.
+*/
+package message
@@ -0,0 +1,102 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+// Verdict is what a Filter decides to do with an object.
+type Verdict uint8
+
+// The verdicts of a Filter.
+const (
+	// Accept lets the object through to the next filter.
+	Accept Verdict = iota
+
+	// Drop rejects the object, so that it is not delivered.
+	Drop
+
+	// Flag lets the object through, but marks it with the reason, such as
+	// for a message which is likely to be spam.
+	Flag
+)
+
+// verdictStrings is a map of verdicts back to their names for pretty
+// printing.
+var verdictStrings = map[Verdict]string{
+	Accept: "accept",
+	Drop:   "drop",
+	Flag:   "flag",
+}
+
+// String returns the Verdict in human-readable form.
+func (v Verdict) String() string {
+	if str, ok := verdictStrings[v]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown Verdict (%d)", uint8(v))
+}
+
+// Filter decides whether a message or broadcast which has been decrypted
+// and verified should be delivered. Filters let spam filtering, keyword
+// rules and sender whitelists be added to a Dispatcher.
+type Filter interface {
+	// Filter returns the verdict on r and the reason for it, which is
+	// ignored if the verdict is Accept.
+	Filter(r *Received) (Verdict, string)
+}
+
+// FilterFunc is a function which is a Filter.
+type FilterFunc func(r *Received) (Verdict, string)
+
+// Filter calls f. This is part of the Filter interface.
+func (f FilterFunc) Filter(r *Received) (Verdict, string) {
+	return f(r)
+}
+
+// EncodingFilter returns a Filter which drops messages and broadcasts whose
+// content uses an encoding which is not in caps.
+func EncodingFilter(caps *format.Capabilities) Filter {
+	return FilterFunc(func(r *Received) (Verdict, string) {
+		if err := caps.Check(r.Encoding); err != nil {
+			return Drop, fmt.Sprintf("%s: %s", r.Encoding, err)
+		}
+		return Accept, ""
+	})
+}
+
+// AddFilter adds a filter which is applied to every message and broadcast
+// before it is delivered to the handlers. Filters are applied in the order
+// in which they are added, and the first one which drops an object stops
+// it from being delivered, in which case Dispatch returns a *RejectError
+// for RejectFiltered.
+func (d *Dispatcher) AddFilter(f Filter) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.filters = append(d.filters, f)
+}
+
+// filter applies the filters to r, adding the reasons of those which flag
+// it to r.Flags.
+func (d *Dispatcher) filter(r *Received) error {
+	d.mtx.RLock()
+	filters := d.filters
+	d.mtx.RUnlock()
+
+	for _, f := range filters {
+		switch verdict, reason := f.Filter(r); verdict {
+		case Accept:
+		case Drop:
+			return reject(RejectFiltered, errors.New(reason))
+		case Flag:
+			r.Flags = append(r.Flags, reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/message"
+)
+
+// whitelist drops objects from any sender but the given addresses.
+func whitelist(addresses ...bmutil.Address) message.Filter {
+	return message.FilterFunc(func(r *message.Received) (message.Verdict, string) {
+		sender := r.Bitmessage.Public.Address().String()
+		for _, a := range addresses {
+			if a.String() == sender {
+				return message.Accept, ""
+			}
+		}
+		return message.Drop, "sender not on whitelist"
+	})
+}
+
+// keyword flags objects which contain a word.
+func keyword(word string) message.Filter {
+	return message.FilterFunc(func(r *message.Received) (message.Verdict, string) {
+		if strings.Contains(string(r.Bitmessage.Content.Message()), word) {
+			return message.Flag, "contains " + word
+		}
+		return message.Accept, ""
+	})
+}
+
+func TestFilter(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+
+	msg, err := message.Compose(from, to.Public(),
+		&format.Encoding1{Body: "Cheap spam!"}, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(to)
+
+	var received []*message.Received
+	handlers := &message.Handlers{
+		OnMsg: func(r *message.Received) { received = append(received, r) },
+	}
+
+	d := message.NewDispatcher(keyring)
+	d.Handle(handlers)
+	d.AddFilter(keyword("spam"))
+	d.AddFilter(keyword("eggs"))
+	d.AddFilter(whitelist(from.Address()))
+	if err := d.Dispatch(msg); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("got %d messages want 1", len(received))
+	}
+	if flags := received[0].Flags; len(flags) != 1 || flags[0] != "contains spam" {
+		t.Errorf("Flags: got %q want [contains spam]", flags)
+	}
+
+	d = message.NewDispatcher(keyring)
+	d.Handle(handlers)
+	d.AddFilter(whitelist(to.Address()))
+	err = d.Dispatch(msg)
+	checkReject(t, "whitelist", err, message.RejectFiltered)
+	if got, want := err.Error(),
+		"object rejected: filtered: sender not on whitelist"; got != want {
+		t.Errorf("Error: got %q want %q", got, want)
+	}
+	if len(received) != 1 {
+		t.Errorf("dropped message was delivered")
+	}
+}
+
+func TestEncodingFilter(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+
+	msg, err := message.Compose(from, to.Public(),
+		&format.Encoding1{Body: "Hello"}, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(to)
+
+	var received []*message.Received
+	handlers := &message.Handlers{
+		OnMsg: func(r *message.Received) { received = append(received, r) },
+	}
+
+	d := message.NewDispatcher(keyring)
+	d.Handle(handlers)
+	d.AddFilter(message.EncodingFilter(&format.DefaultCapabilities))
+	if err := d.Dispatch(msg); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("got %d messages want 1", len(received))
+	}
+	if got, want := received[0].Encoding, (format.Metadata{Encoding: 1}); got != want {
+		t.Errorf("Encoding: got %v want %v", got, want)
+	}
+
+	d = message.NewDispatcher(keyring)
+	d.Handle(handlers)
+	d.AddFilter(message.EncodingFilter(&format.Capabilities{
+		Encodings: []uint64{2},
+	}))
+	err = d.Dispatch(msg)
+	checkReject(t, "encoding 2 only", err, message.RejectFiltered)
+	if got, want := err.Error(), "object rejected: filtered: "+
+		"encoding 1: Unsupported encoding"; got != want {
+		t.Errorf("Error: got %q want %q", got, want)
+	}
+	if len(received) != 1 {
+		t.Errorf("dropped message was delivered")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the message package rather than than the
+message_test package so it can bridge access to the internals to properly
+test cases which are either not possible or can't reliably be tested via
+the public interface. The functions are only exported while the tests are
+being run.
+*/
+
+package message
+
+import (
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TstDoPow does the proof of work on an object.
+func TstDoPow(msg *wire.MsgObject, data pow.Data) {
+	doPow(msg, data, msg.Header().Expiration(), time.Now())
+}
+
+// TstSetNetworkPow sets the proof of work which is done on broadcasts and
+// acknowledgements.
+func TstSetNetworkPow(data pow.Data) {
+	networkPow = data
+}
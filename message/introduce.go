@@ -0,0 +1,69 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Publish sends the pubkey of one of our identities, so that others can
+// encrypt messages to it. It is also the way to answer a request for the
+// pubkey. It returns ErrInvalidTTL if the resolver was configured with a
+// PubKeyTTL outside of MinTTL and wire.MaxObjectTTL.
+func (r *Resolver) Publish(id *identity.PrivateID) error {
+	if err := checkTTL(r.cfg.PubKeyTTL); err != nil {
+		return err
+	}
+
+	now := r.keyring.now()
+	pubkey, err := cipher.CreatePubKey(now.Add(r.cfg.PubKeyTTL), id)
+	if err != nil {
+		return err
+	}
+
+	o := pubkey.Object()
+	doPow(o, networkPow, o.Header().Expiration(), now)
+	r.cfg.Send(wire.NewMsgObject(o.Header(), o.Payload()))
+	return nil
+}
+
+// Introduction is the result of Introduce.
+type Introduction struct {
+	// Contact is the public identity of the address which we introduced
+	// ourselves to. It is nil if Err is not.
+	Contact identity.Public
+
+	// Err is the error which stopped the introduction, if any.
+	Err error
+}
+
+// Introduce makes first contact between one of our identities and another
+// address. It publishes our pubkey, so that the other side can encrypt
+// messages to us, and resolves theirs, so that we can encrypt messages to
+// them. The returned channel receives the result once both are done, or
+// once cancel is closed, and is then closed.
+func (r *Resolver) Introduce(id *identity.PrivateID, to bmutil.Address,
+	cancel <-chan struct{}) <-chan *Introduction {
+
+	result := make(chan *Introduction, 1)
+	go func() {
+		defer close(result)
+
+		if err := r.Publish(id); err != nil {
+			result <- &Introduction{Err: err}
+			return
+		}
+		contact, err := r.Resolve(to, cancel)
+		if err != nil {
+			result <- &Introduction{Err: err}
+			return
+		}
+		result <- &Introduction{Contact: contact}
+	}()
+	return result
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestIntroduce(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	me := recipient(t)
+	contact := sender(t)
+
+	sent := make(chan *wire.MsgObject, 10)
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	r := message.NewResolver(keyring, &message.ResolverConfig{
+		Send: func(msg *wire.MsgObject) { sent <- msg },
+	})
+	result := r.Introduce(me, contact.Address(), nil)
+
+	// Our pubkey is published first, so that the contact can reply.
+	msg := <-sent
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	pk, ok := o.(*obj.EncryptedPubKey)
+	if !ok {
+		t.Fatalf("pubkey: got %T want *obj.EncryptedPubKey", o)
+	}
+	if pk.Tag != *bmutil.Tag(me.Address()) {
+		t.Errorf("pubkey: got tag %s want %s", pk.Tag, bmutil.Tag(me.Address()))
+	}
+	if !msg.CheckPow(lowPow, time.Now()) {
+		t.Error("pubkey: insufficient proof of work")
+	}
+
+	checkRequest(t, <-sent, contact.Address())
+	select {
+	case <-result:
+		t.Fatal("introduction finished before the pubkey arrived")
+	default:
+	}
+
+	if _, err := r.Handle(pubkey(t, contact)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	intro, ok := <-result
+	if !ok {
+		t.Fatal("no result")
+	}
+	if intro.Err != nil {
+		t.Fatalf("Introduce: %v", intro.Err)
+	}
+	if intro.Contact.Address().String() != contact.Address().String() {
+		t.Errorf("Contact: got %s want %s", intro.Contact.Address(), contact.Address())
+	}
+
+	// Introductions can be canceled.
+	cancel := make(chan struct{})
+	close(cancel)
+	intro = <-r.Introduce(contact, me.Address(), cancel)
+	if intro.Err != message.ErrCanceled {
+		t.Errorf("Introduce: got %v want %v", intro.Err, message.ErrCanceled)
+	}
+}
@@ -0,0 +1,558 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// Kind is the way in which an address is held in a Keyring.
+type Kind uint8
+
+// The kinds of addresses in a Keyring.
+const (
+	// KindPrivate is an identity whose private keys we hold, so that
+	// messages sent to it can be read.
+	KindPrivate Kind = iota
+
+	// KindWatched is the public identity of someone else, such as a
+	// contact whose pubkey has been received, so that messages can be
+	// sent to it.
+	KindWatched
+
+	// KindSubscription is an address whose broadcasts are read.
+	KindSubscription
+
+	// KindAudited is a watched identity whose traffic is also tracked,
+	// such as by a tool which audits it. Its broadcasts are read and
+	// requests for its pubkey are seen, but messages sent to it cannot
+	// be.
+	KindAudited
+
+	// KindLabel is the label and notes of an address. They are changed
+	// rather than added or removed, so Removed is always false.
+	KindLabel
+)
+
+// kindStrings is a map of kinds back to their constant names for pretty
+// printing.
+var kindStrings = map[Kind]string{
+	KindPrivate:      "private",
+	KindWatched:      "watched",
+	KindSubscription: "subscription",
+	KindAudited:      "audited",
+	KindLabel:        "label",
+}
+
+// String returns the Kind in human-readable form.
+func (k Kind) String() string {
+	if str, ok := kindStrings[k]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown Kind (%d)", uint8(k))
+}
+
+// Entry is everything a Keyring holds about one address.
+type Entry struct {
+	Address bmutil.Address
+
+	// Private is the identity of the address if we hold its private keys,
+	// and nil otherwise.
+	Private *identity.PrivateID
+
+	// Watched is the public identity of the address if it is watched, and
+	// nil otherwise.
+	Watched identity.Public
+
+	// Audited is whether the traffic of the watched identity is tracked as
+	// well. It is only ever true if Watched is not nil.
+	Audited bool
+
+	// Subscribed is whether the broadcasts of the address are read.
+	Subscribed bool
+
+	// Label is a name which the user gave the address.
+	Label string
+
+	// Notes are the user's notes on the address, such as how far it is
+	// trusted.
+	Notes string
+}
+
+// empty returns whether nothing is held about the address. A label alone
+// is not kept.
+func (e *Entry) empty() bool {
+	return e.Private == nil && e.Watched == nil && !e.Subscribed
+}
+
+// followed returns whether the broadcasts of the address are read, which
+// they are for a subscription and an audited address.
+func (e *Entry) followed() bool {
+	return e.Subscribed || e.Audited
+}
+
+// Change describes an address which has been added to or removed from a
+// Keyring, or whose label has changed.
+type Change struct {
+	Kind    Kind
+	Address bmutil.Address
+
+	// Removed is true if the address was removed, and false if it was
+	// added.
+	Removed bool
+}
+
+// KeyStore persists the contents of a Keyring.
+type KeyStore interface {
+	// Put saves an entry, replacing any entry for the same address.
+	Put(e *Entry) error
+
+	// Delete removes the entry for an address. It is not an error if
+	// there is none.
+	Delete(address bmutil.Address) error
+
+	// Entries returns every entry which has been saved.
+	Entries() ([]*Entry, error)
+}
+
+// MemKeyStore is a KeyStore which keeps entries in memory.
+type MemKeyStore struct {
+	mtx     sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemKeyStore returns an empty MemKeyStore.
+func NewMemKeyStore() *MemKeyStore {
+	return &MemKeyStore{
+		entries: make(map[string]Entry),
+	}
+}
+
+// Put saves an entry. This is part of the KeyStore interface.
+func (s *MemKeyStore) Put(e *Entry) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.entries[e.Address.String()] = *e
+	return nil
+}
+
+// Delete removes the entry for an address. This is part of the KeyStore
+// interface.
+func (s *MemKeyStore) Delete(address bmutil.Address) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.entries, address.String())
+	return nil
+}
+
+// Entries returns every entry. This is part of the KeyStore interface.
+func (s *MemKeyStore) Entries() ([]*Entry, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		e := e
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+// Keyring holds the private identities whose messages Receive is able to
+// read, the public identities which are watched, some of which are audited
+// so that their traffic is tracked, and the subscriptions whose broadcasts
+// are read. Addresses can be looked up by their string form, their ripe or
+// their tag, so a Keyring is all that is needed to decide whether an object
+// or a getpubkey request concerns us.
+//
+// A Keyring is safe for concurrent use. Listeners are told of every change,
+// and if the Keyring was opened with a KeyStore, every change is saved to
+// it before it takes effect.
+type Keyring struct {
+	pow   pow.Data
+	store KeyStore
+	now   func() time.Time
+
+	mtx        sync.RWMutex
+	entries    map[string]*Entry
+	ripes      map[hash.Ripe]*Entry
+	tags       map[hash.Sha]*Entry
+	identities []*identity.PrivateID
+	listeners  []func(Change)
+
+	subscriptions *Subscriptions
+}
+
+// KeyringConfig is the configuration of a Keyring.
+type KeyringConfig struct {
+	// Pow is the proof of work which objects must have to be accepted.
+	// Messages must also satisfy the proof of work of the identity they
+	// are sent to. The default is pow.Default.
+	Pow *pow.Data
+
+	// Clock tells the time against which the expiration and proof of
+	// work of objects are checked, and from which the objects made with
+	// the keyring, such as getpubkey requests, expire. The default is
+	// clock.System.
+	Clock clock.Clock
+}
+
+// NewKeyring returns an empty Keyring. cfg may be nil, in which case the
+// defaults are used.
+func NewKeyring(cfg *KeyringConfig) *Keyring {
+	k := &Keyring{
+		pow:           pow.Default,
+		now:           clock.System.Now,
+		entries:       make(map[string]*Entry),
+		ripes:         make(map[hash.Ripe]*Entry),
+		tags:          make(map[hash.Sha]*Entry),
+		subscriptions: NewSubscriptions(),
+	}
+	if cfg != nil {
+		if cfg.Pow != nil {
+			k.pow = *cfg.Pow
+		}
+		k.now = clock.Or(cfg.Clock).Now
+	}
+	return k
+}
+
+// NewKeyringWithPolicy returns an empty Keyring which accepts objects with
+// at least the proof of work which the policy accepts, in place of the Pow
+// of cfg, which may be nil.
+func NewKeyringWithPolicy(policy *pow.Policy, cfg *KeyringConfig) *Keyring {
+	var c KeyringConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	data := policy.Accept
+	c.Pow = &data
+	return NewKeyring(&c)
+}
+
+// OpenKeyring returns a Keyring which holds the entries saved in store and
+// saves every change to it. cfg is as for NewKeyring.
+func OpenKeyring(store KeyStore, cfg *KeyringConfig) (*Keyring, error) {
+	entries, err := store.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	k := NewKeyring(cfg)
+	for _, e := range entries {
+		e := *e
+		k.set(&e)
+	}
+	k.store = store
+	return k, nil
+}
+
+// set replaces the entry for an address and updates the indexes. It must
+// be called with the mutex held.
+func (k *Keyring) set(e *Entry) {
+	key := e.Address.String()
+	old := k.entries[key]
+
+	if e.empty() {
+		delete(k.entries, key)
+		delete(k.ripes, *e.Address.RipeHash())
+		delete(k.tags, *bmutil.Tag(e.Address))
+	} else {
+		k.entries[key] = e
+		k.ripes[*e.Address.RipeHash()] = e
+		k.tags[*bmutil.Tag(e.Address)] = e
+	}
+
+	var wasPrivate, wasFollowed bool
+	if old != nil {
+		wasPrivate = old.Private != nil
+		wasFollowed = old.followed()
+	}
+
+	// An identity which is replaced keeps its place, so that Identities
+	// stays in the order in which they were added.
+	i := -1
+	if wasPrivate {
+		for j, id := range k.identities {
+			if id == old.Private {
+				i = j
+				break
+			}
+		}
+	}
+	switch {
+	case i >= 0 && e.Private != nil:
+		k.identities[i] = e.Private
+	case i >= 0:
+		k.identities = append(k.identities[:i:i], k.identities[i+1:]...)
+	case e.Private != nil:
+		k.identities = append(k.identities, e.Private)
+	}
+
+	if wasFollowed && !e.followed() {
+		k.subscriptions.Unsubscribe(e.Address)
+	} else if !wasFollowed && e.followed() {
+		k.subscriptions.Subscribe(e.Address)
+	}
+}
+
+// update changes the entry for an address with f, which returns whether
+// anything changed. The change is saved and the listeners are told of it.
+func (k *Keyring) update(address bmutil.Address, change Change,
+	f func(e *Entry) bool) error {
+
+	k.mtx.Lock()
+
+	var e Entry
+	if old, ok := k.entries[address.String()]; ok {
+		e = *old
+	} else {
+		e.Address = address
+	}
+	if !f(&e) {
+		k.mtx.Unlock()
+		return nil
+	}
+
+	if k.store != nil {
+		var err error
+		if e.empty() {
+			err = k.store.Delete(address)
+		} else {
+			err = k.store.Put(&e)
+		}
+		if err != nil {
+			k.mtx.Unlock()
+			return err
+		}
+	}
+
+	k.set(&e)
+	listeners := k.listeners
+	k.mtx.Unlock()
+
+	for _, l := range listeners {
+		l(change)
+	}
+	return nil
+}
+
+// AddIdentity adds an identity to which messages can be sent. An identity
+// which is already in the keyring is replaced.
+func (k *Keyring) AddIdentity(id *identity.PrivateID) error {
+	address := id.Address()
+	return k.update(address, Change{Kind: KindPrivate, Address: address},
+		func(e *Entry) bool {
+			e.Private = id
+			return true
+		})
+}
+
+// RemoveIdentity removes the identity of an address.
+func (k *Keyring) RemoveIdentity(address bmutil.Address) error {
+	return k.update(address,
+		Change{Kind: KindPrivate, Address: address, Removed: true},
+		func(e *Entry) bool {
+			if e.Private == nil {
+				return false
+			}
+			e.Private = nil
+			return true
+		})
+}
+
+// Watch adds the public identity of someone else. An identity which is
+// already watched is replaced.
+func (k *Keyring) Watch(id identity.Public) error {
+	address := id.Address()
+	return k.update(address, Change{Kind: KindWatched, Address: address},
+		func(e *Entry) bool {
+			e.Watched = id
+			return true
+		})
+}
+
+// Unwatch removes the public identity of an address, which also stops it
+// from being audited.
+func (k *Keyring) Unwatch(address bmutil.Address) error {
+	return k.update(address,
+		Change{Kind: KindWatched, Address: address, Removed: true},
+		func(e *Entry) bool {
+			if e.Watched == nil {
+				return false
+			}
+			e.Watched = nil
+			e.Audited = false
+			return true
+		})
+}
+
+// Subscribe adds an address whose broadcasts are to be received.
+func (k *Keyring) Subscribe(address bmutil.Address) error {
+	return k.update(address, Change{Kind: KindSubscription, Address: address},
+		func(e *Entry) bool {
+			if e.Subscribed {
+				return false
+			}
+			e.Subscribed = true
+			return true
+		})
+}
+
+// Unsubscribe removes the subscription to an address.
+func (k *Keyring) Unsubscribe(address bmutil.Address) error {
+	return k.update(address,
+		Change{Kind: KindSubscription, Address: address, Removed: true},
+		func(e *Entry) bool {
+			if !e.Subscribed {
+				return false
+			}
+			e.Subscribed = false
+			return true
+		})
+}
+
+// Audit watches an identity and tracks its traffic without its private
+// keys. Its broadcasts are read as those of a subscription are, and
+// requests for its pubkey are seen. An identity which is already watched
+// is replaced.
+func (k *Keyring) Audit(id identity.Public) error {
+	address := id.Address()
+	return k.update(address, Change{Kind: KindAudited, Address: address},
+		func(e *Entry) bool {
+			e.Watched = id
+			e.Audited = true
+			return true
+		})
+}
+
+// Unaudit stops tracking the traffic of an address. Its identity is still
+// watched.
+func (k *Keyring) Unaudit(address bmutil.Address) error {
+	return k.update(address,
+		Change{Kind: KindAudited, Address: address, Removed: true},
+		func(e *Entry) bool {
+			if !e.Audited {
+				return false
+			}
+			e.Audited = false
+			return true
+		})
+}
+
+// SetLabel sets the label and notes of an address. It does nothing if the
+// address is not in the keyring.
+func (k *Keyring) SetLabel(address bmutil.Address, label, notes string) error {
+	return k.update(address, Change{Kind: KindLabel, Address: address},
+		func(e *Entry) bool {
+			if e.empty() || e.Label == label && e.Notes == notes {
+				return false
+			}
+			e.Label = label
+			e.Notes = notes
+			return true
+		})
+}
+
+// Listen adds a function which is called with every change to the keyring
+// after it has taken effect. It is called from the goroutine which made the
+// change and must not block.
+func (k *Keyring) Listen(f func(Change)) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.listeners = append(k.listeners, f)
+}
+
+// copyEntry returns a copy of an entry so that the caller cannot change the
+// keyring through it, or nil if e is nil.
+func copyEntry(e *Entry) *Entry {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	return &c
+}
+
+// Lookup returns the entry for an address, or nil if there is none.
+func (k *Keyring) Lookup(address bmutil.Address) *Entry {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return copyEntry(k.entries[address.String()])
+}
+
+// LookupRipe returns the entry for the address with the given ripe, or nil
+// if there is none. The ripe is what v2 and v3 getpubkey requests ask for.
+func (k *Keyring) LookupRipe(ripe *hash.Ripe) *Entry {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return copyEntry(k.ripes[*ripe])
+}
+
+// LookupTag returns the entry for the address with the given tag, or nil
+// if there is none. The tag is what v4 getpubkey requests ask for and what
+// identifies the sender of a v5 broadcast.
+func (k *Keyring) LookupTag(tag *hash.Sha) *Entry {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return copyEntry(k.tags[*tag])
+}
+
+// owner returns the private identity whose address has the given ripe, or
+// the given tag if ripe is nil, or nil if there is none. Every identity is
+// compared in constant time, so that the time taken does not reveal to
+// someone flooding us with getpubkey requests which addresses we own.
+func (k *Keyring) owner(ripe *hash.Ripe, tag *hash.Sha) *identity.PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	var found *identity.PrivateID
+	for _, id := range k.identities {
+		var match bool
+		if ripe != nil {
+			match = id.Address().RipeHash().ConstantTimeEqual(ripe)
+		} else {
+			match = bmutil.Tag(id.Address()).ConstantTimeEqual(tag)
+		}
+		if match && found == nil {
+			found = id
+		}
+	}
+	return found
+}
+
+// Entries returns every entry in the keyring.
+func (k *Keyring) Entries() []*Entry {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	entries := make([]*Entry, 0, len(k.entries))
+	for _, e := range k.entries {
+		entries = append(entries, copyEntry(e))
+	}
+	return entries
+}
+
+// Identities returns the private identities in the order in which they were
+// added.
+func (k *Keyring) Identities() []*identity.PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return append([]*identity.PrivateID(nil), k.identities...)
+}
+
+// Subscriptions returns the subscriptions in the keyring, which include the
+// audited addresses. They change with the keyring, and should not be
+// changed except through it.
+func (k *Keyring) Subscriptions() *Subscriptions {
+	return k.subscriptions
+}
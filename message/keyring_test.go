@@ -0,0 +1,272 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/message"
+)
+
+func TestKeyring(t *testing.T) {
+	own := sender(t)
+	contact := recipient(t)
+
+	var changes []message.Change
+	k := message.NewKeyring(nil)
+	k.Listen(func(c message.Change) {
+		changes = append(changes, c)
+	})
+
+	if err := k.AddIdentity(own); err != nil {
+		t.Fatalf("AddIdentity: %v", err)
+	}
+	if err := k.Watch(contact.Public()); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if err := k.Subscribe(contact.Address()); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	// Subscribing again changes nothing.
+	if err := k.Subscribe(contact.Address()); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	wantChanges := []message.Change{
+		{Kind: message.KindPrivate, Address: own.Address()},
+		{Kind: message.KindWatched, Address: contact.Address()},
+		{Kind: message.KindSubscription, Address: contact.Address()},
+	}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("changes: got %v want %v", changes, wantChanges)
+	}
+	for i, c := range changes {
+		want := wantChanges[i]
+		if c.Kind != want.Kind || c.Removed != want.Removed ||
+			c.Address.String() != want.Address.String() {
+			t.Errorf("change %d: got %v want %v", i, c, want)
+		}
+	}
+
+	// Every way of looking up an address finds the same entry.
+	for _, address := range []bmutil.Address{own.Address(), contact.Address()} {
+		e := k.Lookup(address)
+		if e == nil {
+			t.Fatalf("Lookup %s: not found", address)
+		}
+		if r := k.LookupRipe(address.RipeHash()); r == nil || r.Address.String() != address.String() {
+			t.Errorf("LookupRipe %s: got %v", address, r)
+		}
+		if r := k.LookupTag(bmutil.Tag(address)); r == nil || r.Address.String() != address.String() {
+			t.Errorf("LookupTag %s: got %v", address, r)
+		}
+	}
+
+	e := k.Lookup(own.Address())
+	if e.Private != own || e.Watched != nil || e.Subscribed {
+		t.Errorf("own entry: got %+v", e)
+	}
+	e = k.Lookup(contact.Address())
+	if e.Private != nil || e.Watched == nil || !e.Subscribed {
+		t.Errorf("contact entry: got %+v", e)
+	}
+	if n := len(k.Subscriptions().Addresses()); n != 1 {
+		t.Errorf("Subscriptions: got %d addresses want 1", n)
+	}
+	if ids := k.Identities(); len(ids) != 1 || ids[0] != own {
+		t.Errorf("Identities: got %v", ids)
+	}
+
+	// An address is only forgotten once nothing is held about it.
+	if err := k.Unsubscribe(contact.Address()); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if k.Lookup(contact.Address()) == nil {
+		t.Error("Lookup: watched address forgotten")
+	}
+	if err := k.Unwatch(contact.Address()); err != nil {
+		t.Fatalf("Unwatch: %v", err)
+	}
+	if k.Lookup(contact.Address()) != nil || k.LookupRipe(contact.Address().RipeHash()) != nil {
+		t.Error("Lookup: removed address found")
+	}
+	if n := len(k.Subscriptions().Addresses()); n != 0 {
+		t.Errorf("Subscriptions: got %d addresses want 0", n)
+	}
+	if err := k.RemoveIdentity(own.Address()); err != nil {
+		t.Fatalf("RemoveIdentity: %v", err)
+	}
+	if len(k.Identities()) != 0 || len(k.Entries()) != 0 {
+		t.Error("keyring not empty")
+	}
+	if n := len(changes); n != 6 {
+		t.Errorf("changes: got %d want 6", n)
+	}
+}
+
+// TestKeyringIdentitiesOrder tests that an identity which is added again
+// or whose entry changes keeps its place among the identities.
+func TestKeyringIdentitiesOrder(t *testing.T) {
+	first := sender(t)
+	second := recipient(t)
+
+	k := message.NewKeyring(nil)
+	k.AddIdentity(first)
+	k.AddIdentity(second)
+	k.AddIdentity(first)
+	k.SetLabel(first.Address(), "me", "")
+
+	ids := k.Identities()
+	if len(ids) != 2 ||
+		ids[0].Address().String() != first.Address().String() ||
+		ids[1].Address().String() != second.Address().String() {
+		t.Errorf("Identities: got %v", ids)
+	}
+}
+
+func TestKeyringStore(t *testing.T) {
+	own := sender(t)
+	contact := recipient(t)
+	store := message.NewMemKeyStore()
+
+	k, err := message.OpenKeyring(store, nil)
+	if err != nil {
+		t.Fatalf("OpenKeyring: %v", err)
+	}
+	k.AddIdentity(own)
+	k.Subscribe(contact.Address())
+	k.Watch(contact.Public())
+	k.Unwatch(contact.Address())
+
+	k, err = message.OpenKeyring(store, nil)
+	if err != nil {
+		t.Fatalf("OpenKeyring: %v", err)
+	}
+	if n := len(k.Entries()); n != 2 {
+		t.Errorf("Entries: got %d want 2", n)
+	}
+	if e := k.Lookup(own.Address()); e == nil || e.Private == nil {
+		t.Errorf("own entry: got %+v", e)
+	}
+	if e := k.Lookup(contact.Address()); e == nil || !e.Subscribed || e.Watched != nil {
+		t.Errorf("contact entry: got %+v", e)
+	}
+	if n := len(k.Subscriptions().Addresses()); n != 1 {
+		t.Errorf("Subscriptions: got %d addresses want 1", n)
+	}
+	if n := len(k.Identities()); n != 1 {
+		t.Errorf("Identities: got %d want 1", n)
+	}
+}
+
+func TestKeyringConcurrent(t *testing.T) {
+	own := sender(t)
+	contact := recipient(t)
+	k := message.NewKeyring(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			k.Subscribe(contact.Address())
+			k.Unsubscribe(contact.Address())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			k.AddIdentity(own)
+			k.RemoveIdentity(own.Address())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			k.Lookup(contact.Address())
+			k.LookupTag(bmutil.Tag(own.Address()))
+			k.Identities()
+		}
+	}()
+	wg.Wait()
+
+	if n := len(k.Entries()); n != 0 {
+		t.Errorf("Entries: got %d want 0", n)
+	}
+}
+
+func TestKeyringAudit(t *testing.T) {
+	audited := sender(t)
+
+	var changes []message.Change
+	k := message.NewKeyring(nil)
+	k.Listen(func(c message.Change) {
+		changes = append(changes, c)
+	})
+
+	if err := k.Audit(audited.Public()); err != nil {
+		t.Fatalf("Audit: %v", err)
+	}
+	e := k.Lookup(audited.Address())
+	if e == nil || e.Watched == nil || !e.Audited || e.Private != nil ||
+		e.Subscribed {
+		t.Fatalf("entry: got %+v", e)
+	}
+	if len(k.Identities()) != 0 {
+		t.Error("Identities: audited identity can read messages")
+	}
+
+	// The broadcasts of an audited address are read, whether or not it is
+	// also subscribed to.
+	if n := len(k.Subscriptions().Addresses()); n != 1 {
+		t.Errorf("Subscriptions: got %d addresses want 1", n)
+	}
+	k.Subscribe(audited.Address())
+	k.Unaudit(audited.Address())
+	if n := len(k.Subscriptions().Addresses()); n != 1 {
+		t.Errorf("Subscriptions: got %d addresses want 1", n)
+	}
+	k.Unsubscribe(audited.Address())
+	if n := len(k.Subscriptions().Addresses()); n != 0 {
+		t.Errorf("Subscriptions: got %d addresses want 0", n)
+	}
+
+	// The identity is still watched once it is no longer audited, and
+	// unwatching an audited identity stops auditing it.
+	if e := k.Lookup(audited.Address()); e == nil || e.Watched == nil || e.Audited {
+		t.Errorf("entry: got %+v", e)
+	}
+	k.Audit(audited.Public())
+	k.Unwatch(audited.Address())
+	if e := k.Lookup(audited.Address()); e != nil {
+		t.Errorf("entry: got %+v want nil", e)
+	}
+	if n := len(k.Subscriptions().Addresses()); n != 0 {
+		t.Errorf("Subscriptions: got %d addresses want 0", n)
+	}
+
+	wantChanges := []message.Change{
+		{Kind: message.KindAudited, Address: audited.Address()},
+		{Kind: message.KindSubscription, Address: audited.Address()},
+		{Kind: message.KindAudited, Address: audited.Address(), Removed: true},
+		{Kind: message.KindSubscription, Address: audited.Address(), Removed: true},
+		{Kind: message.KindAudited, Address: audited.Address()},
+		{Kind: message.KindWatched, Address: audited.Address(), Removed: true},
+	}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("changes: got %v want %v", changes, wantChanges)
+	}
+	for i, c := range changes {
+		want := wantChanges[i]
+		if c.Kind != want.Kind || c.Removed != want.Removed {
+			t.Errorf("change %d: got %v want %v", i, c, want)
+		}
+	}
+	if got := message.KindAudited.String(); got != "audited" {
+		t.Errorf("String: got %q want %q", got, "audited")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/metrics"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// Reason is the reason why Receive rejected an object.
+type Reason uint8
+
+// The reasons why an object can be rejected.
+const (
+	// RejectMalformed means that the object could not be decoded.
+	RejectMalformed Reason = iota
+
+	// RejectUnsupported means that the object is neither a message nor a
+	// broadcast.
+	RejectUnsupported
+
+	// RejectExpired means that the object has expired.
+	RejectExpired
+
+	// RejectFuture means that the object expires further in the future
+	// than wire.MaxObjectTTL.
+	RejectFuture
+
+	// RejectInsufficientPow means that not enough proof of work was done
+	// on the object.
+	RejectInsufficientPow
+
+	// RejectNotForUs means that none of the identities or subscriptions in
+	// the keyring could decrypt the object.
+	RejectNotForUs
+
+	// RejectInvalidSignature means that the object was decrypted but was
+	// not signed by its sender.
+	RejectInvalidSignature
+
+	// RejectFiltered means that the object was valid but a Filter dropped
+	// it.
+	RejectFiltered
+
+	// RejectUnsupportedEncoding means that the object was decrypted but
+	// its content is in an encoding which cannot be read.
+	RejectUnsupportedEncoding
+)
+
+// reasonStrings is a map of reasons back to their names for pretty
+// printing.
+var reasonStrings = map[Reason]string{
+	RejectMalformed:        "malformed",
+	RejectUnsupported:      "unsupported object type",
+	RejectExpired:          "expired",
+	RejectFuture:           "expires too far in the future",
+	RejectInsufficientPow:  "insufficient proof of work",
+	RejectNotForUs:         "not for us",
+	RejectInvalidSignature: "invalid signature",
+	RejectFiltered:         "filtered",
+
+	RejectUnsupportedEncoding: "unsupported encoding",
+}
+
+// String returns the Reason in human-readable form.
+func (r Reason) String() string {
+	if str, ok := reasonStrings[r]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown Reason (%d)", uint8(r))
+}
+
+// RejectError is returned by Receive when an object is rejected.
+type RejectError struct {
+	Reason Reason
+
+	// Err is the error which caused the rejection, if any.
+	Err error
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *RejectError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("object rejected: %s", e.Reason)
+	}
+	return fmt.Sprintf("object rejected: %s: %v", e.Reason, e.Err)
+}
+
+// reject returns a RejectError.
+func reject(reason Reason, err error) *RejectError {
+	return &RejectError{Reason: reason, Err: err}
+}
+
+// Received is a message or broadcast which has been decrypted and whose
+// signature has been verified.
+type Received struct {
+	// Object is the object which was received.
+	Object *wire.MsgObject
+
+	// Bitmessage holds the content along with the sender and, for a
+	// message, the destination.
+	Bitmessage *cipher.Bitmessage
+
+	// To is the identity which a message was sent to. It is nil for a
+	// broadcast.
+	To *identity.PrivateID
+
+	// Ack is the acknowledgement which was included in a message, if any.
+	Ack []byte
+
+	// Encoding describes the encoding of the content.
+	Encoding format.Metadata
+
+	// Flags are the reasons given by the filters which flagged the
+	// object, if any.
+	Flags []string
+}
+
+// IsBroadcast returns whether a broadcast rather than a message was
+// received.
+func (r *Received) IsBroadcast() bool {
+	return r.To == nil
+}
+
+// Receive checks the expiration and proof of work of an object, tries to
+// decrypt it with the identities and subscriptions in keyring, and verifies
+// the signature of the sender. It returns a *RejectError if any of these
+// fail.
+func Receive(msg *wire.MsgObject, keyring *Keyring) (*Received, error) {
+	return ReceiveContext(context.Background(), msg, keyring)
+}
+
+// ReceiveContext is like Receive, but stops trying identities and
+// subscriptions once ctx is done, in which case it returns the error of ctx.
+func ReceiveContext(ctx context.Context, msg *wire.MsgObject, keyring *Keyring) (*Received, error) {
+	now := keyring.now()
+	header := msg.Header()
+
+	switch header.ObjectType {
+	case wire.ObjectTypeMsg, wire.ObjectTypeBroadcast:
+	default:
+		return nil, reject(RejectUnsupported, nil)
+	}
+
+	if err := keyring.checkObject(msg, now); err != nil {
+		return nil, err
+	}
+
+	if header.ObjectType == wire.ObjectTypeBroadcast {
+		return keyring.subscriptions.open(ctx, msg)
+	}
+
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		return nil, reject(RejectMalformed, err)
+	}
+	m, ok := o.(*obj.Message)
+	if !ok {
+		// The payload could not be decoded as its type says it should be.
+		return nil, reject(RejectMalformed, nil)
+	}
+	return keyring.receiveMessage(ctx, msg, m, now)
+}
+
+// checkObject checks the expiration of an object and whether it has the
+// proof of work which the keyring demands.
+func (k *Keyring) checkObject(msg *wire.MsgObject, now time.Time) error {
+	expiration := msg.Header().Expiration()
+	if expiration.Before(now) {
+		return reject(RejectExpired, nil)
+	}
+	if expiration.Sub(now) > wire.MaxObjectTTL {
+		return reject(RejectFuture, nil)
+	}
+
+	if !msg.CheckPow(k.pow, now) {
+		return reject(RejectInsufficientPow, nil)
+	}
+	return nil
+}
+
+// receiveMessage tries each identity in turn on a message until ctx is done.
+// Each attempt is counted by countDecrypt.
+func (k *Keyring) receiveMessage(ctx context.Context, msg *wire.MsgObject,
+	o *obj.Message, now time.Time) (*Received, error) {
+
+	ids := k.Identities()
+	m, i, err := cipher.TryDecryptAndVerifyMessageAny(ctx, o, ids, countDecrypt)
+	switch {
+	case err == cipher.ErrInvalidIdentity:
+		return nil, reject(RejectNotForUs, nil)
+	case i < 0:
+		return nil, err
+	case err != nil:
+		return nil, verifyError(err)
+	}
+
+	id := ids[i]
+	if !msg.CheckPow(*id.Pow(), now) {
+		return nil, reject(RejectInsufficientPow, nil)
+	}
+
+	return &Received{
+		Object:     msg,
+		Bitmessage: m.Bitmessage(),
+		To:         id,
+		Ack:        m.Ack(),
+		Encoding:   format.MetadataOf(m.Bitmessage().Content),
+	}, nil
+}
+
+// countDecrypt counts an attempt to decrypt an object, given the error
+// which it returned.
+func countDecrypt(err error) {
+	switch err {
+	case nil:
+		metrics.DecryptAttempts.With(metrics.DecryptOK).Inc()
+	case cipher.ErrInvalidIdentity:
+		metrics.DecryptAttempts.With(metrics.DecryptNotForUs).Inc()
+	default:
+		metrics.DecryptAttempts.With(metrics.DecryptInvalid).Inc()
+	}
+}
+
+// verifyError converts an error from decrypting and verifying an object
+// which was intended for us into a RejectError.
+func verifyError(err error) *RejectError {
+	if err == cipher.ErrInvalidSignature {
+		return reject(RejectInvalidSignature, err)
+	}
+	if errors.Is(err, format.ErrUnsupportedEncoding) {
+		return reject(RejectUnsupportedEncoding, err)
+	}
+	return reject(RejectMalformed, err)
+}
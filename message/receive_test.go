@@ -0,0 +1,200 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/metrics"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// checkReject checks that err is a *message.RejectError for the given
+// reason.
+func checkReject(t *testing.T, name string, err error, want message.Reason) {
+	r, ok := err.(*message.RejectError)
+	if !ok {
+		t.Errorf("%s: got error %v want %s", name, err, want)
+		return
+	}
+	if r.Reason != want {
+		t.Errorf("%s: got reason %s want %s", name, r.Reason, want)
+	}
+}
+
+func TestReceiveMessage(t *testing.T) {
+	from := sender(t)
+	to := recipient(t)
+	content := &format.Encoding1{Body: "Hey there!"}
+
+	msg, err := message.Compose(from, to.Public(), content, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(from)
+	keyring.AddIdentity(to)
+
+	ok := metrics.DecryptAttempts.With(metrics.DecryptOK).Value()
+	notForUs := metrics.DecryptAttempts.With(metrics.DecryptNotForUs).Value()
+	r, err := message.Receive(msg, keyring)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+
+	// Each identity was tried until the message was decrypted.
+	if n := metrics.DecryptAttempts.With(metrics.DecryptOK).Value() - ok; n != 1 {
+		t.Errorf("decrypted: got %d attempts want 1", n)
+	}
+	if n := metrics.DecryptAttempts.With(metrics.DecryptNotForUs).Value() -
+		notForUs; n != 1 {
+		t.Errorf("not for us: got %d attempts want 1", n)
+	}
+	if r.IsBroadcast() {
+		t.Error("received a broadcast")
+	}
+	if r.To != to {
+		t.Errorf("To: got %s want %s", r.To.Address(), to.Address())
+	}
+	if got, want := r.Bitmessage.Public.Address().String(),
+		from.Address().String(); got != want {
+		t.Errorf("sender: got %s want %s", got, want)
+	}
+	if got, want := string(r.Bitmessage.Content.Message()),
+		string(content.Message()); got != want {
+		t.Errorf("content: got %q want %q", got, want)
+	}
+
+	// Nobody in the keyring can read the message.
+	keyring = message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(from)
+	_, err = message.Receive(msg, keyring)
+	checkReject(t, "other identity", err, message.RejectNotForUs)
+
+	// The keyring demands more proof of work.
+	keyring = message.NewKeyring(nil)
+	keyring.AddIdentity(to)
+	_, err = message.Receive(msg, keyring)
+	checkReject(t, "default pow", err, message.RejectInsufficientPow)
+
+	// No identity is tried once the context is done.
+	keyring = message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(to)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = message.ReceiveContext(ctx, msg, keyring); err != context.Canceled {
+		t.Errorf("ReceiveContext: got %v want %v", err, context.Canceled)
+	}
+}
+
+func TestReceiveBroadcast(t *testing.T) {
+	from := sender(t)
+	address := from.Address()
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	bm := &cipher.Bitmessage{
+		Public:  from.Public(),
+		Content: &format.Encoding1{Body: "Hey everyone!"},
+	}
+
+	b, err := cipher.SignAndEncryptBroadcast(expiration, bm,
+		bmutil.Tag(address), from)
+	if err != nil {
+		t.Fatalf("SignAndEncryptBroadcast: %v", err)
+	}
+	msg, err := wire.DecodeMsgObject(wire.Encode(b.Object()))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	message.TstDoPow(msg, lowPow)
+
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	_, err = message.Receive(msg, keyring)
+	checkReject(t, "not subscribed", err, message.RejectNotForUs)
+
+	keyring.Subscribe(address)
+	r, err := message.Receive(msg, keyring)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if !r.IsBroadcast() {
+		t.Error("did not receive a broadcast")
+	}
+	if got, want := r.Bitmessage.Public.Address().String(),
+		address.String(); got != want {
+		t.Errorf("sender: got %s want %s", got, want)
+	}
+	if got, want := string(r.Bitmessage.Content.Message()),
+		string(bm.Content.Message()); got != want {
+		t.Errorf("content: got %q want %q", got, want)
+	}
+}
+
+func TestReceiveReject(t *testing.T) {
+	now := time.Now()
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	keyring.AddIdentity(recipient(t))
+
+	// Proof of work is only done on objects which are rejected after it
+	// is checked.
+	tests := []struct {
+		name string
+		msg  *wire.MsgObject
+		pow  bool
+		want message.Reason
+	}{
+		{
+			"expired",
+			obj.NewMessage(0, now.Add(-time.Hour), 1, []byte{1, 2, 3}).MsgObject(),
+			false,
+			message.RejectExpired,
+		},
+		{
+			"future",
+			obj.NewMessage(0, now.Add(wire.MaxObjectTTL+time.Hour), 1,
+				[]byte{1, 2, 3}).MsgObject(),
+			false,
+			message.RejectFuture,
+		},
+		{
+			"getpubkey",
+			wire.NewMsgObject(wire.NewObjectHeader(0, now.Add(time.Hour),
+				wire.ObjectTypeGetPubKey, 4, 1), make([]byte, 32)),
+			false,
+			message.RejectUnsupported,
+		},
+		{
+			"not encrypted",
+			obj.NewMessage(0, now.Add(time.Hour), 1, []byte{1, 2, 3}).MsgObject(),
+			true,
+			message.RejectMalformed,
+		},
+	}
+
+	for _, test := range tests {
+		if test.pow {
+			message.TstDoPow(test.msg, lowPow)
+		}
+		_, err := message.Receive(test.msg, keyring)
+		checkReject(t, test.name, err, test.want)
+	}
+
+	// A keyring made with a policy demands the proof of work which the
+	// policy accepts.
+	keyring = message.NewKeyringWithPolicy(pow.Paranoid(), nil)
+	keyring.AddIdentity(recipient(t))
+	msg := obj.NewMessage(0, now.Add(time.Hour), 1, []byte{1, 2, 3}).MsgObject()
+	message.TstDoPow(msg, lowPow)
+	_, err := message.Receive(msg, keyring)
+	checkReject(t, "policy", err, message.RejectInsufficientPow)
+}
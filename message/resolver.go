@@ -0,0 +1,263 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+const (
+	// DefaultRequestTTL is the time to live of a request for a pubkey. If
+	// the pubkey has not arrived by the time the request expires, it is
+	// requested again.
+	DefaultRequestTTL = 2 * 24 * time.Hour
+
+	// DefaultPubKeyTTL is the time to live of the pubkeys which are
+	// published with a Resolver.
+	DefaultPubKeyTTL = wire.MaxObjectTTL
+)
+
+// ErrCanceled is returned by Resolve when it is canceled before the pubkey
+// arrives.
+var ErrCanceled = errors.New("canceled")
+
+// ResolverConfig is the configuration of a Resolver. Zero values are
+// replaced by the defaults.
+type ResolverConfig struct {
+	// Send is called with each getpubkey object and each of our pubkeys
+	// which is published, once its proof of work is done. The object is to
+	// be given to the network. Send must not be nil.
+	Send func(msg *wire.MsgObject)
+
+	// RequestTTL is the time to live of a getpubkey object. The default,
+	// which is also used for a negative RequestTTL, is DefaultRequestTTL.
+	RequestTTL time.Duration
+
+	// PubKeyTTL is the time to live of the pubkeys of our identities
+	// which are published. The default, which is also used for a negative
+	// PubKeyTTL, is DefaultPubKeyTTL.
+	PubKeyTTL time.Duration
+}
+
+// request is a pubkey which is being waited for.
+type request struct {
+	address bmutil.Address
+	expires time.Time
+	waiters int
+
+	// done is closed once id is set.
+	done chan struct{}
+	id   identity.Public
+}
+
+// Resolver finds the pubkeys of the addresses which messages are to be sent
+// to. If the pubkey of an address is not known, Resolve sends a request for
+// it and waits until it arrives, so that the message can be encrypted.
+// Pubkeys which arrive are given to the Resolver with Handle, and those
+// which were requested are watched in the keyring, which serves as the
+// cache of pubkeys.
+//
+// A Resolver is safe for concurrent use.
+type Resolver struct {
+	keyring *Keyring
+	cfg     ResolverConfig
+
+	mtx      sync.Mutex
+	requests map[string]*request
+	ripes    map[hash.Ripe]*request
+	tags     map[hash.Sha]*request
+}
+
+// NewResolver returns a Resolver which caches pubkeys in keyring.
+func NewResolver(keyring *Keyring, cfg *ResolverConfig) *Resolver {
+	r := &Resolver{
+		keyring:  keyring,
+		cfg:      *cfg,
+		requests: make(map[string]*request),
+		ripes:    make(map[hash.Ripe]*request),
+		tags:     make(map[hash.Sha]*request),
+	}
+	if r.cfg.RequestTTL <= 0 {
+		r.cfg.RequestTTL = DefaultRequestTTL
+	}
+	if r.cfg.PubKeyTTL <= 0 {
+		r.cfg.PubKeyTTL = DefaultPubKeyTTL
+	}
+	return r
+}
+
+// cached returns the identity of address from the keyring, or nil if it is
+// not there.
+func (r *Resolver) cached(address bmutil.Address) identity.Public {
+	e := r.keyring.Lookup(address)
+	switch {
+	case e == nil:
+		return nil
+	case e.Watched != nil:
+		return e.Watched
+	case e.Private != nil:
+		return e.Private.Public()
+	default:
+		return nil
+	}
+}
+
+// sendRequest sends a getpubkey object for address which expires at the
+// given time.
+func (r *Resolver) sendRequest(address bmutil.Address, expiration time.Time) {
+	request := obj.NewGetPubKey(0, expiration, address)
+	doPow(request, networkPow, expiration, r.keyring.now())
+	r.cfg.Send(request.MsgObject())
+}
+
+// wait adds a waiter to the request for address, creating the request if
+// there is none, and returns it along with whether it is new.
+func (r *Resolver) wait(address bmutil.Address) (*request, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if req, ok := r.requests[address.String()]; ok {
+		req.waiters++
+		return req, false
+	}
+
+	req := &request{
+		address: address,
+		expires: r.keyring.now().Add(r.cfg.RequestTTL),
+		waiters: 1,
+		done:    make(chan struct{}),
+	}
+	r.requests[address.String()] = req
+	r.ripes[*address.RipeHash()] = req
+	r.tags[*bmutil.Tag(address)] = req
+	return req, true
+}
+
+// remove removes a request. It must be called with the mutex held.
+func (r *Resolver) remove(req *request) {
+	delete(r.requests, req.address.String())
+	delete(r.ripes, *req.address.RipeHash())
+	delete(r.tags, *bmutil.Tag(req.address))
+}
+
+// Resolve returns the public identity of address. If it is not in the
+// keyring, a request for it is sent and Resolve waits until it arrives,
+// sending the request again each time that it expires. Closing cancel
+// stops waiting, in which case ErrCanceled is returned. ErrInvalidTTL is
+// returned if the resolver was configured with a RequestTTL outside of
+// MinTTL and wire.MaxObjectTTL.
+func (r *Resolver) Resolve(address bmutil.Address,
+	cancel <-chan struct{}) (identity.Public, error) {
+
+	if err := checkTTL(r.cfg.RequestTTL); err != nil {
+		return nil, err
+	}
+
+	if id := r.cached(address); id != nil {
+		return id, nil
+	}
+
+	req, created := r.wait(address)
+	if created {
+		r.sendRequest(address, req.expires)
+	}
+
+	for {
+		r.mtx.Lock()
+		wait := req.expires.Sub(r.keyring.now())
+		r.mtx.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.done:
+			timer.Stop()
+			return req.id, nil
+
+		case <-cancel:
+			timer.Stop()
+			r.mtx.Lock()
+			req.waiters--
+			if req.waiters == 0 && r.requests[address.String()] == req {
+				r.remove(req)
+			}
+			r.mtx.Unlock()
+			return nil, ErrCanceled
+
+		case <-timer.C:
+			// Only one waiter sends the request again.
+			r.mtx.Lock()
+			var expires time.Time
+			if now := r.keyring.now(); !now.Before(req.expires) {
+				req.expires = now.Add(r.cfg.RequestTTL)
+				expires = req.expires
+			}
+			r.mtx.Unlock()
+
+			if !expires.IsZero() {
+				r.sendRequest(address, expires)
+			}
+		}
+	}
+}
+
+// lookup returns the address of the request with the given ripe or tag, or
+// nil if there is none.
+func (r *Resolver) lookup(ripe *hash.Ripe, tag *hash.Sha) bmutil.Address {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var req *request
+	if tag != nil {
+		req = r.tags[*tag]
+	} else {
+		req = r.ripes[*ripe]
+	}
+	if req == nil {
+		return nil
+	}
+	return req.address
+}
+
+// Handle reads a pubkey object. If it is the pubkey of an address which
+// has been requested, it is watched in the keyring, everyone waiting for
+// it in Resolve is given its identity and Handle returns it. Otherwise
+// Handle returns a *RejectError.
+func (r *Resolver) Handle(msg *wire.MsgObject) (identity.Public, error) {
+	if msg.Header().ObjectType != wire.ObjectTypePubKey {
+		return nil, reject(RejectUnsupported, nil)
+	}
+	if err := r.keyring.checkObject(msg, r.keyring.now()); err != nil {
+		return nil, err
+	}
+
+	id, err := openPubKey(msg, r.lookup)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.keyring.Watch(id); err != nil {
+		return nil, err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	req, ok := r.ripes[*id.Address().RipeHash()]
+	if !ok {
+		// Another copy of the pubkey arrived first.
+		return id, nil
+	}
+	r.remove(req)
+	req.id = id
+	close(req.done)
+	return id, nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// pubkey returns the pubkey object of an identity.
+func pubkey(t *testing.T, id *identity.PrivateID) *wire.MsgObject {
+	pk, err := cipher.GeneratePubKey(id, time.Hour)
+	if err != nil {
+		t.Fatalf("GeneratePubKey: %v", err)
+	}
+	msg, err := wire.DecodeMsgObject(wire.Encode(pk.Object()))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	message.TstDoPow(msg, lowPow)
+	return msg
+}
+
+// checkRequest checks that msg is a request for the pubkey of address.
+func checkRequest(t *testing.T, msg *wire.MsgObject, address bmutil.Address) {
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	request, ok := o.(*obj.GetPubKey)
+	if !ok {
+		t.Fatalf("request: got %T want *obj.GetPubKey", o)
+	}
+	if request.Tag != *bmutil.Tag(address) {
+		t.Errorf("request: got tag %s want %s", request.Tag, bmutil.Tag(address))
+	}
+	if !msg.CheckPow(lowPow, time.Now()) {
+		t.Error("request: insufficient proof of work")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	contact := sender(t)
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+
+	requests := make(chan *wire.MsgObject, 10)
+	r := message.NewResolver(keyring, &message.ResolverConfig{
+		Send: func(msg *wire.MsgObject) { requests <- msg },
+	})
+
+	// A pubkey which was not requested is not wanted.
+	_, err := r.Handle(pubkey(t, contact))
+	checkReject(t, "unrequested", err, message.RejectNotForUs)
+
+	type result struct {
+		id  identity.Public
+		err error
+	}
+	results := make(chan result, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			id, err := r.Resolve(contact.Address(), nil)
+			results <- result{id, err}
+		}()
+	}
+
+	checkRequest(t, <-requests, contact.Address())
+
+	id, err := r.Handle(pubkey(t, contact))
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if id.Address().String() != contact.Address().String() {
+		t.Errorf("Handle: got %s want %s", id.Address(), contact.Address())
+	}
+
+	for i := 0; i < 2; i++ {
+		res := <-results
+		if res.err != nil {
+			t.Fatalf("Resolve: %v", res.err)
+		}
+		if res.id.Address().String() != contact.Address().String() {
+			t.Errorf("Resolve: got %s want %s", res.id.Address(), contact.Address())
+		}
+	}
+	if n := len(requests); n != 0 {
+		t.Errorf("got %d more requests want 0", n)
+	}
+
+	// The pubkey is now watched, so it is not requested again.
+	if e := keyring.Lookup(contact.Address()); e == nil || e.Watched == nil {
+		t.Errorf("keyring: got %+v", e)
+	}
+	if _, err := r.Resolve(contact.Address(), nil); err != nil {
+		t.Errorf("Resolve: %v", err)
+	}
+	if n := len(requests); n != 0 {
+		t.Errorf("got %d requests want 0", n)
+	}
+}
+
+func TestResolverRequestAgain(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	// The clock jumps ahead once the first request is made, so that it
+	// expires within a second rather than after MinTTL.
+	var mtx sync.Mutex
+	var skip time.Duration
+	c := clock.Func(func() time.Time {
+		mtx.Lock()
+		defer mtx.Unlock()
+		now := time.Now().Add(skip)
+		skip = message.MinTTL - time.Second
+		return now
+	})
+
+	contact := sender(t)
+	requests := make(chan *wire.MsgObject, 10)
+	keyring := message.NewKeyring(&message.KeyringConfig{
+		Pow:   &lowPow,
+		Clock: c,
+	})
+	r := message.NewResolver(keyring, &message.ResolverConfig{
+		Send:       func(msg *wire.MsgObject) { requests <- msg },
+		RequestTTL: message.MinTTL,
+	})
+
+	cancel := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := r.Resolve(contact.Address(), cancel)
+		done <- err
+	}()
+
+	// The request is sent again once it expires.
+	checkRequest(t, <-requests, contact.Address())
+	checkRequest(t, <-requests, contact.Address())
+
+	close(cancel)
+	if err := <-done; err != message.ErrCanceled {
+		t.Errorf("Resolve: got %v want %v", err, message.ErrCanceled)
+	}
+
+	// Once nobody is waiting, the pubkey is no longer wanted.
+	_, err := r.Handle(pubkey(t, contact))
+	checkReject(t, "canceled", err, message.RejectNotForUs)
+}
+
+func TestResolverInvalidTTL(t *testing.T) {
+	keyring := message.NewKeyring(&message.KeyringConfig{Pow: &lowPow})
+	for _, ttl := range []time.Duration{message.MinTTL - time.Second,
+		wire.MaxObjectTTL + time.Second} {
+
+		r := message.NewResolver(keyring, &message.ResolverConfig{
+			Send:       func(*wire.MsgObject) { t.Error("request was sent") },
+			RequestTTL: ttl,
+		})
+		if _, err := r.Resolve(sender(t).Address(), nil); err != message.ErrInvalidTTL {
+			t.Errorf("ttl %s: got %v want %v", ttl, err, message.ErrInvalidTTL)
+		}
+	}
+}
+
+// TestResolverNegativeTTL tests that a negative RequestTTL is replaced by
+// the default rather than making the request expire over and over.
+func TestResolverNegativeTTL(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	now := time.Unix(1500000000, 0)
+	requests := make(chan *wire.MsgObject, 10)
+	r := message.NewResolver(message.NewKeyring(&message.KeyringConfig{
+		Pow:   &lowPow,
+		Clock: clock.Func(func() time.Time { return now }),
+	}), &message.ResolverConfig{
+		Send:       func(msg *wire.MsgObject) { requests <- msg },
+		RequestTTL: -time.Hour,
+	})
+
+	address := sender(t).Address()
+	cancel := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := r.Resolve(address, cancel)
+		done <- err
+	}()
+	if got, want := (<-requests).Header().Expiration(),
+		now.Add(message.DefaultRequestTTL); !got.Equal(want) {
+		t.Errorf("request expiration: got %v want %v", got, want)
+	}
+	close(cancel)
+	if err := <-done; err != message.ErrCanceled {
+		t.Errorf("Resolve: got %v want %v", err, message.ErrCanceled)
+	}
+	if n := len(requests); n != 0 {
+		t.Errorf("got %d more requests want 0", n)
+	}
+}
+
+// TestResolverClock tests that the requests and pubkeys sent by a Resolver
+// expire according to the clock of its keyring.
+func TestResolverClock(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	c := clock.NewManual(time.Unix(1500000000, 0))
+	sent := make(chan *wire.MsgObject, 10)
+	r := message.NewResolver(message.NewKeyring(&message.KeyringConfig{
+		Pow:   &lowPow,
+		Clock: c,
+	}), &message.ResolverConfig{
+		Send:       func(msg *wire.MsgObject) { sent <- msg },
+		RequestTTL: time.Hour,
+		PubKeyTTL:  2 * time.Hour,
+	})
+
+	if err := r.Publish(sender(t)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got, want := (<-sent).Header().Expiration(),
+		c.Now().Add(2*time.Hour); !got.Equal(want) {
+		t.Errorf("pubkey expiration: got %v want %v", got, want)
+	}
+
+	cancel := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := r.Resolve(recipient(t).Address(), cancel)
+		done <- err
+	}()
+	if got, want := (<-sent).Header().Expiration(),
+		c.Now().Add(time.Hour); !got.Equal(want) {
+		t.Errorf("request expiration: got %v want %v", got, want)
+	}
+	close(cancel)
+	<-done
+}
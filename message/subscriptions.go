@@ -0,0 +1,250 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// Subscriptions holds the addresses whose broadcasts are followed. Tagged
+// broadcasts are matched to a subscription by their tag, so only those which
+// belong to a subscription are decrypted. Tagless broadcasts have to be
+// tried against every subscription to an address without tags.
+//
+// Subscriptions is safe for concurrent use, so subscriptions may be added
+// and removed while broadcasts are being read.
+type Subscriptions struct {
+	mtx     sync.RWMutex
+	tagged  map[hash.Sha]bmutil.Address
+	tagless []bmutil.Address
+}
+
+// NewSubscriptions returns an empty set of subscriptions.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		tagged: make(map[hash.Sha]bmutil.Address),
+	}
+}
+
+// Subscribe adds an address whose broadcasts are to be read. Adding an
+// address more than once has no effect.
+func (s *Subscriptions) Subscribe(address bmutil.Address) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Only v4 addresses send broadcasts with tags.
+	if address.Version() == 4 {
+		s.tagged[*bmutil.Tag(address)] = address
+		return
+	}
+	for _, a := range s.tagless {
+		if a.String() == address.String() {
+			return
+		}
+	}
+	s.tagless = append(s.tagless, address)
+}
+
+// Unsubscribe removes an address.
+func (s *Subscriptions) Unsubscribe(address bmutil.Address) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if address.Version() == 4 {
+		delete(s.tagged, *bmutil.Tag(address))
+		return
+	}
+	for i, a := range s.tagless {
+		if a.String() == address.String() {
+			s.tagless = append(s.tagless[:i], s.tagless[i+1:]...)
+			return
+		}
+	}
+}
+
+// Addresses returns the addresses which are subscribed to.
+func (s *Subscriptions) Addresses() []bmutil.Address {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	addresses := make([]bmutil.Address, 0, len(s.tagged)+len(s.tagless))
+	for _, a := range s.tagged {
+		addresses = append(addresses, a)
+	}
+	return append(addresses, s.tagless...)
+}
+
+// candidates returns the subscriptions which could have sent an object. It
+// only looks at the header and the tag, so nothing is decoded or decrypted
+// for a tagged broadcast which nobody is subscribed to.
+func (s *Subscriptions) candidates(msg *wire.MsgObject) []bmutil.Address {
+	header := msg.Header()
+	if header.ObjectType != wire.ObjectTypeBroadcast {
+		return nil
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	switch header.Version {
+	case obj.TaggedBroadcastVersion:
+		var tag hash.Sha
+		payload := msg.Payload()
+		if len(payload) < hash.ShaSize {
+			return nil
+		}
+		copy(tag[:], payload)
+		if address, ok := s.tagged[tag]; ok {
+			return []bmutil.Address{address}
+		}
+		return nil
+	case obj.TaglessBroadcastVersion:
+		// Copy so that the list can change while it is being tried.
+		return append([]bmutil.Address(nil), s.tagless...)
+	default:
+		return nil
+	}
+}
+
+// open decrypts and verifies a broadcast from one of the subscriptions. It
+// returns a *RejectError if there is none or the broadcast is invalid, and
+// the error of ctx if ctx is done before every subscription is tried.
+func (s *Subscriptions) open(ctx context.Context, msg *wire.MsgObject) (*Received, error) {
+	addresses := s.candidates(msg)
+	if len(addresses) == 0 {
+		return nil, reject(RejectNotForUs, nil)
+	}
+
+	o, err := obj.ReadObject(wire.Encode(msg))
+	if err != nil {
+		return nil, reject(RejectMalformed, err)
+	}
+	broadcast, ok := o.(obj.Broadcast)
+	if !ok {
+		return nil, reject(RejectMalformed, nil)
+	}
+
+	b, i, err := cipher.TryDecryptAndVerifyBroadcastAny(ctx, broadcast,
+		addresses, countDecrypt)
+	switch {
+	case err == cipher.ErrInvalidIdentity:
+		return nil, reject(RejectNotForUs, nil)
+	case i < 0:
+		return nil, err
+	case err != nil:
+		return nil, verifyError(err)
+	}
+
+	return &Received{
+		Object:     msg,
+		Bitmessage: b.Bitmessage(),
+		Encoding:   format.MetadataOf(b.Bitmessage().Content),
+	}, nil
+}
+
+// Read reads the broadcasts from the subscriptions among the results of a
+// relay.Decoder, and sends each of them on the returned channel once it has
+// been decrypted and verified. Results with errors, objects which are not
+// broadcasts, broadcasts from other addresses and broadcasts which fail to
+// verify are dropped. Up to workers broadcasts are decrypted at once; if
+// workers is not positive, the number of CPUs is used.
+//
+// The returned channel is closed once in is closed and every broadcast has
+// been read. Closing cancel stops reading early.
+func (s *Subscriptions) Read(in <-chan *relay.DecodeResult,
+	cancel <-chan struct{}, workers int) <-chan *Received {
+
+	return s.ReadContext(cancelContext(cancel), in, workers)
+}
+
+// ReadContext is like Read, but stops once ctx is done. A broadcast which is
+// being tried against a large number of subscriptions without tags is given
+// up on between them.
+func (s *Subscriptions) ReadContext(ctx context.Context,
+	in <-chan *relay.DecodeResult, workers int) <-chan *Received {
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	out := make(chan *Received, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				var result *relay.DecodeResult
+				var ok bool
+				select {
+				case result, ok = <-in:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+
+				if result.Err != nil {
+					continue
+				}
+				r, err := s.open(ctx, result.Object)
+				if err != nil {
+					continue
+				}
+
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// channelContext is a context which is done when a channel is closed.
+type channelContext struct {
+	context.Context
+	done <-chan struct{}
+}
+
+// cancelContext returns a context which is canceled when cancel is closed.
+// A nil channel gives a context which is never done.
+func cancelContext(cancel <-chan struct{}) context.Context {
+	return &channelContext{Context: context.Background(), done: cancel}
+}
+
+// Done returns the channel. This is part of the context.Context interface.
+func (c *channelContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns context.Canceled once the channel is closed. This is part of
+// the context.Context interface.
+func (c *channelContext) Err() error {
+	select {
+	case <-c.done:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
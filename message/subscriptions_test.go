@@ -0,0 +1,99 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestSubscriptions(t *testing.T) {
+	s := message.NewSubscriptions()
+	a := sender(t).Address()
+	b := recipient(t).Address()
+
+	s.Subscribe(a)
+	s.Subscribe(a)
+	s.Subscribe(b)
+	if n := len(s.Addresses()); n != 2 {
+		t.Errorf("got %d addresses want 2", n)
+	}
+
+	s.Unsubscribe(a)
+	addresses := s.Addresses()
+	if len(addresses) != 1 || addresses[0].String() != b.String() {
+		t.Errorf("got addresses %v want [%s]", addresses, b)
+	}
+}
+
+// broadcast returns an encoded broadcast from id.
+func broadcast(t *testing.T, id *identity.PrivateID, body string) []byte {
+	o, err := message.NewMessage().From(id).Body(body).TTL(time.Hour).Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return wire.Encode(o)
+}
+
+func TestSubscriptionsRead(t *testing.T) {
+	message.TstSetNetworkPow(lowPow)
+	defer message.TstSetNetworkPow(pow.Default)
+
+	from := sender(t)
+	other := recipient(t)
+
+	msg, err := message.Compose(from, other.Public(),
+		&format.Encoding1{Body: "Hey there!"}, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	objects := [][]byte{
+		broadcast(t, from, "first"),
+		broadcast(t, other, "not subscribed"),
+		wire.Encode(msg),
+		[]byte{1, 2, 3},
+		broadcast(t, from, "second"),
+	}
+
+	s := message.NewSubscriptions()
+	s.Subscribe(from.Address())
+
+	in := make(chan []byte)
+	go func() {
+		for _, o := range objects {
+			in <- o
+		}
+		close(in)
+	}()
+
+	cancel := make(chan struct{})
+	defer close(cancel)
+	decoder := relay.NewDecoder(&relay.DecoderConfig{Pow: lowPow})
+	out := s.Read(decoder.Decode(in, cancel), cancel, 2)
+
+	bodies := make(map[string]bool)
+	for r := range out {
+		if !r.IsBroadcast() {
+			t.Error("received a message")
+		}
+		if got, want := r.Bitmessage.Public.Address().String(),
+			from.Address().String(); got != want {
+			t.Errorf("sender: got %s want %s", got, want)
+		}
+		bodies[string(r.Bitmessage.Content.Message())] = true
+	}
+
+	if len(bodies) != 2 {
+		t.Errorf("got %d broadcasts want 2", len(bodies))
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+// Default is the registry of the metrics which bmutil reports.
+var Default = NewRegistry()
+
+// The metrics which bmutil reports.
+var (
+	// MessagesReceived counts the messages received from peers by
+	// command.
+	MessagesReceived = Default.CounterVec("bitmessage_messages_received_total",
+		"Messages received from peers, by command.", "command")
+
+	// MessagesSent counts the messages sent to peers by command.
+	MessagesSent = Default.CounterVec("bitmessage_messages_sent_total",
+		"Messages sent to peers, by command.", "command")
+
+	// PowSeconds is the time taken to do the proof of work on each object
+	// which we send, in seconds.
+	PowSeconds = Default.Histogram("bitmessage_pow_seconds",
+		"Time taken to do proof of work, in seconds.",
+		ExponentialBuckets(0.01, 4, 10))
+
+	// DecryptAttempts counts the attempts to decrypt messages and
+	// broadcasts with each identity or subscription which might read
+	// them, by result.
+	DecryptAttempts = Default.CounterVec("bitmessage_decrypt_attempts_total",
+		"Attempts to decrypt objects, by result.", "result")
+)
+
+// The results by which DecryptAttempts are counted.
+const (
+	// DecryptOK is an object which was decrypted and verified.
+	DecryptOK = "ok"
+
+	// DecryptNotForUs is an object which the key could not decrypt.
+	DecryptNotForUs = "not for us"
+
+	// DecryptInvalid is an object which was decrypted but was invalid.
+	DecryptInvalid = "invalid"
+)
+
+// ObserveStore adds the number of objects in a store to Default, which is
+// read with count whenever the metrics are read, or is zero if count
+// fails. It may only be called once; count is usually a call to
+// store.Count.
+func ObserveStore(count func() (int, error)) {
+	Default.GaugeFunc("bitmessage_store_objects",
+		"Objects in the store.", func() float64 {
+			n, err := count()
+			if err != nil {
+				return 0
+			}
+			return float64(n)
+		})
+}
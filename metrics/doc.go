@@ -0,0 +1,22 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package metrics counts what the other packages of bmutil do, so that the
+behavior of a node can be watched on a dashboard.
+
+A Registry holds counters, gauges and histograms. It can publish them with
+expvar, and it is an http.Handler which serves them in the text format that
+Prometheus scrapes, so no Prometheus library is needed.
+
+Default holds the metrics which bmutil reports itself: the messages sent to
+and received from peers by command, the time taken to do proof of work and
+the attempts to decrypt objects. ObserveStore adds the number of objects in
+a store. Nothing is exported until the application asks for it, for
+example with
+
+	metrics.Default.Publish("bmutil")
+	http.Handle("/metrics", metrics.Default)
+*/
+package metrics
@@ -0,0 +1,188 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a count which only goes up. It is safe for concurrent use.
+type Counter struct {
+	v uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.v, n)
+}
+
+// Value returns the count.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// CounterVec is a set of counters which are told apart by the value of a
+// label, such as the command of a message. It is safe for concurrent use.
+type CounterVec struct {
+	label string
+
+	mtx      sync.RWMutex
+	counters map[string]*Counter
+}
+
+// newCounterVec returns an empty CounterVec.
+func newCounterVec(label string) *CounterVec {
+	return &CounterVec{
+		label:    label,
+		counters: make(map[string]*Counter),
+	}
+}
+
+// With returns the counter for a value of the label, which is created if
+// it does not exist.
+func (v *CounterVec) With(value string) *Counter {
+	v.mtx.RLock()
+	c, ok := v.counters[value]
+	v.mtx.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if c, ok = v.counters[value]; !ok {
+		c = &Counter{}
+		v.counters[value] = c
+	}
+	return c
+}
+
+// Values returns the count for each value of the label.
+func (v *CounterVec) Values() map[string]uint64 {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	values := make(map[string]uint64, len(v.counters))
+	for value, c := range v.counters {
+		values[value] = c.Value()
+	}
+	return values
+}
+
+// sorted returns the values of the label in order.
+func (v *CounterVec) sorted() []string {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// Gauge is a value which can go up and down. It is safe for concurrent
+// use.
+type Gauge struct {
+	bits uint64
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Histogram counts observations, such as durations, in buckets. It is safe
+// for concurrent use.
+type Histogram struct {
+	buckets []float64
+
+	mtx    sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// newHistogram returns an empty Histogram with the given upper bounds of
+// its buckets, which must be in increasing order.
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: append([]float64(nil), buckets...),
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds an observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mtx.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+	h.mtx.Unlock()
+}
+
+// HistogramSnapshot is the state of a Histogram at one time.
+type HistogramSnapshot struct {
+	// Buckets are the upper bounds of the buckets.
+	Buckets []float64
+
+	// Counts are the number of observations which are no more than the
+	// upper bound of each bucket.
+	Counts []uint64
+
+	// Sum is the sum of the observations.
+	Sum float64
+
+	// Count is the number of observations.
+	Count uint64
+}
+
+// Snapshot returns the state of the histogram.
+func (h *Histogram) Snapshot() *HistogramSnapshot {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	s := &HistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  make([]uint64, len(h.counts)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+		s.Counts[i] = total
+	}
+	return s
+}
+
+// ExponentialBuckets returns n bucket bounds, the first of which is start
+// and each of which is factor times the one before.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+	buckets := make([]float64, n)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/metrics"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.Counter("objects_total", "Objects seen.")
+	v := r.CounterVec("messages_total", "Messages by command.", "command")
+	g := r.Gauge("peers", "Connected peers.")
+	r.GaugeFunc("store_objects", "Objects in\nthe store.", func() float64 {
+		return 7
+	})
+	h := r.Histogram("pow_seconds", "Proof of work.",
+		metrics.ExponentialBuckets(1, 10, 2))
+
+	c.Add(3)
+	v.With("inv").Inc()
+	v.With("getdata").Add(2)
+	v.With(`a"b`).Inc()
+	g.Set(1.5)
+	h.Observe(0.5)
+	h.Observe(1)
+	h.Observe(50)
+
+	want := `# HELP objects_total Objects seen.
+# TYPE objects_total counter
+objects_total 3
+# HELP messages_total Messages by command.
+# TYPE messages_total counter
+messages_total{command="a\"b"} 1
+messages_total{command="getdata"} 2
+messages_total{command="inv"} 1
+# HELP peers Connected peers.
+# TYPE peers gauge
+peers 1.5
+# HELP store_objects Objects in\nthe store.
+# TYPE store_objects gauge
+store_objects 7
+# HELP pow_seconds Proof of work.
+# TYPE pow_seconds histogram
+pow_seconds_bucket{le="1"} 2
+pow_seconds_bucket{le="10"} 2
+pow_seconds_bucket{le="+Inf"} 3
+pow_seconds_sum 51.5
+pow_seconds_count 3
+`
+	var b bytes.Buffer
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WritePrometheus: got\n%s\nwant\n%s", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if got := w.Body.String(); got != want {
+		t.Errorf("ServeHTTP: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("objects_total", "Objects seen.").Inc()
+	r.CounterVec("messages_total", "Messages by command.", "command").
+		With("inv").Add(2)
+	r.Publish("metrics_test")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get("metrics_test").String()),
+		&got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := map[string]interface{}{
+		"objects_total":  1.0,
+		"messages_total": map[string]interface{}{"inv": 2.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestDuplicateName(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("objects_total", "Objects seen.")
+	defer func() {
+		if recover() == nil {
+			t.Error("reused name did not panic")
+		}
+	}()
+	r.Gauge("objects_total", "Objects seen.")
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a metric in a Registry.
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	value interface{}
+}
+
+// Registry holds a set of metrics, each with a unique name. Names should
+// follow the Prometheus conventions, such as bitmessage_pow_seconds. It is
+// safe for concurrent use.
+type Registry struct {
+	mtx     sync.RWMutex
+	metrics []*metric
+	names   map[string]struct{}
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]struct{}),
+	}
+}
+
+// add adds a metric. Like expvar.Publish, it panics if the name is taken.
+func (r *Registry) add(name, help, typ string, value interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.names[name]; ok {
+		panic("metrics: reuse of metric name " + name)
+	}
+	r.names[name] = struct{}{}
+	r.metrics = append(r.metrics, &metric{
+		name:  name,
+		help:  help,
+		typ:   typ,
+		value: value,
+	})
+}
+
+// Counter adds a counter.
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{}
+	r.add(name, help, "counter", c)
+	return c
+}
+
+// CounterVec adds a set of counters which are told apart by a label.
+func (r *Registry) CounterVec(name, help, label string) *CounterVec {
+	v := newCounterVec(label)
+	r.add(name, help, "counter", v)
+	return v
+}
+
+// Gauge adds a gauge.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.add(name, help, "gauge", g)
+	return g
+}
+
+// GaugeFunc adds a gauge whose value is read from f whenever the metrics
+// are read.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.add(name, help, "gauge", f)
+}
+
+// Histogram adds a histogram with the given upper bounds of its buckets,
+// which must be in increasing order.
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(buckets)
+	r.add(name, help, "histogram", h)
+	return h
+}
+
+// all returns the metrics in the order in which they were added.
+func (r *Registry) all() []*metric {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return append([]*metric(nil), r.metrics...)
+}
+
+// formatFloat formats a number as Prometheus does.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// helpEscaper and labelEscaper escape help text and label values in the
+// text format.
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range r.all() {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, helpEscaper.Replace(m.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+
+		switch v := m.value.(type) {
+		case *Counter:
+			fmt.Fprintf(bw, "%s %d\n", m.name, v.Value())
+		case *CounterVec:
+			values := v.Values()
+			for _, value := range v.sorted() {
+				fmt.Fprintf(bw, "%s{%s=\"%s\"} %d\n", m.name, v.label,
+					labelEscaper.Replace(value), values[value])
+			}
+		case *Gauge:
+			fmt.Fprintf(bw, "%s %s\n", m.name, formatFloat(v.Value()))
+		case func() float64:
+			fmt.Fprintf(bw, "%s %s\n", m.name, formatFloat(v()))
+		case *Histogram:
+			s := v.Snapshot()
+			for i, le := range s.Buckets {
+				fmt.Fprintf(bw, "%s_bucket{le=\"%s\"} %d\n", m.name,
+					formatFloat(le), s.Counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket{le=\"+Inf\"} %d\n", m.name, s.Count)
+			fmt.Fprintf(bw, "%s_sum %s\n", m.name, formatFloat(s.Sum))
+			fmt.Fprintf(bw, "%s_count %d\n", m.name, s.Count)
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format, so that a
+// Registry can be given to http.Handle.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WritePrometheus(w)
+}
+
+// Values returns the value of each metric by name. A counter is a uint64, a
+// set of counters is a map of the values of its label to uint64, a gauge is
+// a float64 and a histogram is a *HistogramSnapshot.
+func (r *Registry) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, m := range r.all() {
+		switch v := m.value.(type) {
+		case *Counter:
+			values[m.name] = v.Value()
+		case *CounterVec:
+			values[m.name] = v.Values()
+		case *Gauge:
+			values[m.name] = v.Value()
+		case func() float64:
+			values[m.name] = v()
+		case *Histogram:
+			values[m.name] = v.Snapshot()
+		}
+	}
+	return values
+}
+
+// Publish publishes the metrics with expvar under the given name, as a map
+// of the values given by Values. Like expvar.Publish, it panics if the name
+// is taken.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.Values()
+	}))
+}
@@ -0,0 +1,28 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package mockpeer provides a scriptable Bitmessage peer for tests.
+
+A MockPeer runs a script of steps over a connection to the code under test.
+Steps send messages or arbitrary bytes, including malformed frames built
+with Frame, and check the messages which are received. Run stops at the
+first step which fails and reports which one it was.
+
+For example, to check that a peer disconnects when sent a frame with a bad
+checksum:
+
+	a, b := net.Pipe()
+	m := mockpeer.New(a, wire.MainNet)
+	errs := m.Start(append(mockpeer.Handshake(1, []uint32{1}),
+		mockpeer.SendBytes(mockpeer.BadChecksum(
+			mockpeer.Frame(wire.MainNet, wire.CmdPong, nil))),
+		mockpeer.ExpectClose())...)
+	p, err := peer.NewInbound(cfg, b)
+	...
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+*/
+package mockpeer
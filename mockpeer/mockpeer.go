@@ -0,0 +1,235 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mockpeer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// DefaultTimeout is the time that a MockPeer waits for a message.
+const DefaultTimeout = 5 * time.Second
+
+// Step is a step in a script.
+type Step struct {
+	name string
+	run  func(m *MockPeer) error
+}
+
+// String returns the name of the step.
+func (s Step) String() string {
+	return s.name
+}
+
+// StepError is returned by Run when a step fails.
+type StepError struct {
+	Index int
+	Step  Step
+	Err   error
+}
+
+// Error returns a human-readable description of the error.
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d (%s): %v", e.Index, e.Step, e.Err)
+}
+
+// MockPeer runs scripts over a connection.
+type MockPeer struct {
+	conn net.Conn
+	net  wire.BitmessageNet
+
+	// Timeout is the time to wait for a message. If zero,
+	// DefaultTimeout is used.
+	Timeout time.Duration
+
+	// Received holds every message that has been read.
+	Received []wire.Message
+}
+
+// New returns a MockPeer which uses the given connection.
+func New(conn net.Conn, bmnet wire.BitmessageNet) *MockPeer {
+	return &MockPeer{conn: conn, net: bmnet}
+}
+
+// Conn returns the connection of the MockPeer.
+func (m *MockPeer) Conn() net.Conn {
+	return m.conn
+}
+
+// Run runs the steps in order and returns a *StepError for the first one
+// which fails.
+func (m *MockPeer) Run(steps ...Step) error {
+	for i, step := range steps {
+		if err := step.run(m); err != nil {
+			return &StepError{Index: i, Step: step, Err: err}
+		}
+	}
+	return nil
+}
+
+// Start runs the steps in a new goroutine and returns a channel which
+// receives the result of Run.
+func (m *MockPeer) Start(steps ...Step) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- m.Run(steps...)
+	}()
+	return errs
+}
+
+// read reads the next message.
+func (m *MockPeer) read() (wire.Message, error) {
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	m.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer m.conn.SetReadDeadline(time.Time{})
+
+	msg, _, err := wire.ReadMessage(m.conn, m.net)
+	if err != nil {
+		return nil, err
+	}
+	m.Received = append(m.Received, msg)
+	return msg, nil
+}
+
+// Send returns a step which sends a message.
+func Send(msg wire.Message) Step {
+	return Step{
+		name: "send " + msg.Command(),
+		run: func(m *MockPeer) error {
+			return wire.WriteMessage(m.conn, msg, m.net)
+		},
+	}
+}
+
+// SendBytes returns a step which writes the given bytes.
+func SendBytes(b []byte) Step {
+	return Step{
+		name: fmt.Sprintf("send %d bytes", len(b)),
+		run: func(m *MockPeer) error {
+			_, err := m.conn.Write(b)
+			return err
+		},
+	}
+}
+
+// Expect returns a step which reads a message and checks its command.
+func Expect(command string) Step {
+	return ExpectMsg("expect "+command, func(msg wire.Message) error {
+		if msg.Command() != command {
+			return fmt.Errorf("got %s want %s", msg.Command(), command)
+		}
+		return nil
+	})
+}
+
+// ExpectMsg returns a step which reads a message and checks it with the
+// given function.
+func ExpectMsg(name string, check func(wire.Message) error) Step {
+	return Step{
+		name: name,
+		run: func(m *MockPeer) error {
+			msg, err := m.read()
+			if err != nil {
+				return err
+			}
+			return check(msg)
+		},
+	}
+}
+
+// ExpectClose returns a step which reads and discards messages until the
+// connection is closed. It fails if the connection is still open after
+// the timeout.
+func ExpectClose() Step {
+	return Step{
+		name: "expect close",
+		run: func(m *MockPeer) error {
+			for {
+				_, err := m.read()
+				if err == nil {
+					continue
+				}
+				if e, ok := err.(net.Error); ok && e.Timeout() {
+					return fmt.Errorf("connection was not closed")
+				}
+				return nil
+			}
+		},
+	}
+}
+
+// Sleep returns a step which waits for the given time.
+func Sleep(d time.Duration) Step {
+	return Step{
+		name: "sleep " + d.String(),
+		run: func(m *MockPeer) error {
+			time.Sleep(d)
+			return nil
+		},
+	}
+}
+
+// Close returns a step which closes the connection.
+func Close() Step {
+	return Step{
+		name: "close",
+		run: func(m *MockPeer) error {
+			return m.conn.Close()
+		},
+	}
+}
+
+// Version returns a version message with the given nonce and streams.
+func Version(nonce uint64, streams []uint32) *wire.MsgVersion {
+	na := wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), 8444, 1, 0)
+	return wire.NewMsgVersion(na, na, nonce, streams)
+}
+
+// Handshake returns the steps which complete the handshake with a peer
+// which has made an outbound connection to us, or with an inbound peer,
+// which waits for our version before sending its own.
+func Handshake(nonce uint64, streams []uint32) []Step {
+	return []Step{
+		Send(Version(nonce, streams)),
+		Expect(wire.CmdVersion),
+		Send(&wire.MsgVerAck{}),
+		Expect(wire.CmdVerAck),
+	}
+}
+
+// Frame returns a frame containing the given command and payload with a
+// correct header. The payload need not be valid for the command.
+func Frame(bmnet wire.BitmessageNet, command string, payload []byte) []byte {
+	b := make([]byte, wire.MessageHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(b[0:4], uint32(bmnet))
+	copy(b[4:4+wire.CommandSize], command)
+	binary.BigEndian.PutUint32(b[16:20], uint32(len(payload)))
+	copy(b[20:24], hash.Sha512(payload)[:4])
+	copy(b[wire.MessageHeaderSize:], payload)
+	return b
+}
+
+// BadChecksum returns a copy of a frame with a corrupted checksum.
+func BadChecksum(frame []byte) []byte {
+	b := append([]byte(nil), frame...)
+	b[20] ^= 0xff
+	return b
+}
+
+// BadLength returns a copy of a frame whose header gives the wrong length
+// for the payload.
+func BadLength(frame []byte, length uint32) []byte {
+	b := append([]byte(nil), frame...)
+	binary.BigEndian.PutUint32(b[16:20], length)
+	return b
+}
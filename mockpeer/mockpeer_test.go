@@ -0,0 +1,126 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mockpeer_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/mockpeer"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+var cfg = &peer.Config{Net: wire.MainNet, Streams: []uint32{1}}
+
+// TestHandshake tests a script which completes the handshake and sends a
+// message.
+func TestHandshake(t *testing.T) {
+	a, b := net.Pipe()
+	m := mockpeer.New(a, wire.MainNet)
+	steps := append(mockpeer.Handshake(1, []uint32{1}),
+		mockpeer.Send(wire.NewMsgPong()))
+	errs := m.Start(steps...)
+
+	p, err := peer.NewInbound(cfg, b)
+	if err != nil {
+		t.Fatalf("NewInbound: %v", err)
+	}
+	defer p.Disconnect(nil)
+
+	select {
+	case msg := <-p.In():
+		if msg.Command() != wire.CmdPong {
+			t.Errorf("got %s want %s", msg.Command(), wire.CmdPong)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not received")
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(m.Received) != 2 {
+		t.Errorf("Received: got %d messages want %d", len(m.Received), 2)
+	}
+}
+
+// TestMalformed tests that a peer disconnects when it is sent malformed
+// frames after the handshake.
+func TestMalformed(t *testing.T) {
+	pong := mockpeer.Frame(wire.MainNet, wire.CmdPong, nil)
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"bad checksum", mockpeer.BadChecksum(pong)},
+		{"wrong network", mockpeer.Frame(0x01020304, wire.CmdPong, nil)},
+		{"too long", mockpeer.BadLength(pong, wire.MaxMessagePayload+1)},
+		{"truncated version", mockpeer.Frame(wire.MainNet,
+			wire.CmdVersion, []byte{0, 0, 0, 3})},
+	}
+
+	for _, test := range tests {
+		a, b := net.Pipe()
+		m := mockpeer.New(a, wire.MainNet)
+		steps := append(mockpeer.Handshake(1, []uint32{1}),
+			mockpeer.SendBytes(test.frame), mockpeer.ExpectClose())
+		errs := m.Start(steps...)
+
+		p, err := peer.NewInbound(cfg, b)
+		if err != nil {
+			t.Errorf("%s: NewInbound: %v", test.name, err)
+			a.Close()
+			continue
+		}
+
+		select {
+		case <-p.Done():
+		case <-time.After(time.Second):
+			t.Errorf("%s: peer was not disconnected", test.name)
+			p.Disconnect(nil)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("%s: Run: %v", test.name, err)
+		}
+	}
+}
+
+// TestStepError tests that the failing step is reported.
+func TestStepError(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	m := mockpeer.New(a, wire.MainNet)
+	m.Timeout = time.Second
+	errs := m.Start(mockpeer.Send(mockpeer.Version(1, []uint32{1})),
+		mockpeer.Expect(wire.CmdInv))
+
+	go peer.NewInbound(cfg, b)
+
+	err := <-errs
+	e, ok := err.(*mockpeer.StepError)
+	if !ok {
+		t.Fatalf("Run: got %v want *mockpeer.StepError", err)
+	}
+	if e.Index != 1 || e.Step.String() != "expect inv" {
+		t.Errorf("Run: got step %d (%s) want step 1 (expect inv)", e.Index,
+			e.Step)
+	}
+}
+
+// TestFrame tests that frames built by Frame can be read.
+func TestFrame(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+
+	go a.Write(mockpeer.Frame(wire.MainNet, wire.CmdVerAck, nil))
+	msg, _, err := wire.ReadMessage(b, wire.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if _, ok := msg.(*wire.MsgVerAck); !ok {
+		t.Errorf("ReadMessage: got %T want *wire.MsgVerAck", msg)
+	}
+}
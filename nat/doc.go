@@ -0,0 +1,18 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package nat requests port mappings from home routers so that nodes behind
+them can accept inbound connections.
+
+Two protocols are supported. UPnP gateways are found with DiscoverUPnP, or
+created from the location of their device description with NewUPnP.
+NAT-PMP gateways do not announce themselves, so NewNATPMP must be given the
+address of the gateway, which is usually the default route.
+
+A PortMapping keeps a mapping for the listening port alive while the node
+is running and reports the external address, which may be advertised to
+other nodes in version and addr messages.
+*/
+package nat
@@ -0,0 +1,18 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the nat package rather than than the nat_test
+package so it can bridge access to the internals to properly test cases
+which are either not possible or can't reliably be tested via the public
+interface. The functions are only exported while the tests are being run.
+*/
+
+package nat
+
+// TstNewNATPMP returns a NAT-PMP gateway listening at addr rather than on
+// the standard port.
+func TstNewNATPMP(addr string) NAT {
+	return newNATPMP(addr)
+}
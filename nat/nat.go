@@ -0,0 +1,158 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultLifetime is the lifetime requested for port mappings. The
+	// mapping is renewed when half of it has passed.
+	DefaultLifetime = 20 * time.Minute
+)
+
+// NAT is a router which can map ports on its external address to a port on
+// this host.
+type NAT interface {
+	// ExternalIP returns the external address of the router.
+	ExternalIP() (net.IP, error)
+
+	// AddPortMapping maps the external port to the internal port on this
+	// host for the given lifetime and returns the external port which was
+	// actually mapped. Protocol is either "tcp" or "udp".
+	AddPortMapping(protocol string, externalPort, internalPort int,
+		description string, lifetime time.Duration) (int, error)
+
+	// DeletePortMapping removes a mapping.
+	DeletePortMapping(protocol string, externalPort, internalPort int) error
+}
+
+// PortMapping keeps a TCP port mapping alive.
+type PortMapping struct {
+	nat         NAT
+	port        int
+	description string
+	lifetime    time.Duration
+
+	mtx      sync.Mutex
+	external *net.TCPAddr
+
+	quit chan struct{}
+	stop sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewPortMapping returns a PortMapping which maps the same port on the
+// router to port on this host. If lifetime is zero, DefaultLifetime is
+// used.
+func NewPortMapping(nat NAT, port int, description string,
+	lifetime time.Duration) *PortMapping {
+	if lifetime == 0 {
+		lifetime = DefaultLifetime
+	}
+	return &PortMapping{
+		nat:         nat,
+		port:        port,
+		description: description,
+		lifetime:    lifetime,
+	}
+}
+
+// Start adds the mapping and begins renewing it.
+func (m *PortMapping) Start() error {
+	if err := m.renew(); err != nil {
+		return err
+	}
+
+	m.quit = make(chan struct{})
+	m.wg.Add(1)
+	go m.renewHandler()
+	return nil
+}
+
+// Stop stops renewing the mapping and removes it. It does nothing if the
+// mapping was never started or has already been stopped.
+func (m *PortMapping) Stop() error {
+	if m.quit == nil {
+		return nil
+	}
+
+	var err error
+	m.stop.Do(func() {
+		close(m.quit)
+		m.wg.Wait()
+
+		m.mtx.Lock()
+		external := m.external
+		m.external = nil
+		m.mtx.Unlock()
+
+		if external != nil {
+			err = m.nat.DeletePortMapping("tcp", external.Port, m.port)
+		}
+	})
+	return err
+}
+
+// renew adds the mapping again and updates the external address.
+func (m *PortMapping) renew() error {
+	port, err := m.nat.AddPortMapping("tcp", m.port, m.port,
+		m.description, m.lifetime)
+	if err != nil {
+		return err
+	}
+	ip, err := m.nat.ExternalIP()
+	if err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	m.external = &net.TCPAddr{IP: ip, Port: port}
+	m.mtx.Unlock()
+	return nil
+}
+
+// renewHandler renews the mapping when half of its lifetime has passed.
+// If renewal fails it is retried at the same interval, and the last known
+// external address is kept until then.
+func (m *PortMapping) renewHandler() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.lifetime / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.renew()
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// ExternalAddr returns the external address of the mapping, or nil if it
+// has not been mapped.
+func (m *PortMapping) ExternalAddr() *net.TCPAddr {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.external
+}
+
+// NetAddress returns the external address of the mapping in a form which
+// can be advertised to other nodes, or nil if it has not been mapped.
+func (m *PortMapping) NetAddress(stream uint32,
+	services wire.ServiceFlag) *wire.NetAddress {
+	addr := m.ExternalAddr()
+	if addr == nil {
+		return nil
+	}
+	return wire.NewNetAddressIPPort(addr.IP, uint16(addr.Port), stream,
+		services)
+}
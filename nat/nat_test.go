@@ -0,0 +1,99 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/nat"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// fakeNAT is a NAT which records its mappings.
+type fakeNAT struct {
+	mtx      sync.Mutex
+	ip       net.IP
+	mappings map[int]int
+	adds     int
+}
+
+func (n *fakeNAT) ExternalIP() (net.IP, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.ip, nil
+}
+
+func (n *fakeNAT) AddPortMapping(protocol string, externalPort,
+	internalPort int, description string, lifetime time.Duration) (int, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.mappings[externalPort] = internalPort
+	n.adds++
+	return externalPort, nil
+}
+
+func (n *fakeNAT) DeletePortMapping(protocol string, externalPort,
+	internalPort int) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	delete(n.mappings, externalPort)
+	return nil
+}
+
+func (n *fakeNAT) numAdds() int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.adds
+}
+
+// TestPortMapping tests that a port mapping is added, renewed and removed.
+func TestPortMapping(t *testing.T) {
+	n := &fakeNAT{
+		ip:       net.IPv4(192, 0, 2, 1),
+		mappings: make(map[int]int),
+	}
+	m := nat.NewPortMapping(n, 8444, "bitmessage", 100*time.Millisecond)
+
+	if m.ExternalAddr() != nil || m.NetAddress(1, 0) != nil {
+		t.Error("address is known before the port has been mapped")
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("Stop before Start: %v", err)
+	}
+
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	addr := m.ExternalAddr()
+	if addr == nil || !addr.IP.Equal(n.ip) || addr.Port != 8444 {
+		t.Errorf("ExternalAddr: got %v want %v:%d", addr, n.ip, 8444)
+	}
+	na := m.NetAddress(1, wire.SFNodeNetwork)
+	if !na.IP.Equal(n.ip) || na.Port != 8444 || na.Stream != 1 ||
+		na.Services != wire.SFNodeNetwork {
+		t.Errorf("NetAddress: got %v", na)
+	}
+
+	time.Sleep(180 * time.Millisecond)
+	if adds := n.numAdds(); adds < 3 {
+		t.Errorf("mapping was added %d times, want at least %d", adds, 3)
+	}
+
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if len(n.mappings) != 0 {
+		t.Errorf("mappings after Stop: got %v want none", n.mappings)
+	}
+	if m.ExternalAddr() != nil {
+		t.Error("address is known after the mapping has been removed")
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// natpmpPort is the port on which NAT-PMP gateways listen.
+	natpmpPort = 5351
+
+	// natpmpTries is the number of times that a request is sent before
+	// giving up. The first try waits 250ms for a response and each
+	// following try waits twice as long as the last.
+	natpmpTries = 5
+)
+
+// ErrBadResponse is returned when a NAT-PMP gateway sends a response which
+// cannot be understood.
+var ErrBadResponse = errors.New("bad response from NAT-PMP gateway")
+
+// NATPMPError is returned when a NAT-PMP gateway returns a result code
+// other than success.
+type NATPMPError struct {
+	Code uint16
+}
+
+// natpmpResults gives the meanings of the result codes.
+var natpmpResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// Error returns a human-readable description of the error.
+func (e *NATPMPError) Error() string {
+	if s, ok := natpmpResults[e.Code]; ok {
+		return "NAT-PMP: " + s
+	}
+	return fmt.Sprintf("NAT-PMP: Unknown result code (%d)", e.Code)
+}
+
+// natpmp is a NAT-PMP gateway.
+type natpmp struct {
+	gateway string
+}
+
+// NewNATPMP returns the NAT-PMP gateway at the given address. No request
+// is sent until the gateway is used.
+func NewNATPMP(gateway net.IP) NAT {
+	return newNATPMP(net.JoinHostPort(gateway.String(),
+		strconv.Itoa(natpmpPort)))
+}
+
+func newNATPMP(addr string) *natpmp {
+	return &natpmp{gateway: addr}
+}
+
+// request sends a request to the gateway and returns the response, which
+// has been checked to answer the request and to be successful.
+func (n *natpmp) request(msg []byte, size int) ([]byte, error) {
+	conn, err := net.Dial("udp", n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 16)
+	wait := 250 * time.Millisecond
+	for i := 0; i < natpmpTries; i++ {
+		if _, err = conn.Write(msg); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(wait))
+		var m int
+		m, err = conn.Read(buf)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				wait *= 2
+				continue
+			}
+			return nil, err
+		}
+
+		if m < size || buf[0] != 0 || buf[1] != msg[1]|0x80 {
+			return nil, ErrBadResponse
+		}
+		if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+			return nil, &NATPMPError{code}
+		}
+		return buf[:m], nil
+	}
+	return nil, err
+}
+
+// ExternalIP returns the external address of the gateway.
+func (n *natpmp) ExternalIP() (net.IP, error) {
+	resp, err := n.request([]byte{0, 0}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// mapPort sends a mapping request. A lifetime of zero removes the mapping.
+func (n *natpmp) mapPort(protocol string, externalPort, internalPort int,
+	lifetime time.Duration) (int, error) {
+	msg := make([]byte, 12)
+	switch protocol {
+	case "udp":
+		msg[1] = 1
+	case "tcp":
+		msg[1] = 2
+	default:
+		return 0, fmt.Errorf("unsupported protocol %s", protocol)
+	}
+	binary.BigEndian.PutUint16(msg[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(msg[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(msg[8:12], uint32(lifetime/time.Second))
+
+	resp, err := n.request(msg, 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(resp[10:12])), nil
+}
+
+// AddPortMapping maps a port on the gateway to this host.
+func (n *natpmp) AddPortMapping(protocol string, externalPort,
+	internalPort int, description string, lifetime time.Duration) (int, error) {
+	return n.mapPort(protocol, externalPort, internalPort, lifetime)
+}
+
+// DeletePortMapping removes a mapping.
+func (n *natpmp) DeletePortMapping(protocol string, externalPort,
+	internalPort int) error {
+	_, err := n.mapPort(protocol, 0, internalPort, 0)
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/nat"
+)
+
+// natpmpGateway runs a fake NAT-PMP gateway which answers requests with
+// the given result code and records the mapping requests it receives.
+func natpmpGateway(t *testing.T, result uint16) (net.PacketConn, chan []byte) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+
+	requests := make(chan []byte, 10)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte(nil), buf[:n]...)
+			requests <- req
+
+			var resp []byte
+			if req[1] == 0 {
+				resp = make([]byte, 12)
+				copy(resp[8:], []byte{203, 0, 113, 7})
+			} else {
+				resp = make([]byte, 16)
+				copy(resp[8:10], req[4:6])
+				// Map one port above the requested port.
+				ext := binary.BigEndian.Uint16(req[6:8])
+				if ext != 0 {
+					ext++
+				}
+				binary.BigEndian.PutUint16(resp[10:12], ext)
+				copy(resp[12:16], req[8:12])
+			}
+			resp[1] = req[1] | 0x80
+			binary.BigEndian.PutUint16(resp[2:4], result)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn, requests
+}
+
+// TestNATPMP tests requests to a NAT-PMP gateway.
+func TestNATPMP(t *testing.T) {
+	conn, requests := natpmpGateway(t, 0)
+	defer conn.Close()
+	n := nat.TstNewNATPMP(conn.LocalAddr().String())
+
+	ip, err := n.ExternalIP()
+	if err != nil {
+		t.Fatalf("ExternalIP: %v", err)
+	}
+	if want := net.IPv4(203, 0, 113, 7); !ip.Equal(want) {
+		t.Errorf("ExternalIP: got %v want %v", ip, want)
+	}
+	<-requests
+
+	port, err := n.AddPortMapping("tcp", 8444, 8444, "", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPortMapping: %v", err)
+	}
+	if port != 8445 {
+		t.Errorf("AddPortMapping: got %d want %d", port, 8445)
+	}
+	req := <-requests
+	if req[1] != 2 {
+		t.Errorf("AddPortMapping: got opcode %d want %d", req[1], 2)
+	}
+	if lifetime := binary.BigEndian.Uint32(req[8:12]); lifetime != 3600 {
+		t.Errorf("AddPortMapping: got lifetime %d want %d", lifetime, 3600)
+	}
+
+	if err := n.DeletePortMapping("tcp", 8445, 8444); err != nil {
+		t.Fatalf("DeletePortMapping: %v", err)
+	}
+	req = <-requests
+	if lifetime := binary.BigEndian.Uint32(req[8:12]); lifetime != 0 {
+		t.Errorf("DeletePortMapping: got lifetime %d want %d", lifetime, 0)
+	}
+
+	if _, err := n.AddPortMapping("sctp", 1, 1, "", time.Hour); err == nil {
+		t.Error("AddPortMapping: expected error for unsupported protocol")
+	}
+}
+
+// TestNATPMPError tests that result codes are returned as errors.
+func TestNATPMPError(t *testing.T) {
+	conn, _ := natpmpGateway(t, 2)
+	defer conn.Close()
+	n := nat.TstNewNATPMP(conn.LocalAddr().String())
+
+	_, err := n.ExternalIP()
+	e, ok := err.(*nat.NATPMPError)
+	if !ok || e.Code != 2 {
+		t.Fatalf("ExternalIP: got %v want result code 2", err)
+	}
+	if want := "NAT-PMP: not authorized"; e.Error() != want {
+		t.Errorf("Error: got %s want %s", e.Error(), want)
+	}
+	e = &nat.NATPMPError{Code: 99}
+	if want := "NAT-PMP: Unknown result code (99)"; e.Error() != want {
+		t.Errorf("Error: got %s want %s", e.Error(), want)
+	}
+}
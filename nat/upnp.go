@@ -0,0 +1,316 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ssdpAddr is the multicast address to which UPnP searches are sent.
+	ssdpAddr = "239.255.255.250:1900"
+
+	// gatewayDevice is the device type of UPnP gateways.
+	gatewayDevice = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+)
+
+var (
+	// ErrNoGateway is returned by DiscoverUPnP when no gateway answers.
+	ErrNoGateway = errors.New("no UPnP gateway found")
+
+	// ErrNoService is returned by NewUPnP if the device does not offer a
+	// WAN connection service.
+	ErrNoService = errors.New("UPnP device has no WAN connection service")
+)
+
+// connectionServices are the service types through which ports can be
+// mapped, in order of preference.
+var connectionServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpService is a service in a UPnP device description.
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// upnpDevice is a device in a UPnP device description. Devices may contain
+// other devices.
+type upnpDevice struct {
+	DeviceType string        `xml:"deviceType"`
+	Devices    []upnpDevice  `xml:"deviceList>device"`
+	Services   []upnpService `xml:"serviceList>service"`
+}
+
+// upnpRoot is a UPnP device description.
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+// findService returns the service of the given type in the device or any
+// device within it.
+func (d *upnpDevice) findService(serviceType string) *upnpService {
+	for i := range d.Services {
+		if d.Services[i].ServiceType == serviceType {
+			return &d.Services[i]
+		}
+	}
+	for i := range d.Devices {
+		if s := d.Devices[i].findService(serviceType); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// upnp is a UPnP gateway.
+type upnp struct {
+	client      *http.Client
+	controlURL  string
+	serviceType string
+
+	// localIP is the address of this host on the gateway's network.
+	localIP net.IP
+}
+
+// DiscoverUPnP searches the local network for a UPnP gateway and returns
+// the first one which offers a WAN connection service.
+func DiscoverUPnP(timeout time.Duration) (NAT, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: " + gatewayDevice + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return nil, ErrNoGateway
+			}
+			return nil, err
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(
+			bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if !strings.Contains(resp.Header.Get("St"), "InternetGatewayDevice") {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+
+		if nat, err := NewUPnP(location, timeout); err == nil {
+			return nat, nil
+		}
+	}
+}
+
+// NewUPnP reads the device description at location and returns the
+// gateway which it describes.
+func NewUPnP(location string, timeout time.Duration) (NAT, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UPnP: device description returned status %d",
+			resp.StatusCode)
+	}
+
+	var root upnpRoot
+	if err := xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, err
+	}
+
+	var service *upnpService
+	for _, st := range connectionServices {
+		if service = root.Device.findService(st); service != nil {
+			break
+		}
+	}
+	if service == nil {
+		return nil, ErrNoService
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		if base, err = url.Parse(root.URLBase); err != nil {
+			return nil, err
+		}
+	}
+	control, err := base.Parse(service.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+
+	localIP, err := localIPFor(control.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upnp{
+		client:      client,
+		controlURL:  control.String(),
+		serviceType: service.ServiceType,
+		localIP:     localIP,
+	}, nil
+}
+
+// localIPFor returns the address of this host which is used to reach the
+// given host.
+func localIPFor(hostport string) (net.IP, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, "80"
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// soapArg is an argument to a SOAP action.
+type soapArg struct {
+	name, value string
+}
+
+// soapFault is the error returned by a UPnP action.
+type soapFault struct {
+	Code        int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+}
+
+// soapRequest performs an action and decodes the response into v, which
+// may be nil.
+func (u *upnp) soapRequest(action string, args []soapArg, v interface{}) error {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + u.serviceType + `">`)
+	for _, arg := range args {
+		body.WriteString("<" + arg.name + ">")
+		xml.EscapeText(&body, []byte(arg.value))
+		body.WriteString("</" + arg.name + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+
+	req, err := http.NewRequest("POST", u.controlURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+u.serviceType+"#"+action+`"`)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var fault soapFault
+		if xml.NewDecoder(resp.Body).Decode(&fault) == nil &&
+			fault.Code != 0 {
+			return fmt.Errorf("UPnP: %s failed: %s (%d)", action,
+				fault.Description, fault.Code)
+		}
+		return fmt.Errorf("UPnP: %s returned status %d", action,
+			resp.StatusCode)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// ExternalIP returns the external address of the gateway.
+func (u *upnp) ExternalIP() (net.IP, error) {
+	var resp struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := u.soapRequest("GetExternalIPAddress", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(resp.IP))
+	if ip == nil {
+		return nil, fmt.Errorf("UPnP: invalid external address %q", resp.IP)
+	}
+	return ip, nil
+}
+
+// AddPortMapping maps a port on the gateway to this host. UPnP gateways
+// map the requested external port or fail.
+func (u *upnp) AddPortMapping(protocol string, externalPort,
+	internalPort int, description string, lifetime time.Duration) (int, error) {
+	err := u.soapRequest("AddPortMapping", []soapArg{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", strings.ToUpper(protocol)},
+		{"NewInternalPort", strconv.Itoa(internalPort)},
+		{"NewInternalClient", u.localIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", description},
+		{"NewLeaseDuration", strconv.Itoa(int(lifetime / time.Second))},
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return externalPort, nil
+}
+
+// DeletePortMapping removes a mapping.
+func (u *upnp) DeletePortMapping(protocol string, externalPort,
+	internalPort int) error {
+	return u.soapRequest("DeletePortMapping", []soapArg{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", strings.ToUpper(protocol)},
+	}, nil)
+}
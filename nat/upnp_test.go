@@ -0,0 +1,175 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package nat_test
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/nat"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/control</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const testExternalIP = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+      <NewExternalIPAddress>198.51.100.4</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`
+
+const testFault = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <s:Fault>
+      <detail>
+        <UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+          <errorCode>718</errorCode>
+          <errorDescription>ConflictInMappingEntry</errorDescription>
+        </UPnPError>
+      </detail>
+    </s:Fault>
+  </s:Body>
+</s:Envelope>`
+
+// soapArgs reads the arguments of a SOAP action.
+func soapArgs(r *http.Request) map[string]string {
+	args := make(map[string]string)
+	d := xml.NewDecoder(r.Body)
+	var name string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return args
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name = tok.Name.Local
+		case xml.CharData:
+			args[name] = string(tok)
+		}
+	}
+}
+
+// TestUPnP tests requests to a UPnP gateway.
+func TestUPnP(t *testing.T) {
+	var mapped map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testDescription))
+	})
+	mux.HandleFunc("/control", func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("SOAPAction")
+		switch {
+		case strings.HasSuffix(action, `#GetExternalIPAddress"`):
+			w.Write([]byte(testExternalIP))
+		case strings.HasSuffix(action, `#AddPortMapping"`):
+			args := soapArgs(r)
+			if mapped != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(testFault))
+				return
+			}
+			mapped = args
+		case strings.HasSuffix(action, `#DeletePortMapping"`):
+			mapped = nil
+		default:
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	n, err := nat.NewUPnP(server.URL+"/desc.xml", time.Second)
+	if err != nil {
+		t.Fatalf("NewUPnP: %v", err)
+	}
+
+	ip, err := n.ExternalIP()
+	if err != nil {
+		t.Fatalf("ExternalIP: %v", err)
+	}
+	if want := net.IPv4(198, 51, 100, 4); !ip.Equal(want) {
+		t.Errorf("ExternalIP: got %v want %v", ip, want)
+	}
+
+	port, err := n.AddPortMapping("tcp", 8444, 8445, "bitmessage",
+		time.Hour)
+	if err != nil {
+		t.Fatalf("AddPortMapping: %v", err)
+	}
+	if port != 8444 {
+		t.Errorf("AddPortMapping: got %d want %d", port, 8444)
+	}
+	want := map[string]string{
+		"NewExternalPort":           "8444",
+		"NewProtocol":               "TCP",
+		"NewInternalPort":           "8445",
+		"NewInternalClient":         "127.0.0.1",
+		"NewPortMappingDescription": "bitmessage",
+		"NewLeaseDuration":          "3600",
+	}
+	for k, v := range want {
+		if mapped[k] != v {
+			t.Errorf("AddPortMapping: got %s=%q want %q", k, mapped[k], v)
+		}
+	}
+
+	_, err = n.AddPortMapping("tcp", 8444, 8445, "bitmessage", time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "ConflictInMappingEntry") {
+		t.Errorf("AddPortMapping: got %v want ConflictInMappingEntry", err)
+	}
+
+	if err := n.DeletePortMapping("tcp", 8444, 8445); err != nil {
+		t.Fatalf("DeletePortMapping: %v", err)
+	}
+	if mapped != nil {
+		t.Error("DeletePortMapping: mapping was not deleted")
+	}
+}
+
+// TestUPnPNoService tests that a device without a WAN connection service
+// is rejected.
+func TestUPnPNoService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<root><device><deviceType>x</deviceType></device></root>`))
+		}))
+	defer server.Close()
+
+	if _, err := nat.NewUPnP(server.URL, time.Second); err != nat.ErrNoService {
+		t.Errorf("NewUPnP: got %v want %v", err, nat.ErrNoService)
+	}
+}
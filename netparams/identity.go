@@ -0,0 +1,25 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netparams
+
+import (
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// NewPrivateID creates a random identity which demands the default proof
+// of work of the network. It is mainly useful on the test network, where
+// the easier proof of work allows messages to be sent quickly.
+func (p *Params) NewPrivateID(behavior uint32) (*identity.PrivateID, error) {
+	key, err := identity.NewRandom(1)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := identity.NewPrivateAddress(key, bmutil.DefaultAddressVersion,
+		bmutil.DefaultStream)
+	data := p.Pow
+	return identity.NewPrivateID(addr, behavior, &data), nil
+}
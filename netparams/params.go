@@ -0,0 +1,114 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package netparams defines the parameters which distinguish one Bitmessage
+// network from another.
+package netparams
+
+import (
+	"errors"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ErrUnknownNet is returned by ParamsForNet when there are no parameters
+// for a network.
+var ErrUnknownNet = errors.New("unknown network")
+
+// DNSSeed identifies a DNS seed. The addresses returned by a seed are all
+// assumed to listen on the same port.
+type DNSSeed struct {
+	// Host is the host name of the seed.
+	Host string
+
+	// Port is the port on which the nodes returned by the seed listen.
+	Port uint16
+}
+
+// Params defines a Bitmessage network by its parameters. These parameters
+// may be used by applications to differentiate networks as well as
+// addresses and keys for one network from those intended for use on
+// another network.
+type Params struct {
+	// Name is a human-readable identifier for the network.
+	Name string
+
+	// Net is the magic number which begins messages on the network.
+	Net wire.BitmessageNet
+
+	// DefaultPort is the port on which nodes listen by default.
+	DefaultPort uint16
+
+	// DNSSeeds are the seeds which may be asked for the addresses of
+	// nodes on the network.
+	DNSSeeds []DNSSeed
+
+	// SeedNodes are the addresses, in host:port form, of long-running
+	// nodes which may be used if none of the DNS seeds can be reached.
+	SeedNodes []string
+
+	// Pow is the proof of work that is demanded by default on the network.
+	Pow pow.Data
+
+	// FutureTolerance is how far ahead of the local clock an object may
+	// appear to have been created before it is rejected, to allow for
+	// clocks which are ahead. The time at which an object was created is
+	// taken to be its expiration less the longest time to live which the
+	// network allows.
+	FutureTolerance time.Duration
+}
+
+// MainNetParams defines the network parameters for the main Bitmessage
+// network.
+var MainNetParams = Params{
+	Name:        "mainnet",
+	Net:         wire.MainNet,
+	DefaultPort: 8444,
+	DNSSeeds: []DNSSeed{
+		{"bootstrap8444.bitmessage.org", 8444},
+		{"bootstrap8080.bitmessage.org", 8080},
+	},
+	SeedNodes: []string{
+		"5.45.99.75:8444",
+		"75.167.159.54:8444",
+		"95.165.168.168:8444",
+		"85.180.139.241:8444",
+		"158.222.217.190:8080",
+		"178.62.12.187:8448",
+		"24.188.198.204:8111",
+		"109.147.204.113:1195",
+		"178.11.46.221:8444",
+	},
+	Pow:             pow.Default,
+	FutureTolerance: 3 * time.Hour,
+}
+
+// TestNetParams defines the network parameters for the test network, which
+// is for developers to test their nodes against one another. The proof of
+// work is much easier than on the main network and there are no seeds.
+var TestNetParams = Params{
+	Name:        "testnet",
+	Net:         wire.TestNet,
+	DefaultPort: 18444,
+	Pow: pow.Data{
+		NonceTrialsPerByte: 10,
+		ExtraBytes:         10,
+	},
+	FutureTolerance: 3 * time.Hour,
+}
+
+// ParamsForNet returns the parameters of the network with the given magic
+// number.
+func ParamsForNet(net wire.BitmessageNet) (*Params, error) {
+	switch net {
+	case wire.MainNet:
+		return &MainNetParams, nil
+	case wire.TestNet:
+		return &TestNetParams, nil
+	default:
+		return nil, ErrUnknownNet
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netparams_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/netparams"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestParamsForNet tests looking up the parameters of a network.
+func TestParamsForNet(t *testing.T) {
+	tests := []struct {
+		net  wire.BitmessageNet
+		want *netparams.Params
+		err  error
+	}{
+		{wire.MainNet, &netparams.MainNetParams, nil},
+		{wire.TestNet, &netparams.TestNetParams, nil},
+		{0xffffffff, nil, netparams.ErrUnknownNet},
+	}
+
+	for i, test := range tests {
+		params, err := netparams.ParamsForNet(test.net)
+		if params != test.want || err != test.err {
+			t.Errorf("ParamsForNet #%d: got %v, %v want %v, %v", i,
+				params, err, test.want, test.err)
+		}
+	}
+
+	if netparams.TestNetParams.DefaultPort == netparams.MainNetParams.DefaultPort {
+		t.Error("the test network uses the same port as the main network")
+	}
+}
+
+// TestNewPrivateID tests that identities created for the test network
+// have valid addresses and demand the network's proof of work.
+func TestNewPrivateID(t *testing.T) {
+	params := &netparams.TestNetParams
+	id, err := params.NewPrivateID(0)
+	if err != nil {
+		t.Fatalf("NewPrivateID: %v", err)
+	}
+
+	if *id.Pow() != params.Pow {
+		t.Errorf("Pow: got %v want %v", *id.Pow(), params.Pow)
+	}
+
+	addr := id.Address()
+	decoded, err := bmutil.DecodeAddress(addr.String())
+	if err != nil {
+		t.Fatalf("DecodeAddress: %v", err)
+	}
+	if decoded.String() != addr.String() {
+		t.Errorf("DecodeAddress: got %s want %s", decoded, addr)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package outbox provides a durable queue of objects which have been composed
+but not yet delivered, so that pending messages survive a restart.
+
+Each entry moves through the states
+
+	queued -> pow -> sent -> acked
+
+An entry is queued when it is added. It moves to pow while its proof of
+work is being calculated and to sent once the finished object has been
+given to the network. It is acked when the acknowledgement which was
+embedded in it is seen. If no acknowledgement arrives in time, Retry puts a
+sent entry back in the queue so that it can be sent again with fresh proof
+of work.
+
+Proof of work which was interrupted by a restart is lost, so Recover should
+be called when the outbox is opened to put entries which were in the pow
+state back in the queue.
+
+A Tracker automates the acknowledgement side of this. It computes the
+inventory hash of the acknowledgement embedded in each message that is
+added, marks the entry as acked when that hash is seen on the network, and
+periodically retries entries whose acknowledgements are overdue, waiting
+twice as long after each attempt.
+
+A Resender is the alternative used by PyBitmessage. Rather than waiting for
+a fixed timeout, it sends an unacked object again shortly before it expires
+from the network, rebuilding it with twice the time to live and doing its
+proof of work anew.
+*/
+package outbox
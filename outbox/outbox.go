@@ -0,0 +1,405 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// ErrNotFound is returned when there is no entry with a given ID.
+	ErrNotFound = errors.New("entry not found")
+
+	// ErrInvalidTransition is returned when an entry cannot move from
+	// its current state to the requested one.
+	ErrInvalidTransition = errors.New("invalid state transition")
+
+	// ErrCorrupt is returned when an entry cannot be decoded.
+	ErrCorrupt = errors.New("corrupt entry")
+)
+
+var (
+	// entriesBucket maps IDs as 8-byte big-endian integers to encoded
+	// entries.
+	entriesBucket = []byte("entries")
+
+	// acksBucket maps the inventory hashes of acknowledgements to the
+	// IDs of the entries which expect them.
+	acksBucket = []byte("acks")
+)
+
+// State is the state of an entry.
+type State uint8
+
+// The states of an entry.
+const (
+	StateQueued State = iota
+	StatePow
+	StateSent
+	StateAcked
+)
+
+// stateStrings is a map of states back to their names for pretty printing.
+var stateStrings = map[State]string{
+	StateQueued: "queued",
+	StatePow:    "pow",
+	StateSent:   "sent",
+	StateAcked:  "acked",
+}
+
+// String returns the State in human-readable form.
+func (s State) String() string {
+	if str, ok := stateStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown State (%d)", uint8(s))
+}
+
+// Entry is an object in the outbox.
+type Entry struct {
+	ID    uint64
+	State State
+
+	// Object is the object to be sent. Until the entry has been sent,
+	// its nonce is not valid.
+	Object *wire.MsgObject
+
+	// Ack is the inventory hash of the acknowledgement which the
+	// recipient is expected to send, or nil if none is expected.
+	Ack *hash.Sha
+
+	// Attempts is the number of times that the object has been sent.
+	Attempts uint32
+
+	// Created is when the entry was added and Updated is when its state
+	// last changed.
+	Created time.Time
+	Updated time.Time
+}
+
+// encode serializes an entry.
+func (e *Entry) encode() []byte {
+	obj := wire.Encode(e.Object)
+	b := make([]byte, 1+4+8+8+1+hash.ShaSize, 1+4+8+8+1+hash.ShaSize+len(obj))
+	b[0] = byte(e.State)
+	binary.BigEndian.PutUint32(b[1:5], e.Attempts)
+	binary.BigEndian.PutUint64(b[5:13], uint64(e.Created.Unix()))
+	binary.BigEndian.PutUint64(b[13:21], uint64(e.Updated.Unix()))
+	if e.Ack != nil {
+		b[21] = 1
+		copy(b[22:], e.Ack[:])
+	}
+	return append(b, obj...)
+}
+
+// decodeEntry deserializes an entry.
+func decodeEntry(id uint64, b []byte) (*Entry, error) {
+	const size = 1 + 4 + 8 + 8 + 1 + hash.ShaSize
+	if len(b) < size {
+		return nil, ErrCorrupt
+	}
+
+	e := &Entry{
+		ID:       id,
+		State:    State(b[0]),
+		Attempts: binary.BigEndian.Uint32(b[1:5]),
+		Created:  time.Unix(int64(binary.BigEndian.Uint64(b[5:13])), 0),
+		Updated:  time.Unix(int64(binary.BigEndian.Uint64(b[13:21])), 0),
+	}
+	if b[21] == 1 {
+		var err error
+		if e.Ack, err = hash.NewSha(b[22:size]); err != nil {
+			return nil, err
+		}
+	}
+
+	// The decoded payload must not refer to memory owned by the
+	// database, so decode from a copy.
+	obj, err := wire.DecodeMsgObject(append([]byte{}, b[size:]...))
+	if err != nil {
+		return nil, err
+	}
+	e.Object = obj
+	return e, nil
+}
+
+// idKey returns the key of an entry.
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// Outbox is a queue of objects to be sent, backed by a BoltDB database.
+type Outbox struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// Config is the configuration of an Outbox.
+type Config struct {
+	// Clock tells the time at which entries are created and updated.
+	// Trackers and Resenders of the outbox also use it to decide which
+	// entries are overdue and when objects which are sent again expire.
+	// The default is clock.System.
+	Clock clock.Clock
+}
+
+// Open opens or creates the outbox at path. cfg may be nil, in which case
+// the defaults are used.
+func Open(path string, cfg *Config) (*Outbox, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(entriesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(acksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	o := &Outbox{db: db, now: clock.System.Now}
+	if cfg != nil {
+		o.now = clock.Or(cfg.Clock).Now
+	}
+	return o, nil
+}
+
+// Close closes the outbox.
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+// Add queues an object and returns the ID of its entry. Ack is the
+// inventory hash of the acknowledgement that is expected, or nil.
+func (o *Outbox) Add(obj *wire.MsgObject, ack *hash.Sha) (uint64, error) {
+	now := o.now()
+	e := &Entry{
+		State:   StateQueued,
+		Object:  obj,
+		Created: now,
+		Updated: now,
+	}
+	if ack != nil {
+		a := *ack
+		e.Ack = &a
+	}
+
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		id, err := entries.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+
+		if ack != nil {
+			if err := tx.Bucket(acksBucket).Put(ack[:], idKey(id)); err != nil {
+				return err
+			}
+		}
+		return entries.Put(idKey(id), e.encode())
+	})
+	if err != nil {
+		return 0, err
+	}
+	return e.ID, nil
+}
+
+// Get returns the entry with the given ID.
+func (o *Outbox) Get(id uint64) (*Entry, error) {
+	var e *Entry
+	err := o.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(entriesBucket).Get(idKey(id))
+		if b == nil {
+			return ErrNotFound
+		}
+		var err error
+		e, err = decodeEntry(id, b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// update changes the entry with the given ID with f, provided that it is
+// in the state from.
+func (o *Outbox) update(id uint64, from State, f func(e *Entry)) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		b := entries.Get(idKey(id))
+		if b == nil {
+			return ErrNotFound
+		}
+		e, err := decodeEntry(id, b)
+		if err != nil {
+			return err
+		}
+		if e.State != from {
+			return ErrInvalidTransition
+		}
+
+		f(e)
+		e.Updated = o.now()
+		return entries.Put(idKey(id), e.encode())
+	})
+}
+
+// StartPow moves a queued entry to the pow state.
+func (o *Outbox) StartPow(id uint64) error {
+	return o.update(id, StateQueued, func(e *Entry) {
+		e.State = StatePow
+	})
+}
+
+// Sent moves an entry from the pow state to the sent state. Obj is the
+// object with its proof of work, which is the one that was sent.
+func (o *Outbox) Sent(id uint64, obj *wire.MsgObject) error {
+	return o.update(id, StatePow, func(e *Entry) {
+		e.State = StateSent
+		e.Object = obj
+		e.Attempts++
+	})
+}
+
+// Retry puts a sent entry back in the queue to be sent again.
+func (o *Outbox) Retry(id uint64) error {
+	return o.update(id, StateSent, func(e *Entry) {
+		e.State = StateQueued
+	})
+}
+
+// Acked moves a sent entry to the acked state.
+func (o *Outbox) Acked(id uint64) error {
+	return o.update(id, StateSent, func(e *Entry) {
+		e.State = StateAcked
+	})
+}
+
+// Ack marks the entry which expects the acknowledgement with the given
+// inventory hash as acked and returns its ID. It returns false if no entry
+// which has been sent expects the acknowledgement.
+func (o *Outbox) Ack(ack *hash.Sha) (uint64, bool, error) {
+	var key []byte
+	o.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(acksBucket).Get(ack[:]); v != nil {
+			key = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if key == nil {
+		return 0, false, nil
+	}
+
+	id := binary.BigEndian.Uint64(key)
+	switch err := o.Acked(id); err {
+	case nil:
+		return id, true, nil
+	case ErrInvalidTransition, ErrNotFound:
+		return 0, false, nil
+	default:
+		return 0, false, err
+	}
+}
+
+// Remove removes an entry. It is not an error if there is no entry with
+// the given ID.
+func (o *Outbox) Remove(id uint64) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		b := entries.Get(idKey(id))
+		if b == nil {
+			return nil
+		}
+		e, err := decodeEntry(id, b)
+		if err != nil {
+			return err
+		}
+		if e.Ack != nil {
+			if err := tx.Bucket(acksBucket).Delete(e.Ack[:]); err != nil {
+				return err
+			}
+		}
+		return entries.Delete(idKey(id))
+	})
+}
+
+// each calls f with every entry in order of ID.
+func (o *Outbox) each(f func(e *Entry)) error {
+	return o.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(k, v []byte) error {
+			e, err := decodeEntry(binary.BigEndian.Uint64(k), v)
+			if err != nil {
+				return err
+			}
+			f(e)
+			return nil
+		})
+	})
+}
+
+// ByState returns the entries in the given state in order of ID.
+func (o *Outbox) ByState(state State) ([]*Entry, error) {
+	var entries []*Entry
+	err := o.each(func(e *Entry) {
+		if e.State == state {
+			entries = append(entries, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Unacked returns the entries which were sent before t and have not been
+// acked, which are candidates to be sent again.
+func (o *Outbox) Unacked(t time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	err := o.each(func(e *Entry) {
+		if e.State == StateSent && e.Ack != nil && e.Updated.Before(t) {
+			entries = append(entries, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Recover puts entries whose proof of work was interrupted back in the
+// queue and returns how many there were.
+func (o *Outbox) Recover() (int, error) {
+	entries, err := o.ByState(StatePow)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		err := o.update(e.ID, StatePow, func(e *Entry) {
+			e.State = StateQueued
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/outbox"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newObject returns an object with the given nonce and payload.
+func newObject(nonce uint64, payload string) *wire.MsgObject {
+	return wire.NewMsgObject(
+		wire.NewObjectHeader(pow.Nonce(nonce), time.Unix(2000000, 0), wire.ObjectTypeMsg,
+			1, 1),
+		[]byte(payload))
+}
+
+// openOutbox opens an outbox in a new temporary directory.
+func openOutbox(t *testing.T, cfg *outbox.Config) (*outbox.Outbox, string, func()) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	path := filepath.Join(dir, "outbox.db")
+	o, err := outbox.Open(path, cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Open: %v", err)
+	}
+	return o, path, func() {
+		o.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// checkState checks the state of an entry.
+func checkState(t *testing.T, o *outbox.Outbox, id uint64, want outbox.State) {
+	e, err := o.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if e.State != want {
+		t.Errorf("State: got %s want %s", e.State, want)
+	}
+}
+
+// TestStates tests the state transitions of an entry.
+func TestStates(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	o, _, cleanup := openOutbox(t, &outbox.Config{
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+	defer cleanup()
+
+	ack := hash.InventoryHash([]byte("ack"))
+	id, err := o.Add(newObject(0, "hello"), ack)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	checkState(t, o, id, outbox.StateQueued)
+
+	// Entries must go through every state in order.
+	if err := o.Sent(id, newObject(1, "hello")); err != outbox.ErrInvalidTransition {
+		t.Errorf("Sent: got %v want %v", err, outbox.ErrInvalidTransition)
+	}
+	if err := o.StartPow(id); err != nil {
+		t.Fatalf("StartPow: %v", err)
+	}
+	checkState(t, o, id, outbox.StatePow)
+
+	// Not acked before it has been sent.
+	if _, ok, _ := o.Ack(ack); ok {
+		t.Error("Ack: got true want false for an entry not yet sent")
+	}
+
+	now = now.Add(time.Minute)
+	sent := newObject(12345, "hello")
+	if err := o.Sent(id, sent); err != nil {
+		t.Fatalf("Sent: %v", err)
+	}
+	e, _ := o.Get(id)
+	if e.State != outbox.StateSent || e.Attempts != 1 ||
+		!bytes.Equal(wire.Encode(e.Object), wire.Encode(sent)) ||
+		!e.Updated.Equal(now) {
+		t.Errorf("Get: got %+v", e)
+	}
+
+	// No acknowledgement arrives, so the object is sent again.
+	unacked, err := o.Unacked(now.Add(time.Hour))
+	if err != nil || len(unacked) != 1 || unacked[0].ID != id {
+		t.Fatalf("Unacked: got %v, %v want entry %d", unacked, err, id)
+	}
+	if err := o.Retry(id); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	o.StartPow(id)
+	o.Sent(id, sent)
+
+	if got, ok, err := o.Ack(ack); !ok || got != id || err != nil {
+		t.Errorf("Ack: got %d, %v, %v want %d, true, nil", got, ok, err, id)
+	}
+	e, _ = o.Get(id)
+	if e.State != outbox.StateAcked || e.Attempts != 2 {
+		t.Errorf("Get: got state %s after %d attempts want %s after %d",
+			e.State, e.Attempts, outbox.StateAcked, 2)
+	}
+
+	if _, ok, _ := o.Ack(hash.InventoryHash([]byte("other"))); ok {
+		t.Error("Ack: got true want false for an unknown ack")
+	}
+
+	if err := o.Remove(id); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := o.Get(id); err != outbox.ErrNotFound {
+		t.Errorf("Get: got %v want %v", err, outbox.ErrNotFound)
+	}
+}
+
+// TestRecover tests that entries survive a restart and that interrupted
+// proof of work is queued again.
+func TestRecover(t *testing.T) {
+	o, path, cleanup := openOutbox(t, nil)
+	defer cleanup()
+
+	id1, _ := o.Add(newObject(0, "one"), nil)
+	id2, _ := o.Add(newObject(0, "two"), nil)
+	id3, _ := o.Add(newObject(0, "three"), nil)
+	o.StartPow(id2)
+	o.StartPow(id3)
+	o.Sent(id3, newObject(1, "three"))
+	o.Close()
+
+	o, err := outbox.Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer o.Close()
+
+	n, err := o.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Recover: got %d want %d", n, 1)
+	}
+
+	queued, err := o.ByState(outbox.StateQueued)
+	if err != nil {
+		t.Fatalf("ByState: %v", err)
+	}
+	if len(queued) != 2 || queued[0].ID != id1 || queued[1].ID != id2 {
+		t.Errorf("ByState: got %v want entries %d and %d", queued, id1, id2)
+	}
+	checkState(t, o, id3, outbox.StateSent)
+}
+
+// TestStateString tests the stringer for State.
+func TestStateString(t *testing.T) {
+	tests := []struct {
+		in   outbox.State
+		want string
+	}{
+		{outbox.StateQueued, "queued"},
+		{outbox.StatePow, "pow"},
+		{outbox.StateSent, "sent"},
+		{outbox.StateAcked, "acked"},
+		{0xff, "Unknown State (255)"},
+	}
+
+	for i, test := range tests {
+		if s := test.in.String(); s != test.want {
+			t.Errorf("String #%d: got %s want %s", i, s, test.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultResendLead is how long before the object of an unacked entry
+	// expires that it is sent again.
+	DefaultResendLead = time.Hour
+)
+
+// ResenderConfig is the configuration of a Resender. Zero values are
+// replaced by the defaults.
+type ResenderConfig struct {
+	// Rebuild returns a new object for an entry which expires at the given
+	// time, along with the proof of work which its recipient requires, or
+	// nil for the network default. The nonce of the object is ignored.
+	// Since the signature of an object covers its expiration, the object
+	// generally has to be signed and encrypted again. Rebuild must not be
+	// nil.
+	Rebuild func(e *Entry, expiration time.Time) (*wire.MsgObject, *pow.Data, error)
+
+	// Send is called with each entry whose object has been rebuilt, once
+	// its proof of work is done and it is marked as sent. The object is to
+	// be given to the network. Send must not be nil.
+	Send func(e *Entry)
+
+	// Lead is how long before the object of an unacked entry expires that
+	// it is sent again. The default is DefaultResendLead.
+	Lead time.Duration
+
+	// Interval is the time between checks when the Resender has been
+	// started. The default is DefaultAckInterval.
+	Interval time.Duration
+
+	// OnCheck, if not nil, is called with the number of entries sent again
+	// after each check which was started by the Resender itself.
+	OnCheck func(n int, err error)
+
+	// Logger, if not nil, is told of each entry which is sent again and
+	// of failures to send one.
+	Logger logging.Logger
+}
+
+// Resender sends the objects of unacked entries again shortly before they
+// expire from the network, as PyBitmessage does. Each time an object is
+// sent again its time to live is doubled, up to wire.MaxObjectTTL, so that a
+// recipient who is offline for a long time is not flooded.
+//
+// Unlike a Tracker, which puts overdue entries back in the queue for the
+// application to send, a Resender rebuilds the object, does the proof of
+// work and sends it itself.
+type Resender struct {
+	outbox *Outbox
+	cfg    ResenderConfig
+	log    logging.Logger
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewResender returns a Resender for the given outbox. cfg may be nil, but
+// Rebuild and Send must be set before the Resender is used.
+func NewResender(o *Outbox, cfg *ResenderConfig) *Resender {
+	if cfg == nil {
+		cfg = &ResenderConfig{}
+	}
+	r := &Resender{outbox: o, cfg: *cfg, log: logging.Or(cfg.Logger)}
+	if r.cfg.Lead == 0 {
+		r.cfg.Lead = DefaultResendLead
+	}
+	if r.cfg.Interval == 0 {
+		r.cfg.Interval = DefaultAckInterval
+	}
+	return r
+}
+
+// ResendTTL returns the time to live for the next attempt to send an entry.
+// It is twice the time to live which the object had when it was last sent,
+// but no less than message.MinTTL and no more than wire.MaxObjectTTL.
+func ResendTTL(e *Entry) time.Duration {
+	ttl := 2 * e.Object.Header().Expiration().Sub(e.Updated)
+	switch {
+	case ttl > wire.MaxObjectTTL || ttl <= 0:
+		return wire.MaxObjectTTL
+	case ttl < message.MinTTL:
+		return message.MinTTL
+	}
+	return ttl
+}
+
+// resend rebuilds the object of an entry, does its proof of work and marks
+// the entry as sent again. It returns the updated entry, or nil if the entry
+// was acked or removed in the meantime.
+func (r *Resender) resend(e *Entry, now time.Time) (*Entry, error) {
+	expiration := now.Add(ResendTTL(e))
+	obj, data, err := r.cfg.Rebuild(e, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = &pow.Default
+	}
+
+	ttl := uint64(expiration.Unix() - now.Unix())
+	start := time.Now()
+	obj.Header().Nonce = pow.Do(wire.Encode(obj), ttl, *data)
+	elapsed := time.Since(start)
+
+	// The entry is only moved through the queue once the proof of work is
+	// done, so an acknowledgement which arrives in the meantime still
+	// counts.
+	err = r.outbox.Retry(e.ID)
+	if err == nil {
+		err = r.outbox.StartPow(e.ID)
+	}
+	if err == nil {
+		err = r.outbox.Sent(e.ID, obj)
+	}
+	switch err {
+	case nil:
+		r.log.Log(logging.Info, "object sent again", "id", e.ID,
+			"expiration", expiration, "pow", elapsed)
+		return r.outbox.Get(e.ID)
+	case ErrInvalidTransition, ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// Check sends every unacked entry whose object is about to expire again and
+// returns the number of such entries.
+func (r *Resender) Check() (int, error) {
+	now := r.outbox.now()
+	entries, err := r.outbox.Unacked(now)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if e.Object.Header().Expiration().Sub(now) > r.cfg.Lead {
+			continue
+		}
+
+		sent, err := r.resend(e, now)
+		if err != nil {
+			r.log.Log(logging.Error, "could not send object again",
+				"id", e.ID, "err", err)
+			return n, err
+		}
+		if sent == nil {
+			continue
+		}
+		n++
+		r.cfg.Send(sent)
+	}
+	return n, nil
+}
+
+// Start begins checking at the configured interval.
+func (r *Resender) Start() {
+	r.quit = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := r.Check()
+				if r.cfg.OnCheck != nil {
+					r.cfg.OnCheck(n, err)
+				}
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the Resender.
+func (r *Resender) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/message"
+	"github.com/DanielKrawisz/bmutil/outbox"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestResendTTL(t *testing.T) {
+	sent := time.Unix(1000000, 0)
+	entry := func(ttl time.Duration) *outbox.Entry {
+		return &outbox.Entry{
+			Object: wire.NewMsgObject(wire.NewObjectHeader(0, sent.Add(ttl),
+				wire.ObjectTypeMsg, 1, 1), nil),
+			Updated: sent,
+		}
+	}
+
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{time.Minute, message.MinTTL},
+		{time.Hour, 2 * time.Hour},
+		{4 * 24 * time.Hour, 8 * 24 * time.Hour},
+		{20 * 24 * time.Hour, wire.MaxObjectTTL},
+		{-time.Hour, wire.MaxObjectTTL},
+	}
+
+	for _, test := range tests {
+		if got := outbox.ResendTTL(entry(test.ttl)); got != test.want {
+			t.Errorf("ResendTTL(%s): got %s want %s", test.ttl, got, test.want)
+		}
+	}
+}
+
+func TestNewResenderNilConfig(t *testing.T) {
+	o, _, cleanup := openOutbox(t, nil)
+	defer cleanup()
+
+	r := outbox.NewResender(o, nil)
+	if n, err := r.Check(); n != 0 || err != nil {
+		t.Errorf("Check: got %d, %v want 0, nil", n, err)
+	}
+}
+
+func TestResender(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	o, _, cleanup := openOutbox(t, &outbox.Config{
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+	defer cleanup()
+
+	lowPow := pow.Data{NonceTrialsPerByte: 1, ExtraBytes: 1}
+	var sent []*outbox.Entry
+	r := outbox.NewResender(o, &outbox.ResenderConfig{
+		Rebuild: func(e *outbox.Entry, expiration time.Time) (*wire.MsgObject, *pow.Data, error) {
+			header := e.Object.Header()
+			return wire.NewMsgObject(wire.NewObjectHeader(0, expiration,
+				header.ObjectType, header.Version, header.StreamNumber),
+				e.Object.Payload()), &lowPow, nil
+		},
+		Send: func(e *outbox.Entry) {
+			sent = append(sent, e)
+		},
+	})
+
+	_, ackHash := newAck(t, "ack")
+	add := func(payload string) uint64 {
+		id, err := o.Add(newObject(0, payload), ackHash)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if err := o.StartPow(id); err != nil {
+			t.Fatalf("StartPow: %v", err)
+		}
+		obj := wire.NewMsgObject(wire.NewObjectHeader(0, now.Add(2*time.Hour),
+			wire.ObjectTypeMsg, 1, 1), []byte(payload))
+		if err := o.Sent(id, obj); err != nil {
+			t.Fatalf("Sent: %v", err)
+		}
+		return id
+	}
+	check := func(want int) {
+		n, err := r.Check()
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if n != want {
+			t.Errorf("Check at %s: got %d want %d", now, n, want)
+		}
+	}
+
+	id := add("msg")
+
+	// The object is not sent again until it is about to expire.
+	now = now.Add(30 * time.Minute)
+	check(0)
+	now = now.Add(31 * time.Minute)
+	check(1)
+
+	if len(sent) != 1 {
+		t.Fatalf("Send: got %d entries want 1", len(sent))
+	}
+	e := sent[0]
+	if e.ID != id || e.State != outbox.StateSent || e.Attempts != 2 {
+		t.Errorf("Send: got entry %d in state %s after %d attempts",
+			e.ID, e.State, e.Attempts)
+	}
+	if got, want := e.Object.Header().Expiration(), now.Add(4*time.Hour); !got.Equal(want) {
+		t.Errorf("expiration: got %s want %s", got, want)
+	}
+	if !e.Object.CheckPow(lowPow, now) {
+		t.Error("insufficient proof of work")
+	}
+	checkState(t, o, id, outbox.StateSent)
+
+	// The time to live doubles with each attempt.
+	now = now.Add(3*time.Hour + time.Minute)
+	check(1)
+	if got, want := sent[1].Object.Header().Expiration(), now.Add(8*time.Hour); !got.Equal(want) {
+		t.Errorf("expiration: got %s want %s", got, want)
+	}
+
+	// An acked entry is not sent again.
+	if _, ok, err := o.Ack(ackHash); err != nil || !ok {
+		t.Fatalf("Ack: got %v, %v", ok, err)
+	}
+	now = now.Add(8 * time.Hour)
+	check(0)
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultAckTimeout is the time to wait for the acknowledgement of an
+	// object which has been sent once before sending it again.
+	DefaultAckTimeout = 2 * 24 * time.Hour
+
+	// DefaultAckInterval is the time between checks for objects whose
+	// acknowledgements are overdue.
+	DefaultAckInterval = 10 * time.Minute
+)
+
+// ErrInvalidAck is returned when an acknowledgement is too short to
+// contain an object.
+var ErrInvalidAck = errors.New("invalid acknowledgement")
+
+// AckHash returns the inventory hash of the object in an acknowledgement.
+// An acknowledgement is an encoded network message, so the object is what
+// follows the message header.
+func AckHash(ack []byte) (*hash.Sha, error) {
+	if len(ack) <= wire.MessageHeaderSize {
+		return nil, ErrInvalidAck
+	}
+	return hash.InventoryHash(ack[wire.MessageHeaderSize:]), nil
+}
+
+// TrackerConfig is the configuration of a Tracker. Zero values are replaced
+// by the defaults.
+type TrackerConfig struct {
+	// Timeout is the time to wait for an acknowledgement after an object
+	// is sent for the first time. It doubles with each attempt, so that
+	// an object whose recipient is offline for a long time is not sent
+	// over and over. The default is DefaultAckTimeout.
+	Timeout time.Duration
+
+	// Interval is the time between checks when the Tracker has been
+	// started. The default is DefaultAckInterval.
+	Interval time.Duration
+
+	// OnDelivered, if not nil, is called with the ID of an entry when its
+	// acknowledgement is seen.
+	OnDelivered func(id uint64)
+
+	// OnResend, if not nil, is called with each entry which has been put
+	// back in the queue because its acknowledgement is overdue.
+	OnResend func(e *Entry)
+
+	// OnCheck, if not nil, is called with the number of entries put back
+	// in the queue after each check which was started by the Tracker
+	// itself.
+	OnCheck func(n int, err error)
+}
+
+// Tracker watches for the acknowledgements of the objects in an Outbox. It
+// marks an entry as acked when its acknowledgement appears on the network,
+// and puts it back in the queue if no acknowledgement appears in time.
+type Tracker struct {
+	outbox *Outbox
+	cfg    TrackerConfig
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTracker returns a Tracker for the given outbox.
+func NewTracker(o *Outbox, cfg *TrackerConfig) *Tracker {
+	t := &Tracker{outbox: o}
+	if cfg != nil {
+		t.cfg = *cfg
+	}
+	if t.cfg.Timeout == 0 {
+		t.cfg.Timeout = DefaultAckTimeout
+	}
+	if t.cfg.Interval == 0 {
+		t.cfg.Interval = DefaultAckInterval
+	}
+	return t
+}
+
+// Add queues an object in the outbox along with the acknowledgement which
+// was embedded in it, and returns the ID of its entry. If ack is nil, no
+// acknowledgement is expected.
+func (t *Tracker) Add(obj *wire.MsgObject, ack []byte) (uint64, error) {
+	if ack == nil {
+		return t.outbox.Add(obj, nil)
+	}
+
+	invHash, err := AckHash(ack)
+	if err != nil {
+		return 0, err
+	}
+	return t.outbox.Add(obj, invHash)
+}
+
+// Seen is called with the inventory hash of each object which appears on
+// the network. If it is the acknowledgement of an entry that has been sent,
+// the entry is marked as acked and Seen returns true.
+func (t *Tracker) Seen(invHash *hash.Sha) (bool, error) {
+	id, ok, err := t.outbox.Ack(invHash)
+	if err != nil || !ok {
+		return false, err
+	}
+	if t.cfg.OnDelivered != nil {
+		t.cfg.OnDelivered(id)
+	}
+	return true, nil
+}
+
+// timeout returns how long to wait for the acknowledgement of an entry
+// which has been sent the given number of times.
+func (t *Tracker) timeout(attempts uint32) time.Duration {
+	timeout := t.cfg.Timeout
+	for i := uint32(1); i < attempts && timeout < 1<<62; i++ {
+		timeout *= 2
+	}
+	return timeout
+}
+
+// Check puts every entry whose acknowledgement is overdue back in the queue
+// and returns the number of such entries.
+func (t *Tracker) Check() (int, error) {
+	now := t.outbox.now()
+	entries, err := t.outbox.Unacked(now.Add(-t.cfg.Timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if e.Updated.Add(t.timeout(e.Attempts)).After(now) {
+			continue
+		}
+
+		switch err := t.outbox.Retry(e.ID); err {
+		case nil:
+		case ErrInvalidTransition, ErrNotFound:
+			// The entry was acked or removed in the meantime.
+			continue
+		default:
+			return n, err
+		}
+		n++
+
+		if t.cfg.OnResend != nil {
+			e.State = StateQueued
+			t.cfg.OnResend(e)
+		}
+	}
+	return n, nil
+}
+
+// Start begins checking at the configured interval.
+func (t *Tracker) Start() {
+	t.quit = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := t.Check()
+				if t.cfg.OnCheck != nil {
+					t.cfg.OnCheck(n, err)
+				}
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the Tracker.
+func (t *Tracker) Stop() {
+	close(t.quit)
+	t.wg.Wait()
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package outbox_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/outbox"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newAck returns an acknowledgement, which is an object encoded as a
+// network message, and the inventory hash of the object.
+func newAck(t *testing.T, payload string) ([]byte, *hash.Sha) {
+	obj := newObject(0, payload)
+	var buf bytes.Buffer
+	if err := wire.WriteMessage(&buf, obj, wire.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	return buf.Bytes(), hash.InventoryHash(wire.Encode(obj))
+}
+
+func TestAckHash(t *testing.T) {
+	ack, want := newAck(t, "ack")
+	got, err := outbox.AckHash(ack)
+	if err != nil {
+		t.Fatalf("AckHash: %v", err)
+	}
+	if !got.IsEqual(want) {
+		t.Errorf("AckHash: got %s want %s", got, want)
+	}
+
+	if _, err := outbox.AckHash(ack[:wire.MessageHeaderSize]); err != outbox.ErrInvalidAck {
+		t.Errorf("AckHash of header: got %v want %v", err, outbox.ErrInvalidAck)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+	o, _, cleanup := openOutbox(t, &outbox.Config{
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+	defer cleanup()
+
+	var delivered []uint64
+	var resent []*outbox.Entry
+	tracker := outbox.NewTracker(o, &outbox.TrackerConfig{
+		Timeout: time.Hour,
+		OnDelivered: func(id uint64) {
+			delivered = append(delivered, id)
+		},
+		OnResend: func(e *outbox.Entry) {
+			resent = append(resent, e)
+		},
+	})
+
+	ack, ackHash := newAck(t, "ack")
+	id, err := tracker.Add(newObject(0, "msg"), ack)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := tracker.Add(newObject(0, "no ack"), nil); err != nil {
+		t.Fatalf("Add without ack: %v", err)
+	}
+
+	send := func() {
+		if err := o.StartPow(id); err != nil {
+			t.Fatalf("StartPow: %v", err)
+		}
+		if err := o.Sent(id, newObject(1, "msg")); err != nil {
+			t.Fatalf("Sent: %v", err)
+		}
+	}
+	check := func(want int) {
+		n, err := tracker.Check()
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if n != want {
+			t.Errorf("Check at %s: got %d want %d", now, n, want)
+		}
+	}
+
+	// The first attempt times out after an hour.
+	send()
+	now = now.Add(30 * time.Minute)
+	check(0)
+	now = now.Add(31 * time.Minute)
+	check(1)
+	checkState(t, o, id, outbox.StateQueued)
+	if len(resent) != 1 || resent[0].ID != id {
+		t.Errorf("OnResend: got %v want entry %d", resent, id)
+	}
+
+	// The second attempt times out after two hours.
+	send()
+	now = now.Add(61 * time.Minute)
+	check(0)
+	now = now.Add(60 * time.Minute)
+	check(1)
+
+	// Seeing the acknowledgement marks the entry as delivered.
+	send()
+	ok, err := tracker.Seen(ackHash)
+	if err != nil {
+		t.Fatalf("Seen: %v", err)
+	}
+	if !ok {
+		t.Error("Seen: acknowledgement not recognized")
+	}
+	checkState(t, o, id, outbox.StateAcked)
+	if len(delivered) != 1 || delivered[0] != id {
+		t.Errorf("OnDelivered: got %v want [%d]", delivered, id)
+	}
+
+	// A delivered entry is not sent again.
+	now = now.Add(24 * time.Hour)
+	check(0)
+
+	if ok, _ := tracker.Seen(ackHash); ok {
+		t.Error("Seen: acknowledgement recognized twice")
+	}
+	if ok, _ := tracker.Seen(&hash.Sha{}); ok {
+		t.Error("Seen: unknown hash recognized")
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package pcap extracts Bitmessage frames from packet captures, so that
+traffic observed in the wild, such as a message which another client sends
+differently, can be replayed against bmutil in tests.
+
+A capture made with tcpdump or Wireshark in the pcap format is read packet
+by packet. The TCP segments to and from the Bitmessage port are put back in
+order for each direction of each connection, with retransmitted and
+overlapping data removed, and the resulting streams are cut into frames
+which are decoded with wire.ReadMessage:
+
+	frames, err := pcap.ReadFile("testdata/handshake.pcap", &pcap.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		if f.Err != nil {
+			t.Errorf("frame from %s: %v", f.Src, f.Err)
+		}
+	}
+
+A frame which does not decode is returned with its error rather than
+stopping the capture, since such frames are usually what is being looked
+for. Frame.Raw holds the bytes as they were sent, which can be written to a
+peer or a mockpeer.
+
+Ethernet, Linux cooked, loopback and raw IP captures are read, over IPv4 and
+IPv6. If a capture starts in the middle of a connection, or a segment is
+missing from it, the stream is picked up again at the next message header.
+Fragmented IP packets and the pcapng format are not supported.
+*/
+package pcap
@@ -0,0 +1,289 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The link types of the captures which can be read, as numbered in the
+// pcap format.
+const (
+	linkNull      = 0
+	linkEthernet  = 1
+	linkRaw       = 101
+	linkLinuxSLL  = 113
+	linkIPv4      = 228
+	linkIPv6      = 229
+	linkLinuxSLL2 = 276
+)
+
+const (
+	// headerSize is the size of the header at the start of a capture.
+	headerSize = 24
+
+	// recordSize is the size of the header before each packet.
+	recordSize = 16
+
+	// maxPacketSize is the largest packet which is read, which is the
+	// default snapshot length of tcpdump.
+	maxPacketSize = 262144
+)
+
+// The TCP flags which matter for reassembly.
+const (
+	flagFIN = 1 << 0
+	flagSYN = 1 << 1
+	flagRST = 1 << 2
+)
+
+var (
+	// ErrUnknownFormat is returned by NewReader when the input is not a
+	// capture in the pcap format.
+	ErrUnknownFormat = errors.New("not a pcap capture")
+
+	// ErrUnsupportedLinkType is returned by NewReader when the packets of
+	// the capture are of a kind which cannot be read.
+	ErrUnsupportedLinkType = errors.New("unsupported link type")
+)
+
+// fileHeader is what the header at the start of a capture says about the
+// packets which follow it.
+type fileHeader struct {
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+}
+
+// readFileHeader reads the header at the start of a capture.
+func readFileHeader(r io.Reader) (*fileHeader, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrUnknownFormat
+		}
+		return nil, err
+	}
+
+	h := &fileHeader{}
+	switch binary.LittleEndian.Uint32(b[0:4]) {
+	case 0xa1b2c3d4:
+		h.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		h.order, h.nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		h.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		h.order, h.nano = binary.BigEndian, true
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	// The upper bits of the link type hold other information.
+	h.linkType = h.order.Uint32(b[20:24]) & 0x0fffffff
+	switch h.linkType {
+	case linkNull, linkEthernet, linkRaw, linkLinuxSLL, linkIPv4, linkIPv6,
+		linkLinuxSLL2:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedLinkType, h.linkType)
+	}
+	return h, nil
+}
+
+// readPacket reads the next packet of a capture and the time at which it
+// was captured. It returns io.EOF at the end of the capture.
+func (h *fileHeader) readPacket(r io.Reader) (time.Time, []byte, error) {
+	var b [recordSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	sec := int64(h.order.Uint32(b[0:4]))
+	frac := int64(h.order.Uint32(b[4:8]))
+	if !h.nano {
+		frac *= int64(time.Microsecond)
+	}
+	size := h.order.Uint32(b[8:12])
+	if size > maxPacketSize {
+		return time.Time{}, nil, fmt.Errorf("packet of %d bytes is too large",
+			size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, nil, err
+	}
+	return time.Unix(sec, frac), data, nil
+}
+
+// endpoint is one end of a TCP connection.
+type endpoint struct {
+	ip   [16]byte
+	port uint16
+}
+
+// segment is a TCP segment taken from a packet.
+type segment struct {
+	src, dst endpoint
+	seq      uint32
+	flags    byte
+	data     []byte
+}
+
+// decodePacket returns the TCP segment carried by a packet, or false if the
+// packet does not carry one.
+func (h *fileHeader) decodePacket(b []byte) (*segment, bool) {
+	switch h.linkType {
+	case linkNull:
+		// The address family is in the byte order of the machine which
+		// made the capture, so the IP version is looked at instead.
+		if len(b) < 4 {
+			return nil, false
+		}
+		return decodeIP(b[4:])
+	case linkEthernet:
+		if len(b) < 14 {
+			return nil, false
+		}
+		etherType, b := binary.BigEndian.Uint16(b[12:14]), b[14:]
+		for etherType == 0x8100 || etherType == 0x88a8 {
+			// Skip VLAN tags.
+			if len(b) < 4 {
+				return nil, false
+			}
+			etherType, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+		return decodeEtherType(etherType, b)
+	case linkLinuxSLL:
+		if len(b) < 16 {
+			return nil, false
+		}
+		return decodeEtherType(binary.BigEndian.Uint16(b[14:16]), b[16:])
+	case linkLinuxSLL2:
+		if len(b) < 20 {
+			return nil, false
+		}
+		return decodeEtherType(binary.BigEndian.Uint16(b[0:2]), b[20:])
+	default:
+		return decodeIP(b)
+	}
+}
+
+// decodeEtherType returns the TCP segment carried by a packet of the given
+// EtherType.
+func decodeEtherType(etherType uint16, b []byte) (*segment, bool) {
+	switch etherType {
+	case 0x0800, 0x86dd:
+		return decodeIP(b)
+	default:
+		return nil, false
+	}
+}
+
+// decodeIP returns the TCP segment carried by an IPv4 or IPv6 packet.
+func decodeIP(b []byte) (*segment, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+
+	var src, dst endpoint
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return nil, false
+		}
+		size := int(b[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(b[2:4]))
+		if size < 20 || total < size || total > len(b) {
+			return nil, false
+		}
+
+		// Fragments are not put back together.
+		if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 || b[9] != 6 {
+			return nil, false
+		}
+		copy(src.ip[:], v4InV6Prefix)
+		copy(src.ip[12:], b[12:16])
+		copy(dst.ip[:], v4InV6Prefix)
+		copy(dst.ip[12:], b[16:20])
+
+		// Anything after the total length, such as Ethernet padding,
+		// is not part of the packet.
+		b = b[size:total]
+	case 6:
+		if len(b) < 40 {
+			return nil, false
+		}
+		total := 40 + int(binary.BigEndian.Uint16(b[4:6]))
+		if total > len(b) {
+			return nil, false
+		}
+		next := b[6]
+		copy(src.ip[:], b[8:24])
+		copy(dst.ip[:], b[24:40])
+		b = b[40:total]
+
+		for next != 6 {
+			var size int
+			switch next {
+			case 0, 43, 60:
+				// Hop-by-hop, routing and destination options.
+				if len(b) < 2 {
+					return nil, false
+				}
+				size = (int(b[1]) + 1) * 8
+			case 51:
+				// Authentication header.
+				if len(b) < 2 {
+					return nil, false
+				}
+				size = (int(b[1]) + 2) * 4
+			default:
+				// Fragments and other protocols.
+				return nil, false
+			}
+			if size > len(b) {
+				return nil, false
+			}
+			next, b = b[0], b[size:]
+		}
+	default:
+		return nil, false
+	}
+
+	return decodeTCP(src, dst, b)
+}
+
+// v4InV6Prefix is the prefix of an IPv4 address mapped to IPv6.
+var v4InV6Prefix = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+
+// decodeTCP returns the TCP segment in b, which is sent between the given
+// addresses.
+func decodeTCP(src, dst endpoint, b []byte) (*segment, bool) {
+	if len(b) < 20 {
+		return nil, false
+	}
+	size := int(b[12]>>4) * 4
+	if size < 20 || size > len(b) {
+		return nil, false
+	}
+
+	src.port = binary.BigEndian.Uint16(b[0:2])
+	dst.port = binary.BigEndian.Uint16(b[2:4])
+	return &segment{
+		src:   src,
+		dst:   dst,
+		seq:   binary.BigEndian.Uint32(b[4:8]),
+		flags: b[13],
+		data:  b[size:],
+	}, true
+}
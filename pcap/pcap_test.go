@@ -0,0 +1,312 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/pcap"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// The TCP flags used in the tests.
+const (
+	fin = 1 << 0
+	syn = 1 << 1
+)
+
+var (
+	client = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+	server = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 8444}
+	epoch  = time.Unix(1500000000, 0)
+)
+
+// capture writes a capture of Ethernet packets in the pcap format.
+type capture struct {
+	bytes.Buffer
+	packets int
+}
+
+// newCapture returns a capture of the given link type.
+func newCapture(linkType uint32) *capture {
+	c := &capture{}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], linkType)
+	c.Write(header)
+	return c
+}
+
+// tcp writes an Ethernet packet holding an IPv4 TCP segment.
+func (c *capture) tcp(src, dst *net.TCPAddr, seq uint32, flags byte, data []byte) {
+	segment := make([]byte, 20, 20+len(data))
+	binary.BigEndian.PutUint16(segment[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(segment[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint32(segment[4:8], seq)
+	segment[12] = 5 << 4
+	segment[13] = flags
+	segment = append(segment, data...)
+
+	ip := make([]byte, 20, 20+len(segment))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(segment)))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], src.IP.To4())
+	copy(ip[16:20], dst.IP.To4())
+	ip = append(ip, segment...)
+
+	ether := make([]byte, 14, 14+len(ip)+4)
+	binary.BigEndian.PutUint16(ether[12:14], 0x0800)
+	ether = append(ether, ip...)
+
+	// Padding after the IP packet is not part of it.
+	ether = append(ether, 0, 0, 0, 0)
+
+	c.packet(ether)
+}
+
+// packet writes a packet, captured one second after the one before.
+func (c *capture) packet(data []byte) {
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:4],
+		uint32(epoch.Unix())+uint32(c.packets))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(data)))
+	c.Write(record)
+	c.Write(data)
+	c.packets++
+}
+
+// encode returns the frame of a message.
+func encode(t *testing.T, msg wire.Message) []byte {
+	var buf bytes.Buffer
+	if err := wire.WriteMessage(&buf, msg, wire.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// version returns the frame of a version message.
+func version(t *testing.T) []byte {
+	me := wire.NewNetAddressIPPort(client.IP, uint16(client.Port), 1, 0)
+	you := wire.NewNetAddressIPPort(server.IP, uint16(server.Port), 1, 0)
+	return encode(t, wire.NewMsgVersion(me, you, 123, []uint32{1}))
+}
+
+// checkFrames checks the commands and senders of frames.
+func checkFrames(t *testing.T, frames []*pcap.Frame, commands []string, senders []*net.TCPAddr) {
+	t.Helper()
+	if len(frames) != len(commands) {
+		t.Fatalf("got %d frames want %d", len(frames), len(commands))
+	}
+	for i, f := range frames {
+		if f.Err != nil {
+			t.Errorf("frame %d: %v", i, f.Err)
+			continue
+		}
+		if f.Message.Command() != commands[i] {
+			t.Errorf("frame %d: got %s want %s", i, f.Message.Command(),
+				commands[i])
+		}
+		if f.Src.String() != senders[i].String() {
+			t.Errorf("frame %d: got sender %s want %s", i, f.Src, senders[i])
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	ver := version(t)
+	verack := encode(t, wire.NewMsgVerAck())
+	pong := encode(t, wire.NewMsgPong())
+
+	c := newCapture(1)
+	c.tcp(client, server, 1000, syn, nil)
+	c.tcp(server, client, 5000, syn, nil)
+
+	// The version is split in three, and the last part arrives first.
+	c.tcp(client, server, 1001+40, 0, ver[40:])
+	c.tcp(client, server, 1001, 0, ver[:20])
+	c.tcp(client, server, 1001+20, 0, ver[20:40])
+
+	// Segments which were sent twice or which overlap are only counted
+	// once.
+	c.tcp(server, client, 5001, 0, verack)
+	c.tcp(server, client, 5001, 0, verack)
+	c.tcp(server, client, 5001+10, 0, append(verack[10:], pong[:10]...))
+	c.tcp(server, client, 5001+uint32(len(verack)), fin, pong)
+
+	// Traffic on other ports is left out.
+	other := &net.TCPAddr{IP: server.IP, Port: 9000}
+	c.tcp(client, other, 1, syn, nil)
+	c.tcp(client, other, 2, 0, verack)
+
+	frames, err := pcap.ReadAll(bytes.NewReader(c.Bytes()), &pcap.Config{})
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	checkFrames(t, frames, []string{wire.CmdVersion, wire.CmdVerAck, wire.CmdPong},
+		[]*net.TCPAddr{client, server, server})
+
+	if !bytes.Equal(frames[0].Raw, ver) {
+		t.Errorf("Raw: got %x want %x", frames[0].Raw, ver)
+	}
+	if frames[0].Dst.String() != server.String() {
+		t.Errorf("Dst: got %s want %s", frames[0].Dst, server)
+	}
+	if !frames[0].Time.Equal(epoch.Add(4 * time.Second)) {
+		t.Errorf("Time: got %v want %v", frames[0].Time,
+			epoch.Add(4*time.Second))
+	}
+
+	// The other port is read if it is configured.
+	frames, err = pcap.ReadAll(bytes.NewReader(c.Bytes()),
+		&pcap.Config{Ports: []uint16{9000}})
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	checkFrames(t, frames, []string{wire.CmdVerAck}, []*net.TCPAddr{client})
+}
+
+func TestReaderResync(t *testing.T) {
+	ver := version(t)
+	verack := encode(t, wire.NewMsgVerAck())
+	pong := encode(t, wire.NewMsgPong())
+
+	// A frame with a bad checksum is returned with its error.
+	bad := encode(t, wire.NewMsgPong())
+	bad[20] ^= 0xff
+
+	// The capture starts part way through the version.
+	data := append(append(append(ver[30:], verack...), bad...), pong...)
+
+	c := newCapture(1)
+	c.tcp(client, server, 7000, 0, data[:50])
+	c.tcp(client, server, 7050, 0, data[50:])
+
+	r, err := pcap.NewReader(bytes.NewReader(c.Bytes()), &pcap.Config{})
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var frames []*pcap.Frame
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		frames = append(frames, f)
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames want 3", len(frames))
+	}
+	if frames[1].Err == nil || frames[1].Message != nil {
+		t.Errorf("expected an error for the bad frame, got %v", frames[1].Message)
+	}
+	checkFrames(t, frames[2:], []string{wire.CmdPong}, []*net.TCPAddr{client})
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newCapture(1)
+	c.tcp(client, server, 0, syn, nil)
+	c.tcp(client, server, 1, 0, encode(t, wire.NewMsgVerAck()))
+	name := filepath.Join(dir, "capture.pcap")
+	if err := ioutil.WriteFile(name, c.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := pcap.ReadFile(name, &pcap.Config{})
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	checkFrames(t, frames, []string{wire.CmdVerAck}, []*net.TCPAddr{client})
+}
+
+func TestReaderRawIPv6(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 18444}
+	verack := encode(t, wire.NewMsgVerAck())
+
+	// The capture is big endian with nanosecond timestamps.
+	var c bytes.Buffer
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint32(header[0:4], 0xa1b23c4d)
+	binary.BigEndian.PutUint32(header[20:24], 101)
+	c.Write(header)
+
+	segment := make([]byte, 20)
+	binary.BigEndian.PutUint16(segment[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(segment[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint32(segment[4:8], 1)
+	segment[12] = 5 << 4
+	segment = append(segment, verack...)
+
+	packet := make([]byte, 40)
+	packet[0] = 6 << 4
+	binary.BigEndian.PutUint16(packet[4:6], uint16(len(segment)))
+	packet[6] = 6
+	copy(packet[8:24], src.IP)
+	copy(packet[24:40], dst.IP)
+	packet = append(packet, segment...)
+
+	record := make([]byte, 16)
+	binary.BigEndian.PutUint32(record[0:4], uint32(epoch.Unix()))
+	binary.BigEndian.PutUint32(record[4:8], 5)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(packet)))
+	binary.BigEndian.PutUint32(record[12:16], uint32(len(packet)))
+	c.Write(record)
+	c.Write(packet)
+
+	frames, err := pcap.ReadAll(&c, &pcap.Config{Ports: []uint16{18444}})
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	checkFrames(t, frames, []string{wire.CmdVerAck}, []*net.TCPAddr{src})
+	if !frames[0].Time.Equal(epoch.Add(5)) {
+		t.Errorf("Time: got %v want %v", frames[0].Time, epoch.Add(5))
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	if _, err := pcap.NewReader(bytes.NewReader([]byte("not a capture")),
+		&pcap.Config{}); err != pcap.ErrUnknownFormat {
+		t.Errorf("NewReader: got %v want %v", err, pcap.ErrUnknownFormat)
+	}
+
+	c := newCapture(105)
+	if _, err := pcap.NewReader(bytes.NewReader(c.Bytes()),
+		&pcap.Config{}); !errors.Is(err, pcap.ErrUnsupportedLinkType) {
+		t.Errorf("NewReader: got %v want %v", err, pcap.ErrUnsupportedLinkType)
+	}
+
+	c = newCapture(1)
+	c.tcp(client, server, 1, 0, encode(t, wire.NewMsgVerAck()))
+	b := c.Bytes()
+	if _, err := pcap.ReadAll(bytes.NewReader(b[:len(b)-1]),
+		&pcap.Config{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadAll: got %v want %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/netparams"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// maxPending is the most data which is kept for a direction of a connection
+// while waiting for a segment which is missing from the capture. Beyond
+// that, the segment is given up on and the stream resumes at the next
+// message header.
+const maxPending = 4 * wire.MaxMessagePayload
+
+// Config is the configuration of a Reader. Zero values are replaced by the
+// defaults.
+type Config struct {
+	// Net is the network whose frames are read. The default is
+	// wire.MainNet.
+	Net wire.BitmessageNet
+
+	// Ports are the ports on which Bitmessage traffic is looked for. A
+	// segment is read if either of its ports is one of them. The default
+	// is the port of the main network.
+	Ports []uint16
+}
+
+// Frame is a message taken from a capture.
+type Frame struct {
+	// Time is when the packet which completed the frame was captured.
+	Time time.Time
+
+	// Src and Dst are the addresses of the sender and the receiver.
+	Src, Dst *net.TCPAddr
+
+	// Raw is the message header and payload as they were sent.
+	Raw []byte
+
+	// Message is the decoded message, or nil if it could not be decoded.
+	Message wire.Message
+
+	// Err is the error which wire.ReadMessage returned for the frame.
+	Err error
+}
+
+// stream is one direction of a connection which is being reassembled.
+type stream struct {
+	src, dst endpoint
+
+	// next is the sequence number of the next byte which is expected.
+	next uint32
+
+	// buf holds the data which has been received in order but not yet
+	// cut into frames.
+	buf []byte
+
+	// pending holds segments which arrived ahead of next, by sequence
+	// number, and pendingSize is the amount of data in them.
+	pending     map[uint32][]byte
+	pendingSize int
+}
+
+// Reader reads the Bitmessage frames from a capture.
+type Reader struct {
+	r       io.Reader
+	header  *fileHeader
+	net     wire.BitmessageNet
+	magic   [4]byte
+	ports   map[uint16]struct{}
+	streams map[[2]endpoint]*stream
+	frames  []*Frame
+}
+
+// NewReader returns a Reader for a capture. It reads the header of the
+// capture, and returns ErrUnknownFormat if it is not in the pcap format or
+// ErrUnsupportedLinkType if its packets cannot be read.
+func NewReader(r io.Reader, cfg *Config) (*Reader, error) {
+	header, err := readFileHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &Reader{
+		r:       r,
+		header:  header,
+		net:     cfg.Net,
+		ports:   make(map[uint16]struct{}),
+		streams: make(map[[2]endpoint]*stream),
+	}
+	if pr.net == 0 {
+		pr.net = wire.MainNet
+	}
+	binary.BigEndian.PutUint32(pr.magic[:], uint32(pr.net))
+
+	ports := cfg.Ports
+	if len(ports) == 0 {
+		ports = []uint16{netparams.MainNetParams.DefaultPort}
+	}
+	for _, port := range ports {
+		pr.ports[port] = struct{}{}
+	}
+	return pr, nil
+}
+
+// Next returns the next frame of the capture, in the order in which the
+// frames were completed. It returns io.EOF at the end of the capture. Data
+// at the end of a connection which does not make up a whole frame is
+// discarded.
+func (r *Reader) Next() (*Frame, error) {
+	for len(r.frames) == 0 {
+		t, packet, err := r.header.readPacket(r.r)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := r.header.decodePacket(packet); ok {
+			r.add(t, s)
+		}
+	}
+
+	f := r.frames[0]
+	r.frames[0] = nil
+	r.frames = r.frames[1:]
+	return f, nil
+}
+
+// ReadAll returns the frames of a capture.
+func ReadAll(r io.Reader, cfg *Config) ([]*Frame, error) {
+	pr, err := NewReader(r, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []*Frame
+	for {
+		f, err := pr.Next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, f)
+	}
+}
+
+// ReadFile returns the frames of the capture in the named file.
+func ReadFile(name string, cfg *Config) ([]*Frame, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadAll(file, cfg)
+}
+
+// add adds a segment captured at the given time to its stream.
+func (r *Reader) add(t time.Time, seg *segment) {
+	_, src := r.ports[seg.src.port]
+	_, dst := r.ports[seg.dst.port]
+	if !src && !dst {
+		return
+	}
+
+	key := [2]endpoint{seg.src, seg.dst}
+	s, ok := r.streams[key]
+	switch {
+	case seg.flags&flagSYN != 0:
+		s = &stream{src: seg.src, dst: seg.dst, next: seg.seq + 1}
+		r.streams[key] = s
+		s.add(seg.seq+1, seg.data)
+	case ok:
+		s.add(seg.seq, seg.data)
+	case len(seg.data) > 0:
+		// The capture started after the connection was made.
+		s = &stream{src: seg.src, dst: seg.dst, next: seg.seq}
+		r.streams[key] = s
+		s.add(seg.seq, seg.data)
+	default:
+		return
+	}
+
+	r.cut(t, s)
+	if seg.flags&(flagFIN|flagRST) != 0 {
+		delete(r.streams, key)
+	}
+}
+
+// add adds the data of a segment which starts at the given sequence number.
+func (s *stream) add(seq uint32, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	// Sequence numbers wrap around, so they are compared by difference.
+	if ahead := int32(seq - s.next); ahead > 0 {
+		if s.pending == nil {
+			s.pending = make(map[uint32][]byte)
+		}
+		if len(data) > len(s.pending[seq]) {
+			s.pendingSize += len(data) - len(s.pending[seq])
+			s.pending[seq] = append([]byte(nil), data...)
+		}
+		if s.pendingSize > maxPending {
+			s.skip()
+		}
+		return
+	}
+
+	s.append(seq, data)
+	s.drain()
+}
+
+// append appends data which starts at or before next to buf, leaving out
+// what has been received already.
+func (s *stream) append(seq uint32, data []byte) {
+	behind := int(s.next - seq)
+	if behind >= len(data) {
+		return
+	}
+	s.buf = append(s.buf, data[behind:]...)
+	s.next += uint32(len(data) - behind)
+}
+
+// drain appends the pending segments which are no longer ahead of next.
+func (s *stream) drain() {
+	for progress := true; progress; {
+		progress = false
+		for seq, data := range s.pending {
+			if int32(seq-s.next) > 0 {
+				continue
+			}
+			delete(s.pending, seq)
+			s.pendingSize -= len(data)
+			s.append(seq, data)
+			progress = true
+		}
+	}
+}
+
+// skip gives up on the data which is missing before the earliest pending
+// segment. The partial frame in buf is discarded with it.
+func (s *stream) skip() {
+	first := true
+	var earliest uint32
+	for seq := range s.pending {
+		if first || int32(seq-earliest) < 0 {
+			earliest, first = seq, false
+		}
+	}
+	s.buf = nil
+	s.next = earliest
+	s.drain()
+}
+
+// cut takes the complete frames from the start of the data of a stream.
+func (r *Reader) cut(t time.Time, s *stream) {
+	for len(s.buf) >= wire.MessageHeaderSize {
+		if !bytes.Equal(s.buf[:4], r.magic[:]) {
+			r.resync(s)
+			continue
+		}
+
+		// A length which is too large means that this is not really a
+		// message header, so the search goes on past it.
+		length := binary.BigEndian.Uint32(s.buf[16:20])
+		if length > wire.MaxMessagePayload {
+			s.buf = s.buf[1:]
+			continue
+		}
+		size := wire.MessageHeaderSize + int(length)
+		if len(s.buf) < size {
+			break
+		}
+
+		raw := append([]byte(nil), s.buf[:size]...)
+		s.buf = s.buf[size:]
+		msg, _, err := wire.ReadMessage(bytes.NewReader(raw), r.net)
+		r.frames = append(r.frames, &Frame{
+			Time:    t,
+			Src:     s.src.addr(),
+			Dst:     s.dst.addr(),
+			Raw:     raw,
+			Message: msg,
+			Err:     err,
+		})
+	}
+
+	if len(s.buf) == 0 {
+		s.buf = nil
+	}
+}
+
+// resync drops the data at the start of a stream up to the next place
+// where a message header could start.
+func (r *Reader) resync(s *stream) {
+	if i := bytes.Index(s.buf[1:], r.magic[:]); i >= 0 {
+		s.buf = s.buf[1+i:]
+		return
+	}
+
+	// The magic may be cut off at the end.
+	s.buf = append([]byte(nil), s.buf[len(s.buf)-len(r.magic)+1:]...)
+}
+
+// addr returns the endpoint as a TCP address.
+func (e endpoint) addr() *net.TCPAddr {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, e.ip[:])
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return &net.TCPAddr{IP: ip, Port: int(e.port)}
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package peer provides a Bitmessage peer which wraps a net.Conn and handles
+the version/verack handshake.
+
+Once the handshake has completed, messages from the remote peer are
+delivered on the channel returned by In and messages written to the
+channel returned by Out are sent to the remote peer. Any violation of the
+protocol on the part of the remote peer causes it to be disconnected. The
+reason is available from Err after the channel returned by Done is closed.
+
+The messages received and sent by each peer may be rate limited with the
+RecvLimits and SendLimits fields of Config, and the traffic of all peers
+together with GlobalRecvLimiter and GlobalSendLimiter. A peer which exceeds
+its receive limits is not read from until it is back within them.
+
+A peer may keep its connection alive by sending a pong whenever nothing has
+been sent for the PingInterval. It is disconnected if nothing is received
+from the remote peer within the IdleTimeout, or if an object requested with
+getdata does not arrive within the StallTimeout, so that connection slots
+are not held by peers which have stopped being useful.
+
+The order of the handshake is enforced by a Handshake, which moves from
+StateExpectingVersion through StateExpectingVerAck to StateEstablished and
+returns a *ProtocolError for any message that arrives out of turn. It does
+no I/O of its own, so higher layers may use it with other transports, and
+its deadline may be watched with Expired or OnTimeout.
+
+Optional features of the protocol, such as compression, are negotiated as
+Extensions. Each peer advertises those in its Config in its version
+message, with a service bit or a token in the user agent, and Extensions
+returns those which both sides support, so that a feature is only used with
+peers which understand it. Other transports may do the same with Advertise
+and Negotiate.
+
+Peers whose Config has a TLSCertificate encrypt the connection with TLS
+once the handshake is over, if the remote peer has one too. Each side
+advertises the fingerprint of its certificate with the TLS extension and
+requires the other to present the certificate that it advertised, so
+self-signed certificates such as those made by NewCertificate can be used.
+The messages themselves are unchanged.
+*/
+package peer
@@ -0,0 +1,61 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSelfConnection is returned when a peer turns out to be a
+	// connection to ourselves.
+	ErrSelfConnection = errors.New("disconnecting peer connected to self")
+
+	// ErrNoCommonStreams is returned when the remote peer is not
+	// interested in any of the streams we serve.
+	ErrNoCommonStreams = errors.New("no streams in common with remote peer")
+
+	// ErrDisconnected is returned when an operation is attempted on a peer
+	// that has already been disconnected.
+	ErrDisconnected = errors.New("peer disconnected")
+
+	// ErrHandshakeTimeout is returned when the handshake does not
+	// complete before its deadline.
+	ErrHandshakeTimeout = errors.New("handshake timed out")
+
+	// ErrIdleTimeout is returned when nothing has been received from the
+	// remote peer within the idle timeout.
+	ErrIdleTimeout = errors.New("peer idle for too long")
+
+	// ErrStalled is returned when the remote peer has not sent an object
+	// which we requested within the stall timeout.
+	ErrStalled = errors.New("peer stalled on requested objects")
+
+	// ErrTLSRequired is returned when the remote peer does not support
+	// TLS and Config.RequireTLS is set.
+	ErrTLSRequired = errors.New("peer does not support tls")
+
+	// ErrFingerprintMismatch is returned when the remote peer presents a
+	// certificate other than the one it advertised.
+	ErrFingerprintMismatch = errors.New("certificate does not match fingerprint")
+)
+
+// ProtocolError describes a violation of the Bitmessage protocol by the
+// remote peer, such as sending messages out of order or sending a version
+// message that we cannot accept.
+type ProtocolError struct {
+	Description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("protocol violation: %s", e.Description)
+}
+
+// newProtocolError creates a ProtocolError from a format string.
+func newProtocolError(format string, a ...interface{}) *ProtocolError {
+	return &ProtocolError{Description: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// extensionAgent is the name of the part of the user agent which lists the
+// extensions that are advertised by token, as in
+// /wire:0.1.0/ext:1(compression; extaddr)/.
+const extensionAgent = "ext"
+
+// ErrInvalidExtension is returned by Advertise for an extension whose name
+// cannot be put in a user agent.
+var ErrInvalidExtension = errors.New("invalid extension name")
+
+// Extension is an optional feature of the protocol which may only be used
+// with a remote peer which supports it too. Extensions are advertised in the
+// version message, either with a service bit or with a token in the user
+// agent, since nodes which know nothing of an extension ignore both.
+type Extension struct {
+	// Name identifies the extension. Unless Service is set, it is the
+	// token which advertises the extension in the user agent.
+	Name string
+
+	// Service, if not zero, is the service bit which advertises the
+	// extension.
+	Service wire.ServiceFlag
+}
+
+// String returns the name of the extension.
+func (e Extension) String() string {
+	return e.Name
+}
+
+// The extensions which this package knows of. Others may be defined by
+// applications, as long as their names and service bits do not clash.
+var (
+	// Compression means that the peer can read objects whose content is
+	// compressed.
+	Compression = Extension{Name: "compression"}
+
+	// ExtendedAddr means that the peer can read addr messages with
+	// addresses other than IPv4 and IPv6, such as onion addresses.
+	ExtendedAddr = Extension{Name: "extaddr"}
+
+	// ContactCards means that the peer's client can read contact cards,
+	// which are in format.ContactEncoding.
+	ContactCards = Extension{Name: "contact"}
+)
+
+// Extensions is a set of extensions.
+type Extensions []Extension
+
+// Has returns whether e is in the set.
+func (s Extensions) Has(e Extension) bool {
+	for _, x := range s {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the names of the extensions in the set.
+func (s Extensions) String() string {
+	names := make([]string, len(s))
+	for i, e := range s {
+		names[i] = e.Name
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// validExtensionName returns whether name can be put in a user agent.
+func validExtensionName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/:;() \t\r\n")
+}
+
+// Advertise adds the extensions to a version message. Those with a service
+// bit set it, and the rest are listed in the user agent, so Advertise must
+// be called after any other part has been added to the user agent.
+func Advertise(msg *wire.MsgVersion, extensions []Extension) error {
+	var tokens []string
+	for _, e := range extensions {
+		if e.Service != 0 {
+			msg.AddService(e.Service)
+			continue
+		}
+		if !validExtensionName(e.Name) {
+			return ErrInvalidExtension
+		}
+		tokens = append(tokens, e.Name)
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return msg.AddUserAgent(extensionAgent, "1", tokens...)
+}
+
+// userAgentComments returns the comments of the parts of a user agent with
+// the given name, such as compression and extaddr in ext:1(compression;
+// extaddr).
+func userAgentComments(userAgent, name string) []string {
+	var comments []string
+	for _, part := range strings.Split(userAgent, "/") {
+		if !strings.HasPrefix(part, name+":") {
+			continue
+		}
+		open := strings.IndexByte(part, '(')
+		if open < 0 || !strings.HasSuffix(part, ")") {
+			continue
+		}
+		for _, c := range strings.Split(part[open+1:len(part)-1], ";") {
+			comments = append(comments, strings.TrimSpace(c))
+		}
+	}
+	return comments
+}
+
+// advertisedTokens returns the extension tokens in a user agent.
+func advertisedTokens(userAgent string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range userAgentComments(userAgent, extensionAgent) {
+		tokens[token] = true
+	}
+	return tokens
+}
+
+// Negotiate returns those of our extensions which the remote peer
+// advertised in its version message, in the order of ours.
+func Negotiate(ours []Extension, msg *wire.MsgVersion) Extensions {
+	tokens := advertisedTokens(msg.UserAgent)
+	var common Extensions
+	for _, e := range ours {
+		if e.Service != 0 && msg.HasService(e.Service) ||
+			e.Service == 0 && tokens[e.Name] {
+			common = append(common, e)
+		}
+	}
+	return common
+}
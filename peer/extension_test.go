@@ -0,0 +1,87 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+var fastSync = peer.Extension{Name: "fastsync", Service: 1 << 7}
+
+func TestNegotiate(t *testing.T) {
+	msg := newVersion(1, []uint32{1})
+	msg.AddUserAgent("other", "2.0", "linux")
+	err := peer.Advertise(msg, []peer.Extension{peer.Compression,
+		peer.ContactCards, fastSync})
+	if err != nil {
+		t.Fatalf("Advertise: %v", err)
+	}
+	wantUA := wire.DefaultUserAgent + "other:2.0(linux)/ext:1(compression; contact)/"
+	if msg.UserAgent != wantUA || !msg.HasService(fastSync.Service) {
+		t.Errorf("Advertise: got %s, %s", msg.UserAgent, msg.Services)
+	}
+
+	ours := []peer.Extension{fastSync, peer.ExtendedAddr, peer.Compression,
+		{Name: "linux"}}
+	got := peer.Negotiate(ours, msg)
+	want := peer.Extensions{fastSync, peer.Compression}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Negotiate: got %v want %v", got, want)
+	}
+	if !got.Has(peer.Compression) || got.Has(peer.ContactCards) {
+		t.Errorf("Has: wrong result for %v", got)
+	}
+
+	// A service bit is not taken as a token.
+	if got := peer.Negotiate([]peer.Extension{{Name: "fastsync"}}, msg); got != nil {
+		t.Errorf("Negotiate: got %v want none", got)
+	}
+
+	for _, name := range []string{"", "a b", "a;b", "a/b", "a(b)"} {
+		err := peer.Advertise(newVersion(1, []uint32{1}),
+			[]peer.Extension{{Name: name}})
+		if err != peer.ErrInvalidExtension {
+			t.Errorf("Advertise(%q): got %v want %v", name, err,
+				peer.ErrInvalidExtension)
+		}
+	}
+}
+
+// TestExtensions tests that peers agree on the extensions which both of them
+// support during the handshake.
+func TestExtensions(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	cfgOut := &peer.Config{
+		Net:        wire.MainNet,
+		Streams:    []uint32{1},
+		Extensions: []peer.Extension{peer.Compression, peer.ContactCards, fastSync},
+	}
+	cfgIn := &peer.Config{
+		Net:        wire.MainNet,
+		Streams:    []uint32{1},
+		Extensions: []peer.Extension{fastSync, peer.ExtendedAddr, peer.Compression},
+	}
+
+	out, in := connect(cfgOut, cfgIn)
+	if out.err != nil || in.err != nil {
+		t.Fatalf("connect: %v, %v", out.err, in.err)
+	}
+	defer out.p.Disconnect(nil)
+	defer in.p.Disconnect(nil)
+
+	want := peer.Extensions{peer.Compression, fastSync}
+	if got := out.p.Extensions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("outbound: got %v want %v", got, want)
+	}
+	want = peer.Extensions{fastSync, peer.Compression}
+	if got := in.p.Extensions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("inbound: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// HandshakeState is the stage that a version/verack exchange has reached.
+type HandshakeState int
+
+const (
+	// StateExpectingVersion means that the remote peer has not yet sent
+	// its version message.
+	StateExpectingVersion HandshakeState = iota
+
+	// StateExpectingVerAck means that the remote peer has sent its
+	// version message but has not yet acknowledged ours.
+	StateExpectingVerAck
+
+	// StateEstablished means that the handshake has completed.
+	StateEstablished
+)
+
+// Map of handshake states back to their constant names for pretty printing.
+var handshakeStateStrings = map[HandshakeState]string{
+	StateExpectingVersion: "StateExpectingVersion",
+	StateExpectingVerAck:  "StateExpectingVerAck",
+	StateEstablished:      "StateEstablished",
+}
+
+// String returns the HandshakeState in human-readable form.
+func (s HandshakeState) String() string {
+	if str, ok := handshakeStateStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown HandshakeState (%d)", int(s))
+}
+
+// Handshake tracks the version/verack exchange with a remote peer and
+// checks that the messages it receives arrive in an order the protocol
+// allows. It does no I/O, so it can be driven by any transport.
+type Handshake struct {
+	inbound  bool
+	deadline time.Time
+	now      func() time.Time
+
+	mtx         sync.Mutex
+	sentVersion bool
+	gotVersion  bool
+	gotVerAck   bool
+	timer       *time.Timer
+}
+
+// NewHandshake returns a Handshake which must complete within timeout. If
+// timeout is zero, DefaultHandshakeTimeout is used. An inbound handshake
+// waits for the remote peer's version before we send ours.
+func NewHandshake(inbound bool, timeout time.Duration) *Handshake {
+	if timeout == 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	return &Handshake{
+		inbound:  inbound,
+		deadline: time.Now().Add(timeout),
+		now:      time.Now,
+	}
+}
+
+// Inbound returns whether the remote peer initiated the connection.
+func (h *Handshake) Inbound() bool {
+	return h.inbound
+}
+
+// State returns the stage that the handshake has reached.
+func (h *Handshake) State() HandshakeState {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.state()
+}
+
+func (h *Handshake) state() HandshakeState {
+	switch {
+	case h.gotVersion && h.gotVerAck:
+		return StateEstablished
+	case h.gotVersion:
+		return StateExpectingVerAck
+	default:
+		return StateExpectingVersion
+	}
+}
+
+// Established returns whether the handshake has completed.
+func (h *Handshake) Established() bool {
+	return h.State() == StateEstablished
+}
+
+// ShouldSendVersion returns whether it is our turn to send a version
+// message which has not been sent yet. An outbound peer sends its version
+// first, and an inbound peer replies to the remote peer's version.
+func (h *Handshake) ShouldSendVersion() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return !h.sentVersion && (!h.inbound || h.gotVersion)
+}
+
+// VersionSent records that our version message has been sent.
+func (h *Handshake) VersionSent() {
+	h.mtx.Lock()
+	h.sentVersion = true
+	h.mtx.Unlock()
+}
+
+// Deadline returns the time by which the handshake must complete.
+func (h *Handshake) Deadline() time.Time {
+	return h.deadline
+}
+
+// Expired returns whether the deadline has passed without the handshake
+// completing.
+func (h *Handshake) Expired() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.expired()
+}
+
+func (h *Handshake) expired() bool {
+	return h.state() != StateEstablished && !h.now().Before(h.deadline)
+}
+
+// OnTimeout arranges for f to be called in its own goroutine if the
+// handshake has not completed by the deadline. Only the most recent
+// function is kept.
+func (h *Handshake) OnTimeout(f func()) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if h.state() == StateEstablished {
+		return
+	}
+	h.timer = time.AfterFunc(h.deadline.Sub(h.now()), func() {
+		if !h.Established() {
+			f()
+		}
+	})
+}
+
+// Receive checks that msg is allowed in the current state and advances the
+// handshake. It returns ErrHandshakeTimeout if the deadline has passed and
+// a *ProtocolError if msg arrives out of order. Once the handshake is
+// established, any message other than version or verack is accepted.
+func (h *Handshake) Receive(msg wire.Message) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.state() == StateEstablished {
+		switch msg.(type) {
+		case *wire.MsgVersion, *wire.MsgVerAck:
+			return newProtocolError("received %s message after "+
+				"handshake completed", msg.Command())
+		}
+		return nil
+	}
+
+	if h.expired() {
+		return ErrHandshakeTimeout
+	}
+
+	switch msg.(type) {
+	case *wire.MsgVersion:
+		if h.gotVersion {
+			return newProtocolError("duplicate version message")
+		}
+		h.gotVersion = true
+	case *wire.MsgVerAck:
+		if !h.sentVersion || h.gotVerAck {
+			return newProtocolError("unexpected verack message")
+		}
+		h.gotVerAck = true
+	default:
+		return newProtocolError("received %s message before handshake "+
+			"completed", msg.Command())
+	}
+
+	if h.state() == StateEstablished && h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestHandshakeTransitions tests the states that a Handshake passes
+// through and the messages that it rejects in each of them.
+func TestHandshakeTransitions(t *testing.T) {
+	version := newVersion(1, []uint32{1})
+
+	tests := []struct {
+		inbound bool
+		sent    bool
+		msgs    []wire.Message
+		state   peer.HandshakeState
+		fail    bool // whether the last message is rejected
+	}{
+		// Outbound: version then verack.
+		{false, true, []wire.Message{version, &wire.MsgVerAck{}},
+			peer.StateEstablished, false},
+		// Outbound: verack then version.
+		{false, true, []wire.Message{&wire.MsgVerAck{}, version},
+			peer.StateEstablished, false},
+		// Version received, waiting for verack.
+		{true, true, []wire.Message{version},
+			peer.StateExpectingVerAck, false},
+		// Inbound: verack before we sent our version.
+		{true, false, []wire.Message{&wire.MsgVerAck{}},
+			peer.StateExpectingVersion, true},
+		// Duplicate version.
+		{true, true, []wire.Message{version, version},
+			peer.StateExpectingVerAck, true},
+		// Duplicate verack.
+		{false, true, []wire.Message{&wire.MsgVerAck{}, &wire.MsgVerAck{}},
+			peer.StateExpectingVersion, true},
+		// Other message before the handshake.
+		{true, false, []wire.Message{&wire.MsgPong{}},
+			peer.StateExpectingVersion, true},
+		// Other message after the handshake.
+		{false, true, []wire.Message{version, &wire.MsgVerAck{}, &wire.MsgPong{}},
+			peer.StateEstablished, false},
+		// Version after the handshake.
+		{false, true, []wire.Message{version, &wire.MsgVerAck{}, version},
+			peer.StateEstablished, true},
+	}
+
+	for i, test := range tests {
+		h := peer.NewHandshake(test.inbound, 0)
+		if h.State() != peer.StateExpectingVersion {
+			t.Errorf("test #%d: initial state %v", i, h.State())
+		}
+		if test.sent {
+			h.VersionSent()
+		}
+
+		var err error
+		for j, msg := range test.msgs {
+			err = h.Receive(msg)
+			if err != nil && j != len(test.msgs)-1 {
+				t.Errorf("test #%d: message %d rejected: %v", i, j, err)
+			}
+		}
+
+		if test.fail {
+			if _, ok := err.(*peer.ProtocolError); !ok {
+				t.Errorf("test #%d: got %v want *peer.ProtocolError", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("test #%d: unexpected error %v", i, err)
+		}
+		if h.State() != test.state {
+			t.Errorf("test #%d: got state %v want %v", i, h.State(), test.state)
+		}
+		if h.Established() != (test.state == peer.StateEstablished) {
+			t.Errorf("test #%d: Established disagrees with state %v", i,
+				test.state)
+		}
+	}
+}
+
+// TestHandshakeShouldSendVersion tests that an outbound peer sends its
+// version first and an inbound peer waits for the remote version.
+func TestHandshakeShouldSendVersion(t *testing.T) {
+	out := peer.NewHandshake(false, 0)
+	if !out.ShouldSendVersion() {
+		t.Error("outbound handshake should send version first")
+	}
+	out.VersionSent()
+	if out.ShouldSendVersion() {
+		t.Error("version sent twice")
+	}
+
+	in := peer.NewHandshake(true, 0)
+	if in.ShouldSendVersion() {
+		t.Error("inbound handshake should wait for the remote version")
+	}
+	if err := in.Receive(newVersion(1, []uint32{1})); err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if !in.ShouldSendVersion() {
+		t.Error("inbound handshake should reply to the remote version")
+	}
+}
+
+// TestHandshakeTimeout tests that a Handshake rejects messages after its
+// deadline and calls the timeout hook.
+func TestHandshakeTimeout(t *testing.T) {
+	h := peer.NewHandshake(true, time.Minute)
+	if h.Expired() {
+		t.Error("handshake expired immediately")
+	}
+
+	peer.TstSetHandshakeNow(h, func() time.Time {
+		return time.Now().Add(2 * time.Minute)
+	})
+	if !h.Expired() {
+		t.Error("handshake not expired after its deadline")
+	}
+	if err := h.Receive(newVersion(1, []uint32{1})); err != peer.ErrHandshakeTimeout {
+		t.Errorf("got %v want %v", err, peer.ErrHandshakeTimeout)
+	}
+
+	fired := make(chan struct{})
+	h = peer.NewHandshake(true, time.Millisecond)
+	h.OnTimeout(func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Error("timeout hook not called")
+	}
+
+	// The hook is not called once the handshake is established.
+	h = peer.NewHandshake(false, 50*time.Millisecond)
+	h.OnTimeout(func() { t.Error("timeout hook called after handshake") })
+	h.VersionSent()
+	h.Receive(newVersion(1, []uint32{1}))
+	h.Receive(&wire.MsgVerAck{})
+	time.Sleep(100 * time.Millisecond)
+}
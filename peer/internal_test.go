@@ -0,0 +1,29 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the peer package rather than than the peer_test
+package so it can bridge access to the internals to properly test cases which
+are either not possible or can't reliably be tested via the public interface.
+The functions are only exported while the tests are being run.
+*/
+
+package peer
+
+import "time"
+
+// TstAllowSelfConns sets whether peers in the same process may connect to
+// one another and returns the previous value. Without it, every connection
+// made within the test process looks like a connection to ourselves.
+func TstAllowSelfConns(allow bool) bool {
+	prev := allowSelfConns
+	allowSelfConns = allow
+	return prev
+}
+
+// TstSetHandshakeNow sets the function from which a Handshake reads the
+// time.
+func TstSetHandshakeNow(h *Handshake, now func() time.Time) {
+	h.now = now
+}
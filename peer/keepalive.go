@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// keepAliveChecks is the number of times in each PingInterval or
+// StallTimeout that keepAlive checks the peer, which bounds how late a
+// keepalive or a stall may be noticed.
+const keepAliveChecks = 4
+
+// sent records a message which is being written to the remote peer.
+func (p *Peer) sent(msg wire.Message) {
+	now := time.Now()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.lastSend = now
+	if getData, ok := msg.(*wire.MsgGetData); ok && p.cfg.StallTimeout > 0 {
+		for _, iv := range getData.InvList {
+			if _, ok := p.requested[*iv]; !ok {
+				p.requested[*iv] = now
+			}
+		}
+	}
+}
+
+// received records an object received from the remote peer, given the
+// payload of the object message.
+func (p *Peer) received(payload []byte) {
+	iv := wire.InvVect(*hash.InventoryHash(payload))
+
+	p.mtx.Lock()
+	delete(p.requested, iv)
+	p.mtx.Unlock()
+}
+
+// keepAlive sends a pong when nothing has been sent for the PingInterval
+// and disconnects the peer if it stalls on a requested object, until the
+// peer is disconnected.
+func (p *Peer) keepAlive() {
+	interval := p.cfg.PingInterval
+	if interval == 0 || p.cfg.StallTimeout > 0 && p.cfg.StallTimeout < interval {
+		interval = p.cfg.StallTimeout
+	}
+	ticker := time.NewTicker(interval / keepAliveChecks)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			idle, stalled := p.check(now)
+			if stalled {
+				p.Disconnect(ErrStalled)
+				return
+			}
+			if !idle {
+				continue
+			}
+
+			// There is no need for a keepalive if other messages
+			// are waiting to be sent.
+			select {
+			case p.out <- wire.NewMsgPong():
+			default:
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// check returns whether a keepalive is due and whether the remote peer has
+// stalled on an object that we requested. A keepalive which is due is
+// counted as sent, so that no more are queued while it waits.
+func (p *Peer) check(now time.Time) (idle, stalled bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	idle = p.cfg.PingInterval > 0 && now.Sub(p.lastSend) >= p.cfg.PingInterval
+	if idle {
+		p.lastSend = now
+	}
+	if p.cfg.StallTimeout > 0 {
+		for _, t := range p.requested {
+			if now.Sub(t) >= p.cfg.StallTimeout {
+				return idle, true
+			}
+		}
+	}
+	return idle, false
+}
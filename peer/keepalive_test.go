@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// connectWith connects two peers with the given configurations and fails
+// the test if the handshake fails.
+func connectWith(t *testing.T, cfgOut, cfgIn *peer.Config) (out, in *peer.Peer) {
+	o, i := connect(cfgOut, cfgIn)
+	if o.err != nil {
+		t.Fatalf("NewOutbound: %v", o.err)
+	}
+	if i.err != nil {
+		t.Fatalf("NewInbound: %v", i.err)
+	}
+	return o.p, i.p
+}
+
+// waitErr waits for a peer to be disconnected and checks the reason.
+func waitErr(t *testing.T, p *peer.Peer, want error) {
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("peer was not disconnected")
+	}
+	if p.Err() != want {
+		t.Errorf("Err: got %v want %v", p.Err(), want)
+	}
+}
+
+// TestKeepAlive tests that an idle peer sends pongs and that a peer which
+// hears nothing is disconnected.
+func TestKeepAlive(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	out, in := connectWith(t,
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+			PingInterval: 20 * time.Millisecond},
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+			IdleTimeout: 200 * time.Millisecond})
+	defer out.Disconnect(nil)
+	defer in.Disconnect(nil)
+
+	// The keepalives keep the inbound peer connected for longer than its
+	// idle timeout.
+	deadline := time.After(400 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case msg := <-in.In():
+			if _, ok := msg.(*wire.MsgPong); !ok {
+				t.Fatalf("In: got %T want *wire.MsgPong", msg)
+			}
+		case <-in.Done():
+			t.Fatalf("peer disconnected: %v", in.Err())
+		case <-deadline:
+			done = true
+		}
+	}
+
+	// Without keepalives, the inbound peer gives up.
+	out, in = connectWith(t,
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+			IdleTimeout: 50 * time.Millisecond})
+	defer out.Disconnect(nil)
+	waitErr(t, in, peer.ErrIdleTimeout)
+}
+
+// TestStall tests that a peer is disconnected if it does not send the
+// objects requested from it.
+func TestStall(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	obj := wire.NewMsgObject(wire.NewObjectHeader(123,
+		time.Now().Add(time.Hour), wire.ObjectTypeMsg, 1, 1), []byte{1, 2, 3})
+	iv := wire.InvVect(*hash.InventoryHash(wire.Encode(obj)))
+	getData := &wire.MsgGetData{InvList: []*wire.InvVect{&iv}}
+
+	cfgOut := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		StallTimeout: 100 * time.Millisecond}
+	cfgIn := &peer.Config{Net: wire.MainNet, Streams: []uint32{1}}
+
+	// The object is sent in time.
+	out, in := connectWith(t, cfgOut, cfgIn)
+	if err := out.QueueMessage(getData); err != nil {
+		t.Fatalf("QueueMessage: %v", err)
+	}
+	<-in.In()
+	if err := in.QueueMessage(obj); err != nil {
+		t.Fatalf("QueueMessage: %v", err)
+	}
+	<-out.In()
+	select {
+	case <-out.Done():
+		t.Fatalf("peer disconnected: %v", out.Err())
+	case <-time.After(300 * time.Millisecond):
+	}
+	out.Disconnect(nil)
+	in.Disconnect(nil)
+
+	// The object never comes.
+	out, in = connectWith(t, cfgOut, cfgIn)
+	defer in.Disconnect(nil)
+	if err := out.QueueMessage(getData); err != nil {
+		t.Fatalf("QueueMessage: %v", err)
+	}
+	<-in.In()
+	waitErr(t, out, peer.ErrStalled)
+}
@@ -0,0 +1,619 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/metrics"
+	"github.com/DanielKrawisz/bmutil/ratelimit"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// MinProtocolVersion is the lowest protocol version that a remote peer
+	// may advertise.
+	MinProtocolVersion = wire.ProtocolVersion
+
+	// DefaultHandshakeTimeout is the time allowed for the version/verack
+	// exchange if none is given in the Config.
+	DefaultHandshakeTimeout = 30 * time.Second
+
+	// MaxTimeOffset is the largest difference between the remote peer's
+	// clock and ours that we are willing to accept. Objects are stamped
+	// with expiration times, so peers whose clocks are far off would
+	// reject or relay the wrong objects.
+	MaxTimeOffset = time.Hour
+
+	// outputBufferSize is the number of messages that may be queued for
+	// sending before writes to the Out channel block.
+	outputBufferSize = 50
+)
+
+// allowSelfConns is only used in tests to allow a peer to connect to
+// another peer in the same process.
+var allowSelfConns bool
+
+// NonceSet holds the nonces of version messages that the peers of a node
+// have sent and not yet seen the handshake complete for, so that
+// connections from the node to itself can be detected.
+type NonceSet struct {
+	mtx sync.Mutex
+	m   map[uint64]struct{}
+}
+
+// NewNonceSet returns an empty NonceSet.
+func NewNonceSet() *NonceSet {
+	return &NonceSet{m: make(map[uint64]struct{})}
+}
+
+func (s *NonceSet) add(nonce uint64) {
+	s.mtx.Lock()
+	s.m[nonce] = struct{}{}
+	s.mtx.Unlock()
+}
+
+func (s *NonceSet) remove(nonce uint64) {
+	s.mtx.Lock()
+	delete(s.m, nonce)
+	s.mtx.Unlock()
+}
+
+func (s *NonceSet) contains(nonce uint64) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	_, ok := s.m[nonce]
+	return ok
+}
+
+// sentNonces is the NonceSet used by peers whose Config does not give
+// one. It is shared by the whole process.
+var sentNonces = NewNonceSet()
+
+// Config is the configuration shared by peers.
+type Config struct {
+	// Net is the Bitmessage network to which the peer belongs.
+	Net wire.BitmessageNet
+
+	// Streams is the list of streams that we serve.
+	Streams []uint32
+
+	// Services are the services that we advertise.
+	Services wire.ServiceFlag
+
+	// Extensions are the optional features of the protocol which we
+	// support. They are advertised in the version message, and those
+	// which the remote peer also advertises are returned by
+	// Peer.Extensions.
+	Extensions []Extension
+
+	// TLSCertificate, if not nil, is used to encrypt the connection with
+	// TLS after the handshake with peers which have a certificate too.
+	// Its fingerprint is advertised in the version message along with
+	// the TLS extension, and each side requires the other to present the
+	// certificate that it advertised. This protects against
+	// eavesdropping but not against an attacker who can alter the
+	// version messages, unless Peer.Fingerprint is checked against a
+	// fingerprint which is already known. NewCertificate generates a
+	// suitable certificate.
+	TLSCertificate *tls.Certificate
+
+	// RequireTLS causes peers which do not support TLS to be
+	// disconnected with ErrTLSRequired. TLSCertificate must be set too.
+	RequireTLS bool
+
+	// UserAgentName and UserAgentVersion are appended to the default user
+	// agent in the version message if UserAgentName is not empty.
+	UserAgentName    string
+	UserAgentVersion string
+
+	// HandshakeTimeout is the time allowed for the handshake. If zero,
+	// DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+
+	// PingInterval, if not zero, is the time after which a pong message
+	// is sent to keep the connection alive if nothing else has been
+	// sent. Bitmessage has no ping, so the pong goes unanswered.
+	PingInterval time.Duration
+
+	// IdleTimeout, if not zero, is the time after which the peer is
+	// disconnected with ErrIdleTimeout if nothing has been received from
+	// it. It should be longer than the keepalive interval of the remote
+	// node.
+	IdleTimeout time.Duration
+
+	// StallTimeout, if not zero, is the time within which the remote peer
+	// must send each object we request from it with getdata. A peer which
+	// does not is disconnected with ErrStalled.
+	StallTimeout time.Duration
+
+	// RecvLimits and SendLimits are the rate limits applied to each peer
+	// for the messages that it receives and sends. Nil means unlimited.
+	RecvLimits ratelimit.Limits
+	SendLimits ratelimit.Limits
+
+	// GlobalRecvLimiter and GlobalSendLimiter, if not nil, are shared by
+	// all peers using this configuration, so that they limit the total
+	// traffic of every peer together.
+	GlobalRecvLimiter *ratelimit.Limiter
+	GlobalSendLimiter *ratelimit.Limiter
+
+	// InvDuplicates is what is done with inventory vectors which appear
+	// more than once in the inv and getdata messages received from a
+	// peer. A peer whose message is rejected is disconnected.
+	InvDuplicates wire.InvDuplicates
+
+	// Nonces is shared by the peers of a node to detect connections from
+	// the node to itself. If nil, a set shared by the whole process is
+	// used, so several nodes in the same process, as in a simulation,
+	// must each have their own.
+	Nonces *NonceSet
+
+	// Logger, if not nil, is told when peers connect and disconnect.
+	Logger logging.Logger
+}
+
+// newLimiter returns a limiter for a single peer, or nil if there are no
+// limits.
+func newLimiter(limits ratelimit.Limits, global *ratelimit.Limiter) *ratelimit.Limiter {
+	if limits == nil && global == nil {
+		return nil
+	}
+	return ratelimit.NewLimiter(limits, global)
+}
+
+// Peer is a connection to a remote Bitmessage node which has completed the
+// version handshake.
+type Peer struct {
+	conn    net.Conn
+	cfg     *Config
+	inbound bool
+	log     logging.Logger
+
+	nonce       uint64
+	handshake   *Handshake
+	version     *wire.MsgVersion
+	streams     []uint32
+	extensions  Extensions
+	fingerprint string
+
+	in  chan wire.Message
+	out chan wire.Message
+
+	// pause is used to stop the output handler while the connection is
+	// replaced by startTLS.
+	pause chan chan struct{}
+
+	recvLimiter *ratelimit.Limiter
+	sendLimiter *ratelimit.Limiter
+
+	quit       chan struct{}
+	disconnect sync.Once
+	mtx        sync.Mutex
+	err        error
+
+	// lastSend and requested are used for keepalive and stall detection
+	// and are protected by mtx.
+	lastSend  time.Time
+	requested map[wire.InvVect]time.Time
+}
+
+// NewOutbound performs the handshake over a connection which we have
+// initiated and returns the resulting Peer. The connection is closed if
+// the handshake fails.
+func NewOutbound(cfg *Config, conn net.Conn) (*Peer, error) {
+	return newPeer(cfg, conn, false)
+}
+
+// NewInbound performs the handshake over a connection which the remote
+// node has initiated and returns the resulting Peer. The connection is
+// closed if the handshake fails.
+func NewInbound(cfg *Config, conn net.Conn) (*Peer, error) {
+	return newPeer(cfg, conn, true)
+}
+
+func newPeer(cfg *Config, conn net.Conn, inbound bool) (*Peer, error) {
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p := &Peer{
+		conn:    conn,
+		cfg:     cfg,
+		inbound: inbound,
+		nonce:   nonce,
+		in:      make(chan wire.Message),
+		out:     make(chan wire.Message, outputBufferSize),
+		pause:   make(chan chan struct{}),
+		quit:    make(chan struct{}),
+
+		recvLimiter: newLimiter(cfg.RecvLimits, cfg.GlobalRecvLimiter),
+		sendLimiter: newLimiter(cfg.SendLimits, cfg.GlobalSendLimiter),
+
+		lastSend:  time.Now(),
+		requested: make(map[wire.InvVect]time.Time),
+	}
+
+	p.handshake = NewHandshake(inbound, cfg.HandshakeTimeout)
+	p.log = logging.With(cfg.Logger, "addr", conn.RemoteAddr(),
+		"inbound", inbound)
+
+	go p.outHandler()
+
+	if err := p.doHandshake(); err != nil {
+		// If the output handler failed first, the error from the
+		// handshake only reports the closed connection.
+		p.Disconnect(err)
+		p.log.Log(logging.Debug, "handshake failed", "err", p.Err())
+		return nil, p.Err()
+	}
+	if p.extensions.Has(TLS) {
+		if err := p.startTLS(); err != nil {
+			p.Disconnect(err)
+			p.log.Log(logging.Debug, "tls handshake failed", "err", p.Err())
+			return nil, p.Err()
+		}
+	}
+	p.log.Log(logging.Info, "peer connected", "user_agent", p.UserAgent(),
+		"streams", p.streams, "extensions", p.extensions)
+
+	go p.inHandler()
+	if cfg.PingInterval > 0 || cfg.StallTimeout > 0 {
+		go p.keepAlive()
+	}
+	return p, nil
+}
+
+// doHandshake does the version/verack exchange, relying on the Handshake
+// to reject messages which arrive out of order. Writes go through the
+// output queue so that the handshake cannot deadlock if both sides try to
+// write at the same time.
+func (p *Peer) doHandshake() error {
+	p.conn.SetReadDeadline(p.handshake.Deadline())
+	defer p.conn.SetReadDeadline(time.Time{})
+
+	nonces := p.nonces()
+	nonces.add(p.nonce)
+	defer nonces.remove(p.nonce)
+
+	if err := p.sendVersion(); err != nil {
+		return err
+	}
+
+	for !p.handshake.Established() {
+		msg, _, err := wire.ReadMessage(p.conn, p.cfg.Net)
+		if err != nil {
+			if p.handshake.Expired() {
+				return ErrHandshakeTimeout
+			}
+			return err
+		}
+		metrics.MessagesReceived.With(msg.Command()).Inc()
+
+		if err = p.handshake.Receive(msg); err != nil {
+			return err
+		}
+
+		if m, ok := msg.(*wire.MsgVersion); ok {
+			if err = p.handleVersion(m); err != nil {
+				return err
+			}
+			if err = p.sendVersion(); err != nil {
+				return err
+			}
+			if err = p.queue(&wire.MsgVerAck{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendVersion queues our version message if the handshake calls for it.
+func (p *Peer) sendVersion() error {
+	if !p.handshake.ShouldSendVersion() {
+		return nil
+	}
+	p.handshake.VersionSent()
+
+	var stream uint32
+	if len(p.cfg.Streams) > 0 {
+		stream = p.cfg.Streams[0]
+	}
+
+	msg := wire.NewMsgVersion(
+		netAddress(p.conn.LocalAddr(), stream, p.cfg.Services),
+		netAddress(p.conn.RemoteAddr(), stream, 0),
+		p.nonce, p.cfg.Streams)
+	msg.Services = p.cfg.Services
+	if p.cfg.UserAgentName != "" {
+		err := msg.AddUserAgent(p.cfg.UserAgentName, p.cfg.UserAgentVersion)
+		if err != nil {
+			return err
+		}
+	}
+	if err := Advertise(msg, p.cfg.extensions()); err != nil {
+		return err
+	}
+	if err := p.advertiseTLS(msg); err != nil {
+		return err
+	}
+
+	return p.queue(msg)
+}
+
+// nonces returns the NonceSet of the node to which the peer belongs.
+func (p *Peer) nonces() *NonceSet {
+	if p.cfg.Nonces != nil {
+		return p.cfg.Nonces
+	}
+	return sentNonces
+}
+
+// handleVersion checks whether the remote peer's version message is
+// acceptable and negotiates the streams that we will share.
+func (p *Peer) handleVersion(msg *wire.MsgVersion) error {
+	if !allowSelfConns && p.nonces().contains(msg.Nonce) {
+		return ErrSelfConnection
+	}
+
+	if msg.ProtocolVersion < int32(MinProtocolVersion) {
+		return newProtocolError("protocol version %d is too old",
+			msg.ProtocolVersion)
+	}
+
+	offset := time.Now().Sub(msg.Timestamp)
+	if offset > MaxTimeOffset || offset < -MaxTimeOffset {
+		return newProtocolError("time offset of %s is too large", offset)
+	}
+
+	for _, s := range msg.StreamNumbers {
+		for _, t := range p.cfg.Streams {
+			if s == t {
+				p.streams = append(p.streams, s)
+				break
+			}
+		}
+	}
+	if len(p.streams) == 0 {
+		return ErrNoCommonStreams
+	}
+
+	p.extensions = Negotiate(p.cfg.extensions(), msg)
+	if err := p.handleTLS(msg); err != nil {
+		return err
+	}
+	p.version = msg
+	return nil
+}
+
+// queue adds a message to the output queue unless the peer has been
+// disconnected.
+func (p *Peer) queue(msg wire.Message) error {
+	select {
+	case p.out <- msg:
+		return nil
+	case <-p.quit:
+		return ErrDisconnected
+	}
+}
+
+// inHandler reads messages from the connection and delivers them on the
+// In channel until the peer is disconnected.
+func (p *Peer) inHandler() {
+	defer close(p.in)
+	for {
+		if p.cfg.IdleTimeout > 0 {
+			p.conn.SetReadDeadline(time.Now().Add(p.cfg.IdleTimeout))
+		}
+		n, msg, payload, err := wire.ReadMessageN(p.conn, p.cfg.Net)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				err = ErrIdleTimeout
+			}
+			p.Disconnect(err)
+			return
+		}
+		metrics.MessagesReceived.With(msg.Command()).Inc()
+
+		// Stop reading until the peer is within its limits, which
+		// pushes back on the remote node through TCP flow control.
+		if p.recvLimiter != nil &&
+			!p.recvLimiter.Wait(msg.Command(), n, p.quit) {
+			return
+		}
+
+		if err = p.handshake.Receive(msg); err != nil {
+			p.Disconnect(err)
+			return
+		}
+
+		switch m := msg.(type) {
+		case *wire.MsgObject:
+			p.received(payload)
+		case *wire.MsgInv:
+			m.InvList, err = p.cfg.InvDuplicates.Check(m.InvList)
+		case *wire.MsgGetData:
+			m.InvList, err = p.cfg.InvDuplicates.Check(m.InvList)
+		}
+		if err != nil {
+			p.Disconnect(err)
+			return
+		}
+
+		select {
+		case p.in <- msg:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// outHandler writes messages from the Out channel to the connection until
+// the peer is disconnected.
+func (p *Peer) outHandler() {
+	for {
+		select {
+		case msg := <-p.out:
+			if !p.write(msg) {
+				return
+			}
+		case resume := <-p.pause:
+			// Nothing else is queued until the handshake is over,
+			// so the queue holds only handshake messages.
+			for len(p.out) > 0 {
+				if !p.write(<-p.out) {
+					return
+				}
+			}
+			select {
+			case resume <- struct{}{}:
+			case <-p.quit:
+				return
+			}
+			select {
+			case <-resume:
+			case <-p.quit:
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// write writes a message to the connection and returns whether the output
+// handler should go on.
+func (p *Peer) write(msg wire.Message) bool {
+	// Record the message first, since the reply to a getdata may arrive
+	// before the write returns.
+	p.sent(msg)
+	n, err := wire.WriteMessageN(p.conn, msg, p.cfg.Net)
+	if err != nil {
+		p.Disconnect(err)
+		return false
+	}
+	metrics.MessagesSent.With(msg.Command()).Inc()
+	return p.sendLimiter == nil ||
+		p.sendLimiter.Wait(msg.Command(), n, p.quit)
+}
+
+// In returns the channel on which messages from the remote peer are
+// delivered. It is closed when the peer is disconnected.
+func (p *Peer) In() <-chan wire.Message {
+	return p.in
+}
+
+// Out returns the channel on which messages may be sent to the remote peer.
+// The channel is never drained once the peer has been disconnected, so it
+// is unsafe to send on it without also selecting on Done. QueueMessage does
+// this for you.
+func (p *Peer) Out() chan<- wire.Message {
+	return p.out
+}
+
+// QueueMessage queues a message to be sent to the remote peer. It returns
+// ErrDisconnected if the peer has been disconnected.
+func (p *Peer) QueueMessage(msg wire.Message) error {
+	return p.queue(msg)
+}
+
+// Done returns a channel which is closed when the peer is disconnected.
+func (p *Peer) Done() <-chan struct{} {
+	return p.quit
+}
+
+// Err returns the reason that the peer was disconnected, or nil if it is
+// still connected or was disconnected without an error.
+func (p *Peer) Err() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.err
+}
+
+// Disconnect closes the connection to the remote peer. err is recorded as
+// the reason for the disconnection. Only the first call has any effect.
+func (p *Peer) Disconnect(err error) {
+	p.disconnect.Do(func() {
+		p.mtx.Lock()
+		p.err = err
+		p.mtx.Unlock()
+		close(p.quit)
+		p.conn.Close()
+
+		// A failed handshake is logged by newPeer.
+		if p.handshake.Established() {
+			p.log.Log(logging.Info, "peer disconnected", "err", err)
+		}
+	})
+}
+
+// Inbound returns whether the connection was initiated by the remote peer.
+func (p *Peer) Inbound() bool {
+	return p.inbound
+}
+
+// Addr returns the address of the remote peer.
+func (p *Peer) Addr() net.Addr {
+	return p.conn.RemoteAddr()
+}
+
+// Version returns the version message that the remote peer sent during
+// the handshake.
+func (p *Peer) Version() *wire.MsgVersion {
+	return p.version
+}
+
+// UserAgent returns the user agent of the remote peer.
+func (p *Peer) UserAgent() string {
+	return p.version.UserAgent
+}
+
+// Services returns the services advertised by the remote peer.
+func (p *Peer) Services() wire.ServiceFlag {
+	return p.version.Services
+}
+
+// Extensions returns the extensions that both we and the remote peer
+// support, so that features which need them can be used with this peer.
+func (p *Peer) Extensions() Extensions {
+	return p.extensions
+}
+
+// Fingerprint returns the fingerprint of the certificate that the remote
+// peer presented if the connection is encrypted with TLS, or the empty
+// string if it is not.
+func (p *Peer) Fingerprint() string {
+	return p.fingerprint
+}
+
+// Streams returns the streams that both we and the remote peer serve.
+func (p *Peer) Streams() []uint32 {
+	return p.streams
+}
+
+// NetAddress returns the remote peer's address as a wire.NetAddress in its
+// first negotiated stream.
+func (p *Peer) NetAddress() *wire.NetAddress {
+	return netAddress(p.conn.RemoteAddr(), p.streams[0], p.version.Services)
+}
+
+// netAddress converts a net.Addr to a wire.NetAddress. Addresses which
+// are not TCP addresses, such as those of in-memory pipes, are given the
+// unspecified IP and port 0.
+func netAddress(addr net.Addr, stream uint32, services wire.ServiceFlag) *wire.NetAddress {
+	na, err := wire.NewNetAddress(addr, stream, services)
+	if err != nil {
+		return wire.NewNetAddressIPPort(net.IPv4zero, 0, stream, services)
+	}
+	return na
+}
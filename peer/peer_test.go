@@ -0,0 +1,373 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/ratelimit"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// result holds the outcome of one side of a handshake.
+type result struct {
+	p   *peer.Peer
+	err error
+}
+
+// connect runs the handshake between an outbound peer using cfgOut and an
+// inbound peer using cfgIn over an in-memory pipe.
+func connect(cfgOut, cfgIn *peer.Config) (out, in result) {
+	a, b := net.Pipe()
+	ch := make(chan result)
+	go func() {
+		p, err := peer.NewInbound(cfgIn, b)
+		ch <- result{p, err}
+	}()
+	p, err := peer.NewOutbound(cfgOut, a)
+	return result{p, err}, <-ch
+}
+
+// newVersion returns a version message from a remote peer.
+func newVersion(nonce uint64, streams []uint32) *wire.MsgVersion {
+	na := wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), 8444, 1, 0)
+	return wire.NewMsgVersion(na, na, nonce, streams)
+}
+
+// TestHandshake tests that two peers can complete the handshake and then
+// exchange messages.
+func TestHandshake(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	cfgOut := &peer.Config{
+		Net:              wire.MainNet,
+		Streams:          []uint32{1},
+		Services:         wire.SFNodeNetwork,
+		UserAgentName:    "out",
+		UserAgentVersion: "1.0",
+	}
+	cfgIn := &peer.Config{
+		Net:     wire.MainNet,
+		Streams: []uint32{1},
+	}
+
+	out, in := connect(cfgOut, cfgIn)
+	if out.err != nil {
+		t.Fatalf("NewOutbound: %v", out.err)
+	}
+	if in.err != nil {
+		t.Fatalf("NewInbound: %v", in.err)
+	}
+
+	if out.p.Inbound() || !in.p.Inbound() {
+		t.Errorf("Inbound: got %v, %v want false, true",
+			out.p.Inbound(), in.p.Inbound())
+	}
+	wantUA := wire.DefaultUserAgent + "out:1.0/"
+	if ua := in.p.UserAgent(); ua != wantUA {
+		t.Errorf("UserAgent: got %s want %s", ua, wantUA)
+	}
+	if ua := out.p.UserAgent(); ua != wire.DefaultUserAgent {
+		t.Errorf("UserAgent: got %s want %s", ua, wire.DefaultUserAgent)
+	}
+	if s := in.p.Services(); s != wire.SFNodeNetwork {
+		t.Errorf("Services: got %v want %v", s, wire.SFNodeNetwork)
+	}
+	if s := out.p.Streams(); !reflect.DeepEqual(s, []uint32{1}) {
+		t.Errorf("Streams: got %v want %v", s, []uint32{1})
+	}
+
+	// Send a message each way.
+	out.p.Out() <- &wire.MsgPong{}
+	msg := <-in.p.In()
+	if _, ok := msg.(*wire.MsgPong); !ok {
+		t.Errorf("In: got %T want *wire.MsgPong", msg)
+	}
+	in.p.Out() <- &wire.MsgPong{}
+	msg = <-out.p.In()
+	if _, ok := msg.(*wire.MsgPong); !ok {
+		t.Errorf("In: got %T want *wire.MsgPong", msg)
+	}
+
+	// Disconnecting one side should disconnect the other.
+	out.p.Disconnect(nil)
+	select {
+	case <-in.p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("inbound peer was not disconnected")
+	}
+	if _, ok := <-in.p.In(); ok {
+		t.Error("In channel was not closed after disconnection")
+	}
+	if in.p.Err() == nil {
+		t.Error("Err: got nil want error")
+	}
+}
+
+// TestSelfConnection tests that a peer which receives its own nonce
+// disconnects.
+func TestSelfConnection(t *testing.T) {
+	cfg := &peer.Config{
+		Net:     wire.MainNet,
+		Streams: []uint32{1},
+	}
+
+	out, in := connect(cfg, cfg)
+	if in.err != peer.ErrSelfConnection {
+		t.Errorf("NewInbound: got %v want %v", in.err, peer.ErrSelfConnection)
+	}
+	if out.err == nil {
+		t.Error("NewOutbound: got nil want error")
+	}
+}
+
+// TestNonceSet tests that peers belonging to nodes with different nonce
+// sets may connect within the same process, while a node which connects to
+// itself is still detected.
+func TestNonceSet(t *testing.T) {
+	cfgA := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		Nonces: peer.NewNonceSet()}
+	cfgB := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		Nonces: peer.NewNonceSet()}
+
+	out, in := connect(cfgA, cfgB)
+	if out.err != nil {
+		t.Fatalf("NewOutbound: %v", out.err)
+	}
+	if in.err != nil {
+		t.Fatalf("NewInbound: %v", in.err)
+	}
+	out.p.Disconnect(nil)
+	in.p.Disconnect(nil)
+
+	_, in = connect(cfgA, cfgA)
+	if in.err != peer.ErrSelfConnection {
+		t.Errorf("NewInbound: got %v want %v", in.err, peer.ErrSelfConnection)
+	}
+}
+
+// TestNoCommonStreams tests that peers with no streams in common do not
+// connect.
+func TestNoCommonStreams(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	out, in := connect(
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{1}},
+		&peer.Config{Net: wire.MainNet, Streams: []uint32{2}})
+	if in.err != peer.ErrNoCommonStreams {
+		t.Errorf("NewInbound: got %v want %v", in.err, peer.ErrNoCommonStreams)
+	}
+	if out.err == nil {
+		t.Error("NewOutbound: got nil want error")
+	}
+}
+
+// TestProtocolViolation tests that the inbound peer rejects an invalid
+// handshake.
+func TestProtocolViolation(t *testing.T) {
+	tests := []struct {
+		msg wire.Message
+	}{
+		// Message other than version before handshake.
+		{&wire.MsgPong{}},
+		// Verack before version.
+		{&wire.MsgVerAck{}},
+		// Protocol version too old.
+		{func() wire.Message {
+			msg := newVersion(1, []uint32{1})
+			msg.ProtocolVersion = 2
+			return msg
+		}()},
+		// Clock too far off.
+		{func() wire.Message {
+			msg := newVersion(1, []uint32{1})
+			msg.Timestamp = msg.Timestamp.Add(-2 * peer.MaxTimeOffset)
+			return msg
+		}()},
+	}
+
+	cfg := &peer.Config{Net: wire.MainNet, Streams: []uint32{1}}
+	for i, test := range tests {
+		a, b := net.Pipe()
+		go wire.WriteMessage(a, test.msg, wire.MainNet)
+
+		_, err := peer.NewInbound(cfg, b)
+		if _, ok := err.(*peer.ProtocolError); !ok {
+			t.Errorf("test #%d: got %v want *peer.ProtocolError", i, err)
+		}
+		a.Close()
+	}
+}
+
+// TestDuplicateVersion tests that a peer which sends a version message
+// after the handshake is disconnected.
+func TestDuplicateVersion(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+
+	go func() {
+		wire.WriteMessage(a, newVersion(1, []uint32{1}), wire.MainNet)
+		// Read the inbound peer's version and verack.
+		for i := 0; i < 2; i++ {
+			if _, _, err := wire.ReadMessage(a, wire.MainNet); err != nil {
+				return
+			}
+		}
+		wire.WriteMessage(a, &wire.MsgVerAck{}, wire.MainNet)
+		wire.WriteMessage(a, newVersion(1, []uint32{1}), wire.MainNet)
+	}()
+
+	p, err := peer.NewInbound(&peer.Config{Net: wire.MainNet,
+		Streams: []uint32{1}}, b)
+	if err != nil {
+		t.Fatalf("NewInbound: %v", err)
+	}
+
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("peer was not disconnected")
+	}
+	if _, ok := p.Err().(*peer.ProtocolError); !ok {
+		t.Errorf("Err: got %v want *peer.ProtocolError", p.Err())
+	}
+}
+
+// TestInvDuplicates tests that duplicate inventory vectors received from a
+// peer are dealt with according to Config.InvDuplicates.
+func TestInvDuplicates(t *testing.T) {
+	iv := wire.InvVect(*hash.InventoryHash([]byte("a")))
+	inv := &wire.MsgInv{InvList: []*wire.InvVect{&iv, &iv}}
+
+	for _, d := range []wire.InvDuplicates{wire.InvDuplicatesRemoved,
+		wire.InvDuplicatesRejected} {
+
+		a, b := net.Pipe()
+		go func() {
+			wire.WriteMessage(a, newVersion(1, []uint32{1}), wire.MainNet)
+			for i := 0; i < 2; i++ {
+				if _, _, err := wire.ReadMessage(a, wire.MainNet); err != nil {
+					return
+				}
+			}
+			wire.WriteMessage(a, &wire.MsgVerAck{}, wire.MainNet)
+			wire.WriteMessage(a, inv, wire.MainNet)
+		}()
+
+		p, err := peer.NewInbound(&peer.Config{Net: wire.MainNet,
+			Streams: []uint32{1}, InvDuplicates: d}, b)
+		if err != nil {
+			t.Fatalf("NewInbound: %v", err)
+		}
+
+		select {
+		case msg, ok := <-p.In():
+			if d == wire.InvDuplicatesRejected {
+				if ok {
+					t.Errorf("got %v want no message", msg)
+				}
+				if !errors.Is(p.Err(), wire.ErrDuplicateInvVect) {
+					t.Errorf("Err: got %v want %v", p.Err(),
+						wire.ErrDuplicateInvVect)
+				}
+			} else if got, _ := msg.(*wire.MsgInv); got == nil ||
+				len(got.InvList) != 1 {
+				t.Errorf("got %v want one inventory vector", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no message was received")
+		}
+		a.Close()
+		p.Disconnect(nil)
+	}
+}
+
+// errWrite is returned by failConn when written to.
+var errWrite = errors.New("write failed")
+
+// failConn is a net.Conn whose writes always fail.
+type failConn struct {
+	net.Conn
+}
+
+func (c failConn) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+// TestClosedBeforeVersion tests that the handshake fails with the error
+// that caused it to fail rather than a follow-on error from the closed
+// connection.
+func TestClosedBeforeVersion(t *testing.T) {
+	cfg := &peer.Config{Net: wire.MainNet, Streams: []uint32{1}}
+
+	// The remote side closes before reading our version. Either the read
+	// or the write may notice first.
+	a, b := net.Pipe()
+	b.Close()
+	_, err := peer.NewOutbound(cfg, a)
+	if err != io.EOF && err != io.ErrClosedPipe {
+		t.Errorf("NewOutbound: got %v want %v or %v", err, io.EOF,
+			io.ErrClosedPipe)
+	}
+
+	// The version cannot be written.
+	a, b = net.Pipe()
+	defer b.Close()
+	if _, err = peer.NewOutbound(cfg, failConn{a}); err != errWrite {
+		t.Errorf("NewOutbound: got %v want %v", err, errWrite)
+	}
+}
+
+// TestSendLimits tests that a peer does not send faster than its limits
+// allow.
+func TestSendLimits(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	cfgOut := &peer.Config{
+		Net:     wire.MainNet,
+		Streams: []uint32{1},
+		SendLimits: ratelimit.Limits{
+			ratelimit.ClassAddr: {Messages: 4},
+		},
+	}
+	cfgIn := &peer.Config{Net: wire.MainNet, Streams: []uint32{1}}
+
+	out, in := connect(cfgOut, cfgIn)
+	if out.err != nil {
+		t.Fatalf("NewOutbound: %v", out.err)
+	}
+	if in.err != nil {
+		t.Fatalf("NewInbound: %v", in.err)
+	}
+	defer out.p.Disconnect(nil)
+	defer in.p.Disconnect(nil)
+
+	// The first four messages use up the bucket, so the sixth cannot be
+	// sent until a quarter of a second after the fifth.
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := out.p.QueueMessage(wire.NewMsgAddr()); err != nil {
+			t.Fatalf("QueueMessage: %v", err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		select {
+		case <-in.p.In():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %d was not received", i)
+		}
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("messages were received after %v, want at least %v", d,
+			250*time.Millisecond)
+	}
+}
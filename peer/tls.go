@@ -0,0 +1,191 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// tlsAgent is the name of the part of the user agent which carries the
+// fingerprint of our certificate, as in /tls:1(9f86d0...)/.
+const tlsAgent = "tls"
+
+// certificateLifetime is how long a certificate made by NewCertificate is
+// valid for. Certificates are pinned rather than checked against a
+// certificate authority, so there is no reason to renew them often.
+const certificateLifetime = 10 * 365 * 24 * time.Hour
+
+// TLS means that the peer can encrypt the connection with TLS once the
+// handshake is over. It is advertised automatically when
+// Config.TLSCertificate is set.
+var TLS = Extension{Name: "tls"}
+
+// NewCertificate generates a self-signed certificate which may be used as
+// Config.TLSCertificate.
+func NewCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "bitmessage"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Fingerprint returns the fingerprint by which a certificate is pinned,
+// which is the hex encoded SHA-256 hash of the DER encoding of its leaf.
+func Fingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	return fingerprint(cert.Certificate[0])
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// validFingerprint returns whether s could be a fingerprint returned by
+// Fingerprint.
+func validFingerprint(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+// extensions returns the extensions that we advertise, which include TLS
+// if we have a certificate.
+func (cfg *Config) extensions() []Extension {
+	if cfg.TLSCertificate == nil {
+		return cfg.Extensions
+	}
+	extensions := make([]Extension, 0, len(cfg.Extensions)+1)
+	extensions = append(extensions, cfg.Extensions...)
+	return append(extensions, TLS)
+}
+
+// advertiseTLS adds the fingerprint of our certificate to a version
+// message, if we have one. It must be called after Advertise.
+func (p *Peer) advertiseTLS(msg *wire.MsgVersion) error {
+	if p.cfg.TLSCertificate == nil {
+		return nil
+	}
+	return msg.AddUserAgent(tlsAgent, "1", Fingerprint(*p.cfg.TLSCertificate))
+}
+
+// handleTLS checks the fingerprint in the remote peer's version message if
+// we have negotiated TLS with it.
+func (p *Peer) handleTLS(msg *wire.MsgVersion) error {
+	if !p.extensions.Has(TLS) {
+		if p.cfg.RequireTLS {
+			return ErrTLSRequired
+		}
+		return nil
+	}
+
+	fingerprints := userAgentComments(msg.UserAgent, tlsAgent)
+	if len(fingerprints) != 1 || !validFingerprint(fingerprints[0]) {
+		return newProtocolError("tls advertised without a fingerprint")
+	}
+	p.fingerprint = fingerprints[0]
+	return nil
+}
+
+// startTLS replaces the connection with a TLS connection on which the
+// remote peer must present the certificate whose fingerprint it sent in its
+// version message. The outbound peer is the TLS client.
+func (p *Peer) startTLS() error {
+	// Nothing may be written in the clear once the TLS handshake has
+	// begun, so the output handler must first finish with the handshake
+	// messages and then wait for the new connection.
+	resume, err := p.pauseOutput()
+	if err != nil {
+		return err
+	}
+	defer close(resume)
+
+	config := &tls.Config{
+		Certificates:           []tls.Certificate{*p.cfg.TLSCertificate},
+		MinVersion:             tls.VersionTLS12,
+		SessionTicketsDisabled: true,
+
+		// The certificate is self-signed, so it is checked against
+		// the pinned fingerprint instead of a certificate authority.
+		InsecureSkipVerify: true,
+		ClientAuth:         tls.RequireAnyClientCert,
+		VerifyPeerCertificate: func(certs [][]byte, _ [][]*x509.Certificate) error {
+			if len(certs) == 0 || fingerprint(certs[0]) != p.fingerprint {
+				return ErrFingerprintMismatch
+			}
+			return nil
+		},
+	}
+
+	var conn *tls.Conn
+	if p.inbound {
+		conn = tls.Server(p.conn, config)
+	} else {
+		conn = tls.Client(p.conn, config)
+	}
+
+	conn.SetDeadline(p.handshake.Deadline())
+	if err = conn.Handshake(); err != nil {
+		if !time.Now().Before(p.handshake.Deadline()) {
+			return ErrHandshakeTimeout
+		}
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.conn = conn
+	return nil
+}
+
+// pauseOutput waits for the output handler to write the messages which have
+// been queued so far, after which it writes nothing more until the
+// returned channel is closed.
+func (p *Peer) pauseOutput() (chan struct{}, error) {
+	resume := make(chan struct{})
+	select {
+	case p.pause <- resume:
+	case <-p.quit:
+		return nil, ErrDisconnected
+	}
+	select {
+	case <-resume:
+		return resume, nil
+	case <-p.quit:
+		return nil, ErrDisconnected
+	}
+}
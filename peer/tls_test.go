@@ -0,0 +1,198 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// recordConn is a net.Conn which records what is written to it.
+type recordConn struct {
+	net.Conn
+	mtx     sync.Mutex
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.mtx.Lock()
+	c.written.Write(b)
+	c.mtx.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *recordConn) Written() []byte {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]byte(nil), c.written.Bytes()...)
+}
+
+// tcpPipe returns both ends of a TCP connection over the loopback
+// interface. net.Pipe cannot be used because a write of nothing, such as
+// the payload of a verack, blocks until the other end reads.
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	ch := make(chan net.Conn)
+	go func() {
+		conn, _ := l.Accept()
+		ch <- conn
+	}()
+	a, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	b := <-ch
+	if b == nil {
+		t.Fatal("Accept failed")
+	}
+	return a, b
+}
+
+func newCertificate(t *testing.T) *tls.Certificate {
+	cert, err := peer.NewCertificate()
+	if err != nil {
+		t.Fatalf("NewCertificate: %v", err)
+	}
+	return &cert
+}
+
+// TestTLS tests that peers which both have certificates encrypt the
+// connection after the handshake.
+func TestTLS(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	certOut, certIn := newCertificate(t), newCertificate(t)
+	cfgOut := &peer.Config{
+		Net:            wire.MainNet,
+		Streams:        []uint32{1},
+		Extensions:     []peer.Extension{peer.Compression},
+		TLSCertificate: certOut,
+	}
+	cfgIn := &peer.Config{
+		Net:            wire.MainNet,
+		Streams:        []uint32{1},
+		TLSCertificate: certIn,
+		RequireTLS:     true,
+	}
+
+	a, b := tcpPipe(t)
+	conn := &recordConn{Conn: a}
+	ch := make(chan result)
+	go func() {
+		p, err := peer.NewInbound(cfgIn, b)
+		ch <- result{p, err}
+	}()
+	p, err := peer.NewOutbound(cfgOut, conn)
+	out, in := result{p, err}, <-ch
+	if out.err != nil || in.err != nil {
+		t.Fatalf("connect: %v, %v", out.err, in.err)
+	}
+	defer out.p.Disconnect(nil)
+	defer in.p.Disconnect(nil)
+
+	if !out.p.Extensions().Has(peer.TLS) || !in.p.Extensions().Has(peer.TLS) {
+		t.Errorf("Extensions: got %v, %v", out.p.Extensions(),
+			in.p.Extensions())
+	}
+	if fp := out.p.Fingerprint(); fp != peer.Fingerprint(*certIn) {
+		t.Errorf("Fingerprint: got %s want %s", fp, peer.Fingerprint(*certIn))
+	}
+	if fp := in.p.Fingerprint(); fp != peer.Fingerprint(*certOut) {
+		t.Errorf("Fingerprint: got %s want %s", fp, peer.Fingerprint(*certOut))
+	}
+
+	out.p.Out() <- &wire.MsgPong{}
+	if msg := <-in.p.In(); msg.Command() != wire.CmdPong {
+		t.Errorf("In: got %s want %s", msg.Command(), wire.CmdPong)
+	}
+	in.p.Out() <- &wire.MsgPong{}
+	if msg := <-out.p.In(); msg.Command() != wire.CmdPong {
+		t.Errorf("In: got %s want %s", msg.Command(), wire.CmdPong)
+	}
+
+	// The version messages are sent in the clear and the pong is not.
+	written := conn.Written()
+	if !bytes.Contains(written, []byte(wire.CmdVersion)) ||
+		bytes.Contains(written, []byte(wire.CmdPong)) {
+		t.Error("pong was not encrypted")
+	}
+}
+
+// TestTLSRequired tests that a peer which requires TLS disconnects peers
+// which do not support it.
+func TestTLSRequired(t *testing.T) {
+	defer peer.TstAllowSelfConns(peer.TstAllowSelfConns(true))
+
+	cfgOut := &peer.Config{
+		Net:            wire.MainNet,
+		Streams:        []uint32{1},
+		TLSCertificate: newCertificate(t),
+		RequireTLS:     true,
+	}
+	cfgIn := &peer.Config{
+		Net:     wire.MainNet,
+		Streams: []uint32{1},
+	}
+
+	out, in := connect(cfgOut, cfgIn)
+	if out.err != peer.ErrTLSRequired {
+		t.Errorf("NewOutbound: got %v want %v", out.err, peer.ErrTLSRequired)
+	}
+	if in.err == nil {
+		in.p.Disconnect(nil)
+		t.Error("NewInbound: got nil want error")
+	}
+}
+
+// TestFingerprintMismatch tests that a peer which presents a certificate
+// other than the one it advertised is disconnected.
+func TestFingerprintMismatch(t *testing.T) {
+	cfg := &peer.Config{
+		Net:            wire.MainNet,
+		Streams:        []uint32{1},
+		TLSCertificate: newCertificate(t),
+	}
+	advertised, presented := newCertificate(t), newCertificate(t)
+
+	a, b := tcpPipe(t)
+	go func() {
+		defer b.Close()
+		if _, _, err := wire.ReadMessage(b, wire.MainNet); err != nil {
+			return
+		}
+		msg := newVersion(1, []uint32{1})
+		peer.Advertise(msg, []peer.Extension{peer.TLS})
+		msg.AddUserAgent("tls", "1", peer.Fingerprint(*advertised))
+		wire.WriteMessage(b, msg, wire.MainNet)
+		wire.WriteMessage(b, &wire.MsgVerAck{}, wire.MainNet)
+		if _, _, err := wire.ReadMessage(b, wire.MainNet); err != nil {
+			return
+		}
+		conn := tls.Server(b, &tls.Config{
+			Certificates: []tls.Certificate{*presented},
+			ClientAuth:   tls.RequireAnyClientCert,
+		})
+		conn.Handshake()
+	}()
+
+	p, err := peer.NewOutbound(cfg, a)
+	if err != peer.ErrFingerprintMismatch {
+		if p != nil {
+			p.Disconnect(nil)
+		}
+		t.Errorf("NewOutbound: got %v want %v", err, peer.ErrFingerprintMismatch)
+	}
+}
@@ -8,5 +8,16 @@ Package pow implements proof-of-work and its checking mechanism. More details
 for PoW for Bitmessage can be found at:
 
 https://bitmessage.org/wiki/Proof_of_work
+
+The package is written in Go only, without assembly or cgo, so it builds for
+wasm and with TinyGo. Build with the purego tag to keep the standard library's
+SHA-512 from using assembly as well.
+
+A Policy decides how much proof of work identities demand of messages sent
+to them and how much is accepted on the objects which are received. The
+presets returned by MinimumNetwork, MobileFriendly, Desktop and Paranoid
+trade spam against the time that others take to reach an identity, and
+LookupPolicy finds one by name, as given in a configuration file. Each call
+returns a new copy, so changing one does not change the preset.
 */
 package pow
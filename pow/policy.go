@@ -0,0 +1,131 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnknownPolicy is returned by LookupPolicy when there is no preset with
+// the given name.
+var ErrUnknownPolicy = errors.New("unknown proof of work policy")
+
+// Policy is a choice of how much proof of work to demand of others. The
+// more that is demanded, the less spam gets through, but the longer it
+// takes those who are not spammers to send anything, which matters most to
+// those sending from phones.
+type Policy struct {
+	// Name identifies the policy.
+	Name string
+
+	// Demand is the proof of work which identities created under the
+	// policy demand of messages sent to them. It is published in their
+	// pubkeys, so it is what senders do.
+	Demand Data
+
+	// Accept is the least proof of work accepted on an object which is
+	// received, whoever it is for. Messages must also satisfy the Demand
+	// of the identity which they are sent to.
+	Accept Data
+}
+
+// String returns the name of the policy.
+func (p *Policy) String() string {
+	return p.Name
+}
+
+// The presets, from the least demanding to the most. None demands less than
+// Default, since the network drops objects with less proof of work than
+// that. They are only handed out as copies, so that a caller which changes
+// one does not change it for everyone else.
+var presets = []Policy{
+	{
+		Name:   "minimum-network",
+		Demand: Default,
+		Accept: Default,
+	},
+	{
+		Name: "mobile-friendly",
+		Demand: Data{
+			NonceTrialsPerByte: 3 * DefaultNonceTrialsPerByte / 2,
+			ExtraBytes:         DefaultExtraBytes,
+		},
+		Accept: Default,
+	},
+	{
+		Name: "desktop",
+		Demand: Data{
+			NonceTrialsPerByte: 2 * DefaultNonceTrialsPerByte,
+			ExtraBytes:         2 * DefaultExtraBytes,
+		},
+		Accept: Default,
+	},
+	{
+		Name: "paranoid",
+		Demand: Data{
+			NonceTrialsPerByte: 8 * DefaultNonceTrialsPerByte,
+			ExtraBytes:         4 * DefaultExtraBytes,
+		},
+		Accept: Data{
+			NonceTrialsPerByte: 2 * DefaultNonceTrialsPerByte,
+			ExtraBytes:         2 * DefaultExtraBytes,
+		},
+	},
+}
+
+// preset returns a copy of the preset at index i.
+func preset(i int) *Policy {
+	p := presets[i]
+	return &p
+}
+
+// MinimumNetwork returns a policy which demands only what the network
+// requires, so that anyone can reach an identity with as little work as
+// possible.
+func MinimumNetwork() *Policy {
+	return preset(0)
+}
+
+// MobileFriendly returns a policy which demands a little more than the
+// network requires, but little enough that a sender on a phone can still
+// do it in reasonable time.
+func MobileFriendly() *Policy {
+	return preset(1)
+}
+
+// Desktop returns a policy which demands twice what the network requires,
+// which a desktop computer does without much delay.
+func Desktop() *Policy {
+	return preset(2)
+}
+
+// Paranoid returns a policy which demands far more than the network
+// requires, and accepts no object with less than twice the minimum. Since
+// most clients do only the minimum, it misses most broadcasts.
+func Paranoid() *Policy {
+	return preset(3)
+}
+
+// Policies returns a copy of each preset, from the least demanding to the
+// most.
+func Policies() []*Policy {
+	policies := make([]*Policy, len(presets))
+	for i := range presets {
+		policies[i] = preset(i)
+	}
+	return policies
+}
+
+// LookupPolicy returns a copy of the preset with the given name, ignoring
+// case. It returns ErrUnknownPolicy if there is none.
+func LookupPolicy(name string) (*Policy, error) {
+	for i := range presets {
+		if strings.EqualFold(presets[i].Name, name) {
+			return preset(i), nil
+		}
+	}
+	return nil, ErrUnknownPolicy
+}
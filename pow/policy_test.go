@@ -0,0 +1,51 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestPolicies(t *testing.T) {
+	var last *pow.Policy
+	for _, p := range pow.Policies() {
+		// No preset demands or accepts less than the network does.
+		if p.Demand.NonceTrialsPerByte < pow.DefaultNonceTrialsPerByte ||
+			p.Demand.ExtraBytes < pow.DefaultExtraBytes ||
+			p.Accept.NonceTrialsPerByte < pow.DefaultNonceTrialsPerByte ||
+			p.Accept.ExtraBytes < pow.DefaultExtraBytes {
+			t.Errorf("%s: below the network minimum", p)
+		}
+
+		// The presets are in order.
+		if last != nil && (p.Demand.NonceTrialsPerByte < last.Demand.NonceTrialsPerByte ||
+			p.Demand.ExtraBytes < last.Demand.ExtraBytes) {
+			t.Errorf("%s demands less than %s", p, last)
+		}
+		last = p
+
+		got, err := pow.LookupPolicy(p.Name)
+		if err != nil || *got != *p {
+			t.Errorf("LookupPolicy(%q): got %v, %v want %v, nil", p.Name,
+				got, err, p)
+		}
+	}
+
+	got, err := pow.LookupPolicy("Desktop")
+	if err != nil || *got != *pow.Desktop() {
+		t.Errorf("LookupPolicy: got %v, %v want desktop, nil", got, err)
+	}
+
+	// Changing a policy which was looked up does not change the preset.
+	got.Demand = pow.Default
+	if *pow.Desktop() == *got {
+		t.Error("LookupPolicy: changing the result changed the preset")
+	}
+	if _, err := pow.LookupPolicy("lax"); err != pow.ErrUnknownPolicy {
+		t.Errorf("LookupPolicy: got %v want %v", err, pow.ErrUnknownPolicy)
+	}
+}
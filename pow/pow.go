@@ -8,8 +8,11 @@ package pow
 import (
 	"encoding/binary"
 	"math"
+	"runtime"
+	"time"
 
 	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/metrics"
 )
 
 // CalculateTarget calculates the target POW value. payloadLength includes the
@@ -25,16 +28,21 @@ func CalculateTarget(payloadLength, ttl uint64, data Data) Target {
 			math.Pow(2, 16)))))
 }
 
-// Check whether the given message and nonce satisfy the given pow target.
-func Check(target Target, nonce Nonce, message []byte) bool {
+// TrialValue returns the value of the proof of work done with the given
+// nonce on the message, which must be no greater than the target for the
+// work to be sufficient.
+func TrialValue(nonce Nonce, message []byte) uint64 {
 	hashData := make([]byte, 8+len(message))
 	copy(hashData[:8], nonce.Bytes())
 	copy(hashData[8:], message)
 	resultHash := hash.DoubleSha512(hashData)
 
-	powValue := binary.BigEndian.Uint64(resultHash[0:8])
+	return binary.BigEndian.Uint64(resultHash[0:8])
+}
 
-	return powValue <= uint64(target)
+// Check whether the given message and nonce satisfy the given pow target.
+func Check(target Target, nonce Nonce, message []byte) bool {
+	return TrialValue(nonce, message) <= uint64(target)
 }
 
 // DoSequential does the PoW sequentially and returns the nonce value.
@@ -91,3 +99,15 @@ func DoParallel(target Target, initialHash []byte, parallelCount int) Nonce {
 	}
 	return <-nonceValue
 }
+
+// Do does the proof of work on an encoded object with the given time to
+// live in seconds, using a goroutine for each CPU, and returns the nonce.
+// The first 8 bytes of encoded hold the nonce and are not hashed. The time
+// taken is recorded in metrics.PowSeconds.
+func Do(encoded []byte, ttl uint64, data Data) Nonce {
+	target := CalculateTarget(uint64(len(encoded)), ttl, data)
+	start := time.Now()
+	nonce := DoParallel(target, hash.Sha512(encoded[8:]), runtime.NumCPU())
+	metrics.PowSeconds.Observe(time.Since(start).Seconds())
+	return nonce
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket.
+type Bucket struct {
+	rate  float64 // tokens per second
+	burst float64 // maximum number of tokens
+
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBucket returns a full bucket which is refilled at rate tokens per
+// second up to burst tokens.
+func NewBucket(rate, burst float64) *Bucket {
+	return newBucket(rate, burst, time.Now)
+}
+
+func newBucket(rate, burst float64, now func() time.Time) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now(),
+		now:    now,
+	}
+}
+
+// refill adds the tokens accumulated since the last refill. The mutex must
+// be held.
+func (b *Bucket) refill() {
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// Allow takes n tokens if they are available and returns whether it did.
+func (b *Bucket) Allow(n float64) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// Take takes n tokens, going into debt if there are not enough, and returns
+// how long the caller should wait for the debt to be repaid.
+func (b *Bucket) Take(n float64) time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Tokens returns the number of tokens in the bucket, which is negative if
+// the bucket is in debt.
+func (b *Bucket) Tokens() float64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	return b.tokens
+}
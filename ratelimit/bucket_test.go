@@ -0,0 +1,74 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/ratelimit"
+)
+
+// clock is a fake clock for testing buckets.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// TestBucket tests taking tokens from a bucket and refilling it.
+func TestBucket(t *testing.T) {
+	c := &clock{t: time.Unix(1000, 0)}
+	b := ratelimit.TstNewBucket(10, 20, c.now)
+
+	if tokens := b.Tokens(); tokens != 20 {
+		t.Errorf("Tokens: got %v want %v", tokens, 20)
+	}
+	if !b.Allow(15) {
+		t.Error("Allow(15): got false want true")
+	}
+	if b.Allow(10) {
+		t.Error("Allow(10): got true want false")
+	}
+
+	// One second refills 10 tokens.
+	c.advance(time.Second)
+	if tokens := b.Tokens(); tokens != 15 {
+		t.Errorf("Tokens: got %v want %v", tokens, 15)
+	}
+
+	// The bucket never holds more than its burst.
+	c.advance(time.Minute)
+	if tokens := b.Tokens(); tokens != 20 {
+		t.Errorf("Tokens: got %v want %v", tokens, 20)
+	}
+
+	tests := []struct {
+		n    float64
+		wait time.Duration
+	}{
+		{15, 0},
+		{5, 0},
+		{5, 500 * time.Millisecond},
+		{10, 1500 * time.Millisecond},
+	}
+	for i, test := range tests {
+		if wait := b.Take(test.n); wait != test.wait {
+			t.Errorf("Take #%d: got %v want %v", i, wait, test.wait)
+		}
+	}
+
+	// The debt is repaid over time.
+	c.advance(time.Second)
+	if tokens := b.Tokens(); tokens != -5 {
+		t.Errorf("Tokens: got %v want %v", tokens, -5)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package ratelimit provides token bucket rate limiters for wire traffic.
+
+A Bucket holds tokens which are replenished at a constant rate up to a
+maximum. A Limiter holds buckets for messages per second and bytes per
+second for each class of command, and may have a parent Limiter, so that a
+single global limit can be shared by the limiters of every peer.
+
+Tokens are taken after a message has been read or written, when its size is
+known, and the bucket is allowed to go into debt. The caller then waits
+until the debt has been repaid before handling the next message.
+*/
+package ratelimit
@@ -0,0 +1,20 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the ratelimit package rather than than the
+ratelimit_test package so it can bridge access to the internals to properly
+test cases which are either not possible or can't reliably be tested via the
+public interface. The functions are only exported while the tests are being
+run.
+*/
+
+package ratelimit
+
+import "time"
+
+// TstNewBucket returns a bucket which reads the time from now.
+func TstNewBucket(rate, burst float64, now func() time.Time) *Bucket {
+	return newBucket(rate, burst, now)
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Class is a class of commands which are limited together.
+type Class int
+
+// The classes of commands. ClassAll applies to every message in addition
+// to its own class.
+const (
+	ClassAll Class = iota
+	ClassObject
+	ClassInventory
+	ClassAddr
+	ClassControl
+)
+
+// ClassOf returns the class of a command.
+func ClassOf(command string) Class {
+	switch command {
+	case wire.CmdObject:
+		return ClassObject
+	case wire.CmdInv, wire.CmdGetData:
+		return ClassInventory
+	case wire.CmdAddr:
+		return ClassAddr
+	default:
+		return ClassControl
+	}
+}
+
+// Rate is a limit on the number of messages and bytes per second. Zero
+// means unlimited.
+type Rate struct {
+	Messages float64
+	Bytes    float64
+}
+
+// Limits gives the rates for each class of command. Classes which are not
+// present are not limited.
+type Limits map[Class]Rate
+
+// classBuckets are the buckets of a class.
+type classBuckets struct {
+	messages *Bucket
+	bytes    *Bucket
+}
+
+// Limiter limits messages and bytes per second for each class of
+// command.
+type Limiter struct {
+	classes map[Class]classBuckets
+	parent  *Limiter
+}
+
+// NewLimiter returns a Limiter with the given limits. Buckets may hold one
+// second's worth of tokens. If parent is not nil, every message is also
+// counted against it.
+func NewLimiter(limits Limits, parent *Limiter) *Limiter {
+	l := &Limiter{
+		classes: make(map[Class]classBuckets),
+		parent:  parent,
+	}
+	for class, rate := range limits {
+		var cb classBuckets
+		if rate.Messages > 0 {
+			cb.messages = NewBucket(rate.Messages, rate.Messages)
+		}
+		if rate.Bytes > 0 {
+			cb.bytes = NewBucket(rate.Bytes, rate.Bytes)
+		}
+		l.classes[class] = cb
+	}
+	return l
+}
+
+// take takes the tokens for a message of the given class and size from
+// the buckets of the class and returns the longest wait.
+func (l *Limiter) take(class Class, size int) time.Duration {
+	var wait time.Duration
+	cb, ok := l.classes[class]
+	if !ok {
+		return 0
+	}
+	if cb.messages != nil {
+		if d := cb.messages.Take(1); d > wait {
+			wait = d
+		}
+	}
+	if cb.bytes != nil {
+		if d := cb.bytes.Take(float64(size)); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Reserve counts a message with the given command and size against the
+// limiter and its parents, and returns how long the caller should wait
+// before handling another message.
+func (l *Limiter) Reserve(command string, size int) time.Duration {
+	var wait time.Duration
+	for ; l != nil; l = l.parent {
+		if d := l.take(ClassAll, size); d > wait {
+			wait = d
+		}
+		if d := l.take(ClassOf(command), size); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Wait counts a message against the limiter as Reserve does and then
+// waits. It returns false if cancel was closed before the wait was over.
+func (l *Limiter) Wait(command string, size int, cancel <-chan struct{}) bool {
+	wait := l.Reserve(command, size)
+	if wait <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-cancel:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/ratelimit"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestClassOf tests the classes of the wire commands.
+func TestClassOf(t *testing.T) {
+	tests := []struct {
+		command string
+		class   ratelimit.Class
+	}{
+		{wire.CmdObject, ratelimit.ClassObject},
+		{wire.CmdInv, ratelimit.ClassInventory},
+		{wire.CmdGetData, ratelimit.ClassInventory},
+		{wire.CmdAddr, ratelimit.ClassAddr},
+		{wire.CmdVersion, ratelimit.ClassControl},
+		{wire.CmdVerAck, ratelimit.ClassControl},
+		{wire.CmdPong, ratelimit.ClassControl},
+	}
+
+	for i, test := range tests {
+		if class := ratelimit.ClassOf(test.command); class != test.class {
+			t.Errorf("ClassOf #%d: got %v want %v", i, class, test.class)
+		}
+	}
+}
+
+// TestLimiter tests that a limiter counts messages against the limits of
+// their own class and of ClassAll only.
+func TestLimiter(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limits{
+		ratelimit.ClassAll:    {Bytes: 1000},
+		ratelimit.ClassObject: {Messages: 2},
+	}, nil)
+
+	tests := []struct {
+		command string
+		size    int
+		wait    bool
+	}{
+		{wire.CmdObject, 100, false},
+		{wire.CmdObject, 100, false},
+		{wire.CmdObject, 100, true}, // too many objects
+		{wire.CmdInv, 100, false},   // inv is not limited by count
+		{wire.CmdInv, 1000, true},   // too many bytes
+		{wire.CmdAddr, 1, true},     // the bytes are still owed
+	}
+
+	for i, test := range tests {
+		wait := l.Reserve(test.command, test.size)
+		if (wait > 0) != test.wait {
+			t.Errorf("Reserve #%d: got %v want wait %v", i, wait, test.wait)
+		}
+	}
+}
+
+// TestLimiterParent tests that messages are counted against the parent
+// limiter, which is shared by its children.
+func TestLimiterParent(t *testing.T) {
+	global := ratelimit.NewLimiter(ratelimit.Limits{
+		ratelimit.ClassAll: {Messages: 3},
+	}, nil)
+	a := ratelimit.NewLimiter(nil, global)
+	b := ratelimit.NewLimiter(nil, global)
+
+	for i, l := range []*ratelimit.Limiter{a, b, a} {
+		if wait := l.Reserve(wire.CmdObject, 10); wait != 0 {
+			t.Errorf("Reserve #%d: got %v want 0", i, wait)
+		}
+	}
+	if wait := b.Reserve(wire.CmdObject, 10); wait <= 0 {
+		t.Errorf("Reserve: got %v want > 0", wait)
+	}
+}
+
+// TestLimiterWait tests that Wait returns early if it is cancelled.
+func TestLimiterWait(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limits{
+		ratelimit.ClassAll: {Messages: 1},
+	}, nil)
+
+	if !l.Wait(wire.CmdObject, 0, nil) {
+		t.Error("Wait: got false want true")
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	start := time.Now()
+	if l.Wait(wire.CmdObject, 0, cancel) {
+		t.Error("Wait: got true want false")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Wait took %v after being cancelled", d)
+	}
+}
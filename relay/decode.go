@@ -0,0 +1,224 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ErrInsufficientPow is the error of a DecodeResult for an object whose
+// proof of work is not sufficient.
+var ErrInsufficientPow = errors.New("insufficient proof of work")
+
+// DecoderConfig is the configuration of a Decoder.
+type DecoderConfig struct {
+	// Workers is the number of objects which are decoded at once. The
+	// default is the number of CPUs.
+	Workers int
+
+	// Pow is the proof of work which objects must satisfy. The default
+	// is pow.Default.
+	Pow pow.Data
+
+	// Ordered makes the results come out in the same order as the
+	// objects went in. Otherwise each result comes out as soon as it is
+	// ready, which keeps one slow object from holding up the rest.
+	Ordered bool
+
+	// Clock tells the time against which the proof of work of objects is
+	// checked. The default is clock.System.
+	Clock clock.Clock
+}
+
+// DecodeResult is the result of decoding and validating one object.
+type DecodeResult struct {
+	// Index is the position of the object in the input, counting from
+	// zero.
+	Index int
+
+	// Object is the decoded object. It is nil if the object could not
+	// be decoded.
+	Object *wire.MsgObject
+
+	// InvHash is the inventory hash of the object.
+	InvHash *hash.Sha
+
+	// Err is nil if the object is valid. Otherwise it is the error from
+	// decoding the object, or ErrInsufficientPow.
+	Err error
+}
+
+// Decoder decodes encoded objects and checks their proof of work across a
+// fixed number of goroutines. This lets a node catch up quickly on a large
+// number of queued objects.
+type Decoder struct {
+	workers int
+	pow     pow.Data
+	ordered bool
+	now     func() time.Time
+}
+
+// NewDecoder returns a new Decoder. cfg may be nil, in which case the
+// default configuration is used.
+func NewDecoder(cfg *DecoderConfig) *Decoder {
+	if cfg == nil {
+		cfg = &DecoderConfig{}
+	}
+
+	d := &Decoder{
+		workers: cfg.Workers,
+		pow:     cfg.Pow,
+		ordered: cfg.Ordered,
+		now:     clock.Or(cfg.Clock).Now,
+	}
+	if d.workers <= 0 {
+		d.workers = runtime.NumCPU()
+	}
+	if d.pow.NonceTrialsPerByte == 0 {
+		d.pow.NonceTrialsPerByte = pow.DefaultNonceTrialsPerByte
+	}
+	if d.pow.ExtraBytes == 0 {
+		d.pow.ExtraBytes = pow.DefaultExtraBytes
+	}
+	return d
+}
+
+// decode decodes and validates one object.
+func (d *Decoder) decode(index int, encoded []byte) *DecodeResult {
+	result := &DecodeResult{
+		Index:   index,
+		InvHash: hash.InventoryHash(encoded),
+	}
+
+	result.Object, result.Err = wire.DecodeMsgObject(encoded)
+	if result.Err != nil {
+		result.Object = nil
+		return result
+	}
+
+	if !result.Object.CheckPow(d.pow, d.now()) {
+		result.Err = ErrInsufficientPow
+	}
+	return result
+}
+
+// job is an encoded object waiting to be decoded.
+type job struct {
+	index   int
+	encoded []byte
+	result  chan *DecodeResult
+}
+
+// Decode decodes the encoded objects which arrive on in until it is closed,
+// and sends a result for each of them on the returned channel, which is
+// closed once every result has been sent. Closing cancel stops decoding
+// early, in which case some results are not sent.
+func (d *Decoder) Decode(in <-chan []byte, cancel <-chan struct{}) <-chan *DecodeResult {
+	out := make(chan *DecodeResult, d.workers)
+	jobs := make(chan *job)
+
+	// pending holds the result channels of the jobs in the order in which
+	// they arrived, so that results can be sent in order. Its capacity
+	// limits the number of results which can wait for an earlier one.
+	var pending chan chan *DecodeResult
+	if d.ordered {
+		pending = make(chan chan *DecodeResult, 2*d.workers)
+	}
+
+	// Read the input.
+	go func() {
+		defer close(jobs)
+		if pending != nil {
+			defer close(pending)
+		}
+
+		for index := 0; ; index++ {
+			var encoded []byte
+			var ok bool
+			select {
+			case encoded, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-cancel:
+				return
+			}
+
+			j := &job{index: index, encoded: encoded}
+			if pending != nil {
+				j.result = make(chan *DecodeResult, 1)
+				select {
+				case pending <- j.result:
+				case <-cancel:
+					return
+				}
+			}
+
+			select {
+			case jobs <- j:
+			case <-cancel:
+				return
+			}
+		}
+	}()
+
+	// send sends a result unless decoding has been cancelled.
+	send := func(result *DecodeResult) bool {
+		select {
+		case out <- result:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(d.workers)
+	for i := 0; i < d.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result := d.decode(j.index, j.encoded)
+				if j.result != nil {
+					j.result <- result
+				} else if !send(result) {
+					return
+				}
+			}
+		}()
+	}
+
+	if pending == nil {
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		return out
+	}
+
+	// Send the results in order.
+	go func() {
+		defer close(out)
+		for result := range pending {
+			select {
+			case r := <-result:
+				if !send(r) {
+					return
+				}
+			case <-cancel:
+				return
+			}
+		}
+	}()
+	return out
+}
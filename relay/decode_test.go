@@ -0,0 +1,128 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// testPow is an easy proof of work for tests.
+var testPow = pow.Data{NonceTrialsPerByte: 1, ExtraBytes: 1}
+
+// encodeWithPow returns an encoded object with the given payload, with
+// proof of work done for testPow at time now.
+func encodeWithPow(now time.Time, payload byte) []byte {
+	obj := wire.NewMsgObject(wire.NewObjectHeader(0, now.Add(time.Hour),
+		wire.ObjectTypeMsg, 1, 1), []byte{payload})
+	encoded := wire.Encode(obj)
+	target := pow.CalculateTarget(uint64(len(encoded)), 3600, testPow)
+	obj.Header().Nonce = pow.DoSequential(target, hash.Sha512(encoded[8:]))
+	return wire.Encode(obj)
+}
+
+// TestDecoder tests decoding objects in order and out of order.
+func TestDecoder(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	const count = 100
+
+	objects := make([][]byte, count)
+	for i := range objects {
+		objects[i] = encodeWithPow(now, byte(i))
+	}
+	// An object without enough proof of work.
+	objects[10] = wire.Encode(wire.NewMsgObject(wire.NewObjectHeader(0,
+		now.Add(time.Hour), wire.ObjectTypeMsg, 1, 1),
+		make([]byte, 10000)))
+	// An object which cannot be decoded.
+	objects[20] = []byte{1, 2, 3}
+
+	for _, ordered := range []bool{true, false} {
+		d := relay.NewDecoder(&relay.DecoderConfig{
+			Workers: 4,
+			Pow:     testPow,
+			Ordered: ordered,
+			Clock:   clock.NewManual(now),
+		})
+
+		in := make(chan []byte)
+		go func() {
+			for _, obj := range objects {
+				in <- obj
+			}
+			close(in)
+		}()
+
+		seen := make(map[int]bool)
+		next := 0
+		for result := range d.Decode(in, nil) {
+			if ordered && result.Index != next {
+				t.Errorf("ordered: got result %d want %d", result.Index,
+					next)
+			}
+			next++
+			seen[result.Index] = true
+
+			if !result.InvHash.IsEqual(
+				hash.InventoryHash(objects[result.Index])) {
+				t.Errorf("ordered %v: wrong hash for result %d", ordered,
+					result.Index)
+			}
+
+			var wantErr error
+			switch result.Index {
+			case 10:
+				wantErr = relay.ErrInsufficientPow
+			case 20:
+				if result.Err == nil || result.Object != nil {
+					t.Errorf("ordered %v: got %v, %v for an invalid "+
+						"object", ordered, result.Object, result.Err)
+				}
+				continue
+			}
+			if result.Err != wantErr {
+				t.Errorf("ordered %v: result %d got error %v want %v",
+					ordered, result.Index, result.Err, wantErr)
+			}
+		}
+		if len(seen) != count {
+			t.Errorf("ordered %v: got %d results want %d", ordered,
+				len(seen), count)
+		}
+	}
+}
+
+// TestDecoderCancel tests that cancelling decoding closes the results.
+func TestDecoderCancel(t *testing.T) {
+	for _, ordered := range []bool{true, false} {
+		d := relay.NewDecoder(&relay.DecoderConfig{
+			Workers: 2,
+			Ordered: ordered,
+		})
+
+		in := make(chan []byte)
+		cancel := make(chan struct{})
+		out := d.Decode(in, cancel)
+		in <- []byte{1, 2, 3}
+		close(cancel)
+
+		timeout := time.After(5 * time.Second)
+		for done := false; !done; {
+			select {
+			case _, ok := <-out:
+				done = !ok
+			case <-timeout:
+				t.Fatalf("ordered %v: results not closed after cancel",
+					ordered)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package relay schedules the announcement of inventory to peers.
+
+Announcing every object to every peer as soon as it arrives would let an
+observer who is connected to many nodes work out where an object entered
+the network. Instead, each peer has a Trickler which collects the inventory
+vectors that are to be announced to it and sends them in a single inv
+message after a random delay. The Scheduler keeps a Trickler for each
+connected peer and relays new inventory to all peers except the one that it
+came from.
+
+Router encapsulates the rules for forwarding objects, inventory and
+addresses between streams.
+
+Filter is a probabilistic set of inventory vectors with bounded memory,
+which a busy node can use to drop announcements of objects that it has
+already seen.
+
+Downloader keeps track of the objects which have been requested with
+getdata. Each object is requested from one peer at a time and requested
+again from another peer which announced it if the first does not send it
+in time or disconnects.
+
+Decoder decodes objects and checks their proof of work across a fixed number
+of goroutines, delivering the results either in order or as soon as each is
+ready. It is meant for catching up on a large number of queued objects.
+
+Validator checks objects against a sequence of rules before they are
+accepted into a store. The standard rules cover size, stream, expiration,
+proof of work and signature encoding, and applications can insert rules of
+their own between them. Each rule keeps counts of the objects which passed
+and failed it. NewNetValidator takes the proof of work and the tolerance for
+objects which appear to come from the future from the parameters of a
+network.
+*/
+package relay
@@ -0,0 +1,327 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultRequestTimeout is the time that a peer is given to send an
+	// object that has been requested from it.
+	DefaultRequestTimeout = 2 * time.Minute
+
+	// DefaultMaxInFlight is the number of objects which may be requested
+	// from one peer at a time.
+	DefaultMaxInFlight = 1000
+)
+
+// DownloaderConfig is the configuration of a Downloader. Zero values are
+// replaced by the defaults.
+type DownloaderConfig struct {
+	// Timeout is the time that a peer is given to send an object that
+	// has been requested from it. The default is DefaultRequestTimeout.
+	Timeout time.Duration
+
+	// MaxInFlight is the number of objects which may be requested from
+	// one peer at a time. The default is DefaultMaxInFlight.
+	MaxInFlight int
+
+	// Clock tells the time at which objects are requested and time out.
+	// The default is clock.System.
+	Clock clock.Clock
+}
+
+// download is an object which has been announced to us and not yet
+// received.
+type download struct {
+	// sources are the peers which have announced the object, in the
+	// order in which they announced it.
+	sources []*peer.Peer
+
+	// tried are the peers from which the object has been requested.
+	tried map[*peer.Peer]struct{}
+
+	// from is the peer from which the object has been requested and at
+	// is when. From is nil if the object is waiting to be requested.
+	from *peer.Peer
+	at   time.Time
+}
+
+// Downloader keeps track of the objects which have been requested from
+// each peer. Each object is requested from only one peer at a time, and is
+// requested from another peer which has announced it if the first does not
+// send it in time or disconnects.
+type Downloader struct {
+	timeout     time.Duration
+	maxInFlight int
+	now         func() time.Time
+
+	mtx       sync.Mutex
+	downloads map[hash.Sha]*download
+	waiting   map[hash.Sha]struct{}
+	inFlight  map[*peer.Peer]int
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDownloader returns a Downloader. cfg may be nil, in which case the
+// defaults are used.
+func NewDownloader(cfg *DownloaderConfig) *Downloader {
+	if cfg == nil {
+		cfg = &DownloaderConfig{}
+	}
+
+	d := &Downloader{
+		timeout:     cfg.Timeout,
+		maxInFlight: cfg.MaxInFlight,
+		now:         clock.Or(cfg.Clock).Now,
+		downloads:   make(map[hash.Sha]*download),
+		waiting:     make(map[hash.Sha]struct{}),
+		inFlight:    make(map[*peer.Peer]int),
+	}
+	if d.timeout == 0 {
+		d.timeout = DefaultRequestTimeout
+	}
+	if d.maxInFlight == 0 {
+		d.maxInFlight = DefaultMaxInFlight
+	}
+	return d
+}
+
+// AddPeer adds a peer from which objects may be requested. The peer is
+// removed when it disconnects.
+func (d *Downloader) AddPeer(p *peer.Peer) {
+	d.mtx.Lock()
+	if _, ok := d.inFlight[p]; ok {
+		d.mtx.Unlock()
+		return
+	}
+	d.inFlight[p] = 0
+	d.mtx.Unlock()
+
+	go func() {
+		<-p.Done()
+		d.RemovePeer(p)
+	}()
+}
+
+// RemovePeer removes a peer. The objects which were requested from it are
+// requested from other peers.
+func (d *Downloader) RemovePeer(p *peer.Peer) {
+	d.mtx.Lock()
+	if _, ok := d.inFlight[p]; !ok {
+		d.mtx.Unlock()
+		return
+	}
+	delete(d.inFlight, p)
+
+	for h, dl := range d.downloads {
+		for i, s := range dl.sources {
+			if s == p {
+				dl.sources = append(dl.sources[:i], dl.sources[i+1:]...)
+				break
+			}
+		}
+		if dl.from == p {
+			dl.from = nil
+			d.waiting[h] = struct{}{}
+		}
+		d.retire(h, dl)
+	}
+	requests := d.dispatch()
+	d.mtx.Unlock()
+
+	send(requests)
+}
+
+// Announced records that a peer has announced the given inventory and
+// requests any of it that has not already been requested. The peer must
+// have been added. The caller should leave out inventory for objects that
+// it already has.
+func (d *Downloader) Announced(p *peer.Peer, ivs ...*wire.InvVect) {
+	d.mtx.Lock()
+	if _, ok := d.inFlight[p]; !ok {
+		d.mtx.Unlock()
+		return
+	}
+
+	for _, iv := range ivs {
+		h := hash.Sha(*iv)
+		dl, ok := d.downloads[h]
+		if !ok {
+			dl = &download{tried: make(map[*peer.Peer]struct{})}
+			d.downloads[h] = dl
+			d.waiting[h] = struct{}{}
+		}
+
+		known := false
+		for _, s := range dl.sources {
+			if s == p {
+				known = true
+				break
+			}
+		}
+		if !known {
+			dl.sources = append(dl.sources, p)
+		}
+	}
+	requests := d.dispatch()
+	d.mtx.Unlock()
+
+	send(requests)
+}
+
+// Received records that an object has arrived and returns whether it had
+// been requested, so that the caller may reject objects which were not.
+func (d *Downloader) Received(iv *wire.InvVect) bool {
+	d.mtx.Lock()
+	h := hash.Sha(*iv)
+	dl, ok := d.downloads[h]
+	if !ok {
+		d.mtx.Unlock()
+		return false
+	}
+
+	requested := dl.from != nil
+	if requested {
+		d.inFlight[dl.from]--
+	}
+	delete(d.downloads, h)
+	delete(d.waiting, h)
+
+	// The peer has room for another request.
+	requests := d.dispatch()
+	d.mtx.Unlock()
+
+	send(requests)
+	return requested
+}
+
+// Pending returns the number of objects which have been announced but not
+// yet received.
+func (d *Downloader) Pending() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return len(d.downloads)
+}
+
+// InFlight returns the number of objects which have been requested from a
+// peer and not yet received.
+func (d *Downloader) InFlight(p *peer.Peer) int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.inFlight[p]
+}
+
+// retire removes a download which is waiting and which no remaining peer
+// can provide, so that it is requested afresh when it is announced again.
+// The mutex must be held.
+func (d *Downloader) retire(h hash.Sha, dl *download) {
+	if dl.from != nil {
+		return
+	}
+	for _, s := range dl.sources {
+		if _, ok := dl.tried[s]; !ok {
+			return
+		}
+	}
+	delete(d.downloads, h)
+	delete(d.waiting, h)
+}
+
+// dispatch assigns waiting objects to peers which have announced them, have
+// not already failed to send them and have room for more requests. It
+// returns the requests to be sent. The mutex must be held.
+func (d *Downloader) dispatch() map[*peer.Peer][]*wire.InvVect {
+	requests := make(map[*peer.Peer][]*wire.InvVect)
+	now := d.now()
+	for h := range d.waiting {
+		dl := d.downloads[h]
+		for _, s := range dl.sources {
+			if _, ok := dl.tried[s]; ok || d.inFlight[s] >= d.maxInFlight {
+				continue
+			}
+
+			dl.from = s
+			dl.at = now
+			dl.tried[s] = struct{}{}
+			d.inFlight[s]++
+			delete(d.waiting, h)
+
+			iv := wire.InvVect(h)
+			requests[s] = append(requests[s], &iv)
+			break
+		}
+	}
+	return requests
+}
+
+// send sends getdata messages for the requests.
+func send(requests map[*peer.Peer][]*wire.InvVect) {
+	for p, ivs := range requests {
+		msg := wire.NewMsgGetData()
+		for _, iv := range ivs {
+			if msg.AddInvVect(iv) != nil {
+				p.QueueMessage(msg)
+				msg = wire.NewMsgGetData()
+				msg.AddInvVect(iv)
+			}
+		}
+		p.QueueMessage(msg)
+	}
+}
+
+// expire requests objects again from other peers if the peers from which
+// they were requested have not sent them in time.
+func (d *Downloader) expire() {
+	d.mtx.Lock()
+	now := d.now()
+	for h, dl := range d.downloads {
+		if dl.from == nil || now.Sub(dl.at) < d.timeout {
+			continue
+		}
+		d.inFlight[dl.from]--
+		dl.from = nil
+		d.waiting[h] = struct{}{}
+		d.retire(h, dl)
+	}
+	requests := d.dispatch()
+	d.mtx.Unlock()
+
+	send(requests)
+}
+
+// Start begins checking for requests which have timed out.
+func (d *Downloader) Start() {
+	d.quit = make(chan struct{})
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.timeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.expire()
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking for requests which have timed out.
+func (d *Downloader) Stop() {
+	close(d.quit)
+	d.wg.Wait()
+}
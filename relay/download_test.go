@@ -0,0 +1,146 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// readGetData reads a getdata message from the remote side of a peer.
+func readGetData(t *testing.T, c net.Conn) []*wire.InvVect {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	defer c.SetReadDeadline(time.Time{})
+
+	msg, _, err := wire.ReadMessage(c, wire.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	getData, ok := msg.(*wire.MsgGetData)
+	if !ok {
+		t.Fatalf("ReadMessage: got %T want *wire.MsgGetData", msg)
+	}
+	return getData.InvList
+}
+
+// expectNothing checks that nothing is sent to the remote side of a peer.
+func expectNothing(t *testing.T, c net.Conn) {
+	c.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	defer c.SetReadDeadline(time.Time{})
+
+	if msg, _, err := wire.ReadMessage(c, wire.MainNet); err == nil {
+		t.Errorf("unexpected %s message", msg.Command())
+	}
+}
+
+// checkRequested checks that exactly the given inventory was requested.
+func checkRequested(t *testing.T, got []*wire.InvVect, want ...*wire.InvVect) {
+	if len(got) != len(want) {
+		t.Errorf("requested %d objects want %d", len(got), len(want))
+		return
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if *g == *w {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%v was not requested", w)
+		}
+	}
+}
+
+// TestDownloader tests that objects are requested once, and again from
+// other peers when a request times out or a peer disconnects.
+func TestDownloader(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := relay.NewDownloader(&relay.DownloaderConfig{
+		Timeout: time.Minute,
+		Clock:   clock.Func(func() time.Time { return now }),
+	})
+
+	p1, c1 := newPeer(t)
+	p2, c2 := newPeer(t)
+	defer p2.Disconnect(nil)
+	d.AddPeer(p1)
+	d.AddPeer(p2)
+
+	d.Announced(p1, newInvVect(1), newInvVect(2), newInvVect(3))
+	checkRequested(t, readGetData(t, c1), newInvVect(1), newInvVect(2),
+		newInvVect(3))
+
+	// The objects have already been requested.
+	d.Announced(p2, newInvVect(1), newInvVect(2), newInvVect(3))
+	expectNothing(t, c2)
+	if n := d.InFlight(p1); n != 3 {
+		t.Errorf("InFlight: got %d want %d", n, 3)
+	}
+
+	if !d.Received(newInvVect(1)) {
+		t.Error("Received: got false want true")
+	}
+	if d.Received(newInvVect(1)) {
+		t.Error("Received: got true want false for an object received twice")
+	}
+
+	// Object 2 arrives in time, but object 3 does not.
+	now = now.Add(30 * time.Second)
+	d.Received(newInvVect(2))
+	now = now.Add(31 * time.Second)
+	relay.TstExpire(d)
+	checkRequested(t, readGetData(t, c2), newInvVect(3))
+	if n := d.InFlight(p1); n != 0 {
+		t.Errorf("InFlight: got %d want %d", n, 0)
+	}
+
+	// When a peer disconnects, its requests go to another peer.
+	d.Announced(p2, newInvVect(4))
+	checkRequested(t, readGetData(t, c2), newInvVect(4))
+	d.Announced(p1, newInvVect(4))
+	c2.Close()
+	checkRequested(t, readGetData(t, c1), newInvVect(4))
+
+	// Object 3 has been tried from both peers, so it is given up.
+	if n := d.Pending(); n != 1 {
+		t.Errorf("Pending: got %d want %d", n, 1)
+	}
+	p1.Disconnect(nil)
+}
+
+// TestDownloaderMaxInFlight tests that no more than the maximum number of
+// objects are requested from a peer at once.
+func TestDownloaderMaxInFlight(t *testing.T) {
+	d := relay.NewDownloader(&relay.DownloaderConfig{MaxInFlight: 2})
+	p, c := newPeer(t)
+	defer p.Disconnect(nil)
+	d.AddPeer(p)
+
+	d.Announced(p, newInvVect(1), newInvVect(2), newInvVect(3))
+	requested := readGetData(t, c)
+	if len(requested) != 2 {
+		t.Fatalf("requested %d objects want %d", len(requested), 2)
+	}
+	if n := d.Pending(); n != 3 {
+		t.Errorf("Pending: got %d want %d", n, 3)
+	}
+
+	// The third object is requested when there is room for it.
+	var rest *wire.InvVect
+	for i := 1; i <= 3; i++ {
+		iv := newInvVect(i)
+		if *iv != *requested[0] && *iv != *requested[1] {
+			rest = iv
+		}
+	}
+	d.Received(requested[0])
+	checkRequested(t, readGetData(t, c), rest)
+}
@@ -0,0 +1,166 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ErrInvalidFilter is returned by NewFilter for a capacity below 1 or a false
+// positive rate which is not between 0 and 1.
+var ErrInvalidFilter = errors.New("invalid filter parameters")
+
+// bloom is a bloom filter of inventory vectors.
+type bloom struct {
+	bits  []uint64
+	count int
+}
+
+// Filter is a probabilistic set of inventory vectors which uses a bounded
+// amount of memory. It holds two bloom filters. Vectors are added to the
+// current one, and once it holds as many vectors as the filter's capacity
+// it replaces the previous one, which is discarded. A vector is therefore
+// remembered for at least capacity further additions.
+//
+// Contains may return true for a vector that was never added, with
+// probability of about the false positive rate given to NewFilter for each
+// of the two bloom filters, but never returns false for one that was added
+// within the last capacity additions.
+type Filter struct {
+	capacity int
+	m        uint64 // number of bits in each bloom filter
+	k        int    // number of hash functions
+	key      [32]byte
+
+	mtx      sync.Mutex
+	current  *bloom
+	previous *bloom
+}
+
+// NewFilter returns a filter which remembers at least capacity vectors with
+// the given false positive rate, which must be between 0 and 1 exclusive.
+func NewFilter(capacity int, fpRate float64) (*Filter, error) {
+	if capacity < 1 || !(fpRate > 0 && fpRate < 1) {
+		return nil, ErrInvalidFilter
+	}
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	if m < 1 {
+		m = 1
+	}
+	k := int(math.Ceil(m / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	f := &Filter{
+		capacity: capacity,
+		m:        uint64(m),
+		k:        k,
+	}
+
+	// The vectors are keyed so that nobody can choose objects whose
+	// vectors all set the same bits.
+	io.ReadFull(rand.Reader, f.key[:])
+	f.current = f.newBloom()
+	f.previous = f.newBloom()
+	return f, nil
+}
+
+func (f *Filter) newBloom() *bloom {
+	return &bloom{bits: make([]uint64, (f.m+63)/64)}
+}
+
+// indices returns the k bit positions of a vector, using double hashing
+// of two 64-bit words of the keyed vector.
+func (f *Filter) indices(iv *wire.InvVect) []uint64 {
+	var x [16]byte
+	for i := range x {
+		x[i] = iv[i] ^ f.key[i]
+	}
+	h1 := binary.LittleEndian.Uint64(x[:8])
+	h2 := binary.LittleEndian.Uint64(x[8:]) ^ binary.LittleEndian.Uint64(f.key[16:24])
+
+	idx := make([]uint64, f.k)
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return idx
+}
+
+func (b *bloom) contains(idx []uint64) bool {
+	for _, i := range idx {
+		if b.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloom) add(idx []uint64) {
+	for _, i := range idx {
+		b.bits[i/64] |= 1 << (i % 64)
+	}
+	b.count++
+}
+
+// Contains returns whether the vector has probably been added to the
+// filter.
+func (f *Filter) Contains(iv *wire.InvVect) bool {
+	idx := f.indices(iv)
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.current.contains(idx) || f.previous.contains(idx)
+}
+
+// Add adds the vector to the filter.
+func (f *Filter) Add(iv *wire.InvVect) {
+	idx := f.indices(iv)
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.add(idx)
+}
+
+func (f *Filter) add(idx []uint64) {
+	if f.current.count >= f.capacity {
+		f.previous = f.current
+		f.current = f.newBloom()
+	}
+	f.current.add(idx)
+}
+
+// Seen adds the vector to the filter and returns whether it was probably
+// already there. It is convenient for dropping duplicate announcements.
+func (f *Filter) Seen(iv *wire.InvVect) bool {
+	idx := f.indices(iv)
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.current.contains(idx) {
+		return true
+	}
+	seen := f.previous.contains(idx)
+
+	// Vectors only in the previous filter are added again so that
+	// frequently seen vectors are not forgotten.
+	f.add(idx)
+	return seen
+}
+
+// Reset empties the filter.
+func (f *Filter) Reset() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.current = f.newBloom()
+	f.previous = f.newBloom()
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// randomInvVect returns an inventory vector which looks like a real hash.
+func randomInvVect(i int) *wire.InvVect {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(i))
+	iv := wire.InvVect(sha256.Sum256(b[:]))
+	return &iv
+}
+
+// TestFilter tests adding to and querying a filter.
+func TestFilter(t *testing.T) {
+	f, err := relay.NewFilter(1000, 0.001)
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if f.Seen(randomInvVect(i)) {
+			// A false positive is possible but should be rare.
+			t.Logf("false positive for vector %d", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.Contains(randomInvVect(i)) {
+			t.Fatalf("vector %d was forgotten", i)
+		}
+		if !f.Seen(randomInvVect(i)) {
+			t.Fatalf("Seen: vector %d was forgotten", i)
+		}
+	}
+
+	// Check the false positive rate over vectors never added. Two bloom
+	// filters are checked, so allow twice the rate and some slack.
+	fp := 0
+	for i := 1000; i < 101000; i++ {
+		if f.Contains(randomInvVect(i)) {
+			fp++
+		}
+	}
+	if fp > 400 {
+		t.Errorf("got %d false positives in 100000 want fewer than %d", fp, 400)
+	}
+
+	f.Reset()
+	if f.Contains(randomInvVect(0)) {
+		t.Error("Reset did not empty the filter")
+	}
+}
+
+// TestFilterRotation tests that the filter remembers at least its capacity
+// of recent vectors and forgets older ones.
+func TestFilterRotation(t *testing.T) {
+	capacity := 100
+	f, err := relay.NewFilter(capacity, 0.0001)
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+
+	for i := 0; i < 10*capacity; i++ {
+		f.Add(randomInvVect(i))
+		for j := i - capacity + 1; j <= i; j++ {
+			if j >= 0 && !f.Contains(randomInvVect(j)) {
+				t.Fatalf("after adding %d, vector %d was forgotten", i, j)
+			}
+		}
+	}
+
+	forgotten := 0
+	for i := 0; i < 5*capacity; i++ {
+		if !f.Contains(randomInvVect(i)) {
+			forgotten++
+		}
+	}
+	if forgotten < 4*capacity {
+		t.Errorf("got %d of the oldest vectors forgotten want at least %d",
+			forgotten, 4*capacity)
+	}
+}
+
+// TestNewFilterInvalid tests that NewFilter rejects parameters which would
+// not give a usable filter.
+func TestNewFilterInvalid(t *testing.T) {
+	tests := []struct {
+		capacity int
+		fpRate   float64
+	}{
+		{0, 0.01},
+		{-1, 0.01},
+		{100, 0},
+		{100, -0.5},
+		{100, 1},
+		{100, 2},
+		{100, math.NaN()},
+	}
+	for _, test := range tests {
+		if _, err := relay.NewFilter(test.capacity, test.fpRate); err != relay.ErrInvalidFilter {
+			t.Errorf("NewFilter(%d, %v): got %v want %v", test.capacity,
+				test.fpRate, err, relay.ErrInvalidFilter)
+		}
+	}
+
+	// A rate close to 1 still gives at least one bit and hash function.
+	f, err := relay.NewFilter(1, 0.999999)
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	f.Add(randomInvVect(0))
+	if !f.Contains(randomInvVect(0)) {
+		t.Error("vector was forgotten")
+	}
+}
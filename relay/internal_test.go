@@ -0,0 +1,17 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the relay package rather than than the relay_test
+package so it can bridge access to the internals to properly test cases
+which are either not possible or can't reliably be tested via the public
+interface. The functions are only exported while the tests are being run.
+*/
+
+package relay
+
+// TstExpire requests objects again if their requests have timed out.
+func TstExpire(d *Downloader) {
+	d.expire()
+}
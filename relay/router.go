@@ -0,0 +1,130 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// streamSet is a set of stream numbers.
+type streamSet map[uint32]struct{}
+
+func newStreamSet(streams []uint32) streamSet {
+	s := make(streamSet, len(streams))
+	for _, stream := range streams {
+		s[stream] = struct{}{}
+	}
+	return s
+}
+
+func (s streamSet) has(stream uint32) bool {
+	_, ok := s[stream]
+	return ok
+}
+
+// Router decides which peers objects, inventory and addresses should be
+// forwarded to, according to the streams that we serve and the streams
+// that each peer advertised in its version message.
+//
+// Objects are only relayed within their own stream, and only if we serve
+// that stream. Addresses in a stream are also of interest to nodes in the
+// parent stream, since those nodes connect to their child streams.
+type Router struct {
+	streams streamSet
+
+	mtx   sync.RWMutex
+	peers map[*peer.Peer]streamSet
+}
+
+// NewRouter returns a Router for a node which serves the given streams.
+func NewRouter(streams []uint32) *Router {
+	return &Router{
+		streams: newStreamSet(streams),
+		peers:   make(map[*peer.Peer]streamSet),
+	}
+}
+
+// AddPeer adds a peer, which will receive objects in the streams it
+// advertised.
+func (r *Router) AddPeer(p *peer.Peer) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.peers[p] = newStreamSet(p.Version().StreamNumbers)
+}
+
+// RemovePeer removes a peer.
+func (r *Router) RemovePeer(p *peer.Peer) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.peers, p)
+}
+
+// Serves returns whether we serve the stream, and therefore whether we
+// should accept and store objects in it.
+func (r *Router) Serves(stream uint64) bool {
+	return stream <= 0xffffffff && r.streams.has(uint32(stream))
+}
+
+// ObjectPeers returns the peers to which an object in the given stream
+// should be forwarded. from is the peer which sent us the object, and is
+// never included; it may be nil.
+func (r *Router) ObjectPeers(stream uint64, from *peer.Peer) []*peer.Peer {
+	if !r.Serves(stream) {
+		return nil
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	var peers []*peer.Peer
+	for p, streams := range r.peers {
+		if p != from && streams.has(uint32(stream)) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// AddrPeers returns the peers to which addresses in the given stream
+// should be forwarded: those in the stream and those in its parent
+// stream.
+func (r *Router) AddrPeers(stream uint32, from *peer.Peer) []*peer.Peer {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	parent, hasParent := wire.StreamParent(stream)
+	var peers []*peer.Peer
+	for p, streams := range r.peers {
+		if p == from {
+			continue
+		}
+		if streams.has(stream) || (hasParent && streams.has(parent)) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// RouteInv sorts inventory vectors by the peers they should be announced
+// to. streamOf returns the stream of the object with the given vector, and
+// false if the object is unknown, in which case it is not announced.
+func (r *Router) RouteInv(ivs []*wire.InvVect, from *peer.Peer,
+	streamOf func(*wire.InvVect) (uint64, bool)) map[*peer.Peer][]*wire.InvVect {
+
+	routes := make(map[*peer.Peer][]*wire.InvVect)
+	for _, iv := range ivs {
+		stream, ok := streamOf(iv)
+		if !ok {
+			continue
+		}
+		for _, p := range r.ObjectPeers(stream, from) {
+			routes[p] = append(routes[p], iv)
+		}
+	}
+	return routes
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// hasPeers returns whether got holds exactly the peers in want.
+func hasPeers(got []*peer.Peer, want ...*peer.Peer) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	set := make(map[*peer.Peer]bool)
+	for _, p := range got {
+		set[p] = true
+	}
+	for _, p := range want {
+		if !set[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestRouter tests the forwarding rules of Router.
+func TestRouter(t *testing.T) {
+	r := relay.NewRouter([]uint32{1, 2})
+
+	// Version messages currently carry a single stream.
+	p1, _ := newStreamPeer(t, []uint32{1}, []uint32{1})
+	p2, _ := newStreamPeer(t, []uint32{2}, []uint32{2})
+	p12, _ := newStreamPeer(t, []uint32{1}, []uint32{1})
+	for _, p := range []*peer.Peer{p1, p2, p12} {
+		defer p.Disconnect(nil)
+		r.AddPeer(p)
+	}
+
+	if !r.Serves(1) || !r.Serves(2) || r.Serves(3) || r.Serves(1<<32+1) {
+		t.Error("Serves gave the wrong answer")
+	}
+
+	if got := r.ObjectPeers(1, nil); !hasPeers(got, p1, p12) {
+		t.Errorf("ObjectPeers(1): got %v", got)
+	}
+	if got := r.ObjectPeers(1, p1); !hasPeers(got, p12) {
+		t.Errorf("ObjectPeers(1, p1): got %v", got)
+	}
+	if got := r.ObjectPeers(2, nil); !hasPeers(got, p2) {
+		t.Errorf("ObjectPeers(2): got %v", got)
+	}
+	if got := r.ObjectPeers(3, nil); len(got) != 0 {
+		t.Errorf("ObjectPeers(3): got %v want none", got)
+	}
+
+	// Stream 1 is the parent of streams 2 and 3, and stream 2 is the
+	// parent of streams 4 and 5.
+	if got := r.AddrPeers(3, nil); !hasPeers(got, p1, p12) {
+		t.Errorf("AddrPeers(3): got %v", got)
+	}
+	if got := r.AddrPeers(2, nil); !hasPeers(got, p1, p2, p12) {
+		t.Errorf("AddrPeers(2): got %v", got)
+	}
+	if got := r.AddrPeers(5, p2); len(got) != 0 {
+		t.Errorf("AddrPeers(5, p2): got %v want none", got)
+	}
+	if got := r.AddrPeers(1, nil); !hasPeers(got, p1, p12) {
+		t.Errorf("AddrPeers(1): got %v", got)
+	}
+
+	streams := map[wire.InvVect]uint64{
+		*newInvVect(1): 1,
+		*newInvVect(2): 2,
+	}
+	routes := r.RouteInv([]*wire.InvVect{newInvVect(1), newInvVect(2),
+		newInvVect(3)}, p12, func(iv *wire.InvVect) (uint64, bool) {
+		s, ok := streams[*iv]
+		return s, ok
+	})
+	if len(routes) != 2 || len(routes[p1]) != 1 || len(routes[p2]) != 1 ||
+		*routes[p1][0] != *newInvVect(1) || *routes[p2][0] != *newInvVect(2) {
+		t.Errorf("RouteInv: got %v", routes)
+	}
+
+	r.RemovePeer(p1)
+	if got := r.ObjectPeers(1, nil); !hasPeers(got, p12) {
+		t.Errorf("ObjectPeers(1) after RemovePeer: got %v", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Scheduler relays inventory to a set of peers, each through its own
+// Trickler.
+type Scheduler struct {
+	interval time.Duration
+
+	mtx   sync.Mutex
+	peers map[*peer.Peer]*Trickler
+}
+
+// NewScheduler returns a Scheduler whose Tricklers announce inventory on
+// average once per interval.
+func NewScheduler(interval time.Duration) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		peers:    make(map[*peer.Peer]*Trickler),
+	}
+}
+
+// AddPeer starts relaying inventory to p. The peer's Trickler is stopped
+// when p is disconnected.
+func (s *Scheduler) AddPeer(p *peer.Peer) {
+	t := NewTrickler(s.interval, func(msg *wire.MsgInv) {
+		p.QueueMessage(msg)
+	})
+
+	s.mtx.Lock()
+	if _, ok := s.peers[p]; ok {
+		s.mtx.Unlock()
+		return
+	}
+	s.peers[p] = t
+	s.mtx.Unlock()
+
+	t.Start()
+	go func() {
+		<-p.Done()
+		s.RemovePeer(p)
+	}()
+}
+
+// RemovePeer stops relaying inventory to p.
+func (s *Scheduler) RemovePeer(p *peer.Peer) {
+	s.mtx.Lock()
+	t, ok := s.peers[p]
+	delete(s.peers, p)
+	s.mtx.Unlock()
+
+	if ok {
+		t.Stop()
+	}
+}
+
+// Announce queues inventory vectors to be announced to every peer except
+// from, which is the peer that they were learned from. from may be nil if
+// the inventory originated locally.
+func (s *Scheduler) Announce(from *peer.Peer, ivs ...*wire.InvVect) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for p, t := range s.peers {
+		if p != from {
+			t.Add(ivs...)
+		}
+	}
+}
+
+// Trickler returns the Trickler of p, or nil if p has not been added.
+func (s *Scheduler) Trickler(p *peer.Peer) *Trickler {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.peers[p]
+}
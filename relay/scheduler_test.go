@@ -0,0 +1,81 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newPeer returns a peer in stream 1 connected to the returned conn, over
+// which the handshake has been done by hand.
+func newPeer(t *testing.T) (*peer.Peer, net.Conn) {
+	return newStreamPeer(t, []uint32{1}, []uint32{1})
+}
+
+// newStreamPeer returns a peer which serves ours and whose remote side
+// advertises theirs.
+func newStreamPeer(t *testing.T, ours, theirs []uint32) (*peer.Peer, net.Conn) {
+	a, b := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wire.ReadMessage(b, wire.MainNet)
+		na := wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), 8444, 1, 0)
+		wire.WriteMessage(b, wire.NewMsgVersion(na, na, 1, theirs),
+			wire.MainNet)
+		wire.WriteMessage(b, &wire.MsgVerAck{}, wire.MainNet)
+		wire.ReadMessage(b, wire.MainNet)
+	}()
+
+	p, err := peer.NewOutbound(&peer.Config{Net: wire.MainNet,
+		Streams: ours}, a)
+	if err != nil {
+		t.Fatalf("NewOutbound: %v", err)
+	}
+	<-done
+	return p, b
+}
+
+// TestScheduler tests that inventory is announced to every peer except the
+// one it came from.
+func TestScheduler(t *testing.T) {
+	s := relay.NewScheduler(10 * time.Millisecond)
+
+	p1, c1 := newPeer(t)
+	p2, c2 := newPeer(t)
+	defer p1.Disconnect(nil)
+	defer p2.Disconnect(nil)
+	s.AddPeer(p1)
+	s.AddPeer(p2)
+
+	s.Announce(p1, newInvVect(1))
+	if n := s.Trickler(p1).Len(); n != 0 {
+		t.Errorf("source peer: got %d queued want %d", n, 0)
+	}
+
+	msg, _, err := wire.ReadMessage(c2, wire.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	inv, ok := msg.(*wire.MsgInv)
+	if !ok || len(inv.InvList) != 1 || *inv.InvList[0] != *newInvVect(1) {
+		t.Errorf("got %v want inv of %v", msg, newInvVect(1))
+	}
+
+	// A disconnected peer is removed.
+	c1.Close()
+	for i := 0; s.Trickler(p1) != nil; i++ {
+		if i > 100 {
+			t.Fatal("disconnected peer was not removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// DefaultTrickleInterval is the average time between announcements to a
+// peer.
+const DefaultTrickleInterval = 10 * time.Second
+
+// Trickler batches inventory vectors to be announced to a single peer.
+type Trickler struct {
+	interval time.Duration
+	onFlush  func(*wire.MsgInv)
+
+	mtx     sync.Mutex
+	pending []*wire.InvVect
+	queued  map[wire.InvVect]struct{}
+	rand    *rand.Rand
+
+	started bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewTrickler returns a Trickler which announces inventory on average once
+// per interval. onFlush is called from the goroutine started by Start with
+// each inv message to be sent; it may be nil if the caller flushes the
+// Trickler itself.
+func NewTrickler(interval time.Duration, onFlush func(*wire.MsgInv)) *Trickler {
+	if interval == 0 {
+		interval = DefaultTrickleInterval
+	}
+	return &Trickler{
+		interval: interval,
+		onFlush:  onFlush,
+		queued:   make(map[wire.InvVect]struct{}),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add queues inventory vectors for the next announcement. Vectors that are
+// already queued are ignored.
+func (t *Trickler) Add(ivs ...*wire.InvVect) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, iv := range ivs {
+		if _, ok := t.queued[*iv]; ok {
+			continue
+		}
+		t.queued[*iv] = struct{}{}
+		t.pending = append(t.pending, iv)
+	}
+}
+
+// Len returns the number of inventory vectors waiting to be announced.
+func (t *Trickler) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.pending)
+}
+
+// Flush removes the queued inventory vectors and returns them as inv
+// messages, each holding no more than wire.MaxInvPerMsg vectors. The order
+// of the vectors is randomized so that it does not reveal the order in
+// which they were learned.
+func (t *Trickler) Flush() []*wire.MsgInv {
+	t.mtx.Lock()
+	pending := t.pending
+	t.pending = nil
+	t.queued = make(map[wire.InvVect]struct{})
+	for i := range pending {
+		j := t.rand.Intn(i + 1)
+		pending[i], pending[j] = pending[j], pending[i]
+	}
+	t.mtx.Unlock()
+
+	var msgs []*wire.MsgInv
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > wire.MaxInvPerMsg {
+			n = wire.MaxInvPerMsg
+		}
+		msgs = append(msgs, &wire.MsgInv{InvList: pending[:n]})
+		pending = pending[n:]
+	}
+	return msgs
+}
+
+// NextDelay returns a random delay before the next announcement, uniformly
+// distributed between half and one and a half times the interval.
+func (t *Trickler) NextDelay() time.Duration {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.interval/2 + time.Duration(t.rand.Int63n(int64(t.interval)))
+}
+
+// Start begins flushing the Trickler to onFlush after random delays. A
+// Trickler which has been stopped may be started again.
+func (t *Trickler) Start() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.started || t.onFlush == nil {
+		return
+	}
+	t.started = true
+	t.quit = make(chan struct{})
+
+	t.wg.Add(1)
+	go t.trickleHandler(t.quit)
+}
+
+// Stop stops the Trickler. Inventory which has not been announced is
+// discarded.
+func (t *Trickler) Stop() {
+	t.mtx.Lock()
+	started := t.started
+	t.started = false
+	quit := t.quit
+	t.mtx.Unlock()
+	if !started {
+		return
+	}
+
+	close(quit)
+	t.wg.Wait()
+}
+
+// trickleHandler flushes the Trickler after random delays until quit is
+// closed. It must be run as a goroutine.
+func (t *Trickler) trickleHandler(quit chan struct{}) {
+	defer t.wg.Done()
+
+	timer := time.NewTimer(t.NextDelay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			for _, msg := range t.Flush() {
+				t.onFlush(msg)
+			}
+			timer.Reset(t.NextDelay())
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newInvVect returns a distinct inventory vector for each i.
+func newInvVect(i int) *wire.InvVect {
+	var iv wire.InvVect
+	binary.BigEndian.PutUint32(iv[:], uint32(i))
+	return &iv
+}
+
+// TestTricklerFlush tests that queued vectors are deduplicated and split
+// into messages of at most MaxInvPerMsg vectors.
+func TestTricklerFlush(t *testing.T) {
+	tr := relay.NewTrickler(time.Second, nil)
+
+	tr.Add(newInvVect(1), newInvVect(2))
+	tr.Add(newInvVect(1))
+	if n := tr.Len(); n != 2 {
+		t.Errorf("Len: got %d want %d", n, 2)
+	}
+	msgs := tr.Flush()
+	if len(msgs) != 1 || len(msgs[0].InvList) != 2 {
+		t.Fatalf("Flush: got %v", msgs)
+	}
+	if n := tr.Len(); n != 0 {
+		t.Errorf("Len after Flush: got %d want %d", n, 0)
+	}
+	if msgs = tr.Flush(); len(msgs) != 0 {
+		t.Errorf("Flush: got %d messages want %d", len(msgs), 0)
+	}
+
+	// A vector may be queued again once it has been flushed.
+	tr.Add(newInvVect(1))
+	if n := tr.Len(); n != 1 {
+		t.Errorf("Len: got %d want %d", n, 1)
+	}
+	tr.Flush()
+
+	total := wire.MaxInvPerMsg + 10
+	for i := 0; i < total; i++ {
+		tr.Add(newInvVect(i))
+	}
+	msgs = tr.Flush()
+	if len(msgs) != 2 {
+		t.Fatalf("Flush: got %d messages want %d", len(msgs), 2)
+	}
+	if n := len(msgs[0].InvList); n != wire.MaxInvPerMsg {
+		t.Errorf("first message: got %d vectors want %d", n, wire.MaxInvPerMsg)
+	}
+	seen := make(map[wire.InvVect]bool)
+	for _, msg := range msgs {
+		for _, iv := range msg.InvList {
+			seen[*iv] = true
+		}
+	}
+	if len(seen) != total {
+		t.Errorf("got %d distinct vectors want %d", len(seen), total)
+	}
+}
+
+// TestNextDelay tests that delays are between half and one and a half
+// times the interval.
+func TestNextDelay(t *testing.T) {
+	interval := 10 * time.Second
+	tr := relay.NewTrickler(interval, nil)
+	for i := 0; i < 1000; i++ {
+		d := tr.NextDelay()
+		if d < interval/2 || d >= interval*3/2 {
+			t.Fatalf("got delay %v want between %v and %v", d,
+				interval/2, interval*3/2)
+		}
+	}
+}
+
+// TestTricklerStart tests that a started Trickler flushes to its hook.
+func TestTricklerStart(t *testing.T) {
+	flushed := make(chan *wire.MsgInv, 1)
+	tr := relay.NewTrickler(10*time.Millisecond, func(msg *wire.MsgInv) {
+		flushed <- msg
+	})
+	tr.Add(newInvVect(1))
+	tr.Start()
+	defer tr.Stop()
+
+	select {
+	case msg := <-flushed:
+		if len(msg.InvList) != 1 || *msg.InvList[0] != *newInvVect(1) {
+			t.Errorf("got %v want %v", msg.InvList, newInvVect(1))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Trickler was not flushed")
+	}
+
+	// It may be stopped and started again.
+	tr.Stop()
+	tr.Stop()
+	tr.Add(newInvVect(2))
+	tr.Start()
+	select {
+	case msg := <-flushed:
+		if len(msg.InvList) != 1 || *msg.InvList[0] != *newInvVect(2) {
+			t.Errorf("got %v want %v", msg.InvList, newInvVect(2))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restarted Trickler was not flushed")
+	}
+}
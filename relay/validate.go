@@ -0,0 +1,400 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/netparams"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+const (
+	// ExpiredTolerance is how long after its expiration an object is still
+	// accepted, to allow for clocks which are behind.
+	ExpiredTolerance = time.Hour
+
+	// FutureTolerance is how far beyond wire.MaxObjectTTL the expiration of an
+	// object may be, to allow for clocks which are ahead. It is the default
+	// when the tolerance is not given by the network parameters.
+	FutureTolerance = 3 * time.Hour
+)
+
+var (
+	// ErrExpired is returned for an object which has expired.
+	ErrExpired = errors.New("object expired")
+
+	// ErrExpirationTooFar is returned for an object which expires further
+	// in the future than wire.MaxObjectTTL allows.
+	ErrExpirationTooFar = errors.New("expiration too far in the future")
+
+	// ErrWrongStream is returned for an object in a stream which is not
+	// served.
+	ErrWrongStream = errors.New("object is not in a served stream")
+
+	// ErrTooLarge is returned for an object which is larger than allowed.
+	ErrTooLarge = errors.New("object too large")
+
+	// ErrNonCanonicalSignature is returned for an object with a signature
+	// which is not in canonical DER encoding.
+	ErrNonCanonicalSignature = errors.New("signature is not canonical")
+
+	// ErrNoSuchRule is returned when a rule is inserted before one which is
+	// not in the Validator.
+	ErrNoSuchRule = errors.New("no such rule")
+)
+
+// Rule is one stage of a Validator.
+type Rule interface {
+	// Name identifies the rule in errors and statistics.
+	Name() string
+
+	// Check returns nil if the object passes the rule.
+	Check(msg *wire.MsgObject, now time.Time) error
+}
+
+// funcRule is a Rule made from a function.
+type funcRule struct {
+	name  string
+	check func(*wire.MsgObject, time.Time) error
+}
+
+func (r *funcRule) Name() string {
+	return r.name
+}
+
+func (r *funcRule) Check(msg *wire.MsgObject, now time.Time) error {
+	return r.check(msg, now)
+}
+
+// NewRule returns a Rule with the given name which checks objects with the
+// given function. It lets an application add its own rules to a Validator.
+func NewRule(name string, check func(msg *wire.MsgObject, now time.Time) error) Rule {
+	return &funcRule{name: name, check: check}
+}
+
+// PowRule returns a Rule which requires the proof of work of an object to
+// satisfy the given parameters. It fails with ErrInsufficientPow.
+func PowRule(data pow.Data) Rule {
+	return NewRule("pow", func(msg *wire.MsgObject, now time.Time) error {
+		if !msg.CheckPow(data, now) {
+			return ErrInsufficientPow
+		}
+		return nil
+	})
+}
+
+// CheckFuture returns ErrExpirationTooFar if the expiration of an object
+// implies that it was created more than tolerance after now. Since no object
+// may live longer than wire.MaxObjectTTL, an object was created no earlier than
+// its expiration less MaxObjectTTL, so an object which expires further ahead
+// than that was created by a node whose clock is ahead of ours, or was made
+// to stay on the network for longer than is allowed.
+func CheckFuture(msg *wire.MsgObject, now time.Time, tolerance time.Duration) error {
+	created := msg.Header().Expiration().Add(-wire.MaxObjectTTL)
+	if created.Sub(now) > tolerance {
+		return ErrExpirationTooFar
+	}
+	return nil
+}
+
+// ExpirationRule returns a Rule which rejects objects that have expired or
+// that expire too far in the future, with ExpiredTolerance and
+// FutureTolerance allowed for clock differences. It fails with ErrExpired or
+// ErrExpirationTooFar.
+func ExpirationRule() Rule {
+	return ToleranceRule(FutureTolerance)
+}
+
+// ToleranceRule is like ExpirationRule, but allows objects to appear to have
+// been created up to future ahead of the local clock, as checked by
+// CheckFuture. If future is zero, FutureTolerance is used.
+func ToleranceRule(future time.Duration) Rule {
+	if future == 0 {
+		future = FutureTolerance
+	}
+	return NewRule("expiration", func(msg *wire.MsgObject, now time.Time) error {
+		if now.Sub(msg.Header().Expiration()) > ExpiredTolerance {
+			return ErrExpired
+		}
+		return CheckFuture(msg, now, future)
+	})
+}
+
+// StreamRule returns a Rule which only accepts objects in the given
+// streams. It fails with ErrWrongStream.
+func StreamRule(streams []uint32) Rule {
+	set := newStreamSet(streams)
+	return NewRule("stream", func(msg *wire.MsgObject, now time.Time) error {
+		stream := msg.Header().StreamNumber
+		if stream > 0xffffffff || !set.has(uint32(stream)) {
+			return ErrWrongStream
+		}
+		return nil
+	})
+}
+
+// SizeRule returns a Rule which rejects objects whose encoding is longer
+// than max bytes. It fails with ErrTooLarge.
+func SizeRule(max int) Rule {
+	return NewRule("size", func(msg *wire.MsgObject, now time.Time) error {
+		if msg.EncodedSize() > max {
+			return ErrTooLarge
+		}
+		return nil
+	})
+}
+
+// SignatureRule returns a Rule which requires signatures that are visible
+// without decryption, which are those of unencrypted v3 pubkeys, to be in
+// canonical DER encoding. The signature itself is not verified. It fails
+// with ErrNonCanonicalSignature, or with the error from decoding the
+// object.
+func SignatureRule() Rule {
+	return NewRule("signature", func(msg *wire.MsgObject, now time.Time) error {
+		header := msg.Header()
+		if header.ObjectType != wire.ObjectTypePubKey ||
+			header.Version != obj.ExtendedPubKeyVersion {
+			return nil
+		}
+
+		o, err := obj.ReadObject(wire.Encode(msg))
+		if err != nil {
+			return err
+		}
+		pk, ok := o.(*obj.ExtendedPubKey)
+		if !ok {
+			return nil
+		}
+		if !canonicalSignature(pk.Signature) {
+			return ErrNonCanonicalSignature
+		}
+		return nil
+	})
+}
+
+// canonicalSignature returns whether sig is a DER encoded signature with
+// no padding or trailing bytes. It is canonical if it can be parsed and is
+// identical to the minimal encoding of the values it contains.
+func canonicalSignature(sig []byte) bool {
+	s, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(sig, encodeDER(s.R, s.S))
+}
+
+// encodeDER returns the minimal DER encoding of a signature.
+func encodeDER(r, s *big.Int) []byte {
+	integer := func(n *big.Int) []byte {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return append([]byte{0x02, byte(len(b))}, b...)
+	}
+
+	rb := integer(r)
+	sb := integer(s)
+	der := []byte{0x30, byte(len(rb) + len(sb))}
+	der = append(der, rb...)
+	return append(der, sb...)
+}
+
+// ValidationError is returned by a Validator for an object which fails one
+// of its rules.
+type ValidationError struct {
+	// Rule is the name of the rule which failed.
+	Rule string
+
+	// Err is the error returned by the rule.
+	Err error
+}
+
+// Error returns a human-readable string for the error. This is part of the
+// error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("rule %s failed: %v", e.Rule, e.Err)
+}
+
+// RuleStats are the statistics of one rule of a Validator.
+type RuleStats struct {
+	// Name is the name of the rule.
+	Name string
+
+	// Passed is the number of objects which passed the rule.
+	Passed uint64
+
+	// Failed is the number of objects which failed the rule.
+	Failed uint64
+
+	// Time is the total time spent checking objects against the rule.
+	Time time.Duration
+}
+
+// stage is a rule in a Validator along with its statistics.
+type stage struct {
+	rule    Rule
+	passed  uint64
+	failed  uint64
+	elapsed int64
+}
+
+// Validator checks objects against a sequence of rules before they are
+// accepted into a store. The rules are checked in order and the first one
+// which fails rejects the object, so cheap rules should come before
+// expensive ones. Statistics are kept for each rule.
+//
+// Validator is safe for concurrent use, and rules may be added while
+// objects are being validated.
+type Validator struct {
+	mtx    sync.RWMutex
+	stages []*stage
+	log    logging.Logger
+	now    func() time.Time
+}
+
+// ValidatorConfig is the configuration of a Validator.
+type ValidatorConfig struct {
+	// Logger, if not nil, is told of each object that is rejected. It may
+	// be changed later with SetLogger.
+	Logger logging.Logger
+
+	// Clock tells the time against which objects are checked. The
+	// default is clock.System.
+	Clock clock.Clock
+}
+
+// NewValidator returns a Validator which checks objects against the given
+// rules in order. cfg may be nil, in which case the defaults are used.
+func NewValidator(cfg *ValidatorConfig, rules ...Rule) *Validator {
+	v := &Validator{log: logging.Nop, now: clock.System.Now}
+	if cfg != nil {
+		v.log = logging.Or(cfg.Logger)
+		v.now = clock.Or(cfg.Clock).Now
+	}
+	for _, r := range rules {
+		v.stages = append(v.stages, &stage{rule: r})
+	}
+	return v
+}
+
+// NewDefaultValidator returns a Validator with the standard rules for a node
+// which serves the given streams. Objects are checked for size, stream,
+// expiration, proof of work with the network default difficulty, and
+// canonical signatures, in that order. cfg is as for NewValidator.
+func NewDefaultValidator(streams []uint32, cfg *ValidatorConfig) *Validator {
+	return NewValidator(cfg,
+		SizeRule(wire.MaxMessagePayload),
+		StreamRule(streams),
+		ExpirationRule(),
+		PowRule(pow.Default),
+		SignatureRule(),
+	)
+}
+
+// NewNetValidator is like NewDefaultValidator, but takes the proof of work
+// and the tolerance for objects from the future from the parameters of a
+// network.
+func NewNetValidator(params *netparams.Params, streams []uint32,
+	cfg *ValidatorConfig) *Validator {
+
+	return NewValidator(cfg,
+		SizeRule(wire.MaxMessagePayload),
+		StreamRule(streams),
+		ToleranceRule(params.FutureTolerance),
+		PowRule(params.Pow),
+		SignatureRule(),
+	)
+}
+
+// Append adds a rule which is checked after all the others.
+func (v *Validator) Append(r Rule) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.stages = append(v.stages, &stage{rule: r})
+}
+
+// SetLogger sets the Logger which is told of each object that is rejected.
+// A nil Logger discards the entries, which is the default.
+func (v *Validator) SetLogger(l logging.Logger) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.log = logging.Or(l)
+}
+
+// Insert adds a rule which is checked just before the rule with the given
+// name. It returns ErrNoSuchRule if there is no such rule.
+func (v *Validator) Insert(before string, r Rule) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for i, s := range v.stages {
+		if s.rule.Name() != before {
+			continue
+		}
+		stages := make([]*stage, 0, len(v.stages)+1)
+		stages = append(stages, v.stages[:i]...)
+		stages = append(stages, &stage{rule: r})
+		v.stages = append(stages, v.stages[i:]...)
+		return nil
+	}
+	return ErrNoSuchRule
+}
+
+// Validate checks an object against each rule in turn. It returns nil if
+// the object passes all of them, and otherwise a *ValidationError for the
+// first which fails.
+func (v *Validator) Validate(msg *wire.MsgObject) error {
+	v.mtx.RLock()
+	stages := v.stages
+	log := v.log
+	now := v.now()
+	v.mtx.RUnlock()
+
+	for _, s := range stages {
+		start := time.Now()
+		err := s.rule.Check(msg, now)
+		atomic.AddInt64(&s.elapsed, int64(time.Since(start)))
+
+		if err != nil {
+			atomic.AddUint64(&s.failed, 1)
+			log.Log(logging.Debug, "object rejected", "rule",
+				s.rule.Name(), "err", err)
+			return &ValidationError{Rule: s.rule.Name(), Err: err}
+		}
+		atomic.AddUint64(&s.passed, 1)
+	}
+	return nil
+}
+
+// Stats returns the statistics of each rule, in the order in which the
+// rules are checked.
+func (v *Validator) Stats() []RuleStats {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	stats := make([]RuleStats, len(v.stages))
+	for i, s := range v.stages {
+		stats[i] = RuleStats{
+			Name:   s.rule.Name(),
+			Passed: atomic.LoadUint64(&s.passed),
+			Failed: atomic.LoadUint64(&s.failed),
+			Time:   time.Duration(atomic.LoadInt64(&s.elapsed)),
+		}
+	}
+	return stats
+}
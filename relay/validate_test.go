@@ -0,0 +1,257 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package relay_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/logging"
+	"github.com/DanielKrawisz/bmutil/netparams"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/relay"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// extendedPubKey returns a v3 pubkey with the given signature.
+func extendedPubKey(t *testing.T, now time.Time, sig []byte) *wire.MsgObject {
+	o := obj.NewExtendedPubKey(0, now.Add(time.Hour), 1, &obj.PubKeyData{
+		Verification: &wire.PubKey{},
+		Encryption:   &wire.PubKey{},
+		Pow:          &pow.Data{NonceTrialsPerByte: 1000, ExtraBytes: 1000},
+	}, sig)
+	msg, err := wire.DecodeMsgObject(wire.Encode(o))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	return msg
+}
+
+// checkValidation checks that err is a *ValidationError for the given rule
+// and error, or nil if want is nil.
+func checkValidation(t *testing.T, name string, err error, rule string, want error) {
+	if want == nil {
+		if err != nil {
+			t.Errorf("%s: got %v want nil", name, err)
+		}
+		return
+	}
+	verr, ok := err.(*relay.ValidationError)
+	if !ok {
+		t.Errorf("%s: got %v want *ValidationError", name, err)
+		return
+	}
+	if verr.Rule != rule || verr.Err != want {
+		t.Errorf("%s: got %s, %v want %s, %v", name, verr.Rule, verr.Err,
+			rule, want)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	object := func(expiration time.Time, stream uint64, size int) *wire.MsgObject {
+		return wire.NewMsgObject(wire.NewObjectHeader(0, expiration,
+			wire.ObjectTypeMsg, 1, stream), make([]byte, size))
+	}
+	valid, err := wire.DecodeMsgObject(encodeWithPow(now, 1))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+
+	v := relay.NewValidator(&relay.ValidatorConfig{Clock: clock.NewManual(now)},
+		relay.SizeRule(1000),
+		relay.StreamRule([]uint32{1}),
+		relay.ExpirationRule(),
+		relay.PowRule(testPow),
+	)
+
+	tests := []struct {
+		name string
+		msg  *wire.MsgObject
+		rule string
+		err  error
+	}{
+		{"valid", valid, "", nil},
+		{"size", object(now.Add(time.Hour), 1, 1001), "size", relay.ErrTooLarge},
+		{"stream", object(now.Add(time.Hour), 2, 1), "stream",
+			relay.ErrWrongStream},
+		{"expired", object(now.Add(-2*time.Hour), 1, 1), "expiration",
+			relay.ErrExpired},
+		{"future", object(now.Add(wire.MaxObjectTTL+4*time.Hour), 1, 1),
+			"expiration", relay.ErrExpirationTooFar},
+		{"pow", object(now.Add(time.Hour), 1, 500), "pow",
+			relay.ErrInsufficientPow},
+	}
+
+	for _, test := range tests {
+		checkValidation(t, test.name, v.Validate(test.msg), test.rule, test.err)
+	}
+
+	stats := v.Stats()
+	want := []relay.RuleStats{
+		{Name: "size", Passed: 5, Failed: 1},
+		{Name: "stream", Passed: 4, Failed: 1},
+		{Name: "expiration", Passed: 2, Failed: 2},
+		{Name: "pow", Passed: 1, Failed: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("Stats: got %d rules want %d", len(stats), len(want))
+	}
+	for i, s := range stats {
+		s.Time = 0
+		if s != want[i] {
+			t.Errorf("Stats %d: got %+v want %+v", i, s, want[i])
+		}
+	}
+}
+
+func TestValidatorInsert(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	msg, err := wire.DecodeMsgObject(encodeWithPow(now, 1))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+
+	v := relay.NewValidator(&relay.ValidatorConfig{Clock: clock.NewManual(now)},
+		relay.ExpirationRule(), relay.PowRule(testPow))
+
+	errBlocked := errors.New("blocked")
+	blocked := relay.NewRule("blocked", func(msg *wire.MsgObject, now time.Time) error {
+		if msg.Payload()[0] == 1 {
+			return errBlocked
+		}
+		return nil
+	})
+	if err := v.Insert("pow", blocked); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	checkValidation(t, "blocked", v.Validate(msg), "blocked", errBlocked)
+
+	stats := v.Stats()
+	if stats[1].Name != "blocked" || stats[2].Passed+stats[2].Failed != 0 {
+		t.Errorf("Stats: got %+v", stats)
+	}
+
+	if err := v.Insert("missing", blocked); err != relay.ErrNoSuchRule {
+		t.Errorf("Insert: got %v want %v", err, relay.ErrNoSuchRule)
+	}
+}
+
+func TestValidatorLogger(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	msg, err := wire.DecodeMsgObject(encodeWithPow(now, 1))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+
+	var entries [][]interface{}
+	log := logging.LoggerFunc(func(level logging.Level, msg string, keyvals ...interface{}) {
+		if level != logging.Debug || msg != "object rejected" {
+			t.Errorf("Log: got %v %q", level, msg)
+		}
+		entries = append(entries, keyvals)
+	})
+	v := relay.NewValidator(&relay.ValidatorConfig{
+		Logger: log,
+		Clock:  clock.NewManual(now),
+	}, relay.PowRule(pow.Data{
+		NonceTrialsPerByte: 1 << 40,
+		ExtraBytes:         1 << 40,
+	}))
+
+	if v.Validate(msg) == nil {
+		t.Fatal("Validate: got nil want error")
+	}
+	if len(entries) != 1 || len(entries[0]) != 4 || entries[0][1] != "pow" {
+		t.Errorf("Log: got %v", entries)
+	}
+
+	v.SetLogger(nil)
+	v.Validate(msg)
+	if len(entries) != 1 {
+		t.Errorf("Log: got %d entries want 1", len(entries))
+	}
+}
+
+func TestSignatureRule(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	signature := []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01}
+	v := relay.NewValidator(nil, relay.SignatureRule())
+
+	tests := []struct {
+		name string
+		sig  []byte
+		err  error
+	}{
+		{"canonical", signature, nil},
+		{"padded", []byte{0x30, 0x07, 0x02, 0x02, 0x00, 0x01, 0x02, 0x01, 0x01},
+			relay.ErrNonCanonicalSignature},
+		{"trailing bytes", append(signature, 0), relay.ErrNonCanonicalSignature},
+		{"garbage", []byte{1, 2, 3}, relay.ErrNonCanonicalSignature},
+	}
+
+	for _, test := range tests {
+		checkValidation(t, test.name, v.Validate(extendedPubKey(t, now, test.sig)),
+			"signature", test.err)
+	}
+}
+
+func TestCheckFuture(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	object := func(expiration time.Time) *wire.MsgObject {
+		return wire.NewMsgObject(wire.NewObjectHeader(0, expiration,
+			wire.ObjectTypeMsg, 1, 1), []byte{1})
+	}
+
+	tests := []struct {
+		expiration time.Time
+		tolerance  time.Duration
+		err        error
+	}{
+		{now.Add(time.Hour), 0, nil},
+		{now.Add(wire.MaxObjectTTL), 0, nil},
+		{now.Add(wire.MaxObjectTTL + time.Second), 0, relay.ErrExpirationTooFar},
+		{now.Add(wire.MaxObjectTTL + time.Hour), time.Hour, nil},
+		{now.Add(wire.MaxObjectTTL + time.Hour + time.Second), time.Hour,
+			relay.ErrExpirationTooFar},
+	}
+
+	for i, test := range tests {
+		err := relay.CheckFuture(object(test.expiration), now, test.tolerance)
+		if err != test.err {
+			t.Errorf("CheckFuture #%d: got %v want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestNetValidator(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	params := netparams.TestNetParams
+	params.Pow = testPow
+	params.FutureTolerance = time.Minute
+
+	v := relay.NewNetValidator(&params, []uint32{1},
+		&relay.ValidatorConfig{Clock: clock.NewManual(now)})
+
+	valid, err := wire.DecodeMsgObject(encodeWithPow(now, 1))
+	if err != nil {
+		t.Fatalf("DecodeMsgObject: %v", err)
+	}
+	checkValidation(t, "valid", v.Validate(valid), "", nil)
+
+	// An object from two minutes in the future is accepted by the default
+	// validator but not with the tolerance of the parameters.
+	future := wire.NewMsgObject(wire.NewObjectHeader(0,
+		now.Add(wire.MaxObjectTTL+2*time.Minute), wire.ObjectTypeMsg, 1, 1),
+		[]byte{1})
+	checkValidation(t, "future", v.Validate(future), "expiration",
+		relay.ErrExpirationTooFar)
+	if err := relay.ExpirationRule().Check(future, now); err != nil {
+		t.Errorf("ExpirationRule: got %v want nil", err)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package sim simulates a network of Bitmessage nodes within a single process,
+so that the propagation of objects can be tested without sockets.
+
+A Network holds Nodes which are connected to one another by in-memory links
+with a configurable latency and loss rate. Each node keeps its objects in a
+memory store and relays them in the usual way: new objects are announced
+to every other peer with an inv message, peers request the objects they do
+not have with getdata, and the objects are sent in reply.
+
+Links are lossy at the level of whole messages rather than bytes, so that
+the stream of messages remains readable. The version and verack messages
+of the handshake are never lost. Whether a message is lost is decided by a
+random source with a fixed seed, so that a simulation which sends the same
+messages in the same order loses the same ones.
+
+Lost messages are not requested again, just as a real node only requests
+an object again once it has been announced again.
+*/
+package sim
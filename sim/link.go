@@ -0,0 +1,293 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// frameQueueSize is the number of messages which may be in flight in one
+// direction of a link before writes block.
+const frameQueueSize = 256
+
+// LinkConfig gives the properties of a link.
+type LinkConfig struct {
+	// Latency is the time taken for a message to cross the link.
+	Latency time.Duration
+
+	// Loss is the probability, between 0 and 1, that a message is lost.
+	Loss float64
+}
+
+// LinkStats counts the messages which have been sent over a link.
+type LinkStats struct {
+	Delivered int
+	Lost      int
+}
+
+// frame is a message in flight.
+type frame struct {
+	data []byte
+	at   time.Time
+}
+
+// lossSource decides which messages are lost in one direction of a link.
+type lossSource struct {
+	mtx   sync.Mutex
+	rng   *rand.Rand
+	loss  float64
+	stats LinkStats
+}
+
+// lose decides whether a message is lost and counts it.
+func (s *lossSource) lose(command string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.loss > 0 && command != wire.CmdVersion &&
+		command != wire.CmdVerAck && s.rng.Float64() < s.loss {
+		s.stats.Lost++
+		return true
+	}
+	s.stats.Delivered++
+	return false
+}
+
+// halfLink carries data in one direction.
+type halfLink struct {
+	latency time.Duration
+	loss    *lossSource
+
+	// pending holds written data which is not yet a whole message.
+	pending []byte
+	frames  chan frame
+
+	mtx    sync.Mutex
+	buf    bytes.Buffer
+	notify chan struct{}
+
+	quit chan struct{}
+}
+
+func newHalfLink(cfg LinkConfig, loss *lossSource, quit chan struct{}) *halfLink {
+	h := &halfLink{
+		latency: cfg.Latency,
+		loss:    loss,
+		frames:  make(chan frame, frameQueueSize),
+		notify:  make(chan struct{}, 1),
+		quit:    quit,
+	}
+	go h.deliver()
+	return h
+}
+
+// write splits data into messages and sends those which are not lost.
+// Data which cannot be read as a message is sent as it is.
+func (h *halfLink) write(data []byte) error {
+	h.pending = append(h.pending, data...)
+	for len(h.pending) >= wire.MessageHeaderSize {
+		length := binary.BigEndian.Uint32(h.pending[16:20])
+		size := wire.MessageHeaderSize + int(length)
+		if length > wire.MaxMessagePayload {
+			size = len(h.pending)
+		}
+		if len(h.pending) < size {
+			break
+		}
+
+		data := h.pending[:size:size]
+		h.pending = h.pending[size:]
+		command := strings.TrimRight(string(data[4:16]), "\x00")
+		if h.loss.lose(command) {
+			continue
+		}
+
+		select {
+		case h.frames <- frame{data, time.Now().Add(h.latency)}:
+		case <-h.quit:
+			return io.ErrClosedPipe
+		}
+	}
+	return nil
+}
+
+// deliver moves messages to the read buffer once they have crossed the
+// link.
+func (h *halfLink) deliver() {
+	for {
+		select {
+		case f := <-h.frames:
+			if d := f.at.Sub(time.Now()); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-h.quit:
+					return
+				}
+			}
+
+			h.mtx.Lock()
+			h.buf.Write(f.data)
+			h.mtx.Unlock()
+			select {
+			case h.notify <- struct{}{}:
+			default:
+			}
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// read reads delivered data, waiting until there is some, the deadline
+// passes or the link is closed.
+func (h *halfLink) read(b []byte, deadline func() time.Time) (int, error) {
+	for {
+		h.mtx.Lock()
+		if h.buf.Len() > 0 {
+			n, err := h.buf.Read(b)
+			h.mtx.Unlock()
+			return n, err
+		}
+		h.mtx.Unlock()
+
+		var timeout <-chan time.Time
+		if d := deadline(); !d.IsZero() {
+			wait := d.Sub(time.Now())
+			if wait <= 0 {
+				return 0, errTimeout
+			}
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			timeout = t.C
+		}
+
+		select {
+		case <-h.notify:
+		case <-timeout:
+			return 0, errTimeout
+		case <-h.quit:
+			return 0, io.EOF
+		}
+	}
+}
+
+// timeoutError is returned by reads after the deadline has passed.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var errTimeout net.Error = timeoutError{}
+
+// addr is the address of one end of a link.
+type addr string
+
+func (a addr) Network() string { return "sim" }
+func (a addr) String() string  { return string(a) }
+
+// conn is one end of a link.
+type conn struct {
+	local, remote addr
+	in, out       *halfLink
+
+	mtx          sync.Mutex
+	readDeadline time.Time
+
+	// wmtx serializes writes, which may block while the link is full.
+	wmtx sync.Mutex
+
+	quit  chan struct{}
+	close *sync.Once
+}
+
+// Pipe returns the two ends of a link. Messages written to one end can be
+// read from the other after the latency of the link, unless they are lost.
+// Closing either end closes both. Each direction decides which messages
+// are lost with its own random source seeded from seed, so that the
+// messages lost in one direction depend only on the messages written in
+// that direction.
+func Pipe(cfg LinkConfig, seed int64) (net.Conn, net.Conn) {
+	c1, c2, _ := pipe("a", "b", cfg, seed)
+	return c1, c2
+}
+
+func pipe(a, b string, cfg LinkConfig, seed int64) (*conn, *conn, [2]*lossSource) {
+	rng := rand.New(rand.NewSource(seed))
+	loss := [2]*lossSource{
+		{rng: rand.New(rand.NewSource(rng.Int63())), loss: cfg.Loss},
+		{rng: rand.New(rand.NewSource(rng.Int63())), loss: cfg.Loss},
+	}
+	quit := make(chan struct{})
+	once := new(sync.Once)
+	ab := newHalfLink(cfg, loss[0], quit)
+	ba := newHalfLink(cfg, loss[1], quit)
+
+	c1 := &conn{local: addr(a), remote: addr(b), in: ba, out: ab,
+		quit: quit, close: once}
+	c2 := &conn{local: addr(b), remote: addr(a), in: ab, out: ba,
+		quit: quit, close: once}
+	return c1, c2, loss
+}
+
+// Read reads data which has crossed the link.
+func (c *conn) Read(b []byte) (int, error) {
+	return c.in.read(b, func() time.Time {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		return c.readDeadline
+	})
+}
+
+// Write sends data across the link. Writes are not affected by deadlines.
+func (c *conn) Write(b []byte) (int, error) {
+	select {
+	case <-c.quit:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	c.wmtx.Lock()
+	defer c.wmtx.Unlock()
+	if err := c.out.write(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes both ends of the link.
+func (c *conn) Close() error {
+	c.close.Do(func() {
+		close(c.quit)
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr  { return c.local }
+func (c *conn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sim_test
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/sim"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newAddr returns an addr message which is distinguished by its port.
+func newAddr(port uint16) *wire.MsgAddr {
+	msg := wire.NewMsgAddr()
+	msg.AddAddress(wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), port,
+		1, 0))
+	return msg
+}
+
+// received writes n addr messages to a and returns the ports of those
+// which are read from b.
+func received(t *testing.T, a, b net.Conn, n int) []uint16 {
+	go func() {
+		for i := 0; i < n; i++ {
+			wire.WriteMessage(a, newAddr(uint16(i)), wire.MainNet)
+		}
+		wire.WriteMessage(a, wire.NewMsgPong(), wire.MainNet)
+	}()
+
+	var ports []uint16
+	for {
+		msg, _, err := wire.ReadMessage(b, wire.MainNet)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		switch msg := msg.(type) {
+		case *wire.MsgAddr:
+			ports = append(ports, msg.AddrList[0].Port)
+		case *wire.MsgPong:
+			return ports
+		}
+	}
+}
+
+// TestPipe tests that messages cross a link after its latency.
+func TestPipe(t *testing.T) {
+	a, b := sim.Pipe(sim.LinkConfig{Latency: 50 * time.Millisecond}, 0)
+	defer a.Close()
+
+	start := time.Now()
+	ports := received(t, a, b, 10)
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("messages arrived after %v, want at least %v", d,
+			50*time.Millisecond)
+	}
+	want := []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("got %v want %v", ports, want)
+	}
+
+	// Closing one end closes the other.
+	a.Close()
+	if _, err := b.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read: got %v want %v", err, io.EOF)
+	}
+	if _, err := b.Write([]byte{0}); err != io.ErrClosedPipe {
+		t.Errorf("Write: got %v want %v", err, io.ErrClosedPipe)
+	}
+}
+
+// TestPipeLoss tests that the same messages are lost from links with the
+// same seed.
+func TestPipeLoss(t *testing.T) {
+	cfg := sim.LinkConfig{Loss: 0.5}
+	var runs [2][]uint16
+	for i := range runs {
+		a, b := sim.Pipe(cfg, 42)
+		runs[i] = received(t, a, b, 100)
+		a.Close()
+	}
+
+	if !reflect.DeepEqual(runs[0], runs[1]) {
+		t.Errorf("different messages were lost: %v and %v", runs[0], runs[1])
+	}
+	if n := len(runs[0]); n < 20 || n > 80 {
+		t.Errorf("%d of %d messages were delivered", n, 100)
+	}
+}
+
+// TestPipeReadDeadline tests that reads time out.
+func TestPipeReadDeadline(t *testing.T) {
+	a, b := sim.Pipe(sim.LinkConfig{}, 0)
+	defer a.Close()
+
+	b.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := b.Read(make([]byte, 1))
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Errorf("Read: got %v want timeout", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/peer"
+)
+
+// ErrTimeout is returned by WaitFor if an object has not reached every
+// node in time.
+var ErrTimeout = errors.New("object did not reach every node in time")
+
+// Link is a connection between two nodes.
+type Link struct {
+	a, b *Node
+	loss [2]*lossSource
+}
+
+// Stats returns the number of messages that have been delivered and lost
+// over the link in both directions.
+func (l *Link) Stats() LinkStats {
+	var stats LinkStats
+	for _, s := range l.loss {
+		s.mtx.Lock()
+		stats.Delivered += s.stats.Delivered
+		stats.Lost += s.stats.Lost
+		s.mtx.Unlock()
+	}
+	return stats
+}
+
+// Network is a simulated network of nodes.
+type Network struct {
+	rng *rand.Rand
+
+	mtx   sync.Mutex
+	nodes []*Node
+	wg    sync.WaitGroup
+}
+
+// NewNetwork returns an empty network. The seed determines which messages
+// are lost.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rng: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// NewNode adds a node to the network which serves the given streams.
+func (n *Network) NewNode(name string, streams ...uint32) *Node {
+	if len(streams) == 0 {
+		streams = []uint32{1}
+	}
+	node := newNode(name, streams)
+
+	n.mtx.Lock()
+	n.nodes = append(n.nodes, node)
+	n.mtx.Unlock()
+	return node
+}
+
+// Nodes returns the nodes in the order in which they were added.
+func (n *Network) Nodes() []*Node {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]*Node(nil), n.nodes...)
+}
+
+// Connect links two nodes and performs the handshake. Node a makes the
+// outbound connection.
+func (n *Network) Connect(a, b *Node, cfg LinkConfig) (*Link, error) {
+	n.mtx.Lock()
+	seed := n.rng.Int63()
+	n.mtx.Unlock()
+
+	ca, cb, loss := pipe(a.name, b.name, cfg, seed)
+
+	type result struct {
+		p   *peer.Peer
+		err error
+	}
+	ch := make(chan result)
+	go func() {
+		p, err := peer.NewInbound(b.cfg, cb)
+		ch <- result{p, err}
+	}()
+	pa, err := peer.NewOutbound(a.cfg, ca)
+	rb := <-ch
+	if err != nil {
+		return nil, err
+	}
+	if rb.err != nil {
+		pa.Disconnect(nil)
+		return nil, rb.err
+	}
+
+	a.addPeer(pa, &n.wg)
+	b.addPeer(rb.p, &n.wg)
+	return &Link{a: a, b: b, loss: loss}, nil
+}
+
+// WaitFor waits until every node has the object with the given inventory
+// hash.
+func (n *Network) WaitFor(invHash *hash.Sha, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for _, node := range n.Nodes() {
+		for {
+			changed := node.waitChan()
+			if node.Has(invHash) {
+				break
+			}
+			select {
+			case <-changed:
+			case <-deadline:
+				return ErrTimeout
+			}
+		}
+	}
+	return nil
+}
+
+// Stop disconnects every node and waits for them to finish.
+func (n *Network) Stop() {
+	for _, node := range n.Nodes() {
+		node.disconnect()
+	}
+	n.wg.Wait()
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sim_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/sim"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newObject returns an object distinguished by its payload.
+func newObject(payload string) *wire.MsgObject {
+	return wire.NewMsgObject(
+		wire.NewObjectHeader(0, time.Now().Add(time.Hour),
+			wire.ObjectTypeMsg, 1, 1),
+		[]byte(payload))
+}
+
+// TestPropagation tests that an object reaches every node of a chain, and
+// that a node which joins later is sent the objects it missed.
+func TestPropagation(t *testing.T) {
+	n := sim.NewNetwork(1)
+	defer n.Stop()
+
+	cfg := sim.LinkConfig{Latency: 5 * time.Millisecond}
+	var nodes []*sim.Node
+	for i := 0; i < 5; i++ {
+		nodes = append(nodes, n.NewNode("node"+strconv.Itoa(i)))
+		if i > 0 {
+			if _, err := n.Connect(nodes[i-1], nodes[i], cfg); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+		}
+	}
+
+	invHash, err := nodes[0].Publish(newObject("hello"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := n.WaitFor(invHash, 5*time.Second); err != nil {
+		t.Fatalf("WaitFor: %v", err)
+	}
+
+	late := n.NewNode("late")
+	if _, err := n.Connect(late, nodes[2], cfg); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := n.WaitFor(invHash, 5*time.Second); err != nil {
+		t.Fatalf("WaitFor: %v", err)
+	}
+}
+
+// TestTotalLoss tests that objects do not cross a link which loses every
+// message, although the nodes still connect.
+func TestTotalLoss(t *testing.T) {
+	n := sim.NewNetwork(1)
+	defer n.Stop()
+
+	a, b := n.NewNode("a"), n.NewNode("b")
+	link, err := n.Connect(a, b, sim.LinkConfig{Loss: 1})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	invHash, err := a.Publish(newObject("lost"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := n.WaitFor(invHash, 100*time.Millisecond); err != sim.ErrTimeout {
+		t.Errorf("WaitFor: got %v want %v", err, sim.ErrTimeout)
+	}
+
+	// Only the handshake was delivered. The object may have been
+	// announced more than once if it was published while the peer was
+	// still being sent our inventory.
+	stats := link.Stats()
+	if stats.Delivered != 4 || stats.Lost == 0 {
+		t.Errorf("Stats: got %+v want 4 delivered and some lost", stats)
+	}
+}
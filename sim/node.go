@@ -0,0 +1,207 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Node is a simulated Bitmessage node.
+type Node struct {
+	name  string
+	cfg   *peer.Config
+	store *store.MemStore
+
+	mtx       sync.Mutex
+	peers     map[*peer.Peer]struct{}
+	requested map[hash.Sha]struct{}
+	changed   chan struct{}
+}
+
+func newNode(name string, streams []uint32) *Node {
+	return &Node{
+		name: name,
+		cfg: &peer.Config{
+			Net:           wire.MainNet,
+			Streams:       streams,
+			Services:      wire.SFNodeNetwork,
+			UserAgentName: name,
+			Nonces:        peer.NewNonceSet(),
+		},
+		store:     store.NewMemStore(),
+		peers:     make(map[*peer.Peer]struct{}),
+		requested: make(map[hash.Sha]struct{}),
+		changed:   make(chan struct{}),
+	}
+}
+
+// Name returns the name of the node.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Store returns the store in which the node keeps its objects.
+func (n *Node) Store() store.ObjectStore {
+	return n.store
+}
+
+// Has returns whether the node has the object with the given inventory
+// hash.
+func (n *Node) Has(invHash *hash.Sha) bool {
+	ok, _ := n.store.Exists(invHash)
+	return ok
+}
+
+// Publish adds an object to the node and announces it to the node's peers.
+func (n *Node) Publish(obj *wire.MsgObject) (*hash.Sha, error) {
+	invHash, _, err := n.add(obj, nil)
+	return invHash, err
+}
+
+// add adds an object to the store and announces it to every peer but the
+// one it came from, which is nil for objects published by the node itself.
+// It returns whether the object is new.
+func (n *Node) add(obj *wire.MsgObject, from *peer.Peer) (*hash.Sha, bool, error) {
+	invHash := hash.InventoryHash(wire.Encode(obj))
+	if n.Has(invHash) {
+		return invHash, false, nil
+	}
+	if _, err := n.store.Put(obj); err != nil {
+		return nil, false, err
+	}
+
+	n.mtx.Lock()
+	delete(n.requested, *invHash)
+	close(n.changed)
+	n.changed = make(chan struct{})
+	peers := n.peerList()
+	n.mtx.Unlock()
+
+	inv := wire.NewMsgInv()
+	inv.AddInvVect((*wire.InvVect)(invHash))
+	for _, p := range peers {
+		if p != from {
+			p.QueueMessage(inv)
+		}
+	}
+	return invHash, true, nil
+}
+
+// peerList returns the connected peers. The mutex must be held.
+func (n *Node) peerList() []*peer.Peer {
+	peers := make([]*peer.Peer, 0, len(n.peers))
+	for p := range n.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// waitChan returns a channel which is closed when the node next receives
+// an object.
+func (n *Node) waitChan() <-chan struct{} {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.changed
+}
+
+// addPeer starts relaying objects to and from a peer.
+func (n *Node) addPeer(p *peer.Peer, wg *sync.WaitGroup) {
+	n.mtx.Lock()
+	n.peers[p] = struct{}{}
+	n.mtx.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.peerHandler(p)
+
+		n.mtx.Lock()
+		delete(n.peers, p)
+		n.mtx.Unlock()
+	}()
+}
+
+// peerHandler announces our objects to a new peer and then handles its
+// messages until it is disconnected.
+func (n *Node) peerHandler(p *peer.Peer) {
+	for _, stream := range p.Streams() {
+		hashes, err := n.store.ByStream(uint64(stream))
+		if err != nil {
+			continue
+		}
+
+		inv := wire.NewMsgInv()
+		for _, h := range hashes {
+			if inv.AddInvVect((*wire.InvVect)(h)) != nil {
+				p.QueueMessage(inv)
+				inv = wire.NewMsgInv()
+				inv.AddInvVect((*wire.InvVect)(h))
+			}
+		}
+		if len(inv.InvList) > 0 {
+			p.QueueMessage(inv)
+		}
+	}
+
+	for msg := range p.In() {
+		switch msg := msg.(type) {
+		case *wire.MsgInv:
+			n.handleInv(p, msg)
+		case *wire.MsgGetData:
+			n.handleGetData(p, msg)
+		case *wire.MsgObject:
+			n.add(msg, p)
+		}
+	}
+}
+
+// handleInv requests the objects which we neither have nor have already
+// requested.
+func (n *Node) handleInv(p *peer.Peer, msg *wire.MsgInv) {
+	getData := wire.NewMsgGetData()
+	n.mtx.Lock()
+	for _, iv := range msg.InvList {
+		h := (*hash.Sha)(iv)
+		if _, ok := n.requested[*h]; ok || n.Has(h) {
+			continue
+		}
+		n.requested[*h] = struct{}{}
+		getData.AddInvVect(iv)
+	}
+	n.mtx.Unlock()
+
+	if len(getData.InvList) > 0 {
+		p.QueueMessage(getData)
+	}
+}
+
+// handleGetData sends the requested objects which we have.
+func (n *Node) handleGetData(p *peer.Peer, msg *wire.MsgGetData) {
+	for _, iv := range msg.InvList {
+		obj, err := n.store.Get((*hash.Sha)(iv))
+		if err != nil {
+			continue
+		}
+		if p.QueueMessage(obj) != nil {
+			return
+		}
+	}
+}
+
+// disconnect disconnects every peer.
+func (n *Node) disconnect() {
+	n.mtx.Lock()
+	peers := n.peerList()
+	n.mtx.Unlock()
+
+	for _, p := range peers {
+		p.Disconnect(nil)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package socks provides a SOCKS5 proxy dialer so that a node can make its
+connections through a proxy such as Tor.
+
+Host names, including .onion names, are passed to the proxy to resolve
+rather than being resolved locally. Bitmessage represents hidden services
+as OnionCat IPv6 addresses in addr messages; DialAddr converts these back to
+.onion names. When TorIsolation is set, every connection is made with
+different random credentials, which causes Tor to use a separate circuit for
+it. Lookup resolves host names with Tor's RESOLVE extension so that DNS
+seeds can be queried without leaking requests outside of Tor.
+*/
+package socks
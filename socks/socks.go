@@ -0,0 +1,336 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package socks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	socksVersion = 5
+
+	authNone     = 0
+	authPassword = 2
+	authNoAccept = 0xff
+
+	cmdConnect = 1
+
+	// cmdResolve is Tor's extension for resolving a host name through
+	// the proxy.
+	cmdResolve = 0xf0
+
+	atypIPv4   = 1
+	atypDomain = 3
+	atypIPv6   = 4
+)
+
+var (
+	// ErrAuthFailed is returned when the proxy rejects our credentials or
+	// offers no acceptable authentication method.
+	ErrAuthFailed = errors.New("socks: authentication failed")
+
+	// ErrBadReply is returned when the proxy's reply cannot be understood.
+	ErrBadReply = errors.New("socks: malformed reply from proxy")
+)
+
+// replyStrings are the meanings of the reply codes defined in RFC 1928.
+var replyStrings = map[byte]string{
+	1: "general server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// ReplyError is returned when the proxy refuses a request.
+type ReplyError byte
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e ReplyError) Error() string {
+	if s, ok := replyStrings[byte(e)]; ok {
+		return "socks: " + s
+	}
+	return fmt.Sprintf("socks: unknown error %d", byte(e))
+}
+
+// Dialer is the interface of anything that can open a connection to an
+// address, such as a *net.Dialer or a *Proxy.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Proxy is a SOCKS5 proxy.
+type Proxy struct {
+	// Addr is the address of the proxy in host:port form.
+	Addr string
+
+	// Username and Password are the credentials for the proxy. They may
+	// be empty if the proxy does not require authentication.
+	Username string
+	Password string
+
+	// TorIsolation causes every connection to be made with a different
+	// random username and password, which Tor takes as a request to use a
+	// separate circuit. It is ignored if Username is set.
+	TorIsolation bool
+
+	// Timeout is the time allowed to connect to the proxy and complete the
+	// request. If zero, there is no timeout.
+	Timeout time.Duration
+}
+
+// credentials returns the username and password to use for a connection.
+func (p *Proxy) credentials() (string, string, error) {
+	if p.Username != "" || !p.TorIsolation {
+		return p.Username, p.Password, nil
+	}
+
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(b[:8]), hex.EncodeToString(b[8:]), nil
+}
+
+// Dial connects to address through the proxy. address is in host:port
+// form and the host may be a domain name, including a .onion name, which
+// is resolved by the proxy. Only TCP is supported.
+func (p *Proxy) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks: network %s is not supported", network)
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, _, err := p.request(cmdConnect, host, uint16(port))
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// DialAddr connects to addr through the proxy. OnionCat addresses are
+// converted back to .onion names. It may be used as the Dial function of
+// a connmgr.Config.
+func (p *Proxy) DialAddr(addr net.Addr) (net.Conn, error) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return p.Dial(addr.Network(), addr.String())
+	}
+
+	host := tcp.IP.String()
+	if onion, ok := OnionCatHost(tcp.IP); ok {
+		host = onion
+	}
+	return p.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(tcp.Port)))
+}
+
+// Lookup resolves host through the proxy using Tor's RESOLVE extension, so
+// that DNS requests do not leak outside of Tor. It may be used as a
+// bootstrap.LookupFunc.
+func (p *Proxy) Lookup(host string) ([]net.IP, error) {
+	conn, ip, err := p.request(cmdResolve, host, 0)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	// A proxy which answers with a domain name has not resolved anything.
+	if ip == nil {
+		return nil, ErrBadReply
+	}
+	return []net.IP{ip}, nil
+}
+
+// request connects to the proxy and makes a request, returning the
+// connection and the address in the proxy's reply.
+func (p *Proxy) request(cmd byte, host string, port uint16) (net.Conn, net.IP, error) {
+	username, password, err := p.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", p.Addr, p.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(p.Timeout))
+	}
+
+	ip, err := handshake(conn, cmd, host, port, username, password)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, ip, nil
+}
+
+// handshake negotiates authentication and makes the request over conn.
+func handshake(conn io.ReadWriter, cmd byte, host string, port uint16,
+	username, password string) (net.IP, error) {
+
+	// Offer password authentication only if we have credentials.
+	greeting := []byte{socksVersion, 1, authNone}
+	if username != "" {
+		greeting = []byte{socksVersion, 2, authNone, authPassword}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return nil, err
+	}
+	if resp[0] != socksVersion {
+		return nil, ErrBadReply
+	}
+
+	switch resp[1] {
+	case authNone:
+	case authPassword:
+		if username == "" || len(username) > 255 || len(password) > 255 {
+			return nil, ErrAuthFailed
+		}
+		req := []byte{1, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return nil, err
+		}
+		if resp[1] != 0 {
+			return nil, ErrAuthFailed
+		}
+	default:
+		return nil, ErrAuthFailed
+	}
+
+	// Send the request. Host names are sent as domain names so that the
+	// proxy resolves them.
+	req := []byte{socksVersion, cmd, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, atypIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, atypIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("socks: host name %s is too long", host)
+		}
+		req = append(req, atypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	// Read the reply.
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return nil, err
+	}
+	if head[0] != socksVersion {
+		return nil, ErrBadReply
+	}
+	if head[1] != 0 {
+		return nil, ReplyError(head[1])
+	}
+
+	var addr []byte
+	switch head[3] {
+	case atypIPv4:
+		addr = make([]byte, net.IPv4len)
+	case atypIPv6:
+		addr = make([]byte, net.IPv6len)
+	case atypDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return nil, err
+		}
+		addr = make([]byte, l[0])
+	default:
+		return nil, ErrBadReply
+	}
+	if _, err := io.ReadFull(conn, addr); err != nil {
+		return nil, err
+	}
+	var bndPort [2]byte
+	if _, err := io.ReadFull(conn, bndPort[:]); err != nil {
+		return nil, err
+	}
+
+	if head[3] == atypDomain {
+		return nil, nil
+	}
+	return net.IP(addr), nil
+}
+
+// onionCatPrefix is the IPv6 prefix which OnionCat uses to represent Tor
+// hidden services as IPv6 addresses.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// onionEncoding is the encoding of the names of hidden services.
+var onionEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567")
+
+// OnionCatHost returns the .onion name of a hidden service represented as
+// an OnionCat address. The second return value is false if ip is not an
+// OnionCat address.
+func OnionCatHost(ip net.IP) (string, bool) {
+	ip = ip.To16()
+	if ip == nil || !bytes.Equal(ip[:6], onionCatPrefix) {
+		return "", false
+	}
+	return onionEncoding.EncodeToString(ip[6:]) + ".onion", true
+}
+
+// OnionCatIP returns the OnionCat address of a .onion name. Only the
+// ten-byte names of version 2 hidden services can be represented.
+func OnionCatIP(host string) (net.IP, error) {
+	lower := strings.ToLower(host)
+	name := strings.TrimSuffix(lower, ".onion")
+	if name == lower || len(name) != 16 {
+		return nil, fmt.Errorf("socks: %s is not a version 2 onion name", host)
+	}
+	b, err := onionEncoding.DecodeString(name)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, 0, net.IPv6len)
+	ip = append(ip, onionCatPrefix...)
+	return append(ip, b...), nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package socks_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/socks"
+)
+
+// request records what a client asked of the test proxy.
+type request struct {
+	username, password string
+	cmd                byte
+	atyp               byte
+	host               string
+	port               uint16
+}
+
+// serveSocks runs a minimal SOCKS5 server which requires a password if
+// password is true, records the requests that it receives and replies to
+// them with the given reply code. Connections which succeed are answered
+// with an echo.
+func serveSocks(t *testing.T, password bool, rep byte) (net.Listener, chan request) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	reqs := make(chan request, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var r request
+
+				var b [2]byte
+				io.ReadFull(conn, b[:])
+				methods := make([]byte, b[1])
+				io.ReadFull(conn, methods)
+
+				method := byte(0)
+				if password {
+					method = 2
+					if bytes.IndexByte(methods, 2) < 0 {
+						conn.Write([]byte{5, 0xff})
+						return
+					}
+				}
+				conn.Write([]byte{5, method})
+
+				if password {
+					io.ReadFull(conn, b[:])
+					u := make([]byte, b[1])
+					io.ReadFull(conn, u)
+					io.ReadFull(conn, b[:1])
+					p := make([]byte, b[0])
+					io.ReadFull(conn, p)
+					r.username, r.password = string(u), string(p)
+					conn.Write([]byte{1, 0})
+				}
+
+				var head [4]byte
+				io.ReadFull(conn, head[:])
+				r.cmd, r.atyp = head[1], head[3]
+				switch r.atyp {
+				case 1:
+					ip := make([]byte, 4)
+					io.ReadFull(conn, ip)
+					r.host = net.IP(ip).String()
+				case 4:
+					ip := make([]byte, 16)
+					io.ReadFull(conn, ip)
+					r.host = net.IP(ip).String()
+				case 3:
+					io.ReadFull(conn, b[:1])
+					h := make([]byte, b[0])
+					io.ReadFull(conn, h)
+					r.host = string(h)
+				}
+				io.ReadFull(conn, b[:])
+				r.port = uint16(b[0])<<8 | uint16(b[1])
+				reqs <- r
+
+				conn.Write([]byte{5, rep, 0, 1, 10, 1, 2, 3, 0, 0})
+				if rep == 0 && r.cmd == 1 {
+					io.Copy(conn, conn)
+				}
+			}()
+		}
+	}()
+	return l, reqs
+}
+
+// TestDial tests connecting through a proxy.
+func TestDial(t *testing.T) {
+	tests := []struct {
+		addr string
+		atyp byte
+		host string
+		port uint16
+	}{
+		{"8.8.8.8:8444", 1, "8.8.8.8", 8444},
+		{"[2a01:4f8::1]:8080", 4, "2a01:4f8::1", 8080},
+		{"bitmessage.example.onion:8444", 3, "bitmessage.example.onion", 8444},
+	}
+
+	l, reqs := serveSocks(t, false, 0)
+	defer l.Close()
+	proxy := &socks.Proxy{Addr: l.Addr().String()}
+
+	for i, test := range tests {
+		conn, err := proxy.Dial("tcp", test.addr)
+		if err != nil {
+			t.Errorf("test #%d: Dial: %v", i, err)
+			continue
+		}
+
+		r := <-reqs
+		if r.cmd != 1 || r.atyp != test.atyp || r.host != test.host ||
+			r.port != test.port {
+			t.Errorf("test #%d: got request %+v want %d %s %d", i, r,
+				test.atyp, test.host, test.port)
+		}
+
+		// The connection should now be passed through.
+		msg := []byte("hello")
+		conn.Write(msg)
+		buf := make([]byte, len(msg))
+		if _, err = io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, msg) {
+			t.Errorf("test #%d: got %q, %v want %q", i, buf, err, msg)
+		}
+		conn.Close()
+	}
+}
+
+// TestIsolation tests that each connection gets its own credentials when
+// TorIsolation is set, and that fixed credentials are used otherwise.
+func TestIsolation(t *testing.T) {
+	l, reqs := serveSocks(t, true, 0)
+	defer l.Close()
+
+	proxy := &socks.Proxy{Addr: l.Addr().String(), TorIsolation: true}
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		conn, err := proxy.Dial("tcp", "8.8.8.8:8444")
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		conn.Close()
+		r := <-reqs
+		if r.username == "" || seen[r.username+":"+r.password] {
+			t.Errorf("credentials %s:%s are not unique", r.username,
+				r.password)
+		}
+		seen[r.username+":"+r.password] = true
+	}
+
+	proxy = &socks.Proxy{Addr: l.Addr().String(), Username: "alice",
+		Password: "secret", TorIsolation: true}
+	conn, err := proxy.Dial("tcp", "8.8.8.8:8444")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn.Close()
+	if r := <-reqs; r.username != "alice" || r.password != "secret" {
+		t.Errorf("got credentials %s:%s want alice:secret", r.username,
+			r.password)
+	}
+
+	// Without credentials, the proxy refuses us.
+	proxy = &socks.Proxy{Addr: l.Addr().String()}
+	if _, err = proxy.Dial("tcp", "8.8.8.8:8444"); err != socks.ErrAuthFailed {
+		t.Errorf("got error %v want %v", err, socks.ErrAuthFailed)
+	}
+}
+
+// TestReplyError tests that a refusal by the proxy is reported.
+func TestReplyError(t *testing.T) {
+	l, reqs := serveSocks(t, false, 5)
+	defer l.Close()
+
+	proxy := &socks.Proxy{Addr: l.Addr().String()}
+	_, err := proxy.Dial("tcp", "8.8.8.8:8444")
+	<-reqs
+	if err != socks.ReplyError(5) {
+		t.Errorf("got error %v want %v", err, socks.ReplyError(5))
+	}
+	if s := err.Error(); s != "socks: connection refused" {
+		t.Errorf("got %s want %s", s, "socks: connection refused")
+	}
+}
+
+// TestLookup tests resolving a host name through the proxy.
+func TestLookup(t *testing.T) {
+	l, reqs := serveSocks(t, false, 0)
+	defer l.Close()
+
+	proxy := &socks.Proxy{Addr: l.Addr().String()}
+	ips, err := proxy.Lookup("bootstrap8444.bitmessage.org")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	r := <-reqs
+	if r.cmd != 0xf0 || r.host != "bootstrap8444.bitmessage.org" {
+		t.Errorf("got request %+v", r)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.IPv4(10, 1, 2, 3)) {
+		t.Errorf("got %v want %v", ips, []net.IP{net.IPv4(10, 1, 2, 3)})
+	}
+}
+
+// TestLookupDomain tests that a proxy which answers a lookup with a domain
+// name instead of an address is not taken to have resolved the host.
+func TestLookupDomain(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var b [3]byte
+		io.ReadFull(conn, b[:])
+		conn.Write([]byte{5, 0})
+
+		// Read the request, which asks for a domain name.
+		var head [5]byte
+		io.ReadFull(conn, head[:])
+		io.ReadFull(conn, make([]byte, int(head[4])+2))
+		conn.Write([]byte{5, 0, 0, 3, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0, 0})
+	}()
+
+	proxy := &socks.Proxy{Addr: l.Addr().String()}
+	if ips, err := proxy.Lookup("bootstrap8444.bitmessage.org"); err != socks.ErrBadReply {
+		t.Errorf("got %v, %v want nil, %v", ips, err, socks.ErrBadReply)
+	}
+}
+
+// TestDialAddr tests that OnionCat addresses are dialed by .onion name.
+func TestDialAddr(t *testing.T) {
+	l, reqs := serveSocks(t, false, 0)
+	defer l.Close()
+
+	ip, err := socks.OnionCatIP("expyuzz4wqqyqhjn.onion")
+	if err != nil {
+		t.Fatalf("OnionCatIP: %v", err)
+	}
+	proxy := &socks.Proxy{Addr: l.Addr().String()}
+	conn, err := proxy.DialAddr(&net.TCPAddr{IP: ip, Port: 8444})
+	if err != nil {
+		t.Fatalf("DialAddr: %v", err)
+	}
+	conn.Close()
+	if r := <-reqs; r.host != "expyuzz4wqqyqhjn.onion" || r.port != 8444 {
+		t.Errorf("got request %+v", r)
+	}
+}
+
+// TestOnionCat tests conversion between .onion names and OnionCat
+// addresses.
+func TestOnionCat(t *testing.T) {
+	ip, err := socks.OnionCatIP("expyuzz4wqqyqhjn.onion")
+	if err != nil {
+		t.Fatalf("OnionCatIP: %v", err)
+	}
+	if s := ip.String(); s != "fd87:d87e:eb43:25df:8a67:3cb4:2188:1d2d" {
+		t.Errorf("got %s want %s", s, "fd87:d87e:eb43:25df:8a67:3cb4:2188:1d2d")
+	}
+	host, ok := socks.OnionCatHost(ip)
+	if !ok || host != "expyuzz4wqqyqhjn.onion" {
+		t.Errorf("got %s, %v want %s, true", host, ok, "expyuzz4wqqyqhjn.onion")
+	}
+
+	// The suffix is not case sensitive.
+	upper, err := socks.OnionCatIP("EXPYUZZ4WQQYQHJN.ONION")
+	if err != nil {
+		t.Fatalf("OnionCatIP: %v", err)
+	}
+	if !upper.Equal(ip) {
+		t.Errorf("got %s want %s", upper, ip)
+	}
+
+	if _, ok = socks.OnionCatHost(net.ParseIP("8.8.8.8")); ok {
+		t.Error("8.8.8.8 was taken for an OnionCat address")
+	}
+	for _, name := range []string{"example.com", "short.onion",
+		"0000000000000000.onion", "EXPYUZZ4WQQYQHJN"} {
+		if _, err = socks.OnionCatIP(name); err == nil {
+			t.Errorf("OnionCatIP(%s): got nil want error", name)
+		}
+	}
+}
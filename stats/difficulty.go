@@ -0,0 +1,187 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// DefaultDifficultySamples is the number of samples of each kind which a
+// DifficultyObserver keeps by default.
+const DefaultDifficultySamples = 1000
+
+// Distribution is a set of samples from which percentiles can be read.
+type Distribution struct {
+	sorted []float64
+}
+
+// newDistribution returns the distribution of a copy of samples.
+func newDistribution(samples []float64) *Distribution {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	return &Distribution{sorted: sorted}
+}
+
+// Len returns the number of samples.
+func (d *Distribution) Len() int {
+	return len(d.sorted)
+}
+
+// Percentile returns the smallest sample which is at least as large as p
+// percent of the samples, for p from 0 to 100. It returns zero if there are
+// no samples.
+func (d *Distribution) Percentile(p float64) float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(d.sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(d.sorted) {
+		i = len(d.sorted) - 1
+	}
+	return d.sorted[i]
+}
+
+// Median returns the 50th percentile.
+func (d *Distribution) Median() float64 {
+	return d.Percentile(50)
+}
+
+// ring holds the most recent samples of one kind.
+type ring struct {
+	samples []float64
+	next    int
+}
+
+func (r *ring) add(size int, x float64) {
+	if len(r.samples) < size {
+		r.samples = append(r.samples, x)
+		return
+	}
+	r.samples[r.next] = x
+	r.next = (r.next + 1) % size
+}
+
+// Difficulty is what a DifficultyObserver has seen.
+type Difficulty struct {
+	// NonceTrialsPerByte and ExtraBytes are the proof of work which
+	// pubkeys ask for.
+	NonceTrialsPerByte *Distribution
+	ExtraBytes         *Distribution
+
+	// Implied is the nonce trials per byte which the proof of work on
+	// each object would have satisfied, with the default extra bytes.
+	// Since the work done is random, single objects vary widely, but a
+	// low percentile shows how little work nodes accept.
+	Implied *Distribution
+}
+
+// DifficultyObserver samples the proof of work which pubkeys ask for and
+// the proof of work done on the objects which arrive, so that a client can
+// see what the network demands and choose competitive defaults. It keeps a
+// fixed number of the most recent samples of each kind. A
+// DifficultyObserver is safe for concurrent use.
+type DifficultyObserver struct {
+	size int
+	now  func() time.Time
+
+	mtx        sync.Mutex
+	trials     ring
+	extraBytes ring
+	implied    ring
+}
+
+// DifficultyConfig is the configuration of a DifficultyObserver. Zero
+// values are replaced by the defaults.
+type DifficultyConfig struct {
+	// Size is the number of samples of each kind which are kept. The
+	// default is DefaultDifficultySamples.
+	Size int
+
+	// Clock tells the time against which the expiration of objects is
+	// measured. The default is clock.System.
+	Clock clock.Clock
+}
+
+// NewDifficultyObserver returns a new DifficultyObserver. cfg may be nil,
+// in which case the defaults are used.
+func NewDifficultyObserver(cfg *DifficultyConfig) *DifficultyObserver {
+	if cfg == nil {
+		cfg = &DifficultyConfig{}
+	}
+
+	size := cfg.Size
+	if size <= 0 {
+		size = DefaultDifficultySamples
+	}
+	return &DifficultyObserver{
+		size: size,
+		now:  clock.Or(cfg.Clock).Now,
+	}
+}
+
+// AddPubKey samples the proof of work asked for by a pubkey. data may be
+// nil, as it is for the oldest pubkeys, in which case nothing is sampled.
+func (o *DifficultyObserver) AddPubKey(data *pow.Data) {
+	if data == nil {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.trials.add(o.size, float64(data.NonceTrialsPerByte))
+	o.extraBytes.add(o.size, float64(data.ExtraBytes))
+}
+
+// AddObject samples the proof of work done on an object which has just
+// arrived. Objects which have expired are not sampled.
+func (o *DifficultyObserver) AddObject(msg *wire.MsgObject) {
+	ttl := msg.Header().Expiration().Unix() - o.now().Unix()
+	if ttl <= 0 {
+		return
+	}
+
+	encoded := wire.Encode(msg)
+	value := pow.TrialValue(msg.Header().Nonce, hash.Sha512(encoded[8:]))
+	implied := ImpliedTrialsPerByte(value, uint64(len(encoded)), uint64(ttl))
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.implied.add(o.size, implied)
+}
+
+// Difficulty returns the distributions of the samples.
+func (o *DifficultyObserver) Difficulty() *Difficulty {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return &Difficulty{
+		NonceTrialsPerByte: newDistribution(o.trials.samples),
+		ExtraBytes:         newDistribution(o.extraBytes.samples),
+		Implied:            newDistribution(o.implied.samples),
+	}
+}
+
+// ImpliedTrialsPerByte returns the largest nonce trials per byte, with the
+// default extra bytes, for which a proof of work with the given trial
+// value on an object of the given length and time to live in seconds would
+// have been sufficient. It is the inverse of pow.CalculateTarget.
+func ImpliedTrialsPerByte(value, payloadLength, ttl uint64) float64 {
+	if value == 0 {
+		value = 1
+	}
+	length := float64(payloadLength + pow.DefaultExtraBytes)
+	return math.MaxUint64 / (float64(value) * (length + float64(ttl)*length/
+		math.Pow(2, 16)))
+}
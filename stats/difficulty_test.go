@@ -0,0 +1,89 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/stats"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestDifficultyPubKeys(t *testing.T) {
+	o := stats.NewDifficultyObserver(&stats.DifficultyConfig{Size: 4})
+
+	o.AddPubKey(nil)
+	for _, n := range []uint64{5000, 1000, 2000, 3000, 1000, 4000} {
+		o.AddPubKey(&pow.Data{NonceTrialsPerByte: n, ExtraBytes: n / 2})
+	}
+
+	// Only the last four pubkeys are kept.
+	d := o.Difficulty()
+	if n := d.NonceTrialsPerByte.Len(); n != 4 {
+		t.Fatalf("Len: got %d want %d", n, 4)
+	}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1000}, {25, 1000}, {50, 2000}, {75, 3000}, {90, 4000}, {100, 4000},
+	}
+	for _, test := range tests {
+		if got := d.NonceTrialsPerByte.Percentile(test.p); got != test.want {
+			t.Errorf("Percentile(%v): got %v want %v", test.p, got, test.want)
+		}
+	}
+	if got := d.ExtraBytes.Median(); got != 1000 {
+		t.Errorf("ExtraBytes median: got %v want %v", got, 1000)
+	}
+	if got := d.Implied.Median(); d.Implied.Len() != 0 || got != 0 {
+		t.Errorf("Implied: got %d samples and median %v", d.Implied.Len(), got)
+	}
+}
+
+func TestDifficultyObjects(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	o := stats.NewDifficultyObserver(&stats.DifficultyConfig{
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+
+	// Do a small proof of work on an object.
+	ttl := uint64(3600)
+	data := pow.Data{NonceTrialsPerByte: 10, ExtraBytes: pow.DefaultExtraBytes}
+	msg := wire.NewMsgObject(wire.NewObjectHeader(0,
+		now.Add(time.Duration(ttl)*time.Second), wire.ObjectTypeMsg, 1, 1),
+		make([]byte, 100))
+	encoded := wire.Encode(msg)
+	target := pow.CalculateTarget(uint64(len(encoded)), ttl, data)
+	msg.Header().Nonce = pow.DoSequential(target, hash.Sha512(encoded[8:]))
+
+	o.AddObject(msg)
+
+	// An expired object is not sampled.
+	o.AddObject(wire.NewMsgObject(wire.NewObjectHeader(0,
+		now.Add(-time.Minute), wire.ObjectTypeMsg, 1, 1), make([]byte, 100)))
+
+	d := o.Difficulty()
+	if n := d.Implied.Len(); n != 1 {
+		t.Fatalf("Implied: got %d samples want %d", n, 1)
+	}
+	// The target is calculated in integers, so the implied difficulty may
+	// fall just short of the one asked for.
+	if got := d.Implied.Median(); got < 9.9 {
+		t.Errorf("Implied: got %v want at least %v", got, 10)
+	}
+
+	// The implied difficulty of a value on the target is the difficulty
+	// the target was calculated for.
+	implied := stats.ImpliedTrialsPerByte(uint64(pow.CalculateTarget(1000,
+		0, pow.Default)), 1000, 0)
+	if implied < 999.99 || implied > 1000.01 {
+		t.Errorf("ImpliedTrialsPerByte: got %v want %v", implied, 1000)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package stats tallies the objects which a node observes, so that their
+number and mix can be shown on a dashboard or used to adapt the node's
+behavior, such as estimating how much proof of work typical objects carry.
+
+A Collector counts objects by type, stream, size class and time to live in
+a ring of fixed-length buckets. Snapshot adds up the buckets which fall in a
+window ending now, so one Collector can report several sliding windows, for
+example the last minute, hour and day, at the resolution of its buckets.
+
+Sizes are grouped into classes of powers of two bytes and times to live into
+classes of powers of two hours, which keeps the number of classes small
+while separating the objects that matter for proof of work.
+
+A DifficultyObserver samples the proof of work which pubkeys ask for and the
+proof of work implied by the nonces of the objects which arrive. Percentiles
+of the samples show what the network demands, so that a client can choose
+defaults which will not leave its objects ignored.
+*/
+package stats
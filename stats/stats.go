@@ -0,0 +1,267 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultWindow is the longest window over which a Collector keeps
+	// counts by default.
+	DefaultWindow = 24 * time.Hour
+
+	// DefaultResolution is the length of each bucket of a Collector by
+	// default.
+	DefaultResolution = time.Minute
+)
+
+// SizeClass returns the class of an object of the given size in bytes,
+// which is the smallest power of two that is at least size.
+func SizeClass(size int) int {
+	class := 1
+	for class < size {
+		class <<= 1
+	}
+	return class
+}
+
+// TTLClass returns the class of an object with the given time to live,
+// which is the smallest power of two hours that is at least ttl. An object
+// which has already expired is in class zero.
+func TTLClass(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return 0
+	}
+	class := time.Hour
+	for class < ttl {
+		class <<= 1
+	}
+	return class
+}
+
+// Snapshot is the tally of the objects observed during a window.
+type Snapshot struct {
+	// Start and End are the beginning and end of the window.
+	Start time.Time
+	End   time.Time
+
+	// Objects is the number of objects and Bytes is their total size.
+	Objects uint64
+	Bytes   uint64
+
+	// TTL is the total time to live of the objects when they were
+	// observed, not counting those which had already expired.
+	TTL time.Duration
+
+	// Types, Streams, Sizes and TTLs count the objects by type, stream,
+	// SizeClass and TTLClass.
+	Types   map[wire.ObjectType]uint64
+	Streams map[uint64]uint64
+	Sizes   map[int]uint64
+	TTLs    map[time.Duration]uint64
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		Types:   make(map[wire.ObjectType]uint64),
+		Streams: make(map[uint64]uint64),
+		Sizes:   make(map[int]uint64),
+		TTLs:    make(map[time.Duration]uint64),
+	}
+}
+
+// add counts an object of the given type, stream, size and time to live.
+func (s *Snapshot) add(t wire.ObjectType, stream uint64, size int, ttl time.Duration) {
+	s.Objects++
+	s.Bytes += uint64(size)
+	if ttl > 0 {
+		s.TTL += ttl
+	}
+	s.Types[t]++
+	s.Streams[stream]++
+	s.Sizes[SizeClass(size)]++
+	s.TTLs[TTLClass(ttl)]++
+}
+
+// merge adds the counts of o to s.
+func (s *Snapshot) merge(o *Snapshot) {
+	s.Objects += o.Objects
+	s.Bytes += o.Bytes
+	s.TTL += o.TTL
+	for k, v := range o.Types {
+		s.Types[k] += v
+	}
+	for k, v := range o.Streams {
+		s.Streams[k] += v
+	}
+	for k, v := range o.Sizes {
+		s.Sizes[k] += v
+	}
+	for k, v := range o.TTLs {
+		s.TTLs[k] += v
+	}
+}
+
+// Rate returns the number of objects observed per second.
+func (s *Snapshot) Rate() float64 {
+	d := s.End.Sub(s.Start).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.Objects) / d
+}
+
+// MeanSize returns the mean size of the objects in bytes.
+func (s *Snapshot) MeanSize() float64 {
+	if s.Objects == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.Objects)
+}
+
+// MeanTTL returns the mean time to live of the objects which had not
+// expired.
+func (s *Snapshot) MeanTTL() time.Duration {
+	live := s.Objects - s.TTLs[0]
+	if live == 0 {
+		return 0
+	}
+	return s.TTL / time.Duration(live)
+}
+
+// bucket holds the counts of one period of a Collector's resolution.
+type bucket struct {
+	slot   int64
+	counts *Snapshot
+}
+
+// Collector tallies objects in a ring of buckets, each covering one period
+// of its resolution, so that the counts for any window up to its length
+// can be reported. A Collector is safe for concurrent use.
+type Collector struct {
+	window     time.Duration
+	resolution time.Duration
+
+	mtx     sync.Mutex
+	buckets []bucket
+	now     func() time.Time
+}
+
+// CollectorConfig is the configuration of a Collector. Zero values are
+// replaced by the defaults.
+type CollectorConfig struct {
+	// Window is the longest window over which counts are kept. The
+	// default is DefaultWindow.
+	Window time.Duration
+
+	// Resolution is the length of each bucket. The default is
+	// DefaultResolution.
+	Resolution time.Duration
+
+	// Clock tells the time at which objects are counted and rates are
+	// reported. The default is clock.System.
+	Clock clock.Clock
+}
+
+// NewCollector returns a new Collector. cfg may be nil, in which case the
+// defaults are used.
+func NewCollector(cfg *CollectorConfig) *Collector {
+	if cfg == nil {
+		cfg = &CollectorConfig{}
+	}
+
+	window, resolution := cfg.Window, cfg.Resolution
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if resolution <= 0 {
+		resolution = DefaultResolution
+	}
+	if resolution > window {
+		resolution = window
+	}
+
+	n := int((window + resolution - 1) / resolution)
+	buckets := make([]bucket, n)
+	for i := range buckets {
+		buckets[i].slot = -1
+	}
+	return &Collector{
+		window:     window,
+		resolution: resolution,
+		buckets:    buckets,
+		now:        clock.Or(cfg.Clock).Now,
+	}
+}
+
+// slot returns the number of the period which t falls in.
+func (c *Collector) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(c.resolution)
+}
+
+// Add counts an object which has been observed now.
+func (c *Collector) Add(msg *wire.MsgObject) {
+	header := msg.Header()
+	c.AddAt(c.now(), header.ObjectType, header.StreamNumber, msg.EncodedSize(),
+		header.Expiration())
+}
+
+// AddAt counts an object with the given type, stream, encoded size and
+// expiration which was observed at time t. It lets objects be counted
+// without being decoded. Objects observed before 1970, or too far in the
+// future to be counted in nanoseconds, are ignored.
+func (c *Collector) AddAt(t time.Time, objType wire.ObjectType, stream uint64,
+	size int, expiration time.Time) {
+
+	if t.Before(time.Unix(0, 0)) || t.After(time.Unix(0, math.MaxInt64)) {
+		return
+	}
+	slot := c.slot(t)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	b := &c.buckets[int(slot%int64(len(c.buckets)))]
+	if b.slot != slot {
+		if b.slot > slot {
+			// Too old to be counted.
+			return
+		}
+		b.slot = slot
+		b.counts = newSnapshot()
+	}
+	b.counts.add(objType, stream, size, expiration.Sub(t))
+}
+
+// Snapshot returns the counts of the objects observed during the window d
+// which ends now. A window longer than that of the Collector is shortened
+// to it. Since counts are kept by bucket, the window starts at the
+// beginning of the bucket which contains the time d before now.
+func (c *Collector) Snapshot(d time.Duration) *Snapshot {
+	if d <= 0 || d > c.window {
+		d = c.window
+	}
+	now := c.now()
+	last := c.slot(now)
+	first := c.slot(now.Add(-d))
+
+	s := newSnapshot()
+	s.Start = time.Unix(0, first*int64(c.resolution))
+	s.End = now
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, b := range c.buckets {
+		if b.slot >= first && b.slot <= last {
+			s.merge(b.counts)
+		}
+	}
+	return s
+}
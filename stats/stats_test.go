@@ -0,0 +1,108 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/stats"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestClasses(t *testing.T) {
+	sizes := []struct{ size, want int }{
+		{0, 1}, {1, 1}, {2, 2}, {3, 4}, {1000, 1024}, {1024, 1024},
+	}
+	for _, test := range sizes {
+		if got := stats.SizeClass(test.size); got != test.want {
+			t.Errorf("SizeClass(%d): got %d want %d", test.size, got, test.want)
+		}
+	}
+
+	ttls := []struct{ ttl, want time.Duration }{
+		{-time.Hour, 0},
+		{time.Minute, time.Hour},
+		{3 * time.Hour, 4 * time.Hour},
+		{28 * 24 * time.Hour, 1024 * time.Hour},
+	}
+	for _, test := range ttls {
+		if got := stats.TTLClass(test.ttl); got != test.want {
+			t.Errorf("TTLClass(%s): got %s want %s", test.ttl, got, test.want)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	// Start at the beginning of a bucket.
+	now := time.Unix(999999960, 0)
+	c := stats.NewCollector(&stats.CollectorConfig{
+		Window:     time.Hour,
+		Resolution: time.Minute,
+		Clock:      clock.Func(func() time.Time { return now }),
+	})
+
+	add := func(objType wire.ObjectType, stream uint64, size int, ttl time.Duration) {
+		c.Add(wire.NewMsgObject(wire.NewObjectHeader(0, now.Add(ttl), objType,
+			1, stream), make([]byte, size)))
+	}
+
+	add(wire.ObjectTypeMsg, 1, 100, 2*time.Hour)
+	now = now.Add(30 * time.Minute)
+	add(wire.ObjectTypeMsg, 1, 1000, 4*time.Hour)
+	add(wire.ObjectTypeBroadcast, 2, 1000, -time.Minute)
+	now = now.Add(20 * time.Minute)
+
+	s := c.Snapshot(time.Hour)
+	if s.Objects != 3 {
+		t.Errorf("Objects: got %d want 3", s.Objects)
+	}
+	if s.Types[wire.ObjectTypeMsg] != 2 || s.Types[wire.ObjectTypeBroadcast] != 1 {
+		t.Errorf("Types: got %v", s.Types)
+	}
+	if s.Streams[1] != 2 || s.Streams[2] != 1 {
+		t.Errorf("Streams: got %v", s.Streams)
+	}
+	if s.TTLs[0] != 1 || s.TTLs[2*time.Hour] != 1 || s.TTLs[4*time.Hour] != 1 {
+		t.Errorf("TTLs: got %v", s.TTLs)
+	}
+	if got, want := s.MeanTTL(), 3*time.Hour; got != want {
+		t.Errorf("MeanTTL: got %s want %s", got, want)
+	}
+	if s.Sizes[stats.SizeClass(100+wire.NewObjectHeader(0, now, 0, 1, 1).EncodedSize())] != 1 {
+		t.Errorf("Sizes: got %v", s.Sizes)
+	}
+
+	// A shorter window leaves out the first object.
+	s = c.Snapshot(30 * time.Minute)
+	if s.Objects != 2 {
+		t.Errorf("Objects in 30 minutes: got %d want 2", s.Objects)
+	}
+	if got, want := s.Rate(), 2/(30*time.Minute).Seconds(); got != want {
+		t.Errorf("Rate: got %f want %f", got, want)
+	}
+
+	// Once the window has passed, the objects are forgotten.
+	now = now.Add(time.Hour)
+	if s = c.Snapshot(0); s.Objects != 0 {
+		t.Errorf("Objects after an hour: got %d want 0", s.Objects)
+	}
+
+	// Buckets are reused.
+	add(wire.ObjectTypePubKey, 1, 10, time.Hour)
+	if s = c.Snapshot(0); s.Objects != 1 || s.Types[wire.ObjectTypePubKey] != 1 {
+		t.Errorf("Snapshot after reuse: got %+v", s)
+	}
+
+	// Objects observed at times which have no bucket are ignored.
+	for _, at := range []time.Time{time.Unix(-1, 0), time.Unix(-1e12, 0),
+		time.Unix(1e12, 0)} {
+		c.AddAt(at, wire.ObjectTypeMsg, 1, 100, now)
+	}
+	if s = c.Snapshot(0); s.Objects != 1 {
+		t.Errorf("Objects after adding at bad times: got %d want 1", s.Objects)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// objectsBucket maps inventory hashes to encoded objects.
+	objectsBucket = []byte("objects")
+
+	// expirationBucket indexes objects by expiration. Its keys are the
+	// expiration time as an 8-byte big-endian Unix time followed by the
+	// inventory hash, and its values are empty.
+	expirationBucket = []byte("expiration")
+)
+
+// BoltStore is an ObjectStore backed by a BoltDB database.
+type BoltStore struct {
+	db     *bolt.DB
+	mapped bool
+}
+
+// BoltOptions are options for opening a BoltStore.
+type BoltOptions struct {
+	// MappedReads makes Open serve objects straight from the database's
+	// memory map rather than copying them. This saves copying large
+	// objects into memory each time they are sent, at the cost of
+	// holding a read transaction open until each ObjectReader is closed.
+	MappedReads bool
+}
+
+// NewBoltStore opens or creates the database at path with the default
+// options.
+func NewBoltStore(path string) (*BoltStore, error) {
+	return OpenBoltStore(path, nil)
+}
+
+// OpenBoltStore opens or creates the database at path. opts may be nil, in
+// which case the default options are used.
+func OpenBoltStore(path string, opts *BoltOptions) (*BoltStore, error) {
+	if opts == nil {
+		opts = &BoltOptions{}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(objectsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(expirationBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db, mapped: opts.MappedReads}, nil
+}
+
+// expirationKey returns the key of an object in the expiration index.
+func expirationKey(expiration time.Time, invHash *hash.Sha) []byte {
+	key := make([]byte, 8+hash.ShaSize)
+	binary.BigEndian.PutUint64(key, uint64(expiration.Unix()))
+	copy(key[8:], invHash[:])
+	return key
+}
+
+// Put adds an object to the store. This is part of the ObjectStore
+// interface.
+func (s *BoltStore) Put(obj *wire.MsgObject) (*hash.Sha, error) {
+	encoded := wire.Encode(obj)
+	invHash := hash.InventoryHash(encoded)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, obj, encoded, invHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invHash, nil
+}
+
+// put adds an encoded object to the store within a transaction.
+func put(tx *bolt.Tx, obj *wire.MsgObject, encoded []byte, invHash *hash.Sha) error {
+	objects := tx.Bucket(objectsBucket)
+	if objects.Get(invHash[:]) != nil {
+		return nil
+	}
+	if err := objects.Put(invHash[:], encoded); err != nil {
+		return err
+	}
+	return tx.Bucket(expirationBucket).Put(
+		expirationKey(obj.Header().Expiration(), invHash), []byte{})
+}
+
+// Get returns the object with the given inventory hash. This is part of
+// the ObjectStore interface.
+func (s *BoltStore) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	var obj *wire.MsgObject
+	err := s.db.View(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(objectsBucket).Get(invHash[:])
+		if encoded == nil {
+			return ErrNotFound
+		}
+
+		// The decoded payload must not refer to memory owned by the
+		// database, so decode from a copy.
+		var err error
+		obj, err = wire.DecodeMsgObject(append([]byte{}, encoded...))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Exists returns whether the object is in the store. This is part of the
+// ObjectStore interface.
+func (s *BoltStore) Exists(invHash *hash.Sha) (bool, error) {
+	var exists bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(objectsBucket).Get(invHash[:]) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// Delete removes an object from the store. This is part of the
+// ObjectStore interface.
+func (s *BoltStore) Delete(invHash *hash.Sha) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		objects := tx.Bucket(objectsBucket)
+		encoded := objects.Get(invHash[:])
+		if encoded == nil {
+			return nil
+		}
+		header, err := wire.DecodeObjectHeader(bytes.NewReader(encoded))
+		if err != nil {
+			return err
+		}
+		if err = objects.Delete(invHash[:]); err != nil {
+			return err
+		}
+		return tx.Bucket(expirationBucket).Delete(
+			expirationKey(header.Expiration(), invHash))
+	})
+}
+
+// ExpireBefore removes every object which expires before t. This is part
+// of the ObjectStore interface.
+func (s *BoltStore) ExpireBefore(t time.Time) (int, error) {
+	var n int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		objects := tx.Bucket(objectsBucket)
+		expiration := tx.Bucket(expirationBucket)
+
+		limit := uint64(t.Unix())
+		var expired [][]byte
+		c := expiration.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if binary.BigEndian.Uint64(k) >= limit {
+				break
+			}
+			expired = append(expired, append([]byte{}, k...))
+		}
+
+		for _, k := range expired {
+			if err := objects.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := expiration.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
+
+// Expired returns the inventory hashes of up to limit objects which expire
+// before t. This is part of the ObjectStore interface.
+func (s *BoltStore) Expired(t time.Time, limit int) ([]*hash.Sha, error) {
+	var hashes []*hash.Sha
+	err := s.db.View(func(tx *bolt.Tx) error {
+		end := uint64(t.Unix())
+		c := tx.Bucket(expirationBucket).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if binary.BigEndian.Uint64(k) >= end ||
+				(limit > 0 && len(hashes) == limit) {
+				break
+			}
+			invHash, err := hash.NewSha(k[8:])
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, invHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// filter returns the inventory hashes of the objects whose headers satisfy
+// f.
+func (s *BoltStore) filter(f func(*wire.ObjectHeader) bool) ([]*hash.Sha, error) {
+	var hashes []*hash.Sha
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(objectsBucket).ForEach(func(k, v []byte) error {
+			header, err := wire.DecodeObjectHeader(bytes.NewReader(v))
+			if err != nil {
+				return err
+			}
+			if f(header) {
+				invHash, err := hash.NewSha(k)
+				if err != nil {
+					return err
+				}
+				hashes = append(hashes, invHash)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ByType returns the inventory hashes of the objects of the given type.
+// This is part of the ObjectStore interface.
+func (s *BoltStore) ByType(objType wire.ObjectType) ([]*hash.Sha, error) {
+	return s.filter(func(h *wire.ObjectHeader) bool {
+		return h.ObjectType == objType
+	})
+}
+
+// ByStream returns the inventory hashes of the objects in the given
+// stream. This is part of the ObjectStore interface.
+func (s *BoltStore) ByStream(stream uint64) ([]*hash.Sha, error) {
+	return s.filter(func(h *wire.ObjectHeader) bool {
+		return h.StreamNumber == stream
+	})
+}
+
+// Export writes a snapshot of the objects which have not expired by now,
+// those which expire first coming first. The snapshot is taken in a single
+// read transaction, so objects which are added or removed while it is
+// written do not affect it. This is part of the ObjectStore interface.
+func (s *BoltStore) Export(w io.Writer, now time.Time) (int, error) {
+	sw, err := newSnapshotWriter(w)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		objects := tx.Bucket(objectsBucket)
+		c := tx.Bucket(expirationBucket).Cursor()
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, uint64(now.Unix()))
+		for k, _ := c.Seek(start); k != nil; k, _ = c.Next() {
+			expiration := time.Unix(int64(binary.BigEndian.Uint64(k)), 0)
+			if expiration.Before(now) {
+				continue
+			}
+			encoded := objects.Get(k[8:])
+			if encoded == nil {
+				continue
+			}
+			if err := sw.write(encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return sw.n, err
+	}
+	return sw.n, sw.close()
+}
+
+// Import adds the objects in a snapshot which have not expired by now. They
+// are added in batches, each in a single transaction. This is part of the
+// ObjectStore interface.
+func (s *BoltStore) Import(r io.Reader, now time.Time) (int, error) {
+	return importSnapshot(r, now, func(objs []*wire.MsgObject) error {
+		return s.db.Update(func(tx *bolt.Tx) error {
+			for _, obj := range objs {
+				encoded := wire.Encode(obj)
+				err := put(tx, obj, encoded, hash.InventoryHash(encoded))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Close closes the database. This is part of the ObjectStore interface.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,290 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"container/list"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultCacheSize is the number of objects kept by a Cache.
+	DefaultCacheSize = 1000
+
+	// DefaultCacheTTL is the time for which a Cache keeps an object after
+	// it was last added or read from the store.
+	DefaultCacheTTL = 10 * time.Minute
+)
+
+// CacheConfig is the configuration of a Cache. Zero and negative values are
+// replaced by the defaults.
+type CacheConfig struct {
+	// Size is the largest number of objects kept in memory. The default
+	// is DefaultCacheSize.
+	Size int
+
+	// TTL is the time for which an object is kept after it was last
+	// loaded into the cache. The default is DefaultCacheTTL.
+	TTL time.Duration
+
+	// Clock tells the time at which objects are loaded and expire from
+	// the cache. The default is clock.System.
+	Clock clock.Clock
+}
+
+// CacheStats counts the lookups made through a Cache.
+type CacheStats struct {
+	// Hits is the number of objects found in memory.
+	Hits uint64
+
+	// Misses is the number of lookups which went to the store.
+	Misses uint64
+
+	// Evictions is the number of objects dropped to make room for
+	// others.
+	Evictions uint64
+}
+
+// cacheEntry is an object held by a Cache.
+type cacheEntry struct {
+	invHash hash.Sha
+	obj     *wire.MsgObject
+	loaded  time.Time
+}
+
+// Cache is an ObjectStore which keeps the most recently used objects of
+// another ObjectStore in memory, so that popular objects requested by many
+// peers are not read from the store every time. Writes go through to the
+// underlying store.
+type Cache struct {
+	store ObjectStore
+	cfg   CacheConfig
+	now   func() time.Time
+
+	mtx     sync.Mutex
+	lru     *list.List // front is most recently used
+	entries map[hash.Sha]*list.Element
+	stats   CacheStats
+
+	// removals is incremented whenever objects are removed from the
+	// store, so that Get does not cache an object which it read before
+	// the object was removed.
+	removals uint64
+}
+
+// NewCache returns a Cache in front of the given store.
+func NewCache(store ObjectStore, cfg *CacheConfig) *Cache {
+	c := &Cache{
+		store:   store,
+		lru:     list.New(),
+		entries: make(map[hash.Sha]*list.Element),
+	}
+	if cfg != nil {
+		c.cfg = *cfg
+	}
+	c.now = clock.Or(c.cfg.Clock).Now
+	if c.cfg.Size <= 0 {
+		c.cfg.Size = DefaultCacheSize
+	}
+	if c.cfg.TTL <= 0 {
+		c.cfg.TTL = DefaultCacheTTL
+	}
+	return c
+}
+
+// lookup returns the cached object with the given inventory hash if it
+// has not outlived the TTL, and counts the hit or miss. The mutex must be
+// held.
+func (c *Cache) lookup(invHash *hash.Sha) *wire.MsgObject {
+	e, ok := c.entries[*invHash]
+	if ok {
+		entry := e.Value.(*cacheEntry)
+		if c.now().Sub(entry.loaded) < c.cfg.TTL {
+			c.lru.MoveToFront(e)
+			c.stats.Hits++
+			return entry.obj
+		}
+		c.remove(e)
+	}
+	c.stats.Misses++
+	return nil
+}
+
+// add puts an object in the cache, evicting the least recently used
+// objects if it is full. The mutex must be held.
+func (c *Cache) add(invHash *hash.Sha, obj *wire.MsgObject) {
+	if e, ok := c.entries[*invHash]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.obj = obj
+		entry.loaded = c.now()
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	for c.lru.Len() >= c.cfg.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	c.entries[*invHash] = c.lru.PushFront(&cacheEntry{
+		invHash: *invHash,
+		obj:     obj,
+		loaded:  c.now(),
+	})
+}
+
+// remove drops an entry from the cache. The mutex must be held.
+func (c *Cache) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*cacheEntry).invHash)
+	c.lru.Remove(e)
+}
+
+// Put adds an object to the underlying store and to the cache. This is
+// part of the ObjectStore interface.
+func (c *Cache) Put(obj *wire.MsgObject) (*hash.Sha, error) {
+	invHash, err := c.store.Put(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.Lock()
+	c.add(invHash, obj.Copy())
+	c.mtx.Unlock()
+	return invHash, nil
+}
+
+// Get returns the object from the cache if it is there and from the
+// underlying store otherwise. This is part of the ObjectStore interface.
+func (c *Cache) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	c.mtx.Lock()
+	obj := c.lookup(invHash)
+	removals := c.removals
+	c.mtx.Unlock()
+	if obj != nil {
+		return obj.Copy(), nil
+	}
+
+	obj, err := c.store.Get(invHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// If anything was removed from the store in the meantime, the object
+	// may have been among it.
+	c.mtx.Lock()
+	if c.removals == removals {
+		c.add(invHash, obj.Copy())
+	}
+	c.mtx.Unlock()
+	return obj, nil
+}
+
+// Exists returns whether the object is in the store. Cached objects are
+// not looked up in the underlying store. This is part of the ObjectStore
+// interface.
+func (c *Cache) Exists(invHash *hash.Sha) (bool, error) {
+	c.mtx.Lock()
+	obj := c.lookup(invHash)
+	c.mtx.Unlock()
+	if obj != nil {
+		return true, nil
+	}
+	return c.store.Exists(invHash)
+}
+
+// Delete removes an object from the cache and the underlying store. This
+// is part of the ObjectStore interface.
+func (c *Cache) Delete(invHash *hash.Sha) error {
+	// The object is removed from the cache after the store, so that no
+	// Get can read it from the store once it is gone from the cache.
+	err := c.store.Delete(invHash)
+
+	c.mtx.Lock()
+	if e, ok := c.entries[*invHash]; ok {
+		c.remove(e)
+	}
+	c.removals++
+	c.mtx.Unlock()
+	return err
+}
+
+// ExpireBefore removes every object which expires before t from the cache
+// and the underlying store. This is part of the ObjectStore interface.
+func (c *Cache) ExpireBefore(t time.Time) (int, error) {
+	n, err := c.store.ExpireBefore(t)
+
+	c.mtx.Lock()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*cacheEntry).obj.Header().Expiration().Before(t) {
+			c.remove(e)
+		}
+		e = next
+	}
+	c.removals++
+	c.mtx.Unlock()
+	return n, err
+}
+
+// Expired returns the inventory hashes of up to limit objects in the
+// underlying store which expire before t. This is part of the ObjectStore
+// interface.
+func (c *Cache) Expired(t time.Time, limit int) ([]*hash.Sha, error) {
+	return c.store.Expired(t, limit)
+}
+
+// ByType returns the inventory hashes of the objects of the given type in
+// the underlying store. This is part of the ObjectStore interface.
+func (c *Cache) ByType(objType wire.ObjectType) ([]*hash.Sha, error) {
+	return c.store.ByType(objType)
+}
+
+// ByStream returns the inventory hashes of the objects in the given stream
+// in the underlying store. This is part of the ObjectStore interface.
+func (c *Cache) ByStream(stream uint64) ([]*hash.Sha, error) {
+	return c.store.ByStream(stream)
+}
+
+// Export writes a snapshot of the objects in the underlying store which
+// have not expired by now. This is part of the ObjectStore interface.
+func (c *Cache) Export(w io.Writer, now time.Time) (int, error) {
+	return c.store.Export(w, now)
+}
+
+// Import adds the objects in a snapshot which have not expired by now to
+// the underlying store, without caching them. This is part of the
+// ObjectStore interface.
+func (c *Cache) Import(r io.Reader, now time.Time) (int, error) {
+	return c.store.Import(r, now)
+}
+
+// Close empties the cache and closes the underlying store. This is part of
+// the ObjectStore interface.
+func (c *Cache) Close() error {
+	c.mtx.Lock()
+	c.lru.Init()
+	c.entries = make(map[hash.Sha]*list.Element)
+	c.mtx.Unlock()
+	return c.store.Close()
+}
+
+// Len returns the number of objects in the cache.
+func (c *Cache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the number of hits, misses and evictions so far.
+func (c *Cache) Stats() CacheStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.stats
+}
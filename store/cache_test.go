@@ -0,0 +1,135 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestCacheStore tests that a Cache behaves as an ObjectStore.
+func TestCacheStore(t *testing.T) {
+	s := store.NewCache(store.NewMemStore(), &store.CacheConfig{Size: 2})
+	defer s.Close()
+	testStore(t, s)
+}
+
+// TestCache tests that a Cache serves recently used objects from memory,
+// evicts the least recently used and expires objects after the TTL.
+func TestCache(t *testing.T) {
+	now := time.Now()
+	mem := store.NewMemStore()
+	c := store.NewCache(mem, &store.CacheConfig{Size: 2, TTL: time.Minute,
+		Clock: clock.Func(func() time.Time { return now })})
+	defer c.Close()
+
+	expires := now.Add(time.Hour)
+	var hashes []*hash.Sha
+	for _, payload := range []string{"a", "b", "c"} {
+		obj := newObject(expires, wire.ObjectTypeMsg, 1, payload)
+		h, err := mem.Put(obj)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	get := func(h *hash.Sha) {
+		if _, err := c.Get(h); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	check := func(step string, want store.CacheStats) {
+		if got := c.Stats(); got != want {
+			t.Errorf("%s: got %+v want %+v", step, got, want)
+		}
+	}
+
+	get(hashes[0])
+	get(hashes[0])
+	check("first object", store.CacheStats{Hits: 1, Misses: 1})
+
+	// Loading a third object evicts the least recently used.
+	get(hashes[1])
+	get(hashes[0])
+	get(hashes[2])
+	check("eviction", store.CacheStats{Hits: 2, Misses: 3, Evictions: 1})
+	if c.Len() != 2 {
+		t.Errorf("got %d cached objects want 2", c.Len())
+	}
+	get(hashes[0])
+	check("kept", store.CacheStats{Hits: 3, Misses: 3, Evictions: 1})
+
+	// An object is not served after the TTL.
+	now = now.Add(2 * time.Minute)
+	get(hashes[0])
+	check("ttl", store.CacheStats{Hits: 3, Misses: 4, Evictions: 1})
+
+	// A deleted object is not served from the cache.
+	if err := c.Delete(hashes[0]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get(hashes[0]); err != store.ErrNotFound {
+		t.Errorf("got %v want %v", err, store.ErrNotFound)
+	}
+}
+
+// slowStore is an ObjectStore whose Get waits to return until it is told
+// to, after it has read the object.
+type slowStore struct {
+	store.ObjectStore
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (s *slowStore) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	obj, err := s.ObjectStore.Get(invHash)
+	s.read <- struct{}{}
+	<-s.resume
+	return obj, err
+}
+
+// TestCacheDeleteDuringGet tests that an object which is deleted while Get
+// reads it from the store is not put back in the cache.
+func TestCacheDeleteDuringGet(t *testing.T) {
+	mem := store.NewMemStore()
+	slow := &slowStore{ObjectStore: mem, read: make(chan struct{}),
+		resume: make(chan struct{})}
+	c := store.NewCache(slow, &store.CacheConfig{Size: -1})
+	defer c.Close()
+
+	obj := newObject(time.Now().Add(time.Hour), wire.ObjectTypeMsg, 1, "a")
+	h, err := mem.Put(obj)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(h)
+		done <- err
+	}()
+	<-slow.read
+	if err := c.Delete(h); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	close(slow.resume)
+	if err := <-done; err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("got %d cached objects want 0", c.Len())
+	}
+	go func() { <-slow.read }()
+	if _, err := c.Get(h); err != store.ErrNotFound {
+		t.Errorf("got %v want %v", err, store.ErrNotFound)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package store defines ObjectStore, the interface of a persistent set of
+object messages keyed by their inventory hashes, along with two
+implementations. BoltStore keeps objects in an embedded BoltDB database and
+MemStore keeps them in memory, which is useful for tests and short-lived
+processes.
+
+GC removes objects from any ObjectStore once they have been expired for
+longer than a grace period. It removes them in batches so that a large
+collection does not hold up other users of the store.
+A GC may also be given a PrunePolicy, which limits the total size of the
+store, the number of objects in it and the number of objects of each type.
+When a limit is exceeded, the objects which expire soonest are removed
+first.
+
+Cache is an ObjectStore in front of another which keeps the most recently
+used objects in memory for a limited time, so that popular objects requested
+by many peers are not read from disk each time. Its Stats report how many
+lookups it served.
+
+BoltStore.Open returns an object still in its encoded form, ready to be
+written to a peer. A BoltStore opened with MappedReads serves these straight
+from the database's memory map, so relaying a large object does not copy it
+into memory for every send.
+
+ReplayWindow remembers the inventory hashes of objects for a fixed time
+after they are first seen, so that an application can ignore objects which
+it has already processed even after they have been removed from its store.
+It can be saved to a file and loaded again on restart.
+
+An Exporter writes the metadata of the objects in a store, namely their
+inventory hashes, types, versions, streams, sizes and expiration times, as
+CSV or newline-delimited JSON for analysis with other tools.
+
+Export writes the objects of a store which have not expired as a compact
+snapshot, and Import reads one into any other store. A new node can be
+bootstrapped from a snapshot made by a node which it trusts, and an
+operator can move from one kind of store to another:
+
+	n, err := bolt.Export(file, time.Now())
+	...
+	n, err = other.Import(file, time.Now())
+
+A snapshot holds each object as it is encoded on the network and ends with
+a checksum, so that one which is truncated or corrupt is rejected before
+any of its objects are added.
+*/
+package store
@@ -0,0 +1,144 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ExportFormat is the format in which an Exporter writes object metadata.
+type ExportFormat int
+
+const (
+	// ExportCSV writes a header row followed by one row per object.
+	ExportCSV ExportFormat = iota
+
+	// ExportNDJSON writes one JSON object per line.
+	ExportNDJSON
+)
+
+// ErrUnknownExportFormat is returned by NewExporter for a format which it
+// does not know.
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// csvHeader names the columns written by an Exporter in CSV format.
+var csvHeader = []string{"inv_hash", "type", "version", "stream", "size",
+	"expiration"}
+
+// ObjectMetadata is the information about an object which an Exporter
+// writes. It leaves out the nonce and payload.
+type ObjectMetadata struct {
+	InvHash    string    `json:"inv_hash"`
+	Type       string    `json:"type"`
+	Version    uint64    `json:"version"`
+	Stream     uint64    `json:"stream"`
+	Size       int       `json:"size"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// Metadata returns the metadata of an object.
+func Metadata(obj *wire.MsgObject) *ObjectMetadata {
+	b := wire.Encode(obj)
+	h := obj.Header()
+	return &ObjectMetadata{
+		InvHash:    hash.InventoryHash(b).String(),
+		Type:       h.ObjectType.String(),
+		Version:    h.Version,
+		Stream:     h.StreamNumber,
+		Size:       len(b),
+		Expiration: h.Expiration(),
+	}
+}
+
+// Exporter writes object metadata to a stream as CSV or NDJSON, for
+// analysis with tools which know nothing of Bitmessage.
+type Exporter struct {
+	format ExportFormat
+	csv    *csv.Writer
+	json   *json.Encoder
+	header bool
+}
+
+// NewExporter returns an Exporter which writes to w in the given format.
+func NewExporter(w io.Writer, format ExportFormat) (*Exporter, error) {
+	e := &Exporter{format: format}
+	switch format {
+	case ExportCSV:
+		e.csv = csv.NewWriter(w)
+	case ExportNDJSON:
+		e.json = json.NewEncoder(w)
+	default:
+		return nil, ErrUnknownExportFormat
+	}
+	return e, nil
+}
+
+// Write writes the metadata of one object. In CSV format, the header row
+// is written before the first object.
+func (e *Exporter) Write(m *ObjectMetadata) error {
+	if e.format == ExportNDJSON {
+		return e.json.Encode(m)
+	}
+
+	if !e.header {
+		if err := e.csv.Write(csvHeader); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	return e.csv.Write([]string{
+		m.InvHash,
+		m.Type,
+		strconv.FormatUint(m.Version, 10),
+		strconv.FormatUint(m.Stream, 10),
+		strconv.Itoa(m.Size),
+		m.Expiration.Format(time.RFC3339),
+	})
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *Exporter) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// Export writes the metadata of every object in s, those which expire
+// first coming first, and flushes the output. It returns the number of
+// objects written. Objects removed from the store while the export is
+// running are left out.
+func (e *Exporter) Export(s ObjectStore) (int, error) {
+	hashes, err := s.Expired(endOfTime, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, invHash := range hashes {
+		obj, err := s.Get(invHash)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if err = e.Write(Metadata(obj)); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, e.Flush()
+}
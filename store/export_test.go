@@ -0,0 +1,101 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestExport tests that the metadata of every object in a store is
+// exported in order of expiration in both formats.
+func TestExport(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+	objs := []*wire.MsgObject{
+		newObject(now.Add(2*time.Hour), wire.ObjectTypeMsg, 1, "a"),
+		newObject(now.Add(-time.Hour), wire.ObjectTypeBroadcast, 2, "bb"),
+		newObject(now.Add(time.Hour), wire.ObjectType(9), 1, "ccc"),
+	}
+	// The order in which the objects expire.
+	order := []int{1, 2, 0}
+
+	s := store.NewMemStore()
+	defer s.Close()
+	for _, obj := range objs {
+		if _, err := s.Put(obj); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	// CSV.
+	var buf bytes.Buffer
+	e, err := store.NewExporter(&buf, store.ExportCSV)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	n, err := e.Export(s)
+	if err != nil || n != len(objs) {
+		t.Fatalf("Export: got %d, %v want %d", n, err, len(objs))
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(rows) != len(objs)+1 || rows[0][0] != "inv_hash" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	for i, j := range order {
+		obj := objs[j]
+		want := []string{
+			hash.InventoryHash(wire.Encode(obj)).String(),
+			obj.Header().ObjectType.String(),
+			"1",
+			strconv.FormatUint(obj.Header().StreamNumber, 10),
+			strconv.Itoa(len(wire.Encode(obj))),
+			obj.Header().Expiration().Format(time.RFC3339),
+		}
+		if !equal(rows[i+1], want) {
+			t.Errorf("row %d: got %v want %v", i+1, rows[i+1], want)
+		}
+	}
+
+	// NDJSON.
+	buf.Reset()
+	e, err = store.NewExporter(&buf, store.ExportNDJSON)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	if _, err = e.Export(s); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dec := json.NewDecoder(&buf)
+	for _, j := range order {
+		var m store.ObjectMetadata
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		want := store.Metadata(objs[j])
+		if m != *want {
+			t.Errorf("got %+v want %+v", m, *want)
+		}
+	}
+	if dec.More() {
+		t.Error("extra output after the last object")
+	}
+
+	if _, err = store.NewExporter(&buf, store.ExportFormat(7)); err != store.ErrUnknownExportFormat {
+		t.Errorf("got %v want %v", err, store.ErrUnknownExportFormat)
+	}
+}
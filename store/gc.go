@@ -0,0 +1,198 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+const (
+	// ExpirationGracePeriod is the time for which objects are kept after
+	// they expire. Nodes whose clocks are slow may still announce them
+	// for a while, and if we had forgotten them we would download them
+	// again.
+	ExpirationGracePeriod = 3 * time.Hour
+
+	// DefaultGCBatchSize is the number of objects removed in each batch.
+	DefaultGCBatchSize = 100
+
+	// DefaultGCInterval is the time between collections.
+	DefaultGCInterval = 10 * time.Minute
+)
+
+// GCConfig is the configuration of a GC. Zero values are replaced by the
+// defaults.
+type GCConfig struct {
+	// GracePeriod is the time for which objects are kept after they
+	// expire. The default is ExpirationGracePeriod.
+	GracePeriod time.Duration
+
+	// BatchSize is the number of objects removed at a time. The default
+	// is DefaultGCBatchSize.
+	BatchSize int
+
+	// BatchDelay is the time to wait between batches, so that a large
+	// collection does not keep the store busy. The default is no delay.
+	BatchDelay time.Duration
+
+	// Interval is the time between collections when the GC has been
+	// started. The default is DefaultGCInterval.
+	Interval time.Duration
+
+	// OnCollect, if not nil, is called with the number of objects
+	// removed after each collection which was started by the GC itself.
+	OnCollect func(n int, err error)
+
+	// Prune, if not nil, limits the size of the store. It is applied
+	// after expired objects have been removed.
+	Prune *PrunePolicy
+
+	// Clock tells the time against which objects are expired. The
+	// default is clock.System.
+	Clock clock.Clock
+}
+
+// GC removes expired objects from a store.
+type GC struct {
+	store ObjectStore
+	cfg   GCConfig
+	now   func() time.Time
+
+	mtx   sync.Mutex
+	total uint64
+
+	// pruneIndex holds the types and sizes of the objects which were in
+	// the store when it was last pruned.
+	pruneMtx   sync.Mutex
+	pruneIndex map[hash.Sha]pruneInfo
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewGC returns a GC for the given store.
+func NewGC(store ObjectStore, cfg *GCConfig) *GC {
+	g := &GC{store: store}
+	if cfg != nil {
+		g.cfg = *cfg
+	}
+	g.now = clock.Or(g.cfg.Clock).Now
+	if g.cfg.GracePeriod == 0 {
+		g.cfg.GracePeriod = ExpirationGracePeriod
+	}
+	if g.cfg.BatchSize == 0 {
+		g.cfg.BatchSize = DefaultGCBatchSize
+	}
+	if g.cfg.Interval == 0 {
+		g.cfg.Interval = DefaultGCInterval
+	}
+	return g
+}
+
+// Collect removes every object which expired more than the grace period
+// ago, one batch at a time, and then prunes the store if there is a
+// PrunePolicy. It returns the number of objects removed, and returns early
+// if the GC is stopped.
+func (g *GC) Collect() (int, error) {
+	n, err := g.expire()
+	if err != nil || g.cfg.Prune == nil || g.stopped(0) {
+		return n, err
+	}
+
+	m, err := g.Prune()
+	return n + m, err
+}
+
+// expire removes every object which expired more than the grace period
+// ago.
+func (g *GC) expire() (int, error) {
+	cutoff := g.now().Add(-g.cfg.GracePeriod)
+	n := 0
+	for {
+		expired, err := g.store.Expired(cutoff, g.cfg.BatchSize)
+		if err != nil {
+			return n, err
+		}
+		for _, invHash := range expired {
+			if err := g.store.Delete(invHash); err != nil {
+				return n, err
+			}
+			n++
+		}
+
+		g.mtx.Lock()
+		g.total += uint64(len(expired))
+		g.mtx.Unlock()
+
+		if len(expired) < g.cfg.BatchSize {
+			return n, nil
+		}
+
+		if g.stopped(g.cfg.BatchDelay) {
+			return n, nil
+		}
+	}
+}
+
+// stopped waits for d and returns whether the GC was stopped meanwhile.
+func (g *GC) stopped(d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-g.quit:
+			return true
+		default:
+			return false
+		}
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-g.quit:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// Total returns the number of objects which the GC has removed since it
+// was created.
+func (g *GC) Total() uint64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.total
+}
+
+// Start begins collecting at the configured interval.
+func (g *GC) Start() {
+	g.quit = make(chan struct{})
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(g.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := g.Collect()
+				if g.cfg.OnCollect != nil {
+					g.cfg.OnCollect(n, err)
+				}
+			case <-g.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the GC, interrupting any collection between batches.
+func (g *GC) Stop() {
+	close(g.quit)
+	g.wg.Wait()
+}
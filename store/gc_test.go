@@ -0,0 +1,276 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// countingStore counts the batches requested from a store and the objects
+// read from it.
+type countingStore struct {
+	store.ObjectStore
+	batches int
+	gets    int
+}
+
+func (s *countingStore) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	s.gets++
+	return s.ObjectStore.Get(invHash)
+}
+
+func (s *countingStore) Expired(t time.Time, limit int) ([]*hash.Sha, error) {
+	s.batches++
+	return s.ObjectStore.Expired(t, limit)
+}
+
+// TestGC tests that the GC removes objects which expired more than the
+// grace period ago, in batches.
+func TestGC(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	s := &countingStore{ObjectStore: store.NewMemStore()}
+
+	// Ten objects expired four hours ago, three expired two hours ago
+	// and two have not yet expired.
+	var old []*hash.Sha
+	for i := 0; i < 15; i++ {
+		exp := now.Add(-4 * time.Hour)
+		if i >= 10 {
+			exp = now.Add(-2 * time.Hour)
+		}
+		if i >= 13 {
+			exp = now.Add(time.Hour)
+		}
+		h, err := s.Put(newObject(exp, wire.ObjectTypeMsg, 1,
+			strconv.Itoa(i)))
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if i < 10 {
+			old = append(old, h)
+		}
+	}
+
+	g := store.NewGC(s, &store.GCConfig{BatchSize: 4,
+		Clock: clock.Func(func() time.Time { return now })})
+
+	n, err := g.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if n != 10 {
+		t.Errorf("Collect: got %d removed want %d", n, 10)
+	}
+	if s.batches != 3 {
+		t.Errorf("Collect: got %d batches want %d", s.batches, 3)
+	}
+	for _, h := range old {
+		if ok, _ := s.Exists(h); ok {
+			t.Errorf("object %s was not removed", h)
+		}
+	}
+
+	// After the grace period, the rest of the expired objects go.
+	now = now.Add(2 * time.Hour)
+	if n, _ = g.Collect(); n != 3 {
+		t.Errorf("Collect: got %d removed want %d", n, 3)
+	}
+	if total := g.Total(); total != 13 {
+		t.Errorf("Total: got %d want %d", total, 13)
+	}
+}
+
+// TestGCStart tests that a started GC collects periodically and reports
+// what it removed.
+func TestGCStart(t *testing.T) {
+	s := store.NewMemStore()
+	s.Put(newObject(time.Now().Add(-4*time.Hour), wire.ObjectTypeMsg, 1, "a"))
+
+	collected := make(chan int, 10)
+	g := store.NewGC(s, &store.GCConfig{
+		Interval: 5 * time.Millisecond,
+		OnCollect: func(n int, err error) {
+			collected <- n
+		},
+	})
+	g.Start()
+	defer g.Stop()
+
+	select {
+	case n := <-collected:
+		if n != 1 {
+			t.Errorf("OnCollect: got %d removed want %d", n, 1)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GC did not collect")
+	}
+}
+
+// TestGCPrune tests that a PrunePolicy removes the objects which expire
+// soonest until the store is within its limits.
+func TestGCPrune(t *testing.T) {
+	now := time.Unix(1000000, 0)
+
+	// put adds objects of the given type which expire i hours from now,
+	// with payloads of ten bytes.
+	put := func(s store.ObjectStore, objType wire.ObjectType, hours ...int) []*hash.Sha {
+		var hashes []*hash.Sha
+		for _, i := range hours {
+			h, err := s.Put(newObject(now.Add(time.Duration(i)*time.Hour),
+				objType, 1, fmt.Sprintf("%10d", i)))
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			hashes = append(hashes, h)
+		}
+		return hashes
+	}
+	size := newObject(now, wire.ObjectTypeMsg, 1, "0123456789").EncodedSize()
+
+	tests := []struct {
+		policy  store.PrunePolicy
+		removed []int // indices of the removed objects
+	}{
+		{store.PrunePolicy{}, nil},
+		{store.PrunePolicy{MaxObjects: 4}, []int{0, 4}},
+		{store.PrunePolicy{MaxBytes: int64(3 * size)}, []int{0, 4, 1}},
+		{store.PrunePolicy{TypeQuotas: map[wire.ObjectType]int{
+			wire.ObjectTypeBroadcast: 1,
+		}}, []int{4}},
+		// The quota is applied first, and then the object with the
+		// soonest expiration of those left.
+		{store.PrunePolicy{
+			MaxObjects: 3,
+			TypeQuotas: map[wire.ObjectType]int{wire.ObjectTypeMsg: 2},
+		}, []int{0, 1, 4}},
+	}
+
+	for i, test := range tests {
+		s := store.NewMemStore()
+		// The messages expire in 1, 3, 4 and 6 hours and the
+		// broadcasts in 2 and 5.
+		hashes := put(s, wire.ObjectTypeMsg, 1, 3, 4, 6)
+		hashes = append(hashes, put(s, wire.ObjectTypeBroadcast, 2, 5)...)
+
+		policy := test.policy
+		g := store.NewGC(s, &store.GCConfig{Prune: &policy,
+			Clock: clock.Func(func() time.Time { return now })})
+
+		n, err := g.Collect()
+		if err != nil {
+			t.Fatalf("test #%d: Collect: %v", i, err)
+		}
+		if n != len(test.removed) {
+			t.Errorf("test #%d: got %d removed want %d", i, n,
+				len(test.removed))
+		}
+
+		removed := make(map[int]bool)
+		for _, j := range test.removed {
+			removed[j] = true
+		}
+		for j, h := range hashes {
+			if ok, _ := s.Exists(h); ok == removed[j] {
+				t.Errorf("test #%d: object %d exists %v", i, j, ok)
+			}
+		}
+	}
+}
+
+// TestGCPruneIndex tests that pruning reads each object only once, and
+// that an object which is deleted while the store is pruned is skipped.
+func TestGCPruneIndex(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	s := &countingStore{ObjectStore: store.NewMemStore()}
+	var hashes []*hash.Sha
+	for i := 1; i <= 4; i++ {
+		h, err := s.Put(newObject(now.Add(time.Duration(i)*time.Hour),
+			wire.ObjectTypeMsg, 1, strconv.Itoa(i)))
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	g := store.NewGC(s, &store.GCConfig{
+		Prune: &store.PrunePolicy{MaxBytes: 1 << 20},
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+	if _, err := g.Prune(); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if s.gets != 4 {
+		t.Errorf("first prune: got %d reads want 4", s.gets)
+	}
+
+	h, err := s.Put(newObject(now.Add(5*time.Hour), wire.ObjectTypeMsg, 1, "5"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	s.gets = 0
+	if _, err := g.Prune(); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if s.gets != 1 {
+		t.Errorf("second prune: got %d reads want 1", s.gets)
+	}
+
+	// An object which is listed but gone by the time it is read does not
+	// stop the prune.
+	size := newObject(now, wire.ObjectTypeMsg, 1, "0").EncodedSize()
+	vanishing := &vanishingStore{ObjectStore: s.ObjectStore, gone: h}
+	g = store.NewGC(vanishing, &store.GCConfig{
+		Prune: &store.PrunePolicy{MaxBytes: int64(3 * size)},
+		Clock: clock.Func(func() time.Time { return now }),
+	})
+	n, err := g.Prune()
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d removed want 1", n)
+	}
+	if ok, _ := s.Exists(hashes[0]); ok {
+		t.Errorf("object expiring soonest was not removed")
+	}
+}
+
+// vanishingStore is a store in which one object is deleted just before it
+// is read, as if by another goroutine.
+type vanishingStore struct {
+	store.ObjectStore
+	gone *hash.Sha
+}
+
+func (s *vanishingStore) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	if *invHash == *s.gone {
+		s.ObjectStore.Delete(invHash)
+	}
+	return s.ObjectStore.Get(invHash)
+}
+
+// TestGCClock tests that the GC expires objects by the time of its Clock.
+func TestGCClock(t *testing.T) {
+	c := clock.NewManual(time.Unix(1000000, 0))
+	s := store.NewMemStore()
+	s.Put(newObject(c.Now().Add(time.Hour), wire.ObjectTypeMsg, 1, "a"))
+
+	g := store.NewGC(s, &store.GCConfig{Clock: c})
+	if n, err := g.Collect(); n != 0 || err != nil {
+		t.Errorf("Collect: got %d, %v want 0, nil", n, err)
+	}
+	c.Advance(time.Hour + store.ExpirationGracePeriod + time.Second)
+	if n, err := g.Collect(); n != 1 || err != nil {
+		t.Errorf("Collect: got %d, %v want 1, nil", n, err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/boltdb/bolt"
+)
+
+// ErrReadOnly is returned when an ObjectReader is asked to decode.
+var ErrReadOnly = errors.New("object reader is read only")
+
+// ObjectReader is an encoded object read from a BoltStore. If the store was
+// opened with MappedReads, the encoding is not copied out of the database's
+// memory map, and a read transaction is held open until Close is called.
+// Close should therefore be called as soon as the object has been sent,
+// since an open read transaction keeps the database from growing its
+// memory map. Neither the ObjectReader nor any slice returned by
+// EncodedBytes may be used after Close.
+//
+// An ObjectReader can be read like a bytes.Reader. It also implements
+// wire.Message, so it can be given to wire.WriteMessage, which writes the
+// encoding without copying it.
+type ObjectReader struct {
+	*bytes.Reader
+	data []byte
+
+	mtx sync.Mutex
+	tx  *bolt.Tx
+}
+
+// EncodedBytes returns the encoded object. This is part of the
+// wire.Preencoded interface.
+func (r *ObjectReader) EncodedBytes() []byte {
+	return r.data
+}
+
+// EncodedSize returns the length of the encoded object. This is part of
+// the wire.Sizer interface.
+func (r *ObjectReader) EncodedSize() int {
+	return len(r.data)
+}
+
+// Encode writes the encoded object to w. This is part of the wire.Message
+// interface implementation.
+func (r *ObjectReader) Encode(w io.Writer) error {
+	_, err := w.Write(r.data)
+	return err
+}
+
+// Decode always returns ErrReadOnly. This is part of the wire.Message
+// interface implementation.
+func (r *ObjectReader) Decode(io.Reader) error {
+	return ErrReadOnly
+}
+
+// Command returns the protocol command string for the message. This is
+// part of the wire.Message interface implementation.
+func (r *ObjectReader) Command() string {
+	return wire.CmdObject
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver. This is part of the wire.Message interface implementation.
+func (r *ObjectReader) MaxPayloadLength() int {
+	return wire.MaxPayloadOfMsgObject
+}
+
+// Close releases the read transaction, if any. It is safe to call more
+// than once.
+func (r *ObjectReader) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.tx == nil {
+		return nil
+	}
+	err := r.tx.Rollback()
+	r.tx = nil
+	return err
+}
+
+// newObjectReader returns an ObjectReader for data, which belongs to tx if
+// tx is not nil.
+func newObjectReader(data []byte, tx *bolt.Tx) *ObjectReader {
+	return &ObjectReader{
+		Reader: bytes.NewReader(data),
+		data:   data,
+		tx:     tx,
+	}
+}
+
+// Open returns a reader for the encoded object with the given inventory
+// hash, or ErrNotFound. Unless the store was opened with MappedReads, the
+// encoding is copied and the reader need not be closed.
+func (s *BoltStore) Open(invHash *hash.Sha) (*ObjectReader, error) {
+	if !s.mapped {
+		var data []byte
+		err := s.db.View(func(tx *bolt.Tx) error {
+			encoded := tx.Bucket(objectsBucket).Get(invHash[:])
+			if encoded == nil {
+				return ErrNotFound
+			}
+			data = append([]byte{}, encoded...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newObjectReader(data, nil), nil
+	}
+
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	encoded := tx.Bucket(objectsBucket).Get(invHash[:])
+	if encoded == nil {
+		tx.Rollback()
+		return nil, ErrNotFound
+	}
+	return newObjectReader(encoded, tx), nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestOpen tests reading encoded objects from a BoltStore, with and without
+// MappedReads.
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, mapped := range []bool{false, true} {
+		path := filepath.Join(dir, "objects.db")
+		s, err := store.OpenBoltStore(path,
+			&store.BoltOptions{MappedReads: mapped})
+		if err != nil {
+			t.Fatalf("OpenBoltStore #%d: %v", i, err)
+		}
+
+		obj := newObject(time.Now().Add(time.Hour), wire.ObjectTypeMsg, 1,
+			string(bytes.Repeat([]byte{0xaa}, 5000)))
+		h, err := s.Put(obj)
+		if err != nil {
+			t.Fatalf("Put #%d: %v", i, err)
+		}
+		encoded := wire.Encode(obj)
+
+		r, err := s.Open(h)
+		if err != nil {
+			t.Fatalf("Open #%d: %v", i, err)
+		}
+		if !bytes.Equal(r.EncodedBytes(), encoded) {
+			t.Errorf("EncodedBytes #%d: got %x want %x", i,
+				r.EncodedBytes(), encoded)
+		}
+
+		// Writing the reader as a message must give the same result as
+		// writing the object.
+		var got, want bytes.Buffer
+		if err = wire.WriteMessage(&got, r, wire.MainNet); err != nil {
+			t.Errorf("WriteMessage #%d: %v", i, err)
+		}
+		wire.WriteMessage(&want, obj, wire.MainNet)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("WriteMessage #%d: got %x want %x", i, got.Bytes(),
+				want.Bytes())
+		}
+
+		read, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(read, encoded) {
+			t.Errorf("ReadAll #%d: got %x, %v want %x, nil", i, read, err,
+				encoded)
+		}
+		if err = r.Decode(bytes.NewReader(encoded)); err != store.ErrReadOnly {
+			t.Errorf("Decode #%d: got %v want %v", i, err, store.ErrReadOnly)
+		}
+		if err = r.Close(); err != nil {
+			t.Errorf("Close #%d: %v", i, err)
+		}
+		if err = r.Close(); err != nil {
+			t.Errorf("Close #%d again: %v", i, err)
+		}
+
+		if _, err = s.Open(&hash.Sha{}); err != store.ErrNotFound {
+			t.Errorf("Open #%d of missing object: got %v want %v", i, err,
+				store.ErrNotFound)
+		}
+
+		if err = s.Close(); err != nil {
+			t.Fatalf("Close #%d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// MemStore is an ObjectStore which keeps objects in memory.
+type MemStore struct {
+	mtx     sync.RWMutex
+	objects map[hash.Sha]*wire.MsgObject
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		objects: make(map[hash.Sha]*wire.MsgObject),
+	}
+}
+
+// Put adds an object to the store. A copy is kept so that the caller may
+// not modify it. This is part of the ObjectStore interface.
+func (s *MemStore) Put(obj *wire.MsgObject) (*hash.Sha, error) {
+	invHash := hash.InventoryHash(wire.Encode(obj))
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.objects[*invHash]; !ok {
+		s.objects[*invHash] = obj.Copy()
+	}
+	return invHash, nil
+}
+
+// Get returns the object with the given inventory hash. This is part of
+// the ObjectStore interface.
+func (s *MemStore) Get(invHash *hash.Sha) (*wire.MsgObject, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	obj, ok := s.objects[*invHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return obj.Copy(), nil
+}
+
+// Exists returns whether the object is in the store. This is part of the
+// ObjectStore interface.
+func (s *MemStore) Exists(invHash *hash.Sha) (bool, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	_, ok := s.objects[*invHash]
+	return ok, nil
+}
+
+// Delete removes an object from the store. This is part of the
+// ObjectStore interface.
+func (s *MemStore) Delete(invHash *hash.Sha) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.objects, *invHash)
+	return nil
+}
+
+// ExpireBefore removes every object which expires before t. This is part
+// of the ObjectStore interface.
+func (s *MemStore) ExpireBefore(t time.Time) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	n := 0
+	for k, obj := range s.objects {
+		if obj.Header().Expiration().Before(t) {
+			delete(s.objects, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Expired returns the inventory hashes of up to limit objects which expire
+// before t. This is part of the ObjectStore interface.
+func (s *MemStore) Expired(t time.Time, limit int) ([]*hash.Sha, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	type entry struct {
+		invHash    hash.Sha
+		expiration time.Time
+	}
+	var expired []entry
+	for k, obj := range s.objects {
+		if exp := obj.Header().Expiration(); exp.Before(t) {
+			expired = append(expired, entry{k, exp})
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].expiration.Before(expired[j].expiration)
+	})
+
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	hashes := make([]*hash.Sha, len(expired))
+	for i := range expired {
+		hashes[i] = &expired[i].invHash
+	}
+	return hashes, nil
+}
+
+// filter returns the inventory hashes of the objects whose headers satisfy
+// f.
+func (s *MemStore) filter(f func(*wire.ObjectHeader) bool) []*hash.Sha {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var hashes []*hash.Sha
+	for k, obj := range s.objects {
+		if f(obj.Header()) {
+			invHash := k
+			hashes = append(hashes, &invHash)
+		}
+	}
+	return hashes
+}
+
+// ByType returns the inventory hashes of the objects of the given type.
+// This is part of the ObjectStore interface.
+func (s *MemStore) ByType(objType wire.ObjectType) ([]*hash.Sha, error) {
+	return s.filter(func(h *wire.ObjectHeader) bool {
+		return h.ObjectType == objType
+	}), nil
+}
+
+// ByStream returns the inventory hashes of the objects in the given
+// stream. This is part of the ObjectStore interface.
+func (s *MemStore) ByStream(stream uint64) ([]*hash.Sha, error) {
+	return s.filter(func(h *wire.ObjectHeader) bool {
+		return h.StreamNumber == stream
+	}), nil
+}
+
+// Export writes a snapshot of the objects which have not expired by now.
+// This is part of the ObjectStore interface.
+func (s *MemStore) Export(w io.Writer, now time.Time) (int, error) {
+	return exportSnapshot(s, w, now)
+}
+
+// Import adds the objects in a snapshot which have not expired by now. This
+// is part of the ObjectStore interface.
+func (s *MemStore) Import(r io.Reader, now time.Time) (int, error) {
+	return importSnapshot(r, now, func(objs []*wire.MsgObject) error {
+		for _, obj := range objs {
+			if _, err := s.Put(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close does nothing. This is part of the ObjectStore interface.
+func (s *MemStore) Close() error {
+	return nil
+}
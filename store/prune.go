@@ -0,0 +1,167 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"errors"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// PrunePolicy limits the size of a store so that a device with little
+// space can keep up with the network. When a limit is exceeded, the
+// objects which expire soonest are removed first, since they are the
+// least use to other peers. Zero values mean no limit.
+type PrunePolicy struct {
+	// MaxBytes is the largest total size of the objects in the store,
+	// measured by their encoded sizes. The space used on disk is
+	// somewhat larger.
+	MaxBytes int64
+
+	// MaxObjects is the largest number of objects in the store.
+	MaxObjects int
+
+	// TypeQuotas limits the number of objects of each type. Types which
+	// are not in the map are not limited.
+	TypeQuotas map[wire.ObjectType]int
+}
+
+// pruneInfo is what pruning needs to know of an object. Objects do not
+// change once they are in a store, so a GC reads each one only the first
+// time it is pruned and remembers its pruneInfo after that.
+type pruneInfo struct {
+	objType wire.ObjectType
+	size    int64
+}
+
+// pruneEntry is an object in the store which may be pruned.
+type pruneEntry struct {
+	invHash *hash.Sha
+	pruneInfo
+	removed bool
+}
+
+// Prune removes objects from the store until it is within the limits of
+// the PrunePolicy, removing those which expire soonest first, and returns
+// the number removed. The type quotas are applied before the limits on
+// the whole store. It does nothing if the GC has no PrunePolicy.
+func (g *GC) Prune() (int, error) {
+	p := g.cfg.Prune
+	if p == nil {
+		return 0, nil
+	}
+
+	g.pruneMtx.Lock()
+	defer g.pruneMtx.Unlock()
+
+	entries, err := g.pruneEntries(p.MaxBytes > 0 || len(p.TypeQuotas) > 0)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	defer func() {
+		g.mtx.Lock()
+		g.total += uint64(n)
+		g.mtx.Unlock()
+	}()
+
+	remove := func(e *pruneEntry) error {
+		if err := g.store.Delete(e.invHash); err != nil {
+			return err
+		}
+		e.removed = true
+		n++
+		return nil
+	}
+
+	count := make(map[wire.ObjectType]int)
+	for _, e := range entries {
+		count[e.objType]++
+	}
+	for _, e := range entries {
+		quota, ok := p.TypeQuotas[e.objType]
+		if !ok || count[e.objType] <= quota {
+			continue
+		}
+		if err := remove(e); err != nil {
+			return n, err
+		}
+		count[e.objType]--
+	}
+
+	objects := len(entries) - n
+	var size int64
+	for _, e := range entries {
+		if !e.removed {
+			size += e.size
+		}
+	}
+	over := func() bool {
+		return p.MaxObjects > 0 && objects > p.MaxObjects ||
+			p.MaxBytes > 0 && size > p.MaxBytes
+	}
+	for _, e := range entries {
+		if !over() {
+			break
+		}
+		if e.removed {
+			continue
+		}
+		if err := remove(e); err != nil {
+			return n, err
+		}
+		objects--
+		size -= e.size
+	}
+
+	return n, nil
+}
+
+// pruneEntries lists the objects in the store, those which expire soonest
+// coming first. If their types and sizes are needed, they are taken from
+// the index of the GC, and only the objects which are not in it yet are
+// read. Objects which are deleted in the meantime are left out. It must be
+// called with pruneMtx held.
+func (g *GC) pruneEntries(read bool) ([]*pruneEntry, error) {
+	hashes, err := g.store.Expired(endOfTime, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The index is rebuilt from the objects which are listed, so that
+	// those which have been removed from the store are forgotten.
+	var index map[hash.Sha]pruneInfo
+	if read {
+		index = make(map[hash.Sha]pruneInfo, len(hashes))
+	}
+
+	entries := make([]*pruneEntry, 0, len(hashes))
+	for _, invHash := range hashes {
+		e := &pruneEntry{invHash: invHash}
+		if read {
+			info, ok := g.pruneIndex[*invHash]
+			if !ok {
+				obj, err := g.store.Get(invHash)
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				info = pruneInfo{
+					objType: obj.Header().ObjectType,
+					size:    int64(obj.EncodedSize()),
+				}
+			}
+			index[*invHash] = info
+			e.pruneInfo = info
+		}
+		entries = append(entries, e)
+	}
+	g.pruneIndex = index
+	return entries, nil
+}
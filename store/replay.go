@@ -0,0 +1,229 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+const (
+	// DefaultReplayWindow is the time for which a ReplayWindow remembers an
+	// object. It is the maximum time to live of an object, plus three hours
+	// for nodes whose clocks run fast and the grace period for which stores
+	// keep expired objects, so an object is remembered for as long as any
+	// node could still relay it.
+	DefaultReplayWindow = wire.MaxObjectTTL + 3*time.Hour + ExpirationGracePeriod
+
+	// replayVersion is the current version of the on-disk format.
+	replayVersion = 1
+)
+
+// ErrUnknownReplayVersion is returned when a saved replay window has a
+// format which is not understood.
+var ErrUnknownReplayVersion = errors.New("unknown version of saved replay window")
+
+// ReplayWindow is a set of the inventory hashes of objects which have been
+// seen recently. Each hash is remembered for a fixed time after it was
+// first seen, so that an application can ignore objects which it has
+// already processed even after they have been removed from its store.
+//
+// A ReplayWindow may be saved to a file and loaded again when the
+// application restarts. It is safe for concurrent use.
+type ReplayWindow struct {
+	window time.Duration
+	path   string
+	now    func() time.Time
+
+	mtx  sync.Mutex
+	seen map[hash.Sha]time.Time
+}
+
+// ReplayConfig is the configuration of a ReplayWindow.
+type ReplayConfig struct {
+	// Window is the time for which a hash is remembered after it was
+	// first seen. The default is DefaultReplayWindow.
+	Window time.Duration
+
+	// Path, if not empty, is the file in which Save and Load keep the
+	// window.
+	Path string
+
+	// Clock tells the time at which hashes are seen. The default is
+	// clock.System.
+	Clock clock.Clock
+}
+
+// serializedReplayWindow is the on-disk form of a ReplayWindow. The times
+// at which hashes were first seen are stored as unix times.
+type serializedReplayWindow struct {
+	Version int
+	Seen    map[string]int64
+}
+
+// NewReplayWindow returns a new ReplayWindow. cfg may be nil, in which case
+// the defaults are used.
+func NewReplayWindow(cfg *ReplayConfig) *ReplayWindow {
+	w := &ReplayWindow{
+		window: DefaultReplayWindow,
+		now:    clock.System.Now,
+		seen:   make(map[hash.Sha]time.Time),
+	}
+	if cfg != nil {
+		if cfg.Window != 0 {
+			w.window = cfg.Window
+		}
+		w.path = cfg.Path
+		w.now = clock.Or(cfg.Clock).Now
+	}
+	return w
+}
+
+// fresh returns whether a hash first seen at t is still remembered.
+func (w *ReplayWindow) fresh(t, now time.Time) bool {
+	return now.Sub(t) < w.window
+}
+
+// Contains returns whether the hash has been seen within the window.
+func (w *ReplayWindow) Contains(invHash *hash.Sha) bool {
+	now := w.now()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	t, ok := w.seen[*invHash]
+	return ok && w.fresh(t, now)
+}
+
+// Seen records the hash and returns whether it had already been seen
+// within the window. A hash which is seen again is still forgotten at the
+// end of the window after it was first seen.
+func (w *ReplayWindow) Seen(invHash *hash.Sha) bool {
+	now := w.now()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if t, ok := w.seen[*invHash]; ok && w.fresh(t, now) {
+		return true
+	}
+	w.seen[*invHash] = now
+	return false
+}
+
+// Len returns the number of hashes held, including any which have left the
+// window but have not yet been pruned.
+func (w *ReplayWindow) Len() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return len(w.seen)
+}
+
+// Prune forgets the hashes which have left the window and returns how many
+// there were.
+func (w *ReplayWindow) Prune() int {
+	now := w.now()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	n := 0
+	for h, t := range w.seen {
+		if !w.fresh(t, now) {
+			delete(w.seen, h)
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the hashes which are still within the window to the file
+// given in the ReplayConfig. It does nothing if there is no file.
+func (w *ReplayWindow) Save() error {
+	if w.path == "" {
+		return nil
+	}
+	now := w.now()
+
+	w.mtx.Lock()
+	srw := &serializedReplayWindow{
+		Version: replayVersion,
+		Seen:    make(map[string]int64, len(w.seen)),
+	}
+	for h, t := range w.seen {
+		if w.fresh(t, now) {
+			srw.Seen[h.String()] = t.Unix()
+		}
+	}
+	w.mtx.Unlock()
+
+	// Write to a temporary file first so that a crash cannot leave a
+	// partially written file.
+	tmp := w.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(f).Encode(srw); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// Load reads the hashes from the file given in the ReplayConfig and adds
+// those which are still within the window. It is not an error for the file
+// not to exist, or for there to be no file.
+func (w *ReplayWindow) Load() error {
+	if w.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var srw serializedReplayWindow
+	if err = json.NewDecoder(f).Decode(&srw); err != nil {
+		return err
+	}
+	if srw.Version != replayVersion {
+		return ErrUnknownReplayVersion
+	}
+
+	seen := make(map[hash.Sha]time.Time, len(srw.Seen))
+	for s, unix := range srw.Seen {
+		h, err := hash.NewShaFromStr(s)
+		if err != nil {
+			return err
+		}
+		seen[*h] = time.Unix(unix, 0)
+	}
+
+	now := w.now()
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for h, t := range seen {
+		if !w.fresh(t, now) {
+			continue
+		}
+		if old, ok := w.seen[h]; !ok || t.Before(old) {
+			w.seen[h] = t
+		}
+	}
+	return nil
+}
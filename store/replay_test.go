@@ -0,0 +1,112 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/clock"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+)
+
+// TestReplayWindow tests that hashes are remembered for the length of the
+// window after they are first seen.
+func TestReplayWindow(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	w := store.NewReplayWindow(&store.ReplayConfig{Window: time.Hour,
+		Clock: clock.Func(func() time.Time { return now })})
+
+	a := hash.InventoryHash([]byte("a"))
+	b := hash.InventoryHash([]byte("b"))
+
+	if w.Seen(a) {
+		t.Error("Seen: new hash reported as seen")
+	}
+	if !w.Seen(a) {
+		t.Error("Seen: hash not reported as seen the second time")
+	}
+	if w.Contains(b) {
+		t.Error("Contains: got true for a hash never seen")
+	}
+
+	// Seeing a hash again does not extend its time in the window.
+	now = now.Add(40 * time.Minute)
+	w.Seen(b)
+	w.Seen(a)
+	now = now.Add(30 * time.Minute)
+	if w.Contains(a) {
+		t.Error("Contains: hash remembered past the window")
+	}
+	if !w.Contains(b) {
+		t.Error("Contains: hash forgotten within the window")
+	}
+
+	if n := w.Prune(); n != 1 {
+		t.Errorf("Prune: got %d removed want %d", n, 1)
+	}
+	if n := w.Len(); n != 1 {
+		t.Errorf("Len: got %d want %d", n, 1)
+	}
+}
+
+// TestReplayWindowSave tests that a saved window can be loaded again and
+// that hashes which have left the window are not kept.
+func TestReplayWindowSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "replay.json")
+
+	now := time.Unix(1000000, 0)
+	c := clock.Func(func() time.Time { return now })
+	cfg := &store.ReplayConfig{Window: time.Hour, Path: path, Clock: c}
+
+	a := hash.InventoryHash([]byte("a"))
+	b := hash.InventoryHash([]byte("b"))
+
+	w := store.NewReplayWindow(cfg)
+
+	// Loading a file which does not exist is not an error.
+	if err = w.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	w.Seen(a)
+	now = now.Add(30 * time.Minute)
+	w.Seen(b)
+	if err = w.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	now = now.Add(40 * time.Minute)
+	loaded := store.NewReplayWindow(cfg)
+	if err = loaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Contains(a) {
+		t.Error("Load: kept a hash which had left the window")
+	}
+	if !loaded.Contains(b) {
+		t.Error("Load: lost a hash within the window")
+	}
+	if n := loaded.Len(); n != 1 {
+		t.Errorf("Len: got %d want %d", n, 1)
+	}
+
+	// A file in an unknown format is an error.
+	if err = ioutil.WriteFile(path, []byte(`{"Version":2}`), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err = loaded.Load(); err != store.ErrUnknownReplayVersion {
+		t.Errorf("Load: got %v want %v", err, store.ErrUnknownReplayVersion)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// A snapshot begins with snapshotMagic and the version of the format as a
+// var_int. Each object follows as a var_int length and its encoding, and
+// the end is marked by a length of zero, the number of objects as a
+// var_int, and the SHA-512 hash of everything before the hash.
+var snapshotMagic = []byte("BMSS")
+
+const (
+	// snapshotVersion is the version of the snapshot format which is
+	// written.
+	snapshotVersion = 1
+
+	// snapshotBatch is the number of objects which Import adds at once.
+	snapshotBatch = 1000
+)
+
+var (
+	// ErrInvalidSnapshot is returned by Import for a snapshot which is
+	// malformed, truncated or does not match its checksum.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrUnknownSnapshotVersion is returned by Import for a snapshot in a
+	// later version of the format.
+	ErrUnknownSnapshotVersion = errors.New("unknown snapshot version")
+)
+
+// snapshotWriter writes a snapshot.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	sum hash.Hash
+	out io.Writer
+	n   int
+}
+
+// newSnapshotWriter writes the start of a snapshot to w.
+func newSnapshotWriter(w io.Writer) (*snapshotWriter, error) {
+	sw := &snapshotWriter{w: bufio.NewWriter(w), sum: sha512.New()}
+	sw.out = io.MultiWriter(sw.w, sw.sum)
+	if _, err := sw.out.Write(snapshotMagic); err != nil {
+		return nil, err
+	}
+	if err := bmutil.WriteVarInt(sw.out, snapshotVersion); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// write writes an encoded object.
+func (sw *snapshotWriter) write(encoded []byte) error {
+	if err := bmutil.WriteVarInt(sw.out, uint64(len(encoded))); err != nil {
+		return err
+	}
+	if _, err := sw.out.Write(encoded); err != nil {
+		return err
+	}
+	sw.n++
+	return nil
+}
+
+// close writes the end of the snapshot and flushes it.
+func (sw *snapshotWriter) close() error {
+	if err := bmutil.WriteVarInt(sw.out, 0); err != nil {
+		return err
+	}
+	if err := bmutil.WriteVarInt(sw.out, uint64(sw.n)); err != nil {
+		return err
+	}
+	if _, err := sw.w.Write(sw.sum.Sum(nil)); err != nil {
+		return err
+	}
+	return sw.w.Flush()
+}
+
+// exportSnapshot writes a snapshot of the objects in s which have not
+// expired by now. It is used by stores which have no faster way to do so.
+func exportSnapshot(s ObjectStore, w io.Writer, now time.Time) (int, error) {
+	hashes, err := s.Expired(endOfTime, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	sw, err := newSnapshotWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	for _, invHash := range hashes {
+		obj, err := s.Get(invHash)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return sw.n, err
+		}
+		if obj.Header().Expiration().Before(now) {
+			continue
+		}
+		if err = sw.write(wire.Encode(obj)); err != nil {
+			return sw.n, err
+		}
+	}
+	return sw.n, sw.close()
+}
+
+// snapshotError returns ErrInvalidSnapshot with a reason.
+func snapshotError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSnapshot, fmt.Sprintf(format, args...))
+}
+
+// importSnapshot reads a snapshot and calls add with batches of the objects
+// in it which have not expired by now. It returns the number of objects
+// given to add. The whole snapshot is read and checked before add is first
+// called, so nothing is added from a snapshot which is invalid.
+func importSnapshot(r io.Reader, now time.Time, add func([]*wire.MsgObject) error) (int, error) {
+	br := bufio.NewReader(r)
+	sum := sha512.New()
+	in := io.TeeReader(br, sum)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(in, magic); err != nil ||
+		!bytes.Equal(magic, snapshotMagic) {
+		return 0, snapshotError("not a snapshot")
+	}
+	version, err := bmutil.ReadVarInt(in)
+	if err != nil {
+		return 0, snapshotError("no version")
+	}
+	if version != snapshotVersion {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownSnapshotVersion, version)
+	}
+
+	var objs []*wire.MsgObject
+	read := 0
+	for {
+		length, err := wire.ReadBoundedLength(in, wire.MaxMessagePayload,
+			"importSnapshot", "object")
+		if _, ok := err.(*wire.MessageError); ok {
+			return 0, snapshotError("object %d is too large", read)
+		}
+		if err != nil {
+			return 0, snapshotError("truncated after %d objects", read)
+		}
+		if length == 0 {
+			break
+		}
+
+		encoded := make([]byte, length)
+		if _, err := io.ReadFull(in, encoded); err != nil {
+			return 0, snapshotError("truncated after %d objects", read)
+		}
+		obj, err := wire.DecodeMsgObject(encoded)
+		if err != nil {
+			return 0, snapshotError("object %d: %v", read, err)
+		}
+		read++
+
+		if !obj.Header().Expiration().Before(now) {
+			objs = append(objs, obj)
+		}
+	}
+
+	count, err := bmutil.ReadVarInt(in)
+	if err != nil || count != uint64(read) {
+		return 0, snapshotError("wrong number of objects")
+	}
+	expected := sum.Sum(nil)
+	checksum := make([]byte, sha512.Size)
+	if _, err := io.ReadFull(br, checksum); err != nil ||
+		!bytes.Equal(checksum, expected) {
+		return 0, snapshotError("checksum does not match")
+	}
+
+	n := 0
+	for len(objs) > 0 {
+		batch := objs
+		if len(batch) > snapshotBatch {
+			batch = batch[:snapshotBatch]
+		}
+		if err := add(batch); err != nil {
+			return n, err
+		}
+		n += len(batch)
+		objs = objs[len(batch):]
+	}
+	return n, nil
+}
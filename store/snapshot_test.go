@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestSnapshot tests moving objects between stores with a snapshot.
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bolt, err := store.NewBoltStore(filepath.Join(dir, "objects.db"))
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer bolt.Close()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	objs := []*wire.MsgObject{
+		newObject(now.Add(time.Hour), wire.ObjectTypeMsg, 1, "a"),
+		newObject(now.Add(3*time.Hour), wire.ObjectTypeBroadcast, 1, "b"),
+		newObject(now.Add(-time.Hour), wire.ObjectTypeMsg, 2, "c"),
+	}
+	mem := store.NewMemStore()
+	for _, obj := range objs {
+		mem.Put(obj)
+	}
+
+	// Expired objects are left out of the snapshot.
+	var snapshot bytes.Buffer
+	if n, err := mem.Export(&snapshot, now); n != 2 || err != nil {
+		t.Fatalf("Export: got %d, %v want 2, nil", n, err)
+	}
+	if n, err := bolt.Import(bytes.NewReader(snapshot.Bytes()), now); n != 2 ||
+		err != nil {
+		t.Fatalf("Import: got %d, %v want 2, nil", n, err)
+	}
+	for i, obj := range objs {
+		ok, err := bolt.Exists(hash.InventoryHash(wire.Encode(obj)))
+		if err != nil || ok != (i < 2) {
+			t.Errorf("Exists #%d: got %v, %v want %v, nil", i, ok, err, i < 2)
+		}
+	}
+
+	// The snapshot of the BoltStore is the same, and objects which have
+	// expired by the time it is imported are left out.
+	var again bytes.Buffer
+	if n, err := bolt.Export(&again, now); n != 2 || err != nil {
+		t.Fatalf("Export: got %d, %v want 2, nil", n, err)
+	}
+	if !bytes.Equal(again.Bytes(), snapshot.Bytes()) {
+		t.Errorf("Export: got %x want %x", again.Bytes(), snapshot.Bytes())
+	}
+	cache := store.NewCache(store.NewMemStore(), &store.CacheConfig{})
+	if n, err := cache.Import(&again, now.Add(2*time.Hour)); n != 1 ||
+		err != nil {
+		t.Fatalf("Import: got %d, %v want 1, nil", n, err)
+	}
+	if n, _ := store.Count(cache); n != 1 {
+		t.Errorf("Count: got %d want 1", n)
+	}
+}
+
+// TestSnapshotErrors tests that snapshots which are invalid are rejected.
+func TestSnapshotErrors(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	mem := store.NewMemStore()
+	mem.Put(newObject(now.Add(time.Hour), wire.ObjectTypeMsg, 1, "a"))
+
+	var snapshot bytes.Buffer
+	if _, err := mem.Export(&snapshot, now); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	b := snapshot.Bytes()
+
+	corrupt := append([]byte(nil), b...)
+	corrupt[len(corrupt)-1] ^= 1
+	version := append([]byte(nil), b...)
+	version[4] = 2
+
+	tests := []struct {
+		name     string
+		snapshot []byte
+		err      error
+	}{
+		{"empty", nil, store.ErrInvalidSnapshot},
+		{"not a snapshot", []byte("not a snapshot"), store.ErrInvalidSnapshot},
+		{"truncated", b[:len(b)-70], store.ErrInvalidSnapshot},
+		{"corrupt", corrupt, store.ErrInvalidSnapshot},
+		{"version", version, store.ErrUnknownSnapshotVersion},
+	}
+	for _, test := range tests {
+		s := store.NewMemStore()
+		_, err := s.Import(bytes.NewReader(test.snapshot), now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v want %v", test.name, err, test.err)
+		}
+		if n, _ := store.Count(s); n != 0 {
+			t.Errorf("%s: %d objects were added", test.name, n)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// ErrNotFound is returned when an object is not in the store.
+var ErrNotFound = errors.New("object not found")
+
+// endOfTime is later than the expiration of any object that the network
+// would accept, so that Expired(endOfTime, 0) lists every object in a
+// store.
+var endOfTime = time.Unix(1<<62, 0)
+
+// ObjectStore is a set of object messages keyed by inventory hash.
+type ObjectStore interface {
+	// Put adds an object to the store and returns its inventory hash.
+	// Adding an object which is already in the store has no effect.
+	Put(obj *wire.MsgObject) (*hash.Sha, error)
+
+	// Get returns the object with the given inventory hash, or
+	// ErrNotFound.
+	Get(invHash *hash.Sha) (*wire.MsgObject, error)
+
+	// Exists returns whether the object with the given inventory hash is
+	// in the store.
+	Exists(invHash *hash.Sha) (bool, error)
+
+	// Delete removes the object with the given inventory hash. It is not
+	// an error if the object is not in the store.
+	Delete(invHash *hash.Sha) error
+
+	// ExpireBefore removes every object which expires before t and
+	// returns the number removed.
+	ExpireBefore(t time.Time) (int, error)
+
+	// Expired returns the inventory hashes of up to limit objects which
+	// expire before t, those which expire first coming first. A limit
+	// of zero means no limit.
+	Expired(t time.Time, limit int) ([]*hash.Sha, error)
+
+	// ByType returns the inventory hashes of the objects of the given
+	// type.
+	ByType(objType wire.ObjectType) ([]*hash.Sha, error)
+
+	// ByStream returns the inventory hashes of the objects in the given
+	// stream.
+	ByStream(stream uint64) ([]*hash.Sha, error)
+
+	// Export writes a snapshot of the objects which have not expired by
+	// now to w and returns the number written. The snapshot may be read
+	// into any ObjectStore with Import, so it can be used to bootstrap a
+	// new node or to move to another kind of store.
+	Export(w io.Writer, now time.Time) (int, error)
+
+	// Import adds the objects in a snapshot written by Export, leaving
+	// out those which have expired by now, and returns the number added.
+	// It returns ErrInvalidSnapshot if the snapshot is malformed, in which
+	// case none of its objects are added.
+	Import(r io.Reader, now time.Time) (int, error)
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Count returns the number of objects in a store.
+func Count(s ObjectStore) (int, error) {
+	hashes, err := s.Expired(endOfTime, 0)
+	if err != nil {
+		return 0, err
+	}
+	return len(hashes), nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package store_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/store"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newObject returns an object with the given properties.
+func newObject(expiration time.Time, objType wire.ObjectType, stream uint64,
+	payload string) *wire.MsgObject {
+	return wire.NewMsgObject(
+		wire.NewObjectHeader(123, expiration, objType, 1, stream),
+		[]byte(payload))
+}
+
+// sortHashes sorts inventory hashes so that they can be compared.
+func sortHashes(hashes []*hash.Sha) []string {
+	s := make([]string, len(hashes))
+	for i, h := range hashes {
+		s[i] = h.String()
+	}
+	sort.Strings(s)
+	return s
+}
+
+// testStore runs the tests common to every ObjectStore.
+func testStore(t *testing.T, s store.ObjectStore) {
+	now := time.Unix(time.Now().Unix(), 0)
+	objs := []*wire.MsgObject{
+		newObject(now.Add(time.Hour), wire.ObjectTypeMsg, 1, "a"),
+		newObject(now.Add(2*time.Hour), wire.ObjectTypeBroadcast, 1, "b"),
+		newObject(now.Add(-time.Hour), wire.ObjectTypeMsg, 2, "c"),
+		newObject(now.Add(-2*time.Hour), wire.ObjectTypeGetPubKey, 1, "d"),
+	}
+
+	hashes := make([]*hash.Sha, len(objs))
+	for i, obj := range objs {
+		h, err := s.Put(obj)
+		if err != nil {
+			t.Fatalf("Put #%d: %v", i, err)
+		}
+		if !h.IsEqual(hash.InventoryHash(wire.Encode(obj))) {
+			t.Errorf("Put #%d: got hash %s want %s", i, h,
+				hash.InventoryHash(wire.Encode(obj)))
+		}
+		hashes[i] = h
+
+		// Putting an object twice has no effect.
+		if _, err = s.Put(obj); err != nil {
+			t.Fatalf("Put #%d again: %v", i, err)
+		}
+	}
+	if n, err := store.Count(s); n != len(objs) || err != nil {
+		t.Errorf("Count: got %d, %v want %d, nil", n, err, len(objs))
+	}
+
+	for i, h := range hashes {
+		obj, err := s.Get(h)
+		if err != nil {
+			t.Fatalf("Get #%d: %v", i, err)
+		}
+		if !bytes.Equal(wire.Encode(obj), wire.Encode(objs[i])) {
+			t.Errorf("Get #%d: got %s want %s", i, obj, objs[i])
+		}
+		if ok, err := s.Exists(h); !ok || err != nil {
+			t.Errorf("Exists #%d: got %v, %v want true, nil", i, ok, err)
+		}
+	}
+
+	msgs, err := s.ByType(wire.ObjectTypeMsg)
+	if err != nil {
+		t.Fatalf("ByType: %v", err)
+	}
+	want := sortHashes([]*hash.Sha{hashes[0], hashes[2]})
+	if got := sortHashes(msgs); !equal(got, want) {
+		t.Errorf("ByType: got %v want %v", got, want)
+	}
+
+	stream1, err := s.ByStream(1)
+	if err != nil {
+		t.Fatalf("ByStream: %v", err)
+	}
+	want = sortHashes([]*hash.Sha{hashes[0], hashes[1], hashes[3]})
+	if got := sortHashes(stream1); !equal(got, want) {
+		t.Errorf("ByStream: got %v want %v", got, want)
+	}
+
+	expired, err := s.Expired(now.Add(90*time.Minute), 0)
+	if err != nil {
+		t.Fatalf("Expired: %v", err)
+	}
+	wantExpired := []*hash.Sha{hashes[3], hashes[2], hashes[0]}
+	if len(expired) != len(wantExpired) {
+		t.Errorf("Expired: got %d hashes want %d", len(expired),
+			len(wantExpired))
+	} else {
+		for i := range expired {
+			if !expired[i].IsEqual(wantExpired[i]) {
+				t.Errorf("Expired #%d: got %s want %s", i, expired[i],
+					wantExpired[i])
+			}
+		}
+	}
+	if expired, _ = s.Expired(now.Add(90*time.Minute), 2); len(expired) != 2 ||
+		!expired[0].IsEqual(hashes[3]) {
+		t.Errorf("Expired with limit: got %v want first 2 of %v", expired,
+			wantExpired)
+	}
+
+	n, err := s.ExpireBefore(now)
+	if err != nil {
+		t.Fatalf("ExpireBefore: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ExpireBefore: got %d removed want %d", n, 2)
+	}
+	for i, h := range hashes {
+		ok, _ := s.Exists(h)
+		if ok != (i < 2) {
+			t.Errorf("after ExpireBefore, Exists #%d: got %v want %v", i,
+				ok, i < 2)
+		}
+	}
+	if _, err = s.Get(hashes[2]); err != store.ErrNotFound {
+		t.Errorf("Get: got error %v want %v", err, store.ErrNotFound)
+	}
+
+	if err = s.Delete(hashes[0]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := s.Exists(hashes[0]); ok {
+		t.Error("Delete: object still exists")
+	}
+	if err = s.Delete(hashes[0]); err != nil {
+		t.Errorf("Delete of missing object: %v", err)
+	}
+
+	// Deleted objects must not be removed again by ExpireBefore.
+	if n, _ = s.ExpireBefore(now.Add(24 * time.Hour)); n != 1 {
+		t.Errorf("ExpireBefore: got %d removed want %d", n, 1)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestMemStore tests MemStore.
+func TestMemStore(t *testing.T) {
+	s := store.NewMemStore()
+	defer s.Close()
+	testStore(t, s)
+}
+
+// TestBoltStore tests BoltStore, including that objects are kept after
+// the database is reopened.
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "objects.db")
+
+	s, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	testStore(t, s)
+
+	obj := newObject(time.Now().Add(time.Hour), wire.ObjectTypeMsg, 1, "e")
+	h, err := s.Put(obj)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s, err = store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer s.Close()
+	got, err := s.Get(h)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(wire.Encode(got), wire.Encode(obj)) {
+		t.Errorf("Get: got %s want %s", got, obj)
+	}
+}
@@ -19,73 +19,80 @@ import (
 // MaxVarIntSize is the maximum size of a variable length integer.
 const MaxVarIntSize = 9
 
-// ReadVarInt reads a variable length integer from r and returns it as a uint64.
-func ReadVarInt(r io.Reader) (uint64, error) {
-	var b [8]byte
-	_, err := io.ReadFull(r, b[0:1])
-	if err != nil {
-		return 0, err
-	}
-
-	var rv uint64
-	discriminant := uint8(b[0])
+// varIntLength returns the number of bytes which follow the given
+// discriminant in a variable length integer.
+func varIntLength(discriminant byte) int {
 	switch discriminant {
 	case 0xff:
-		_, err := io.ReadFull(r, b[:])
-		if err != nil {
-			return 0, err
-		}
-		rv = binary.BigEndian.Uint64(b[:])
-
+		return 8
 	case 0xfe:
-		_, err := io.ReadFull(r, b[0:4])
-		if err != nil {
-			return 0, err
-		}
-		rv = uint64(binary.BigEndian.Uint32(b[:]))
-
+		return 4
 	case 0xfd:
-		_, err := io.ReadFull(r, b[0:2])
-		if err != nil {
-			return 0, err
-		}
-		rv = uint64(binary.BigEndian.Uint16(b[:]))
-
+		return 2
 	default:
-		rv = uint64(discriminant)
+		return 0
 	}
-
-	return rv, nil
 }
 
-// WriteVarInt serializes val to w using a variable number of bytes depending
-// on its value.
-func WriteVarInt(w io.Writer, val uint64) error {
-	if val < 0xfd {
-		_, err := w.Write([]byte{uint8(val)})
-		return err
+// ReadVarInt reads a variable length integer from r and returns it as a uint64.
+// The discriminant is read first and then the bytes which follow it in a
+// single read, both into a buffer on the stack.
+func ReadVarInt(r io.Reader) (uint64, error) {
+	var b [MaxVarIntSize]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return 0, err
 	}
 
-	if val <= math.MaxUint16 {
-		var buf [3]byte
-		buf[0] = 0xfd
-		binary.BigEndian.PutUint16(buf[1:], uint16(val))
-		_, err := w.Write(buf[:])
-		return err
+	n := varIntLength(b[0])
+	if n == 0 {
+		return uint64(b[0]), nil
 	}
 
-	if val <= math.MaxUint32 {
-		var buf [5]byte
-		buf[0] = 0xfe
-		binary.BigEndian.PutUint32(buf[1:], uint32(val))
-		_, err := w.Write(buf[:])
-		return err
+	// The integer is incomplete if r ends after the discriminant.
+	if _, err := io.ReadFull(r, b[1:1+n]); err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, err
 	}
+	switch n {
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b[1:])), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b[1:])), nil
+	default:
+		return binary.BigEndian.Uint64(b[1:]), nil
+	}
+}
 
-	var buf [9]byte
-	buf[0] = 0xff
-	binary.BigEndian.PutUint64(buf[1:], val)
-	_, err := w.Write(buf[:])
+// putVarInt encodes val as a variable length integer at the start of b,
+// which must be at least MaxVarIntSize long, and returns the number of
+// bytes used.
+func putVarInt(b []byte, val uint64) int {
+	switch {
+	case val < 0xfd:
+		b[0] = uint8(val)
+		return 1
+	case val <= math.MaxUint16:
+		b[0] = 0xfd
+		binary.BigEndian.PutUint16(b[1:], uint16(val))
+		return 3
+	case val <= math.MaxUint32:
+		b[0] = 0xfe
+		binary.BigEndian.PutUint32(b[1:], uint32(val))
+		return 5
+	default:
+		b[0] = 0xff
+		binary.BigEndian.PutUint64(b[1:], val)
+		return 9
+	}
+}
+
+// WriteVarInt serializes val to w using a variable number of bytes depending
+// on its value. The integer is encoded in a buffer on the stack and written
+// with a single call to w.Write.
+func WriteVarInt(w io.Writer, val uint64) error {
+	var b [MaxVarIntSize]byte
+	_, err := w.Write(b[:putVarInt(b[:], val)])
 	return err
 }
 
@@ -119,6 +126,9 @@ func VarIntSerializeSize(val uint64) int {
 // not be possible to put a varString of that size into a block anyways and it
 // also helps protect against memory exhaustion attacks and forced panics
 // through malformed messages.
+//
+// Deprecated: Use wire.ReadBoundedBytes, which reports a length which is too
+// great with a *wire.MessageError like the other decoders.
 func ReadVarString(r io.Reader, maxAllowed int) (string, error) {
 	count, err := ReadVarInt(r)
 	if err != nil {
@@ -163,6 +173,9 @@ func WriteVarString(w io.Writer, str string) error {
 // attacks and forced panics thorugh malformed messages. The fieldName
 // parameter is only used for the error message so it provides more context in
 // the error.
+//
+// Deprecated: Use wire.ReadBoundedBytes, which reports a length which is too
+// great with a *wire.MessageError like the other decoders.
 func ReadVarBytes(r io.Reader, maxAllowed int,
 	fieldName string) ([]byte, error) {
 
@@ -81,6 +81,30 @@ func TestVarIntWire(t *testing.T) {
 				val, test.out)
 			continue
 		}
+
+		// Encode and decode again through a writer and a reader which
+		// have no other methods.
+		buf.Reset()
+		err = bmutil.WriteVarInt(struct{ io.Writer }{&buf}, test.in)
+		if err != nil {
+			t.Errorf("WriteVarInt #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("WriteVarInt #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		val, err = bmutil.ReadVarInt(struct{ io.Reader }{&buf})
+		if err != nil {
+			t.Errorf("ReadVarInt #%d error %v", i, err)
+			continue
+		}
+		if val != test.out {
+			t.Errorf("ReadVarInt #%d\n got: %d want: %d", i,
+				val, test.out)
+			continue
+		}
 	}
 }
 
@@ -123,6 +147,19 @@ func TestVarIntWireErrors(t *testing.T) {
 				i, err, test.readErr)
 			continue
 		}
+
+		// Decode from a bytes.Reader holding the same bytes as the
+		// fixed reader.
+		b := test.buf
+		if test.max < len(b) {
+			b = b[:test.max]
+		}
+		_, err = bmutil.ReadVarInt(bytes.NewReader(b))
+		if err != test.readErr {
+			t.Errorf("ReadVarInt #%d from bytes.Reader wrong error "+
+				"got: %v, want: %v", i, err, test.readErr)
+			continue
+		}
 	}
 }
 
@@ -0,0 +1,79 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledBufferSize is the capacity above which buffers are not returned
+// to the pool, so that a few large objects do not keep large buffers
+// alive.
+const maxPooledBufferSize = 1 << 16
+
+// bufferPool holds buffers for encoding.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// poolingDisabled is set to 1 when buffers are not to be reused.
+var poolingDisabled int32
+
+// SetBufferPooling sets whether encoding reuses buffers. Pooling is
+// enabled by default. Disabling it makes every encoding allocate a fresh
+// buffer, which can make memory profiles easier to read.
+func SetBufferPooling(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&poolingDisabled, 0)
+	} else {
+		atomic.StoreInt32(&poolingDisabled, 1)
+	}
+}
+
+// GetBuffer returns an empty buffer, taken from the pool if pooling is
+// enabled. It should be given back with PutBuffer once its contents are no
+// longer needed.
+func GetBuffer() *bytes.Buffer {
+	if atomic.LoadInt32(&poolingDisabled) == 1 {
+		return new(bytes.Buffer)
+	}
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer returns a buffer to the pool. Neither the buffer nor any slice
+// of its contents may be used afterwards.
+func PutBuffer(b *bytes.Buffer) {
+	if atomic.LoadInt32(&poolingDisabled) == 1 ||
+		b.Cap() > maxPooledBufferSize {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// encodeToBytes encodes msg and returns the result, which belongs to the
+// caller. If msg is a Sizer, the result is allocated once at exactly the
+// right size. Otherwise msg is encoded into a pooled buffer and the result
+// is copied out of it. If encoding fails, whatever was written before the
+// error is returned along with it.
+func encodeToBytes(msg Encodable) ([]byte, error) {
+	if s, ok := msg.(Sizer); ok {
+		buf := bytes.NewBuffer(make([]byte, 0, s.EncodedSize()))
+		err := msg.Encode(buf)
+		return buf.Bytes(), err
+	}
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	err := msg.Encode(buf)
+	b := make([]byte, buf.Len())
+	copy(b, buf.Bytes())
+	return b, err
+}
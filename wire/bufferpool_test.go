@@ -0,0 +1,167 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// newTestObject returns an object with the given payload.
+func newTestObject(payload []byte) *wire.MsgObject {
+	return wire.NewMsgObject(
+		wire.NewObjectHeader(123, time.Unix(1000000, 0), wire.ObjectTypeMsg,
+			1, 1),
+		payload)
+}
+
+// TestEncodePooling tests that the results of Encode do not share memory
+// with buffers that are reused, with and without pooling.
+func TestEncodePooling(t *testing.T) {
+	defer wire.SetBufferPooling(true)
+
+	for _, pooling := range []bool{true, false} {
+		wire.SetBufferPooling(pooling)
+
+		a := wire.Encode(newTestObject(bytes.Repeat([]byte{0xaa}, 100)))
+		saved := append([]byte(nil), a...)
+
+		// Encode something else, which may reuse the same buffer.
+		for i := 0; i < 10; i++ {
+			wire.Encode(newTestObject(bytes.Repeat([]byte{0xbb}, 200)))
+			wire.WriteMessage(ioutil.Discard,
+				newTestObject(bytes.Repeat([]byte{0xcc}, 50)), wire.MainNet)
+		}
+
+		if !bytes.Equal(a, saved) {
+			t.Errorf("pooling %v: encoded object was modified", pooling)
+		}
+	}
+}
+
+// TestEncodedSize tests that EncodedSize returns the length of the
+// encoding of messages and that WriteMessage still writes them correctly.
+func TestEncodedSize(t *testing.T) {
+	inv := wire.NewMsgInv()
+	getData := wire.NewMsgGetData()
+	for i := 0; i < 3; i++ {
+		iv := wire.InvVect(hash.Sha{byte(i)})
+		inv.AddInvVect(&iv)
+		getData.AddInvVect(&iv)
+	}
+	addr := wire.NewMsgAddr()
+	addr.AddAddress(wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"),
+		8444, 1, wire.SFNodeNetwork))
+
+	tests := []wire.Message{
+		newTestObject(nil),
+		newTestObject(bytes.Repeat([]byte{0xaa}, 70000)),
+		wire.NewMsgObject(wire.NewObjectHeader(123, time.Unix(1000000, 0),
+			wire.ObjectTypeBroadcast, 5, 300), []byte{1, 2, 3}),
+		wire.NewMsgInv(),
+		inv,
+		getData,
+		addr,
+		wire.NewMsgVerAck(),
+		wire.NewMsgPong(),
+	}
+
+	for i, msg := range tests {
+		encoded := wire.Encode(msg)
+		size := msg.(wire.Sizer).EncodedSize()
+		if size != len(encoded) {
+			t.Errorf("EncodedSize #%d: got %d want %d", i, size, len(encoded))
+		}
+		if cap(encoded) != len(encoded) {
+			t.Errorf("Encode #%d: got capacity %d want %d", i, cap(encoded),
+				len(encoded))
+		}
+
+		var buf bytes.Buffer
+		n, err := wire.WriteMessageN(&buf, msg, wire.MainNet)
+		if err != nil {
+			t.Errorf("WriteMessageN #%d: %v", i, err)
+			continue
+		}
+		if n != wire.MessageHeaderSize+size || n != buf.Len() {
+			t.Errorf("WriteMessageN #%d: got %d bytes want %d", i, n,
+				wire.MessageHeaderSize+size)
+		}
+		if !bytes.Equal(buf.Bytes()[wire.MessageHeaderSize:], encoded) {
+			t.Errorf("WriteMessageN #%d: wrong payload", i)
+		}
+	}
+}
+
+// preencodedObject is an object which is already encoded.
+type preencodedObject struct {
+	wire.MsgObject
+	encoded []byte
+}
+
+func (p *preencodedObject) EncodedBytes() []byte {
+	return p.encoded
+}
+
+// TestWriteMessagePreencoded tests that WriteMessage writes the encoding of
+// a wire.Preencoded message as it is.
+func TestWriteMessagePreencoded(t *testing.T) {
+	obj := newTestObject(bytes.Repeat([]byte{0xaa}, 100))
+	p := &preencodedObject{encoded: wire.Encode(obj)}
+
+	var got, want bytes.Buffer
+	n, err := wire.WriteMessageN(&got, p, wire.MainNet)
+	if err != nil {
+		t.Fatalf("WriteMessageN: %v", err)
+	}
+	if n != got.Len() {
+		t.Errorf("WriteMessageN: got %d bytes want %d", n, got.Len())
+	}
+	wire.WriteMessage(&want, obj, wire.MainNet)
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("WriteMessageN: got %x want %x", got.Bytes(), want.Bytes())
+	}
+}
+
+// TestGetBuffer tests that buffers from the pool are empty.
+func TestGetBuffer(t *testing.T) {
+	b := wire.GetBuffer()
+	b.WriteString("hello")
+	wire.PutBuffer(b)
+
+	for i := 0; i < 10; i++ {
+		b = wire.GetBuffer()
+		if b.Len() != 0 {
+			t.Fatalf("GetBuffer: got buffer of length %d want 0", b.Len())
+		}
+		wire.PutBuffer(b)
+	}
+}
+
+// BenchmarkEncodeObject performs a benchmark on how long it takes to
+// encode an object with a 1KB payload.
+func BenchmarkEncodeObject(b *testing.B) {
+	obj := newTestObject(make([]byte, 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wire.Encode(obj)
+	}
+}
+
+// BenchmarkWriteMessageObject performs a benchmark on how long it takes to
+// write an object message with a 1KB payload.
+func BenchmarkWriteMessageObject(b *testing.B) {
+	obj := newTestObject(make([]byte, 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wire.WriteMessage(ioutil.Discard, obj, wire.MainNet)
+	}
+}
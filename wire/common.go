@@ -11,8 +11,10 @@ package wire
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"fmt"
 	"io"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 )
 
@@ -309,6 +311,39 @@ func WriteElements(w io.Writer, elements ...interface{}) error {
 	return nil
 }
 
+// ReadBoundedLength reads a var int which gives the length of a field or the
+// number of items in a list, and returns a *MessageError attributed to fn,
+// which wraps ErrTooLarge, if it is greater than max. The decoders of bmutil read every var int length
+// with ReadBoundedLength or ReadBoundedBytes, rather than with ReadVarBytes
+// or ReadVarString of package bmutil, so that no length from the network is
+// used to allocate memory before it has been checked.
+func ReadBoundedLength(r io.Reader, max uint64, fn, field string) (uint64, error) {
+	length, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return 0, err
+	}
+	if length > max {
+		str := fmt.Sprintf("%s exceeds max length - indicates %d, but "+
+			"max length is %d", field, length, max)
+		return 0, WrapMessageError(fn, str, ErrTooLarge)
+	}
+	return length, nil
+}
+
+// ReadBoundedBytes reads a var int length with ReadBoundedLength followed by
+// that many bytes.
+func ReadBoundedBytes(r io.Reader, max uint64, fn, field string) ([]byte, error) {
+	length, err := ReadBoundedLength(r, max, fn, field)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 // randomUint64 returns a cryptographically random uint64 value.  This
 // unexported version takes a reader primarily to ensure the error paths
 // can be properly tested by passing a fake reader in the tests.
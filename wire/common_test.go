@@ -10,6 +10,7 @@ package wire_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -296,3 +297,45 @@ func TestRandomUint64Errors(t *testing.T) {
 		t.Errorf("TestRandomUint64Fails: nonce is not 0 [%v]", nonce)
 	}
 }
+
+// TestReadBounded tests reading var int lengths and the bytes which follow
+// them with an upper bound.
+func TestReadBounded(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		max uint64
+		out []byte
+		err error
+	}{
+		{[]byte{0x00}, 0, []byte{}, nil},
+		{[]byte{0x03, 1, 2, 3}, 3, []byte{1, 2, 3}, nil},
+		{[]byte{0x03, 1, 2, 3}, 2, nil, &wire.MessageError{}},
+		// A huge length is rejected before anything is allocated.
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			wire.MaxMessagePayload, nil, &wire.MessageError{}},
+		{[]byte{0x03, 1, 2}, 3, nil, io.ErrUnexpectedEOF},
+		{[]byte{}, 3, nil, io.EOF},
+	}
+
+	for i, test := range tests {
+		b, err := wire.ReadBoundedBytes(bytes.NewReader(test.in), test.max,
+			"Test", "field")
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("ReadBoundedBytes #%d: got error %v want %v", i, err,
+				test.err)
+			continue
+		}
+		if _, ok := err.(*wire.MessageError); !ok && err != test.err {
+			t.Errorf("ReadBoundedBytes #%d: got error %v want %v", i, err,
+				test.err)
+			continue
+		}
+		if _, ok := err.(*wire.MessageError); ok && !errors.Is(err, wire.ErrTooLarge) {
+			t.Errorf("ReadBoundedBytes #%d: error %v does not wrap %v", i,
+				err, wire.ErrTooLarge)
+		}
+		if !bytes.Equal(b, test.out) {
+			t.Errorf("ReadBoundedBytes #%d: got %x want %x", i, b, test.out)
+		}
+	}
+}
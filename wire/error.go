@@ -9,30 +9,54 @@
 package wire
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrTooLarge is wrapped by the MessageError returned for a message, or a
+// field or list within one, which is larger than is allowed, so that it can
+// be told apart from other malformed messages with errors.Is.
+var ErrTooLarge = errors.New("larger than allowed")
+
 // MessageError describes an issue with a message.
 // An example of some potential issues are messages from the wrong bitmessage
 // network, invalid commands, mismatched checksums, and exceeding max payloads.
 //
 // This provides a mechanism for the caller to type assert the error to
 // differentiate between general io errors such as io.EOF and issues that
-// resulted from malformed messages.
+// resulted from malformed messages. A MessageError may also wrap the error
+// which caused it, such as io.ErrUnexpectedEOF from a truncated payload,
+// which can be found with errors.Is and errors.As.
 type MessageError struct {
 	Func        string // Function name
 	Description string // Human readable description of the issue
+	Err         error  // Underlying cause, if any
 }
 
 // Error satisfies the error interface and prints human-readable errors.
 func (e *MessageError) Error() string {
+	str := e.Description
 	if e.Func != "" {
-		return fmt.Sprintf("%v: %v", e.Func, e.Description)
+		str = fmt.Sprintf("%v: %v", e.Func, e.Description)
+	}
+	if e.Err != nil {
+		str = fmt.Sprintf("%v: %v", str, e.Err)
 	}
-	return e.Description
+	return str
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *MessageError) Unwrap() error {
+	return e.Err
 }
 
 // NewMessageError creates an error for the given function and description.
 func NewMessageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
 }
+
+// WrapMessageError creates an error for the given function and description
+// which wraps err.
+func WrapMessageError(f string, desc string, err error) *MessageError {
+	return &MessageError{Func: f, Description: desc, Err: err}
+}
@@ -0,0 +1,466 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// This program generates layout_gen.go, which encodes and decodes the
+// structures in the wire protocol which have a fixed layout. It is run by
+// go generate.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+)
+
+// kind is the encoding of a field.
+type kind int
+
+const (
+	// kindUint32 is a big-endian 32-bit integer.
+	kindUint32 kind = iota
+
+	// kindUint64 is a big-endian 64-bit integer.
+	kindUint64
+
+	// kindBytes is a byte array of a fixed size.
+	kindBytes
+
+	// kindString is a string padded with zeros to a fixed size.
+	kindString
+
+	// kindVarInt is a var_int. The field must be a uint64.
+	kindVarInt
+)
+
+// maxVarIntSize is the longest encoding of a var_int.
+const maxVarIntSize = 9
+
+// field is a field of a structure.
+type field struct {
+	name string
+	kind kind
+
+	// size is the size of a kindBytes or kindString field.
+	size int
+
+	// typ is the type of an integer field, if it is not a plain uint32 or
+	// uint64.
+	typ string
+}
+
+// width returns the length of the encoding of a field which is not a
+// var_int.
+func (f *field) width() int {
+	switch f.kind {
+	case kindUint32:
+		return 4
+	case kindUint64:
+		return 8
+	default:
+		return f.size
+	}
+}
+
+// layout is a structure whose fields are encoded one after another in the
+// order given.
+type layout struct {
+	// name is used in the names of the generated functions.
+	name string
+
+	// typ is the type of the structure.
+	typ string
+
+	// doc describes the structure in the comments of the generated
+	// functions.
+	doc string
+
+	fields []field
+
+	// The functions to generate besides the append function.
+	parse, read, size bool
+}
+
+// layouts are the structures for which code is generated.
+var layouts = []layout{
+	{
+		name: "messageHeader",
+		typ:  "messageHeader",
+		doc:  "the header of a message",
+		fields: []field{
+			{name: "magic", kind: kindUint32, typ: "BitmessageNet"},
+			{name: "command", kind: kindString, size: 12},
+			{name: "length", kind: kindUint32},
+			{name: "checksum", kind: kindBytes, size: 4},
+		},
+		parse: true,
+	},
+	{
+		name: "objectHeader",
+		typ:  "ObjectHeader",
+		doc:  "the header of an object",
+		fields: []field{
+			{name: "Nonce", kind: kindUint64, typ: "pow.Nonce"},
+			{name: "expiration", kind: kindUint64},
+			{name: "ObjectType", kind: kindUint32, typ: "ObjectType"},
+			{name: "Version", kind: kindVarInt},
+			{name: "StreamNumber", kind: kindVarInt},
+		},
+		parse: true,
+		read:  true,
+		size:  true,
+	},
+	{
+		name: "objectHeaderForSigning",
+		typ:  "ObjectHeader",
+		doc:  "the part of the header of an object which is signed",
+		fields: []field{
+			{name: "expiration", kind: kindUint64},
+			{name: "ObjectType", kind: kindUint32, typ: "ObjectType"},
+			{name: "Version", kind: kindVarInt},
+			{name: "StreamNumber", kind: kindVarInt},
+		},
+	},
+}
+
+// packages are the import paths of the packages which may be named in the
+// type of a field.
+var packages = map[string]string{
+	"pow": "github.com/DanielKrawisz/bmutil/pow",
+}
+
+// generator writes the source of layout_gen.go.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// comment writes text as a comment wrapped at 77 columns.
+func (g *generator) comment(format string, args ...interface{}) {
+	line := "//"
+	for _, word := range strings.Fields(fmt.Sprintf(format, args...)) {
+		if len(line)+1+len(word) > 77 {
+			g.printf("%s\n", line)
+			line = "//"
+		}
+		line += " " + word
+	}
+	g.printf("%s\n", line)
+}
+
+// title returns name with its first letter in upper case.
+func title(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// convert returns the expression which converts x to the type of the field.
+func (g *generator) convert(f *field, x string) string {
+	if f.typ == "" {
+		return x
+	}
+	if i := strings.Index(f.typ, "."); i >= 0 {
+		g.imports[packages[f.typ[:i]]] = true
+	}
+	return f.typ + "(" + x + ")"
+}
+
+// fixedSize returns the total length of the fields which are not var_ints
+// and the number of var_ints.
+func (l *layout) fixedSize() (int, int) {
+	n, varInts := 0, 0
+	for i := range l.fields {
+		if l.fields[i].kind == kindVarInt {
+			varInts++
+		} else {
+			n += l.fields[i].width()
+		}
+	}
+	return n, varInts
+}
+
+// runs splits the fields into runs of fields which are not var_ints, each
+// followed by at most one var_int.
+func (l *layout) runs() [][]field {
+	var runs [][]field
+	var run []field
+	for _, f := range l.fields {
+		run = append(run, f)
+		if f.kind == kindVarInt {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// genAppend generates the function which appends the encoding of l.
+func (g *generator) genAppend(l *layout) {
+	doc := fmt.Sprintf("append%s appends the encoding of %s to b.",
+		title(l.name), l.doc)
+	for _, f := range l.fields {
+		if f.kind == kindString {
+			doc += " Strings longer than their field are cut short."
+			break
+		}
+	}
+	g.comment(doc)
+	g.printf("func append%s(b []byte, v *%s) []byte {\n", title(l.name), l.typ)
+	for _, f := range l.fields {
+		switch f.kind {
+		case kindUint32, kindUint64:
+			parts := make([]string, f.width())
+			for i := range parts {
+				shift := 8 * (f.width() - 1 - i)
+				if shift == 0 {
+					parts[i] = fmt.Sprintf("byte(v.%s)", f.name)
+				} else {
+					parts[i] = fmt.Sprintf("byte(v.%s>>%d)", f.name, shift)
+				}
+			}
+			// Four bytes to a line.
+			var lines []string
+			for i := 0; i < len(parts); i += 4 {
+				lines = append(lines, strings.Join(parts[i:i+4], ", "))
+			}
+			if len(lines) == 1 {
+				g.printf("b = append(b, %s)\n", lines[0])
+			} else {
+				g.printf("b = append(b,\n%s)\n", strings.Join(lines, ",\n"))
+			}
+		case kindBytes:
+			g.printf("b = append(b, v.%s[:]...)\n", f.name)
+		case kindString:
+			g.printf("b = appendPadded(b, v.%s, %d)\n", f.name, f.size)
+		case kindVarInt:
+			g.printf("b = appendVarInt(b, v.%s)\n", f.name)
+		}
+	}
+	g.printf("return b\n}\n\n")
+}
+
+// index returns the expression for the index n bytes after offset, which
+// is a variable, or after the start if offset is empty.
+func index(offset string, n int) string {
+	switch {
+	case offset == "":
+		return fmt.Sprintf("%d", n)
+	case n == 0:
+		return offset
+	default:
+		return fmt.Sprintf("%s+%d", offset, n)
+	}
+}
+
+// genFixed generates the statements which decode a run of fields which
+// are not var_ints from b, starting at the index base bytes after offset.
+func (g *generator) genFixed(run []field, b string, offset string, base int) {
+	at := base
+	for _, f := range run {
+		if f.kind == kindVarInt {
+			break
+		}
+		start := index(offset, at)
+		switch f.kind {
+		case kindUint32:
+			g.imports["encoding/binary"] = true
+			g.printf("v.%s = %s\n", f.name,
+				g.convert(&f, fmt.Sprintf("binary.BigEndian.Uint32(%s[%s:])", b, start)))
+		case kindUint64:
+			g.imports["encoding/binary"] = true
+			g.printf("v.%s = %s\n", f.name,
+				g.convert(&f, fmt.Sprintf("binary.BigEndian.Uint64(%s[%s:])", b, start)))
+		case kindBytes:
+			g.printf("copy(v.%s[:], %s[%s:])\n", f.name, b, start)
+		case kindString:
+			g.imports["bytes"] = true
+			end := index(offset, at+f.size)
+			g.printf("v.%s = string(bytes.TrimRight(%s[%s:%s], \"\\x00\"))\n",
+				f.name, b, start, end)
+		}
+		at += f.width()
+	}
+}
+
+// boundaries returns the indices at which the fields of a run which are not
+// var_ints start, base bytes after offset, as a list of arguments.
+func boundaries(run []field, offset string, base int) string {
+	var indices []string
+	at := base
+	for _, f := range run {
+		if f.kind == kindVarInt {
+			break
+		}
+		indices = append(indices, index(offset, at))
+		at += f.width()
+	}
+	return strings.Join(indices, ", ")
+}
+
+// runWidth returns the length of the fields in a run which are not
+// var_ints.
+func runWidth(run []field) int {
+	n := 0
+	for i := range run {
+		if run[i].kind != kindVarInt {
+			n += run[i].width()
+		}
+	}
+	return n
+}
+
+// genParse generates the function which decodes l from a byte slice.
+func (g *generator) genParse(l *layout) {
+	g.imports["io"] = true
+	g.comment("parse%s decodes %s from the start of b and returns the "+
+		"number of bytes used. If b ends early, it returns io.EOF if it "+
+		"ends between fields and io.ErrUnexpectedEOF if it ends within "+
+		"one.", title(l.name), l.doc)
+	g.printf("func parse%s(b []byte, v *%s) (int, error) {\n", title(l.name), l.typ)
+
+	// Offsets are constants up to the first var_int and are kept in i
+	// after it.
+	off, dynamic := 0, false
+	for _, run := range l.runs() {
+		if w := runWidth(run); w > 0 && dynamic {
+			g.printf("if len(b)-i < %d {\nreturn 0, shortRead(len(b), %s)\n}\n",
+				w, boundaries(run, "i", 0))
+			g.genFixed(run, "b", "i", 0)
+			g.printf("i += %d\n", w)
+		} else if w > 0 {
+			g.printf("if len(b) < %d {\nreturn 0, shortRead(len(b), %s)\n}\n",
+				off+w, boundaries(run, "", off))
+			g.genFixed(run, "b", "", off)
+			off += w
+		}
+
+		f := run[len(run)-1]
+		if f.kind != kindVarInt {
+			continue
+		}
+		if dynamic {
+			g.printf("n = decodeVarInt(b[i:], &v.%s)\n", f.name)
+			g.printf("if n == 0 {\nreturn 0, shortRead(len(b), i)\n}\n")
+		} else {
+			g.printf("n := decodeVarInt(b[%d:], &v.%s)\n", off, f.name)
+			g.printf("if n == 0 {\nreturn 0, shortRead(len(b), %d)\n}\n", off)
+		}
+		if dynamic {
+			g.printf("i += n\n")
+		} else {
+			g.printf("i := %s\n", strings.TrimPrefix(fmt.Sprintf("%d + n", off), "0 + "))
+			dynamic = true
+		}
+	}
+	if dynamic {
+		g.printf("return i, nil\n}\n\n")
+	} else {
+		g.printf("return %d, nil\n}\n\n", off)
+	}
+}
+
+// genRead generates the function which reads l from an io.Reader.
+func (g *generator) genRead(l *layout) {
+	g.imports["io"] = true
+	g.comment("read%s reads %s from r. If r ends early, it returns "+
+		"io.EOF if it ends between fields and io.ErrUnexpectedEOF if it "+
+		"ends within one, as if each field were read with io.ReadFull.",
+		title(l.name), l.doc)
+	g.printf("func read%s(r io.Reader, v *%s) error {\n", title(l.name), l.typ)
+
+	for i, run := range l.runs() {
+		if w := runWidth(run); w > 0 {
+			b := fmt.Sprintf("b%d", i)
+			g.printf("var %s [%d]byte\n", b, w)
+			g.printf("if err := readFixed(r, %s[:], %s); err != nil {\nreturn err\n}\n",
+				b, boundaries(run, "", 0))
+			g.genFixed(run, b, "", 0)
+		}
+		if f := run[len(run)-1]; f.kind == kindVarInt {
+			g.printf("if err := readVarInt(r, &v.%s); err != nil {\nreturn err\n}\n", f.name)
+		}
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+// genSize generates the function which returns the length of the encoding
+// of l, and a constant for its greatest length.
+func (g *generator) genSize(l *layout) {
+	fixed, varInts := l.fixedSize()
+	g.comment("max%sSize is the longest encoding of %s.", title(l.name), l.doc)
+	g.printf("const max%sSize = %d\n\n", title(l.name), fixed+varInts*maxVarIntSize)
+
+	g.comment("%sSize returns the length of the encoding of %s.", l.name, l.doc)
+	g.printf("func %sSize(v *%s) int {\n", l.name, l.typ)
+	terms := []string{fmt.Sprintf("%d", fixed)}
+	for _, f := range l.fields {
+		if f.kind == kindVarInt {
+			g.imports["github.com/DanielKrawisz/bmutil"] = true
+			terms = append(terms, fmt.Sprintf("bmutil.VarIntSerializeSize(v.%s)", f.name))
+		}
+	}
+	g.printf("return %s\n}\n\n", strings.Join(terms, " + "))
+}
+
+func main() {
+	g := &generator{imports: make(map[string]bool)}
+	for i := range layouts {
+		l := &layouts[i]
+		g.genAppend(l)
+		if l.parse {
+			g.genParse(l)
+		}
+		if l.read {
+			g.genRead(l)
+		}
+		if l.size {
+			g.genSize(l)
+		}
+	}
+
+	// The standard library is imported before other packages.
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by gen_layout.go. DO NOT EDIT.\n\n")
+	src.WriteString("package wire\n\nimport (\n")
+	for _, path := range std {
+		fmt.Fprintf(&src, "%q\n", path)
+	}
+	src.WriteString("\n")
+	for _, path := range other {
+		fmt.Fprintf(&src, "%q\n", path)
+	}
+	src.WriteString(")\n\n")
+	src.Write(g.buf.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		log.Fatalf("generated code does not parse: %v\n%s", err, src.Bytes())
+	}
+	if err = ioutil.WriteFile("layout_gen.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
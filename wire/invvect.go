@@ -9,7 +9,10 @@
 package wire
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"sort"
 
 	"github.com/DanielKrawisz/bmutil/hash"
 )
@@ -23,6 +26,94 @@ const (
 	maxInvVectPayload = hash.ShaSize
 )
 
+// ErrDuplicateInvVect is wrapped by the error returned when an inv or getdata
+// message with the same inventory vector more than once is decoded while
+// duplicates are rejected.
+var ErrDuplicateInvVect = errors.New("duplicate inventory vector")
+
+// InvDuplicates is what is done with inventory vectors which appear more
+// than once in an inv or getdata message as it is decoded. Duplicates are
+// not allowed by the protocol, and a peer which sends them may be trying to
+// make us request or send the same object many times. It is set for each
+// message by its Duplicates field.
+type InvDuplicates int32
+
+const (
+	// InvDuplicatesAllowed keeps duplicates, which is the default.
+	InvDuplicatesAllowed InvDuplicates = iota
+
+	// InvDuplicatesRemoved removes all but the first of each inventory
+	// vector.
+	InvDuplicatesRemoved
+
+	// InvDuplicatesRejected fails to decode a message with duplicates.
+	InvDuplicatesRejected
+)
+
+// Check applies d to a list of inventory vectors, as when a message is
+// decoded. It returns the list, with duplicates removed if they are to be,
+// or an error wrapping ErrDuplicateInvVect if they are to be rejected. It
+// may be used on messages which were decoded with duplicates allowed, as by
+// ReadMessage.
+func (d InvDuplicates) Check(list []*InvVect) ([]*InvVect, error) {
+	return d.check(list, "InvDuplicates.Check")
+}
+
+// check is Check with errors attributed to fn.
+func (d InvDuplicates) check(list []*InvVect, fn string) ([]*InvVect, error) {
+	if d == InvDuplicatesAllowed || len(list) < 2 {
+		return list, nil
+	}
+
+	seen := make(map[InvVect]struct{}, len(list))
+	var unique []*InvVect
+	for i, iv := range list {
+		if _, ok := seen[*iv]; !ok {
+			seen[*iv] = struct{}{}
+			if unique != nil {
+				unique = append(unique, iv)
+			}
+			continue
+		}
+
+		if d == InvDuplicatesRejected {
+			return nil, WrapMessageError(fn, "inventory vector "+
+				(*hash.Sha)(iv).String()+" appears more than once",
+				ErrDuplicateInvVect)
+		}
+
+		// The list is only copied once the first duplicate is found.
+		if unique == nil {
+			unique = append(make([]*InvVect, 0, len(list)-1), list[:i]...)
+		}
+	}
+	if unique == nil {
+		return list, nil
+	}
+	return unique, nil
+}
+
+// normalizeInvList sorts list in place by the bytes of its inventory
+// vectors and removes duplicates, returning the shortened list.
+func normalizeInvList(list []*InvVect) []*InvVect {
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i][:], list[j][:]) < 0
+	})
+
+	n := 0
+	for _, iv := range list {
+		if n > 0 && *list[n-1] == *iv {
+			continue
+		}
+		list[n] = iv
+		n++
+	}
+	for i := n; i < len(list); i++ {
+		list[i] = nil
+	}
+	return list[:n]
+}
+
 // InvVect defines a bitmessage inventory vector which is used to describe data,
 // as specified by the Type field, that a peer wants, has, or does not have to
 // another peer.
@@ -38,6 +129,48 @@ func readInvVect(r io.Reader, iv *InvVect) error {
 	return nil
 }
 
+// invListReadBatch is the number of inventory vectors which readInvList
+// reads at once.
+const invListReadBatch = 1024
+
+// readInvList reads count encoded InvVects from r. Rather than being
+// allocated one at a time, the InvVects share a single backing array, and
+// they are read in batches through a pooled buffer. If r runs out of data
+// on the boundary of an InvVect, io.EOF is returned, just as when reading
+// them one at a time.
+func readInvList(r io.Reader, count int) ([]*InvVect, error) {
+	batch := count
+	if batch > invListReadBatch {
+		batch = invListReadBatch
+	}
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	buf.Grow(batch * maxInvVectPayload)
+	b := buf.Bytes()[:batch*maxInvVectPayload]
+
+	ivs := make([]InvVect, count)
+	list := make([]*InvVect, count)
+	for i := 0; i < count; i += batch {
+		if count-i < batch {
+			batch = count - i
+		}
+		chunk := b[:batch*maxInvVectPayload]
+		n, err := io.ReadFull(r, chunk)
+		if err == io.ErrUnexpectedEOF && n%maxInvVectPayload == 0 {
+			err = io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for j := 0; j < batch; j++ {
+			copy(ivs[i+j][:], chunk[j*maxInvVectPayload:])
+			list[i+j] = &ivs[i+j]
+		}
+	}
+	return list, nil
+}
+
 // writeInvVect serializes an InvVect to w depending on the protocol version.
 func writeInvVect(w io.Writer, iv *InvVect) error {
 	err := WriteElements(w, (*hash.Sha)(iv))
@@ -0,0 +1,146 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrInvWriterClosed is returned when inventory is added to an InvWriter
+// which has been closed.
+var ErrInvWriterClosed = errors.New("inv writer is closed")
+
+// InvWriter collects inventory vectors and writes them to an underlying
+// writer as inv messages. A message is written as soon as it holds as many
+// vectors as the threshold given to NewInvWriter, and otherwise once the
+// flush delay has passed since the first vector was added to it, so that
+// a send loop can add vectors one at a time without building messages
+// itself.
+//
+// If writing a message fails, the error is returned by the next call to
+// Add, Flush or Close, and every later call returns it too.
+//
+// An InvWriter is safe for concurrent use.
+type InvWriter struct {
+	w         io.Writer
+	bmnet     BitmessageNet
+	threshold int
+	delay     time.Duration
+
+	mtx    sync.Mutex
+	msg    *MsgInv
+	gen    uint64 // Counts the messages written.
+	timer  *time.Timer
+	err    error
+	closed bool
+}
+
+// NewInvWriter returns an InvWriter which writes inv messages for the given
+// network to w. A message is written when it holds threshold vectors, which
+// is MaxInvPerMsg if threshold is zero or larger than that, or when delay
+// has passed since its first vector was added. If delay is zero, messages
+// which are not full are only written by Flush and Close.
+func NewInvWriter(w io.Writer, bmnet BitmessageNet, threshold int,
+	delay time.Duration) *InvWriter {
+
+	if threshold <= 0 || threshold > MaxInvPerMsg {
+		threshold = MaxInvPerMsg
+	}
+	return &InvWriter{
+		w:         w,
+		bmnet:     bmnet,
+		threshold: threshold,
+		delay:     delay,
+	}
+}
+
+// Add adds inventory vectors, writing an inv message each time one fills.
+func (iw *InvWriter) Add(ivs ...*InvVect) error {
+	iw.mtx.Lock()
+	defer iw.mtx.Unlock()
+
+	if iw.closed {
+		return ErrInvWriterClosed
+	}
+	if iw.err != nil {
+		return iw.err
+	}
+
+	for _, iv := range ivs {
+		if iw.msg == nil {
+			iw.msg = NewMsgInvSizeHint(uint(iw.threshold))
+			if iw.delay > 0 {
+				gen := iw.gen
+				iw.timer = time.AfterFunc(iw.delay, func() {
+					iw.expire(gen)
+				})
+			}
+		}
+		iw.msg.AddInvVect(iv)
+		if len(iw.msg.InvList) >= iw.threshold {
+			if err := iw.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expire is called when the flush delay of the message of generation gen
+// has passed. The message may already have been written and replaced while
+// expire waited for the mutex, in which case nothing is done.
+func (iw *InvWriter) expire(gen uint64) {
+	iw.mtx.Lock()
+	defer iw.mtx.Unlock()
+	if iw.gen == gen && iw.err == nil {
+		iw.flush()
+	}
+}
+
+// flush writes the pending message, if there is one. It must be called
+// with the mutex held.
+func (iw *InvWriter) flush() error {
+	if iw.timer != nil {
+		iw.timer.Stop()
+		iw.timer = nil
+	}
+	if iw.msg == nil {
+		return nil
+	}
+
+	msg := iw.msg
+	iw.msg = nil
+	iw.gen++
+	iw.err = WriteMessage(iw.w, msg, iw.bmnet)
+	return iw.err
+}
+
+// Flush writes the pending inventory vectors as an inv message, if there
+// are any.
+func (iw *InvWriter) Flush() error {
+	iw.mtx.Lock()
+	defer iw.mtx.Unlock()
+
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.flush()
+}
+
+// Close flushes the InvWriter and stops it from accepting more inventory.
+// It does not close the underlying writer.
+func (iw *InvWriter) Close() error {
+	iw.mtx.Lock()
+	defer iw.mtx.Unlock()
+
+	iw.closed = true
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.flush()
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/fixed"
+)
+
+// invVects returns n distinct inventory vectors.
+func invVects(n int) []*wire.InvVect {
+	ivs := make([]*wire.InvVect, n)
+	for i := range ivs {
+		ivs[i] = (*wire.InvVect)(hash.InventoryHash([]byte(strconv.Itoa(i))))
+	}
+	return ivs
+}
+
+// readInvs reads inv messages from r until it is empty and returns the
+// number of vectors in each.
+func readInvs(t *testing.T, r io.Reader) []int {
+	var counts []int
+	for {
+		msg, _, err := wire.ReadMessage(r, wire.MainNet)
+		if err == io.EOF {
+			return counts
+		}
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		counts = append(counts, len(msg.(*wire.MsgInv).InvList))
+	}
+}
+
+// TestInvWriter tests that an InvWriter writes a message each time one
+// reaches the threshold and writes the rest when it is closed.
+func TestInvWriter(t *testing.T) {
+	var buf bytes.Buffer
+	iw := wire.NewInvWriter(&buf, wire.MainNet, 3, 0)
+
+	ivs := invVects(7)
+	if err := iw.Add(ivs[:5]...); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, iv := range ivs[5:] {
+		if err := iw.Add(iv); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := iw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	counts := readInvs(t, &buf)
+	if len(counts) != 3 || counts[0] != 3 || counts[1] != 3 || counts[2] != 1 {
+		t.Errorf("got messages of %v vectors want [3 3 1]", counts)
+	}
+
+	if err := iw.Add(ivs[0]); err != wire.ErrInvWriterClosed {
+		t.Errorf("Add after Close: got %v want %v", err, wire.ErrInvWriterClosed)
+	}
+
+	// Flushing with nothing pending writes nothing.
+	iw = wire.NewInvWriter(&buf, wire.MainNet, 0, 0)
+	if err := iw.Flush(); err != nil || buf.Len() != 0 {
+		t.Errorf("Flush of empty writer: got %v, %d bytes written", err,
+			buf.Len())
+	}
+}
+
+// TestInvWriterDelay tests that a message which is not full is written
+// once the flush delay has passed.
+func TestInvWriterDelay(t *testing.T) {
+	r, w := io.Pipe()
+	iw := wire.NewInvWriter(w, wire.MainNet, 0, 10*time.Millisecond)
+	defer iw.Close()
+
+	if err := iw.Add(invVects(2)...); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	done := make(chan wire.Message)
+	go func() {
+		msg, _, err := wire.ReadMessage(r, wire.MainNet)
+		if err != nil {
+			t.Errorf("ReadMessage: %v", err)
+		}
+		done <- msg
+	}()
+
+	select {
+	case msg := <-done:
+		if inv, ok := msg.(*wire.MsgInv); !ok || len(inv.InvList) != 2 {
+			t.Errorf("got %v want inv of 2 vectors", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not written after the delay")
+	}
+}
+
+// TestInvWriterError tests that an error from the underlying writer is
+// returned from then on.
+func TestInvWriterError(t *testing.T) {
+	iw := wire.NewInvWriter(fixed.NewWriter(10), wire.MainNet, 2, 0)
+
+	ivs := invVects(4)
+	err := iw.Add(ivs[:2]...)
+	if err == nil {
+		t.Fatal("Add: expected error from the underlying writer")
+	}
+	if e := iw.Add(ivs[2]); e != err {
+		t.Errorf("Add: got %v want %v", e, err)
+	}
+	if e := iw.Flush(); e != err {
+		t.Errorf("Flush: got %v want %v", e, err)
+	}
+	if e := iw.Close(); e != err {
+		t.Errorf("Close: got %v want %v", e, err)
+	}
+}
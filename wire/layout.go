@@ -0,0 +1,110 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+// The headers of messages and objects have fixed layouts, for which
+// layout_gen.go has functions to encode and decode them straight to and from
+// byte slices, without a call for each field. The functions are generated
+// from the table of layouts in gen_layout.go.
+//
+//go:generate go run gen_layout.go
+
+// shortRead returns the error for an encoding which ends early, given the
+// number of bytes there were and the indices at which fields start. It
+// returns io.EOF if the encoding ends between two fields and
+// io.ErrUnexpectedEOF if it ends within one, which is what reading each
+// field with io.ReadFull would return.
+func shortRead(n int, fields ...int) error {
+	for _, start := range fields {
+		if n == start {
+			return io.EOF
+		}
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// appendPadded appends s to b padded with zeros to size bytes, or cut short
+// if it is longer.
+func appendPadded(b []byte, s string, size int) []byte {
+	n := len(b)
+	b = append(b, make([]byte, size)...)
+	copy(b[n:], s)
+	return b
+}
+
+// appendVarInt appends the var_int encoding of val to b.
+func appendVarInt(b []byte, val uint64) []byte {
+	switch {
+	case val < 0xfd:
+		return append(b, byte(val))
+	case val <= 0xffff:
+		return append(b, 0xfd, byte(val>>8), byte(val))
+	case val <= 0xffffffff:
+		return append(b, 0xfe, byte(val>>24), byte(val>>16), byte(val>>8),
+			byte(val))
+	default:
+		return append(b, 0xff, byte(val>>56), byte(val>>48), byte(val>>40),
+			byte(val>>32), byte(val>>24), byte(val>>16), byte(val>>8),
+			byte(val))
+	}
+}
+
+// decodeVarInt decodes a var_int from the start of b into val and returns
+// its length, or zero if b ends within it.
+func decodeVarInt(b []byte, val *uint64) int {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var n int
+	switch b[0] {
+	case 0xff:
+		n = 8
+	case 0xfe:
+		n = 4
+	case 0xfd:
+		n = 2
+	default:
+		*val = uint64(b[0])
+		return 1
+	}
+	if len(b) < 1+n {
+		return 0
+	}
+
+	var v uint64
+	for _, c := range b[1 : 1+n] {
+		v = v<<8 | uint64(c)
+	}
+	*val = v
+	return 1 + n
+}
+
+// readFixed reads len(b) bytes, which hold fields starting at the given
+// indices, from r in one call. If r ends early, it returns the error that
+// shortRead gives.
+func readFixed(r io.Reader, b []byte, fields ...int) error {
+	n, err := io.ReadFull(r, b)
+	if err == io.ErrUnexpectedEOF {
+		return shortRead(n, fields...)
+	}
+	return err
+}
+
+// readVarInt reads a var_int from r into val.
+func readVarInt(r io.Reader, val *uint64) error {
+	v, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return err
+	}
+	*val = v
+	return nil
+}
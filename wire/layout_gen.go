@@ -0,0 +1,114 @@
+// Code generated by gen_layout.go. DO NOT EDIT.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// appendMessageHeader appends the encoding of the header of a message to b.
+// Strings longer than their field are cut short.
+func appendMessageHeader(b []byte, v *messageHeader) []byte {
+	b = append(b, byte(v.magic>>24), byte(v.magic>>16), byte(v.magic>>8), byte(v.magic))
+	b = appendPadded(b, v.command, 12)
+	b = append(b, byte(v.length>>24), byte(v.length>>16), byte(v.length>>8), byte(v.length))
+	b = append(b, v.checksum[:]...)
+	return b
+}
+
+// parseMessageHeader decodes the header of a message from the start of b and
+// returns the number of bytes used. If b ends early, it returns io.EOF if it
+// ends between fields and io.ErrUnexpectedEOF if it ends within one.
+func parseMessageHeader(b []byte, v *messageHeader) (int, error) {
+	if len(b) < 24 {
+		return 0, shortRead(len(b), 0, 4, 16, 20)
+	}
+	v.magic = BitmessageNet(binary.BigEndian.Uint32(b[0:]))
+	v.command = string(bytes.TrimRight(b[4:16], "\x00"))
+	v.length = binary.BigEndian.Uint32(b[16:])
+	copy(v.checksum[:], b[20:])
+	return 24, nil
+}
+
+// appendObjectHeader appends the encoding of the header of an object to b.
+func appendObjectHeader(b []byte, v *ObjectHeader) []byte {
+	b = append(b,
+		byte(v.Nonce>>56), byte(v.Nonce>>48), byte(v.Nonce>>40), byte(v.Nonce>>32),
+		byte(v.Nonce>>24), byte(v.Nonce>>16), byte(v.Nonce>>8), byte(v.Nonce))
+	b = append(b,
+		byte(v.expiration>>56), byte(v.expiration>>48), byte(v.expiration>>40), byte(v.expiration>>32),
+		byte(v.expiration>>24), byte(v.expiration>>16), byte(v.expiration>>8), byte(v.expiration))
+	b = append(b, byte(v.ObjectType>>24), byte(v.ObjectType>>16), byte(v.ObjectType>>8), byte(v.ObjectType))
+	b = appendVarInt(b, v.Version)
+	b = appendVarInt(b, v.StreamNumber)
+	return b
+}
+
+// parseObjectHeader decodes the header of an object from the start of b and
+// returns the number of bytes used. If b ends early, it returns io.EOF if it
+// ends between fields and io.ErrUnexpectedEOF if it ends within one.
+func parseObjectHeader(b []byte, v *ObjectHeader) (int, error) {
+	if len(b) < 20 {
+		return 0, shortRead(len(b), 0, 8, 16)
+	}
+	v.Nonce = pow.Nonce(binary.BigEndian.Uint64(b[0:]))
+	v.expiration = binary.BigEndian.Uint64(b[8:])
+	v.ObjectType = ObjectType(binary.BigEndian.Uint32(b[16:]))
+	n := decodeVarInt(b[20:], &v.Version)
+	if n == 0 {
+		return 0, shortRead(len(b), 20)
+	}
+	i := 20 + n
+	n = decodeVarInt(b[i:], &v.StreamNumber)
+	if n == 0 {
+		return 0, shortRead(len(b), i)
+	}
+	i += n
+	return i, nil
+}
+
+// readObjectHeader reads the header of an object from r. If r ends early, it
+// returns io.EOF if it ends between fields and io.ErrUnexpectedEOF if it
+// ends within one, as if each field were read with io.ReadFull.
+func readObjectHeader(r io.Reader, v *ObjectHeader) error {
+	var b0 [20]byte
+	if err := readFixed(r, b0[:], 0, 8, 16); err != nil {
+		return err
+	}
+	v.Nonce = pow.Nonce(binary.BigEndian.Uint64(b0[0:]))
+	v.expiration = binary.BigEndian.Uint64(b0[8:])
+	v.ObjectType = ObjectType(binary.BigEndian.Uint32(b0[16:]))
+	if err := readVarInt(r, &v.Version); err != nil {
+		return err
+	}
+	if err := readVarInt(r, &v.StreamNumber); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maxObjectHeaderSize is the longest encoding of the header of an object.
+const maxObjectHeaderSize = 38
+
+// objectHeaderSize returns the length of the encoding of the header of an
+// object.
+func objectHeaderSize(v *ObjectHeader) int {
+	return 20 + bmutil.VarIntSerializeSize(v.Version) + bmutil.VarIntSerializeSize(v.StreamNumber)
+}
+
+// appendObjectHeaderForSigning appends the encoding of the part of the
+// header of an object which is signed to b.
+func appendObjectHeaderForSigning(b []byte, v *ObjectHeader) []byte {
+	b = append(b,
+		byte(v.expiration>>56), byte(v.expiration>>48), byte(v.expiration>>40), byte(v.expiration>>32),
+		byte(v.expiration>>24), byte(v.expiration>>16), byte(v.expiration>>8), byte(v.expiration))
+	b = append(b, byte(v.ObjectType>>24), byte(v.ObjectType>>16), byte(v.ObjectType>>8), byte(v.ObjectType))
+	b = appendVarInt(b, v.Version)
+	b = appendVarInt(b, v.StreamNumber)
+	return b
+}
@@ -48,6 +48,20 @@ type Encodable interface {
 	Decode(io.Reader) error
 }
 
+// Sizer is implemented by Encodables which can report the length of their
+// encoding without performing it. Encode and WriteMessage use it to
+// allocate their buffers once at exactly the right size.
+type Sizer interface {
+	EncodedSize() int
+}
+
+// Preencoded is implemented by messages which already hold their encoding,
+// such as objects read straight from a database. WriteMessage writes the
+// encoding as it is rather than copying it into a buffer.
+type Preencoded interface {
+	EncodedBytes() []byte
+}
+
 // Message is an interface that describes a bitmessage message.  A type that
 // implements Message has complete control over the representation of its data
 // and may therefore contain additional or fewer fields than those which
@@ -109,15 +123,11 @@ func readMessageHeader(r io.Reader) (int, *messageHeader, error) {
 	if err != nil {
 		return n, nil, err
 	}
-	hr := bytes.NewReader(headerBytes[:])
 
 	// Create and populate a messageHeader struct from the raw header bytes.
+	// Trailing zeros are stripped from the command.
 	hdr := messageHeader{}
-	var command [CommandSize]byte
-	ReadElements(hr, &hdr.magic, &command, &hdr.length, &hdr.checksum)
-
-	// Strip trailing zeros from command string.
-	hdr.command = string(bytes.TrimRight(command[:], string(0)))
+	parseMessageHeader(headerBytes[:], &hdr)
 
 	return n, &hdr, nil
 }
@@ -149,22 +159,36 @@ func WriteMessageN(w io.Writer, msg Message, bmnet BitmessageNet) (int, error) {
 	totalBytes := 0
 
 	// Enforce max command size.
-	var command [CommandSize]byte
 	cmd := msg.Command()
 	if len(cmd) > CommandSize {
 		str := fmt.Sprintf("command [%s] is too long [max %v]",
 			cmd, CommandSize)
 		return totalBytes, NewMessageError("WriteMessage", str)
 	}
-	copy(command[:], []byte(cmd))
 
-	// Encode the message payload.
-	var bw bytes.Buffer
-	err := msg.Encode(&bw)
-	if err != nil {
-		return totalBytes, err
+	// Encode the message payload after space left for the header. If the
+	// size of the payload is known, the whole message is allocated at once.
+	// If the message is already encoded, only the header is allocated.
+	var frame, payload []byte
+	if p, ok := msg.(Preencoded); ok {
+		frame = make([]byte, MessageHeaderSize)
+		payload = p.EncodedBytes()
+	} else {
+		var bw *bytes.Buffer
+		if s, ok := msg.(Sizer); ok {
+			bw = bytes.NewBuffer(make([]byte, MessageHeaderSize,
+				MessageHeaderSize+s.EncodedSize()))
+		} else {
+			bw = GetBuffer()
+			defer PutBuffer(bw)
+			bw.Write(make([]byte, MessageHeaderSize))
+		}
+		if err := msg.Encode(bw); err != nil {
+			return totalBytes, err
+		}
+		frame = bw.Bytes()
+		payload = frame[MessageHeaderSize:]
 	}
-	payload := bw.Bytes()
 	lenp := len(payload)
 
 	// Enforce maximum overall message payload.
@@ -191,15 +215,12 @@ func WriteMessageN(w io.Writer, msg Message, bmnet BitmessageNet) (int, error) {
 	hdr.length = uint32(lenp)
 	copy(hdr.checksum[:], hash.Sha512(payload)[0:4])
 
-	// Encode the header for the message.  This is done to a buffer
-	// rather than directly to the writer since WriteElements doesn't
-	// return the number of bytes written.
-	hw := bytes.NewBuffer(make([]byte, 0, MessageHeaderSize))
-
-	WriteElements(hw, hdr.magic, command, hdr.length, hdr.checksum)
+	// Encode the header into the space left for it in front of the
+	// payload.
+	appendMessageHeader(frame[:0], &hdr)
 
 	// Write header.
-	n, err := w.Write(hw.Bytes())
+	n, err := w.Write(frame[:MessageHeaderSize])
 	if err != nil {
 		totalBytes += n
 		return totalBytes, err
@@ -249,7 +270,8 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
 			"bytes", hdr.length, MaxMessagePayload)
-		return totalBytes, nil, nil, NewMessageError("ReadMessage", str)
+		return totalBytes, nil, nil, WrapMessageError("ReadMessage", str,
+			ErrTooLarge)
 	}
 
 	// Check for messages from the wrong bitmessage network.
@@ -288,7 +310,8 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v", hdr.length, command, mpl)
-		return totalBytes, nil, nil, NewMessageError("ReadMessage", str)
+		return totalBytes, nil, nil, WrapMessageError("ReadMessage", str,
+			ErrTooLarge)
 	}
 
 	// Test checksum.
@@ -303,7 +326,9 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 	// Unmarshal message.
 	err = msg.Decode(bytes.NewReader(payload))
 	if err != nil {
-		return totalBytes, nil, nil, err
+		str := fmt.Sprintf("failed to decode payload of [%v] message",
+			command)
+		return totalBytes, nil, nil, WrapMessageError("ReadMessage", str, err)
 	}
 
 	return totalBytes, msg, payload, nil
@@ -324,7 +349,6 @@ func ReadMessage(r io.Reader, bmnet BitmessageNet) (Message, []byte, error) {
 // the standard bitmessage header that goes along with every message sent over
 // the p2p connection.
 func Encode(msg Encodable) []byte {
-	var buf bytes.Buffer
-	msg.Encode(&buf)
-	return buf.Bytes()
+	b, _ := encodeToBytes(msg)
+	return b
 }
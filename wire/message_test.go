@@ -11,6 +11,7 @@ package wire_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"net"
 	"reflect"
@@ -380,12 +381,13 @@ func TestReadMessageWireErrors(t *testing.T) {
 			26,
 		},
 
-		// Message with a valid header, but wrong format.
+		// Message with a valid header, but wrong format. The error
+		// from decoding the payload is wrapped.
 		{
 			badMessageBytes,
 			bmnet,
 			len(badMessageBytes),
-			io.EOF,
+			&wire.MessageError{},
 			25,
 		},
 
@@ -465,6 +467,31 @@ func TestReadMessageWireErrors(t *testing.T) {
 	}
 }
 
+// TestMessageErrorUnwrap tests that the cause of an error from decoding a
+// payload can be found with errors.Is and errors.As.
+func TestMessageErrorUnwrap(t *testing.T) {
+	buf := makeHeader(wire.MainNet, "addr", 1, 0xfab848c9)
+	buf = append(buf, 0x2)
+
+	_, _, err := wire.ReadMessage(bytes.NewReader(buf), wire.MainNet)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage: got %v, which does not wrap %v", err, io.EOF)
+	}
+	var msgErr *wire.MessageError
+	if !errors.As(err, &msgErr) || msgErr.Func != "ReadMessage" {
+		t.Errorf("ReadMessage: got %v want a *MessageError", err)
+	}
+
+	wrapped := wire.WrapMessageError("Decode", "bad payload", io.ErrUnexpectedEOF)
+	if got, want := wrapped.Error(),
+		"Decode: bad payload: unexpected EOF"; got != want {
+		t.Errorf("Error: got %q want %q", got, want)
+	}
+	if wire.NewMessageError("Decode", "bad payload").Unwrap() != nil {
+		t.Error("Unwrap: got a cause for an error without one")
+	}
+}
+
 // TestWriteMessageWireErrors performs negative tests against wire.encoding from
 // concrete messages to confirm error paths work correctly.
 func TestWriteMessageWireErrors(t *testing.T) {
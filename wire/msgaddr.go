@@ -64,18 +64,13 @@ func (msg *MsgAddr) ClearAddresses() {
 // Decode decodes r using the bitmessage protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgAddr) Decode(r io.Reader) error {
-	count, err := bmutil.ReadVarInt(r)
+	// Limit to max addresses per message.
+	count, err := ReadBoundedLength(r, MaxAddrPerMsg, "MsgAddr.Decode",
+		"address count")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max addresses per message.
-	if count > MaxAddrPerMsg {
-		str := fmt.Sprintf("too many addresses for message "+
-			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return NewMessageError("MsgAddr.Decode", str)
-	}
-
 	msg.AddrList = make([]*NetAddress, 0, count)
 	for i := uint64(0); i < count; i++ {
 		na := NetAddress{}
@@ -113,6 +108,14 @@ func (msg *MsgAddr) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the Sizer interface.
+func (msg *MsgAddr) EncodedSize() int {
+	count := len(msg.AddrList)
+	return bmutil.VarIntSerializeSize(uint64(count)) +
+		count*maxNetAddressPayload()
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgAddr) Command() string {
@@ -86,14 +86,14 @@ func TestAddr(t *testing.T) {
 func TestAddrWire(t *testing.T) {
 	// A couple of NetAddresses to use for testing.
 	na := &wire.NetAddress{
-		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Timestamp: time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST
 		Services:  wire.SFNodeNetwork,
 		IP:        net.ParseIP("127.0.0.1"),
 		Port:      8333,
 		Stream:    1,
 	}
 	na2 := &wire.NetAddress{
-		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Timestamp: time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST
 		Services:  wire.SFNodeNetwork,
 		IP:        net.ParseIP("192.168.0.1"),
 		Port:      8334,
@@ -184,13 +184,13 @@ func TestAddrWireErrors(t *testing.T) {
 
 	// A couple of NetAddresses to use for testing.
 	na := &wire.NetAddress{
-		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Timestamp: time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST
 		Services:  wire.SFNodeNetwork,
 		IP:        net.ParseIP("127.0.0.1"),
 		Port:      8333,
 	}
 	na2 := &wire.NetAddress{
-		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Timestamp: time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST
 		Services:  wire.SFNodeNetwork,
 		IP:        net.ParseIP("192.168.0.1"),
 		Port:      8334,
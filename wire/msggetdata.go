@@ -27,6 +27,10 @@ import (
 // sending a getdata message to another peer.
 type MsgGetData struct {
 	InvList []*InvVect
+
+	// Duplicates is what Decode does with inventory vectors which
+	// appear more than once. It is not encoded.
+	Duplicates InvDuplicates
 }
 
 // AddInvVect adds an inventory vector to the message.
@@ -44,28 +48,19 @@ func (msg *MsgGetData) AddInvVect(iv *InvVect) error {
 // Decode decodes r using the bitmessage protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgGetData) Decode(r io.Reader) error {
-	count, err := bmutil.ReadVarInt(r)
+	// Limit to max inventory vectors per message.
+	count, err := ReadBoundedLength(r, MaxInvPerMsg, "MsgGetData.Decode",
+		"invvect count")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max inventory vectors per message.
-	if count > MaxInvPerMsg {
-		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return NewMessageError("MsgGetData.Decode", str)
-	}
-
-	msg.InvList = make([]*InvVect, 0, count)
-	for i := uint64(0); i < count; i++ {
-		iv := InvVect{}
-		err := readInvVect(r, &iv)
-		if err != nil {
-			return err
-		}
-		msg.AddInvVect(&iv)
+	list, err := readInvList(r, int(count))
+	if err != nil {
+		return err
 	}
-
-	return nil
+	msg.InvList, err = msg.Duplicates.check(list, "MsgGetData.Decode")
+	return err
 }
 
 // Encode encodes the receiver to w using the bitmessage protocol encoding.
@@ -93,6 +88,13 @@ func (msg *MsgGetData) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the Sizer interface.
+func (msg *MsgGetData) EncodedSize() int {
+	count := len(msg.InvList)
+	return bmutil.VarIntSerializeSize(uint64(count)) + count*maxInvVectPayload
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgGetData) Command() string {
@@ -33,6 +33,10 @@ const defaultInvListAlloc = 1000
 // sending an inv message to another peer.
 type MsgInv struct {
 	InvList []*InvVect
+
+	// Duplicates is what Decode does with inventory vectors which
+	// appear more than once. It is not encoded.
+	Duplicates InvDuplicates
 }
 
 // AddInvVect adds an inventory vector to the message.
@@ -47,31 +51,29 @@ func (msg *MsgInv) AddInvVect(iv *InvVect) error {
 	return nil
 }
 
+// Normalize sorts the inventory vectors and removes duplicates, so that
+// messages with the same inventory are encoded identically. This is useful
+// for tests and for comparing the inventory of two peers.
+func (msg *MsgInv) Normalize() {
+	msg.InvList = normalizeInvList(msg.InvList)
+}
+
 // Decode decodes r using the bitmessage protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgInv) Decode(r io.Reader) error {
-	count, err := bmutil.ReadVarInt(r)
+	// Limit to max inventory vectors per message.
+	count, err := ReadBoundedLength(r, MaxInvPerMsg, "MsgInv.Decode",
+		"invvect count")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max inventory vectors per message.
-	if count > MaxInvPerMsg {
-		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return NewMessageError("MsgInv.Decode", str)
-	}
-
-	msg.InvList = make([]*InvVect, 0, count)
-	for i := uint64(0); i < count; i++ {
-		iv := InvVect{}
-		err := readInvVect(r, &iv)
-		if err != nil {
-			return err
-		}
-		msg.AddInvVect(&iv)
+	list, err := readInvList(r, int(count))
+	if err != nil {
+		return err
 	}
-
-	return nil
+	msg.InvList, err = msg.Duplicates.check(list, "MsgInv.Decode")
+	return err
 }
 
 // Encode encodes the receiver to w using the bitmessage protocol encoding.
@@ -99,6 +101,13 @@ func (msg *MsgInv) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the Sizer interface.
+func (msg *MsgInv) EncodedSize() int {
+	count := len(msg.InvList)
+	return bmutil.VarIntSerializeSize(uint64(count)) + count*maxInvVectPayload
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgInv) Command() string {
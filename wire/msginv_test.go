@@ -10,6 +10,7 @@ package wire_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
@@ -257,3 +258,166 @@ func TestInvWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// newLargeInv returns an inv message with count distinct inventory vectors.
+func newLargeInv(count int) *wire.MsgInv {
+	msg := wire.NewMsgInvSizeHint(uint(count))
+	for i := 0; i < count; i++ {
+		iv := wire.InvVect(hash.Sha{byte(i >> 16), byte(i >> 8), byte(i)})
+		msg.AddInvVect(&iv)
+	}
+	return msg
+}
+
+// TestInvLarge tests decoding inventory lists which are read in more than
+// one batch, including lists which are cut short.
+func TestInvLarge(t *testing.T) {
+	const count = 3000
+	msg := newLargeInv(count)
+	encoded := wire.Encode(msg)
+
+	var got wire.MsgInv
+	if err := got.Decode(bytes.NewReader(encoded)); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(&got, msg) {
+		t.Errorf("Decode: got %d vectors, not the same as the %d encoded",
+			len(got.InvList), count)
+	}
+
+	// The header of the list is a 3 byte var int.
+	tests := []struct {
+		length int
+		err    error
+	}{
+		{3, io.EOF},
+		{3 + 2000*hash.ShaSize, io.EOF},
+		{3 + 2000*hash.ShaSize + 1, io.ErrUnexpectedEOF},
+		{len(encoded) - 1, io.ErrUnexpectedEOF},
+	}
+	for i, test := range tests {
+		var msg wire.MsgInv
+		err := msg.Decode(bytes.NewReader(encoded[:test.length]))
+		if err != test.err {
+			t.Errorf("Decode #%d: got error %v want %v", i, err, test.err)
+		}
+	}
+}
+
+// BenchmarkDecodeInv performs a benchmark on how long it takes to decode an
+// inv message with the maximum number of inventory vectors.
+func BenchmarkDecodeInv(b *testing.B) {
+	encoded := wire.Encode(newLargeInv(wire.MaxInvPerMsg))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var msg wire.MsgInv
+		msg.Decode(bytes.NewReader(encoded))
+	}
+}
+
+// TestInvDuplicates tests each way of dealing with duplicate inventory
+// vectors in inv and getdata messages.
+func TestInvDuplicates(t *testing.T) {
+	a := wire.InvVect(*hash.InventoryHash([]byte("a")))
+	b := wire.InvVect(*hash.InventoryHash([]byte("b")))
+	list := []*wire.InvVect{&a, &b, &a, &b, &a}
+
+	inv := wire.NewMsgInv()
+	getData := wire.NewMsgGetData()
+	for _, iv := range list {
+		inv.AddInvVect(iv)
+		getData.AddInvVect(iv)
+	}
+
+	tests := []struct {
+		d    wire.InvDuplicates
+		want []*wire.InvVect
+		err  error
+	}{
+		{wire.InvDuplicatesAllowed, list, nil},
+		{wire.InvDuplicatesRemoved, []*wire.InvVect{&a, &b}, nil},
+		{wire.InvDuplicatesRejected, nil, wire.ErrDuplicateInvVect},
+	}
+
+	for i, test := range tests {
+		for _, msg := range []wire.Message{inv, getData} {
+			var decoded wire.Message
+			var got func() []*wire.InvVect
+			switch msg.(type) {
+			case *wire.MsgInv:
+				m := &wire.MsgInv{Duplicates: test.d}
+				decoded, got = m, func() []*wire.InvVect { return m.InvList }
+			case *wire.MsgGetData:
+				m := &wire.MsgGetData{Duplicates: test.d}
+				decoded, got = m, func() []*wire.InvVect { return m.InvList }
+			}
+
+			err := decoded.Decode(bytes.NewReader(wire.Encode(msg)))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("#%d %s: got error %v want %v", i,
+						msg.Command(), err, test.err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("#%d %s: %v", i, msg.Command(), err)
+				continue
+			}
+			if !reflect.DeepEqual(got(), test.want) {
+				t.Errorf("#%d %s: got %s want %s", i, msg.Command(),
+					spew.Sdump(got()), spew.Sdump(test.want))
+			}
+		}
+
+		// Check does the same to a list which has already been
+		// decoded.
+		checked, err := test.d.Check(list)
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d Check: got error %v want %v", i, err, test.err)
+		}
+		if test.err == nil && !reflect.DeepEqual(checked, test.want) {
+			t.Errorf("#%d Check: got %s want %s", i, spew.Sdump(checked),
+				spew.Sdump(test.want))
+		}
+	}
+}
+
+// TestInvNormalize tests that normalized messages with the same inventory
+// are encoded identically.
+func TestInvNormalize(t *testing.T) {
+	var ivs []wire.InvVect
+	for _, s := range []string{"a", "b", "c"} {
+		ivs = append(ivs, wire.InvVect(*hash.InventoryHash([]byte(s))))
+	}
+
+	x := wire.NewMsgInv()
+	for _, i := range []int{2, 0, 1, 0, 2} {
+		x.AddInvVect(&ivs[i])
+	}
+	y := wire.NewMsgInv()
+	for _, i := range []int{1, 2, 0} {
+		y.AddInvVect(&ivs[i])
+	}
+
+	x.Normalize()
+	y.Normalize()
+	if len(x.InvList) != 3 {
+		t.Fatalf("Normalize: got %d vectors want %d", len(x.InvList), 3)
+	}
+	for i := 1; i < len(x.InvList); i++ {
+		if bytes.Compare(x.InvList[i-1][:], x.InvList[i][:]) >= 0 {
+			t.Errorf("Normalize: vectors %d and %d are out of order", i-1, i)
+		}
+	}
+	if !bytes.Equal(wire.Encode(x), wire.Encode(y)) {
+		t.Errorf("Normalize: encodings differ - got %x want %x",
+			wire.Encode(x), wire.Encode(y))
+	}
+
+	empty := wire.NewMsgInv()
+	empty.Normalize()
+	if len(empty.InvList) != 0 {
+		t.Errorf("Normalize of empty message: got %d vectors", len(empty.InvList))
+	}
+}
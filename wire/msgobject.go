@@ -6,13 +6,13 @@
 package wire
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"time"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/DanielKrawisz/bmutil/pow"
 )
@@ -21,6 +21,11 @@ const (
 	// MaxPayloadOfMsgObject is the the maximum payload of object message = 2^18 bytes.
 	// (not to be confused with the object payload)
 	MaxPayloadOfMsgObject = 262144
+
+	// MaxObjectTTL is the longest time to live which an object may have.
+	// Nodes reject objects which expire further in the future than this,
+	// give or take the difference between their clocks.
+	MaxObjectTTL = 28 * 24 * time.Hour
 )
 
 // obStrings is a map of service flags back to their constant names for pretty
@@ -49,15 +54,29 @@ type MsgObject struct {
 // Decode decodes r using the bitmessage protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgObject) Decode(r io.Reader) error {
-	var err error
-	msg.header, err = DecodeObjectHeader(r)
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
-	msg.payload, err = ioutil.ReadAll(r)
+	return msg.decode(b)
+}
 
-	return err
+// decode decodes an object from b, which becomes the backing array of the
+// payload.
+func (msg *MsgObject) decode(b []byte) error {
+	header := new(ObjectHeader)
+	n, err := parseObjectHeader(b, header)
+	if err != nil {
+		return err
+	}
+	if err = bmutil.CheckStream(header.StreamNumber); err != nil {
+		return err
+	}
+
+	msg.header = header
+	msg.payload = b[n:]
+	return nil
 }
 
 // Encode encodes the receiver to w using the bitmessage protocol encoding.
@@ -72,6 +91,12 @@ func (msg *MsgObject) Encode(w io.Writer) error {
 	return err
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the Sizer interface.
+func (msg *MsgObject) EncodedSize() int {
+	return msg.header.EncodedSize() + len(msg.payload)
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgObject) Command() string {
@@ -125,9 +150,10 @@ func (msg *MsgObject) Copy() *MsgObject {
 }
 
 // DecodeMsgObject takes a byte array and turns it into an object message.
+// The object does not share memory with obj.
 func DecodeMsgObject(obj []byte) (*MsgObject, error) {
 	msgObj := &MsgObject{}
-	err := msgObj.Decode(bytes.NewReader(obj)) // no error
+	err := msgObj.decode(append([]byte(nil), obj...))
 	if err != nil {
 		return nil, err
 	}
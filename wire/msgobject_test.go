@@ -3,9 +3,11 @@ package wire_test
 import (
 	"bytes"
 	"encoding/hex"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/DanielKrawisz/bmutil/pow"
 	"github.com/DanielKrawisz/bmutil/wire"
@@ -291,3 +293,98 @@ func TestNew(t *testing.T) {
 		t.Error("Wrong command string:", obj.MaxPayloadLength())
 	}
 }
+
+// TestDecodeObjectStream tests that objects with invalid stream numbers are
+// rejected.
+func TestDecodeObjectStream(t *testing.T) {
+	tests := []struct {
+		stream uint64
+		err    error
+	}{
+		{0, bmutil.ErrInvalidStream},
+		{1, nil},
+		{bmutil.MaxStream, nil},
+		{bmutil.MaxStream + 1, bmutil.ErrInvalidStream},
+	}
+
+	expires := time.Unix(0x495fab29, 0)
+	for i, test := range tests {
+		msg := wire.NewMsgObject(wire.NewObjectHeader(0, expires,
+			wire.ObjectTypeMsg, 1, test.stream), []byte{1, 2, 3})
+		if _, err := wire.DecodeMsgObject(wire.Encode(msg)); err != test.err {
+			t.Errorf("test #%d: got %v want %v", i, err, test.err)
+		}
+	}
+}
+
+// TestDecodeObjectHeaderShort tests the errors returned for object headers
+// which end early, which are io.EOF between fields and io.ErrUnexpectedEOF
+// within them.
+func TestDecodeObjectHeaderShort(t *testing.T) {
+	header := wire.NewObjectHeader(123, time.Unix(0x495fab29, 0),
+		wire.ObjectTypeMsg, 1000, 1)
+	encoded := wire.Encode(wire.NewMsgObject(header, nil))
+
+	// The nonce, expiration, object type, version and stream start at
+	// these indices.
+	fields := map[int]bool{0: true, 8: true, 16: true, 20: true, 23: true}
+	for i := range encoded {
+		want := io.ErrUnexpectedEOF
+		if fields[i] {
+			want = io.EOF
+		}
+		if _, err := wire.DecodeObjectHeader(bytes.NewReader(encoded[:i])); err != want {
+			t.Errorf("DecodeObjectHeader, length %d: got %v want %v", i, err, want)
+		}
+		if _, err := wire.DecodeMsgObject(encoded[:i]); err != want {
+			t.Errorf("DecodeMsgObject, length %d: got %v want %v", i, err, want)
+		}
+	}
+
+	got, err := wire.DecodeObjectHeader(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *header {
+		t.Errorf("got %s want %s", got, header)
+	}
+}
+
+// BenchmarkDecodeObjectHeader performs a benchmark on how long it takes to
+// decode the header of an object.
+func BenchmarkDecodeObjectHeader(b *testing.B) {
+	encoded := wire.Encode(newTestObject(make([]byte, 1024)))
+	r := bytes.NewReader(encoded)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded)
+		wire.DecodeObjectHeader(r)
+	}
+}
+
+// BenchmarkDecodeMsgObject performs a benchmark on how long it takes to
+// decode an object with a 1KB payload.
+func BenchmarkDecodeMsgObject(b *testing.B) {
+	encoded := wire.Encode(newTestObject(make([]byte, 1024)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wire.DecodeMsgObject(encoded)
+	}
+}
+
+// BenchmarkReadMessageObject performs a benchmark on how long it takes to
+// read an object message with a 1KB payload.
+func BenchmarkReadMessageObject(b *testing.B) {
+	var buf bytes.Buffer
+	wire.WriteMessage(&buf, newTestObject(make([]byte, 1024)), wire.MainNet)
+	encoded := buf.Bytes()
+	r := bytes.NewReader(encoded)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded)
+		wire.ReadMessage(r, wire.MainNet)
+	}
+}
@@ -28,6 +28,12 @@ func (msg *MsgPong) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodedSize returns the number of bytes Encode writes, which is always
+// zero. This is part of the Sizer interface.
+func (msg *MsgPong) EncodedSize() int {
+	return 0
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgPong) Command() string {
@@ -87,7 +87,7 @@ func (msg *MsgVersion) Decode(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	msg.Timestamp = time.Unix(sec, 0)
+	msg.Timestamp = time.Unix(sec, 0).UTC()
 
 	msg.AddrYou = new(NetAddress)
 	err = readNetAddress(r, msg.AddrYou, false)
@@ -104,33 +104,30 @@ func (msg *MsgVersion) Decode(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	userAgent, err := bmutil.ReadVarString(r, MaxMessagePayload)
+	userAgent, err := ReadBoundedBytes(r, MaxUserAgentLen,
+		"MsgVersion.Decode", "user agent")
 	if err != nil {
 		return err
 	}
-	err = validateUserAgent(userAgent)
-	if err != nil {
-		return err
-	}
-	msg.UserAgent = userAgent
+	msg.UserAgent = string(userAgent)
 
-	streamLen, err := bmutil.ReadVarInt(r)
+	streamLen, err := ReadBoundedLength(r, MaxStreams, "MsgVersion.Decode",
+		"stream count")
 	if err != nil {
 		return err
 	}
 
-	if streamLen > MaxStreams {
-		return fmt.Errorf("number of streams is too large: %v", streamLen)
-	}
-
 	msg.StreamNumbers = make([]uint32, int(streamLen))
 	var n uint64
 	for i := uint64(0); i < streamLen; i++ {
 		n, err = bmutil.ReadVarInt(r)
-		msg.StreamNumbers[i] = uint32(n)
 		if err != nil {
 			return err
 		}
+		if err = bmutil.CheckStream(n); err != nil {
+			return err
+		}
+		msg.StreamNumbers[i] = uint32(n)
 	}
 
 	return nil
@@ -176,10 +173,15 @@ func (msg *MsgVersion) Encode(w io.Writer) error {
 	}
 
 	if len(msg.StreamNumbers) > MaxStreams {
-		return fmt.Errorf("number of streams is too large: %v", len(msg.StreamNumbers))
+		str := fmt.Sprintf("stream count exceeds max length - indicates "+
+			"%d, but max length is %d", len(msg.StreamNumbers), MaxStreams)
+		return NewMessageError("MsgVersion.Encode", str)
 	}
 
 	for _, stream := range msg.StreamNumbers {
+		if err = bmutil.CheckStream(uint64(stream)); err != nil {
+			return err
+		}
 		err = bmutil.WriteVarInt(w, uint64(stream))
 		if err != nil {
 			return err
@@ -217,7 +219,7 @@ func NewMsgVersion(me *NetAddress, you *NetAddress, nonce uint64, streams []uint
 	return &MsgVersion{
 		ProtocolVersion: int32(3),
 		Services:        0,
-		Timestamp:       time.Unix(time.Now().Unix(), 0),
+		Timestamp:       Timestamp(time.Now()),
 		AddrYou:         you,
 		AddrMe:          me,
 		Nonce:           nonce,
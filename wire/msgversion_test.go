@@ -10,7 +10,6 @@ package wire_test
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"net"
 	"reflect"
@@ -291,8 +290,7 @@ func TestVersionWireErrors(t *testing.T) {
 		{baseVersion, baseVersionEncoded, 98, io.ErrShortWrite, io.EOF},
 		// Force error for too many streams.
 		{tooManyStreamsVersion, tooManyStreamsVersionEncoded, 300,
-			fmt.Errorf("number of streams is too large: %v", 2),
-			fmt.Errorf("number of streams is too large: %v", 2)},
+			wireErr, wireErr},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -348,7 +346,7 @@ func TestVersionWireErrors(t *testing.T) {
 var baseVersion = &wire.MsgVersion{
 	ProtocolVersion: 3,
 	Services:        wire.SFNodeNetwork,
-	Timestamp:       time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST)
+	Timestamp:       time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST)
 	AddrYou: &wire.NetAddress{
 		Timestamp: time.Time{}, // Zero value -- no timestamp in version
 		Stream:    0,           // Zero value -- no stream in version
@@ -394,7 +392,7 @@ var baseVersionEncoded = []byte{
 var tooManyStreamsVersion = &wire.MsgVersion{
 	ProtocolVersion: 3,
 	Services:        wire.SFNodeNetwork,
-	Timestamp:       time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST)
+	Timestamp:       time.Unix(0x495fab29, 0).UTC(), // 2009-01-03 12:15:05 -0600 CST)
 	AddrYou: &wire.NetAddress{
 		Timestamp: time.Time{}, // Zero value -- no timestamp in version
 		Stream:    0,           // Zero value -- no stream in version
@@ -435,3 +433,22 @@ var tooManyStreamsVersionEncoded = []byte{
 	0x74, 0x3a, 0x30, 0x2e, 0x30, 0x2e, 0x31, 0x2f, // User agent
 	0x02, 0x01, 0x02, // Stream Numbers
 }
+
+// TestVersionStream tests that version messages with invalid stream numbers
+// can be neither encoded nor decoded.
+func TestVersionStream(t *testing.T) {
+	bvc := *baseVersion
+	bvc.StreamNumbers = []uint32{0}
+	var buf bytes.Buffer
+	if err := bvc.Encode(&buf); err != bmutil.ErrInvalidStream {
+		t.Errorf("Encode: got %v want %v", err, bmutil.ErrInvalidStream)
+	}
+
+	// The stream is the last byte of the encoding.
+	encoded := append([]byte{}, baseVersionEncoded...)
+	encoded[len(encoded)-1] = 0
+	var msg wire.MsgVersion
+	if err := msg.Decode(bytes.NewReader(encoded)); err != bmutil.ErrInvalidStream {
+		t.Errorf("Decode: got %v want %v", err, bmutil.ErrInvalidStream)
+	}
+}
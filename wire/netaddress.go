@@ -75,7 +75,7 @@ func NewNetAddressIPPort(ip net.IP, port uint16, stream uint32, services Service
 	// Limit the timestamp to one second precision since the protocol
 	// doesn't support better.
 	na := NetAddress{
-		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Timestamp: Timestamp(time.Now()),
 		Stream:    stream,
 		Services:  services,
 		IP:        ip,
@@ -115,7 +115,7 @@ func readNetAddress(r io.Reader, na *NetAddress, big bool) error {
 		if err != nil {
 			return err
 		}
-		timestamp = time.Unix(int64(stamp), 0)
+		timestamp = time.Unix(int64(stamp), 0).UTC()
 	}
 
 	err := ReadElements(r, &services, &ip)
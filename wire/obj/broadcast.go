@@ -101,6 +101,17 @@ func (msg *TaglessBroadcast) Encode(w io.Writer) error {
 	return msg.encodePayload(w)
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the wire.Sizer interface.
+func (msg *TaglessBroadcast) EncodedSize() int {
+	return msg.header.EncodedSize() + msg.payloadSize()
+}
+
+// payloadSize returns the number of bytes encodePayload writes.
+func (msg *TaglessBroadcast) payloadSize() int {
+	return len(msg.encrypted)
+}
+
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver. This is part of the Message interface implementation.
 func (msg *TaglessBroadcast) MaxPayloadLength() int {
@@ -121,9 +132,7 @@ func (msg *TaglessBroadcast) Header() *wire.ObjectHeader {
 
 // Payload return the object payload of the message.
 func (msg *TaglessBroadcast) Payload() []byte {
-	w := &bytes.Buffer{}
-	msg.encodePayload(w)
-	return w.Bytes()
+	return encodeToSize(msg.payloadSize(), msg.encodePayload)
 }
 
 // MsgObject transforms the PubKeyObject to a *MsgObject.
@@ -145,9 +154,12 @@ func (msg *TaglessBroadcast) Encrypted() []byte {
 // TaggedBroadcast implements the Object and Message interfaces and
 // represents a broadcast message in tagged format that can be decrypted by
 // all the clients that know the address of the sender.
+//
+// The tag is held by value so that it can be compared with == and used as a
+// map key.
 type TaggedBroadcast struct {
 	header    *wire.ObjectHeader
-	Tag       *hash.Sha
+	Tag       hash.Sha
 	encrypted []byte
 }
 
@@ -159,7 +171,7 @@ func (msg *TaggedBroadcast) EncodeForSigning(w io.Writer) error {
 		return err
 	}
 
-	err = wire.WriteElement(w, msg.Tag)
+	err = wire.WriteElement(w, &msg.Tag)
 	if err != nil {
 		return err
 	}
@@ -171,9 +183,8 @@ func (msg *TaggedBroadcast) EncodeForSigning(w io.Writer) error {
 // This is part of the Message interface implementation.
 func (msg *TaggedBroadcast) decodePayload(r io.Reader) error {
 	var err error
-	msg.Tag = &hash.Sha{}
 
-	err = wire.ReadElements(r, msg.Tag)
+	err = wire.ReadElements(r, &msg.Tag)
 	if err != nil {
 		return err
 	}
@@ -208,7 +219,7 @@ func (msg *TaggedBroadcast) Decode(r io.Reader) error {
 }
 
 func (msg *TaggedBroadcast) encodePayload(w io.Writer) (err error) {
-	if err = wire.WriteElement(w, msg.Tag); err != nil {
+	if err = wire.WriteElement(w, &msg.Tag); err != nil {
 		return err
 	}
 
@@ -227,6 +238,17 @@ func (msg *TaggedBroadcast) Encode(w io.Writer) error {
 	return msg.encodePayload(w)
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the wire.Sizer interface.
+func (msg *TaggedBroadcast) EncodedSize() int {
+	return msg.header.EncodedSize() + msg.payloadSize()
+}
+
+// payloadSize returns the number of bytes encodePayload writes.
+func (msg *TaggedBroadcast) payloadSize() int {
+	return hash.ShaSize + len(msg.encrypted)
+}
+
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver. This is part of the Message interface implementation.
 func (msg *TaggedBroadcast) MaxPayloadLength() int {
@@ -236,7 +258,7 @@ func (msg *TaggedBroadcast) MaxPayloadLength() int {
 func (msg *TaggedBroadcast) String() string {
 	return fmt.Sprintf("Broadcast{%s, Tag:%s, %s}",
 		msg.header.String(),
-		hex.EncodeToString(msg.Tag.Bytes()),
+		hex.EncodeToString(msg.Tag[:]),
 		hex.EncodeToString(msg.encrypted))
 }
 
@@ -247,9 +269,7 @@ func (msg *TaggedBroadcast) Header() *wire.ObjectHeader {
 
 // Payload return the object payload of the message.
 func (msg *TaggedBroadcast) Payload() []byte {
-	w := &bytes.Buffer{}
-	msg.encodePayload(w)
-	return w.Bytes()
+	return encodeToSize(msg.payloadSize(), msg.encodePayload)
 }
 
 // MsgObject transforms the PubKeyObject to a *MsgObject.
@@ -287,10 +307,10 @@ func NewTaglessBroadcast(nonce pow.Nonce, expiration time.Time, streamNumber uin
 
 // NewTaggedBroadcast returns a new object message that conforms to the
 // Object interface using the passed parameters and defaults for the remaining
-// fields.
+// fields. The tag is copied. If it is nil, the tag is left zero.
 func NewTaggedBroadcast(nonce pow.Nonce, expiration time.Time, streamNumber uint64,
 	tag *hash.Sha, encrypted []byte) *TaggedBroadcast {
-	return &TaggedBroadcast{
+	msg := &TaggedBroadcast{
 		header: wire.NewObjectHeader(
 			nonce,
 			expiration,
@@ -298,9 +318,12 @@ func NewTaggedBroadcast(nonce pow.Nonce, expiration time.Time, streamNumber uint
 			TaggedBroadcastVersion,
 			streamNumber,
 		),
-		Tag:       tag,
 		encrypted: encrypted,
 	}
+	if tag != nil {
+		msg.Tag = *tag
+	}
+	return msg
 }
 
 // DecodeBroadcast takes a byte array and turns it into a broadcast object.
@@ -110,6 +110,35 @@ func TestBroadcastWire(t *testing.T) {
 	}
 }
 
+// TestBroadcastTagKey tests that the tag of a decoded broadcast can be used
+// to look up the address it belongs to without keeping any reference to
+// the tag it was created with.
+func TestBroadcastTagKey(t *testing.T) {
+	expires := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST)
+	tag := hash.Sha{1, 2, 3}
+	msg := obj.NewTaggedBroadcast(83928, expires, 1, &tag, []byte{1, 2, 3})
+
+	// Changing the original must not change the broadcast.
+	subscriptions := map[hash.Sha]string{tag: "subscribed"}
+	tag[0] = 0xff
+
+	var b bytes.Buffer
+	if err := msg.Encode(&b); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded := &obj.TaggedBroadcast{}
+	if err := decoded.Decode(&b); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if decoded.Tag != msg.Tag {
+		t.Errorf("got tag %s want %s", decoded.Tag.String(), msg.Tag.String())
+	}
+	if subscriptions[decoded.Tag] != "subscribed" {
+		t.Errorf("tag %s not found", decoded.Tag.String())
+	}
+}
+
 // TestBroadcastWireError tests the Broadcast error paths
 func TestBroadcastWireError(t *testing.T) {
 	wireErr := &wire.MessageError{}
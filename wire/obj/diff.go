@@ -0,0 +1,190 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// diffExcerpt is the number of bytes shown on either side of the first
+// difference between two byte fields.
+const diffExcerpt = 8
+
+// FieldDiff describes one field which differs between two objects. A and B
+// are human-readable forms of the field in each object. For byte fields,
+// they are hex excerpts around the first differing byte.
+type FieldDiff struct {
+	Field string
+	A     string
+	B     string
+}
+
+// String returns the difference in a human-readable form.
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.A, d.B)
+}
+
+// field is a named value of an object which Diff compares. The value is
+// either a []byte or something that formats well with %v.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// Diff compares two objects field by field and returns the fields which
+// differ, in the order in which they are encoded. It returns nil if the
+// objects are the same. A field which only one of the objects has is
+// reported with "<none>" in place of the other's value.
+func Diff(a, b Object) []FieldDiff {
+	fa, fb := fields(a), fields(b)
+
+	var diffs []FieldDiff
+	for _, f := range fa {
+		g, ok := lookupField(fb, f.name)
+		if !ok {
+			diffs = append(diffs, FieldDiff{f.name, formatField(f.value), "<none>"})
+			continue
+		}
+		if d, ok := diffField(f.name, f.value, g.value); ok {
+			diffs = append(diffs, d)
+		}
+	}
+	for _, g := range fb {
+		if _, ok := lookupField(fa, g.name); !ok {
+			diffs = append(diffs, FieldDiff{g.name, "<none>", formatField(g.value)})
+		}
+	}
+
+	return diffs
+}
+
+func lookupField(fs []field, name string) (field, bool) {
+	for _, f := range fs {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// diffField compares two values of the same field.
+func diffField(name string, a, b interface{}) (FieldDiff, bool) {
+	ab, aok := a.([]byte)
+	bb, bok := b.([]byte)
+	if aok && bok {
+		if bytes.Equal(ab, bb) {
+			return FieldDiff{}, false
+		}
+		i := firstDifference(ab, bb)
+		return FieldDiff{name, hexExcerpt(ab, i), hexExcerpt(bb, i)}, true
+	}
+
+	fa, fb := formatField(a), formatField(b)
+	if fa == fb {
+		return FieldDiff{}, false
+	}
+	return FieldDiff{name, fa, fb}, true
+}
+
+func formatField(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return hexExcerpt(b, 0)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// firstDifference returns the index of the first byte at which a and b
+// differ, or the length of the shorter if one is a prefix of the other.
+func firstDifference(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// hexExcerpt returns the length of b and the bytes around offset i in hex.
+func hexExcerpt(b []byte, i int) string {
+	start := i - diffExcerpt
+	if start < 0 {
+		start = 0
+	}
+	end := i + diffExcerpt
+	if end > len(b) {
+		end = len(b)
+	}
+
+	s := fmt.Sprintf("len %d, at %d: ", len(b), i)
+	if start > 0 {
+		s += "..."
+	}
+	s += hex.EncodeToString(b[start:end])
+	if end < len(b) {
+		s += "..."
+	}
+	return s
+}
+
+// fields returns the fields of an object in the order in which they are
+// encoded.
+func fields(o Object) []field {
+	h := o.Header()
+	fs := []field{
+		{"Type", fmt.Sprintf("%T", o)},
+		{"Nonce", h.Nonce},
+		{"Expiration", h.Expiration()},
+		{"ObjectType", h.ObjectType},
+		{"Version", h.Version},
+		{"StreamNumber", h.StreamNumber},
+	}
+
+	switch m := o.(type) {
+	case *GetPubKey:
+		if m.Ripe != nil {
+			fs = append(fs, field{"Ripe", m.Ripe.Bytes()})
+		}
+		fs = append(fs, field{"Tag", m.Tag.Bytes()})
+	case *SimplePubKey:
+		fs = append(fs, pubKeyDataFields(m.data)...)
+	case *ExtendedPubKey:
+		fs = append(fs, pubKeyDataFields(m.data)...)
+		fs = append(fs, field{"Signature", m.Signature})
+	case *EncryptedPubKey:
+		fs = append(fs, field{"Tag", m.Tag.Bytes()},
+			field{"Encrypted", m.Encrypted})
+	case *Message:
+		fs = append(fs, field{"Encrypted", m.Encrypted})
+	case *TaglessBroadcast:
+		fs = append(fs, field{"Encrypted", m.encrypted})
+	case *TaggedBroadcast:
+		fs = append(fs, field{"Tag", m.Tag.Bytes()},
+			field{"Encrypted", m.encrypted})
+	default:
+		fs = append(fs, field{"Payload", o.Payload()})
+	}
+
+	return fs
+}
+
+func pubKeyDataFields(data *PubKeyData) []field {
+	if data == nil {
+		return nil
+	}
+
+	fs := []field{{"Behavior", data.Behavior}}
+	if data.Verification != nil {
+		fs = append(fs, field{"Verification", data.Verification.Bytes()})
+	}
+	if data.Encryption != nil {
+		fs = append(fs, field{"Encryption", data.Encryption.Bytes()})
+	}
+	if data.Pow != nil {
+		fs = append(fs, field{"NonceTrialsPerByte", data.Pow.NonceTrialsPerByte},
+			field{"ExtraBytes", data.Pow.ExtraBytes})
+	}
+	return fs
+}
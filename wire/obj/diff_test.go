@@ -0,0 +1,106 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TestDiff tests that Diff reports the fields which differ between two
+// objects and nothing else.
+func TestDiff(t *testing.T) {
+	expires := time.Unix(0x495fab29, 0)
+	enc := make([]byte, 40)
+	changed := make([]byte, 40)
+	changed[20] = 0xff
+
+	var tag, otherTag hash.Sha
+	otherTag[0] = 1
+
+	tests := []struct {
+		a, b   obj.Object
+		fields []string
+	}{
+		{
+			obj.NewMessage(123, expires, 1, enc),
+			obj.NewMessage(123, expires, 1, enc),
+			nil,
+		},
+		{
+			obj.NewMessage(123, expires, 1, enc),
+			obj.NewMessage(124, expires, 2, enc),
+			[]string{"Nonce", "StreamNumber"},
+		},
+		{
+			obj.NewMessage(123, expires, 1, enc),
+			obj.NewMessage(123, expires.Add(time.Hour), 1, changed),
+			[]string{"Expiration", "Encrypted"},
+		},
+		{
+			obj.NewTaggedBroadcast(123, expires, 1, &tag, enc),
+			obj.NewTaggedBroadcast(123, expires, 1, &otherTag, enc),
+			[]string{"Tag"},
+		},
+		{
+			obj.NewTaglessBroadcast(123, expires, 1, enc),
+			obj.NewTaggedBroadcast(123, expires, 1, &tag, enc),
+			[]string{"Type", "Version", "Tag"},
+		},
+		{
+			obj.NewMessage(123, expires, 1, enc),
+			obj.NewEncryptedPubKey(123, expires, 1, &tag, enc),
+			[]string{"Type", "ObjectType", "Version", "Tag"},
+		},
+	}
+
+	for i, test := range tests {
+		diffs := obj.Diff(test.a, test.b)
+		var got []string
+		for _, d := range diffs {
+			got = append(got, d.Field)
+		}
+		if !reflect.DeepEqual(got, test.fields) {
+			t.Errorf("test #%d: got %v want %v", i, got, test.fields)
+		}
+	}
+}
+
+// TestDiffExcerpt tests that a byte field is reported with a hex excerpt
+// around the first difference.
+func TestDiffExcerpt(t *testing.T) {
+	expires := time.Unix(0x495fab29, 0)
+	a := make([]byte, 40)
+	b := make([]byte, 40)
+	b[20] = 0xff
+
+	diffs := obj.Diff(obj.NewMessage(1, expires, 1, a),
+		obj.NewMessage(1, expires, 1, b))
+	if len(diffs) != 1 {
+		t.Fatalf("got %d differences want 1", len(diffs))
+	}
+
+	d := diffs[0]
+	want := "len 40, at 20: ...0000000000000000ff00000000000000..."
+	if d.B != want {
+		t.Errorf("got %q want %q", d.B, want)
+	}
+	if !strings.HasPrefix(d.String(), "Encrypted: len 40, at 20: ") {
+		t.Errorf("unexpected string %q", d.String())
+	}
+
+	// A raw object is compared by its payload.
+	header := obj.NewMessage(1, expires, 1, a).Header()
+	diffs = obj.Diff(wire.NewMsgObject(header, a), wire.NewMsgObject(header, b))
+	if len(diffs) != 1 || diffs[0].Field != "Payload" {
+		t.Errorf("got %v want a difference in Payload", diffs)
+	}
+}
@@ -6,7 +6,6 @@
 package obj
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -31,15 +30,14 @@ const (
 type GetPubKey struct {
 	header *wire.ObjectHeader
 	Ripe   *hash.Ripe
-	Tag    *hash.Sha
+	Tag    hash.Sha
 }
 
 func (msg *GetPubKey) decodePayload(r io.Reader) error {
 	var err error
 	switch msg.header.Version {
 	case TagGetPubKeyVersion:
-		msg.Tag, _ = hash.NewSha(make([]byte, hash.ShaSize))
-		if err = wire.ReadElement(r, msg.Tag); err != nil {
+		if err = wire.ReadElement(r, &msg.Tag); err != nil {
 			return err
 		}
 	case SimplePubKeyVersion, ExtendedPubKeyVersion:
@@ -75,7 +73,7 @@ func (msg *GetPubKey) Decode(r io.Reader) error {
 func (msg *GetPubKey) encodePayload(w io.Writer) (err error) {
 	switch msg.header.Version {
 	case TagGetPubKeyVersion:
-		if err = wire.WriteElement(w, msg.Tag); err != nil {
+		if err = wire.WriteElement(w, &msg.Tag); err != nil {
 			return err
 		}
 	case SimplePubKeyVersion, ExtendedPubKeyVersion:
@@ -104,7 +102,7 @@ func (msg *GetPubKey) Encode(w io.Writer) error {
 func (msg *GetPubKey) String() string {
 	var hash string
 	if msg.Ripe == nil {
-		hash = hex.EncodeToString(msg.Tag.Bytes())
+		hash = hex.EncodeToString(msg.Tag[:])
 	} else {
 		hash = hex.EncodeToString(msg.Ripe.Bytes())
 	}
@@ -130,9 +128,7 @@ func (msg *GetPubKey) Header() *wire.ObjectHeader {
 
 // Payload return the object payload of the message.
 func (msg *GetPubKey) Payload() []byte {
-	w := &bytes.Buffer{}
-	msg.encodePayload(w)
-	return w.Bytes()
+	return encodeToBytes(msg.encodePayload)
 }
 
 // MsgObject transforms the PubKeyObject to a *MsgObject.
@@ -155,6 +151,6 @@ func NewGetPubKey(nonce pow.Nonce, expiration time.Time, address Address) *GetPu
 			address.Version(),
 			address.Stream()),
 		Ripe: address.RipeHash(),
-		Tag:  Tag(address),
+		Tag:  *Tag(address),
 	}
 }
@@ -72,7 +72,7 @@ func MakeGetPubKey(
 
 	// Limit the timestamp to one second precision since the protocol
 	// doesn't support better.
-	return &GetPubKey{
+	msg := &GetPubKey{
 		header: wire.NewObjectHeader(
 			nonce,
 			expiration,
@@ -80,8 +80,11 @@ func MakeGetPubKey(
 			version,
 			stream),
 		Ripe: ripe,
-		Tag:  tag,
 	}
+	if tag != nil {
+		msg.Tag = *tag
+	}
+	return msg
 }
 
 // TstTaggedBroadcast is a broadcast from a v4 address (includes a tag).
@@ -93,7 +96,7 @@ func TstTaggedBroadcast() *TaggedBroadcast {
 			wire.ObjectTypeBroadcast,
 			TaggedBroadcastVersion,
 			1),
-		Tag: &hash.Sha{
+		Tag: hash.Sha{
 			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 		},
@@ -142,7 +145,6 @@ func TstBaseGetPubKey() *GetPubKey {
 			3,
 			1),
 		Ripe: &hash.Ripe{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Tag:  nil,
 	}
 }
 
@@ -156,7 +158,7 @@ func TstTagGetPubKey() *GetPubKey {
 			4,
 			1),
 		Ripe: nil,
-		Tag: &hash.Sha{
+		Tag: hash.Sha{
 			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 	}
@@ -172,7 +174,6 @@ func TstInvalidGetPubKeyVersion() *GetPubKey {
 			5,
 			1),
 		Ripe: &hash.Ripe{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Tag:  nil,
 	}
 }
 
@@ -244,7 +245,7 @@ func TstEncryptedPubKey(tag *hash.Sha) *EncryptedPubKey {
 			wire.ObjectTypePubKey,
 			4,
 			1),
-		Tag:       tag,
+		Tag:       *tag,
 		Encrypted: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8},
 	}
 }
@@ -66,6 +66,12 @@ func (msg *Message) Encode(w io.Writer) error {
 	return err
 }
 
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the wire.Sizer interface.
+func (msg *Message) EncodedSize() int {
+	return msg.header.EncodedSize() + len(msg.Encrypted)
+}
+
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver. This is part of the Message interface implementation.
 func (msg *Message) MaxPayloadLength() int {
@@ -39,6 +39,26 @@ func InventoryHash(obj Object) *hash.Sha {
 	return hash.InventoryHash(wire.Encode(obj))
 }
 
+// encodeToBytes calls encode with a pooled buffer and returns a copy of
+// what it wrote.
+func encodeToBytes(encode func(io.Writer) error) []byte {
+	buf := wire.GetBuffer()
+	defer wire.PutBuffer(buf)
+
+	encode(buf)
+	b := make([]byte, buf.Len())
+	copy(b, buf.Bytes())
+	return b
+}
+
+// encodeToSize calls encode with a buffer allocated once at the given size,
+// which must be exactly the number of bytes that encode writes.
+func encodeToSize(size int, encode func(io.Writer) error) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	encode(buf)
+	return buf.Bytes()
+}
+
 // DecodeObject tries to convert a MsgObject into an an Object.
 func DecodeObject(r io.Reader) (Object, error) {
 	header, err := wire.DecodeObjectHeader(r)
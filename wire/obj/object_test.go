@@ -164,3 +164,28 @@ func TestObject(t *testing.T) {
 		}
 	}
 }
+
+// TestEncodedSize tests that EncodedSize and Payload agree with Encode for
+// the objects with encrypted payloads.
+func TestEncodedSize(t *testing.T) {
+	tests := []interface {
+		obj.Object
+		wire.Sizer
+	}{
+		obj.TstBaseMessage(),
+		obj.TstTaglessBroadcast(),
+		obj.TstTaggedBroadcast(),
+	}
+
+	for i, o := range tests {
+		encoded := wire.Encode(o)
+		if size := o.EncodedSize(); size != len(encoded) {
+			t.Errorf("EncodedSize #%d: got %d want %d", i, size, len(encoded))
+		}
+		headerSize := o.Header().EncodedSize()
+		if !bytes.Equal(o.Payload(), encoded[headerSize:]) {
+			t.Errorf("Payload #%d: got %x want %x", i, o.Payload(),
+				encoded[headerSize:])
+		}
+	}
+}
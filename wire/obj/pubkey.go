@@ -52,20 +52,7 @@ func EncodePubKeySignature(w io.Writer, signature []byte) (err error) {
 
 // DecodePubKeySignature decodes a PubKey signature.
 func DecodePubKeySignature(r io.Reader) (signature []byte, err error) {
-	sigLength, err := bmutil.ReadVarInt(r)
-	if err != nil {
-		return
-	}
-	if sigLength > SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, SignatureMaxLength)
-		err = wire.NewMessageError("Decode", str)
-		return
-	}
-	signature = make([]byte, sigLength)
-	_, err = io.ReadFull(r, signature)
-	return
+	return wire.ReadBoundedBytes(r, SignatureMaxLength, "Decode", "signature")
 }
 
 // SimplePubKey implements the Message and Object interfaces and represents a pubkey sent in
@@ -159,9 +146,7 @@ func (p *SimplePubKey) Header() *wire.ObjectHeader {
 // Payload is part of the Object interface and
 // returns the object payload of the message.
 func (p *SimplePubKey) Payload() []byte {
-	w := &bytes.Buffer{}
-	p.encodePayload(w)
-	return w.Bytes()
+	return encodeToBytes(p.encodePayload)
 }
 
 // MsgObject is part of the Object interface and transforms
@@ -305,9 +290,7 @@ func (p *ExtendedPubKey) Header() *wire.ObjectHeader {
 // Payload is part of the Object interface and
 // returns the object payload of the message.
 func (p *ExtendedPubKey) Payload() []byte {
-	w := &bytes.Buffer{}
-	p.encodePayload(w)
-	return w.Bytes()
+	return encodeToBytes(p.encodePayload)
 }
 
 // MsgObject is part of the Object interface and transforms
@@ -376,14 +359,13 @@ func NewExtendedPubKey(nonce pow.Nonce, expiration time.Time, streamNumber uint6
 // EncryptedPubKey represents an encrypted pubkey.
 type EncryptedPubKey struct {
 	header    *wire.ObjectHeader
-	Tag       *hash.Sha
+	Tag       hash.Sha
 	Encrypted []byte
 }
 
 func (p *EncryptedPubKey) decodePayload(r io.Reader) error {
 	var err error
-	p.Tag = &hash.Sha{}
-	if err = wire.ReadElement(r, p.Tag); err != nil {
+	if err = wire.ReadElement(r, &p.Tag); err != nil {
 		return err
 	}
 	// The rest is the encrypted data, accessible only to those that know
@@ -416,7 +398,7 @@ func (p *EncryptedPubKey) Decode(r io.Reader) error {
 }
 
 func (p *EncryptedPubKey) encodePayload(w io.Writer) error {
-	if err := wire.WriteElement(w, p.Tag); err != nil {
+	if err := wire.WriteElement(w, &p.Tag); err != nil {
 		return err
 	}
 	// The rest is the encrypted data, accessible only to the holder
@@ -456,9 +438,7 @@ func (p *EncryptedPubKey) Header() *wire.ObjectHeader {
 // Payload is part of the Object interface and
 // returns the object payload of the message.
 func (p *EncryptedPubKey) Payload() []byte {
-	w := &bytes.Buffer{}
-	p.encodePayload(w)
-	return w.Bytes()
+	return encodeToBytes(p.encodePayload)
 }
 
 // MsgObject is part of the Object interface and transforms
@@ -473,9 +453,10 @@ func (p *EncryptedPubKey) String() string {
 
 // NewEncryptedPubKey returns a new object message that conforms to the Message
 // interface using the passed parameters and defaults for the remaining fields.
+// The tag is copied. If it is nil, the tag is left zero.
 func NewEncryptedPubKey(nonce pow.Nonce, expiration time.Time,
 	streamNumber uint64, tag *hash.Sha, encrypted []byte) *EncryptedPubKey {
-	return &EncryptedPubKey{
+	p := &EncryptedPubKey{
 		header: wire.NewObjectHeader(
 			nonce,
 			expiration,
@@ -483,9 +464,12 @@ func NewEncryptedPubKey(nonce pow.Nonce, expiration time.Time,
 			EncryptedPubKeyVersion,
 			streamNumber,
 		),
-		Tag:       tag,
 		Encrypted: encrypted,
 	}
+	if tag != nil {
+		p.Tag = *tag
+	}
+	return p
 }
 
 // DecodePubKey takes a reader and decodes it as some kind of PubKey object.
@@ -42,9 +42,9 @@ type ObjectHeader struct {
 	StreamNumber uint64
 }
 
-// Expiration provides the expration time.
+// Expiration provides the expration time, in UTC.
 func (h *ObjectHeader) Expiration() time.Time {
-	return time.Unix(int64(h.expiration), 0)
+	return time.Unix(int64(h.expiration), 0).UTC()
 }
 
 // String returns the header in a human-readible string form.
@@ -56,58 +56,39 @@ func (h *ObjectHeader) String() string {
 // EncodeForSigning encodes the object header used for signing.
 // It consists of everything in the normal object header except for nonce.
 func (h *ObjectHeader) EncodeForSigning(w io.Writer) error {
-	err := WriteElements(w, h.expiration, h.ObjectType)
-	if err != nil {
-		return err
-	}
-	if err = bmutil.WriteVarInt(w, h.Version); err != nil {
-		return err
-	}
-	if err = bmutil.WriteVarInt(w, h.StreamNumber); err != nil {
-		return err
-	}
-	return nil
+	var b [maxObjectHeaderSize]byte
+	_, err := w.Write(appendObjectHeaderForSigning(b[:0], h))
+	return err
 }
 
 // Encode encodes the object header to the given writer. Object
 // header consists of Nonce, ExpiresTime, ObjectType, Version and Stream, in
 // that order. Read Protocol Specifications for more information.
 func (h *ObjectHeader) Encode(w io.Writer) error {
-	err := h.Nonce.Encode(w)
-	if err != nil {
-		return err
-	}
+	var b [maxObjectHeaderSize]byte
+	_, err := w.Write(appendObjectHeader(b[:0], h))
+	return err
+}
 
-	return h.EncodeForSigning(w)
+// EncodedSize returns the number of bytes Encode writes. This is part of
+// the Sizer interface.
+func (h *ObjectHeader) EncodedSize() int {
+	return objectHeaderSize(h)
 }
 
 // DecodeObjectHeader decodes the object header from given reader. Object
 // header consists of Nonce, ExpiresTime, ObjectType, Version and Stream, in
-// that order. Read Protocol Specifications for more information.
+// that order. Read Protocol Specifications for more information. It returns
+// io.EOF if r is empty, io.ErrUnexpectedEOF if it ends within the header and
+// bmutil.ErrInvalidStream if the stream number is zero or too large.
 func DecodeObjectHeader(r io.Reader) (*ObjectHeader, error) {
 	var header ObjectHeader
-	var err error
-	header.Nonce, err = pow.DecodeNonce(r)
-	if err != nil {
+	if err := readObjectHeader(r, &header); err != nil {
 		return nil, err
 	}
-
-	err = ReadElements(r, &header.expiration, &header.ObjectType)
-	if err != nil {
-		return nil, err
-	}
-
-	version, err := bmutil.ReadVarInt(r)
-	if err != nil {
-		return nil, err
-	}
-	header.Version = version
-
-	streamNumber, err := bmutil.ReadVarInt(r)
-	if err != nil {
+	if err := bmutil.CheckStream(header.StreamNumber); err != nil {
 		return nil, err
 	}
-	header.StreamNumber = streamNumber
 
 	return &header, nil
 }
@@ -67,12 +67,17 @@ type BitmessageNet uint32
 const (
 	// MainNet represents the main bitmessage network.
 	MainNet BitmessageNet = 0xe9beb4d9
+
+	// TestNet represents the test network. Its magic is one more than that
+	// of MainNet, and is not used by any Bitcoin network.
+	TestNet BitmessageNet = 0xe9beb4da
 )
 
 // bnStrings is a map of bitmessage networks back to their constant names for
 // pretty printing.
 var bnStrings = map[BitmessageNet]string{
 	MainNet: "MainNet",
+	TestNet: "TestNet",
 }
 
 // String returns the BitmessageNet in human-readable form.
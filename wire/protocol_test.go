@@ -36,6 +36,29 @@ func TestServiceFlagStringer(t *testing.T) {
 	}
 }
 
+// TestBitmessageNetMagic tests that the test network cannot be mistaken for
+// the main network or for a Bitcoin network.
+func TestBitmessageNetMagic(t *testing.T) {
+	bitcoin := []wire.BitmessageNet{
+		0xd9b4bef9, // mainnet
+		0x0709110b, // testnet3
+		0xdab5bffa, // regtest
+	}
+	if wire.TestNet == wire.MainNet {
+		t.Errorf("TestNet has the magic of MainNet")
+	}
+	for _, magic := range bitcoin {
+		if wire.TestNet == magic || wire.TestNet == swap(magic) {
+			t.Errorf("TestNet has the magic %#x of a Bitcoin network", magic)
+		}
+	}
+}
+
+// swap reverses the byte order of a network magic.
+func swap(n wire.BitmessageNet) wire.BitmessageNet {
+	return n<<24 | n>>24 | (n&0xff00)<<8 | (n>>8)&0xff00
+}
+
 // TestBitmessageNetStringer tests the stringized output for bitmessage net types.
 func TestBitmessageNetStringer(t *testing.T) {
 	tests := []struct {
@@ -43,6 +66,7 @@ func TestBitmessageNetStringer(t *testing.T) {
 		want string
 	}{
 		{wire.MainNet, "MainNet"},
+		{wire.TestNet, "TestNet"},
 		{0xffffffff, "Unknown BitmessageNet (4294967295)"},
 	}
 
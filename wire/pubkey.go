@@ -8,7 +8,9 @@ package wire
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/btcsuite/btcd/btcec"
 )
@@ -17,6 +19,14 @@ import (
 // that the first byte (0x04) is excluded when storing them.
 const PubKeySize = 64
 
+// UncompressedPubKeySize is the size of the standard uncompressed
+// serialization of a public key, which is a PubKey preceded by the prefix
+// byte 0x04.
+const UncompressedPubKeySize = PubKeySize + 1
+
+// pubKeyUncompressed is the prefix byte of an uncompressed public key.
+const pubKeyUncompressed byte = 0x04
+
 // MaxPubKeyStringSize is the maximum length of a PubKey string.
 const MaxPubKeyStringSize = PubKeySize * 2
 
@@ -24,6 +34,11 @@ const MaxPubKeyStringSize = PubKeySize * 2
 // a PubKey string that does not have the right number of characters.
 var ErrPubKeyStrSize = fmt.Errorf("string length must be %v chars", MaxPubKeyStringSize)
 
+// ErrInvalidPubKey describes an error that indicates a PubKey which is not
+// the coordinates of a point on the secp256k1 curve, and so cannot be used
+// to verify a signature or to encrypt.
+var ErrInvalidPubKey = errors.New("public key is not a point on secp256k1")
+
 // PubKey is used in several of the bitmessage messages and common structures.
 // The first 32 bytes contain the X value and the other 32 contain the Y value.
 type PubKey [PubKeySize]byte
@@ -59,14 +74,39 @@ func (pubkey *PubKey) IsEqual(target *PubKey) bool {
 	return bytes.Equal(pubkey[:], target[:])
 }
 
+// Validate checks that the PubKey is the uncompressed form of a secp256k1
+// public key, which is to say that its X and Y values are less than the
+// order of the field and are the coordinates of a point on the curve. It
+// returns ErrInvalidPubKey otherwise.
+func (pubkey *PubKey) Validate() error {
+	curve := btcec.S256()
+	x := new(big.Int).SetBytes(pubkey[:PubKeySize/2])
+	y := new(big.Int).SetBytes(pubkey[PubKeySize/2:])
+	if x.Cmp(curve.P) >= 0 || y.Cmp(curve.P) >= 0 || !curve.IsOnCurve(x, y) {
+		return ErrInvalidPubKey
+	}
+	return nil
+}
+
 // ToBtcec converts PubKey to btcec.PublicKey so that it can be used for
-// cryptographic operations like encryption/signature verification.
+// cryptographic operations like encryption/signature verification. It
+// returns ErrInvalidPubKey if the PubKey fails Validate.
 func (pubkey *PubKey) ToBtcec() (key *btcec.PublicKey, err error) {
-	b := make([]byte, PubKeySize+1)
-	b[0] = 0x04 // uncompressed key
-	copy(b[1:PubKeySize+1], pubkey.Bytes())
+	if err = pubkey.Validate(); err != nil {
+		return nil, err
+	}
+
+	return btcec.ParsePubKey(pubkey.Uncompressed(), btcec.S256())
+}
+
+// Uncompressed returns the standard uncompressed serialization of the PubKey,
+// which is the prefix 0x04 followed by the X and Y values.
+func (pubkey *PubKey) Uncompressed() []byte {
+	b := make([]byte, UncompressedPubKeySize)
+	b[0] = pubKeyUncompressed
+	copy(b[1:], pubkey[:])
 
-	return btcec.ParsePubKey(b, btcec.S256())
+	return b
 }
 
 // NewPubKey returns a new PubKey from a byte slice. An error is returned if
@@ -80,6 +120,35 @@ func NewPubKey(newHash []byte) (*PubKey, error) {
 	return &pubkey, err
 }
 
+// NewPubKeyFromBtcec returns the PubKey for a btcec.PublicKey, which is its
+// uncompressed serialization without the prefix.
+func NewPubKeyFromBtcec(key *btcec.PublicKey) *PubKey {
+	var pubkey PubKey
+	copy(pubkey[:], key.SerializeUncompressed()[1:])
+	return &pubkey
+}
+
+// NewPubKeyFromUncompressed returns a PubKey from the standard uncompressed
+// serialization of a public key. An error is returned if the number of bytes
+// is not UncompressedPubKeySize, and ErrInvalidPubKey is returned if the
+// prefix is not 0x04 or the key fails Validate.
+func NewPubKeyFromUncompressed(b []byte) (*PubKey, error) {
+	if len(b) != UncompressedPubKeySize {
+		return nil, fmt.Errorf("invalid uncompressed pub key length of %v, want %v",
+			len(b), UncompressedPubKeySize)
+	}
+	if b[0] != pubKeyUncompressed {
+		return nil, ErrInvalidPubKey
+	}
+
+	var pubkey PubKey
+	copy(pubkey[:], b[1:])
+	if err := pubkey.Validate(); err != nil {
+		return nil, err
+	}
+	return &pubkey, nil
+}
+
 // NewPubKeyFromStr creates a PubKey from a hash string. The string should be
 // the hexadecimal string of the PubKey.
 func NewPubKeyFromStr(pubkey string) (*PubKey, error) {
@@ -231,11 +231,88 @@ func TestToBtcec(t *testing.T) {
 		if btcErrExp != nil {
 			if btcErrTest == nil {
 				t.Errorf("Error case %d: expecting error %s, but no error was returned. ", test_case, btcErrExp)
-			} else if btcErrTest.Error() != btcErrExp.Error() {
-				t.Errorf("Error case %d: expecting error %s, but got error %s. ", test_case, btcErrExp, btcErrTest)
+			} else if btcErrTest != wire.ErrInvalidPubKey {
+				t.Errorf("Error case %d: expecting error %s, but got error %s. ", test_case, wire.ErrInvalidPubKey, btcErrTest)
 			}
 		} else if !bytes.Equal(btcPubKeyExp.SerializeUncompressed(), btcPubKeyTest.SerializeUncompressed()) {
 			t.Errorf("Error case %d: different public key bytes returned. ", test_case)
 		}
 	}
 }
+
+// TestPubKeyValidate tests that keys which are not points on the curve are
+// rejected.
+func TestPubKeyValidate(t *testing.T) {
+	// The generator of secp256k1.
+	g, _ := hex.DecodeString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" +
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
+	// X is the order of the field.
+	p, _ := hex.DecodeString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f" +
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
+	offCurve := append(append([]byte{}, g[:32]...), make([]byte, 32)...)
+
+	tests := []struct {
+		key []byte
+		err error
+	}{
+		{g, nil},
+		{p, wire.ErrInvalidPubKey},
+		{offCurve, wire.ErrInvalidPubKey},
+		{make([]byte, wire.PubKeySize), wire.ErrInvalidPubKey},
+	}
+
+	for i, test := range tests {
+		pk, err := wire.NewPubKey(test.key)
+		if err != nil {
+			t.Fatalf("NewPubKey #%d: %v", i, err)
+		}
+		if err := pk.Validate(); err != test.err {
+			t.Errorf("Validate #%d: got %v want %v", i, err, test.err)
+		}
+	}
+}
+
+// TestPubKeyUncompressed tests conversion between PubKeys and the standard
+// uncompressed serialization of public keys.
+func TestPubKeyUncompressed(t *testing.T) {
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	ser := priv.PubKey().SerializeUncompressed()
+
+	pk := wire.NewPubKeyFromBtcec(priv.PubKey())
+	if !bytes.Equal(pk.Bytes(), ser[1:]) {
+		t.Errorf("NewPubKeyFromBtcec: got %x want %x", pk.Bytes(), ser[1:])
+	}
+	if !bytes.Equal(pk.Uncompressed(), ser) {
+		t.Errorf("Uncompressed: got %x want %x", pk.Uncompressed(), ser)
+	}
+
+	parsed, err := wire.NewPubKeyFromUncompressed(ser)
+	if err != nil {
+		t.Fatalf("NewPubKeyFromUncompressed: unexpected error %v", err)
+	}
+	if !parsed.IsEqual(pk) {
+		t.Errorf("NewPubKeyFromUncompressed: got %v want %v", parsed, pk)
+	}
+
+	// Wrong length.
+	if _, err = wire.NewPubKeyFromUncompressed(ser[1:]); err == nil {
+		t.Error("NewPubKeyFromUncompressed: expected error for short key")
+	}
+
+	// Compressed prefix.
+	bad := append([]byte{}, ser...)
+	bad[0] = 0x02
+	if _, err = wire.NewPubKeyFromUncompressed(bad); err != wire.ErrInvalidPubKey {
+		t.Errorf("NewPubKeyFromUncompressed: got %v want %v", err, wire.ErrInvalidPubKey)
+	}
+
+	// Not on the curve.
+	bad = append([]byte{}, ser...)
+	bad[wire.UncompressedPubKeySize-1] ^= 0x01
+	if _, err = wire.NewPubKeyFromUncompressed(bad); err != wire.ErrInvalidPubKey {
+		t.Errorf("NewPubKeyFromUncompressed: got %v want %v", err, wire.ErrInvalidPubKey)
+	}
+}
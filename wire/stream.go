@@ -0,0 +1,69 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import "sort"
+
+// RootStream is the stream at the root of the tree of streams. Every other
+// stream n is a child of stream n/2, so stream n has children 2n and 2n+1.
+// A node which serves a stream also connects to nodes in its child streams,
+// so that it can pass objects and addresses between them.
+const RootStream uint32 = 1
+
+// StreamParent returns the parent of a stream, and false if the stream is
+// the root or is not a valid stream.
+func StreamParent(stream uint32) (uint32, bool) {
+	if stream <= RootStream {
+		return 0, false
+	}
+	return stream / 2, true
+}
+
+// StreamChildren returns the children of a stream. Children whose numbers
+// would be too large to encode in a network address are left out.
+func StreamChildren(stream uint32) []uint32 {
+	if stream == 0 || stream > 0x7fffffff {
+		return nil
+	}
+	return []uint32{2 * stream, 2*stream + 1}
+}
+
+// IsStreamDescendant returns whether stream is in the subtree of the tree
+// of streams below ancestor, not counting ancestor itself.
+func IsStreamDescendant(stream, ancestor uint32) bool {
+	if ancestor == 0 {
+		return false
+	}
+	for stream > ancestor {
+		stream /= 2
+		if stream == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectStreams returns the streams to which a node which serves the given
+// streams should connect: the streams themselves and their children, in
+// ascending order without duplicates.
+func ConnectStreams(streams []uint32) []uint32 {
+	set := make(map[uint32]struct{})
+	for _, stream := range streams {
+		if stream == 0 {
+			continue
+		}
+		set[stream] = struct{}{}
+		for _, child := range StreamChildren(stream) {
+			set[child] = struct{}{}
+		}
+	}
+
+	connect := make([]uint32, 0, len(set))
+	for stream := range set {
+		connect = append(connect, stream)
+	}
+	sort.Slice(connect, func(i, j int) bool { return connect[i] < connect[j] })
+	return connect
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// TestStreamTree tests navigation of the tree of streams.
+func TestStreamTree(t *testing.T) {
+	parents := []struct {
+		stream, parent uint32
+		ok             bool
+	}{
+		{0, 0, false},
+		{1, 0, false},
+		{2, 1, true},
+		{3, 1, true},
+		{7, 3, true},
+		{0xffffffff, 0x7fffffff, true},
+	}
+	for _, test := range parents {
+		parent, ok := wire.StreamParent(test.stream)
+		if parent != test.parent || ok != test.ok {
+			t.Errorf("StreamParent(%d): got %d, %v want %d, %v",
+				test.stream, parent, ok, test.parent, test.ok)
+		}
+	}
+
+	children := []struct {
+		stream   uint32
+		children []uint32
+	}{
+		{0, nil},
+		{1, []uint32{2, 3}},
+		{5, []uint32{10, 11}},
+		{0x7fffffff, []uint32{0xfffffffe, 0xffffffff}},
+		{0x80000000, nil},
+	}
+	for _, test := range children {
+		got := wire.StreamChildren(test.stream)
+		if !reflect.DeepEqual(got, test.children) {
+			t.Errorf("StreamChildren(%d): got %v want %v", test.stream,
+				got, test.children)
+		}
+		for _, child := range got {
+			if parent, _ := wire.StreamParent(child); parent != test.stream {
+				t.Errorf("parent of child %d is %d, not %d", child,
+					parent, test.stream)
+			}
+		}
+	}
+
+	descendants := []struct {
+		stream, ancestor uint32
+		want             bool
+	}{
+		{2, 1, true},
+		{13, 1, true},
+		{13, 3, true},
+		{13, 2, false},
+		{3, 3, false},
+		{1, 2, false},
+		{5, 0, false},
+	}
+	for _, test := range descendants {
+		if got := wire.IsStreamDescendant(test.stream, test.ancestor); got != test.want {
+			t.Errorf("IsStreamDescendant(%d, %d): got %v want %v",
+				test.stream, test.ancestor, got, test.want)
+		}
+	}
+}
+
+// TestConnectStreams tests the streams to which a node connects.
+func TestConnectStreams(t *testing.T) {
+	tests := []struct {
+		streams []uint32
+		want    []uint32
+	}{
+		{nil, []uint32{}},
+		{[]uint32{1}, []uint32{1, 2, 3}},
+		{[]uint32{1, 2}, []uint32{1, 2, 3, 4, 5}},
+		{[]uint32{3, 0}, []uint32{3, 6, 7}},
+	}
+	for _, test := range tests {
+		if got := wire.ConnectStreams(test.streams); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ConnectStreams(%v): got %v want %v", test.streams,
+				got, test.want)
+		}
+	}
+}
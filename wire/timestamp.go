@@ -0,0 +1,17 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import "time"
+
+// Timestamp returns t in the form in which times are encoded in messages and
+// objects, which is a whole number of seconds in UTC with no reading of the
+// monotonic clock. Every time in this package is constructed and decoded in
+// this form, so a time which has been through an encoding and a decoding is
+// only equal to its original, with == or reflect.DeepEqual, if the original
+// was passed through Timestamp.
+func Timestamp(t time.Time) time.Time {
+	return time.Unix(t.Unix(), 0).UTC()
+}
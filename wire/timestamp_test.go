@@ -0,0 +1,67 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestTimestamp(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	tests := []struct {
+		in  time.Time
+		out time.Time
+	}{
+		{time.Date(2016, 5, 1, 12, 0, 0, 999999999, time.UTC),
+			time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{time.Date(2016, 5, 1, 17, 0, 0, 1, zone),
+			time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{time.Unix(1500000000, 0), time.Unix(1500000000, 0).UTC()},
+		{time.Time{}, time.Time{}},
+	}
+
+	for i, test := range tests {
+		if got := wire.Timestamp(test.in); got != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, got)
+		}
+	}
+
+	// The monotonic clock reading is removed.
+	now := time.Now()
+	if got := wire.Timestamp(now); got != wire.Timestamp(got) ||
+		!reflect.DeepEqual(got, time.Unix(now.Unix(), 0).UTC()) {
+		t.Errorf("time from the clock was not normalized: %v", got)
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	now := time.Now()
+
+	header := wire.NewObjectHeader(0, now, wire.ObjectTypeMsg, 1, 1)
+	decoded, err := wire.DecodeObjectHeader(bytes.NewReader(wire.Encode(
+		wire.NewMsgObject(header, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Expiration(), wire.Timestamp(now)) ||
+		!reflect.DeepEqual(header.Expiration(), wire.Timestamp(now)) {
+		t.Errorf("expected expiration %v, got %v and %v", wire.Timestamp(now),
+			header.Expiration(), decoded.Expiration())
+	}
+
+	na := wire.NewNetAddressIPPort(nil, 8444, 1, 0)
+	if na.Timestamp != wire.Timestamp(na.Timestamp) {
+		t.Errorf("net address timestamp was not normalized: %v", na.Timestamp)
+	}
+	version := wire.NewMsgVersion(na, na, 1, []uint32{1})
+	if version.Timestamp != wire.Timestamp(version.Timestamp) {
+		t.Errorf("version timestamp was not normalized: %v", version.Timestamp)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wstransport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrTextMessage is returned by Read if the remote side sends a text
+	// message.
+	ErrTextMessage = errors.New("received text WebSocket message")
+
+	// ErrBadFrame is returned by Read if a WebSocket message does not
+	// contain exactly one wire message.
+	ErrBadFrame = errors.New("WebSocket message is not one wire message")
+
+	// ErrTooLarge is returned by Write if the header of a wire message
+	// gives a length greater than wire.MaxMessagePayload.
+	ErrTooLarge = errors.New("wire message is too large")
+)
+
+// Conn is a net.Conn which sends each wire message written to it as one
+// binary WebSocket message.
+type Conn struct {
+	ws *websocket.Conn
+
+	rmtx sync.Mutex
+	rbuf bytes.Reader
+
+	wmtx    sync.Mutex
+	pending []byte
+}
+
+// NewConn wraps a WebSocket connection.
+func NewConn(ws *websocket.Conn) *Conn {
+	ws.SetReadLimit(wire.MessageHeaderSize + wire.MaxMessagePayload)
+	return &Conn{ws: ws}
+}
+
+// Read reads from the current WebSocket message, or waits for the next
+// one once it has all been read.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.rmtx.Lock()
+	defer c.rmtx.Unlock()
+
+	for c.rbuf.Len() == 0 {
+		typ, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if typ != websocket.BinaryMessage {
+			return 0, ErrTextMessage
+		}
+		if len(data) < wire.MessageHeaderSize ||
+			int(binary.BigEndian.Uint32(data[16:20])) !=
+				len(data)-wire.MessageHeaderSize {
+			return 0, ErrBadFrame
+		}
+		c.rbuf.Reset(data)
+	}
+	return c.rbuf.Read(b)
+}
+
+// Write buffers data until it contains a whole wire message, which is then
+// sent as one WebSocket message.
+func (c *Conn) Write(b []byte) (int, error) {
+	c.wmtx.Lock()
+	defer c.wmtx.Unlock()
+
+	c.pending = append(c.pending, b...)
+	for len(c.pending) >= wire.MessageHeaderSize {
+		length := binary.BigEndian.Uint32(c.pending[16:20])
+		if length > wire.MaxMessagePayload {
+			c.pending = nil
+			return 0, ErrTooLarge
+		}
+		size := wire.MessageHeaderSize + int(length)
+		if len(c.pending) < size {
+			break
+		}
+
+		err := c.ws.WriteMessage(websocket.BinaryMessage, c.pending[:size])
+		c.pending = c.pending[size:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Close closes the WebSocket connection.
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
+
+// LocalAddr returns the local address of the underlying connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the underlying connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
+
+// Dial opens a WebSocket connection to the given URL.
+func Dial(url string, header http.Header) (*Conn, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(ws), nil
+}
+
+// Handler returns an http.Handler which upgrades requests to WebSocket
+// connections and passes them to accept. Requests from any origin are
+// accepted, since Bitmessage nodes do not rely on cookies.
+func Handler(accept func(net.Conn)) http.Handler {
+	upgrader := &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		accept(NewConn(ws))
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wstransport_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/mockpeer"
+	"github.com/DanielKrawisz/bmutil/peer"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wstransport"
+	"github.com/gorilla/websocket"
+)
+
+// wsURL returns the WebSocket URL of a test server.
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// TestPeer tests that peers can complete the handshake and exchange
+// messages over WebSocket.
+func TestPeer(t *testing.T) {
+	cfgIn := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		Nonces: peer.NewNonceSet()}
+	cfgOut := &peer.Config{Net: wire.MainNet, Streams: []uint32{1},
+		Nonces: peer.NewNonceSet()}
+
+	inbound := make(chan *peer.Peer, 1)
+	server := httptest.NewServer(wstransport.Handler(func(conn net.Conn) {
+		p, err := peer.NewInbound(cfgIn, conn)
+		if err != nil {
+			inbound <- nil
+			return
+		}
+		inbound <- p
+		<-p.Done()
+	}))
+	defer server.Close()
+
+	conn, err := wstransport.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	out, err := peer.NewOutbound(cfgOut, conn)
+	if err != nil {
+		t.Fatalf("NewOutbound: %v", err)
+	}
+	defer out.Disconnect(nil)
+	in := <-inbound
+	if in == nil {
+		t.Fatal("NewInbound failed")
+	}
+	defer in.Disconnect(nil)
+
+	if err := out.QueueMessage(wire.NewMsgPong()); err != nil {
+		t.Fatalf("QueueMessage: %v", err)
+	}
+	select {
+	case msg := <-in.In():
+		if msg.Command() != wire.CmdPong {
+			t.Errorf("got %s want %s", msg.Command(), wire.CmdPong)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+// TestOneMessagePerFrame tests that each wire message is sent as one
+// WebSocket message, however it is written.
+func TestOneMessagePerFrame(t *testing.T) {
+	frames := make(chan []byte, 10)
+	upgrader := &websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			close(frames)
+			return
+		}
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- data
+		}
+	}))
+	defer server.Close()
+
+	conn, err := wstransport.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	pong := mockpeer.Frame(wire.MainNet, wire.CmdPong, nil)
+	version := wire.Encode(mockpeer.Version(1, []uint32{1}))
+	versionFrame := mockpeer.Frame(wire.MainNet, wire.CmdVersion, version)
+
+	// Two messages in one write, then one message in two writes.
+	conn.Write(append(append([]byte(nil), pong...), versionFrame[:30]...))
+	conn.Write(versionFrame[30:])
+	conn.Close()
+
+	want := [][]byte{pong, versionFrame}
+	for i, w := range want {
+		got := <-frames
+		if string(got) != string(w) {
+			t.Errorf("frame %d: got %x want %x", i, got, w)
+		}
+	}
+	if extra, ok := <-frames; ok {
+		t.Errorf("unexpected frame %x", extra)
+	}
+}
+
+// TestBadFrame tests that WebSocket messages which are not exactly one
+// wire message are rejected.
+func TestBadFrame(t *testing.T) {
+	pong := mockpeer.Frame(wire.MainNet, wire.CmdPong, nil)
+	tests := []struct {
+		typ  int
+		data []byte
+		err  error
+	}{
+		{websocket.BinaryMessage, pong, nil},
+		{websocket.BinaryMessage, append(pong, pong...), wstransport.ErrBadFrame},
+		{websocket.BinaryMessage, pong[:10], wstransport.ErrBadFrame},
+		{websocket.TextMessage, pong, wstransport.ErrTextMessage},
+	}
+
+	for i, test := range tests {
+		conns := make(chan net.Conn, 1)
+		done := make(chan struct{})
+		server := httptest.NewServer(wstransport.Handler(func(conn net.Conn) {
+			conns <- conn
+			<-done
+		}))
+
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		ws.WriteMessage(test.typ, test.data)
+
+		conn := <-conns
+		_, _, err = wire.ReadMessage(conn, wire.MainNet)
+		if err != test.err {
+			t.Errorf("ReadMessage #%d: got %v want %v", i, err, test.err)
+		}
+		close(done)
+		ws.Close()
+		server.Close()
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package wstransport carries Bitmessage wire messages over WebSocket, so that
+browsers and clients behind restrictive firewalls can connect to a node
+through an HTTP gateway.
+
+Each wire message, header included, is sent as one binary WebSocket
+message. Conn wraps a WebSocket connection as a net.Conn, so it can be
+given to the peer package like any other connection:
+
+	http.Handle("/bitmessage", wstransport.Handler(func(conn net.Conn) {
+		p, err := peer.NewInbound(cfg, conn)
+		...
+	}))
+
+	conn, err := wstransport.Dial("wss://example.com/bitmessage", nil)
+	p, err := peer.NewOutbound(cfg, conn)
+*/
+package wstransport